@@ -10,10 +10,14 @@ import (
 	"go.uber.org/zap"
 	"strings"
 	"teamide/internal/context"
+	"teamide/internal/module/module_consul"
 	"teamide/internal/module/module_database"
 	"teamide/internal/module/module_datamove"
 	"teamide/internal/module/module_elasticsearch"
+	"teamide/internal/module/module_etcd"
 	"teamide/internal/module/module_file_manager"
+	"teamide/internal/module/module_grpc"
+	"teamide/internal/module/module_http"
 	"teamide/internal/module/module_id"
 	"teamide/internal/module/module_javascript"
 	"teamide/internal/module/module_kafka"
@@ -21,9 +25,11 @@ import (
 	"teamide/internal/module/module_login"
 	"teamide/internal/module/module_maker"
 	"teamide/internal/module/module_mongodb"
+	"teamide/internal/module/module_mqtt"
 	"teamide/internal/module/module_net"
 	"teamide/internal/module/module_node"
 	"teamide/internal/module/module_power"
+	"teamide/internal/module/module_rabbitmq"
 	"teamide/internal/module/module_redis"
 	"teamide/internal/module/module_register"
 	"teamide/internal/module/module_setting"
@@ -57,6 +63,8 @@ func NewApi(ServerContext *context.ServerContext) (api *Api, err error) {
 		logService:             module_log.NewLogService(ServerContext),
 		apiCache:               make(map[string]*base.ApiWorker),
 	}
+	api.kafkaLagService = module_kafka.NewLagService(api.toolboxService)
+	api.esDiagnoseService = module_elasticsearch.NewDiagnoseService(api.toolboxService)
 	var apis []*base.ApiWorker
 	apis, err = api.GetApis()
 	if err != nil {
@@ -100,6 +108,14 @@ func NewApi(ServerContext *context.ServerContext) (api *Api, err error) {
 	if err != nil {
 		return
 	}
+	err = api.kafkaLagService.ServerReady()
+	if err != nil {
+		return
+	}
+	err = api.esDiagnoseService.ServerReady()
+	if err != nil {
+		return
+	}
 
 	return
 }
@@ -135,6 +151,8 @@ type Api struct {
 	powerRouteService      *module_power.PowerRouteService
 	powerUserService       *module_power.PowerUserService
 	logService             *module_log.LogService
+	kafkaLagService        *module_kafka.LagService
+	esDiagnoseService      *module_elasticsearch.DiagnoseService
 	settingService         *module_setting.SettingService
 	idService              *module_id.IDService
 	installService         *InstallService
@@ -177,8 +195,12 @@ func (this_ *Api) GetApis() (apis []*base.ApiWorker, err error) {
 	apis = append(apis, module_database.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_datamove.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_zookeeper.NewApi(this_.toolboxService).GetApis()...)
-	apis = append(apis, module_kafka.NewApi(this_.toolboxService).GetApis()...)
-	apis = append(apis, module_elasticsearch.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_etcd.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_consul.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_kafka.NewApi(this_.toolboxService, this_.kafkaLagService).GetApis()...)
+	apis = append(apis, module_rabbitmq.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_mqtt.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_elasticsearch.NewApi(this_.toolboxService, this_.esDiagnoseService).GetApis()...)
 	apis = append(apis, module_log.NewApi(this_.logService).GetApis()...)
 	apis = append(apis, module_power.NewApi(this_.powerRoleService).GetApis()...)
 	apis = append(apis, module_tools.NewApi(this_.ServerContext).GetApis()...)
@@ -187,6 +209,8 @@ func (this_ *Api) GetApis() (apis []*base.ApiWorker, err error) {
 	apis = append(apis, module_javascript.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_mongodb.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_net.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_http.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_grpc.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_maker.NewApi(this_.toolboxService).GetApis()...)
 
 	return
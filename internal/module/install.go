@@ -7,7 +7,9 @@ import (
 	"strings"
 	"teamide/internal/context"
 	"teamide/internal/install"
+	"teamide/internal/module/module_elasticsearch"
 	"teamide/internal/module/module_id"
+	"teamide/internal/module/module_kafka"
 	"teamide/internal/module/module_log"
 	"teamide/internal/module/module_login"
 	"teamide/internal/module/module_node"
@@ -132,6 +134,16 @@ func (this_ *InstallService) Install() (err error) {
 		return
 	}
 
+	err = this_.InstallSteps(module_kafka.GetInstallStages())
+	if err != nil {
+		return
+	}
+
+	err = this_.InstallSteps(module_elasticsearch.GetInstallStages())
+	if err != nil {
+		return
+	}
+
 	return
 }
 
@@ -0,0 +1,213 @@
+package module_consul
+
+import (
+	"github.com/gin-gonic/gin"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+)
+
+type api struct {
+	toolboxService *module_toolbox.ToolboxService
+}
+
+func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+	return &api{
+		toolboxService: toolboxService,
+	}
+}
+
+var (
+	Power         = base.AppendPower(&base.PowerAction{Action: "consul", Text: "Consul", ShouldLogin: true, StandAlone: true})
+	check         = base.AppendPower(&base.PowerAction{Action: "check", Text: "Consul测试", ShouldLogin: true, StandAlone: true, Parent: Power})
+	infoPower     = base.AppendPower(&base.PowerAction{Action: "info", Text: "Consul信息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	treePower     = base.AppendPower(&base.PowerAction{Action: "tree", Text: "Consul查询Key树", ShouldLogin: true, StandAlone: true, Parent: Power})
+	getPower      = base.AppendPower(&base.PowerAction{Action: "get", Text: "Consul获取Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	putPower      = base.AppendPower(&base.PowerAction{Action: "put", Text: "Consul保存Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	deletePower   = base.AppendPower(&base.PowerAction{Action: "delete", Text: "Consul删除Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	sessionsPower = base.AppendPower(&base.PowerAction{Action: "sessions", Text: "Consul查询会话", ShouldLogin: true, StandAlone: true, Parent: Power})
+	sessionPower  = base.AppendPower(&base.PowerAction{Action: "session", Text: "Consul会话详情", ShouldLogin: true, StandAlone: true, Parent: Power})
+	locksPower    = base.AppendPower(&base.PowerAction{Action: "locks", Text: "Consul查询锁", ShouldLogin: true, StandAlone: true, Parent: Power})
+	closePower    = base.AppendPower(&base.PowerAction{Action: "close", Text: "Consul关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	watchKeyPower       = base.AppendPower(&base.PowerAction{Action: "watch/key", Text: "Consul监听Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	watchWebsocketPower = base.AppendPower(&base.PowerAction{Action: "watch/websocket", Text: "Consul监听WebSocket", ShouldLogin: true, StandAlone: true, Parent: Power})
+	watchClosePower     = base.AppendPower(&base.PowerAction{Action: "watch/close", Text: "Consul监听关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: check, Do: this_.check})
+	apis = append(apis, &base.ApiWorker{Power: infoPower, Do: this_.info})
+	apis = append(apis, &base.ApiWorker{Power: treePower, Do: this_.tree})
+	apis = append(apis, &base.ApiWorker{Power: getPower, Do: this_.get})
+	apis = append(apis, &base.ApiWorker{Power: putPower, Do: this_.put})
+	apis = append(apis, &base.ApiWorker{Power: deletePower, Do: this_.delete})
+	apis = append(apis, &base.ApiWorker{Power: sessionsPower, Do: this_.sessions})
+	apis = append(apis, &base.ApiWorker{Power: sessionPower, Do: this_.session})
+	apis = append(apis, &base.ApiWorker{Power: locksPower, Do: this_.locks})
+	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+
+	apis = append(apis, &base.ApiWorker{Power: watchKeyPower, Do: this_.watchKey})
+	apis = append(apis, &base.ApiWorker{Power: watchWebsocketPower, Do: watcherCache.Websocket, IsWebSocket: true})
+	apis = append(apis, &base.ApiWorker{Power: watchClosePower, Do: watcherCache.Close})
+
+	return
+}
+
+func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *Config, sshConfig *ssh.Config, err error) {
+	config = &Config{}
+	sshConfig, err = this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	// 工具 保存 时 Token 和 密码 已 加密
+	config.Token = this_.toolboxService.DecryptOptionAttr(config.Token)
+	config.Password = this_.toolboxService.DecryptOptionAttr(config.Password)
+	if config.CaCert != "" {
+		config.CaCert = this_.toolboxService.GetFilesFile(config.CaCert)
+	}
+	if config.ClientCert != "" {
+		config.ClientCert = this_.toolboxService.GetFilesFile(config.ClientCert)
+	}
+	if config.ClientKey != "" {
+		config.ClientKey = this_.toolboxService.GetFilesFile(config.ClientKey)
+	}
+	return
+}
+
+func (this_ *api) getService(requestBean *base.RequestBean, c *gin.Context) (res *Service, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	res, err = getService(config, sshConfig)
+	return
+}
+
+type BaseRequest struct {
+	WorkerId  string `json:"workerId"`
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	Base64    bool   `json:"base64"`    // Value 为 Base64 编码 的 二进制 数据
+	Flags     uint64 `json:"flags"`     // 保存 时 的 标记
+	Separator string `json:"separator"` // Key 树 的 分隔符 默认 /
+	Limit     int    `json:"limit"`     // 最多 返回 的 子节点 数 默认 1000
+	Recursive bool   `json:"recursive"` // 删除 时 删除 所有 以 Key 为 前缀 的 Key
+	DryRun    bool   `json:"dryRun"`    // 删除 时 只 统计 数量，不 删除
+	Session   string `json:"session"`   // 会话 ID，查询 锁 时 只 返回 该 会话 持有 的 锁
+	// 保存 时 比较 修改 索引，不 一致 则 失败，0 表示 Key 必须 不存在，为空 不 比较
+	ModifyIndex *uint64 `json:"modifyIndex"`
+}
+
+func (this_ *api) check(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	_, err = this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) info(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	res, err = service.info()
+	return
+}
+
+func (this_ *api) tree(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = service.tree(request)
+	return
+}
+
+func (this_ *api) get(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = service.get(request)
+	return
+}
+
+func (this_ *api) put(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = service.put(request)
+	return
+}
+
+func (this_ *api) delete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = service.delete(request)
+	return
+}
+
+func (this_ *api) sessions(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	res, err = service.sessions()
+	return
+}
+
+func (this_ *api) session(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = service.session(request)
+	return
+}
+
+func (this_ *api) locks(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = service.locks(request)
+	return
+}
+
+func (this_ *api) close(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	watcherCache.RemoveWorker(request.WorkerId)
+	return
+}
@@ -0,0 +1,397 @@
+package module_consul
+
+import (
+	"encoding/base64"
+	"errors"
+	consulApi "github.com/hashicorp/consul/api"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Key 树 最多 返回 的 子节点 数
+	defaultTreeLimit = 1000
+	// 删除 预览 最多 返回 的 Key 数
+	deletePreviewSize = 100
+)
+
+// toText 值 不是 UTF-8 文本 时 使用 Base64 编码
+func toText(bs []byte) (text string, isBase64 bool) {
+	if utf8.Valid(bs) {
+		return string(bs), false
+	}
+	return base64.StdEncoding.EncodeToString(bs), true
+}
+
+func (this_ *BaseRequest) getValue() (value []byte, err error) {
+	if !this_.Base64 {
+		value = []byte(this_.Value)
+		return
+	}
+	value, err = base64.StdEncoding.DecodeString(this_.Value)
+	if err != nil {
+		err = errors.New("value base64 decode error:" + err.Error())
+		return
+	}
+	return
+}
+
+type KeyValue struct {
+	Key         string        `json:"key"`
+	Value       string        `json:"value"`
+	Base64      bool          `json:"base64,omitempty"`
+	Flags       uint64        `json:"flags"`
+	CreateIndex uint64        `json:"createIndex"`
+	ModifyIndex uint64        `json:"modifyIndex"`
+	LockIndex   uint64        `json:"lockIndex"`
+	Session     string        `json:"session,omitempty"` // 持有 锁 的 会话
+	SessionInfo *SessionEntry `json:"sessionInfo,omitempty"`
+}
+
+func toKeyValue(pair *consulApi.KVPair) (res *KeyValue) {
+	if pair == nil {
+		return
+	}
+	res = &KeyValue{
+		Key:         pair.Key,
+		Flags:       pair.Flags,
+		CreateIndex: pair.CreateIndex,
+		ModifyIndex: pair.ModifyIndex,
+		LockIndex:   pair.LockIndex,
+		Session:     pair.Session,
+	}
+	res.Value, res.Base64 = toText(pair.Value)
+	return
+}
+
+type SessionEntry struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Node        string   `json:"node"`
+	Behavior    string   `json:"behavior"` // 会话 失效 时 对 锁 的 处理，release 或 delete
+	Ttl         string   `json:"ttl"`
+	LockDelay   string   `json:"lockDelay"`
+	CreateIndex uint64   `json:"createIndex"`
+	NodeChecks  []string `json:"nodeChecks,omitempty"`
+}
+
+func toSessionEntry(entry *consulApi.SessionEntry) (res *SessionEntry) {
+	if entry == nil {
+		return
+	}
+	res = &SessionEntry{
+		Id:          entry.ID,
+		Name:        entry.Name,
+		Node:        entry.Node,
+		Behavior:    entry.Behavior,
+		Ttl:         entry.TTL,
+		LockDelay:   entry.LockDelay.String(),
+		CreateIndex: entry.CreateIndex,
+		NodeChecks:  entry.NodeChecks,
+	}
+	if len(res.NodeChecks) == 0 {
+		res.NodeChecks = entry.Checks
+	}
+	return
+}
+
+func (this_ *Service) info() (res interface{}, err error) {
+	q, cancel := this_.queryOptions()
+	defer cancel()
+	data := map[string]interface{}{}
+	leader, err := this_.Status().LeaderWithQueryOptions(q)
+	if err != nil {
+		return
+	}
+	data["leader"] = leader
+	// 以下 信息 需要 额外 的 ACL 权限，获取 失败 时 忽略
+	if peers, e := this_.Status().PeersWithQueryOptions(q); e == nil {
+		data["peers"] = peers
+	}
+	if datacenters, e := this_.Catalog().Datacenters(); e == nil {
+		data["datacenters"] = datacenters
+	}
+	if self, e := this_.Agent().Self(); e == nil {
+		if config := self["Config"]; config != nil {
+			data["version"] = config["Version"]
+			data["nodeName"] = config["NodeName"]
+			data["datacenter"] = config["Datacenter"]
+			data["server"] = config["Server"]
+		}
+	}
+	res = data
+	return
+}
+
+// TreeNode Key 树 的 节点，目录 的 Name 以 分隔符 结尾
+type TreeNode struct {
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	HasValue    bool   `json:"hasValue"`
+	HasChildren bool   `json:"hasChildren"`
+}
+
+// tree 使用 Consul 的 分隔符 查询 Key 的 下一级，目录 由 服务端 合并
+func (this_ *Service) tree(request *BaseRequest) (res interface{}, err error) {
+	separator := request.Separator
+	if separator == "" {
+		separator = "/"
+	}
+	limit := request.Limit
+	if limit <= 0 {
+		limit = defaultTreeLimit
+	}
+	parent := request.Key
+	if parent != "" && !strings.HasSuffix(parent, separator) {
+		parent += separator
+	}
+	q, cancel := this_.queryOptions()
+	defer cancel()
+	keys, meta, err := this_.KV().Keys(parent, separator, q)
+	if err != nil {
+		return
+	}
+	sort.Strings(keys)
+	var children []*TreeNode
+	var hasValue bool
+	var more bool
+	for _, key := range keys {
+		name := key[len(parent):]
+		if name == "" {
+			hasValue = true
+			continue
+		}
+		if len(children) >= limit {
+			more = true
+			break
+		}
+		node := &TreeNode{Name: name, Key: key}
+		// 服务端 返回 的 目录 以 分隔符 结尾，目录 本身 是否 有 值 在 查询 时 获取
+		if strings.HasSuffix(name, separator) {
+			node.HasChildren = true
+		} else {
+			node.HasValue = true
+		}
+		children = append(children, node)
+	}
+	data := map[string]interface{}{}
+	data["key"] = request.Key
+	data["hasValue"] = hasValue
+	data["children"] = children
+	data["more"] = more
+	data["total"] = len(keys)
+	data["index"] = meta.LastIndex
+	res = data
+	return
+}
+
+func (this_ *Service) get(request *BaseRequest) (res interface{}, err error) {
+	q, cancel := this_.queryOptions()
+	defer cancel()
+	pair, meta, err := this_.KV().Get(request.Key, q)
+	if err != nil {
+		err = errors.New("key [" + request.Key + "] get error:" + err.Error())
+		return
+	}
+	data := map[string]interface{}{}
+	data["index"] = meta.LastIndex
+	if pair != nil {
+		kv := toKeyValue(pair)
+		if pair.Session != "" {
+			var entry *consulApi.SessionEntry
+			// 会话 可能 刚好 失效，忽略 错误
+			entry, _, _ = this_.Session().Info(pair.Session, q)
+			kv.SessionInfo = toSessionEntry(entry)
+		}
+		data["kv"] = kv
+	}
+	res = data
+	return
+}
+
+func (this_ *Service) put(request *BaseRequest) (res interface{}, err error) {
+	if request.Key == "" {
+		err = errors.New("key can not be empty")
+		return
+	}
+	value, err := request.getValue()
+	if err != nil {
+		return
+	}
+	pair := &consulApi.KVPair{
+		Key:   request.Key,
+		Value: value,
+		Flags: request.Flags,
+	}
+	w, cancel := this_.writeOptions()
+	defer cancel()
+	if request.ModifyIndex != nil {
+		pair.ModifyIndex = *request.ModifyIndex
+		var ok bool
+		ok, _, err = this_.KV().CAS(pair, w)
+		if err != nil {
+			err = errors.New("key [" + request.Key + "] put error:" + err.Error())
+			return
+		}
+		if !ok {
+			err = errors.New("key [" + request.Key + "] has been modified, modify index is not " + strconv.FormatUint(*request.ModifyIndex, 10))
+			return
+		}
+		return
+	}
+	_, err = this_.KV().Put(pair, w)
+	if err != nil {
+		err = errors.New("key [" + request.Key + "] put error:" + err.Error())
+		return
+	}
+	return
+}
+
+func (this_ *Service) delete(request *BaseRequest) (res interface{}, err error) {
+	if request.Key == "" {
+		err = errors.New("key can not be empty")
+		return
+	}
+	data := map[string]interface{}{}
+	if request.DryRun {
+		q, cancel := this_.queryOptions()
+		defer cancel()
+		var keys []string
+		if request.Recursive {
+			keys, _, err = this_.KV().Keys(request.Key, "", q)
+			if err != nil {
+				return
+			}
+		} else {
+			var pair *consulApi.KVPair
+			pair, _, err = this_.KV().Get(request.Key, q)
+			if err != nil {
+				return
+			}
+			if pair != nil {
+				keys = append(keys, pair.Key)
+			}
+		}
+		data["count"] = len(keys)
+		if len(keys) > deletePreviewSize {
+			keys = keys[:deletePreviewSize]
+		}
+		data["keys"] = keys
+		res = data
+		return
+	}
+	w, cancel := this_.writeOptions()
+	defer cancel()
+	if request.Recursive {
+		_, err = this_.KV().DeleteTree(request.Key, w)
+	} else {
+		_, err = this_.KV().Delete(request.Key, w)
+	}
+	if err != nil {
+		err = errors.New("key [" + request.Key + "] delete error:" + err.Error())
+		return
+	}
+	return
+}
+
+func (this_ *Service) sessions() (res interface{}, err error) {
+	q, cancel := this_.queryOptions()
+	defer cancel()
+	list, _, err := this_.Session().List(q)
+	if err != nil {
+		return
+	}
+	var sessions []*SessionEntry
+	for _, one := range list {
+		sessions = append(sessions, toSessionEntry(one))
+	}
+	res = sessions
+	return
+}
+
+// session 查询 会话 详情 及 其 持有 的 锁
+func (this_ *Service) session(request *BaseRequest) (res interface{}, err error) {
+	if request.Session == "" {
+		err = errors.New("session can not be empty")
+		return
+	}
+	q, cancel := this_.queryOptions()
+	defer cancel()
+	entry, _, err := this_.Session().Info(request.Session, q)
+	if err != nil {
+		return
+	}
+	if entry == nil {
+		err = errors.New("session [" + request.Session + "] not exist")
+		return
+	}
+	locks, err := this_.listLocks(request.Key, request.Session)
+	if err != nil {
+		return
+	}
+	data := map[string]interface{}{}
+	data["session"] = toSessionEntry(entry)
+	data["locks"] = locks
+	res = data
+	return
+}
+
+type LockInfo struct {
+	Key         string        `json:"key"`
+	Session     string        `json:"session"`
+	LockIndex   uint64        `json:"lockIndex"`
+	ModifyIndex uint64        `json:"modifyIndex"`
+	Flags       uint64        `json:"flags"`
+	SessionInfo *SessionEntry `json:"sessionInfo,omitempty"`
+}
+
+// listLocks 查询 前缀 下 被 会话 持有 的 Key，session 不为 空 时 只 返回 该 会话 持有 的
+func (this_ *Service) listLocks(prefix string, session string) (res []*LockInfo, err error) {
+	q, cancel := this_.queryOptions()
+	defer cancel()
+	pairs, _, err := this_.KV().List(prefix, q)
+	if err != nil {
+		return
+	}
+	for _, pair := range pairs {
+		if pair.Session == "" || (session != "" && pair.Session != session) {
+			continue
+		}
+		res = append(res, &LockInfo{
+			Key:         pair.Key,
+			Session:     pair.Session,
+			LockIndex:   pair.LockIndex,
+			ModifyIndex: pair.ModifyIndex,
+			Flags:       pair.Flags,
+		})
+	}
+	return
+}
+
+func (this_ *Service) locks(request *BaseRequest) (res interface{}, err error) {
+	locks, err := this_.listLocks(request.Key, request.Session)
+	if err != nil {
+		return
+	}
+	if len(locks) > 0 {
+		// 补充 会话 信息，同一 会话 只 查询 一次
+		q, cancel := this_.queryOptions()
+		defer cancel()
+		var list []*consulApi.SessionEntry
+		list, _, err = this_.Session().List(q)
+		if err != nil {
+			return
+		}
+		sessionCache := map[string]*SessionEntry{}
+		for _, one := range list {
+			sessionCache[one.ID] = toSessionEntry(one)
+		}
+		for _, one := range locks {
+			one.SessionInfo = sessionCache[one.Session]
+		}
+	}
+	res = locks
+	return
+}
@@ -0,0 +1,173 @@
+package module_consul
+
+import (
+	"context"
+	"errors"
+	consulApi "github.com/hashicorp/consul/api"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	goSSH "golang.org/x/crypto/ssh"
+	"net"
+	"net/http"
+	"os"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+type Config struct {
+	Address            string `json:"address"`
+	Scheme             string `json:"scheme,omitempty"` // http https
+	Datacenter         string `json:"datacenter,omitempty"`
+	Token              string `json:"token,omitempty"`
+	Username           string `json:"username,omitempty"` // HTTP Basic 认证
+	Password           string `json:"password,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	CaCert             string `json:"caCert,omitempty"`
+	ClientCert         string `json:"clientCert,omitempty"`
+	ClientKey          string `json:"clientKey,omitempty"`
+}
+
+type Service struct {
+	*consulApi.Client
+	config    *Config
+	transport *http.Transport
+	sshClient *goSSH.Client
+}
+
+func (this_ *Service) Close() {
+	if this_.transport != nil {
+		this_.transport.CloseIdleConnections()
+	}
+	if this_.sshClient != nil {
+		_ = this_.sshClient.Close()
+	}
+}
+
+// queryOptions 普通 查询 超时 30 秒，监听 的 阻塞 查询 单独 设置
+func (this_ *Service) queryOptions() (*consulApi.QueryOptions, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return (&consulApi.QueryOptions{}).WithContext(ctx), cancel
+}
+
+func (this_ *Service) writeOptions() (*consulApi.WriteOptions, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	return (&consulApi.WriteOptions{}).WithContext(ctx), cancel
+}
+
+func newService(config *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	res = &Service{config: config}
+	if config.Address == "" {
+		err = errors.New("consul address is empty")
+		return
+	}
+	// 不 使用 consulApi.DefaultConfig，避免 读取 环境 变量 中 的 地址 和 Token
+	res.transport = http.DefaultTransport.(*http.Transport).Clone()
+	if sshConfig != nil {
+		res.sshClient, err = ssh.NewClient(*sshConfig)
+		if err != nil {
+			return
+		}
+		sshClient := res.sshClient
+		res.transport.Proxy = nil
+		res.transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return sshClient.Dial(network, addr)
+		}
+	}
+	clientConfig, err := newClientConfig(config, res.transport)
+	if err != nil {
+		res.Close()
+		return
+	}
+	res.Client, err = consulApi.NewClient(clientConfig)
+	if err != nil {
+		res.Close()
+		return
+	}
+	q, cancel := res.queryOptions()
+	defer cancel()
+	_, err = res.Status().LeaderWithQueryOptions(q)
+	if err != nil {
+		res.Close()
+		return
+	}
+	return
+}
+
+// newClientConfig consulApi.NewClient 会 合并 DefaultConfig，配置 为空 的 项 使用 进程 的 环境 变量
+// 自己 创建 HttpClient，不 使用 CONSUL_CACERT、CONSUL_CLIENT_CERT 等 证书 配置
+// TokenFile 设置 为 空 文件，Token 为空 时 不 使用 CONSUL_HTTP_TOKEN、CONSUL_HTTP_TOKEN_FILE
+func newClientConfig(config *Config, transport *http.Transport) (clientConfig *consulApi.Config, err error) {
+	tlsConfig := consulApi.TLSConfig{
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		CAFile:             config.CaCert,
+		CertFile:           config.ClientCert,
+		KeyFile:            config.ClientKey,
+	}
+	httpClient, err := consulApi.NewHttpClient(transport, tlsConfig)
+	if err != nil {
+		return
+	}
+	clientConfig = &consulApi.Config{
+		Address:    config.Address,
+		Scheme:     config.Scheme,
+		Datacenter: config.Datacenter,
+		Token:      config.Token,
+		TokenFile:  os.DevNull,
+		Transport:  transport,
+		HttpClient: httpClient,
+		TLSConfig:  tlsConfig,
+	}
+	if clientConfig.Scheme == "" {
+		clientConfig.Scheme = "http"
+	}
+	if config.Username != "" {
+		clientConfig.HttpAuth = &consulApi.HttpBasicAuth{
+			Username: config.Username,
+			Password: config.Password,
+		}
+	}
+	return
+}
+
+func getService(config *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	key := "consul-" + config.Scheme + "-" + config.Address + "-" + config.Datacenter
+	if config.Token != "" {
+		key += "-" + base.GetMd5String(key+config.Token)
+	}
+	if config.Username != "" {
+		key += "-" + base.GetMd5String(key+config.Username)
+	}
+	if config.Password != "" {
+		key += "-" + base.GetMd5String(key+config.Password)
+	}
+	if config.Scheme == "https" {
+		key += "-tls-" + base.GetMd5String(config.CaCert+config.ClientCert+config.ClientKey)
+	}
+	if sshConfig != nil {
+		key += "-ssh-" + sshConfig.Address
+		key += "-ssh-" + sshConfig.Username
+	}
+	var serviceInfo *base.ServiceInfo
+	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
+		var s *Service
+		s, err = newService(config, sshConfig)
+		if err != nil {
+			util.Logger.Error("getConsulService error", zap.Any("key", key), zap.Error(err))
+			return
+		}
+		res = &base.ServiceInfo{
+			WaitTime:    10 * 60 * 1000,
+			LastUseTime: util.GetNowMilli(),
+			Service:     s,
+			Stop:        s.Close,
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = serviceInfo.Service.(*Service)
+	serviceInfo.SetLastUseTime()
+	return
+}
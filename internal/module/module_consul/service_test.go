@@ -0,0 +1,44 @@
+package module_consul
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestNewServiceIgnoreEnv(t *testing.T) {
+	var tokens []string
+	var lock sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		tokens = append(tokens, r.Header.Get("X-Consul-Token"))
+		lock.Unlock()
+		_, _ = w.Write([]byte(`"127.0.0.1:8300"`))
+	}))
+	defer server.Close()
+
+	// 进程 的 环境 变量 不能 用于 用户 的 连接，文件 不存在 时 读取 会 出错
+	missing := filepath.Join(t.TempDir(), "missing")
+	t.Setenv("CONSUL_HTTP_ADDR", "127.0.0.1:1")
+	t.Setenv("CONSUL_HTTP_TOKEN", "env-token")
+	t.Setenv("CONSUL_HTTP_TOKEN_FILE", missing)
+	t.Setenv("CONSUL_CACERT", missing)
+	t.Setenv("CONSUL_CLIENT_CERT", missing)
+	t.Setenv("CONSUL_CLIENT_KEY", missing)
+
+	for _, token := range []string{"", "token", "env-token"} {
+		s, err := newService(&Config{Address: server.Listener.Addr().String(), Token: token}, nil)
+		if err != nil {
+			t.Fatalf("token [%s] new service error:%s", token, err)
+		}
+		lock.Lock()
+		res := tokens[len(tokens)-1]
+		lock.Unlock()
+		if res != token {
+			t.Errorf("token [%s] expect request token [%s], got [%s]", token, token, res)
+		}
+		s.Close()
+	}
+}
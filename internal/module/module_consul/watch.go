@@ -0,0 +1,204 @@
+package module_consul
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	consulApi "github.com/hashicorp/consul/api"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+type WatchRequest struct {
+	WorkerId string `json:"workerId"`
+	Key      string `json:"key"`
+	WatchKey string `json:"watchKey"`
+	Prefix   bool   `json:"prefix"` // 监听 所有 以 WatchKey 为 前缀 的 Key，WatchKey 为 空 时 监听 所有 Key
+}
+
+type WatchMessage struct {
+	Type   string    `json:"type"` // put delete lock unlock error
+	Key    string    `json:"key,omitempty"`
+	Kv     *KeyValue `json:"kv,omitempty"`
+	PrevKv *KeyValue `json:"prevKv,omitempty"`
+	Index  uint64    `json:"index,omitempty"`
+	Time   int64     `json:"time"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// watcher 使用 阻塞 查询 等待 索引 变化，比较 前后 两次 结果 得到 变化 的 Key
+// 每个 监听 会话 使用 独立 的 连接，避免 缓存 的 服务 空闲 回收 后 关闭 SSH 隧道
+type watcher struct {
+	*base.WebsocketSession
+	request     *WatchRequest
+	config      *Config
+	sshConfig   *ssh.Config
+	service     *Service
+	serviceLock sync.Mutex
+}
+
+var watcherCache = base.NewWebsocketCache("consul watch")
+
+func (this_ *watcher) write(msg *WatchMessage) {
+	msg.Time = util.GetNowMilli()
+	if err := this_.WriteJSON(msg); err != nil {
+		go this_.Stop()
+	}
+}
+
+func (this_ *watcher) sleep() bool {
+	select {
+	case <-this_.Done():
+		return false
+	case <-time.After(time.Second):
+		return true
+	}
+}
+
+func (this_ *watcher) query(service *Service, index uint64) (pairs consulApi.KVPairs, meta *consulApi.QueryMeta, err error) {
+	q := (&consulApi.QueryOptions{WaitIndex: index, WaitTime: 5 * time.Minute}).WithContext(this_.Context())
+	if this_.request.Prefix {
+		return service.KV().List(this_.request.WatchKey, q)
+	}
+	var pair *consulApi.KVPair
+	pair, meta, err = service.KV().Get(this_.request.WatchKey, q)
+	if pair != nil {
+		pairs = append(pairs, pair)
+	}
+	return
+}
+
+func (this_ *watcher) watch() {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("consul watch error", zap.Any("error", e))
+		}
+		this_.Stop()
+	}()
+	service, err := this_.connect()
+	if err != nil {
+		this_.write(&WatchMessage{Type: "error", Error: err.Error()})
+		return
+	}
+	if service == nil {
+		return
+	}
+	var index uint64
+	// 第一次 查询 的 结果 不 推送
+	var last map[string]*consulApi.KVPair
+	for {
+		pairs, meta, err := this_.query(service, index)
+		if this_.IsStopped() {
+			return
+		}
+		if err != nil {
+			this_.write(&WatchMessage{Type: "error", Error: err.Error()})
+			if !this_.sleep() {
+				return
+			}
+			continue
+		}
+		// 索引 变小 时 需要 重新 开始，参考 Consul 阻塞 查询 的 说明
+		if meta.LastIndex < index {
+			index = 0
+		} else {
+			index = meta.LastIndex
+		}
+		now := map[string]*consulApi.KVPair{}
+		for _, pair := range pairs {
+			now[pair.Key] = pair
+		}
+		if last != nil {
+			this_.diff(last, now, meta.LastIndex)
+		}
+		last = now
+	}
+}
+
+func (this_ *watcher) diff(last map[string]*consulApi.KVPair, now map[string]*consulApi.KVPair, index uint64) {
+	for key, pair := range now {
+		prev := last[key]
+		if prev != nil && prev.ModifyIndex == pair.ModifyIndex {
+			continue
+		}
+		msg := &WatchMessage{Type: "put", Key: key, Kv: toKeyValue(pair), PrevKv: toKeyValue(prev), Index: index}
+		var prevSession string
+		if prev != nil {
+			prevSession = prev.Session
+		}
+		if pair.Session != prevSession {
+			if pair.Session != "" {
+				msg.Type = "lock"
+			} else {
+				msg.Type = "unlock"
+			}
+		}
+		this_.write(msg)
+	}
+	for key, prev := range last {
+		if now[key] == nil {
+			this_.write(&WatchMessage{Type: "delete", Key: key, PrevKv: toKeyValue(prev), Index: index})
+		}
+	}
+}
+
+// connect 创建 会话 的 连接，连接 过程 中 已 停止 时 返回 nil
+func (this_ *watcher) connect() (service *Service, err error) {
+	s, err := newService(this_.config, this_.sshConfig)
+	if err != nil {
+		return
+	}
+	this_.serviceLock.Lock()
+	defer this_.serviceLock.Unlock()
+	select {
+	case <-this_.Done():
+		s.Close()
+		return
+	default:
+	}
+	this_.service = s
+	service = s
+	return
+}
+
+func (this_ *watcher) Start() (err error) {
+	go this_.watch()
+	return
+}
+
+// OnStop 监听 使用 会话 的 Context，会话 结束 时 已 取消，然后 关闭 连接
+func (this_ *watcher) OnStop() {
+	this_.serviceLock.Lock()
+	defer this_.serviceLock.Unlock()
+	if this_.service != nil {
+		this_.service.Close()
+	}
+}
+
+// watchKey 创建 监听 会话，返回 key 用于 建立 websocket
+func (this_ *api) watchKey(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &WatchRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.WatchKey == "" && !request.Prefix {
+		err = errors.New("watch key can not be empty")
+		return
+	}
+	one := &watcher{
+		WebsocketSession: base.NewWebsocketSession(requestBean, request.WorkerId),
+		request:          request,
+		config:           config,
+		sshConfig:        sshConfig,
+	}
+	res = watcherCache.Add(one)
+	return
+}
@@ -0,0 +1,68 @@
+package module_consul
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"teamide/pkg/base"
+	"testing"
+)
+
+func newTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/status/leader" {
+			_, _ = w.Write([]byte(`"127.0.0.1:8300"`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+}
+
+func TestWatcherConnect(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	config := &Config{Address: server.Listener.Addr().String()}
+
+	one := &watcher{
+		WebsocketSession: base.NewWebsocketSession(nil, ""),
+		request:          &WatchRequest{WatchKey: "a"},
+		config:           config,
+	}
+	service, err := one.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每个 会话 使用 独立 的 连接
+	other := &watcher{
+		WebsocketSession: base.NewWebsocketSession(nil, ""),
+		config:           config,
+	}
+	otherService, err := other.connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service == nil || service != one.service || otherService == service {
+		t.Errorf("watcher expect own service")
+	}
+	one.Stop()
+	one.OnStop()
+	other.Stop()
+	other.OnStop()
+
+	// 连接 过程 中 已 停止 的 会话 不 保存 连接
+	stopped := &watcher{
+		WebsocketSession: base.NewWebsocketSession(nil, ""),
+		config:           config,
+	}
+	stopped.Stop()
+	if service, err = stopped.connect(); err != nil || service != nil || stopped.service != nil {
+		t.Errorf("stopped watcher expect no service, got %v %v", service, err)
+	}
+
+	failed := &watcher{
+		WebsocketSession: base.NewWebsocketSession(nil, ""),
+		config:           &Config{},
+	}
+	if _, err = failed.connect(); err == nil {
+		t.Errorf("empty address expect error")
+	}
+}
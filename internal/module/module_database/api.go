@@ -26,6 +26,7 @@ import (
 	"sync"
 	"teamide/internal/module/module_toolbox"
 	"teamide/pkg/base"
+	"teamide/pkg/load"
 	"teamide/pkg/ssh"
 )
 
@@ -69,6 +70,8 @@ var (
 	taskStatusPower     = base.AppendPower(&base.PowerAction{Action: "taskStatus", Text: "数据库任务状态查询", ShouldLogin: true, StandAlone: true, Parent: Power})
 	taskStopPower       = base.AppendPower(&base.PowerAction{Action: "taskStop", Text: "数据库任务停止", ShouldLogin: true, StandAlone: true, Parent: Power})
 	taskCleanPower      = base.AppendPower(&base.PowerAction{Action: "taskClean", Text: "数据库任务清理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	dictionaryPower     = base.AppendPower(&base.PowerAction{Action: "dictionary", Text: "数据库数据字典", ShouldLogin: true, StandAlone: true, Parent: Power})
+	dictDownloadPower   = base.AppendPower(&base.PowerAction{Action: "dictionaryDownload", Text: "数据库数据字典下载", ShouldLogin: true, StandAlone: true, Parent: Power})
 	closePower          = base.AppendPower(&base.PowerAction{Action: "close", Text: "数据库关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
 
 	testStart  = base.AppendPower(&base.PowerAction{Action: "test/start", Text: "测试开始", ShouldLogin: true, StandAlone: true, Parent: Power})
@@ -76,6 +79,17 @@ var (
 	testStop   = base.AppendPower(&base.PowerAction{Action: "test/stop", Text: "测试停止", ShouldLogin: true, StandAlone: true, Parent: Power})
 	testList   = base.AppendPower(&base.PowerAction{Action: "test/list", Text: "测试任务", ShouldLogin: true, StandAlone: true, Parent: Power})
 	testDelete = base.AppendPower(&base.PowerAction{Action: "test/delete", Text: "测试删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	generatePreview = base.AppendPower(&base.PowerAction{Action: "generate/preview", Text: "测试数据生成预览", ShouldLogin: true, StandAlone: true, Parent: Power})
+	generateStart   = base.AppendPower(&base.PowerAction{Action: "generate/start", Text: "测试数据生成开始", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	clickHousePartsPower          = base.AppendPower(&base.PowerAction{Action: "clickhouse/parts", Text: "ClickHouse数据片段查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	clickHouseMutationsPower      = base.AppendPower(&base.PowerAction{Action: "clickhouse/mutations", Text: "ClickHouse变更查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	clickHouseKillMutationPower   = base.AppendPower(&base.PowerAction{Action: "clickhouse/killMutation", Text: "ClickHouse变更终止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	clickHouseQueryKeyPower       = base.AppendPower(&base.PowerAction{Action: "clickhouse/query/key", Text: "ClickHouse流式执行Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	clickHouseQueryWebsocketPower = base.AppendPower(&base.PowerAction{Action: "clickhouse/query/websocket", Text: "ClickHouse流式执行WebSocket", ShouldLogin: true, StandAlone: true, Parent: Power})
+	clickHouseQueryClosePower     = base.AppendPower(&base.PowerAction{Action: "clickhouse/query/close", Text: "ClickHouse流式执行关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+	clickHouseExportPower         = base.AppendPower(&base.PowerAction{Action: "clickhouse/exportDownload", Text: "ClickHouse导出下载", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
 func (this_ *api) GetApis() (apis []*base.ApiWorker) {
@@ -107,6 +121,8 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: taskStatusPower, Do: this_.taskStatus, NotRecodeLog: true})
 	apis = append(apis, &base.ApiWorker{Power: taskStopPower, Do: this_.taskStop})
 	apis = append(apis, &base.ApiWorker{Power: taskCleanPower, Do: this_.taskClean})
+	apis = append(apis, &base.ApiWorker{Power: dictionaryPower, Do: this_.dictionary})
+	apis = append(apis, &base.ApiWorker{Power: dictDownloadPower, Do: this_.dictionaryDownload})
 
 	apis = append(apis, &base.ApiWorker{Power: testStart, Do: this_.testStart})
 	apis = append(apis, &base.ApiWorker{Power: testInfo, Do: this_.testInfo})
@@ -114,6 +130,17 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: testStop, Do: this_.testStop})
 	apis = append(apis, &base.ApiWorker{Power: testDelete, Do: this_.testDelete})
 
+	apis = append(apis, &base.ApiWorker{Power: generatePreview, Do: this_.generatePreview})
+	apis = append(apis, &base.ApiWorker{Power: generateStart, Do: this_.generateStart})
+
+	apis = append(apis, &base.ApiWorker{Power: clickHousePartsPower, Do: this_.clickHouseParts, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: clickHouseMutationsPower, Do: this_.clickHouseMutations, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: clickHouseKillMutationPower, Do: this_.clickHouseKillMutation})
+	apis = append(apis, &base.ApiWorker{Power: clickHouseQueryKeyPower, Do: this_.clickHouseQueryKey})
+	apis = append(apis, &base.ApiWorker{Power: clickHouseQueryWebsocketPower, Do: clickHouseQueryCache.Websocket, IsWebSocket: true})
+	apis = append(apis, &base.ApiWorker{Power: clickHouseQueryClosePower, Do: clickHouseQueryCache.Close})
+	apis = append(apis, &base.ApiWorker{Power: clickHouseExportPower, Do: this_.clickHouseExportDownload})
+
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
 
 	return
@@ -226,6 +253,8 @@ type BaseRequest struct {
 	CountSecond int    `json:"countSecond,omitempty"` // 统计间隔秒 如 每秒统计 输入 1 默认 10 秒统计
 	CountTop    bool   `json:"countTop,omitempty"`
 
+	LoadProfile *load.Profile `json:"loadProfile,omitempty"` // 负载 模型，为空 时 固定 Worker 个 线程
+
 	MaxIdleConn int `json:"maxIdleConn,omitempty"`
 	MaxOpenConn int `json:"maxOpenConn,omitempty"`
 
@@ -305,6 +334,10 @@ func (this_ *api) owners(requestBean *base.RequestBean, c *gin.Context) (res int
 
 	param := this_.getParam(requestBean, c)
 
+	if isClickHouse(service) {
+		res, err = clickHouseOwners(service)
+		return
+	}
 	res, err = service.OwnersSelect(param)
 	if err != nil {
 		return
@@ -396,6 +429,10 @@ func (this_ *api) ddl(requestBean *base.RequestBean, c *gin.Context) (res interf
 	}
 	param := this_.getParam(requestBean, c)
 
+	if isClickHouse(service) {
+		res, err = clickHouseDDL(service, param, request.OwnerName, request.TableName)
+		return
+	}
 	res, err = service.DDL(param, request.OwnerName, request.TableName)
 	if err != nil {
 		return
@@ -442,6 +479,10 @@ func (this_ *api) tables(requestBean *base.RequestBean, c *gin.Context) (res int
 	}
 	param := this_.getParam(requestBean, c)
 
+	if isClickHouse(service) {
+		res, err = clickHouseTables(service, request.OwnerName)
+		return
+	}
 	res, err = service.TablesSelect(param, request.OwnerName)
 	if err != nil {
 		return
@@ -465,6 +506,10 @@ func (this_ *api) tableDetail(requestBean *base.RequestBean, c *gin.Context) (re
 	}
 	param := this_.getParam(requestBean, c)
 
+	if isClickHouse(service) {
+		res, err = clickHouseTableDetail(service, request.OwnerName, request.TableName)
+		return
+	}
 	res, err = service.TableDetail(param, request.OwnerName, request.TableName)
 	if err != nil {
 		return
@@ -948,6 +993,10 @@ func (this_ *api) taskStatus(requestBean *base.RequestBean, c *gin.Context) (res
 		return
 	}
 
+	if task := getGenerateTask(request.TaskId); task != nil {
+		res = task.status()
+		return
+	}
 	res = worker.GetTask(request.TaskId)
 	return
 }
@@ -959,6 +1008,10 @@ func (this_ *api) taskStop(requestBean *base.RequestBean, c *gin.Context) (res i
 		return
 	}
 
+	if task := getGenerateTask(request.TaskId); task != nil {
+		task.stop()
+		return
+	}
 	worker.StopTask(request.TaskId)
 	return
 }
@@ -982,6 +1035,7 @@ func (this_ *api) taskClean(requestBean *base.RequestBean, c *gin.Context) (res
 		}
 	}
 	worker.ClearTask(request.TaskId)
+	removeGenerateTask(request.TaskId)
 	return
 }
 
@@ -993,6 +1047,7 @@ func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res inte
 	}
 
 	removeWorkerTasks(request.WorkerId)
+	clickHouseQueryCache.RemoveWorker(request.WorkerId)
 	return
 }
 
@@ -1026,6 +1081,7 @@ func removeWorkerTasks(workerId string) {
 			}
 			worker.ClearTask(taskId)
 		}
+		removeGenerateTask(taskId)
 	}
 	delete(workerTasksCache, workerId)
 	return
@@ -0,0 +1,343 @@
+package module_database
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/worker"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"teamide/pkg/base"
+	"time"
+)
+
+type ClickHouseExportRequest struct {
+	OwnerName  string                 `json:"ownerName,omitempty"`
+	TableName  string                 `json:"tableName,omitempty"`
+	ExecuteSQL string                 `json:"executeSQL,omitempty"` // 自定义 查询，为空 时 导出 整表
+	Format     string                 `json:"format,omitempty"`     // csv tsv jsonEachRow sql，默认 csv
+	AppendDDL  bool                   `json:"appendDDL,omitempty"`  // sql 格式 时 追加 建表 语句
+	BatchSize  int                    `json:"batchSize,omitempty"`  // sql 格式 每条 INSERT 行数 默认 500
+	Settings   map[string]interface{} `json:"settings,omitempty"`
+}
+
+var clickHouseExportExts = map[string]string{
+	"csv":         ".csv",
+	"tsv":         ".tsv",
+	"jsoneachrow": ".jsonl",
+	"sql":         ".sql",
+}
+
+// clickHouseLiteral 转为 ClickHouse SQL 字面量，数组 元组 Map 递归 处理
+func clickHouseLiteral(value interface{}) string {
+	if value == nil {
+		return "NULL"
+	}
+	switch v := value.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(v, `\`, `\\`), "'", `\'`) + "'"
+	case []byte:
+		return clickHouseLiteral(string(v))
+	case bool:
+		return strconv.FormatBool(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case time.Time:
+		return "'" + clickHouseTimeText(v) + "'"
+	}
+	vOf := reflect.ValueOf(value)
+	switch vOf.Kind() {
+	case reflect.Ptr:
+		if vOf.IsNil() {
+			return "NULL"
+		}
+		return clickHouseLiteral(vOf.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		var items []string
+		for i := 0; i < vOf.Len(); i++ {
+			items = append(items, clickHouseLiteral(vOf.Index(i).Interface()))
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	case reflect.Map:
+		var items []string
+		for _, key := range vOf.MapKeys() {
+			items = append(items, clickHouseLiteral(key.Interface())+":"+clickHouseLiteral(vOf.MapIndex(key).Interface()))
+		}
+		// Map 无序，排序 保证 导出 结果 稳定
+		sort.Strings(items)
+		return "{" + strings.Join(items, ",") + "}"
+	}
+	return clickHouseLiteral(fmt.Sprint(value))
+}
+
+func clickHouseTimeText(v time.Time) string {
+	if v.Nanosecond() == 0 {
+		return v.Format("2006-01-02 15:04:05")
+	}
+	return v.Format("2006-01-02 15:04:05.999999999")
+}
+
+// clickHouseText CSV TSV 中 的 文本，字符串 原样，复合 类型 使用 字面量 格式
+func clickHouseText(value interface{}) (text string, isNull bool) {
+	if value == nil {
+		return "", true
+	}
+	switch v := value.(type) {
+	case string:
+		return v, false
+	case []byte:
+		return string(v), false
+	case time.Time:
+		return clickHouseTimeText(v), false
+	}
+	vOf := reflect.ValueOf(value)
+	switch vOf.Kind() {
+	case reflect.Ptr:
+		if vOf.IsNil() {
+			return "", true
+		}
+		return clickHouseText(vOf.Elem().Interface())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return clickHouseLiteral(value), false
+	}
+	return fmt.Sprint(value), false
+}
+
+var clickHouseTsvReplacer = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// clickHouseExportWriter 按 格式 逐行 写出
+type clickHouseExportWriter struct {
+	format     string
+	tableName  string
+	batchSize  int
+	columns    []string
+	writer     *bufio.Writer
+	csvWriter  *csv.Writer
+	sqlValues  []string
+	sqlColumns string
+}
+
+func (this_ *clickHouseExportWriter) writeHeader() (err error) {
+	switch this_.format {
+	case "csv":
+		this_.csvWriter = csv.NewWriter(this_.writer)
+		err = this_.csvWriter.Write(this_.columns)
+	case "tsv":
+		var names []string
+		for _, name := range this_.columns {
+			names = append(names, clickHouseTsvReplacer.Replace(name))
+		}
+		_, err = this_.writer.WriteString(strings.Join(names, "\t") + "\n")
+	case "sql":
+		var names []string
+		for _, name := range this_.columns {
+			names = append(names, clickHouseName(name))
+		}
+		this_.sqlColumns = "(" + strings.Join(names, ",") + ")"
+	}
+	return
+}
+
+func (this_ *clickHouseExportWriter) writeRow(row []interface{}) (err error) {
+	switch this_.format {
+	case "csv":
+		var record []string
+		for _, value := range row {
+			text, _ := clickHouseText(value)
+			record = append(record, text)
+		}
+		err = this_.csvWriter.Write(record)
+	case "tsv":
+		var record []string
+		for _, value := range row {
+			text, isNull := clickHouseText(value)
+			if isNull {
+				record = append(record, `\N`)
+			} else {
+				record = append(record, clickHouseTsvReplacer.Replace(text))
+			}
+		}
+		_, err = this_.writer.WriteString(strings.Join(record, "\t") + "\n")
+	case "jsoneachrow":
+		data := map[string]interface{}{}
+		for i, value := range row {
+			if t, ok := value.(time.Time); ok {
+				value = clickHouseTimeText(t)
+			}
+			data[this_.columns[i]] = value
+		}
+		var bs []byte
+		bs, err = json.Marshal(data)
+		if err != nil {
+			return
+		}
+		_, err = this_.writer.Write(append(bs, '\n'))
+	case "sql":
+		var values []string
+		for _, value := range row {
+			values = append(values, clickHouseLiteral(value))
+		}
+		this_.sqlValues = append(this_.sqlValues, "("+strings.Join(values, ",")+")")
+		if len(this_.sqlValues) >= this_.batchSize {
+			err = this_.flushSql()
+		}
+	}
+	return
+}
+
+func (this_ *clickHouseExportWriter) flushSql() (err error) {
+	if len(this_.sqlValues) == 0 {
+		return
+	}
+	_, err = this_.writer.WriteString("INSERT INTO " + this_.tableName + " " + this_.sqlColumns + " VALUES\n" + strings.Join(this_.sqlValues, ",\n") + ";\n")
+	this_.sqlValues = nil
+	return
+}
+
+func (this_ *clickHouseExportWriter) finish() (err error) {
+	switch this_.format {
+	case "csv":
+		this_.csvWriter.Flush()
+		err = this_.csvWriter.Error()
+	case "sql":
+		err = this_.flushSql()
+	}
+	if err != nil {
+		return
+	}
+	err = this_.writer.Flush()
+	return
+}
+
+// clickHouseExportDownload 边 查询 边 写出，不 落 临时 文件，适合 大表 导出
+func (this_ *api) clickHouseExportDownload(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getClickHouseService(requestBean, c)
+	if err != nil {
+		return
+	}
+	var request = &ClickHouseExportRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	format := strings.ToLower(request.Format)
+	if format == "" {
+		format = "csv"
+	}
+	ext, ok := clickHouseExportExts[format]
+	if !ok {
+		err = errors.New("不支持的导出格式[" + request.Format + "]")
+		return
+	}
+	executeSQL := strings.TrimSpace(request.ExecuteSQL)
+	if executeSQL == "" {
+		if request.TableName == "" {
+			err = errors.New("tableName和executeSQL不能同时为空")
+			return
+		}
+		executeSQL = "SELECT * FROM " + clickHouseTableName(request.OwnerName, request.TableName)
+	}
+	batchSize := request.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	var ddlList []string
+	if format == "sql" && request.AppendDDL && request.TableName != "" {
+		ddlList, err = clickHouseDDL(service, &db.Param{}, request.OwnerName, request.TableName)
+		if err != nil {
+			return
+		}
+	}
+
+	workDb, err := newClickHouseWorkDb(service, request.OwnerName)
+	if err != nil {
+		return
+	}
+	defer func() { _ = workDb.Close() }()
+
+	// 客户端 断开 时 取消 查询
+	ctx := c.Request.Context()
+	if len(request.Settings) > 0 {
+		ctx = clickhouse.Context(ctx, clickhouse.WithSettings(request.Settings))
+	}
+	rows, err := workDb.QueryContext(ctx, executeSQL)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return
+	}
+
+	fileName := request.TableName
+	if request.ExecuteSQL != "" || fileName == "" {
+		fileName = "query-" + time.Now().Format("20060102150405")
+	}
+	fileName += ext
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", "attachment; filename="+url.QueryEscape(fileName))
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("download-file-name", fileName)
+	c.Status(http.StatusOK)
+	res = base.HttpNotResponse
+
+	exportWriter := &clickHouseExportWriter{
+		format:    format,
+		tableName: clickHouseTableName(request.OwnerName, request.TableName),
+		batchSize: batchSize,
+		writer:    bufio.NewWriterSize(c.Writer, 64*1024),
+	}
+	if request.TableName == "" {
+		exportWriter.tableName = "`table`"
+	}
+	for _, columnType := range columnTypes {
+		exportWriter.columns = append(exportWriter.columns, columnType.Name())
+	}
+	// 响应头 已 写出，之后 的 错误 只能 记录 日志
+	if e := clickHouseExportRows(exportWriter, ddlList, rows, columnTypes); e != nil {
+		util.Logger.Error("clickhouse export error", zap.Any("sql", executeSQL), zap.Error(e))
+	}
+	return
+}
+
+func clickHouseExportRows(exportWriter *clickHouseExportWriter, ddlList []string, rows *sql.Rows, columnTypes []*sql.ColumnType) (err error) {
+	for _, ddl := range ddlList {
+		if _, err = exportWriter.writer.WriteString(ddl + ";\n\n"); err != nil {
+			return
+		}
+	}
+	if err = exportWriter.writeHeader(); err != nil {
+		return
+	}
+	for rows.Next() {
+		cache := worker.GetSqlValueCache(columnTypes)
+		if err = rows.Scan(cache...); err != nil {
+			return
+		}
+		row := make([]interface{}, len(cache))
+		for i, data := range cache {
+			row[i] = worker.GetSqlValue(columnTypes[i], data)
+		}
+		if err = exportWriter.writeRow(row); err != nil {
+			return
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	err = exportWriter.finish()
+	return
+}
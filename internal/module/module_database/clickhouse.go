@@ -0,0 +1,213 @@
+package module_database
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// ClickHouse 使用 mysql 方言，反引号 包装 名称 和 LIMIT 分页 语法 一致，库表 结构 查询 走 system 表
+func init() {
+	err := db.AddDatabaseType(&db.DatabaseType{
+		NewDb:       newClickHouseDb,
+		DialectName: "mysql",
+		Matches:     []string{"clickhouse"},
+	})
+	if err != nil {
+		util.Logger.Error("init clickhouse db error", zap.Error(err))
+		panic("init clickhouse db error:" + err.Error())
+	}
+}
+
+func isClickHouse(service db.IService) bool {
+	return strings.EqualFold(service.GetConfig().Type, "clickhouse")
+}
+
+func getClickHouseTLSConfig(config *db.Config) (res *tls.Config, err error) {
+	switch config.TlsConfig {
+	case "":
+		return
+	case "skip-verify", "preferred":
+		res = &tls.Config{InsecureSkipVerify: true}
+		return
+	}
+	res = &tls.Config{}
+	if config.TlsRootCert != "" {
+		var bs []byte
+		bs, err = os.ReadFile(config.TlsRootCert)
+		if err != nil {
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			err = errors.New("root cert error, append certs from PEM error")
+			return
+		}
+		res.RootCAs = pool
+	}
+	if config.TlsClientCert != "" || config.TlsClientKey != "" {
+		var cert tls.Certificate
+		cert, err = tls.LoadX509KeyPair(config.TlsClientCert, config.TlsClientKey)
+		if err != nil {
+			err = errors.New("client cert or key error, " + err.Error())
+			return
+		}
+		res.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+// newClickHouseDb 使用 原生 TCP 协议 连接，默认 端口 9000，TLS 端口 9440
+func newClickHouseDb(config *db.Config) (res *sql.DB, err error) {
+	tlsConfig, err := getClickHouseTLSConfig(config)
+	if err != nil {
+		return
+	}
+	options := &clickhouse.Options{
+		Addr: []string{fmt.Sprintf("%s:%d", config.Host, config.Port)},
+		Auth: clickhouse.Auth{
+			Database: config.Database,
+			Username: config.Username,
+			Password: config.Password,
+		},
+		TLS:         tlsConfig,
+		DialTimeout: 10 * time.Second,
+	}
+	if config.SSHClient != nil {
+		sshClient := config.SSHClient
+		// 自定义 拨号 时 驱动 不再 处理 TLS
+		options.DialContext = func(ctx context.Context, addr string) (conn net.Conn, err error) {
+			conn, err = sshClient.Dial("tcp", addr)
+			if err != nil {
+				return
+			}
+			conn = &util.SSHChanConn{Conn: conn}
+			if tlsConfig != nil {
+				tlsConf := tlsConfig.Clone()
+				if tlsConf.ServerName == "" {
+					tlsConf.ServerName, _, _ = net.SplitHostPort(addr)
+				}
+				tlsConn := tls.Client(conn, tlsConf)
+				if err = tlsConn.HandshakeContext(ctx); err != nil {
+					_ = conn.Close()
+					return
+				}
+				conn = tlsConn
+			}
+			return
+		}
+	}
+	res = sql.OpenDB(&clickHouseConnector{Connector: clickhouse.Connector(options)})
+	return
+}
+
+// clickHouseConnector 驱动 的 Prepare 只 支持 批量 INSERT，
+// 方言 工具 查询 和 执行 都 通过 Prepare，这里 包装 为 直接 执行
+type clickHouseConnector struct {
+	driver.Connector
+}
+
+func (this_ *clickHouseConnector) Connect(ctx context.Context) (res driver.Conn, err error) {
+	conn, err := this_.Connector.Connect(ctx)
+	if err != nil {
+		return
+	}
+	res = &clickHouseConn{Conn: conn}
+	return
+}
+
+type clickHouseConn struct {
+	driver.Conn
+}
+
+func (this_ *clickHouseConn) Prepare(query string) (driver.Stmt, error) {
+	return &clickHouseStmt{conn: this_, query: query}, nil
+}
+
+func (this_ *clickHouseConn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return &clickHouseStmt{conn: this_, query: query}, nil
+}
+
+func (this_ *clickHouseConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return this_.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (this_ *clickHouseConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return this_.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (this_ *clickHouseConn) Ping(ctx context.Context) error {
+	return this_.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (this_ *clickHouseConn) ResetSession(ctx context.Context) error {
+	return this_.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+// CheckNamedValue 参数 由 驱动 在 客户端 绑定，支持 数组 等 类型
+func (this_ *clickHouseConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return this_.Conn.(driver.NamedValueChecker).CheckNamedValue(nv)
+}
+
+type clickHouseStmt struct {
+	conn  *clickHouseConn
+	query string
+}
+
+func (this_ *clickHouseStmt) Close() error {
+	return nil
+}
+
+func (this_ *clickHouseStmt) NumInput() int {
+	return -1
+}
+
+func toNamedValues(args []driver.Value) (res []driver.NamedValue) {
+	for i, arg := range args {
+		res = append(res, driver.NamedValue{Ordinal: i + 1, Value: arg})
+	}
+	return
+}
+
+func (this_ *clickHouseStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return this_.conn.ExecContext(context.Background(), this_.query, toNamedValues(args))
+}
+
+func (this_ *clickHouseStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return this_.conn.QueryContext(context.Background(), this_.query, toNamedValues(args))
+}
+
+func (this_ *clickHouseStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return this_.conn.ExecContext(ctx, this_.query, args)
+}
+
+func (this_ *clickHouseStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return this_.conn.QueryContext(ctx, this_.query, args)
+}
+
+// newClickHouseWorkDb 按 库 创建 独立 连接，ClickHouse 没有 会话 级 USE，通过 连接 的 默认 库 实现
+func newClickHouseWorkDb(service db.IService, ownerName string) (res *sql.DB, err error) {
+	config := service.GetConfig()
+	if ownerName != "" {
+		config.Database = ownerName
+	}
+	res, err = newClickHouseDb(&config)
+	if err != nil {
+		return
+	}
+	res.SetMaxOpenConns(1)
+	res.SetMaxIdleConns(1)
+	return
+}
@@ -0,0 +1,356 @@
+package module_database
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"teamide/pkg/base"
+)
+
+// clickHouseName 反引号 包装 库 表 名称
+func clickHouseName(name string) string {
+	return "`" + strings.ReplaceAll(strings.ReplaceAll(name, `\`, `\\`), "`", "\\`") + "`"
+}
+
+func clickHouseTableName(ownerName string, tableName string) string {
+	if ownerName == "" {
+		return clickHouseName(tableName)
+	}
+	return clickHouseName(ownerName) + "." + clickHouseName(tableName)
+}
+
+// clickHouseOwners 查询 库，附带 引擎 及 表 数量 行数 大小
+func clickHouseOwners(service db.IService) (owners []*dialect.OwnerModel, err error) {
+	list, err := service.QueryMap(`SELECT name ownerName,engine FROM system.databases ORDER BY name`, nil)
+	if err != nil {
+		return
+	}
+	stats, err := service.QueryMap(`SELECT database ownerName,count() tableCount,sum(total_rows) totalRows,sum(total_bytes) totalBytes FROM system.tables GROUP BY database`, nil)
+	if err != nil {
+		return
+	}
+	statCache := map[string]map[string]interface{}{}
+	for _, one := range stats {
+		statCache[getMapValue(one, "ownerName")] = one
+	}
+	for _, one := range list {
+		owner := &dialect.OwnerModel{
+			OwnerName: getMapValue(one, "ownerName"),
+			Extend: map[string]interface{}{
+				"engine":     getMapValue(one, "engine"),
+				"tableCount": 0,
+			},
+		}
+		if stat := statCache[owner.OwnerName]; stat != nil {
+			owner.Extend["tableCount"] = stat["tableCount"]
+			owner.Extend["totalRows"] = stat["totalRows"]
+			owner.Extend["totalBytes"] = stat["totalBytes"]
+		}
+		owners = append(owners, owner)
+	}
+	return
+}
+
+const clickHouseTablesSelect = `SELECT name tableName,comment tableComment,engine,engine_full engineFull,
+partition_key partitionKey,sorting_key sortingKey,primary_key primaryKey,sampling_key samplingKey,
+total_rows totalRows,total_bytes totalBytes,metadata_modification_time metadataModificationTime
+FROM system.tables WHERE database=?`
+
+// toClickHouseTable 表 引擎 分区键 排序键 等 放入 Extend
+func toClickHouseTable(ownerName string, data map[string]interface{}) (table *dialect.TableModel) {
+	engine := getMapValue(data, "engine")
+	table = &dialect.TableModel{
+		OwnerName:    ownerName,
+		TableName:    getMapValue(data, "tableName"),
+		TableComment: getMapValue(data, "tableComment"),
+		Extend: map[string]interface{}{
+			"engine":                   engine,
+			"engineFull":               getMapValue(data, "engineFull"),
+			"isMergeTree":              strings.HasSuffix(engine, "MergeTree"),
+			"partitionKey":             getMapValue(data, "partitionKey"),
+			"sortingKey":               getMapValue(data, "sortingKey"),
+			"primaryKey":               getMapValue(data, "primaryKey"),
+			"samplingKey":              getMapValue(data, "samplingKey"),
+			"totalRows":                data["totalRows"],
+			"totalBytes":               data["totalBytes"],
+			"metadataModificationTime": data["metadataModificationTime"],
+		},
+	}
+	return
+}
+
+func clickHouseTables(service db.IService, ownerName string) (tables []*dialect.TableModel, err error) {
+	list, err := service.QueryMap(clickHouseTablesSelect+` ORDER BY name`, []interface{}{ownerName})
+	if err != nil {
+		return
+	}
+	for _, one := range list {
+		tables = append(tables, toClickHouseTable(ownerName, one))
+	}
+	return
+}
+
+// clickHouseTableDetail 表 字段 跳数索引 及 活动 分区 汇总
+func clickHouseTableDetail(service db.IService, ownerName string, tableName string) (table *dialect.TableModel, err error) {
+	list, err := service.QueryMap(clickHouseTablesSelect+` AND name=?`, []interface{}{ownerName, tableName})
+	if err != nil {
+		return
+	}
+	if len(list) == 0 {
+		return
+	}
+	table = toClickHouseTable(ownerName, list[0])
+
+	columns, err := service.QueryMap(`SELECT name columnName,type columnType,default_kind defaultKind,default_expression defaultExpression,
+comment columnComment,compression_codec compressionCodec,is_in_partition_key inPartitionKey,is_in_sorting_key inSortingKey,
+is_in_primary_key inPrimaryKey,is_in_sampling_key inSamplingKey
+FROM system.columns WHERE database=? AND table=? ORDER BY position`, []interface{}{ownerName, tableName})
+	if err != nil {
+		return
+	}
+	for _, one := range columns {
+		columnType := getMapValue(one, "columnType")
+		column := &dialect.ColumnModel{
+			OwnerName:      ownerName,
+			TableName:      tableName,
+			ColumnName:     getMapValue(one, "columnName"),
+			ColumnDataType: columnType,
+			ColumnComment:  getMapValue(one, "columnComment"),
+			ColumnDefault:  getMapValue(one, "defaultExpression"),
+			ColumnNotNull:  !strings.HasPrefix(columnType, "Nullable("),
+			PrimaryKey:     getMapValue(one, "inPrimaryKey") == "1",
+			Extend: map[string]interface{}{
+				"defaultKind":      getMapValue(one, "defaultKind"),
+				"compressionCodec": getMapValue(one, "compressionCodec"),
+				"inPartitionKey":   getMapValue(one, "inPartitionKey") == "1",
+				"inSortingKey":     getMapValue(one, "inSortingKey") == "1",
+				"inSamplingKey":    getMapValue(one, "inSamplingKey") == "1",
+			},
+		}
+		if column.PrimaryKey {
+			table.PrimaryKeys = append(table.PrimaryKeys, column.ColumnName)
+		}
+		table.AddColumn(column)
+	}
+
+	// 跳数索引 低版本 没有 该表，查询失败 忽略
+	indexes, e := service.QueryMap(`SELECT name indexName,type indexType,expr,granularity FROM system.data_skipping_indices WHERE database=? AND table=?`, []interface{}{ownerName, tableName})
+	if e != nil {
+		util.Logger.Warn("clickhouse data skipping indices select error", zap.Any("tableName", tableName), zap.Error(e))
+	}
+	for _, one := range indexes {
+		table.IndexList = append(table.IndexList, &dialect.IndexModel{
+			OwnerName:    ownerName,
+			TableName:    tableName,
+			IndexName:    getMapValue(one, "indexName"),
+			IndexType:    getMapValue(one, "indexType"),
+			ColumnName:   getMapValue(one, "expr"),
+			IndexComment: "GRANULARITY " + getMapValue(one, "granularity"),
+		})
+	}
+
+	partitions, err := service.QueryMap(`SELECT uniqExact(partition_id) partitionCount,count() partCount,sum(rows) rows,sum(bytes_on_disk) bytesOnDisk,
+sum(data_compressed_bytes) compressedBytes,sum(data_uncompressed_bytes) uncompressedBytes
+FROM system.parts WHERE active AND database=? AND table=?`, []interface{}{ownerName, tableName})
+	if err != nil {
+		return
+	}
+	if len(partitions) > 0 {
+		table.Extend["partitions"] = partitions[0]
+	}
+	return
+}
+
+// clickHouseDDL 使用 服务端 生成 的 建表 语句，包含 引擎 分区 排序 等 完整 定义
+func clickHouseDDL(service db.IService, param *db.Param, ownerName string, tableName string) (sqlList []string, err error) {
+	if param.AppendOwnerCreateSql {
+		var list []map[string]interface{}
+		list, err = service.QueryMap(`SHOW CREATE DATABASE `+clickHouseName(ownerName), nil)
+		if err != nil {
+			return
+		}
+		for _, one := range list {
+			sqlList = append(sqlList, getMapValue(one, "statement"))
+		}
+	}
+	var list []map[string]interface{}
+	if tableName != "" {
+		list, err = service.QueryMap(`SHOW CREATE TABLE `+clickHouseTableName(ownerName, tableName), nil)
+	} else {
+		list, err = service.QueryMap(`SELECT create_table_query statement FROM system.tables WHERE database=? AND NOT is_temporary ORDER BY name`, []interface{}{ownerName})
+	}
+	if err != nil {
+		return
+	}
+	for _, one := range list {
+		sqlList = append(sqlList, getMapValue(one, "statement"))
+	}
+	return
+}
+
+type ClickHousePartsRequest struct {
+	OwnerName        string `json:"ownerName,omitempty"`
+	TableName        string `json:"tableName,omitempty"`
+	PartitionId      string `json:"partitionId,omitempty"`
+	WithInactive     bool   `json:"withInactive,omitempty"`     // 包含 合并 后 待 清理 的 非活动 数据片段
+	GroupByPartition bool   `json:"groupByPartition,omitempty"` // 按 分区 汇总
+	MaxSize          int    `json:"maxSize,omitempty"`          // 默认 1000
+}
+
+func clickHouseParts(service db.IService, request *ClickHousePartsRequest) (res []map[string]interface{}, err error) {
+	var wheres []string
+	var args []interface{}
+	if !request.WithInactive {
+		wheres = append(wheres, "active")
+	}
+	if request.OwnerName != "" {
+		wheres = append(wheres, "database=?")
+		args = append(args, request.OwnerName)
+	}
+	if request.TableName != "" {
+		wheres = append(wheres, "table=?")
+		args = append(args, request.TableName)
+	}
+	if request.PartitionId != "" {
+		wheres = append(wheres, "partition_id=?")
+		args = append(args, request.PartitionId)
+	}
+	maxSize := request.MaxSize
+	if maxSize <= 0 {
+		maxSize = 1000
+	}
+
+	var sql string
+	if request.GroupByPartition {
+		sql = `SELECT database ownerName,table tableName,partition,partition_id partitionId,count() partCount,countIf(active) activePartCount,
+sum(rows) rows,sum(bytes_on_disk) bytesOnDisk,sum(data_compressed_bytes) compressedBytes,sum(data_uncompressed_bytes) uncompressedBytes,
+max(modification_time) modificationTime FROM system.parts`
+	} else {
+		sql = `SELECT database ownerName,table tableName,partition,partition_id partitionId,name,part_type partType,active,
+rows,bytes_on_disk bytesOnDisk,data_compressed_bytes compressedBytes,data_uncompressed_bytes uncompressedBytes,
+marks,level,min_block_number minBlockNumber,max_block_number maxBlockNumber,modification_time modificationTime,disk_name diskName
+FROM system.parts`
+	}
+	if len(wheres) > 0 {
+		sql += " WHERE " + strings.Join(wheres, " AND ")
+	}
+	if request.GroupByPartition {
+		sql += " GROUP BY database,table,partition,partition_id ORDER BY database,table,partition_id"
+	} else {
+		sql += " ORDER BY database,table,partition_id,name"
+	}
+	sql += " LIMIT " + strconv.Itoa(maxSize)
+	res, err = service.QueryMap(sql, args)
+	return
+}
+
+type ClickHouseMutationsRequest struct {
+	OwnerName   string `json:"ownerName,omitempty"`
+	TableName   string `json:"tableName,omitempty"`
+	MutationId  string `json:"mutationId,omitempty"`
+	OnlyRunning bool   `json:"onlyRunning,omitempty"` // 只 查询 未 完成 的
+	MaxSize     int    `json:"maxSize,omitempty"`     // 默认 500
+}
+
+// clickHouseMutations ALTER UPDATE DELETE 等 变更 异步 执行，partsToDo 为 剩余 待 处理 的 数据片段 数
+func clickHouseMutations(service db.IService, request *ClickHouseMutationsRequest) (res []map[string]interface{}, err error) {
+	var wheres []string
+	var args []interface{}
+	if request.OwnerName != "" {
+		wheres = append(wheres, "database=?")
+		args = append(args, request.OwnerName)
+	}
+	if request.TableName != "" {
+		wheres = append(wheres, "table=?")
+		args = append(args, request.TableName)
+	}
+	if request.MutationId != "" {
+		wheres = append(wheres, "mutation_id=?")
+		args = append(args, request.MutationId)
+	}
+	if request.OnlyRunning {
+		wheres = append(wheres, "NOT is_done")
+	}
+	maxSize := request.MaxSize
+	if maxSize <= 0 {
+		maxSize = 500
+	}
+	sql := `SELECT database ownerName,table tableName,mutation_id mutationId,command,create_time createTime,
+parts_to_do partsToDo,is_done isDone,latest_failed_part latestFailedPart,latest_fail_time latestFailTime,latest_fail_reason latestFailReason
+FROM system.mutations`
+	if len(wheres) > 0 {
+		sql += " WHERE " + strings.Join(wheres, " AND ")
+	}
+	sql += " ORDER BY create_time DESC LIMIT " + strconv.Itoa(maxSize)
+	res, err = service.QueryMap(sql, args)
+	return
+}
+
+func clickHouseKillMutation(service db.IService, request *ClickHouseMutationsRequest) (err error) {
+	if request.OwnerName == "" || request.TableName == "" || request.MutationId == "" {
+		err = errors.New("ownerName、tableName、mutationId不能为空")
+		return
+	}
+	_, err = service.Exec(`KILL MUTATION WHERE database=? AND table=? AND mutation_id=?`, []interface{}{request.OwnerName, request.TableName, request.MutationId})
+	return
+}
+
+func (this_ *api) getClickHouseService(requestBean *base.RequestBean, c *gin.Context) (service db.IService, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err = getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+	if !isClickHouse(service) {
+		err = errors.New("数据库类型[" + config.Type + "]不是ClickHouse")
+		return
+	}
+	return
+}
+
+func (this_ *api) clickHouseParts(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getClickHouseService(requestBean, c)
+	if err != nil {
+		return
+	}
+	var request = &ClickHousePartsRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = clickHouseParts(service, request)
+	return
+}
+
+func (this_ *api) clickHouseMutations(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getClickHouseService(requestBean, c)
+	if err != nil {
+		return
+	}
+	var request = &ClickHouseMutationsRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = clickHouseMutations(service, request)
+	return
+}
+
+func (this_ *api) clickHouseKillMutation(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getClickHouseService(requestBean, c)
+	if err != nil {
+		return
+	}
+	var request = &ClickHouseMutationsRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	err = clickHouseKillMutation(service, request)
+	return
+}
@@ -0,0 +1,273 @@
+package module_database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/worker"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+type ClickHouseQueryRequest struct {
+	WorkerId      string                 `json:"workerId,omitempty"`
+	Key           string                 `json:"key,omitempty"`
+	OwnerName     string                 `json:"ownerName,omitempty"`
+	ExecuteSQL    string                 `json:"executeSQL,omitempty"`
+	BatchSize     int                    `json:"batchSize,omitempty"`     // 每批 推送 行数 默认 500
+	MaxRows       int                    `json:"maxRows,omitempty"`       // 单个 查询 最多 推送 行数 默认 100000，超过 后 取消 查询
+	Settings      map[string]interface{} `json:"settings,omitempty"`      // 查询 级 设置 如 max_threads max_execution_time
+	ErrorContinue bool                   `json:"errorContinue,omitempty"` // 多条 语句 时 出错 继续 执行
+}
+
+type ClickHouseQueryProgress struct {
+	ReadRows   uint64 `json:"readRows"`
+	ReadBytes  uint64 `json:"readBytes"`
+	TotalRows  uint64 `json:"totalRows"` // 预计 需要 读取 的 行数，用于 计算 进度
+	WroteRows  uint64 `json:"wroteRows"`
+	WroteBytes uint64 `json:"wroteBytes"`
+	Elapsed    int64  `json:"elapsed"` // 服务端 耗时 毫秒
+}
+
+type ClickHouseQueryMessage struct {
+	Type      string                   `json:"type"` // start columns rows progress end finish error
+	Index     int                      `json:"index"`
+	QueryId   string                   `json:"queryId,omitempty"`
+	Sql       string                   `json:"sql,omitempty"`
+	Columns   []map[string]interface{} `json:"columns,omitempty"`
+	Rows      [][]interface{}          `json:"rows,omitempty"`
+	Progress  *ClickHouseQueryProgress `json:"progress,omitempty"`
+	RowCount  int                      `json:"rowCount,omitempty"`
+	Truncated bool                     `json:"truncated,omitempty"` // 超过 MaxRows 已 取消
+	UseTime   int64                    `json:"useTime,omitempty"`
+	Time      int64                    `json:"time"`
+	Error     string                   `json:"error,omitempty"`
+}
+
+// clickHouseQuery 流式 执行 会话，结果 分批 通过 websocket 推送，关闭 时 取消 查询
+type clickHouseQuery struct {
+	*base.WebsocketSession
+	request *ClickHouseQueryRequest
+	service db.IService
+}
+
+var clickHouseQueryCache = base.NewWebsocketCache("clickhouse query")
+
+func (this_ *clickHouseQuery) write(msg *ClickHouseQueryMessage) {
+	if msg.Time == 0 {
+		msg.Time = util.GetNowMilli()
+	}
+	if err := this_.WriteJSON(msg); err != nil {
+		go this_.Stop()
+	}
+}
+
+func (this_ *clickHouseQuery) Start() (err error) {
+	go this_.run()
+	return
+}
+
+// OnStop 执行 使用 会话 的 Context，会话 结束 时 已 取消 查询
+func (this_ *clickHouseQuery) OnStop() {
+}
+
+func (this_ *clickHouseQuery) run() {
+	defer this_.Stop()
+
+	workDb, err := newClickHouseWorkDb(this_.service, this_.request.OwnerName)
+	if err != nil {
+		this_.write(&ClickHouseQueryMessage{Type: "error", Error: err.Error()})
+		return
+	}
+	defer func() { _ = workDb.Close() }()
+
+	sqlList := this_.service.GetDialect().SqlSplit(this_.request.ExecuteSQL)
+	for index, executeSql := range sqlList {
+		if this_.IsStopped() {
+			return
+		}
+		if err = this_.execute(workDb, index, executeSql); err != nil {
+			util.Logger.Error("clickhouse query error", zap.Any("sql", executeSql), zap.Error(err))
+			this_.write(&ClickHouseQueryMessage{Type: "error", Index: index, Sql: executeSql, Error: err.Error()})
+			if !this_.request.ErrorContinue {
+				return
+			}
+		}
+	}
+	this_.write(&ClickHouseQueryMessage{Type: "finish"})
+}
+
+// isClickHouseQuerySql 有 结果集 的 语句，其它 语句 使用 Exec
+func isClickHouseQuerySql(executeSql string) bool {
+	str := strings.ToLower(strings.TrimSpace(executeSql))
+	for _, prefix := range []string{"select", "with", "show", "desc", "explain", "exists", "check", "("} {
+		if strings.HasPrefix(str, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (this_ *clickHouseQuery) execute(workDb *sql.DB, index int, executeSql string) (err error) {
+	batchSize := this_.request.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	maxRows := this_.request.MaxRows
+	if maxRows <= 0 {
+		maxRows = 100000
+	}
+	queryId := util.GetUUID()
+	startTime := util.GetNowMilli()
+	this_.write(&ClickHouseQueryMessage{Type: "start", Index: index, QueryId: queryId, Sql: executeSql})
+
+	// 进度 为 增量，累加 后 最多 每 200 毫秒 推送 一次
+	var progress = &ClickHouseQueryProgress{}
+	var progressLock sync.Mutex
+	var lastProgressTime int64
+	ctx, cancel := context.WithCancel(this_.Context())
+	defer cancel()
+	options := []clickhouse.QueryOption{
+		clickhouse.WithQueryID(queryId),
+		clickhouse.WithProgress(func(p *clickhouse.Progress) {
+			progressLock.Lock()
+			defer progressLock.Unlock()
+			progress.ReadRows += p.Rows
+			progress.ReadBytes += p.Bytes
+			progress.TotalRows += p.TotalRows
+			progress.WroteRows += p.WroteRows
+			progress.WroteBytes += p.WroteBytes
+			progress.Elapsed = p.Elapsed.Milliseconds()
+			now := util.GetNowMilli()
+			if now-lastProgressTime < 200 {
+				return
+			}
+			lastProgressTime = now
+			one := *progress
+			this_.write(&ClickHouseQueryMessage{Type: "progress", Index: index, QueryId: queryId, Progress: &one})
+		}),
+	}
+	if len(this_.request.Settings) > 0 {
+		options = append(options, clickhouse.WithSettings(this_.request.Settings))
+	}
+	ctx = clickhouse.Context(ctx, options...)
+
+	end := &ClickHouseQueryMessage{Type: "end", Index: index, QueryId: queryId}
+	defer func() {
+		if err != nil {
+			return
+		}
+		progressLock.Lock()
+		one := *progress
+		progressLock.Unlock()
+		end.Progress = &one
+		end.UseTime = util.GetNowMilli() - startTime
+		this_.write(end)
+	}()
+
+	if !isClickHouseQuerySql(executeSql) {
+		_, err = workDb.ExecContext(ctx, executeSql)
+		return
+	}
+
+	rows, err := workDb.QueryContext(ctx, executeSql)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return
+	}
+	var columns []map[string]interface{}
+	for _, columnType := range columnTypes {
+		columns = append(columns, map[string]interface{}{
+			"name": columnType.Name(),
+			"type": columnType.DatabaseTypeName(),
+		})
+	}
+	this_.write(&ClickHouseQueryMessage{Type: "columns", Index: index, QueryId: queryId, Columns: columns})
+
+	var batch [][]interface{}
+	for rows.Next() {
+		if end.RowCount >= maxRows {
+			// 取消 后 驱动 向 服务端 发送 Cancel
+			end.Truncated = true
+			cancel()
+			break
+		}
+		cache := worker.GetSqlValueCache(columnTypes)
+		if err = rows.Scan(cache...); err != nil {
+			return
+		}
+		row := make([]interface{}, len(cache))
+		for i, data := range cache {
+			row[i] = clickHouseValue(columnTypes[i], data)
+		}
+		batch = append(batch, row)
+		end.RowCount++
+		if len(batch) >= batchSize {
+			this_.write(&ClickHouseQueryMessage{Type: "rows", Index: index, QueryId: queryId, Rows: batch})
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		this_.write(&ClickHouseQueryMessage{Type: "rows", Index: index, QueryId: queryId, Rows: batch})
+	}
+	if !end.Truncated {
+		err = rows.Err()
+	}
+	return
+}
+
+// clickHouseValue 时间 转 毫秒，超出 JS 安全 整数 范围 的 转 字符串
+func clickHouseValue(columnType *sql.ColumnType, data interface{}) (value interface{}) {
+	value = worker.GetSqlValue(columnType, data)
+	switch v := value.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		return util.GetMilliByTime(v)
+	case int64:
+		if v > 9007199254740991 || v < -9007199254740991 {
+			return fmt.Sprint(v)
+		}
+	case uint64:
+		if v > 9007199254740991 {
+			return fmt.Sprint(v)
+		}
+	}
+	return
+}
+
+// clickHouseQueryKey 创建 流式 执行 会话，返回 key 用于 建立 websocket
+func (this_ *api) clickHouseQueryKey(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getClickHouseService(requestBean, c)
+	if err != nil {
+		return
+	}
+	var request = &ClickHouseQueryRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if strings.TrimSpace(request.ExecuteSQL) == "" {
+		err = errors.New("executeSQL不能为空")
+		return
+	}
+	one := &clickHouseQuery{
+		WebsocketSession: base.NewWebsocketSession(requestBean, request.WorkerId),
+		request:          request,
+		service:          service,
+	}
+	res = clickHouseQueryCache.Add(one)
+	return
+}
@@ -0,0 +1,146 @@
+package module_database
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIsClickHouseQuerySql(t *testing.T) {
+	for _, one := range []struct {
+		sql    string
+		expect bool
+	}{
+		{"SELECT 1", true},
+		{"  with a as (select 1) select * from a", true},
+		{"\nshow tables", true},
+		{"DESC t", true},
+		{"explain select 1", true},
+		{"exists table t", true},
+		{"check table t", true},
+		{"(select 1) union all (select 2)", true},
+		{"insert into t values (1)", false},
+		{"ALTER TABLE t DELETE WHERE 1", false},
+		{"", false},
+	} {
+		if res := isClickHouseQuerySql(one.sql); res != one.expect {
+			t.Errorf("sql %q expect %v, got %v", one.sql, one.expect, res)
+		}
+	}
+}
+
+func TestClickHouseValue(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, one := range []struct {
+		data   interface{}
+		expect interface{}
+	}{
+		{nil, nil},
+		{"a", "a"},
+		{int64(9007199254740991), int64(9007199254740991)},
+		// 超出 JS 安全 整数 范围 转为 字符串
+		{int64(9007199254740992), "9007199254740992"},
+		{int64(-9007199254740992), "-9007199254740992"},
+		{uint64(18446744073709551615), "18446744073709551615"},
+		{uint64(1), uint64(1)},
+		{ts, ts.UnixMilli()},
+		{time.Time{}, nil},
+		{sql.NullString{}, nil},
+		{sql.NullInt64{Int64: 3, Valid: true}, int64(3)},
+	} {
+		if res := clickHouseValue(nil, one.data); !reflect.DeepEqual(res, one.expect) {
+			t.Errorf("data %v expect %v(%T), got %v(%T)", one.data, one.expect, one.expect, res, res)
+		}
+	}
+}
+
+func TestClickHouseLiteral(t *testing.T) {
+	s := "a"
+	var nilPtr *string
+	for _, one := range []struct {
+		value  interface{}
+		expect string
+	}{
+		{nil, "NULL"},
+		{"it's", `'it\'s'`},
+		{`a\b`, `'a\\b'`},
+		{[]byte("x"), "'x'"},
+		{true, "true"},
+		{int32(-1), "-1"},
+		{1.5, "1.5"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "'2024-01-02 03:04:05'"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 120000000, time.UTC), "'2024-01-02 03:04:05.12'"},
+		{&s, "'a'"},
+		{nilPtr, "NULL"},
+		{[]interface{}{"a", 1, nil}, "['a',1,NULL]"},
+		{map[string]int{"b": 2, "a": 1}, "{'a':1,'b':2}"},
+	} {
+		if res := clickHouseLiteral(one.value); res != one.expect {
+			t.Errorf("value %v expect %s, got %s", one.value, one.expect, res)
+		}
+	}
+}
+
+func TestClickHouseText(t *testing.T) {
+	var nilPtr *int
+	for _, one := range []struct {
+		value  interface{}
+		expect string
+		isNull bool
+	}{
+		{nil, "", true},
+		{nilPtr, "", true},
+		{"it's", "it's", false},
+		{[]byte("x"), "x", false},
+		{int64(3), "3", false},
+		{[]string{"a", "b"}, "['a','b']", false},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2024-01-02 03:04:05", false},
+	} {
+		text, isNull := clickHouseText(one.value)
+		if text != one.expect || isNull != one.isNull {
+			t.Errorf("value %v expect %s %v, got %s %v", one.value, one.expect, one.isNull, text, isNull)
+		}
+	}
+}
+
+func TestClickHouseExportWriter(t *testing.T) {
+	rows := [][]interface{}{
+		{int64(1), "a\tb\n", nil},
+		{int64(2), `it's "q"`, []string{"x"}},
+	}
+	for _, one := range []struct {
+		format string
+		expect string
+	}{
+		{"csv", "id,name,tags\n1,\"a\tb\n\",\n2,\"it's \"\"q\"\"\",['x']\n"},
+		{"tsv", "id\tname\ttags\n1\ta\\tb\\n\t\\N\n2\tit's \"q\"\t['x']\n"},
+		{"jsoneachrow", "{\"id\":1,\"name\":\"a\\tb\\n\",\"tags\":null}\n{\"id\":2,\"name\":\"it's \\\"q\\\"\",\"tags\":[\"x\"]}\n"},
+		{"sql", "INSERT INTO `db`.`t` (`id`,`name`,`tags`) VALUES\n(1,'a\tb\n',NULL);\nINSERT INTO `db`.`t` (`id`,`name`,`tags`) VALUES\n(2,'it\\'s \"q\"',['x']);\n"},
+	} {
+		buf := &bytes.Buffer{}
+		w := &clickHouseExportWriter{
+			format:    one.format,
+			tableName: clickHouseTableName("db", "t"),
+			batchSize: 1,
+			columns:   []string{"id", "name", "tags"},
+			writer:    bufio.NewWriter(buf),
+		}
+		if err := w.writeHeader(); err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range rows {
+			if err := w.writeRow(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.finish(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != one.expect {
+			t.Errorf("format %s expect %q, got %q", one.format, one.expect, buf.String())
+		}
+	}
+}
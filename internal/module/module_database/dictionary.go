@@ -0,0 +1,560 @@
+package module_database
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/tealeg/xlsx"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"teamide/pkg/base"
+)
+
+type DictionaryRequest struct {
+	OwnerName     string   `json:"ownerName,omitempty"`
+	TableNames    []string `json:"tableNames,omitempty"` // 为空 则 导出 库下所有表
+	Format        string   `json:"format,omitempty"`     // markdown html xlsx
+	ErType        string   `json:"erType,omitempty"`     // mermaid plantuml dot
+	InferByNaming bool     `json:"inferByNaming,omitempty"`
+}
+
+// loadDictionary 加载 表明细 和 表关系
+func loadDictionary(service db.IService, param *db.Param, request *DictionaryRequest) (tables []*dialect.TableModel, relations []*TableRelation, err error) {
+	var tableNames = request.TableNames
+	if len(tableNames) == 0 {
+		var list []*dialect.TableModel
+		list, err = service.TablesSelect(param, request.OwnerName)
+		if err != nil {
+			return
+		}
+		for _, one := range list {
+			tableNames = append(tableNames, one.TableName)
+		}
+	}
+	for _, tableName := range tableNames {
+		var table *dialect.TableModel
+		table, err = service.TableDetail(param, request.OwnerName, tableName)
+		if err != nil {
+			return
+		}
+		if table == nil {
+			continue
+		}
+		tables = append(tables, table)
+	}
+	relations = loadRelations(service, param, request.OwnerName, tables, request.InferByNaming)
+	return
+}
+
+func columnTypeText(dia dialect.Dialect, column *dialect.ColumnModel) string {
+	if dia != nil {
+		if s, err := dia.ColumnTypePack(column); err == nil && s != "" {
+			return s
+		}
+	}
+	if column.ColumnLength > 0 {
+		if column.ColumnScale > 0 {
+			return fmt.Sprintf("%s(%d,%d)", column.ColumnDataType, column.ColumnLength, column.ColumnScale)
+		}
+		return fmt.Sprintf("%s(%d)", column.ColumnDataType, column.ColumnLength)
+	}
+	return column.ColumnDataType
+}
+
+func indexText(index *dialect.IndexModel) string {
+	names := index.ColumnNames
+	if len(names) == 0 && index.ColumnName != "" {
+		names = []string{index.ColumnName}
+	}
+	s := index.IndexName
+	if index.IndexType != "" {
+		s += " " + index.IndexType
+	}
+	return s + " (" + strings.Join(names, ", ") + ")"
+}
+
+func boolText(v bool) string {
+	if v {
+		return "是"
+	}
+	return ""
+}
+
+// dictionaryHeaders 数据字典 字段 表头
+var dictionaryHeaders = []string{"序号", "字段", "类型", "主键", "非空", "默认值", "注释"}
+
+func dictionaryColumnRow(dia dialect.Dialect, index int, column *dialect.ColumnModel) []string {
+	return []string{
+		fmt.Sprint(index + 1),
+		column.ColumnName,
+		columnTypeText(dia, column),
+		boolText(column.PrimaryKey),
+		boolText(column.ColumnNotNull),
+		column.ColumnDefault,
+		column.ColumnComment,
+	}
+}
+
+func markdownEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\r", "")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// dictionaryMarkdown 生成 Markdown 数据字典
+func dictionaryMarkdown(dia dialect.Dialect, ownerName string, tables []*dialect.TableModel) string {
+	var buf bytes.Buffer
+	buf.WriteString("# " + ownerName + " 数据字典\n\n")
+	buf.WriteString("| 表名 | 注释 |\n| --- | --- |\n")
+	for _, table := range tables {
+		buf.WriteString("| " + markdownEscape(table.TableName) + " | " + markdownEscape(table.TableComment) + " |\n")
+	}
+	for _, table := range tables {
+		buf.WriteString("\n## " + table.TableName)
+		if table.TableComment != "" {
+			buf.WriteString(" " + markdownEscape(table.TableComment))
+		}
+		buf.WriteString("\n\n| " + strings.Join(dictionaryHeaders, " | ") + " |\n")
+		buf.WriteString(strings.Repeat("| --- ", len(dictionaryHeaders)) + "|\n")
+		for i, column := range table.ColumnList {
+			row := dictionaryColumnRow(dia, i, column)
+			for n := range row {
+				row[n] = markdownEscape(row[n])
+			}
+			buf.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		}
+		if len(table.IndexList) > 0 {
+			buf.WriteString("\n索引：\n\n")
+			for _, index := range table.IndexList {
+				buf.WriteString("- " + markdownEscape(indexText(index)) + "\n")
+			}
+		}
+	}
+	return buf.String()
+}
+
+// dictionaryHtml 生成 HTML 数据字典
+func dictionaryHtml(dia dialect.Dialect, ownerName string, tables []*dialect.TableModel) string {
+	var buf bytes.Buffer
+	buf.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>` + html.EscapeString(ownerName) + ` 数据字典</title>`)
+	buf.WriteString(`<style>body{font-family:sans-serif;font-size:13px;}table{border-collapse:collapse;margin-bottom:10px;}th,td{border:1px solid #ccc;padding:4px 8px;text-align:left;}th{background:#f2f2f2;}</style>`)
+	buf.WriteString(`</head><body>`)
+	buf.WriteString(`<h1>` + html.EscapeString(ownerName) + ` 数据字典</h1>`)
+	buf.WriteString(`<table><tr><th>表名</th><th>注释</th></tr>`)
+	for _, table := range tables {
+		buf.WriteString(`<tr><td><a href="#table-` + html.EscapeString(table.TableName) + `">` + html.EscapeString(table.TableName) + `</a></td><td>` + html.EscapeString(table.TableComment) + `</td></tr>`)
+	}
+	buf.WriteString(`</table>`)
+	for _, table := range tables {
+		buf.WriteString(`<h2 id="table-` + html.EscapeString(table.TableName) + `">` + html.EscapeString(table.TableName))
+		if table.TableComment != "" {
+			buf.WriteString(` ` + html.EscapeString(table.TableComment))
+		}
+		buf.WriteString(`</h2><table><tr>`)
+		for _, header := range dictionaryHeaders {
+			buf.WriteString(`<th>` + header + `</th>`)
+		}
+		buf.WriteString(`</tr>`)
+		for i, column := range table.ColumnList {
+			buf.WriteString(`<tr>`)
+			for _, v := range dictionaryColumnRow(dia, i, column) {
+				buf.WriteString(`<td>` + html.EscapeString(v) + `</td>`)
+			}
+			buf.WriteString(`</tr>`)
+		}
+		buf.WriteString(`</table>`)
+		if len(table.IndexList) > 0 {
+			buf.WriteString(`<div>索引：</div><ul>`)
+			for _, index := range table.IndexList {
+				buf.WriteString(`<li>` + html.EscapeString(indexText(index)) + `</li>`)
+			}
+			buf.WriteString(`</ul>`)
+		}
+	}
+	buf.WriteString(`</body></html>`)
+	return buf.String()
+}
+
+var sheetNameInvalid = regexp.MustCompile(`[\\/?*\[\]:]`)
+
+// dictionaryXlsx 生成 XLSX 数据字典 第一个 sheet 为 表目录 每个表 一个 sheet
+func dictionaryXlsx(dia dialect.Dialect, tables []*dialect.TableModel) (file *xlsx.File, err error) {
+	file = xlsx.NewFile()
+	sheet, err := file.AddSheet("表目录")
+	if err != nil {
+		return
+	}
+	addRow := func(sheet *xlsx.Sheet, values ...string) {
+		row := sheet.AddRow()
+		for _, v := range values {
+			row.AddCell().SetString(v)
+		}
+	}
+	addRow(sheet, "表名", "注释", "Sheet")
+
+	var sheetNames = map[string]bool{"表目录": true}
+	for _, table := range tables {
+		name := sheetNameInvalid.ReplaceAllString(table.TableName, "_")
+		if len([]rune(name)) > 28 {
+			name = string([]rune(name)[:28])
+		}
+		sheetName := name
+		for i := 1; sheetNames[strings.ToLower(sheetName)]; i++ {
+			sheetName = fmt.Sprintf("%s~%d", name, i)
+		}
+		sheetNames[strings.ToLower(sheetName)] = true
+		addRow(sheet, table.TableName, table.TableComment, sheetName)
+
+		var tableSheet *xlsx.Sheet
+		tableSheet, err = file.AddSheet(sheetName)
+		if err != nil {
+			return
+		}
+		addRow(tableSheet, "表名", table.TableName, "注释", table.TableComment)
+		addRow(tableSheet)
+		addRow(tableSheet, dictionaryHeaders...)
+		for i, column := range table.ColumnList {
+			addRow(tableSheet, dictionaryColumnRow(dia, i, column)...)
+		}
+		if len(table.IndexList) > 0 {
+			addRow(tableSheet)
+			addRow(tableSheet, "索引", "类型", "字段", "注释")
+			for _, index := range table.IndexList {
+				names := index.ColumnNames
+				if len(names) == 0 && index.ColumnName != "" {
+					names = []string{index.ColumnName}
+				}
+				addRow(tableSheet, index.IndexName, index.IndexType, strings.Join(names, ", "), index.IndexComment)
+			}
+		}
+	}
+	return
+}
+
+var erNameInvalid = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func erName(name string) string {
+	s := erNameInvalid.ReplaceAllString(name, "_")
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+	return s
+}
+
+// erNamer 为 名称 分配 唯一 标识，a-b 和 a_b 替换 后 相同，追加 序号 区分
+type erNamer struct {
+	names map[string]string
+	used  map[string]bool
+	keys  []string
+}
+
+func newErNamer() *erNamer {
+	return &erNamer{names: map[string]string{}, used: map[string]bool{}}
+}
+
+// get 相同 名称 返回 相同 标识，关系 中的 表名 大小写 可能 不一致，找不到 时 忽略 大小写 匹配
+func (this_ *erNamer) get(name string) string {
+	if s, ok := this_.names[name]; ok {
+		return s
+	}
+	for _, key := range this_.keys {
+		if strings.EqualFold(key, name) {
+			return this_.names[key]
+		}
+	}
+	base := erName(name)
+	s := base
+	for i := 2; this_.used[strings.ToLower(s)]; i++ {
+		s = fmt.Sprintf("%s_%d", base, i)
+	}
+	this_.used[strings.ToLower(s)] = true
+	this_.names[name] = s
+	this_.keys = append(this_.keys, name)
+	return s
+}
+
+func newTableErNamer(tables []*dialect.TableModel) *erNamer {
+	namer := newErNamer()
+	for _, table := range tables {
+		namer.get(table.TableName)
+	}
+	return namer
+}
+
+func erQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, `'`)
+	s = strings.ReplaceAll(s, "\r", "")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// plantUmlReplacer 使用 Creole 转义符 ~ 转义 会被 识别 为 格式 的 字符
+var plantUmlReplacer = strings.NewReplacer(
+	"~", "~~", "<", "~<", ">", "~>", "{", "~{", "}", "~}", "[", "~[", "]", "~]",
+	"__", "~_~_", "--", "~-~-", "**", "~*~*", "//", "~/~/", `""`, `~"~"`, "==", "~=~=", "..", "~.~.",
+	"\r", "", "\n", " ",
+)
+
+// plantUmlText 实体 字段 文本，开头 的 可见性 符号 也 需要 转义
+func plantUmlText(s string) string {
+	s = plantUmlReplacer.Replace(s)
+	if s != "" && strings.ContainsRune("-#+*", rune(s[0])) {
+		s = "~" + s
+	}
+	return s
+}
+
+// erMermaid 生成 Mermaid erDiagram
+func erMermaid(dia dialect.Dialect, tables []*dialect.TableModel, relations []*TableRelation) string {
+	var buf bytes.Buffer
+	namer := newTableErNamer(tables)
+	buf.WriteString("erDiagram\n")
+	for _, table := range tables {
+		buf.WriteString("    " + namer.get(table.TableName) + " {\n")
+		for _, column := range table.ColumnList {
+			buf.WriteString("        " + erName(columnTypeText(dia, column)) + " " + erName(column.ColumnName))
+			if column.PrimaryKey {
+				buf.WriteString(" PK")
+			} else if findRelation(relations, table.TableName, column.ColumnName) != nil {
+				buf.WriteString(" FK")
+			}
+			if column.ColumnComment != "" {
+				buf.WriteString(` "` + erQuote(column.ColumnComment) + `"`)
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("    }\n")
+	}
+	for _, one := range relations {
+		buf.WriteString("    " + namer.get(one.RefTableName) + " ||--o{ " + namer.get(one.TableName) + ` : "` + erQuote(one.ColumnName) + `"` + "\n")
+	}
+	return buf.String()
+}
+
+// erPlantUml 生成 PlantUML 实体关系图
+func erPlantUml(dia dialect.Dialect, tables []*dialect.TableModel, relations []*TableRelation) string {
+	var buf bytes.Buffer
+	namer := newTableErNamer(tables)
+	buf.WriteString("@startuml\n")
+	for _, table := range tables {
+		buf.WriteString(`entity "` + erQuote(table.TableName) + `" as ` + namer.get(table.TableName) + " {\n")
+		var hasPrimaryKey bool
+		for _, column := range table.ColumnList {
+			if !column.PrimaryKey {
+				continue
+			}
+			hasPrimaryKey = true
+			buf.WriteString("  *" + plantUmlText(column.ColumnName) + " : " + plantUmlText(columnTypeText(dia, column)) + " <<PK>>\n")
+		}
+		if hasPrimaryKey {
+			buf.WriteString("  --\n")
+		}
+		for _, column := range table.ColumnList {
+			if column.PrimaryKey {
+				continue
+			}
+			buf.WriteString("  ")
+			if column.ColumnNotNull {
+				buf.WriteString("*")
+			}
+			buf.WriteString(plantUmlText(column.ColumnName) + " : " + plantUmlText(columnTypeText(dia, column)))
+			if findRelation(relations, table.TableName, column.ColumnName) != nil {
+				buf.WriteString(" <<FK>>")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("}\n")
+	}
+	for _, one := range relations {
+		buf.WriteString(namer.get(one.RefTableName) + " ||..o{ " + namer.get(one.TableName) + " : " + plantUmlText(one.ColumnName) + "\n")
+	}
+	buf.WriteString("@enduml\n")
+	return buf.String()
+}
+
+func dotEscape(s string) string {
+	for _, c := range []string{`\`, `{`, `}`, `|`, `<`, `>`, `"`} {
+		s = strings.ReplaceAll(s, c, `\`+c)
+	}
+	return s
+}
+
+// erDot 生成 Graphviz DOT 实体关系图
+func erDot(dia dialect.Dialect, tables []*dialect.TableModel, relations []*TableRelation) string {
+	var buf bytes.Buffer
+	buf.WriteString("digraph er {\n")
+	buf.WriteString("    rankdir=LR;\n")
+	buf.WriteString("    node [shape=record, fontsize=10];\n")
+	namer := newTableErNamer(tables)
+	// 每个表 的 字段 端口 单独 分配
+	var columnNamers = map[string]*erNamer{}
+	columnNamer := func(tableName string) *erNamer {
+		key := namer.get(tableName)
+		if columnNamers[key] == nil {
+			columnNamers[key] = newErNamer()
+		}
+		return columnNamers[key]
+	}
+	for _, table := range tables {
+		var lines []string
+		for _, column := range table.ColumnList {
+			line := "<" + columnNamer(table.TableName).get(column.ColumnName) + "> " + dotEscape(column.ColumnName+" : "+columnTypeText(dia, column))
+			if column.PrimaryKey {
+				line += " (PK)"
+			}
+			lines = append(lines, line+`\l`)
+		}
+		buf.WriteString(`    ` + namer.get(table.TableName) + ` [label="{` + dotEscape(table.TableName) + `|` + strings.Join(lines, "") + `}"];` + "\n")
+	}
+	for _, one := range relations {
+		buf.WriteString(`    ` + namer.get(one.TableName) + `:` + columnNamer(one.TableName).get(one.ColumnName) + ` -> ` + namer.get(one.RefTableName) + `:` + columnNamer(one.RefTableName).get(one.RefColumnName))
+		if one.IsNamingInferred {
+			buf.WriteString(` [style=dashed]`)
+		}
+		buf.WriteString(";\n")
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+func findRelation(relations []*TableRelation, tableName string, columnName string) *TableRelation {
+	for _, one := range relations {
+		if strings.EqualFold(one.TableName, tableName) && strings.EqualFold(one.ColumnName, columnName) {
+			return one
+		}
+	}
+	return nil
+}
+
+func erDiagram(dia dialect.Dialect, erType string, tables []*dialect.TableModel, relations []*TableRelation) (content string, err error) {
+	switch strings.ToLower(erType) {
+	case "", "mermaid":
+		content = erMermaid(dia, tables, relations)
+	case "plantuml":
+		content = erPlantUml(dia, tables, relations)
+	case "dot":
+		content = erDot(dia, tables, relations)
+	default:
+		err = errors.New("不支持的ER图类型[" + erType + "]")
+	}
+	return
+}
+
+func (this_ *api) dictionary(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	var request = &DictionaryRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	param := this_.getParam(requestBean, c)
+	tables, relations, err := loadDictionary(service, param, request)
+	if err != nil {
+		return
+	}
+	dia := service.GetTargetDialect(param)
+
+	data := map[string]interface{}{}
+	data["tables"] = tables
+	data["relations"] = relations
+	switch strings.ToLower(request.Format) {
+	case "", "markdown":
+		data["content"] = dictionaryMarkdown(dia, request.OwnerName, tables)
+	case "html":
+		data["content"] = dictionaryHtml(dia, request.OwnerName, tables)
+	case "xlsx":
+		err = errors.New("xlsx 格式 请使用 下载 导出")
+		return
+	default:
+		err = errors.New("不支持的导出格式[" + request.Format + "]")
+		return
+	}
+	data["er"], err = erDiagram(dia, request.ErType, tables, relations)
+	if err != nil {
+		return
+	}
+	res = data
+	return
+}
+
+func (this_ *api) dictionaryDownload(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	var request = &DictionaryRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	param := this_.getParam(requestBean, c)
+	tables, relations, err := loadDictionary(service, param, request)
+	if err != nil {
+		return
+	}
+	dia := service.GetTargetDialect(param)
+
+	var fileName = request.OwnerName
+	var buf bytes.Buffer
+	switch strings.ToLower(request.Format) {
+	case "", "markdown":
+		fileName += "-数据字典.md"
+		buf.WriteString(dictionaryMarkdown(dia, request.OwnerName, tables))
+	case "html":
+		fileName += "-数据字典.html"
+		buf.WriteString(dictionaryHtml(dia, request.OwnerName, tables))
+	case "xlsx":
+		fileName += "-数据字典.xlsx"
+		var file *xlsx.File
+		file, err = dictionaryXlsx(dia, tables)
+		if err != nil {
+			return
+		}
+		err = file.Write(&buf)
+		if err != nil {
+			return
+		}
+	case "mermaid", "plantuml", "dot":
+		var content string
+		content, err = erDiagram(dia, request.Format, tables, relations)
+		if err != nil {
+			return
+		}
+		fileName += "-ER." + map[string]string{"mermaid": "mmd", "plantuml": "puml", "dot": "dot"}[strings.ToLower(request.Format)]
+		buf.WriteString(content)
+	default:
+		err = errors.New("不支持的导出格式[" + request.Format + "]")
+		return
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", "attachment; filename="+url.QueryEscape(fileName))
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("Content-Length", fmt.Sprint(buf.Len()))
+	c.Header("download-file-name", fileName)
+
+	_, err = c.Writer.Write(buf.Bytes())
+	if err != nil {
+		return
+	}
+
+	c.Status(http.StatusOK)
+	res = base.HttpNotResponse
+	return
+}
@@ -0,0 +1,70 @@
+package module_database
+
+import (
+	"github.com/team-ide/go-dialect/dialect"
+	"strings"
+	"testing"
+)
+
+func TestPlantUmlText(t *testing.T) {
+	for _, one := range []struct {
+		text   string
+		expect string
+	}{
+		{"user_id", "user_id"},
+		{"user__id", "user~_~_id"},
+		{"a<b>", "a~<b~>"},
+		{"-flag", "~-flag"},
+		{"*x", "~*x"},
+		{"{static}", "~{static~}"},
+		{"a~b", "a~~b"},
+		{"line1\r\nline2", "line1 line2"},
+		{"varchar(32)", "varchar(32)"},
+	} {
+		if s := plantUmlText(one.text); s != one.expect {
+			t.Errorf("plantuml text %q expect %q, got %q", one.text, one.expect, s)
+		}
+	}
+}
+
+func TestErNamer(t *testing.T) {
+	namer := newErNamer()
+	a := namer.get("a-b")
+	b := namer.get("a_b")
+	c := namer.get("a b")
+	if a != "a_b" || b != "a_b_2" || c != "a_b_3" {
+		t.Errorf("er namer expect a_b a_b_2 a_b_3, got %s %s %s", a, b, c)
+	}
+	if namer.get("a-b") != a || namer.get("A-B") != a {
+		t.Errorf("er namer expect same name for same table")
+	}
+	if s := namer.get("1x"); s != "_1x" {
+		t.Errorf("er namer expect _1x, got %s", s)
+	}
+}
+
+func TestErDiagramCollision(t *testing.T) {
+	tables := []*dialect.TableModel{
+		{TableName: "order-item", ColumnList: []*dialect.ColumnModel{{ColumnName: "id", ColumnDataType: "int", PrimaryKey: true}}},
+		{TableName: "order_item", ColumnList: []*dialect.ColumnModel{
+			{ColumnName: "id", ColumnDataType: "int", PrimaryKey: true},
+			{ColumnName: "item-id", ColumnDataType: "int"},
+			{ColumnName: "item_id", ColumnDataType: "int"},
+		}},
+	}
+	relations := []*TableRelation{{TableName: "order_item", ColumnName: "item_id", RefTableName: "order-item", RefColumnName: "id"}}
+
+	uml := erPlantUml(nil, tables, relations)
+	for _, expect := range []string{`entity "order-item" as order_item {`, `entity "order_item" as order_item_2 {`, "order_item ||..o{ order_item_2 : item_id"} {
+		if !strings.Contains(uml, expect) {
+			t.Errorf("plantuml expect contains %q, got:\n%s", expect, uml)
+		}
+	}
+
+	dot := erDot(nil, tables, relations)
+	for _, expect := range []string{"<item_id> item-id", "<item_id_2> item_id", "order_item_2:item_id_2 -> order_item:id"} {
+		if !strings.Contains(dot, expect) {
+			t.Errorf("dot expect contains %q, got:\n%s", expect, dot)
+		}
+	}
+}
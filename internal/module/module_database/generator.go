@@ -0,0 +1,364 @@
+package module_database
+
+import (
+	"fmt"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/util"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// ColumnGenerator 字段 数据 生成器
+type ColumnGenerator struct {
+	ColumnName string   `json:"columnName"`
+	Type       string   `json:"type"` // 生成类型 见 generatorTypes
+	Enums      []string `json:"enums,omitempty"`
+	Value      string   `json:"value,omitempty"` // 固定值
+	Min        int64    `json:"min,omitempty"`
+	Max        int64    `json:"max,omitempty"`
+	Length     int      `json:"length,omitempty"`
+	Scale      int      `json:"scale,omitempty"`
+	NullRate   int      `json:"nullRate,omitempty"` // 为空 的 百分比 0~100 只对可以为空的字段生效
+
+	RefTableName  string `json:"refTableName,omitempty"`
+	RefColumnName string `json:"refColumnName,omitempty"`
+
+	sequence  int64
+	refValues []interface{}
+	column    *dialect.ColumnModel
+}
+
+const (
+	generatorSkip     = "skip"
+	generatorFixed    = "fixed"
+	generatorSequence = "sequence"
+	generatorRef      = "ref"
+	generatorEnum     = "enum"
+	generatorName     = "name"
+	generatorUsername = "username"
+	generatorPhone    = "phone"
+	generatorEmail    = "email"
+	generatorAddress  = "address"
+	generatorIp       = "ip"
+	generatorUrl      = "url"
+	generatorUuid     = "uuid"
+	generatorDate     = "date"
+	generatorDatetime = "datetime"
+	generatorInteger  = "integer"
+	generatorDecimal  = "decimal"
+	generatorBoolean  = "boolean"
+	generatorString   = "string"
+	generatorText     = "text"
+	generatorGender   = "gender"
+	generatorAge      = "age"
+	generatorNull     = "null"
+)
+
+var (
+	generatorTypes = []string{
+		generatorSkip, generatorFixed, generatorSequence, generatorRef, generatorEnum,
+		generatorName, generatorUsername, generatorPhone, generatorEmail, generatorAddress,
+		generatorIp, generatorUrl, generatorUuid, generatorDate, generatorDatetime,
+		generatorInteger, generatorDecimal, generatorBoolean, generatorString, generatorText,
+		generatorGender, generatorAge, generatorNull,
+	}
+
+	firstNames  = []string{"张", "王", "李", "赵", "刘", "陈", "杨", "黄", "周", "吴", "徐", "孙", "马", "朱", "胡", "郭", "何", "林", "高", "罗"}
+	lastNames   = []string{"伟", "芳", "娜", "敏", "静", "丽", "强", "磊", "军", "洋", "勇", "艳", "杰", "涛", "明", "超", "秀英", "桂英", "志强", "建华"}
+	enNames     = []string{"james", "mary", "john", "linda", "robert", "susan", "michael", "lisa", "david", "karen", "tom", "emma", "jack", "lucy", "alice"}
+	mailDomains = []string{"example.com", "test.com", "demo.org", "mail.com"}
+	phoneHeads  = []string{"130", "131", "132", "135", "136", "137", "138", "139", "150", "151", "152", "158", "159", "176", "177", "186", "187", "188", "189"}
+	cities      = []string{"北京市", "上海市", "广州市", "深圳市", "杭州市", "南京市", "成都市", "武汉市", "西安市", "苏州市"}
+	streets     = []string{"人民路", "解放路", "中山路", "建设路", "和平路", "新华路", "长江路", "黄河路"}
+	letters     = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+func hasAnyName(name string, matches ...string) bool {
+	for _, match := range matches {
+		if strings.Contains(name, match) {
+			return true
+		}
+	}
+	return false
+}
+
+// inferColumnGenerator 根据 字段类型 和 字段名称 推断 生成器
+func inferColumnGenerator(dia dialect.Dialect, table *dialect.TableModel, column *dialect.ColumnModel, relation *TableRelation) (generator *ColumnGenerator) {
+	generator = &ColumnGenerator{
+		ColumnName: column.ColumnName,
+		Length:     column.ColumnLength,
+		Scale:      column.ColumnScale,
+		column:     column,
+	}
+	name := strings.ToLower(column.ColumnName)
+	extra := strings.ToLower(column.ColumnExtra)
+
+	var typeInfo *dialect.ColumnTypeInfo
+	if dia != nil {
+		typeInfo, _ = dia.GetColumnTypeInfo(column)
+	}
+	if typeInfo == nil {
+		typeInfo = &dialect.ColumnTypeInfo{}
+		dataType := strings.ToLower(column.ColumnDataType)
+		switch {
+		case hasAnyName(dataType, "int", "serial"):
+			typeInfo.IsNumber, typeInfo.IsInteger = true, true
+		case hasAnyName(dataType, "decimal", "numeric", "number", "float", "double", "real"):
+			typeInfo.IsNumber, typeInfo.IsFloat = true, true
+		case hasAnyName(dataType, "date", "time"):
+			typeInfo.IsDateTime = true
+		case hasAnyName(dataType, "bool", "bit"):
+			typeInfo.IsBoolean = true
+		case hasAnyName(dataType, "blob", "binary", "bytea"):
+			typeInfo.IsBytes = true
+		default:
+			typeInfo.IsString = true
+		}
+	}
+
+	switch {
+	case strings.Contains(extra, "auto_increment") || strings.Contains(strings.ToLower(column.ColumnDefault), "nextval"):
+		generator.Type = generatorSkip
+	case relation != nil:
+		generator.Type = generatorRef
+		generator.RefTableName = relation.RefTableName
+		generator.RefColumnName = relation.RefColumnName
+	case len(column.ColumnEnums) > 0:
+		generator.Type = generatorEnum
+		generator.Enums = column.ColumnEnums
+	case column.PrimaryKey && typeInfo.IsInteger:
+		generator.Type = generatorSequence
+	case column.PrimaryKey && typeInfo.IsString:
+		generator.Type = generatorUuid
+	case typeInfo.IsBytes:
+		generator.Type = generatorNull
+	case typeInfo.IsDateTime:
+		if strings.Contains(strings.ToLower(column.ColumnDataType), "time") {
+			generator.Type = generatorDatetime
+		} else {
+			generator.Type = generatorDate
+		}
+	case typeInfo.IsBoolean:
+		generator.Type = generatorBoolean
+	case typeInfo.IsNumber:
+		switch {
+		case hasAnyName(name, "age"):
+			generator.Type = generatorAge
+		case hasAnyName(name, "status", "state", "type", "flag", "level", "sex", "gender", "deleted"):
+			generator.Type = generatorInteger
+			generator.Min, generator.Max = 0, 3
+		case hasAnyName(name, "time", "date") && typeInfo.IsInteger && (column.ColumnPrecision == 0 || column.ColumnPrecision >= 13):
+			generator.Type = generatorInteger
+			now := time.Now().UnixMilli()
+			generator.Min, generator.Max = now-365*24*60*60*1000, now
+		case typeInfo.IsFloat || column.ColumnScale > 0:
+			generator.Type = generatorDecimal
+			generator.Min, generator.Max = 0, 10000
+		default:
+			generator.Type = generatorInteger
+		}
+	default:
+		switch {
+		case hasAnyName(name, "email", "mail"):
+			generator.Type = generatorEmail
+		case hasAnyName(name, "phone", "mobile", "tel"):
+			generator.Type = generatorPhone
+		case hasAnyName(name, "username", "account", "login"):
+			generator.Type = generatorUsername
+		case hasAnyName(name, "name", "nick"):
+			generator.Type = generatorName
+		case hasAnyName(name, "address", "addr"):
+			generator.Type = generatorAddress
+		case name == "ip" || strings.HasSuffix(name, "_ip") || strings.HasSuffix(name, "ipaddress"):
+			generator.Type = generatorIp
+		case hasAnyName(name, "url", "link", "avatar", "image", "img"):
+			generator.Type = generatorUrl
+		case hasAnyName(name, "uuid", "guid"):
+			generator.Type = generatorUuid
+		case hasAnyName(name, "sex", "gender"):
+			generator.Type = generatorGender
+		case hasAnyName(name, "date", "time", "birthday"):
+			generator.Type = generatorDatetime
+		case hasAnyName(name, "content", "remark", "comment", "desc", "note", "memo"):
+			generator.Type = generatorText
+		default:
+			generator.Type = generatorString
+		}
+	}
+	if table != nil && generator.Type == generatorSequence && len(table.PrimaryKeys) > 1 {
+		generator.Type = generatorInteger
+	}
+	return
+}
+
+// merge 使用 用户配置 覆盖 推断的 生成器
+func (this_ *ColumnGenerator) merge(custom *ColumnGenerator) {
+	if custom == nil || custom.Type == "" {
+		return
+	}
+	this_.Type = custom.Type
+	if len(custom.Enums) > 0 {
+		this_.Enums = custom.Enums
+	}
+	if custom.Value != "" {
+		this_.Value = custom.Value
+	}
+	if custom.Min != 0 || custom.Max != 0 {
+		this_.Min, this_.Max = custom.Min, custom.Max
+	}
+	if custom.Length > 0 {
+		this_.Length = custom.Length
+	}
+	if custom.Scale > 0 {
+		this_.Scale = custom.Scale
+	}
+	if custom.NullRate > 0 {
+		this_.NullRate = custom.NullRate
+	}
+	if custom.RefTableName != "" {
+		this_.RefTableName = custom.RefTableName
+		this_.RefColumnName = custom.RefColumnName
+	}
+}
+
+func randItem(list []string) string {
+	return list[rand.Intn(len(list))]
+}
+
+func randString(length int) string {
+	if length <= 0 {
+		length = 8
+	}
+	var sb strings.Builder
+	for i := 0; i < length; i++ {
+		sb.WriteByte(letters[rand.Intn(len(letters))])
+	}
+	return sb.String()
+}
+
+// randInt64 生成 [min, max] 的 随机数，范围 使用 uint64 计算，避免 max-min+1 溢出
+func randInt64(min int64, max int64) int64 {
+	if max <= min {
+		return min
+	}
+	span := uint64(max) - uint64(min)
+	if span < math.MaxInt64 {
+		return min + rand.Int63n(int64(span)+1)
+	}
+	// 范围 超过 Int63n 支持 的 上限，拒绝 采样 的 概率 不 超过 一半
+	for {
+		if v := rand.Uint64(); v <= span {
+			return int64(uint64(min) + v)
+		}
+	}
+}
+
+func (this_ *ColumnGenerator) fitLength(value string) string {
+	if this_.Length > 0 && len([]rune(value)) > this_.Length {
+		return string([]rune(value)[:this_.Length])
+	}
+	return value
+}
+
+func (this_ *ColumnGenerator) integerRange() (min int64, max int64) {
+	min, max = this_.Min, this_.Max
+	if min == 0 && max == 0 {
+		max = 100000
+		if this_.column != nil && this_.column.ColumnPrecision > 0 && this_.column.ColumnPrecision < 10 {
+			max = int64(math.Pow10(this_.column.ColumnPrecision-this_.column.ColumnScale)) - 1
+		}
+	}
+	return
+}
+
+// isSkip 不 参与 插入 的 字段，非空 字段 配置 为 null 时 交给 数据库 默认值
+func (this_ *ColumnGenerator) isSkip() bool {
+	switch this_.Type {
+	case generatorSkip:
+		return true
+	case generatorNull:
+		return this_.column != nil && this_.column.ColumnNotNull
+	}
+	return false
+}
+
+// next 生成 下一个 值
+func (this_ *ColumnGenerator) next(index int) (value interface{}, skip bool) {
+	if this_.isSkip() {
+		skip = true
+		return
+	}
+	if this_.NullRate > 0 && (this_.column == nil || !this_.column.ColumnNotNull) && rand.Intn(100) < this_.NullRate {
+		return
+	}
+	switch this_.Type {
+	case generatorNull:
+	case generatorFixed:
+		value = this_.Value
+	case generatorSequence:
+		value = this_.sequence + int64(index)
+	case generatorRef:
+		if len(this_.refValues) > 0 {
+			value = this_.refValues[rand.Intn(len(this_.refValues))]
+		}
+	case generatorEnum:
+		if len(this_.Enums) > 0 {
+			value = randItem(this_.Enums)
+		}
+	case generatorName:
+		value = this_.fitLength(randItem(firstNames) + randItem(lastNames))
+	case generatorUsername:
+		value = this_.fitLength(fmt.Sprintf("%s%d", randItem(enNames), rand.Intn(100000)))
+	case generatorPhone:
+		value = this_.fitLength(fmt.Sprintf("%s%08d", randItem(phoneHeads), rand.Intn(100000000)))
+	case generatorEmail:
+		value = this_.fitLength(fmt.Sprintf("%s%d@%s", randItem(enNames), rand.Intn(100000), randItem(mailDomains)))
+	case generatorAddress:
+		value = this_.fitLength(fmt.Sprintf("%s%s%d号", randItem(cities), randItem(streets), rand.Intn(999)+1))
+	case generatorIp:
+		value = fmt.Sprintf("%d.%d.%d.%d", rand.Intn(223)+1, rand.Intn(256), rand.Intn(256), rand.Intn(254)+1)
+	case generatorUrl:
+		value = this_.fitLength(fmt.Sprintf("https://www.%s/%s", randItem(mailDomains), randString(10)))
+	case generatorUuid:
+		value = this_.fitLength(util.GetUUID())
+	case generatorDate:
+		value = time.Now().AddDate(0, 0, -rand.Intn(365*3)).Format("2006-01-02")
+	case generatorDatetime:
+		value = time.Now().Add(-time.Duration(rand.Int63n(int64(365 * 24 * time.Hour)))).Format("2006-01-02 15:04:05")
+	case generatorInteger:
+		value = randInt64(this_.integerRange())
+	case generatorDecimal:
+		min, max := this_.integerRange()
+		scale := this_.Scale
+		if scale <= 0 {
+			scale = 2
+		}
+		v := float64(min) + rand.Float64()*float64(max-min)
+		value = fmt.Sprintf("%.*f", scale, v)
+	case generatorBoolean:
+		if this_.column != nil && strings.Contains(strings.ToLower(this_.column.ColumnDataType), "bool") {
+			value = rand.Intn(2) == 1
+		} else {
+			value = rand.Intn(2)
+		}
+	case generatorGender:
+		value = this_.fitLength(randItem([]string{"男", "女"}))
+	case generatorAge:
+		value = randInt64(18, 65)
+	case generatorText:
+		length := this_.Length
+		if length <= 0 || length > 200 {
+			length = 200
+		}
+		value = randString(rand.Intn(length) + 1)
+	default:
+		length := this_.Length
+		if length <= 0 || length > 32 {
+			length = 32
+		}
+		value = randString(rand.Intn(length) + 1)
+	}
+	return
+}
@@ -0,0 +1,213 @@
+package module_database
+
+import (
+	"github.com/team-ide/go-dialect/dialect"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestInferColumnGenerator(t *testing.T) {
+	table := &dialect.TableModel{TableName: "user", PrimaryKeys: []string{"id"}}
+	for _, one := range []struct {
+		column *dialect.ColumnModel
+		expect string
+	}{
+		{&dialect.ColumnModel{ColumnName: "id", ColumnDataType: "bigint", PrimaryKey: true}, generatorSequence},
+		{&dialect.ColumnModel{ColumnName: "id", ColumnDataType: "varchar", PrimaryKey: true}, generatorUuid},
+		{&dialect.ColumnModel{ColumnName: "id", ColumnDataType: "int", ColumnExtra: "auto_increment"}, generatorSkip},
+		{&dialect.ColumnModel{ColumnName: "email", ColumnDataType: "varchar"}, generatorEmail},
+		{&dialect.ColumnModel{ColumnName: "mobile", ColumnDataType: "varchar"}, generatorPhone},
+		{&dialect.ColumnModel{ColumnName: "nick_name", ColumnDataType: "varchar"}, generatorName},
+		{&dialect.ColumnModel{ColumnName: "age", ColumnDataType: "int"}, generatorAge},
+		{&dialect.ColumnModel{ColumnName: "price", ColumnDataType: "decimal", ColumnScale: 2}, generatorDecimal},
+		{&dialect.ColumnModel{ColumnName: "create_time", ColumnDataType: "datetime"}, generatorDatetime},
+		{&dialect.ColumnModel{ColumnName: "birthday", ColumnDataType: "date"}, generatorDate},
+		{&dialect.ColumnModel{ColumnName: "avatar", ColumnDataType: "blob"}, generatorNull},
+		{&dialect.ColumnModel{ColumnName: "level", ColumnDataType: "varchar", ColumnEnums: []string{"a", "b"}}, generatorEnum},
+	} {
+		generator := inferColumnGenerator(nil, table, one.column, nil)
+		if generator.Type != one.expect {
+			t.Errorf("column %s %s expect %s, got %s", one.column.ColumnName, one.column.ColumnDataType, one.expect, generator.Type)
+		}
+	}
+
+	relation := &TableRelation{RefTableName: "dept", RefColumnName: "id"}
+	generator := inferColumnGenerator(nil, table, &dialect.ColumnModel{ColumnName: "dept_id", ColumnDataType: "int"}, relation)
+	if generator.Type != generatorRef || generator.RefTableName != "dept" || generator.RefColumnName != "id" {
+		t.Errorf("ref column expect ref dept.id, got %s %s.%s", generator.Type, generator.RefTableName, generator.RefColumnName)
+	}
+
+	// 联合 主键 不 使用 序列
+	multi := &dialect.TableModel{TableName: "user_role", PrimaryKeys: []string{"user_id", "role_id"}}
+	generator = inferColumnGenerator(nil, multi, &dialect.ColumnModel{ColumnName: "user_id", ColumnDataType: "int", PrimaryKey: true}, nil)
+	if generator.Type != generatorInteger {
+		t.Errorf("multi primary key expect integer, got %s", generator.Type)
+	}
+}
+
+func TestColumnGeneratorMerge(t *testing.T) {
+	generator := &ColumnGenerator{ColumnName: "status", Type: generatorInteger, Min: 0, Max: 3, Length: 10}
+	generator.merge(nil)
+	generator.merge(&ColumnGenerator{Min: 5})
+	if generator.Type != generatorInteger || generator.Min != 0 {
+		t.Errorf("merge without type expect unchanged, got %s %d", generator.Type, generator.Min)
+	}
+	generator.merge(&ColumnGenerator{Type: generatorEnum, Enums: []string{"on", "off"}})
+	if generator.Type != generatorEnum || len(generator.Enums) != 2 || generator.Length != 10 {
+		t.Errorf("merge enum error, got %+v", generator)
+	}
+}
+
+func TestColumnGeneratorNext(t *testing.T) {
+	column := &dialect.ColumnModel{ColumnName: "v", ColumnNotNull: true}
+	for _, one := range []struct {
+		generator *ColumnGenerator
+		check     func(value interface{}) bool
+	}{
+		{&ColumnGenerator{Type: generatorFixed, Value: "x"}, func(v interface{}) bool { return v == "x" }},
+		{&ColumnGenerator{Type: generatorSequence, sequence: 10}, func(v interface{}) bool { return v == int64(13) }},
+		{&ColumnGenerator{Type: generatorInteger, Min: 5, Max: 7}, func(v interface{}) bool {
+			n := v.(int64)
+			return n >= 5 && n <= 7
+		}},
+		{&ColumnGenerator{Type: generatorDecimal, Min: 1, Max: 2, Scale: 3}, func(v interface{}) bool {
+			return regexp.MustCompile(`^1\.\d{3}$|^2\.000$`).MatchString(v.(string))
+		}},
+		{&ColumnGenerator{Type: generatorEnum, Enums: []string{"a"}}, func(v interface{}) bool { return v == "a" }},
+		{&ColumnGenerator{Type: generatorRef, refValues: []interface{}{int64(9)}}, func(v interface{}) bool { return v == int64(9) }},
+		{&ColumnGenerator{Type: generatorName, Length: 1}, func(v interface{}) bool { return len([]rune(v.(string))) == 1 }},
+		{&ColumnGenerator{Type: generatorPhone}, func(v interface{}) bool { return regexp.MustCompile(`^1\d{10}$`).MatchString(v.(string)) }},
+		{&ColumnGenerator{Type: generatorEmail}, func(v interface{}) bool { return regexp.MustCompile(`^\w+@[\w.]+$`).MatchString(v.(string)) }},
+		{&ColumnGenerator{Type: generatorIp}, func(v interface{}) bool {
+			return regexp.MustCompile(`^\d+\.\d+\.\d+\.\d+$`).MatchString(v.(string))
+		}},
+		{&ColumnGenerator{Type: generatorDate}, func(v interface{}) bool { return regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`).MatchString(v.(string)) }},
+		{&ColumnGenerator{Type: generatorAge}, func(v interface{}) bool {
+			n := v.(int64)
+			return n >= 18 && n <= 65
+		}},
+		{&ColumnGenerator{Type: generatorString, Length: 4}, func(v interface{}) bool {
+			n := len(v.(string))
+			return n >= 1 && n <= 4
+		}},
+		// 非空 字段 不 受 NullRate 影响
+		{&ColumnGenerator{Type: generatorFixed, Value: "y", NullRate: 100, column: column}, func(v interface{}) bool { return v == "y" }},
+		{&ColumnGenerator{Type: generatorFixed, Value: "y", NullRate: 100}, func(v interface{}) bool { return v == nil }},
+	} {
+		for i := 0; i < 20; i++ {
+			value, skip := one.generator.next(3)
+			if skip || !one.check(value) {
+				t.Errorf("generator %s got %v skip %v", one.generator.Type, value, skip)
+				break
+			}
+		}
+	}
+
+	if _, skip := (&ColumnGenerator{Type: generatorSkip}).next(0); !skip {
+		t.Errorf("skip generator expect skip")
+	}
+	if value, skip := (&ColumnGenerator{Type: generatorNull}).next(0); skip || value != nil {
+		t.Errorf("null generator expect nil value, got %v skip %v", value, skip)
+	}
+	if _, skip := (&ColumnGenerator{Type: generatorNull, column: column}).next(0); !skip {
+		t.Errorf("null generator on not null column expect skip")
+	}
+}
+
+func TestIntegerRange(t *testing.T) {
+	for _, one := range []struct {
+		generator *ColumnGenerator
+		min, max  int64
+	}{
+		{&ColumnGenerator{}, 0, 100000},
+		{&ColumnGenerator{Min: 3, Max: 8}, 3, 8},
+		{&ColumnGenerator{column: &dialect.ColumnModel{ColumnPrecision: 5, ColumnScale: 2}}, 0, 999},
+		{&ColumnGenerator{column: &dialect.ColumnModel{ColumnPrecision: 12}}, 0, 100000},
+	} {
+		min, max := one.generator.integerRange()
+		if min != one.min || max != one.max {
+			t.Errorf("integer range expect %d~%d, got %d~%d", one.min, one.max, min, max)
+		}
+	}
+}
+
+func TestRandInt64(t *testing.T) {
+	for _, one := range []struct {
+		min int64
+		max int64
+	}{
+		{5, 5},
+		{5, 1},
+		{-3, 3},
+		{0, math.MaxInt64},
+		{-1, math.MaxInt64},
+		{math.MinInt64, math.MaxInt64},
+		{math.MinInt64, 0},
+		{math.MaxInt64 - 1, math.MaxInt64},
+	} {
+		for i := 0; i < 100; i++ {
+			res := randInt64(one.min, one.max)
+			if one.max <= one.min {
+				if res != one.min {
+					t.Fatalf("range %d %d expect min, got %d", one.min, one.max, res)
+				}
+				continue
+			}
+			if res < one.min || res > one.max {
+				t.Fatalf("range %d %d got out of range %d", one.min, one.max, res)
+			}
+		}
+	}
+}
+
+func TestGenerateTableNextRows(t *testing.T) {
+	table := &GenerateTable{
+		TableName: "user",
+		Generators: []*ColumnGenerator{
+			{ColumnName: "id", Type: generatorSkip, column: &dialect.ColumnModel{ColumnName: "id", ColumnNotNull: true}},
+			{ColumnName: "seq", Type: generatorSequence, sequence: 100, column: &dialect.ColumnModel{ColumnName: "seq"}},
+			{ColumnName: "photo", Type: generatorNull, column: &dialect.ColumnModel{ColumnName: "photo", ColumnNotNull: true}},
+			{ColumnName: "remark", Type: generatorNull, column: &dialect.ColumnModel{ColumnName: "remark"}},
+		},
+	}
+	columnList, dataList := table.nextRows(5, 3)
+	var names []string
+	for _, column := range columnList {
+		names = append(names, column.ColumnName)
+	}
+	if strings.Join(names, ",") != "seq,remark" {
+		t.Errorf("column list expect seq,remark, got %v", names)
+	}
+	if len(dataList) != 3 {
+		t.Fatalf("data list expect 3 rows, got %d", len(dataList))
+	}
+	for i, data := range dataList {
+		if len(data) != len(columnList) {
+			t.Errorf("row %d expect %d values, got %v", i, len(columnList), data)
+		}
+		if data["seq"] != int64(105+i) {
+			t.Errorf("row %d seq expect %d, got %v", i, 105+i, data["seq"])
+		}
+		if v, ok := data["remark"]; !ok || v != nil {
+			t.Errorf("row %d remark expect nil, got %v", i, v)
+		}
+	}
+}
+
+func TestGenerateTaskStatus(t *testing.T) {
+	task := &GenerateTask{TaskId: "t", Tables: []*GenerateTable{{TableName: "a", RowCount: 10}}}
+	task.stop()
+	if !task.isStop() {
+		t.Errorf("task expect stopped")
+	}
+	status := task.status()
+	task.Tables[0].SuccessCount = 5
+	if status == task || status.Tables[0] == task.Tables[0] || status.Tables[0].SuccessCount != 0 {
+		t.Errorf("status expect snapshot copy")
+	}
+	if !status.IsStop {
+		t.Errorf("status expect isStop")
+	}
+}
@@ -0,0 +1,246 @@
+package module_database
+
+import (
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"strings"
+)
+
+// TableRelation 表关系 外键 或 根据命名推断
+type TableRelation struct {
+	TableName        string `json:"tableName"`
+	ColumnName       string `json:"columnName"`
+	RefTableName     string `json:"refTableName"`
+	RefColumnName    string `json:"refColumnName"`
+	IsForeignKey     bool   `json:"isForeignKey"`     // 是否是 数据库 定义的 外键
+	IsNamingInferred bool   `json:"isNamingInferred"` // 是否是 根据 命名规则 推断
+}
+
+// foreignKeysSelectSql 查询 外键 SQL，不支持的数据库返回空
+func foreignKeysSelectSql(dia dialect.Dialect, ownerName string, tableName string) (sql string) {
+	owner := strings.ReplaceAll(ownerName, "'", "''")
+	switch dia.DialectType().Name {
+	case dialect.TypeMysql.Name:
+		sql = `SELECT TABLE_NAME tableName,COLUMN_NAME columnName,REFERENCED_TABLE_NAME refTableName,REFERENCED_COLUMN_NAME refColumnName FROM information_schema.KEY_COLUMN_USAGE `
+		sql += `WHERE TABLE_SCHEMA='` + owner + `' AND REFERENCED_TABLE_NAME IS NOT NULL`
+	case dialect.TypePostgresql.Name, dialect.TypeOpenGauss.Name, dialect.TypeKingBase.Name:
+		sql = `SELECT tc.table_name tableName,kcu.column_name columnName,ccu.table_name refTableName,ccu.column_name refColumnName FROM information_schema.table_constraints tc `
+		sql += `JOIN information_schema.key_column_usage kcu ON tc.constraint_name=kcu.constraint_name AND tc.table_schema=kcu.table_schema `
+		sql += `JOIN information_schema.constraint_column_usage ccu ON ccu.constraint_name=tc.constraint_name AND ccu.table_schema=tc.table_schema `
+		sql += `WHERE tc.constraint_type='FOREIGN KEY' AND tc.table_schema='` + owner + `'`
+	case dialect.TypeOracle.Name, dialect.TypeDM.Name, dialect.TypeShenTong.Name:
+		sql = `SELECT a.TABLE_NAME tableName,a.COLUMN_NAME columnName,pk.TABLE_NAME refTableName,b.COLUMN_NAME refColumnName FROM ALL_CONS_COLUMNS a `
+		sql += `JOIN ALL_CONSTRAINTS c ON a.OWNER=c.OWNER AND a.CONSTRAINT_NAME=c.CONSTRAINT_NAME `
+		sql += `JOIN ALL_CONSTRAINTS pk ON c.R_OWNER=pk.OWNER AND c.R_CONSTRAINT_NAME=pk.CONSTRAINT_NAME `
+		sql += `JOIN ALL_CONS_COLUMNS b ON b.OWNER=pk.OWNER AND b.CONSTRAINT_NAME=pk.CONSTRAINT_NAME AND b.POSITION=a.POSITION `
+		sql += `WHERE c.CONSTRAINT_TYPE='R' AND a.OWNER='` + owner + `'`
+	case dialect.TypeSqlite.Name:
+		if tableName == "" {
+			return
+		}
+		sql = `SELECT '` + strings.ReplaceAll(tableName, "'", "''") + `' tableName,"from" columnName,"table" refTableName,"to" refColumnName FROM pragma_foreign_key_list('` + strings.ReplaceAll(tableName, "'", "''") + `')`
+	}
+	return
+}
+
+// getMapValue 忽略大小写 获取 值，不同数据库返回的 列名 大小写不一致
+func getMapValue(data map[string]interface{}, name string) string {
+	if v, ok := data[name]; ok {
+		return util.GetStringValue(v)
+	}
+	for k, v := range data {
+		if strings.EqualFold(k, name) {
+			return util.GetStringValue(v)
+		}
+	}
+	return ""
+}
+
+// loadForeignKeys 加载 外键，查询失败的 忽略
+func loadForeignKeys(service db.IService, param *db.Param, ownerName string, tables []*dialect.TableModel) (relations []*TableRelation) {
+	dia := service.GetTargetDialect(param)
+	var sqlList []string
+	if dia.DialectType().Name == dialect.TypeSqlite.Name {
+		for _, table := range tables {
+			sqlList = append(sqlList, foreignKeysSelectSql(dia, ownerName, table.TableName))
+		}
+	} else if sql := foreignKeysSelectSql(dia, ownerName, ""); sql != "" {
+		sqlList = append(sqlList, sql)
+	}
+
+	for _, sql := range sqlList {
+		list, err := service.QueryMap(sql, nil)
+		if err != nil {
+			util.Logger.Warn("load foreign keys error", zap.Any("ownerName", ownerName), zap.Error(err))
+			continue
+		}
+		for _, one := range list {
+			relation := &TableRelation{
+				TableName:     getMapValue(one, "tableName"),
+				ColumnName:    getMapValue(one, "columnName"),
+				RefTableName:  getMapValue(one, "refTableName"),
+				RefColumnName: getMapValue(one, "refColumnName"),
+				IsForeignKey:  true,
+			}
+			if relation.TableName == "" || relation.RefTableName == "" {
+				continue
+			}
+			relations = append(relations, relation)
+		}
+	}
+	return
+}
+
+// namingRefSuffixes 根据 命名规则 推断 关联字段 的 后缀
+var namingRefSuffixes = []string{"_id", "Id", "ID", "_no", "No", "_code", "Code"}
+
+// namingTablePrefixes 表名 常见前缀
+var namingTablePrefixes = []string{"", "t_", "tb_", "tbl_", "sys_"}
+
+// inferRelations 根据 命名规则 推断 关联关系，如 user_id 关联 user 表 的 主键
+func inferRelations(tables []*dialect.TableModel) (relations []*TableRelation) {
+	var tableCache = map[string]*dialect.TableModel{}
+	for _, table := range tables {
+		tableCache[strings.ToLower(table.TableName)] = table
+	}
+	findTable := func(base string) *dialect.TableModel {
+		base = strings.ToLower(base)
+		for _, prefix := range namingTablePrefixes {
+			for _, name := range []string{base, base + "s", base + "es", strings.TrimSuffix(base, "y") + "ies"} {
+				if find := tableCache[prefix+name]; find != nil {
+					return find
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, table := range tables {
+		for _, column := range table.ColumnList {
+			if column.PrimaryKey && len(table.PrimaryKeys) <= 1 {
+				continue
+			}
+			var base string
+			for _, suffix := range namingRefSuffixes {
+				if len(column.ColumnName) > len(suffix) && strings.HasSuffix(column.ColumnName, suffix) {
+					base = strings.TrimSuffix(column.ColumnName, suffix)
+					break
+				}
+			}
+			if base == "" {
+				continue
+			}
+			refTable := findTable(base)
+			if refTable == nil || refTable == table {
+				continue
+			}
+			refColumnName := getSinglePrimaryKey(refTable)
+			if refColumnName == "" {
+				if refTable.FindColumnByName("id") != nil {
+					refColumnName = "id"
+				} else {
+					continue
+				}
+			}
+			relations = append(relations, &TableRelation{
+				TableName:        table.TableName,
+				ColumnName:       column.ColumnName,
+				RefTableName:     refTable.TableName,
+				RefColumnName:    refColumnName,
+				IsNamingInferred: true,
+			})
+		}
+	}
+	return
+}
+
+func getSinglePrimaryKey(table *dialect.TableModel) string {
+	if len(table.PrimaryKeys) == 1 {
+		return table.PrimaryKeys[0]
+	}
+	var names []string
+	for _, column := range table.ColumnList {
+		if column.PrimaryKey {
+			names = append(names, column.ColumnName)
+		}
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return ""
+}
+
+// loadRelations 加载 表关系，优先使用 外键，没有外键的表 根据 命名规则 推断
+func loadRelations(service db.IService, param *db.Param, ownerName string, tables []*dialect.TableModel, inferByNaming bool) (relations []*TableRelation) {
+	relations = loadForeignKeys(service, param, ownerName, tables)
+	if !inferByNaming {
+		return
+	}
+	var hasForeignKey = map[string]bool{}
+	for _, one := range relations {
+		hasForeignKey[strings.ToLower(one.TableName)] = true
+	}
+	for _, one := range inferRelations(tables) {
+		if hasForeignKey[strings.ToLower(one.TableName)] {
+			continue
+		}
+		relations = append(relations, one)
+	}
+	return
+}
+
+// sortTablesByRelation 根据 关系 排序，被依赖的表 排在前面，存在循环依赖的 按原顺序追加
+func sortTablesByRelation(tables []*dialect.TableModel, relations []*TableRelation) (sorted []*dialect.TableModel) {
+	var dependCache = map[string]map[string]bool{}
+	var tableCache = map[string]bool{}
+	for _, table := range tables {
+		tableCache[strings.ToLower(table.TableName)] = true
+	}
+	for _, one := range relations {
+		name := strings.ToLower(one.TableName)
+		refName := strings.ToLower(one.RefTableName)
+		if name == refName || !tableCache[refName] {
+			continue
+		}
+		if dependCache[name] == nil {
+			dependCache[name] = map[string]bool{}
+		}
+		dependCache[name][refName] = true
+	}
+
+	var done = map[string]bool{}
+	for len(sorted) < len(tables) {
+		var added bool
+		for _, table := range tables {
+			name := strings.ToLower(table.TableName)
+			if done[name] {
+				continue
+			}
+			var ready = true
+			for refName := range dependCache[name] {
+				if !done[refName] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				done[name] = true
+				sorted = append(sorted, table)
+				added = true
+			}
+		}
+		if !added {
+			// 循环依赖
+			for _, table := range tables {
+				name := strings.ToLower(table.TableName)
+				if !done[name] {
+					done[name] = true
+					sorted = append(sorted, table)
+				}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,441 @@
+package module_database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-dialect/dialect"
+	"github.com/team-ide/go-tool/db"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+)
+
+type GenerateRequest struct {
+	WorkerId       string         `json:"workerId,omitempty"`
+	OwnerName      string         `json:"ownerName,omitempty"`
+	TableNames     []string       `json:"tableNames,omitempty"`     // 为空 则 生成 库下所有表
+	RowCount       int            `json:"rowCount,omitempty"`       // 每个表 生成 行数
+	TableRowCounts map[string]int `json:"tableRowCounts,omitempty"` // 单独 配置 某个表 的 行数
+	BatchSize      int            `json:"batchSize,omitempty"`
+	InferByNaming  bool           `json:"inferByNaming,omitempty"` // 没有外键时 根据 命名规则 推断 关联关系
+	PreviewSize    int            `json:"previewSize,omitempty"`
+
+	// 用户 自定义 生成器 表名 -> 字段名 -> 生成器
+	ColumnGenerators map[string]map[string]*ColumnGenerator `json:"columnGenerators,omitempty"`
+}
+
+type GenerateTable struct {
+	TableName    string             `json:"tableName"`
+	RowCount     int                `json:"rowCount"`
+	SuccessCount int                `json:"successCount"`
+	ErrorCount   int                `json:"errorCount"`
+	IsEnd        bool               `json:"isEnd"`
+	Error        string             `json:"error,omitempty"`
+	Generators   []*ColumnGenerator `json:"generators"`
+
+	table *dialect.TableModel
+}
+
+type GenerateTask struct {
+	TaskId    string `json:"taskId"`
+	OwnerName string `json:"ownerName"`
+	StartTime int64  `json:"startTime"`
+	EndTime   int64  `json:"endTime"`
+	UseTime   int64  `json:"useTime"`
+	IsEnd     bool   `json:"isEnd"`
+	IsStop    bool   `json:"isStop"`
+	Error     string `json:"error,omitempty"`
+
+	TableCount        int `json:"tableCount"`
+	TableSuccessCount int `json:"tableSuccessCount"`
+	TableErrorCount   int `json:"tableErrorCount"`
+	DataCount         int `json:"dataCount"`
+	DataSuccessCount  int `json:"dataSuccessCount"`
+	DataErrorCount    int `json:"dataErrorCount"`
+
+	Tables    []*GenerateTable `json:"tables"`
+	Relations []*TableRelation `json:"relations"`
+
+	request *GenerateRequest
+	service db.IService
+	param   *db.Param
+	dia     dialect.Dialect
+	lock    sync.Mutex
+}
+
+// generateTaskCache 生成 任务 与 导入 导出 任务 共用 task/status task/stop task/clean 和 workerTasksCache
+var generateTaskCache = map[string]*GenerateTask{}
+var generateTaskCacheLock = &sync.Mutex{}
+
+func getGenerateTask(taskId string) *GenerateTask {
+	generateTaskCacheLock.Lock()
+	defer generateTaskCacheLock.Unlock()
+	return generateTaskCache[taskId]
+}
+
+func removeGenerateTask(taskId string) {
+	generateTaskCacheLock.Lock()
+	defer generateTaskCacheLock.Unlock()
+	task := generateTaskCache[taskId]
+	if task != nil {
+		task.stop()
+		delete(generateTaskCache, taskId)
+	}
+}
+
+// newGenerateTask 加载 表 和 关联关系 并 推断 每个字段 的 生成器
+func newGenerateTask(service db.IService, param *db.Param, request *GenerateRequest) (task *GenerateTask, err error) {
+	task = &GenerateTask{
+		TaskId:    util.GetUUID(),
+		OwnerName: request.OwnerName,
+		request:   request,
+		service:   service,
+		param:     param,
+		dia:       service.GetTargetDialect(param),
+	}
+
+	var tableNames = request.TableNames
+	if len(tableNames) == 0 {
+		var tables []*dialect.TableModel
+		tables, err = service.TablesSelect(param, request.OwnerName)
+		if err != nil {
+			return
+		}
+		for _, table := range tables {
+			tableNames = append(tableNames, table.TableName)
+		}
+	}
+	if len(tableNames) == 0 {
+		err = errors.New("owner [" + request.OwnerName + "] has no table")
+		return
+	}
+
+	var tables []*dialect.TableModel
+	for _, tableName := range tableNames {
+		var table *dialect.TableModel
+		table, err = service.TableDetail(param, request.OwnerName, tableName)
+		if err != nil {
+			return
+		}
+		if table == nil {
+			err = errors.New("table [" + tableName + "] not found")
+			return
+		}
+		tables = append(tables, table)
+	}
+
+	task.Relations = loadRelations(service, param, request.OwnerName, tables, request.InferByNaming)
+	tables = sortTablesByRelation(tables, task.Relations)
+
+	for _, table := range tables {
+		generateTable := &GenerateTable{
+			TableName: table.TableName,
+			RowCount:  request.RowCount,
+			table:     table,
+		}
+		if count, ok := request.TableRowCounts[table.TableName]; ok {
+			generateTable.RowCount = count
+		}
+		customs := request.ColumnGenerators[table.TableName]
+		for _, column := range table.ColumnList {
+			var relation *TableRelation
+			for _, one := range task.Relations {
+				if strings.EqualFold(one.TableName, table.TableName) && strings.EqualFold(one.ColumnName, column.ColumnName) {
+					relation = one
+					break
+				}
+			}
+			generator := inferColumnGenerator(task.dia, table, column, relation)
+			generator.merge(customs[column.ColumnName])
+			generateTable.Generators = append(generateTable.Generators, generator)
+		}
+		task.Tables = append(task.Tables, generateTable)
+		task.TableCount++
+		task.DataCount += generateTable.RowCount
+	}
+	return
+}
+
+// loadSequenceStart 整型主键 从 当前 最大值 之后 开始
+func (this_ *GenerateTask) loadSequenceStart(table *GenerateTable, generator *ColumnGenerator) {
+	generator.sequence = 1
+	if generator.Min > 0 {
+		generator.sequence = generator.Min
+	}
+	sql := "SELECT MAX(" + this_.dia.ColumnNamePack(this_.param.ParamModel, generator.ColumnName) + ") " +
+		"FROM " + this_.dia.OwnerTablePack(this_.param.ParamModel, this_.OwnerName, table.TableName)
+	list, err := this_.service.QueryMap(sql, nil)
+	if err != nil {
+		util.Logger.Warn("generate load sequence start error", zap.Any("tableName", table.TableName), zap.Error(err))
+		return
+	}
+	for _, one := range list {
+		for _, v := range one {
+			max, e := strconv.ParseInt(util.GetStringValue(v), 10, 64)
+			if e == nil && max >= generator.sequence {
+				generator.sequence = max + 1
+			}
+		}
+	}
+}
+
+// loadRefValues 加载 关联表 已有的 值 作为 外键 字段 的 候选值
+func (this_ *GenerateTask) loadRefValues(generator *ColumnGenerator) (err error) {
+	if generator.RefTableName == "" || generator.RefColumnName == "" {
+		return
+	}
+	columnName := this_.dia.ColumnNamePack(this_.param.ParamModel, generator.RefColumnName)
+	sql := "SELECT " + columnName + " FROM " + this_.dia.OwnerTablePack(this_.param.ParamModel, this_.OwnerName, generator.RefTableName)
+	sql = this_.dia.PackPageSql(sql, 1000, 1)
+	list, err := this_.service.QueryMap(sql, nil)
+	if err != nil {
+		return
+	}
+	generator.refValues = []interface{}{}
+	for _, one := range list {
+		v, find := one[generator.RefColumnName]
+		if !find {
+			for _, value := range one {
+				v = value
+			}
+		}
+		if v != nil {
+			generator.refValues = append(generator.refValues, v)
+		}
+	}
+	return
+}
+
+// prepare 生成前 准备 序列 起始值 和 关联值
+func (this_ *GenerateTask) prepare(table *GenerateTable) (err error) {
+	for _, generator := range table.Generators {
+		switch generator.Type {
+		case generatorSequence:
+			this_.loadSequenceStart(table, generator)
+		case generatorRef:
+			err = this_.loadRefValues(generator)
+			if err != nil {
+				return
+			}
+			if len(generator.refValues) == 0 && generator.column != nil && generator.column.ColumnNotNull {
+				err = errors.New("table [" + table.TableName + "] column [" + generator.ColumnName + "] ref table [" + generator.RefTableName + "] has no data")
+				return
+			}
+		}
+	}
+	return
+}
+
+// nextRows 生成 数据 行 startIndex 用于 序列
+func (table *GenerateTable) nextRows(startIndex int, size int) (columnList []*dialect.ColumnModel, dataList []map[string]interface{}) {
+	for _, generator := range table.Generators {
+		if !generator.isSkip() && generator.column != nil {
+			columnList = append(columnList, generator.column)
+		}
+	}
+	for i := 0; i < size; i++ {
+		data := map[string]interface{}{}
+		for _, generator := range table.Generators {
+			value, skip := generator.next(startIndex + i)
+			if skip {
+				continue
+			}
+			data[generator.ColumnName] = value
+		}
+		dataList = append(dataList, data)
+	}
+	return
+}
+
+func (this_ *GenerateTask) stop() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	this_.IsStop = true
+}
+
+func (this_ *GenerateTask) isStop() bool {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	return this_.IsStop
+}
+
+// status 在 锁 内 复制 进度，避免 返回 后 序列化 时 与 执行 协程 并发 读写
+func (this_ *GenerateTask) status() (res *GenerateTask) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	res = &GenerateTask{
+		TaskId:            this_.TaskId,
+		OwnerName:         this_.OwnerName,
+		StartTime:         this_.StartTime,
+		EndTime:           this_.EndTime,
+		UseTime:           this_.UseTime,
+		IsEnd:             this_.IsEnd,
+		IsStop:            this_.IsStop,
+		Error:             this_.Error,
+		TableCount:        this_.TableCount,
+		TableSuccessCount: this_.TableSuccessCount,
+		TableErrorCount:   this_.TableErrorCount,
+		DataCount:         this_.DataCount,
+		DataSuccessCount:  this_.DataSuccessCount,
+		DataErrorCount:    this_.DataErrorCount,
+		Relations:         this_.Relations,
+	}
+	if !res.IsEnd {
+		res.UseTime = util.GetNowMilli() - res.StartTime
+	}
+	for _, table := range this_.Tables {
+		one := *table
+		res.Tables = append(res.Tables, &one)
+	}
+	return
+}
+
+func (this_ *GenerateTask) run() {
+	defer func() {
+		e := recover()
+		if e != nil {
+			util.Logger.Error("generate task error", zap.Any("error", e))
+		}
+		this_.lock.Lock()
+		defer this_.lock.Unlock()
+		if e != nil {
+			this_.Error = fmt.Sprint(e)
+		}
+		this_.EndTime = util.GetNowMilli()
+		this_.UseTime = this_.EndTime - this_.StartTime
+		this_.IsEnd = true
+	}()
+
+	batchSize := this_.request.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	for _, table := range this_.Tables {
+		if this_.isStop() {
+			return
+		}
+		err := this_.generateTable(table, batchSize)
+		this_.lock.Lock()
+		table.IsEnd = true
+		if err != nil {
+			table.Error = err.Error()
+			this_.TableErrorCount++
+		} else {
+			this_.TableSuccessCount++
+		}
+		this_.lock.Unlock()
+	}
+}
+
+func (this_ *GenerateTask) generateTable(table *GenerateTable, batchSize int) (err error) {
+	err = this_.prepare(table)
+	if err != nil {
+		util.Logger.Error("generate table prepare error", zap.Any("tableName", table.TableName), zap.Error(err))
+		return
+	}
+	for index := 0; index < table.RowCount; index += batchSize {
+		if this_.isStop() {
+			return
+		}
+		size := batchSize
+		if index+size > table.RowCount {
+			size = table.RowCount - index
+		}
+		columnList, dataList := table.nextRows(index, size)
+		_, _, batchSqlList, batchValuesList, e := this_.dia.DataListInsertSql(this_.param.ParamModel, this_.OwnerName, table.TableName, columnList, dataList)
+		if e == nil {
+			_, e = this_.service.Execs(batchSqlList, batchValuesList)
+		}
+		this_.lock.Lock()
+		if e != nil {
+			table.ErrorCount += size
+			this_.DataErrorCount += size
+			err = e
+		} else {
+			table.SuccessCount += size
+			this_.DataSuccessCount += size
+		}
+		this_.lock.Unlock()
+		if e != nil {
+			util.Logger.Error("generate table insert error", zap.Any("tableName", table.TableName), zap.Error(e))
+			return
+		}
+	}
+	return
+}
+
+func (this_ *api) generatePreview(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	var request = &GenerateRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	task, err := newGenerateTask(service, this_.getParam(requestBean, c), request)
+	if err != nil {
+		return
+	}
+	previewSize := request.PreviewSize
+	if previewSize <= 0 {
+		previewSize = 10
+	}
+	var previews = map[string][]map[string]interface{}{}
+	for _, table := range task.Tables {
+		_, previews[table.TableName] = table.nextRows(0, previewSize)
+	}
+
+	data := map[string]interface{}{}
+	data["tables"] = task.Tables
+	data["relations"] = task.Relations
+	data["previews"] = previews
+	data["generatorTypes"] = generatorTypes
+	res = data
+	return
+}
+
+func (this_ *api) generateStart(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	var request = &GenerateRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.RowCount <= 0 && len(request.TableRowCounts) == 0 {
+		err = errors.New("row count must be greater than 0")
+		return
+	}
+	task, err := newGenerateTask(service, this_.getParam(requestBean, c), request)
+	if err != nil {
+		return
+	}
+	task.StartTime = util.GetNowMilli()
+
+	generateTaskCacheLock.Lock()
+	generateTaskCache[task.TaskId] = task
+	generateTaskCacheLock.Unlock()
+
+	util.Logger.Info("generate start", zap.Any("taskId", task.TaskId), zap.Any("ownerName", task.OwnerName), zap.Any("tableCount", task.TableCount), zap.Any("dataCount", task.DataCount))
+	addWorkerTask(request.WorkerId, task.TaskId)
+	go task.run()
+
+	res = task.status()
+	return
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"sync"
 	"teamide/pkg/base"
+	"teamide/pkg/load"
 	"time"
 )
 
@@ -79,17 +80,17 @@ func (this_ *api) testStart(requestBean *base.RequestBean, c *gin.Context) (res
 		return
 	}
 
-	var t *task.Task
-	t, err = task.New(&task.Options{
+	runner, err := load.New(&task.Options{
 		Key:       fmt.Sprintf("%d", time.Now().UnixNano()),
 		Worker:    request.Worker,
 		Frequency: request.Frequency,
 		Duration:  request.Duration,
 		Executor:  executor,
-	})
+	}, request.LoadProfile, request.CountSecond)
 	if err != nil {
 		return
 	}
+	t := runner.Task()
 	if request.CountSecond > 0 {
 		t.Metric.SetCountSecond(request.CountSecond)
 	}
@@ -115,6 +116,9 @@ func (this_ *api) testStart(requestBean *base.RequestBean, c *gin.Context) (res
 		saveData["request"] = request
 		saveData["executor"] = executor
 		saveData["count"] = t.Metric.GetCount()
+		saveData["secondCounts"] = t.Metric.GetSecondCounts()
+		saveData["latency"] = runner.Latency.Total()
+		saveData["latencyBuckets"] = runner.Latency.Buckets()
 
 		f, e := os.Create(taskJsonPath)
 		if e != nil {
@@ -156,7 +160,7 @@ func (this_ *api) testStart(requestBean *base.RequestBean, c *gin.Context) (res
 			executor.Close()
 		}()
 
-		t.Run()
+		runner.Run()
 	}()
 	saveTask()
 	addTestTask(t)
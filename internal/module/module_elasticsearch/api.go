@@ -11,12 +11,14 @@ import (
 )
 
 type api struct {
-	toolboxService *module_toolbox.ToolboxService
+	toolboxService  *module_toolbox.ToolboxService
+	diagnoseService *DiagnoseService
 }
 
-func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+func NewApi(toolboxService *module_toolbox.ToolboxService, diagnoseService *DiagnoseService) *api {
 	return &api{
-		toolboxService: toolboxService,
+		toolboxService:  toolboxService,
+		diagnoseService: diagnoseService,
 	}
 }
 
@@ -45,6 +47,37 @@ var (
 	taskStopPower    = base.AppendPower(&base.PowerAction{Action: "taskStop", Text: "ES任务停止", ShouldLogin: true, StandAlone: true, Parent: Power})
 	taskCleanPower   = base.AppendPower(&base.PowerAction{Action: "taskClean", Text: "ES任务清理", ShouldLogin: true, StandAlone: true, Parent: Power})
 	closePower       = base.AppendPower(&base.PowerAction{Action: "close", Text: "ES关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	componentTemplateListPower   = base.AppendPower(&base.PowerAction{Action: "componentTemplateList", Text: "ES组件模板列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	componentTemplateGetPower    = base.AppendPower(&base.PowerAction{Action: "componentTemplateGet", Text: "ES组件模板查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	componentTemplatePutPower    = base.AppendPower(&base.PowerAction{Action: "componentTemplatePut", Text: "ES组件模板保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	componentTemplateDeletePower = base.AppendPower(&base.PowerAction{Action: "componentTemplateDelete", Text: "ES组件模板删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	indexTemplateListPower       = base.AppendPower(&base.PowerAction{Action: "indexTemplateList", Text: "ES索引模板列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	indexTemplateGetPower        = base.AppendPower(&base.PowerAction{Action: "indexTemplateGet", Text: "ES索引模板查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	indexTemplatePutPower        = base.AppendPower(&base.PowerAction{Action: "indexTemplatePut", Text: "ES索引模板保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	indexTemplateDeletePower     = base.AppendPower(&base.PowerAction{Action: "indexTemplateDelete", Text: "ES索引模板删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	indexTemplateSimulatePower   = base.AppendPower(&base.PowerAction{Action: "indexTemplateSimulate", Text: "ES索引模板模拟", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	ilmPolicyListPower   = base.AppendPower(&base.PowerAction{Action: "ilmPolicyList", Text: "ES生命周期策略列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ilmPolicyGetPower    = base.AppendPower(&base.PowerAction{Action: "ilmPolicyGet", Text: "ES生命周期策略查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ilmPolicyPutPower    = base.AppendPower(&base.PowerAction{Action: "ilmPolicyPut", Text: "ES生命周期策略保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ilmPolicyDeletePower = base.AppendPower(&base.PowerAction{Action: "ilmPolicyDelete", Text: "ES生命周期策略删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ilmExplainPower      = base.AppendPower(&base.PowerAction{Action: "ilmExplain", Text: "ES索引生命周期状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	snapshotRepositoryListPower   = base.AppendPower(&base.PowerAction{Action: "snapshotRepositoryList", Text: "ES快照仓库列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	snapshotRepositoryPutPower    = base.AppendPower(&base.PowerAction{Action: "snapshotRepositoryPut", Text: "ES快照仓库保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	snapshotRepositoryDeletePower = base.AppendPower(&base.PowerAction{Action: "snapshotRepositoryDelete", Text: "ES快照仓库删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	snapshotListPower             = base.AppendPower(&base.PowerAction{Action: "snapshotList", Text: "ES快照列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	snapshotCreatePower           = base.AppendPower(&base.PowerAction{Action: "snapshotCreate", Text: "ES快照创建", ShouldLogin: true, StandAlone: true, Parent: Power})
+	snapshotRestorePower          = base.AppendPower(&base.PowerAction{Action: "snapshotRestore", Text: "ES快照恢复", ShouldLogin: true, StandAlone: true, Parent: Power})
+	snapshotDeletePower           = base.AppendPower(&base.PowerAction{Action: "snapshotDelete", Text: "ES快照删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	diagnosePower               = base.AppendPower(&base.PowerAction{Action: "diagnose", Text: "ES诊断", ShouldLogin: true, StandAlone: true, Parent: Power})
+	diagnoseHistoryPower        = base.AppendPower(&base.PowerAction{Action: "diagnoseHistory", Text: "ES诊断历史", ShouldLogin: true, StandAlone: true, Parent: Power})
+	diagnoseGetPower            = base.AppendPower(&base.PowerAction{Action: "diagnoseGet", Text: "ES诊断报告查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	diagnoseScheduleGetPower    = base.AppendPower(&base.PowerAction{Action: "diagnoseScheduleGet", Text: "ES定时诊断查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	diagnoseScheduleSavePower   = base.AppendPower(&base.PowerAction{Action: "diagnoseScheduleSave", Text: "ES定时诊断保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	diagnoseScheduleDeletePower = base.AppendPower(&base.PowerAction{Action: "diagnoseScheduleDelete", Text: "ES定时诊断删除", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
 func (this_ *api) GetApis() (apis []*base.ApiWorker) {
@@ -72,6 +105,37 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: taskCleanPower, Do: this_.taskClean})
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
 
+	apis = append(apis, &base.ApiWorker{Power: componentTemplateListPower, Do: this_.componentTemplateList})
+	apis = append(apis, &base.ApiWorker{Power: componentTemplateGetPower, Do: this_.componentTemplateGet})
+	apis = append(apis, &base.ApiWorker{Power: componentTemplatePutPower, Do: this_.componentTemplatePut})
+	apis = append(apis, &base.ApiWorker{Power: componentTemplateDeletePower, Do: this_.componentTemplateDelete})
+	apis = append(apis, &base.ApiWorker{Power: indexTemplateListPower, Do: this_.indexTemplateList})
+	apis = append(apis, &base.ApiWorker{Power: indexTemplateGetPower, Do: this_.indexTemplateGet})
+	apis = append(apis, &base.ApiWorker{Power: indexTemplatePutPower, Do: this_.indexTemplatePut})
+	apis = append(apis, &base.ApiWorker{Power: indexTemplateDeletePower, Do: this_.indexTemplateDelete})
+	apis = append(apis, &base.ApiWorker{Power: indexTemplateSimulatePower, Do: this_.indexTemplateSimulate})
+
+	apis = append(apis, &base.ApiWorker{Power: ilmPolicyListPower, Do: this_.ilmPolicyList})
+	apis = append(apis, &base.ApiWorker{Power: ilmPolicyGetPower, Do: this_.ilmPolicyGet})
+	apis = append(apis, &base.ApiWorker{Power: ilmPolicyPutPower, Do: this_.ilmPolicyPut})
+	apis = append(apis, &base.ApiWorker{Power: ilmPolicyDeletePower, Do: this_.ilmPolicyDelete})
+	apis = append(apis, &base.ApiWorker{Power: ilmExplainPower, Do: this_.ilmExplain})
+
+	apis = append(apis, &base.ApiWorker{Power: snapshotRepositoryListPower, Do: this_.snapshotRepositoryList})
+	apis = append(apis, &base.ApiWorker{Power: snapshotRepositoryPutPower, Do: this_.snapshotRepositoryPut})
+	apis = append(apis, &base.ApiWorker{Power: snapshotRepositoryDeletePower, Do: this_.snapshotRepositoryDelete})
+	apis = append(apis, &base.ApiWorker{Power: snapshotListPower, Do: this_.snapshotList})
+	apis = append(apis, &base.ApiWorker{Power: snapshotCreatePower, Do: this_.snapshotCreate})
+	apis = append(apis, &base.ApiWorker{Power: snapshotRestorePower, Do: this_.snapshotRestore})
+	apis = append(apis, &base.ApiWorker{Power: snapshotDeletePower, Do: this_.snapshotDelete})
+
+	apis = append(apis, &base.ApiWorker{Power: diagnosePower, Do: this_.diagnose, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: diagnoseHistoryPower, Do: this_.diagnoseHistory, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: diagnoseGetPower, Do: this_.diagnoseGet})
+	apis = append(apis, &base.ApiWorker{Power: diagnoseScheduleGetPower, Do: this_.diagnoseScheduleGet})
+	apis = append(apis, &base.ApiWorker{Power: diagnoseScheduleSavePower, Do: this_.diagnoseScheduleSave})
+	apis = append(apis, &base.ApiWorker{Power: diagnoseScheduleDeletePower, Do: this_.diagnoseScheduleDelete})
+
 	return
 }
 
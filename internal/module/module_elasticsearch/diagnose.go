@@ -0,0 +1,884 @@
+package module_elasticsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/elasticsearch"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_id"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"time"
+)
+
+var (
+	// 诊断 报告 保存 天数
+	diagnoseSaveDays = 7
+	// 定时 诊断 最小 间隔 秒
+	diagnoseMinIntervalSecond = 30
+	// 热点 线程 文本 最大 长度
+	hotThreadsMaxSize = 64 * 1024
+)
+
+// ClusterHealth _cluster/health 返回，保持 ES 原 字段 名
+type ClusterHealth struct {
+	ClusterName                 string  `json:"cluster_name"`
+	Status                      string  `json:"status"`
+	TimedOut                    bool    `json:"timed_out"`
+	NumberOfNodes               int64   `json:"number_of_nodes"`
+	NumberOfDataNodes           int64   `json:"number_of_data_nodes"`
+	ActivePrimaryShards         int64   `json:"active_primary_shards"`
+	ActiveShards                int64   `json:"active_shards"`
+	RelocatingShards            int64   `json:"relocating_shards"`
+	InitializingShards          int64   `json:"initializing_shards"`
+	UnassignedShards            int64   `json:"unassigned_shards"`
+	DelayedUnassignedShards     int64   `json:"delayed_unassigned_shards"`
+	NumberOfPendingTasks        int64   `json:"number_of_pending_tasks"`
+	NumberOfInFlightFetch       int64   `json:"number_of_in_flight_fetch"`
+	TaskMaxWaitingInQueueMillis int64   `json:"task_max_waiting_in_queue_millis"`
+	ActiveShardsPercentAsNumber float64 `json:"active_shards_percent_as_number"`
+}
+
+// ShardSummary 分片 状态 统计
+type ShardSummary struct {
+	Total             int            `json:"total"`
+	Started           int            `json:"started"`
+	Relocating        int            `json:"relocating"`
+	Initializing      int            `json:"initializing"`
+	Unassigned        int            `json:"unassigned"`
+	UnassignedPrimary int            `json:"unassignedPrimary"`
+	UnassignedIndex   map[string]int `json:"unassignedIndex"` // 索引 未 分配 分片 数
+}
+
+type UnassignedShard struct {
+	Index   string             `json:"index"`
+	Shard   string             `json:"shard"`
+	Primary bool               `json:"primary"`
+	Reason  string             `json:"reason"`
+	Explain *AllocationExplain `json:"explain,omitempty"`
+}
+
+// AllocationExplain _cluster/allocation/explain 的 精简 结果，节点 只 保留 拒绝 的 决策
+type AllocationExplain struct {
+	CanAllocate  string          `json:"canAllocate"`
+	Explanation  string          `json:"explanation"`
+	UnassignedAt string          `json:"unassignedAt,omitempty"`
+	Details      string          `json:"details,omitempty"`
+	Nodes        []*NodeDecision `json:"nodes,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+type NodeDecision struct {
+	NodeName string   `json:"nodeName"`
+	Decision string   `json:"decision"`
+	Deciders []string `json:"deciders"` // decider: explanation
+}
+
+// DiskWatermark 磁盘 水位，值 为 百分比、比例 或 字节 大小
+type DiskWatermark struct {
+	Low        string `json:"low"`
+	High       string `json:"high"`
+	FloodStage string `json:"floodStage"`
+}
+
+type DiagnoseNode struct {
+	Id              string   `json:"id"`
+	Name            string   `json:"name"`
+	Host            string   `json:"host"`
+	Roles           []string `json:"roles"`
+	HeapUsed        int64    `json:"heapUsed"`
+	HeapMax         int64    `json:"heapMax"`
+	HeapUsedPercent int64    `json:"heapUsedPercent"`
+	DiskTotal       int64    `json:"diskTotal"`
+	DiskAvailable   int64    `json:"diskAvailable"`
+	DiskUsedPercent float64  `json:"diskUsedPercent"`
+	DiskWatermark   string   `json:"diskWatermark,omitempty"` // 超过 的 水位 low、high、flood_stage
+	GcYoungCount    int64    `json:"gcYoungCount"`
+	GcYoungTime     int64    `json:"gcYoungTime"` // 毫秒
+	GcOldCount      int64    `json:"gcOldCount"`
+	GcOldTime       int64    `json:"gcOldTime"` // 毫秒
+	CpuPercent      int64    `json:"cpuPercent"`
+	Load1m          float64  `json:"load1m"`
+}
+
+type DiagnoseProblem struct {
+	Level   string `json:"level"` // error、warn
+	Message string `json:"message"`
+}
+
+// DiagnoseReport 诊断 报告，某 部分 查询 失败 时 记录 到 errors 不 影响 其它 部分
+type DiagnoseReport struct {
+	Time             int64              `json:"time"`
+	UseTime          int64              `json:"useTime"`
+	Health           *ClusterHealth     `json:"health"`
+	Shards           *ShardSummary      `json:"shards,omitempty"`
+	UnassignedShards []*UnassignedShard `json:"unassignedShards,omitempty"`
+	DiskWatermark    *DiskWatermark     `json:"diskWatermark,omitempty"`
+	Nodes            []*DiagnoseNode    `json:"nodes,omitempty"`
+	HotThreads       string             `json:"hotThreads,omitempty"`
+	Problems         []*DiagnoseProblem `json:"problems"`
+	Errors           map[string]string  `json:"errors,omitempty"`
+}
+
+func (this_ *DiagnoseReport) addProblem(level string, format string, args ...interface{}) {
+	this_.Problems = append(this_.Problems, &DiagnoseProblem{Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+func (this_ *DiagnoseReport) addError(part string, err error) {
+	if this_.Errors == nil {
+		this_.Errors = map[string]string{}
+	}
+	this_.Errors[part] = err.Error()
+}
+
+// DiagnoseOption 诊断 选项
+type DiagnoseOption struct {
+	MaxExplain        int  `json:"maxExplain"` // 最多 解释 的 未 分配 分片 数，默认 10
+	WithoutHotThreads bool `json:"withoutHotThreads"`
+}
+
+func doRequest(service elasticsearch.IService, method string, path string, params url.Values, body interface{}) (bs []byte, err error) {
+	options := elasticsearch.PerformRequestOptions{}
+	options.Method = method
+	options.Path = path
+	options.Params = params
+	options.Body = body
+	response, err := service.PerformRequest(options)
+	if err != nil {
+		return
+	}
+	bs = response.Body
+	return
+}
+
+func getJSON(service elasticsearch.IService, path string, params url.Values, obj interface{}) (err error) {
+	bs, err := doRequest(service, http.MethodGet, path, params, nil)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bs, obj)
+	return
+}
+
+// loadDiagnoseReport 汇总 集群 健康、分片、未 分配 原因、节点 状态 和 热点 线程
+// 集群 健康 查询 失败 直接 返回 错误，其它 部分 失败 记录 到 报告 中
+func loadDiagnoseReport(service elasticsearch.IService, option *DiagnoseOption) (report *DiagnoseReport, err error) {
+	startTime := time.Now()
+	health := &ClusterHealth{}
+	err = getJSON(service, "/_cluster/health", nil, health)
+	if err != nil {
+		return
+	}
+	report = &DiagnoseReport{
+		Time:   util.GetMilliByTime(startTime),
+		Health: health,
+	}
+	if option.MaxExplain <= 0 {
+		option.MaxExplain = 10
+	}
+
+	if e := report.loadShards(service, option.MaxExplain); e != nil {
+		report.addError("shards", e)
+	}
+	if e := report.loadNodes(service); e != nil {
+		report.addError("nodes", e)
+	}
+	if !option.WithoutHotThreads {
+		params := url.Values{}
+		params.Set("threads", "3")
+		params.Set("ignore_idle_threads", "true")
+		bs, e := doRequest(service, http.MethodGet, "/_nodes/hot_threads", params, nil)
+		if e != nil {
+			report.addError("hotThreads", e)
+		} else {
+			if len(bs) > hotThreadsMaxSize {
+				bs = bs[:hotThreadsMaxSize]
+			}
+			report.HotThreads = string(bs)
+		}
+	}
+	report.checkHealth()
+	report.UseTime = time.Since(startTime).Milliseconds()
+	return
+}
+
+type catShard struct {
+	Index            string `json:"index"`
+	Shard            string `json:"shard"`
+	Prirep           string `json:"prirep"`
+	State            string `json:"state"`
+	UnassignedReason string `json:"unassigned.reason"`
+}
+
+func (this_ *DiagnoseReport) loadShards(service elasticsearch.IService, maxExplain int) (err error) {
+	params := url.Values{}
+	params.Set("format", "json")
+	params.Set("h", "index,shard,prirep,state,unassigned.reason")
+	var shards []*catShard
+	err = getJSON(service, "/_cat/shards", params, &shards)
+	if err != nil {
+		return
+	}
+	summary := &ShardSummary{UnassignedIndex: map[string]int{}}
+	for _, one := range shards {
+		summary.Total++
+		switch one.State {
+		case "STARTED":
+			summary.Started++
+		case "RELOCATING":
+			summary.Relocating++
+		case "INITIALIZING":
+			summary.Initializing++
+		case "UNASSIGNED":
+			summary.Unassigned++
+			summary.UnassignedIndex[one.Index]++
+			shard := &UnassignedShard{
+				Index:   one.Index,
+				Shard:   one.Shard,
+				Primary: one.Prirep == "p",
+				Reason:  one.UnassignedReason,
+			}
+			if shard.Primary {
+				summary.UnassignedPrimary++
+			}
+			this_.UnassignedShards = append(this_.UnassignedShards, shard)
+		}
+	}
+	this_.Shards = summary
+
+	// 主 分片 优先 解释
+	sort.SliceStable(this_.UnassignedShards, func(i, j int) bool {
+		return this_.UnassignedShards[i].Primary && !this_.UnassignedShards[j].Primary
+	})
+	for i, shard := range this_.UnassignedShards {
+		if i >= maxExplain {
+			break
+		}
+		shard.Explain = explainAllocation(service, shard)
+	}
+	return
+}
+
+type allocationExplainResponse struct {
+	CanAllocate         string `json:"can_allocate"`
+	AllocateExplanation string `json:"allocate_explanation"`
+	UnassignedInfo      struct {
+		At      string `json:"at"`
+		Details string `json:"details"`
+	} `json:"unassigned_info"`
+	NodeAllocationDecisions []struct {
+		NodeName     string `json:"node_name"`
+		NodeDecision string `json:"node_decision"`
+		Deciders     []struct {
+			Decider     string `json:"decider"`
+			Decision    string `json:"decision"`
+			Explanation string `json:"explanation"`
+		} `json:"deciders"`
+	} `json:"node_allocation_decisions"`
+}
+
+func explainAllocation(service elasticsearch.IService, shard *UnassignedShard) (res *AllocationExplain) {
+	res = &AllocationExplain{}
+	shardNum, _ := strconv.Atoi(shard.Shard)
+	body := map[string]interface{}{
+		"index":   shard.Index,
+		"shard":   shardNum,
+		"primary": shard.Primary,
+	}
+	bs, err := doRequest(service, http.MethodGet, "/_cluster/allocation/explain", nil, body)
+	if err != nil {
+		res.Error = err.Error()
+		return
+	}
+	response := &allocationExplainResponse{}
+	if err = json.Unmarshal(bs, response); err != nil {
+		res.Error = err.Error()
+		return
+	}
+	res.CanAllocate = response.CanAllocate
+	res.Explanation = response.AllocateExplanation
+	res.UnassignedAt = response.UnassignedInfo.At
+	res.Details = response.UnassignedInfo.Details
+	for _, node := range response.NodeAllocationDecisions {
+		decision := &NodeDecision{NodeName: node.NodeName, Decision: node.NodeDecision}
+		for _, decider := range node.Deciders {
+			if decider.Decision != "NO" {
+				continue
+			}
+			decision.Deciders = append(decision.Deciders, decider.Decider+": "+decider.Explanation)
+		}
+		res.Nodes = append(res.Nodes, decision)
+	}
+	return
+}
+
+type nodesStatsResponse struct {
+	Nodes map[string]*struct {
+		Name  string   `json:"name"`
+		Host  string   `json:"host"`
+		Roles []string `json:"roles"`
+		Jvm   struct {
+			Mem struct {
+				HeapUsedInBytes int64 `json:"heap_used_in_bytes"`
+				HeapUsedPercent int64 `json:"heap_used_percent"`
+				HeapMaxInBytes  int64 `json:"heap_max_in_bytes"`
+			} `json:"mem"`
+			Gc struct {
+				Collectors map[string]struct {
+					CollectionCount        int64 `json:"collection_count"`
+					CollectionTimeInMillis int64 `json:"collection_time_in_millis"`
+				} `json:"collectors"`
+			} `json:"gc"`
+		} `json:"jvm"`
+		Fs struct {
+			Total struct {
+				TotalInBytes     int64 `json:"total_in_bytes"`
+				AvailableInBytes int64 `json:"available_in_bytes"`
+			} `json:"total"`
+		} `json:"fs"`
+		Os struct {
+			Cpu struct {
+				Percent     int64              `json:"percent"`
+				LoadAverage map[string]float64 `json:"load_average"`
+			} `json:"cpu"`
+		} `json:"os"`
+	} `json:"nodes"`
+}
+
+type clusterSettingsResponse struct {
+	Persistent map[string]interface{} `json:"persistent"`
+	Transient  map[string]interface{} `json:"transient"`
+	Defaults   map[string]interface{} `json:"defaults"`
+}
+
+// get 按 transient、persistent、defaults 顺序 取 生效 的 配置
+func (this_ *clusterSettingsResponse) get(key string) string {
+	for _, settings := range []map[string]interface{}{this_.Transient, this_.Persistent, this_.Defaults} {
+		if v, ok := settings[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func (this_ *DiagnoseReport) loadNodes(service elasticsearch.IService) (err error) {
+	stats := &nodesStatsResponse{}
+	err = getJSON(service, "/_nodes/stats/jvm,fs,os", nil, stats)
+	if err != nil {
+		return
+	}
+
+	params := url.Values{}
+	params.Set("include_defaults", "true")
+	params.Set("flat_settings", "true")
+	settings := &clusterSettingsResponse{}
+	if e := getJSON(service, "/_cluster/settings", params, settings); e != nil {
+		this_.addError("settings", e)
+	} else {
+		this_.DiskWatermark = &DiskWatermark{
+			Low:        settings.get("cluster.routing.allocation.disk.watermark.low"),
+			High:       settings.get("cluster.routing.allocation.disk.watermark.high"),
+			FloodStage: settings.get("cluster.routing.allocation.disk.watermark.flood_stage"),
+		}
+	}
+
+	for id, one := range stats.Nodes {
+		node := &DiagnoseNode{
+			Id:              id,
+			Name:            one.Name,
+			Host:            one.Host,
+			Roles:           one.Roles,
+			HeapUsed:        one.Jvm.Mem.HeapUsedInBytes,
+			HeapMax:         one.Jvm.Mem.HeapMaxInBytes,
+			HeapUsedPercent: one.Jvm.Mem.HeapUsedPercent,
+			DiskTotal:       one.Fs.Total.TotalInBytes,
+			DiskAvailable:   one.Fs.Total.AvailableInBytes,
+			GcYoungCount:    one.Jvm.Gc.Collectors["young"].CollectionCount,
+			GcYoungTime:     one.Jvm.Gc.Collectors["young"].CollectionTimeInMillis,
+			GcOldCount:      one.Jvm.Gc.Collectors["old"].CollectionCount,
+			GcOldTime:       one.Jvm.Gc.Collectors["old"].CollectionTimeInMillis,
+			CpuPercent:      one.Os.Cpu.Percent,
+			Load1m:          one.Os.Cpu.LoadAverage["1m"],
+		}
+		if node.DiskTotal > 0 {
+			node.DiskUsedPercent = float64(node.DiskTotal-node.DiskAvailable) * 100 / float64(node.DiskTotal)
+		}
+		if this_.DiskWatermark != nil && node.DiskTotal > 0 {
+			if watermarkExceeded(this_.DiskWatermark.FloodStage, node.DiskUsedPercent, node.DiskAvailable) {
+				node.DiskWatermark = "flood_stage"
+			} else if watermarkExceeded(this_.DiskWatermark.High, node.DiskUsedPercent, node.DiskAvailable) {
+				node.DiskWatermark = "high"
+			} else if watermarkExceeded(this_.DiskWatermark.Low, node.DiskUsedPercent, node.DiskAvailable) {
+				node.DiskWatermark = "low"
+			}
+		}
+		this_.Nodes = append(this_.Nodes, node)
+	}
+	sort.Slice(this_.Nodes, func(i, j int) bool {
+		return this_.Nodes[i].Name < this_.Nodes[j].Name
+	})
+	return
+}
+
+// watermarkExceeded 判断 磁盘 是否 超过 水位
+// 水位 为 百分比 或 比例 时 比较 已用 百分比，为 字节 大小 时 比较 剩余 空间
+func watermarkExceeded(watermark string, usedPercent float64, available int64) bool {
+	watermark = strings.ToLower(strings.TrimSpace(watermark))
+	if watermark == "" {
+		return false
+	}
+	if strings.HasSuffix(watermark, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(watermark, "%"), 64)
+		return err == nil && usedPercent >= percent
+	}
+	if ratio, err := strconv.ParseFloat(watermark, 64); err == nil {
+		return usedPercent >= ratio*100
+	}
+	size, ok := parseByteSize(watermark)
+	return ok && available <= size
+}
+
+var byteSizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"pb", 1 << 50},
+	{"tb", 1 << 40},
+	{"gb", 1 << 30},
+	{"mb", 1 << 20},
+	{"kb", 1 << 10},
+	{"b", 1},
+}
+
+func parseByteSize(value string) (size int64, ok bool) {
+	for _, unit := range byteSizeUnits {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), 64)
+		if err != nil {
+			return
+		}
+		return int64(n * float64(unit.size)), true
+	}
+	return
+}
+
+// checkHealth 根据 报告 内容 列出 问题
+func (this_ *DiagnoseReport) checkHealth() {
+	switch this_.Health.Status {
+	case "red":
+		this_.addProblem("error", "集群 状态 为 red，存在 未 分配 的 主 分片")
+	case "yellow":
+		this_.addProblem("warn", "集群 状态 为 yellow，存在 未 分配 的 副本 分片")
+	}
+	if this_.Shards != nil && this_.Shards.Unassigned > 0 {
+		level := "warn"
+		if this_.Shards.UnassignedPrimary > 0 {
+			level = "error"
+		}
+		this_.addProblem(level, "未 分配 分片 %d 个，其中 主 分片 %d 个，涉及 索引 %d 个", this_.Shards.Unassigned, this_.Shards.UnassignedPrimary, len(this_.Shards.UnassignedIndex))
+	}
+	if this_.Health.NumberOfPendingTasks > 0 {
+		this_.addProblem("warn", "集群 待 处理 任务 %d 个，最长 等待 %d 毫秒", this_.Health.NumberOfPendingTasks, this_.Health.TaskMaxWaitingInQueueMillis)
+	}
+	for _, node := range this_.Nodes {
+		if node.HeapUsedPercent >= 95 {
+			this_.addProblem("error", "节点 [%s] 堆 内存 使用 %d%%", node.Name, node.HeapUsedPercent)
+		} else if node.HeapUsedPercent >= 85 {
+			this_.addProblem("warn", "节点 [%s] 堆 内存 使用 %d%%", node.Name, node.HeapUsedPercent)
+		}
+		switch node.DiskWatermark {
+		case "flood_stage":
+			this_.addProblem("error", "节点 [%s] 磁盘 使用 %.1f%% 超过 flood_stage 水位，索引 已 被 设置 为 只读", node.Name, node.DiskUsedPercent)
+		case "high":
+			this_.addProblem("error", "节点 [%s] 磁盘 使用 %.1f%% 超过 high 水位，分片 将 被 迁出", node.Name, node.DiskUsedPercent)
+		case "low":
+			this_.addProblem("warn", "节点 [%s] 磁盘 使用 %.1f%% 超过 low 水位，不再 分配 新 分片", node.Name, node.DiskUsedPercent)
+		}
+	}
+	for part, e := range this_.Errors {
+		this_.addProblem("warn", "%s 查询 失败：%s", part, e)
+	}
+	if this_.Problems == nil {
+		this_.Problems = []*DiagnoseProblem{}
+	}
+}
+
+// NewDiagnoseService 创建 诊断 服务
+func NewDiagnoseService(toolboxService *module_toolbox.ToolboxService) (res *DiagnoseService) {
+	res = &DiagnoseService{
+		ServerContext:  toolboxService.ServerContext,
+		toolboxService: toolboxService,
+		idService:      module_id.NewIDService(toolboxService.ServerContext),
+		diagnosing:     map[int64]bool{},
+		lastTime:       map[int64]time.Time{},
+	}
+	return
+}
+
+// DiagnoseService 按 定时 配置 诊断 工具 的 集群 并 保存 报告
+type DiagnoseService struct {
+	*context.ServerContext
+	toolboxService *module_toolbox.ToolboxService
+	idService      *module_id.IDService
+	diagnosing     map[int64]bool
+	lastTime       map[int64]time.Time
+	diagnosingLock sync.Mutex
+}
+
+func (this_ *DiagnoseService) ServerReady() (err error) {
+	// 每 10 秒 检查 一次 是否 到达 诊断 间隔
+	_, err = this_.CronHandler.AddFunc("*/10 * * * * ?", this_.scheduleTask)
+	if err != nil {
+		return
+	}
+	// 每天 3 点 30 清理
+	_, err = this_.CronHandler.AddFunc("0 30 3 * * ?", this_.cleanTask)
+	return
+}
+
+func (this_ *DiagnoseService) cleanTask() {
+	deleteBeforeTime := time.Now().AddDate(0, 0, -diagnoseSaveDays)
+	sql := "DELETE FROM " + TableElasticsearchDiagnose + " WHERE createTime<? "
+	deleteCount, err := this_.DatabaseWorker.Exec(sql, []interface{}{deleteBeforeTime})
+	if err != nil {
+		this_.Logger.Error("elasticsearch diagnose clean task error", zap.Error(err))
+		return
+	}
+	this_.Logger.Info("elasticsearch diagnose clean task end", zap.Any("deleteBeforeTime", deleteBeforeTime), zap.Any("deleteCount", deleteCount))
+}
+
+func (this_ *DiagnoseService) scheduleTask() {
+	schedules, err := this_.QuerySchedule(0)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, schedule := range schedules {
+		interval := time.Duration(schedule.IntervalSecond) * time.Second
+		this_.diagnosingLock.Lock()
+		// 上一次 诊断 未 结束 或 未 到 间隔 则 跳过
+		if this_.diagnosing[schedule.ToolboxId] || now.Sub(this_.lastTime[schedule.ToolboxId]) < interval {
+			this_.diagnosingLock.Unlock()
+			continue
+		}
+		this_.diagnosing[schedule.ToolboxId] = true
+		this_.lastTime[schedule.ToolboxId] = now
+		this_.diagnosingLock.Unlock()
+		go func(toolboxId int64) {
+			defer func() {
+				if e := recover(); e != nil {
+					this_.Logger.Error("elasticsearch diagnose error", zap.Any("toolboxId", toolboxId), zap.Any("error", e))
+				}
+				this_.diagnosingLock.Lock()
+				delete(this_.diagnosing, toolboxId)
+				this_.diagnosingLock.Unlock()
+			}()
+			if e := this_.diagnose(toolboxId); e != nil {
+				this_.Logger.Error("elasticsearch diagnose error", zap.Any("toolboxId", toolboxId), zap.Error(e))
+			}
+		}(schedule.ToolboxId)
+	}
+}
+
+func (this_ *DiagnoseService) diagnose(toolboxId int64) (err error) {
+	config := &elasticsearch.Config{}
+	_, err = this_.toolboxService.BindConfigById(toolboxId, config)
+	if err != nil {
+		return
+	}
+	if config.Url == "" {
+		err = errors.New("toolbox [" + util.GetStringValue(toolboxId) + "] elasticsearch url is empty")
+		return
+	}
+	service, err := getService(config)
+	if err != nil {
+		return
+	}
+	report, err := loadDiagnoseReport(service, &DiagnoseOption{})
+	if err != nil {
+		// 连接 失败 也 记录，便于 查看 集群 何时 不可用
+		report = &DiagnoseReport{
+			Time:     util.GetMilliByTime(time.Now()),
+			Health:   &ClusterHealth{Status: "unavailable"},
+			Problems: []*DiagnoseProblem{{Level: "error", Message: "集群 不可用：" + err.Error()}},
+		}
+		err = nil
+	}
+	_, err = this_.Insert(toolboxId, report)
+	return
+}
+
+// Insert 保存 诊断 报告
+func (this_ *DiagnoseService) Insert(toolboxId int64, report *DiagnoseReport) (diagnoseId int64, err error) {
+	diagnoseId, err = this_.idService.GetNextID(module_id.IDTypeElasticsearchDiagnose)
+	if err != nil {
+		return
+	}
+	bs, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	sql := `INSERT INTO ` + TableElasticsearchDiagnose + `(diagnoseId, toolboxId, status, unassignedShards, problemCount, report, createTime) VALUES (?, ?, ?, ?, ?, ?, ?) `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{diagnoseId, toolboxId, report.Health.Status, report.Health.UnassignedShards, len(report.Problems), string(bs), time.UnixMilli(report.Time)})
+	return
+}
+
+// QueryHistory 查询 诊断 历史，按 时间 倒序
+func (this_ *DiagnoseService) QueryHistory(toolboxId int64, startTime time.Time, endTime time.Time, size int, withReport bool) (list []*DiagnoseModel, err error) {
+	columns := "diagnoseId, toolboxId, status, unassignedShards, problemCount, createTime"
+	if withReport {
+		columns += ", report"
+	}
+	sql := `SELECT ` + columns + ` FROM ` + TableElasticsearchDiagnose + ` WHERE toolboxId=? `
+	values := []interface{}{toolboxId}
+	if !startTime.IsZero() {
+		sql += " AND createTime>=? "
+		values = append(values, startTime)
+	}
+	if !endTime.IsZero() {
+		sql += " AND createTime<=? "
+		values = append(values, endTime)
+	}
+	sql += " ORDER BY createTime DESC "
+	if size > 0 {
+		sql += " LIMIT " + util.GetStringValue(size)
+	}
+	err = this_.DatabaseWorker.Query(sql, values, &list)
+	return
+}
+
+// Get 查询 某次 诊断 报告
+func (this_ *DiagnoseService) Get(toolboxId int64, diagnoseId int64) (res *DiagnoseModel, err error) {
+	var list []*DiagnoseModel
+	sql := `SELECT * FROM ` + TableElasticsearchDiagnose + ` WHERE toolboxId=? AND diagnoseId=? `
+	err = this_.DatabaseWorker.Query(sql, []interface{}{toolboxId, diagnoseId}, &list)
+	if err != nil {
+		return
+	}
+	if len(list) > 0 {
+		res = list[0]
+	}
+	return
+}
+
+// QuerySchedule 查询 定时 配置，toolboxId 为 0 时 查询 所有
+func (this_ *DiagnoseService) QuerySchedule(toolboxId int64) (list []*DiagnoseScheduleModel, err error) {
+	sql := `SELECT * FROM ` + TableElasticsearchDiagnoseSchedule + ` WHERE 1=1 `
+	var values []interface{}
+	if toolboxId != 0 {
+		sql += " AND toolboxId=? "
+		values = append(values, toolboxId)
+	}
+	err = this_.DatabaseWorker.Query(sql, values, &list)
+	return
+}
+
+// SaveSchedule 新增 或 修改 定时 配置，同一 工具 只 保留 一个
+func (this_ *DiagnoseService) SaveSchedule(schedule *DiagnoseScheduleModel) (err error) {
+	if schedule.IntervalSecond < diagnoseMinIntervalSecond {
+		schedule.IntervalSecond = diagnoseMinIntervalSecond
+	}
+	find, err := this_.QuerySchedule(schedule.ToolboxId)
+	if err != nil {
+		return
+	}
+	if len(find) > 0 {
+		schedule.ScheduleId = find[0].ScheduleId
+		sql := `UPDATE ` + TableElasticsearchDiagnoseSchedule + ` SET intervalSecond=?,userId=?,updateTime=? WHERE scheduleId=? `
+		_, err = this_.DatabaseWorker.Exec(sql, []interface{}{schedule.IntervalSecond, schedule.UserId, time.Now(), schedule.ScheduleId})
+		return
+	}
+	schedule.ScheduleId, err = this_.idService.GetNextID(module_id.IDTypeElasticsearchDiagnoseSchedule)
+	if err != nil {
+		return
+	}
+	schedule.CreateTime = time.Now()
+	sql := `INSERT INTO ` + TableElasticsearchDiagnoseSchedule + `(scheduleId, toolboxId, intervalSecond, userId, createTime) VALUES (?, ?, ?, ?, ?) `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{schedule.ScheduleId, schedule.ToolboxId, schedule.IntervalSecond, schedule.UserId, schedule.CreateTime})
+	return
+}
+
+func (this_ *DiagnoseService) DeleteSchedule(toolboxId int64) (err error) {
+	sql := `DELETE FROM ` + TableElasticsearchDiagnoseSchedule + ` WHERE toolboxId=? `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{toolboxId})
+	return
+}
+
+type DiagnoseRequest struct {
+	ToolboxId int64 `json:"toolboxId"`
+	DiagnoseOption
+	Save bool `json:"save"` // 保存 到 历史
+
+	DiagnoseId int64 `json:"diagnoseId"`
+	StartTime  int64 `json:"startTime"` // 毫秒
+	EndTime    int64 `json:"endTime"`   // 毫秒
+	Size       int   `json:"size"`
+
+	IntervalSecond int `json:"intervalSecond"`
+}
+
+// diagnose 立即 诊断，页面 定时 刷新 时 调用
+func (this_ *api) diagnose(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	report, err := loadDiagnoseReport(service, &request.DiagnoseOption)
+	if err != nil {
+		return
+	}
+	data := map[string]interface{}{
+		"report": report,
+	}
+	if request.Save {
+		// 测试 工具 的 配置 来自 请求，不能 保存 到 请求 中 的 toolboxId
+		var toolbox *module_toolbox.ToolboxModel
+		if toolbox, err = this_.toolboxService.GetRequestToolbox(requestBean, c); err != nil {
+			return
+		}
+		if data["diagnoseId"], err = this_.diagnoseService.Insert(toolbox.ToolboxId, report); err != nil {
+			return
+		}
+	}
+	res = data
+	return
+}
+
+// diagnoseHistory 诊断 历史，默认 不 返回 报告 内容，按 时间 正序 便于 画图
+func (this_ *api) diagnoseHistory(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	// 使用 校验 过 权限 的 工具，不 信任 请求 中 的 toolboxId
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	var startTime, endTime time.Time
+	if request.StartTime > 0 {
+		startTime = time.UnixMilli(request.StartTime)
+	}
+	if request.EndTime > 0 {
+		endTime = time.UnixMilli(request.EndTime)
+	}
+	if request.Size <= 0 {
+		request.Size = 1440
+	}
+	list, err := this_.diagnoseService.QueryHistory(toolbox.ToolboxId, startTime, endTime, request.Size, false)
+	if err != nil {
+		return
+	}
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	res = list
+	return
+}
+
+func (this_ *api) diagnoseGet(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	find, err := this_.diagnoseService.Get(toolbox.ToolboxId, request.DiagnoseId)
+	if err != nil || find == nil {
+		return
+	}
+	report := &DiagnoseReport{}
+	if err = json.Unmarshal([]byte(find.Report), report); err != nil {
+		return
+	}
+	res = map[string]interface{}{
+		"diagnoseId": find.DiagnoseId,
+		"report":     report,
+	}
+	return
+}
+
+func (this_ *api) diagnoseScheduleGet(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	list, err := this_.diagnoseService.QuerySchedule(toolbox.ToolboxId)
+	if err != nil {
+		return
+	}
+	if len(list) > 0 {
+		res = list[0]
+	}
+	return
+}
+
+// diagnoseScheduleSave 开启 定时 诊断，关闭 页面 后 仍 继续 诊断 并 保存 报告
+func (this_ *api) diagnoseScheduleSave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	schedule := &DiagnoseScheduleModel{
+		ToolboxId:      toolbox.ToolboxId,
+		IntervalSecond: request.IntervalSecond,
+	}
+	if requestBean.JWT != nil {
+		schedule.UserId = requestBean.JWT.UserId
+	}
+	err = this_.diagnoseService.SaveSchedule(schedule)
+	if err != nil {
+		return
+	}
+	res = schedule
+	return
+}
+
+func (this_ *api) diagnoseScheduleDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &DiagnoseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	err = this_.diagnoseService.DeleteSchedule(toolbox.ToolboxId)
+	return
+}
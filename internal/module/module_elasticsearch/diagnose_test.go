@@ -0,0 +1,101 @@
+package module_elasticsearch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for _, one := range []struct {
+		value  string
+		expect int64
+		ok     bool
+	}{
+		{"100b", 100, true},
+		{"10kb", 10 << 10, true},
+		{"1.5gb", 3 << 29, true},
+		{"2 tb", 2 << 40, true},
+		{"1pb", 1 << 50, true},
+		{"100", 0, false},
+		{"xgb", 0, false},
+		{"", 0, false},
+	} {
+		res, ok := parseByteSize(one.value)
+		if res != one.expect || ok != one.ok {
+			t.Errorf("value %s expect %d %v, got %d %v", one.value, one.expect, one.ok, res, ok)
+		}
+	}
+}
+
+func TestWatermarkExceeded(t *testing.T) {
+	for _, one := range []struct {
+		watermark   string
+		usedPercent float64
+		available   int64
+		expect      bool
+	}{
+		{"", 99, 0, false},
+		{"85%", 85, 0, true},
+		{"85%", 84.9, 0, false},
+		{" 90% ", 95, 0, true},
+		{"0.9", 90, 0, true},
+		{"0.9", 89, 0, false},
+		// 字节 大小 比较 剩余 空间
+		{"10GB", 50, 10 << 30, true},
+		{"10gb", 99, 11 << 30, false},
+		{"abc", 99, 0, false},
+	} {
+		if res := watermarkExceeded(one.watermark, one.usedPercent, one.available); res != one.expect {
+			t.Errorf("watermark %s used %v available %d expect %v, got %v", one.watermark, one.usedPercent, one.available, one.expect, res)
+		}
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	for _, one := range []struct {
+		name   string
+		report *DiagnoseReport
+		expect []*DiagnoseProblem
+	}{
+		{"green", &DiagnoseReport{Health: &ClusterHealth{Status: "green"}}, []*DiagnoseProblem{}},
+		{"red", &DiagnoseReport{
+			Health: &ClusterHealth{Status: "red"},
+			Shards: &ShardSummary{Unassigned: 3, UnassignedPrimary: 1, UnassignedIndex: map[string]int{"a": 3}},
+		}, []*DiagnoseProblem{
+			{"error", "集群 状态 为 red，存在 未 分配 的 主 分片"},
+			{"error", "未 分配 分片 3 个，其中 主 分片 1 个，涉及 索引 1 个"},
+		}},
+		{"yellow", &DiagnoseReport{
+			Health: &ClusterHealth{Status: "yellow", NumberOfPendingTasks: 2, TaskMaxWaitingInQueueMillis: 30},
+			Shards: &ShardSummary{Unassigned: 2, UnassignedIndex: map[string]int{"a": 1, "b": 1}},
+		}, []*DiagnoseProblem{
+			{"warn", "集群 状态 为 yellow，存在 未 分配 的 副本 分片"},
+			{"warn", "未 分配 分片 2 个，其中 主 分片 0 个，涉及 索引 2 个"},
+			{"warn", "集群 待 处理 任务 2 个，最长 等待 30 毫秒"},
+		}},
+		{"nodes", &DiagnoseReport{
+			Health: &ClusterHealth{Status: "green"},
+			Nodes: []*DiagnoseNode{
+				{Name: "n1", HeapUsedPercent: 96, DiskUsedPercent: 96, DiskWatermark: "flood_stage"},
+				{Name: "n2", HeapUsedPercent: 85, DiskUsedPercent: 91, DiskWatermark: "high"},
+				{Name: "n3", HeapUsedPercent: 50, DiskUsedPercent: 86, DiskWatermark: "low"},
+			},
+			Errors: map[string]string{"nodes": "timeout"},
+		}, []*DiagnoseProblem{
+			{"error", "节点 [n1] 堆 内存 使用 96%"},
+			{"error", "节点 [n1] 磁盘 使用 96.0% 超过 flood_stage 水位，索引 已 被 设置 为 只读"},
+			{"warn", "节点 [n2] 堆 内存 使用 85%"},
+			{"error", "节点 [n2] 磁盘 使用 91.0% 超过 high 水位，分片 将 被 迁出"},
+			{"warn", "节点 [n3] 磁盘 使用 86.0% 超过 low 水位，不再 分配 新 分片"},
+			{"warn", "nodes 查询 失败：timeout"},
+		}},
+	} {
+		one.report.checkHealth()
+		if !reflect.DeepEqual(one.report.Problems, one.expect) {
+			t.Errorf("%s expect %v, got %v", one.name, one.expect, one.report.Problems)
+			for _, p := range one.report.Problems {
+				t.Logf("%s %s", p.Level, p.Message)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package module_elasticsearch
+
+import (
+	"teamide/internal/install"
+)
+
+func GetInstallStages() []*install.StageModel {
+
+	return []*install.StageModel{
+
+		// 创建 诊断 报告 表
+		{
+			Version: "1.0",
+			Module:  ModuleElasticsearchDiagnose,
+			Stage:   `创建表[` + TableElasticsearchDiagnose + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableElasticsearchDiagnose + ` (
+	diagnoseId bigint(20) NOT NULL COMMENT '诊断ID',
+	toolboxId bigint(20) NOT NULL COMMENT '工具ID',
+	status varchar(20) DEFAULT NULL COMMENT '集群状态',
+	unassignedShards bigint(20) NOT NULL DEFAULT 0 COMMENT '未分配分片数',
+	problemCount int(10) NOT NULL DEFAULT 0 COMMENT '问题数',
+	report mediumtext DEFAULT NULL COMMENT '诊断报告',
+	createTime datetime NOT NULL COMMENT '诊断时间',
+	PRIMARY KEY (diagnoseId),
+	KEY index_toolboxId (toolboxId),
+	KEY index_createTime (createTime)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableElasticsearchDiagnoseComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableElasticsearchDiagnose + ` (
+	diagnoseId bigint(20) NOT NULL,
+	toolboxId bigint(20) NOT NULL,
+	status varchar(20) DEFAULT NULL,
+	unassignedShards bigint(20) NOT NULL DEFAULT 0,
+	problemCount int(10) NOT NULL DEFAULT 0,
+	report text DEFAULT NULL,
+	createTime datetime NOT NULL,
+	PRIMARY KEY (diagnoseId)
+);
+`,
+					`CREATE INDEX ` + TableElasticsearchDiagnose + `_index_toolboxId on ` + TableElasticsearchDiagnose + ` (toolboxId);`,
+					`CREATE INDEX ` + TableElasticsearchDiagnose + `_index_createTime on ` + TableElasticsearchDiagnose + ` (createTime);`,
+				},
+			},
+		},
+
+		// 创建 诊断 定时 表
+		{
+			Version: "1.0",
+			Module:  ModuleElasticsearchDiagnose,
+			Stage:   `创建表[` + TableElasticsearchDiagnoseSchedule + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableElasticsearchDiagnoseSchedule + ` (
+	scheduleId bigint(20) NOT NULL COMMENT '定时ID',
+	toolboxId bigint(20) NOT NULL COMMENT '工具ID',
+	intervalSecond int(10) NOT NULL DEFAULT 60 COMMENT '诊断间隔秒',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	updateTime datetime DEFAULT NULL COMMENT '修改时间',
+	PRIMARY KEY (scheduleId),
+	KEY index_toolboxId (toolboxId)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableElasticsearchDiagnoseScheduleComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableElasticsearchDiagnoseSchedule + ` (
+	scheduleId bigint(20) NOT NULL,
+	toolboxId bigint(20) NOT NULL,
+	intervalSecond int(10) NOT NULL DEFAULT 60,
+	userId bigint(20) DEFAULT NULL,
+	createTime datetime NOT NULL,
+	updateTime datetime DEFAULT NULL,
+	PRIMARY KEY (scheduleId)
+);
+`,
+					`CREATE INDEX ` + TableElasticsearchDiagnoseSchedule + `_index_toolboxId on ` + TableElasticsearchDiagnoseSchedule + ` (toolboxId);`,
+				},
+			},
+		},
+	}
+}
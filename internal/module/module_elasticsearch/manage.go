@@ -0,0 +1,261 @@
+package module_elasticsearch
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/olivere/elastic/v7"
+	"github.com/team-ide/go-tool/elasticsearch"
+	"github.com/team-ide/go-tool/util"
+	"net/http"
+	"net/url"
+	"teamide/pkg/base"
+)
+
+// ManageRequest 模板、生命周期 策略、快照 管理 请求
+type ManageRequest struct {
+	Name      string `json:"name"`      // 模板 名称 或 策略 名称
+	IndexName string `json:"indexName"` // 模拟 模板 或 explain 的 索引
+	// 快照 仓库 和 快照 名称
+	Repository string `json:"repository"`
+	Snapshot   string `json:"snapshot"`
+	// 请求 体，JSON 对象 或 JSON 字符串
+	Body interface{} `json:"body"`
+	// 额外 的 查询 参数，如 wait_for_completion、master_timeout
+	Params map[string]string `json:"params"`
+}
+
+// perform 通过 HTTP 请求 执行 管理 操作，返回 解析 后 的 JSON
+func (this_ *api) perform(requestBean *base.RequestBean, c *gin.Context, build func(request *ManageRequest) (method string, path string, err error)) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config)
+	if err != nil {
+		return
+	}
+
+	request := &ManageRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	method, path, err := build(request)
+	if err != nil {
+		return
+	}
+	options := elasticsearch.PerformRequestOptions{}
+	options.Method = method
+	options.Path = path
+	if len(request.Params) > 0 {
+		options.Params = url.Values{}
+		for k, v := range request.Params {
+			options.Params.Set(k, v)
+		}
+	}
+	options.Body = manageBody(method, request.Body)
+	response, err := service.PerformRequest(options)
+	if err != nil {
+		// 列表 查询 时 不存在 返回 空
+		if method == http.MethodGet && elastic.IsNotFound(err) {
+			err = nil
+		}
+		return
+	}
+	if len(response.Body) == 0 {
+		return
+	}
+	err = util.JSONDecodeUseNumber(response.Body, &res)
+	if err != nil {
+		res = string(response.Body)
+		err = nil
+	}
+	return
+}
+
+// manageBody 只有 PUT POST 携带 请求 体，空 字符串 视为 无 请求 体
+func manageBody(method string, body interface{}) interface{} {
+	if method != http.MethodPut && method != http.MethodPost {
+		return nil
+	}
+	if s, ok := body.(string); ok && s == "" {
+		return nil
+	}
+	return body
+}
+
+func requireName(name string, label string) (err error) {
+	if name == "" {
+		err = errors.New(label + " 不能为空")
+	}
+	return
+}
+
+func (this_ *api) componentTemplateList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		return http.MethodGet, "/_component_template", nil
+	})
+}
+
+func (this_ *api) componentTemplateGet(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Name, "模板 名称")
+		return http.MethodGet, "/_component_template/" + url.PathEscape(request.Name), err
+	})
+}
+
+func (this_ *api) componentTemplatePut(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Name, "模板 名称")
+		return http.MethodPut, "/_component_template/" + url.PathEscape(request.Name), err
+	})
+}
+
+func (this_ *api) componentTemplateDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Name, "模板 名称")
+		return http.MethodDelete, "/_component_template/" + url.PathEscape(request.Name), err
+	})
+}
+
+func (this_ *api) indexTemplateList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		return http.MethodGet, "/_index_template", nil
+	})
+}
+
+func (this_ *api) indexTemplateGet(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Name, "模板 名称")
+		return http.MethodGet, "/_index_template/" + url.PathEscape(request.Name), err
+	})
+}
+
+func (this_ *api) indexTemplatePut(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Name, "模板 名称")
+		return http.MethodPut, "/_index_template/" + url.PathEscape(request.Name), err
+	})
+}
+
+func (this_ *api) indexTemplateDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Name, "模板 名称")
+		return http.MethodDelete, "/_index_template/" + url.PathEscape(request.Name), err
+	})
+}
+
+// indexTemplateSimulate 模拟 模板 效果
+// 指定 indexName 时 返回 该 索引 创建 时 生效 的 配置，否则 模拟 name 对应 的 模板 或 body 中 的 模板
+func (this_ *api) indexTemplateSimulate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		return http.MethodPost, simulatePath(request), nil
+	})
+}
+
+func simulatePath(request *ManageRequest) (path string) {
+	if request.IndexName != "" {
+		return "/_index_template/_simulate_index/" + url.PathEscape(request.IndexName)
+	}
+	path = "/_index_template/_simulate"
+	if request.Name != "" {
+		path += "/" + url.PathEscape(request.Name)
+	}
+	return
+}
+
+func (this_ *api) ilmPolicyList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		return http.MethodGet, "/_ilm/policy", nil
+	})
+}
+
+func (this_ *api) ilmPolicyGet(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Name, "策略 名称")
+		return http.MethodGet, "/_ilm/policy/" + url.PathEscape(request.Name), err
+	})
+}
+
+func (this_ *api) ilmPolicyPut(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Name, "策略 名称")
+		return http.MethodPut, "/_ilm/policy/" + url.PathEscape(request.Name), err
+	})
+}
+
+func (this_ *api) ilmPolicyDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Name, "策略 名称")
+		return http.MethodDelete, "/_ilm/policy/" + url.PathEscape(request.Name), err
+	})
+}
+
+// ilmExplain 查看 索引 当前 所处 的 生命周期 阶段 和 错误，indexName 支持 通配符
+func (this_ *api) ilmExplain(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.IndexName, "索引 名称")
+		return http.MethodGet, "/" + url.PathEscape(request.IndexName) + "/_ilm/explain", err
+	})
+}
+
+func (this_ *api) snapshotRepositoryList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		return http.MethodGet, "/_snapshot", nil
+	})
+}
+
+func (this_ *api) snapshotRepositoryPut(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Repository, "仓库 名称")
+		return http.MethodPut, "/_snapshot/" + url.PathEscape(request.Repository), err
+	})
+}
+
+func (this_ *api) snapshotRepositoryDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Repository, "仓库 名称")
+		return http.MethodDelete, "/_snapshot/" + url.PathEscape(request.Repository), err
+	})
+}
+
+func (this_ *api) snapshotList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		err = requireName(request.Repository, "仓库 名称")
+		return http.MethodGet, "/_snapshot/" + url.PathEscape(request.Repository) + "/_all", err
+	})
+}
+
+// snapshotCreate 创建 快照，默认 不 等待 完成，进度 通过 snapshotList 查看
+func (this_ *api) snapshotCreate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		path, err = snapshotPath(request)
+		return http.MethodPut, path, err
+	})
+}
+
+// snapshotRestore 恢复 快照，body 中 可 指定 indices、rename_pattern、rename_replacement 等
+func (this_ *api) snapshotRestore(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		path, err = snapshotPath(request)
+		return http.MethodPost, path + "/_restore", err
+	})
+}
+
+func (this_ *api) snapshotDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.perform(requestBean, c, func(request *ManageRequest) (method string, path string, err error) {
+		path, err = snapshotPath(request)
+		return http.MethodDelete, path, err
+	})
+}
+
+// snapshotPath 快照 操作 需要 仓库 和 快照 名称
+func snapshotPath(request *ManageRequest) (path string, err error) {
+	if err = requireName(request.Repository, "仓库 名称"); err != nil {
+		return
+	}
+	if err = requireName(request.Snapshot, "快照 名称"); err != nil {
+		return
+	}
+	path = "/_snapshot/" + url.PathEscape(request.Repository) + "/" + url.PathEscape(request.Snapshot)
+	return
+}
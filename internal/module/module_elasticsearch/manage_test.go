@@ -0,0 +1,62 @@
+package module_elasticsearch
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestManageBody(t *testing.T) {
+	body := map[string]interface{}{"policy": map[string]interface{}{}}
+	for _, one := range []struct {
+		method string
+		body   interface{}
+		expect interface{}
+	}{
+		{http.MethodGet, body, nil},
+		{http.MethodDelete, `{"a":1}`, nil},
+		{http.MethodPut, body, body},
+		{http.MethodPost, `{"a":1}`, `{"a":1}`},
+		{http.MethodPut, "", nil},
+		{http.MethodPost, nil, nil},
+	} {
+		if res := manageBody(one.method, one.body); !reflect.DeepEqual(res, one.expect) {
+			t.Errorf("%s body %v expect %v, got %v", one.method, one.body, one.expect, res)
+		}
+	}
+}
+
+func TestSimulatePath(t *testing.T) {
+	for _, one := range []struct {
+		request *ManageRequest
+		expect  string
+	}{
+		{&ManageRequest{}, "/_index_template/_simulate"},
+		{&ManageRequest{Name: "logs"}, "/_index_template/_simulate/logs"},
+		// 指定 索引 时 优先 模拟 索引
+		{&ManageRequest{Name: "logs", IndexName: "logs-2024"}, "/_index_template/_simulate_index/logs-2024"},
+		{&ManageRequest{Name: "a/b"}, "/_index_template/_simulate/a%2Fb"},
+	} {
+		if res := simulatePath(one.request); res != one.expect {
+			t.Errorf("request %+v expect %s, got %s", one.request, one.expect, res)
+		}
+	}
+}
+
+func TestSnapshotPath(t *testing.T) {
+	for _, one := range []struct {
+		request *ManageRequest
+		expect  string
+		isErr   bool
+	}{
+		{&ManageRequest{Repository: "backup", Snapshot: "s1"}, "/_snapshot/backup/s1", false},
+		{&ManageRequest{Repository: "back up", Snapshot: "s/1"}, "/_snapshot/back%20up/s%2F1", false},
+		{&ManageRequest{Snapshot: "s1"}, "", true},
+		{&ManageRequest{Repository: "backup"}, "", true},
+	} {
+		res, err := snapshotPath(one.request)
+		if (err != nil) != one.isErr || res != one.expect {
+			t.Errorf("request %+v expect %s %v, got %s %v", one.request, one.expect, one.isErr, res, err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package module_elasticsearch
+
+import "time"
+
+const (
+	// ModuleElasticsearchDiagnose ES 诊断 模块
+	ModuleElasticsearchDiagnose = "elasticsearch_diagnose"
+	// TableElasticsearchDiagnose ES 诊断 报告 表
+	TableElasticsearchDiagnose        = "TM_ELASTICSEARCH_DIAGNOSE"
+	TableElasticsearchDiagnoseComment = "ES诊断报告"
+
+	// TableElasticsearchDiagnoseSchedule ES 诊断 定时 表
+	TableElasticsearchDiagnoseSchedule        = "TM_ELASTICSEARCH_DIAGNOSE_SCHEDULE"
+	TableElasticsearchDiagnoseScheduleComment = "ES诊断定时"
+)
+
+// DiagnoseModel 某次 诊断 的 报告，report 为 DiagnoseReport JSON
+type DiagnoseModel struct {
+	DiagnoseId       int64     `json:"diagnoseId,omitempty"`
+	ToolboxId        int64     `json:"toolboxId,omitempty"`
+	Status           string    `json:"status,omitempty"`
+	UnassignedShards int64     `json:"unassignedShards"`
+	ProblemCount     int       `json:"problemCount"`
+	Report           string    `json:"report,omitempty"`
+	CreateTime       time.Time `json:"createTime,omitempty"`
+}
+
+// DiagnoseScheduleModel 诊断 定时 配置，配置 后 该 工具 按 间隔 自动 诊断 并 保存 报告
+type DiagnoseScheduleModel struct {
+	ScheduleId     int64     `json:"scheduleId,omitempty"`
+	ToolboxId      int64     `json:"toolboxId,omitempty"`
+	IntervalSecond int       `json:"intervalSecond"` // 诊断 间隔 秒，最小 30
+	UserId         int64     `json:"userId,omitempty"`
+	CreateTime     time.Time `json:"createTime,omitempty"`
+	UpdateTime     time.Time `json:"updateTime,omitempty"`
+}
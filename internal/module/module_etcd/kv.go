@@ -0,0 +1,476 @@
+package module_etcd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Key 树 每次 最多 返回 的 子节点 数
+	defaultTreeLimit = 1000
+	// 扫描 Key 时 每 批 查询 的 数量
+	scanBatchSize = 500
+	// 删除 预览 最多 返回 的 Key 数
+	deletePreviewSize = 100
+	// 租约 列表 最多 查询 的 租约 数
+	maxLeaseSize = 1000
+)
+
+// toText 值 不是 UTF-8 文本 时 使用 Base64 编码
+func toText(bs []byte) (text string, isBase64 bool) {
+	if utf8.Valid(bs) {
+		return string(bs), false
+	}
+	return base64.StdEncoding.EncodeToString(bs), true
+}
+
+func (this_ *BaseRequest) getValue() (value string, err error) {
+	if !this_.Base64 {
+		value = this_.Value
+		return
+	}
+	bs, err := base64.StdEncoding.DecodeString(this_.Value)
+	if err != nil {
+		err = errors.New("value base64 decode error:" + err.Error())
+		return
+	}
+	value = string(bs)
+	return
+}
+
+// 租约 ID 与 etcdctl 一致 使用 十六进制 显示
+func formatLeaseId(id clientv3.LeaseID) string {
+	return fmt.Sprintf("%016x", int64(id))
+}
+
+func parseLeaseId(text string) (id clientv3.LeaseID, err error) {
+	text = strings.TrimPrefix(strings.TrimSpace(text), "0x")
+	if text == "" {
+		err = errors.New("lease id can not be empty")
+		return
+	}
+	v, err := strconv.ParseInt(text, 16, 64)
+	if err != nil {
+		err = errors.New("lease id [" + text + "] invalid, please use hex")
+		return
+	}
+	id = clientv3.LeaseID(v)
+	return
+}
+
+type KeyValue struct {
+	Key            string     `json:"key"`
+	Value          string     `json:"value"`
+	Base64         bool       `json:"base64,omitempty"`
+	CreateRevision int64      `json:"createRevision"`
+	ModRevision    int64      `json:"modRevision"`
+	Version        int64      `json:"version"`
+	Lease          string     `json:"lease,omitempty"`
+	LeaseInfo      *LeaseInfo `json:"leaseInfo,omitempty"`
+}
+
+func toKeyValue(kv *mvccpb.KeyValue) (res *KeyValue) {
+	if kv == nil {
+		return
+	}
+	res = &KeyValue{
+		Key:            string(kv.Key),
+		CreateRevision: kv.CreateRevision,
+		ModRevision:    kv.ModRevision,
+		Version:        kv.Version,
+	}
+	res.Value, res.Base64 = toText(kv.Value)
+	if kv.Lease != 0 {
+		res.Lease = formatLeaseId(clientv3.LeaseID(kv.Lease))
+	}
+	return
+}
+
+type LeaseInfo struct {
+	Id         string   `json:"id"`
+	Ttl        int64    `json:"ttl"` // 剩余 秒数，-1 表示 已 过期
+	GrantedTtl int64    `json:"grantedTtl"`
+	Keys       []string `json:"keys,omitempty"`
+}
+
+func (this_ *Service) leaseInfo(id clientv3.LeaseID, withKeys bool) (res *LeaseInfo, err error) {
+	ctx, cancel := this_.timeoutContext()
+	defer cancel()
+	var opts []clientv3.LeaseOption
+	if withKeys {
+		opts = append(opts, clientv3.WithAttachedKeys())
+	}
+	resp, err := this_.TimeToLive(ctx, id, opts...)
+	if err != nil {
+		err = errors.New("lease [" + formatLeaseId(id) + "] time to live error:" + err.Error())
+		return
+	}
+	res = &LeaseInfo{
+		Id:         formatLeaseId(id),
+		Ttl:        resp.TTL,
+		GrantedTtl: resp.GrantedTTL,
+	}
+	for _, key := range resp.Keys {
+		res.Keys = append(res.Keys, string(key))
+	}
+	return
+}
+
+func (this_ *Service) leases() (res interface{}, err error) {
+	ctx, cancel := this_.timeoutContext()
+	defer cancel()
+	resp, err := this_.Leases(ctx)
+	if err != nil {
+		return
+	}
+	var list []*LeaseInfo
+	for _, one := range resp.Leases {
+		if len(list) >= maxLeaseSize {
+			break
+		}
+		var info *LeaseInfo
+		info, err = this_.leaseInfo(one.ID, false)
+		if err != nil {
+			return
+		}
+		list = append(list, info)
+	}
+	data := map[string]interface{}{}
+	data["total"] = len(resp.Leases)
+	data["leases"] = list
+	res = data
+	return
+}
+
+type MemberInfo struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs"`
+	IsLearner  bool     `json:"isLearner"`
+}
+
+type EndpointStatus struct {
+	Endpoint    string   `json:"endpoint"`
+	Version     string   `json:"version,omitempty"`
+	DbSize      int64    `json:"dbSize,omitempty"`
+	DbSizeInUse int64    `json:"dbSizeInUse,omitempty"`
+	Leader      string   `json:"leader,omitempty"`
+	MemberId    string   `json:"memberId,omitempty"`
+	IsLearner   bool     `json:"isLearner,omitempty"`
+	RaftIndex   uint64   `json:"raftIndex,omitempty"`
+	RaftTerm    uint64   `json:"raftTerm,omitempty"`
+	Revision    int64    `json:"revision,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+func (this_ *Service) info() (res interface{}, err error) {
+	ctx, cancel := this_.timeoutContext()
+	defer cancel()
+	memberResp, err := this_.MemberList(ctx)
+	if err != nil {
+		return
+	}
+	var members []*MemberInfo
+	for _, one := range memberResp.Members {
+		members = append(members, &MemberInfo{
+			Id:         fmt.Sprintf("%x", one.ID),
+			Name:       one.Name,
+			PeerURLs:   one.PeerURLs,
+			ClientURLs: one.ClientURLs,
+			IsLearner:  one.IsLearner,
+		})
+	}
+	// 单个 节点 状态 查询 失败 不 影响 其它 节点
+	var statusList []*EndpointStatus
+	for _, endpoint := range this_.Endpoints() {
+		status := &EndpointStatus{Endpoint: endpoint}
+		resp, e := this_.Status(ctx, endpoint)
+		if e != nil {
+			status.Error = e.Error()
+		} else {
+			status.Version = resp.Version
+			status.DbSize = resp.DbSize
+			status.DbSizeInUse = resp.DbSizeInUse
+			status.Leader = fmt.Sprintf("%x", resp.Leader)
+			status.IsLearner = resp.IsLearner
+			status.RaftIndex = resp.RaftIndex
+			status.RaftTerm = resp.RaftTerm
+			status.Errors = resp.Errors
+			if resp.Header != nil {
+				status.MemberId = fmt.Sprintf("%x", resp.Header.MemberId)
+				status.Revision = resp.Header.Revision
+			}
+		}
+		statusList = append(statusList, status)
+	}
+	data := map[string]interface{}{}
+	data["members"] = members
+	data["status"] = statusList
+	if memberResp.Header != nil {
+		data["clusterId"] = fmt.Sprintf("%x", memberResp.Header.ClusterId)
+	}
+	res = data
+	return
+}
+
+// TreeNode Key 树 的 节点，目录 的 Name 以 分隔符 结尾
+type TreeNode struct {
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	HasValue    bool   `json:"hasValue"`
+	HasChildren bool   `json:"hasChildren"`
+}
+
+func keyStart(key string) string {
+	// etcd 不 支持 空 Key，从 最小 的 Key 开始
+	if key == "" {
+		return "\x00"
+	}
+	return key
+}
+
+// tree 按 分隔符 查询 Key 的 下一级，目录 下 的 Key 不 逐个 读取，遇到 后 直接 跳到 目录 之后
+func (this_ *Service) tree(request *BaseRequest) (res interface{}, err error) {
+	separator := request.Separator
+	if separator == "" {
+		separator = "/"
+	}
+	limit := request.Limit
+	if limit <= 0 {
+		limit = defaultTreeLimit
+	}
+	parent := request.Key
+	if parent != "" && !strings.HasSuffix(parent, separator) {
+		parent += separator
+	}
+	end := clientv3.GetPrefixRangeEnd(parent)
+	start := parent
+	if request.After != "" {
+		if strings.HasSuffix(request.After, separator) {
+			start = clientv3.GetPrefixRangeEnd(parent + request.After)
+		} else {
+			start = parent + request.After + "\x00"
+		}
+	}
+
+	ctx, cancel := this_.timeoutContext()
+	defer cancel()
+
+	var children []*TreeNode
+	nodeCache := map[string]*TreeNode{}
+	var revision int64
+	var hasValue bool
+	var more bool
+scan:
+	for {
+		opts := []clientv3.OpOption{clientv3.WithRange(end), clientv3.WithKeysOnly(), clientv3.WithLimit(scanBatchSize)}
+		// 后续 查询 使用 第一次 查询 的 版本，保证 结果 一致
+		if revision > 0 {
+			opts = append(opts, clientv3.WithRev(revision))
+		}
+		resp, e := this_.Get(ctx, keyStart(start), opts...)
+		if e != nil {
+			err = e
+			return
+		}
+		if revision == 0 {
+			revision = resp.Header.Revision
+		}
+		if len(resp.Kvs) == 0 {
+			break
+		}
+		for _, kv := range resp.Kvs {
+			rest := string(kv.Key)[len(parent):]
+			if rest == "" {
+				hasValue = true
+				continue
+			}
+			name := rest
+			isDir := false
+			if index := strings.Index(rest, separator); index >= 0 {
+				name = rest[:index+len(separator)]
+				isDir = name != rest
+			}
+			node := nodeCache[name]
+			if node == nil {
+				if int64(len(children)) >= limit {
+					more = true
+					break scan
+				}
+				node = &TreeNode{Name: name, Key: parent + name}
+				nodeCache[name] = node
+				children = append(children, node)
+			}
+			if !isDir {
+				node.HasValue = true
+				continue
+			}
+			node.HasChildren = true
+			start = clientv3.GetPrefixRangeEnd(parent + name)
+			if start == "\x00" || (end != "\x00" && start >= end) {
+				break scan
+			}
+			continue scan
+		}
+		if !resp.More {
+			break
+		}
+		start = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+	data := map[string]interface{}{}
+	data["key"] = request.Key
+	data["hasValue"] = hasValue
+	data["children"] = children
+	data["more"] = more
+	data["revision"] = revision
+	res = data
+	return
+}
+
+func (this_ *Service) get(request *BaseRequest) (res interface{}, err error) {
+	ctx, cancel := this_.timeoutContext()
+	defer cancel()
+	var opts []clientv3.OpOption
+	if request.Revision > 0 {
+		opts = append(opts, clientv3.WithRev(request.Revision))
+	}
+	resp, err := this_.Get(ctx, request.Key, opts...)
+	if err != nil {
+		err = errors.New("key [" + request.Key + "] get error:" + err.Error())
+		return
+	}
+	data := map[string]interface{}{}
+	data["revision"] = resp.Header.Revision
+	if len(resp.Kvs) > 0 {
+		kv := toKeyValue(resp.Kvs[0])
+		if resp.Kvs[0].Lease != 0 && request.Revision <= 0 {
+			kv.LeaseInfo, err = this_.leaseInfo(clientv3.LeaseID(resp.Kvs[0].Lease), false)
+			if err != nil {
+				return
+			}
+		}
+		data["kv"] = kv
+	}
+	res = data
+	return
+}
+
+func (this_ *Service) put(request *BaseRequest) (res interface{}, err error) {
+	if request.Key == "" {
+		err = errors.New("key can not be empty")
+		return
+	}
+	value, err := request.getValue()
+	if err != nil {
+		return
+	}
+	ctx, cancel := this_.timeoutContext()
+	defer cancel()
+
+	var leaseId clientv3.LeaseID
+	var granted bool
+	if request.Ttl > 0 {
+		var grantResp *clientv3.LeaseGrantResponse
+		grantResp, err = this_.Grant(ctx, request.Ttl)
+		if err != nil {
+			err = errors.New("lease grant error:" + err.Error())
+			return
+		}
+		leaseId = grantResp.ID
+		granted = true
+	} else if request.LeaseId != "" {
+		leaseId, err = parseLeaseId(request.LeaseId)
+		if err != nil {
+			return
+		}
+	}
+	defer func() {
+		// 保存 失败 时 回收 新建 的 租约
+		if err != nil && granted {
+			_, _ = this_.Revoke(ctx, leaseId)
+		}
+	}()
+	var opts []clientv3.OpOption
+	if leaseId != 0 {
+		opts = append(opts, clientv3.WithLease(leaseId))
+	}
+
+	var revision int64
+	if request.ModRevision != nil {
+		var txnResp *clientv3.TxnResponse
+		txnResp, err = this_.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(request.Key), "=", *request.ModRevision)).
+			Then(clientv3.OpPut(request.Key, value, opts...)).
+			Commit()
+		if err != nil {
+			err = errors.New("key [" + request.Key + "] put error:" + err.Error())
+			return
+		}
+		if !txnResp.Succeeded {
+			err = errors.New("key [" + request.Key + "] has been modified, mod revision is not " + strconv.FormatInt(*request.ModRevision, 10))
+			return
+		}
+		revision = txnResp.Header.Revision
+	} else {
+		var putResp *clientv3.PutResponse
+		putResp, err = this_.Put(ctx, request.Key, value, opts...)
+		if err != nil {
+			err = errors.New("key [" + request.Key + "] put error:" + err.Error())
+			return
+		}
+		revision = putResp.Header.Revision
+	}
+	data := map[string]interface{}{}
+	data["revision"] = revision
+	if leaseId != 0 {
+		data["lease"] = formatLeaseId(leaseId)
+	}
+	res = data
+	return
+}
+
+func (this_ *Service) delete(request *BaseRequest) (res interface{}, err error) {
+	if request.Key == "" {
+		err = errors.New("key can not be empty")
+		return
+	}
+	ctx, cancel := this_.timeoutContext()
+	defer cancel()
+	var opts []clientv3.OpOption
+	if request.Prefix {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+	data := map[string]interface{}{}
+	if request.DryRun {
+		var resp *clientv3.GetResponse
+		resp, err = this_.Get(ctx, request.Key, append(opts, clientv3.WithKeysOnly(), clientv3.WithLimit(deletePreviewSize))...)
+		if err != nil {
+			return
+		}
+		var keys []string
+		for _, kv := range resp.Kvs {
+			keys = append(keys, string(kv.Key))
+		}
+		data["count"] = resp.Count
+		data["keys"] = keys
+		res = data
+		return
+	}
+	resp, err := this_.Delete(ctx, request.Key, opts...)
+	if err != nil {
+		err = errors.New("key [" + request.Key + "] delete error:" + err.Error())
+		return
+	}
+	data["count"] = resp.Deleted
+	data["revision"] = resp.Header.Revision
+	res = data
+	return
+}
@@ -0,0 +1,177 @@
+package module_etcd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	goSSH "golang.org/x/crypto/ssh"
+	"google.golang.org/grpc"
+	"net"
+	"os"
+	"strings"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+type Config struct {
+	Address            string `json:"address"` // 多个 地址 使用 , 分隔
+	Username           string `json:"username,omitempty"`
+	Password           string `json:"password,omitempty"`
+	Tls                bool   `json:"tls,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	CaCert             string `json:"caCert,omitempty"`
+	ClientCert         string `json:"clientCert,omitempty"`
+	ClientKey          string `json:"clientKey,omitempty"`
+	DialTimeout        int    `json:"dialTimeout,omitempty"` // 连接 超时 毫秒
+}
+
+func (this_ *Config) getEndpoints() (res []string) {
+	for _, one := range strings.Split(strings.ReplaceAll(this_.Address, ";", ","), ",") {
+		if one = strings.TrimSpace(one); one != "" {
+			res = append(res, one)
+		}
+	}
+	return
+}
+
+func (this_ *Config) getTlsConfig() (res *tls.Config, err error) {
+	if !this_.Tls {
+		return
+	}
+	res = &tls.Config{
+		InsecureSkipVerify: this_.InsecureSkipVerify,
+	}
+	if this_.CaCert != "" {
+		var bs []byte
+		bs, err = os.ReadFile(this_.CaCert)
+		if err != nil {
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			err = errors.New("ca cert [" + this_.CaCert + "] append error")
+			return
+		}
+		res.RootCAs = pool
+	}
+	if this_.ClientCert != "" && this_.ClientKey != "" {
+		var cert tls.Certificate
+		cert, err = tls.LoadX509KeyPair(this_.ClientCert, this_.ClientKey)
+		if err != nil {
+			return
+		}
+		res.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+type Service struct {
+	*clientv3.Client
+	config    *Config
+	sshClient *goSSH.Client
+}
+
+func (this_ *Service) Close() {
+	if this_.Client != nil {
+		_ = this_.Client.Close()
+	}
+	if this_.sshClient != nil {
+		_ = this_.sshClient.Close()
+	}
+}
+
+func (this_ *Service) timeoutContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
+func newService(config *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	res = &Service{config: config}
+	endpoints := config.getEndpoints()
+	if len(endpoints) == 0 {
+		err = errors.New("etcd address is empty")
+		return
+	}
+	dialTimeout := config.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = 10 * 1000
+	}
+	clientConfig := clientv3.Config{
+		Endpoints:   endpoints,
+		Username:    config.Username,
+		Password:    config.Password,
+		DialTimeout: time.Duration(dialTimeout) * time.Millisecond,
+		// 客户端 重试 等 调试 日志 过多，只 输出 警告 以上
+		Logger:      util.Logger.WithOptions(zap.IncreaseLevel(zap.WarnLevel)),
+		DialOptions: []grpc.DialOption{grpc.WithBlock()},
+	}
+	if clientConfig.TLS, err = config.getTlsConfig(); err != nil {
+		return
+	}
+	if sshConfig != nil {
+		res.sshClient, err = ssh.NewClient(*sshConfig)
+		if err != nil {
+			return
+		}
+		sshClient := res.sshClient
+		clientConfig.DialOptions = append(clientConfig.DialOptions, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return sshClient.Dial("tcp", addr)
+		}))
+	}
+	res.Client, err = clientv3.New(clientConfig)
+	if err != nil {
+		res.Close()
+		return
+	}
+	ctx, cancel := res.timeoutContext()
+	defer cancel()
+	_, err = res.Status(ctx, endpoints[0])
+	if err != nil {
+		res.Close()
+		return
+	}
+	return
+}
+
+func getService(config *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	key := "etcd-" + config.Address
+	if config.Username != "" {
+		key += "-" + base.GetMd5String(key+config.Username)
+	}
+	if config.Password != "" {
+		key += "-" + base.GetMd5String(key+config.Password)
+	}
+	if config.Tls {
+		key += "-tls-" + base.GetMd5String(config.CaCert+config.ClientCert+config.ClientKey)
+	}
+	if sshConfig != nil {
+		key += "-ssh-" + sshConfig.Address
+		key += "-ssh-" + sshConfig.Username
+	}
+	var serviceInfo *base.ServiceInfo
+	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
+		var s *Service
+		s, err = newService(config, sshConfig)
+		if err != nil {
+			util.Logger.Error("getEtcdService error", zap.Any("key", key), zap.Error(err))
+			return
+		}
+		res = &base.ServiceInfo{
+			WaitTime:    10 * 60 * 1000,
+			LastUseTime: util.GetNowMilli(),
+			Service:     s,
+			Stop:        s.Close,
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = serviceInfo.Service.(*Service)
+	serviceInfo.SetLastUseTime()
+	return
+}
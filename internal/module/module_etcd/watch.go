@@ -0,0 +1,160 @@
+package module_etcd
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/util"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"strconv"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+)
+
+type WatchRequest struct {
+	WorkerId string `json:"workerId"`
+	Key      string `json:"key"`
+	WatchKey string `json:"watchKey"`
+	Prefix   bool   `json:"prefix"`   // 监听 所有 以 WatchKey 为 前缀 的 Key，WatchKey 为 空 时 监听 所有 Key
+	Revision int64  `json:"revision"` // 从 指定 版本 开始 监听，可 回放 历史 事件，0 为 当前
+}
+
+type WatchMessage struct {
+	Type     string    `json:"type"` // put delete compacted error
+	Key      string    `json:"key,omitempty"`
+	Kv       *KeyValue `json:"kv,omitempty"`
+	PrevKv   *KeyValue `json:"prevKv,omitempty"`
+	Revision int64     `json:"revision,omitempty"`
+	Time     int64     `json:"time"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// watcher 每个 监听 会话 使用 独立 的 连接，避免 缓存 的 服务 空闲 回收 后 监听 结束
+type watcher struct {
+	*base.WebsocketSession
+	request     *WatchRequest
+	config      *Config
+	sshConfig   *ssh.Config
+	service     *Service
+	serviceLock sync.Mutex
+}
+
+var watcherCache = base.NewWebsocketCache("etcd watch")
+
+func (this_ *watcher) write(msg *WatchMessage) {
+	msg.Time = util.GetNowMilli()
+	if err := this_.WriteJSON(msg); err != nil {
+		go this_.Stop()
+	}
+}
+
+func (this_ *watcher) watch() {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("etcd watch error", zap.Any("error", e))
+		}
+		this_.Stop()
+	}()
+	service, err := this_.connect()
+	if err != nil {
+		this_.write(&WatchMessage{Type: "error", Error: err.Error()})
+		return
+	}
+	if service == nil {
+		return
+	}
+	opts := []clientv3.OpOption{clientv3.WithPrevKV()}
+	if this_.request.Prefix {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+	if this_.request.Revision > 0 {
+		opts = append(opts, clientv3.WithRev(this_.request.Revision))
+	}
+	// 要求 有 Leader，集群 不可用 时 及时 结束 监听
+	ctx := clientv3.WithRequireLeader(this_.Context())
+	for resp := range service.Watch(ctx, this_.request.WatchKey, opts...) {
+		if resp.CompactRevision > 0 {
+			this_.write(&WatchMessage{Type: "compacted", Revision: resp.CompactRevision, Error: "revision has been compacted, watch from revision " + strconv.FormatInt(resp.CompactRevision, 10)})
+			continue
+		}
+		if err := resp.Err(); err != nil {
+			this_.write(&WatchMessage{Type: "error", Error: err.Error()})
+			continue
+		}
+		for _, event := range resp.Events {
+			msg := &WatchMessage{
+				Key:      string(event.Kv.Key),
+				Kv:       toKeyValue(event.Kv),
+				PrevKv:   toKeyValue(event.PrevKv),
+				Revision: event.Kv.ModRevision,
+			}
+			if event.Type == clientv3.EventTypeDelete {
+				msg.Type = "delete"
+				// 删除 事件 的 Kv 只有 Key 和 版本
+				msg.Kv = nil
+			} else {
+				msg.Type = "put"
+			}
+			this_.write(msg)
+		}
+	}
+}
+
+// connect 创建 会话 的 连接，连接 过程 中 已 停止 时 返回 nil
+func (this_ *watcher) connect() (service *Service, err error) {
+	s, err := newService(this_.config, this_.sshConfig)
+	if err != nil {
+		return
+	}
+	this_.serviceLock.Lock()
+	defer this_.serviceLock.Unlock()
+	select {
+	case <-this_.Done():
+		s.Close()
+		return
+	default:
+	}
+	this_.service = s
+	service = s
+	return
+}
+
+func (this_ *watcher) Start() (err error) {
+	go this_.watch()
+	return
+}
+
+// OnStop 监听 使用 会话 的 Context，会话 结束 时 已 取消，然后 关闭 连接
+func (this_ *watcher) OnStop() {
+	this_.serviceLock.Lock()
+	defer this_.serviceLock.Unlock()
+	if this_.service != nil {
+		this_.service.Close()
+	}
+}
+
+// watchKey 创建 监听 会话，返回 key 用于 建立 websocket
+func (this_ *api) watchKey(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &WatchRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.WatchKey == "" && !request.Prefix {
+		err = errors.New("watch key can not be empty")
+		return
+	}
+	one := &watcher{
+		WebsocketSession: base.NewWebsocketSession(requestBean, request.WorkerId),
+		request:          request,
+		config:           config,
+		sshConfig:        sshConfig,
+	}
+	res = watcherCache.Add(one)
+	return
+}
@@ -0,0 +1,318 @@
+package module_grpc
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/grpc"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/load"
+	"teamide/pkg/loadtask"
+	"time"
+)
+
+type api struct {
+	toolboxService *module_toolbox.ToolboxService
+	taskManager    *loadtask.Manager
+}
+
+func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+	return &api{
+		toolboxService: toolboxService,
+		taskManager:    loadtask.NewManager("grpc-tasks", toolboxService.GetFilesDir),
+	}
+}
+
+var (
+	Power                   = base.AppendPower(&base.PowerAction{Action: "grpc", Text: "gRPC", ShouldLogin: true, StandAlone: true})
+	checkPower              = base.AppendPower(&base.PowerAction{Action: "check", Text: "gRPC测试", ShouldLogin: true, StandAlone: true, Parent: Power})
+	contextPower            = base.AppendPower(&base.PowerAction{Action: "context", Text: "gRPC服务列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	getMethodArgFieldsPower = base.AppendPower(&base.PowerAction{Action: "getMethodArgFields", Text: "gRPC方法参数", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokePower             = base.AppendPower(&base.PowerAction{Action: "invoke", Text: "gRPC调用", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeReportsPower      = base.AppendPower(&base.PowerAction{Action: "invokeReports", Text: "gRPC执行报告", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeReportDeletePower = base.AppendPower(&base.PowerAction{Action: "invokeReportDelete", Text: "gRPC执行报告删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeStopPower         = base.AppendPower(&base.PowerAction{Action: "invokeStop", Text: "gRPC执行停止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeInfoPower         = base.AppendPower(&base.PowerAction{Action: "invokeInfo", Text: "gRPC执行信息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	downloadRecordsPower    = base.AppendPower(&base.PowerAction{Action: "downloadRecords", Text: "gRPC执行记录", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeMetricPower       = base.AppendPower(&base.PowerAction{Action: "invokeMetric", Text: "gRPC执行指标", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeMarkdownPower     = base.AppendPower(&base.PowerAction{Action: "invokeMarkdown", Text: "gRPC执行报告导出", ShouldLogin: true, StandAlone: true, Parent: Power})
+	closePower              = base.AppendPower(&base.PowerAction{Action: "close", Text: "gRPC关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: checkPower, Do: this_.check})
+	apis = append(apis, &base.ApiWorker{Power: contextPower, Do: this_.context})
+	apis = append(apis, &base.ApiWorker{Power: getMethodArgFieldsPower, Do: this_.getMethodArgFields})
+	apis = append(apis, &base.ApiWorker{Power: invokePower, Do: this_.invoke})
+	apis = append(apis, &base.ApiWorker{Power: invokeReportsPower, Do: this_.invokeReports})
+	apis = append(apis, &base.ApiWorker{Power: invokeReportDeletePower, Do: this_.invokeReportDelete})
+	apis = append(apis, &base.ApiWorker{Power: downloadRecordsPower, Do: this_.downloadRecords, IsGet: true})
+	apis = append(apis, &base.ApiWorker{Power: invokeStopPower, Do: this_.invokeStop})
+	apis = append(apis, &base.ApiWorker{Power: invokeInfoPower, Do: this_.invokeInfo})
+	apis = append(apis, &base.ApiWorker{Power: invokeMetricPower, Do: this_.invokeMetric})
+	apis = append(apis, &base.ApiWorker{Power: invokeMarkdownPower, Do: this_.invokeMarkdown})
+	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+
+	return
+}
+
+func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *Config, err error) {
+	config = &Config{}
+	sshConfig, err := this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	config.sshConfig = sshConfig
+	if config.ProtoFile != "" {
+		config.ProtoFile = this_.toolboxService.GetFilesFile(config.ProtoFile)
+	}
+	if config.CaCert != "" {
+		config.CaCert = this_.toolboxService.GetFilesFile(config.CaCert)
+	}
+	if config.ClientCert != "" {
+		config.ClientCert = this_.toolboxService.GetFilesFile(config.ClientCert)
+	}
+	if config.ClientKey != "" {
+		config.ClientKey = this_.toolboxService.GetFilesFile(config.ClientKey)
+	}
+	return
+}
+
+func (this_ *api) getService(requestBean *base.RequestBean, c *gin.Context) (res *Service, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	res, err = getService(config)
+	if err != nil {
+		return
+	}
+	return
+}
+
+type BaseRequest struct {
+	ServiceName string        `json:"serviceName,omitempty"`
+	MethodName  string        `json:"methodName,omitempty"`
+	Args        string        `json:"args,omitempty"` // JSON 参数，可以 使用 ${脚本}
+	Metadata    []*KeyValue   `json:"metadata,omitempty"`
+	Timeout     int           `json:"timeout,omitempty"`     // 调用 超时 毫秒，为 0 时 不 限制
+	MaxMessages int           `json:"maxMessages,omitempty"` // 服务端 流 最多 接收 消息 数，默认 1000
+	Address     string        `json:"address,omitempty"`     // 调用 时 由 配置 设置，用于 报告
+	Reload      bool          `json:"reload,omitempty"`
+	TaskKey     string        `json:"taskKey,omitempty"`
+	ToolboxId   int64         `json:"toolboxId,omitempty"`
+	IsTest      bool          `json:"isTest,omitempty"`
+	Worker      int           `json:"worker,omitempty"`
+	Duration    int           `json:"duration,omitempty"`
+	Frequency   int           `json:"frequency,omitempty"`
+	CountSecond int           `json:"countSecond,omitempty"` // 统计间隔秒 如 每秒统计 输入 1 默认 10 秒统计
+	SaveRecords bool          `json:"saveRecords,omitempty"`
+	CountTop    bool          `json:"countTop,omitempty"`
+	LoadProfile *load.Profile `json:"loadProfile,omitempty"` // 负载 模型，为空 时 固定 Worker 个 线程
+
+	RequestMd5 string `json:"requestMd5,omitempty"`
+}
+
+func (this_ *api) check(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	err = service.load(true)
+	if err != nil {
+		return
+	}
+	_, err = service.serviceNames()
+	return
+}
+
+type MethodInfo struct {
+	Name            string `json:"name"`
+	InputType       string `json:"inputType"`
+	OutputType      string `json:"outputType"`
+	ClientStreaming bool   `json:"clientStreaming,omitempty"`
+	ServerStreaming bool   `json:"serverStreaming,omitempty"`
+	Comment         string `json:"comment,omitempty"`
+}
+
+type ServiceInfo struct {
+	Name    string        `json:"name"`
+	Comment string        `json:"comment,omitempty"`
+	Methods []*MethodInfo `json:"methods"`
+	Error   string        `json:"error,omitempty"`
+}
+
+func (this_ *api) context(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	err = service.load(request.Reload)
+	if err != nil {
+		return
+	}
+	names, err := service.serviceNames()
+	if err != nil {
+		return
+	}
+	var serviceList []*ServiceInfo
+	for _, name := range names {
+		info := &ServiceInfo{Name: name}
+		serviceList = append(serviceList, info)
+
+		var sd *desc.ServiceDescriptor
+		sd, err = service.findService(name)
+		if err != nil {
+			// 单个 服务 解析 失败 不 影响 其它 服务
+			info.Error = err.Error()
+			err = nil
+			continue
+		}
+		info.Comment = comment(sd)
+		for _, md := range sd.GetMethods() {
+			info.Methods = append(info.Methods, &MethodInfo{
+				Name:            md.GetName(),
+				InputType:       md.GetInputType().GetFullyQualifiedName(),
+				OutputType:      md.GetOutputType().GetFullyQualifiedName(),
+				ClientStreaming: md.IsClientStreaming(),
+				ServerStreaming: md.IsServerStreaming(),
+				Comment:         comment(md),
+			})
+		}
+	}
+
+	data := map[string]interface{}{}
+	data["serviceList"] = serviceList
+	res = data
+	return
+}
+
+func (this_ *api) getMethod(service *Service, request *BaseRequest) (res *desc.MethodDescriptor, err error) {
+	err = service.load(false)
+	if err != nil {
+		return
+	}
+	res, err = service.findMethod(request.ServiceName, request.MethodName)
+	return
+}
+
+func (this_ *api) getMethodArgFields(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	method, err := this_.getMethod(service, request)
+	if err != nil {
+		return
+	}
+
+	bs, _ := json.MarshalIndent(getTemplate(method.GetInputType(), map[string]bool{}), "", "  ")
+
+	data := map[string]interface{}{}
+	data["inputType"] = method.GetInputType().GetFullyQualifiedName()
+	data["outputType"] = method.GetOutputType().GetFullyQualifiedName()
+	data["clientStreaming"] = method.IsClientStreaming()
+	data["serverStreaming"] = method.IsServerStreaming()
+	data["argFields"] = getFields(method.GetInputType(), map[string]bool{})
+	data["resultFields"] = getFields(method.GetOutputType(), map[string]bool{})
+	data["argDemoData"] = string(bs)
+	res = data
+	return
+}
+
+func (this_ *api) invoke(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	request.Address = config.Address
+
+	method, err := this_.getMethod(service, request)
+	if err != nil {
+		return
+	}
+	caller_, err := newCaller(method, request)
+	if err != nil {
+		return
+	}
+
+	argFormat_, err := newArgFormat()
+	if err != nil {
+		err = errors.New("newArgFormat error:" + err.Error())
+		return
+	}
+	args, err := parseArgs(request.Args)
+	if err != nil {
+		return
+	}
+
+	data := map[string]interface{}{}
+	res = data
+	data["isTest"] = request.IsTest
+	data["start"] = time.Now().UnixMilli()
+	defer func() {
+		data["end"] = time.Now().UnixMilli()
+	}()
+
+	if request.IsTest {
+		executor := &invokeExecutor{
+			argFormat:   argFormat_,
+			BaseRequest: request,
+			args:        args,
+			caller:      caller_,
+			service:     service,
+			workerConn:  make(map[int]*grpc.ClientConn),
+		}
+		t, e := this_.startTask(executor)
+		if e != nil {
+			err = e
+			return
+		}
+		data["taskKey"] = t.Key
+		return
+	}
+
+	bs, err := formatArgs(argFormat_, args, nil)
+	if err != nil {
+		err = errors.New("formatArgs error:" + err.Error())
+		return
+	}
+	message, err := caller_.newRequest(bs)
+	if err != nil {
+		return
+	}
+	result := &CallResult{}
+	result.Request = caller_.toJSON(message)
+	// 单次 调用 的 状态 错误 在 结果 中 展示，不 作为 接口 错误
+	_ = caller_.invoke(service.conn, message, result, true)
+	data["result"] = result
+	data["useTime"] = result.UseTime
+	return
+}
+
+func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return
+}
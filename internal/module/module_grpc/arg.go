@@ -0,0 +1,44 @@
+package module_grpc
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/team-ide/go-tool/task"
+	"github.com/team-ide/go-tool/util"
+	"strings"
+	"teamide/pkg/loadtask"
+)
+
+// newArgFormat 与 Thrift 一致，参数 中 的 ${脚本} 每次 调用 时 执行，参数 为 JSON，字符串 值 不 带 引号
+func newArgFormat() (res *loadtask.ArgFormat, err error) {
+	res, err = loadtask.NewArgFormat()
+	if err != nil {
+		return
+	}
+	res.ExportValue = true
+	return
+}
+
+// parseArgs 解析 JSON 参数，空 时 为 空 对象
+func parseArgs(args string) (res interface{}, err error) {
+	if strings.TrimSpace(args) == "" {
+		res = map[string]interface{}{}
+		return
+	}
+	err = util.JSONDecodeUseNumber([]byte(args), &res)
+	if err != nil {
+		err = errors.New("args json to object error:" + err.Error())
+		return
+	}
+	return
+}
+
+// formatArgs 执行 参数 中 的 脚本 后 转为 JSON
+func formatArgs(argFormat *loadtask.ArgFormat, args interface{}, param *task.ExecutorParam) (res []byte, err error) {
+	v, err := argFormat.FormatArg(args, param)
+	if err != nil {
+		return
+	}
+	res, err = json.Marshal(v)
+	return
+}
@@ -0,0 +1,320 @@
+package module_grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	goSSH "golang.org/x/crypto/ssh"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+const (
+	SourceReflection = "reflection" // 服务端 反射
+	SourceProto      = "proto"      // 上传 或 目录 下 的 proto 文件
+)
+
+type Config struct {
+	Address            string `json:"address"`
+	Source             string `json:"source,omitempty"`
+	ProtoDir           string `json:"protoDir,omitempty"`  // proto 文件 目录，同时 作为 import 目录
+	ProtoFile          string `json:"protoFile,omitempty"` // 上传 的 proto 文件
+	Tls                bool   `json:"tls,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	ServerName         string `json:"serverName,omitempty"`
+	CaCert             string `json:"caCert,omitempty"`
+	ClientCert         string `json:"clientCert,omitempty"`
+	ClientKey          string `json:"clientKey,omitempty"`
+	Timeout            int    `json:"timeout,omitempty"` // 连接 超时 毫秒
+
+	sshConfig *ssh.Config
+}
+
+func (this_ *Config) getKey() string {
+	key := "grpc-" + this_.Address
+	if this_.Source == SourceProto {
+		key += "-proto-" + base.GetMd5String(this_.ProtoDir+this_.ProtoFile)
+	}
+	if this_.Tls {
+		key += "-tls-" + base.GetMd5String(this_.ServerName+this_.CaCert+this_.ClientCert+this_.ClientKey)
+		if this_.InsecureSkipVerify {
+			key += "-insecure"
+		}
+	}
+	if this_.sshConfig != nil {
+		key += "-ssh-" + this_.sshConfig.Address
+		key += "-ssh-" + this_.sshConfig.Username
+	}
+	return key
+}
+
+func (this_ *Config) transportCredentials() (res credentials.TransportCredentials, err error) {
+	if !this_.Tls {
+		res = insecure.NewCredentials()
+		return
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: this_.InsecureSkipVerify,
+		ServerName:         this_.ServerName,
+	}
+	if this_.CaCert != "" {
+		var bs []byte
+		bs, err = os.ReadFile(this_.CaCert)
+		if err != nil {
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			err = errors.New("ca cert [" + this_.CaCert + "] append error")
+			return
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if this_.ClientCert != "" && this_.ClientKey != "" {
+		var cert tls.Certificate
+		cert, err = tls.LoadX509KeyPair(this_.ClientCert, this_.ClientKey)
+		if err != nil {
+			return
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	res = credentials.NewTLS(tlsConfig)
+	return
+}
+
+// dial 创建 连接，sshClient 不为 空 时 经 SSH隧道 连接
+func dial(config *Config, sshClient *goSSH.Client) (conn *grpc.ClientConn, err error) {
+	creds, err := config.transportCredentials()
+	if err != nil {
+		return
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 10 * 1000
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithBlock(),
+	}
+	if sshClient != nil {
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return sshClient.Dial("tcp", addr)
+		}))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+	conn, err = grpc.DialContext(ctx, config.Address, opts...)
+	if err != nil {
+		err = errors.New("dial [" + config.Address + "] error:" + err.Error())
+		return
+	}
+	return
+}
+
+// Service 连接 和 服务 描述，反射 时 服务 描述 由 服务端 获取
+type Service struct {
+	config    *Config
+	conn      *grpc.ClientConn
+	sshClient *goSSH.Client
+	reflect   *grpcreflect.Client
+	files     []*desc.FileDescriptor
+	lock      sync.Mutex
+}
+
+func (this_ *Service) Close() {
+	if this_.reflect != nil {
+		this_.reflect.Reset()
+	}
+	if this_.conn != nil {
+		_ = this_.conn.Close()
+	}
+	if this_.sshClient != nil {
+		_ = this_.sshClient.Close()
+	}
+}
+
+// newConn 压测 时 每个 线程 使用 独立 的 连接
+func (this_ *Service) newConn() (conn *grpc.ClientConn, err error) {
+	return dial(this_.config, this_.sshClient)
+}
+
+func getService(config *Config) (res *Service, err error) {
+	key := config.getKey()
+	var serviceInfo *base.ServiceInfo
+	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
+		s := &Service{config: config}
+		if config.sshConfig != nil {
+			s.sshClient, err = ssh.NewClient(*config.sshConfig)
+			if err != nil {
+				util.Logger.Error("getGrpcService ssh NewClient error", zap.Any("key", key), zap.Error(err))
+				return
+			}
+		}
+		s.conn, err = dial(config, s.sshClient)
+		if err != nil {
+			util.Logger.Error("getGrpcService error", zap.Any("key", key), zap.Error(err))
+			s.Close()
+			return
+		}
+		res = &base.ServiceInfo{
+			WaitTime:    10 * 60 * 1000,
+			LastUseTime: util.GetNowMilli(),
+			Service:     s,
+			Stop:        s.Close,
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = serviceInfo.Service.(*Service)
+	serviceInfo.SetLastUseTime()
+	return
+}
+
+// load 加载 服务 描述，reload 为 true 时 重新 获取
+func (this_ *Service) load(reload bool) (err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.config.Source == SourceProto {
+		if this_.files != nil && !reload {
+			return
+		}
+		this_.files, err = parseProtoFiles(this_.config)
+		return
+	}
+	if this_.reflect != nil && !reload {
+		return
+	}
+	if this_.reflect != nil {
+		this_.reflect.Reset()
+	}
+	this_.reflect = grpcreflect.NewClientAuto(context.Background(), this_.conn)
+	return
+}
+
+// parseProtoFiles 解析 目录 下 所有 proto 文件 和 上传 的 proto 文件
+func parseProtoFiles(config *Config) (res []*desc.FileDescriptor, err error) {
+	var importPaths []string
+	var filenames []string
+	if config.ProtoDir != "" {
+		importPaths = append(importPaths, config.ProtoDir)
+		err = filepath.WalkDir(config.ProtoDir, func(path string, d fs.DirEntry, e error) error {
+			if e != nil {
+				return e
+			}
+			if d.IsDir() || !strings.HasSuffix(d.Name(), ".proto") {
+				return nil
+			}
+			name, e := filepath.Rel(config.ProtoDir, path)
+			if e != nil {
+				return e
+			}
+			filenames = append(filenames, filepath.ToSlash(name))
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	if config.ProtoFile != "" {
+		importPaths = append(importPaths, filepath.Dir(config.ProtoFile))
+		filenames = append(filenames, filepath.Base(config.ProtoFile))
+	}
+	if len(filenames) == 0 {
+		err = errors.New("proto file not found, please config protoDir or upload protoFile")
+		return
+	}
+	parser := protoparse.Parser{
+		ImportPaths:           importPaths,
+		IncludeSourceCodeInfo: true,
+	}
+	res, err = parser.ParseFiles(filenames...)
+	if err != nil {
+		err = errors.New("parse proto files error:" + err.Error())
+		return
+	}
+	return
+}
+
+func (this_ *Service) serviceNames() (res []string, err error) {
+	if this_.config.Source == SourceProto {
+		seen := map[string]bool{}
+		for _, file := range this_.files {
+			for _, one := range file.GetServices() {
+				if !seen[one.GetFullyQualifiedName()] {
+					seen[one.GetFullyQualifiedName()] = true
+					res = append(res, one.GetFullyQualifiedName())
+				}
+			}
+		}
+	} else {
+		var names []string
+		names, err = this_.reflect.ListServices()
+		if err != nil {
+			err = errors.New("server reflection list services error:" + err.Error())
+			return
+		}
+		for _, name := range names {
+			// 反射 服务 本身 不 展示
+			if strings.HasPrefix(name, "grpc.reflection.") {
+				continue
+			}
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return
+}
+
+func (this_ *Service) findService(serviceName string) (res *desc.ServiceDescriptor, err error) {
+	if this_.config.Source == SourceProto {
+		for _, file := range this_.files {
+			for _, one := range file.GetServices() {
+				if one.GetFullyQualifiedName() == serviceName {
+					res = one
+					return
+				}
+			}
+		}
+		err = errors.New("service [" + serviceName + "] not found")
+		return
+	}
+	res, err = this_.reflect.ResolveService(serviceName)
+	if err != nil {
+		err = errors.New("server reflection resolve service [" + serviceName + "] error:" + err.Error())
+		return
+	}
+	return
+}
+
+func (this_ *Service) findMethod(serviceName string, methodName string) (res *desc.MethodDescriptor, err error) {
+	service, err := this_.findService(serviceName)
+	if err != nil {
+		return
+	}
+	res = service.FindMethodByName(methodName)
+	if res == nil {
+		err = errors.New("service [" + serviceName + "] method [" + methodName + "] not found")
+		return
+	}
+	return
+}
@@ -0,0 +1,335 @@
+package module_grpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"github.com/team-ide/go-tool/task"
+	"github.com/team-ide/go-tool/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"teamide/pkg/load"
+	"teamide/pkg/loadtask"
+	"time"
+)
+
+// 服务端 流 默认 最多 接收 的 消息 数
+const defaultMaxMessages = 1000
+
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// CallResult 一次 调用 的 结果，Code 为 gRPC 状态码
+type CallResult struct {
+	Index       int                 `json:"index,omitempty"`
+	WorkerIndex int                 `json:"workerIndex,omitempty"`
+	Request     string              `json:"request,omitempty"`
+	Response    string              `json:"response,omitempty"`
+	Messages    []string            `json:"messages,omitempty"` // 服务端 流 的 消息
+	Header      map[string][]string `json:"header,omitempty"`
+	Trailer     map[string][]string `json:"trailer,omitempty"`
+	Code        string              `json:"code"`
+	Message     string              `json:"message,omitempty"`
+	Error       string              `json:"error,omitempty"`
+	StartTime   int64               `json:"startTime"`
+	EndTime     int64               `json:"endTime"`
+	UseTime     float64             `json:"useTime"` // 毫秒
+}
+
+// caller 按 方法 描述 使用 动态 消息 调用，不 需要 生成 代码
+type caller struct {
+	method      *desc.MethodDescriptor
+	request     *BaseRequest
+	marshaler   *jsonpb.Marshaler
+	unmarshaler *jsonpb.Unmarshaler
+}
+
+func newCaller(method *desc.MethodDescriptor, request *BaseRequest) (res *caller, err error) {
+	if method.IsClientStreaming() {
+		err = errors.New("method [" + method.GetFullyQualifiedName() + "] is client streaming, only unary and server streaming are supported")
+		return
+	}
+	resolver := dynamic.AnyResolver(nil, method.GetFile())
+	res = &caller{
+		method:  method,
+		request: request,
+		marshaler: &jsonpb.Marshaler{
+			OrigName:     true,
+			EmitDefaults: true,
+			Indent:       "  ",
+			AnyResolver:  resolver,
+		},
+		unmarshaler: &jsonpb.Unmarshaler{
+			AnyResolver: resolver,
+		},
+	}
+	return
+}
+
+func (this_ *caller) newRequest(args []byte) (res *dynamic.Message, err error) {
+	res = dynamic.NewMessage(this_.method.GetInputType())
+	err = res.UnmarshalJSONPB(this_.unmarshaler, args)
+	if err != nil {
+		err = errors.New("args to [" + this_.method.GetInputType().GetFullyQualifiedName() + "] error:" + err.Error())
+		return
+	}
+	return
+}
+
+func (this_ *caller) toJSON(message proto.Message) string {
+	var bs []byte
+	var err error
+	if dm, ok := message.(*dynamic.Message); ok {
+		bs, err = dm.MarshalJSONPB(this_.marshaler)
+	} else {
+		var s string
+		s, err = this_.marshaler.MarshalToString(message)
+		bs = []byte(s)
+	}
+	if err != nil {
+		return "marshal error:" + err.Error()
+	}
+	return string(bs)
+}
+
+func (this_ *caller) context() (ctx context.Context, cancel context.CancelFunc) {
+	ctx = context.Background()
+	if len(this_.request.Metadata) > 0 {
+		md := metadata.MD{}
+		for _, one := range this_.request.Metadata {
+			if one.Key != "" {
+				md.Append(one.Key, one.Value)
+			}
+		}
+		ctx = metadata.NewOutgoingContext(ctx, md)
+	}
+	if this_.request.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(this_.request.Timeout)*time.Millisecond)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	return
+}
+
+// invoke 调用 一次，withBody 为 false 时 不 转换 响应 消息，用于 压测 不 保存 记录 时
+func (this_ *caller) invoke(conn *grpc.ClientConn, message *dynamic.Message, res *CallResult, withBody bool) (err error) {
+	ctx, cancel := this_.context()
+	defer cancel()
+
+	var header, trailer metadata.MD
+	stub := grpcdynamic.NewStub(conn)
+	start := time.Now()
+	res.StartTime = start.UnixMilli()
+	defer func() {
+		end := time.Now()
+		res.EndTime = end.UnixMilli()
+		res.UseTime = float64(end.Sub(start).Microseconds()) / 1000
+		res.Header = header
+		res.Trailer = trailer
+		st, _ := status.FromError(err)
+		res.Code = st.Code().String()
+		res.Message = st.Message()
+		if err != nil {
+			res.Error = err.Error()
+		}
+	}()
+
+	if !this_.method.IsServerStreaming() {
+		var response proto.Message
+		response, err = stub.InvokeRpc(ctx, this_.method, message, grpc.Header(&header), grpc.Trailer(&trailer))
+		if err == nil && withBody {
+			res.Response = this_.toJSON(response)
+		}
+		return
+	}
+
+	stream, err := stub.InvokeRpcServerStream(ctx, this_.method, message)
+	if err != nil {
+		return
+	}
+	maxMessages := this_.request.MaxMessages
+	if maxMessages <= 0 {
+		maxMessages = defaultMaxMessages
+	}
+	for count := 0; count < maxMessages; count++ {
+		var response proto.Message
+		response, err = stream.RecvMsg()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			break
+		}
+		if withBody {
+			res.Messages = append(res.Messages, this_.toJSON(response))
+		}
+	}
+	header, _ = stream.Header()
+	trailer = stream.Trailer()
+	return
+}
+
+type invokeExecutor struct {
+	*BaseRequest
+	argFormat      *loadtask.ArgFormat
+	args           interface{}
+	caller         *caller
+	service        *Service
+	workerConn     map[int]*grpc.ClientConn
+	workerConnLock sync.Mutex
+	taskDir        string
+	recordListLock sync.Mutex
+	recordsFile    *os.File
+	recordList     []*CallResult
+	t              *task.Task
+	latency        *load.Latency
+}
+
+type executeParam struct {
+	result  *CallResult
+	message *dynamic.Message
+}
+
+func (this_ *invokeExecutor) getConn(param *task.ExecutorParam) (conn *grpc.ClientConn, err error) {
+	this_.workerConnLock.Lock()
+	defer this_.workerConnLock.Unlock()
+
+	conn = this_.workerConn[param.WorkerIndex]
+	if conn != nil {
+		return
+	}
+	conn, err = this_.service.newConn()
+	if err != nil {
+		return
+	}
+	this_.workerConn[param.WorkerIndex] = conn
+	return
+}
+
+func (this_ *invokeExecutor) stop() {
+	this_.workerConnLock.Lock()
+	defer this_.workerConnLock.Unlock()
+
+	for _, conn := range this_.workerConn {
+		_ = conn.Close()
+	}
+}
+
+func (this_ *invokeExecutor) Before(param *task.ExecutorParam) (err error) {
+	args, err := formatArgs(this_.argFormat, this_.args, param)
+	if err != nil {
+		return
+	}
+	message, err := this_.caller.newRequest(args)
+	if err != nil {
+		return
+	}
+	result := &CallResult{Index: param.Index, WorkerIndex: param.WorkerIndex}
+	if this_.SaveRecords {
+		result.Request = string(args)
+	}
+	param.Extend = &executeParam{result: result, message: message}
+
+	_, err = this_.getConn(param)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	return
+}
+
+func (this_ *invokeExecutor) Execute(param *task.ExecutorParam) (err error) {
+	p := param.Extend.(*executeParam)
+
+	conn, err := this_.getConn(param)
+	if err != nil {
+		p.result.Error = err.Error()
+		return
+	}
+	err = this_.caller.invoke(conn, p.message, p.result, this_.SaveRecords)
+	// 调用 失败 时 不 执行 After，在 此 记录 以 保留 错误 的 调用
+	this_.addRecord(p.result)
+	return
+}
+
+func (this_ *invokeExecutor) After(param *task.ExecutorParam) (err error) {
+	return
+}
+
+func (this_ *invokeExecutor) startSaveRecords() {
+	if !this_.SaveRecords {
+		return
+	}
+	go func() {
+		for !this_.t.IsEnd {
+			time.Sleep(time.Millisecond * 500)
+			this_.saveRecords()
+		}
+		this_.saveRecords()
+		if this_.recordsFile != nil {
+			_ = this_.recordsFile.Close()
+		}
+	}()
+}
+
+func (this_ *invokeExecutor) addRecord(record *CallResult) {
+	if !this_.SaveRecords {
+		return
+	}
+
+	this_.recordListLock.Lock()
+	defer this_.recordListLock.Unlock()
+
+	this_.recordList = append(this_.recordList, record)
+}
+
+func (this_ *invokeExecutor) getAndCleanRecords() (records []*CallResult) {
+	this_.recordListLock.Lock()
+	defer this_.recordListLock.Unlock()
+
+	records = this_.recordList
+	this_.recordList = []*CallResult{}
+	return
+}
+
+func (this_ *invokeExecutor) saveRecords() {
+	records := this_.getAndCleanRecords()
+	if len(records) == 0 {
+		return
+	}
+	if this_.recordsFile == nil {
+		if ex, _ := util.PathExists(this_.taskDir); !ex {
+			_ = os.MkdirAll(this_.taskDir, fs.ModePerm)
+		}
+		var err error
+		this_.recordsFile, err = os.OpenFile(this_.taskDir+"/records.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			return
+		}
+	}
+	writer := bufio.NewWriter(this_.recordsFile)
+	for _, record := range records {
+		bs, _ := json.Marshal(record)
+		if _, err := writer.Write(bs); err != nil {
+			_ = this_.recordsFile.Close()
+			this_.recordsFile = nil
+			return
+		}
+		_ = writer.WriteByte('\n')
+	}
+	_ = writer.Flush()
+}
@@ -0,0 +1,140 @@
+package module_grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/team-ide/go-tool/metric"
+	"teamide/pkg/load"
+)
+
+func toMarkdown(requestMd5 string, taskList []map[string]interface{}) (content string) {
+
+	var groupList []*[]map[string]interface{}
+	groupCache := map[string]*[]map[string]interface{}{}
+	for _, one := range taskList {
+		if one["requestMd5"] == nil {
+			continue
+		}
+		requestMd5_ := one["requestMd5"].(string)
+		if requestMd5 != "" && requestMd5 != requestMd5_ {
+			continue
+		}
+		group := groupCache[requestMd5_]
+		if group == nil {
+			group = &[]map[string]interface{}{}
+			groupCache[requestMd5_] = group
+			groupList = append(groupList, group)
+		}
+		*group = append(*group, one)
+	}
+
+	content += fmt.Sprintf("# 测试结果  \n\n")
+	for index, group := range groupList {
+
+		content += groupToMarkdown(index, *group)
+	}
+	return
+}
+
+func groupToMarkdown(index int, group []map[string]interface{}) (content string) {
+	if len(group) == 0 {
+		return
+	}
+	var bs []byte
+	bs, _ = json.Marshal(group[0]["request"])
+	request := &BaseRequest{}
+	_ = json.Unmarshal(bs, request)
+
+	content += fmt.Sprintf("## 测试组-%d  \n\n", index+1)
+	content += fmt.Sprintf("#### 接口信息  \n\n")
+	content += fmt.Sprintf("* 服务名称：%s  \n", request.ServiceName)
+	content += fmt.Sprintf("* 方法名称：%s  \n", request.MethodName)
+
+	content += fmt.Sprintf("\n")
+
+	content += fmt.Sprintf("#### 测试信息  \n\n")
+	content += fmt.Sprintf("* 线程数：%d  \n", request.Worker)
+	if request.Frequency > 0 {
+		content += fmt.Sprintf("* 执行次数：%d  \n", request.Frequency)
+	} else {
+		content += fmt.Sprintf("* 执行时长：%d  \n", request.Duration)
+	}
+	if !request.LoadProfile.IsFixed() {
+		content += fmt.Sprintf("* 负载模型：%s  \n", loadProfileText(request.LoadProfile))
+	}
+	content += fmt.Sprintf("* 测试地址：%s  \n", request.Address)
+	content += fmt.Sprintf("* 超时时长：%d  \n", request.Timeout)
+	for _, one := range request.Metadata {
+		content += fmt.Sprintf("* Metadata：%s=%s  \n", one.Key, one.Value)
+	}
+
+	content += fmt.Sprintf("\n")
+	content += fmt.Sprintf("* 参数：  \n\n")
+	content += fmt.Sprintf("```json\n")
+	content += request.Args
+	content += fmt.Sprintf("\n")
+	content += fmt.Sprintf("```\n\n")
+
+	content += fmt.Sprintf("\n\n")
+	content += fmt.Sprintf("#### 测试记录  \n\n")
+	content += fmt.Sprintf("* 任务用时：任务的开始时间~结束时间耗时； \n")
+	content += fmt.Sprintf("* 执行用时：单个线程执行用时累计，取最大；（这里的用时是调用接口耗时，去除了额外开销，所以执行用时小于任务执行时间，两者相差越大，则表示额外开销越多） \n")
+	content += fmt.Sprintf("* 累计用时：所有执行用时累计 \n")
+	content += fmt.Sprintf("* TPS：总次数 / 任务用时 \n")
+	content += fmt.Sprintf("* 错误：返回 状态 不为 OK 的 调用 \n")
+
+	content += fmt.Sprintf("\n")
+
+	var cs []*metric.Count
+	for _, task := range group {
+		bs, _ = json.Marshal(task["metric"])
+		count := &metric.Count{}
+		_ = json.Unmarshal(bs, count)
+		cs = append(cs, count)
+	}
+	content += metric.MarkdownTable(cs, &metric.Options{
+		AddHtmlFormat: true,
+		WarnUseTime:   1000,
+	})
+	content += fmt.Sprintf("\n\n")
+	content += latencyToMarkdown(group)
+	return
+}
+
+func loadProfileText(profile *load.Profile) string {
+	switch profile.Mode {
+	case load.ModeRamp:
+		return fmt.Sprintf("线性 %d 秒 从 %d 增加 到 最大 线程数，保持 %d 秒，%d 秒 减少 到 0", profile.RampUpSecond, profile.StartWorker, profile.HoldSecond, profile.RampDownSecond)
+	case load.ModeStep:
+		return fmt.Sprintf("阶梯 每 %d 秒 增加 %d 个 线程 到 最大 线程数，保持 %d 秒", profile.StepSecond, profile.StepWorker, profile.HoldSecond)
+	case load.ModeRate:
+		return fmt.Sprintf("固定 到达 速率 每秒 %d 次，保持 %d 秒，耗时 分位 包含 排队 等待", profile.Rate, profile.HoldSecond)
+	}
+	return profile.Mode
+}
+
+// latencyToMarkdown 耗时 直方图 统计 的 分位
+func latencyToMarkdown(group []map[string]interface{}) (content string) {
+	var rows string
+	for i, task := range group {
+		if task["latency"] == nil {
+			continue
+		}
+		bs, _ := json.Marshal(task["latency"])
+		latency := &load.Percentile{}
+		_ = json.Unmarshal(bs, latency)
+		rows += fmt.Sprintf("| %d | %d | %.2f | %.2f | %.2f | %.2f | %.2f | %.2f |\n",
+			i+1, latency.Count, latency.Avg, latency.P50, latency.P90, latency.P99, latency.P999, latency.Max)
+	}
+	if rows == "" {
+		return
+	}
+	content += fmt.Sprintf("#### 耗时分位  \n\n")
+	content += fmt.Sprintf("* 由 耗时 直方图 计算，单位 毫秒，各 时段 的 分位 见 执行 指标 \n")
+	content += fmt.Sprintf("\n")
+	content += fmt.Sprintf("| 序号 | 样本数 | 平均 | P50 | P90 | P99 | P999 | 最大 |\n")
+	content += fmt.Sprintf("| --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	content += rows
+	content += fmt.Sprintf("\n\n")
+	return
+}
@@ -0,0 +1,195 @@
+package module_grpc
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/metric"
+	"github.com/team-ide/go-tool/task"
+	"teamide/pkg/base"
+	"teamide/pkg/load"
+	"teamide/pkg/loadtask"
+	"time"
+)
+
+// startTask 创建 并 启动 压测 任务，与 Thrift 使用 相同 的 负载 模型 和 报告 格式
+func (this_ *api) startTask(executor *invokeExecutor) (t *task.Task, err error) {
+	request := executor.BaseRequest
+	parentRelativePath, err := this_.getTaskParentRelativePath(request)
+	if err != nil {
+		return
+	}
+	runner, err := load.New(&task.Options{
+		Key:       fmt.Sprintf("%d", time.Now().UnixNano()),
+		Worker:    request.Worker,
+		Frequency: request.Frequency,
+		Duration:  request.Duration,
+		Executor:  executor,
+	}, request.LoadProfile, request.CountSecond)
+	if err != nil {
+		return
+	}
+	t = runner.Task()
+	executor.taskDir, err = this_.taskManager.TaskDir(parentRelativePath, t.Key)
+	if err != nil {
+		return
+	}
+	executor.latency = runner.Latency
+	if request.CountSecond > 0 {
+		t.Metric.SetCountSecond(request.CountSecond)
+	}
+	if request.CountTop {
+		t.Metric.SetCountTop(request.CountTop)
+	}
+	executor.t = t
+	executor.startSaveRecords()
+	this_.taskManager.Run(runner, func() {
+		_ = loadtask.SaveTask(executor.taskDir, request, t, executor.latency, nil)
+	}, executor.stop)
+	return
+}
+
+// getTaskParentRelativePath 任务 按 服务/方法 存放
+func (this_ *api) getTaskParentRelativePath(request *BaseRequest) (relativePath string, err error) {
+	if err = loadtask.CheckName(request.ServiceName); err != nil {
+		return
+	}
+	if err = loadtask.CheckName(request.MethodName); err != nil {
+		return
+	}
+	relativePath, err = this_.taskManager.ParentRelativePath(request.ToolboxId, request.ServiceName, request.MethodName)
+	return
+}
+
+func (this_ *api) getTaskDir(request *BaseRequest) (taskDir string, err error) {
+	parentRelativePath, err := this_.getTaskParentRelativePath(request)
+	if err != nil {
+		return
+	}
+	taskDir, err = this_.taskManager.TaskDir(parentRelativePath, request.TaskKey)
+	return
+}
+
+func (this_ *api) loadTasks(c *gin.Context) (taskList []map[string]interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	parentRelativePath, err := this_.getTaskParentRelativePath(request)
+	if err != nil {
+		return
+	}
+	taskList, err = this_.taskManager.LoadTasks(parentRelativePath)
+	return
+}
+
+func (this_ *api) invokeReports(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	res, err = this_.loadTasks(c)
+
+	return
+}
+
+func (this_ *api) invokeMarkdown(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	taskList, err := this_.loadTasks(c)
+	if err != nil {
+		return
+	}
+	res = toMarkdown(request.RequestMd5, taskList)
+	return
+}
+
+func (this_ *api) downloadRecords(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	res = base.HttpNotResponse
+
+	request := map[string]string{}
+	err = c.Bind(&request)
+	if err != nil {
+		return
+	}
+
+	fileName := "" + request["serviceName"] + "." + request["methodName"] + "-执行记录.txt"
+	err = this_.taskManager.DownloadRecords(c, fileName, request["taskRelativePath"])
+	return
+}
+
+func (this_ *api) invokeReportDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	parentRelativePath, err := this_.getTaskParentRelativePath(request)
+	if err != nil {
+		return
+	}
+	err = this_.taskManager.Delete(parentRelativePath, request.TaskKey, request.RequestMd5)
+	return
+}
+
+func (this_ *api) invokeStop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	this_.taskManager.Stop(request.TaskKey)
+	return
+}
+
+func (this_ *api) invokeInfo(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	taskDir, err := this_.getTaskDir(request)
+	if err != nil {
+		return
+	}
+
+	res, err = this_.taskManager.LoadTask(taskDir)
+
+	return
+}
+
+// invokeMetricCount 客户端 统计 和 同一 时段 的 耗时 分位
+type invokeMetricCount struct {
+	*metric.Count
+	Latency *load.Percentile `json:"latency,omitempty"`
+}
+
+func (this_ *api) invokeMetric(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	taskDir, err := this_.getTaskDir(request)
+	if err != nil {
+		return
+	}
+
+	data, latency, err := loadtask.ReadMetric(taskDir)
+	if err != nil {
+		return
+	}
+	if len(latency) == 0 {
+		res = data
+		return
+	}
+	var list []*invokeMetricCount
+	for _, count := range data {
+		list = append(list, &invokeMetricCount{Count: count, Latency: load.Match(latency, count.StartTime)})
+	}
+	res = list
+
+	return
+}
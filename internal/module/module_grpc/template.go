@@ -0,0 +1,168 @@
+package module_grpc
+
+import (
+	"fmt"
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"strings"
+)
+
+// 生成 模板 的 最大 嵌套 层级
+const templateMaxDepth = 8
+
+// Field 消息 字段 描述，用于 展示 参数 结构
+type Field struct {
+	Name     string   `json:"name"`
+	JsonName string   `json:"jsonName,omitempty"`
+	Number   int32    `json:"number"`
+	Type     string   `json:"type"`
+	TypeName string   `json:"typeName,omitempty"` // 消息、枚举 的 全称
+	Label    string   `json:"label,omitempty"`    // repeated、map、required、optional
+	OneOf    string   `json:"oneOf,omitempty"`
+	Enums    []string `json:"enums,omitempty"`
+	Comment  string   `json:"comment,omitempty"`
+	Fields   []*Field `json:"fields,omitempty"` // 消息 类型 的 字段，递归 引用 时 为 空
+}
+
+func fieldTypeName(field *desc.FieldDescriptor) string {
+	return strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+}
+
+func comment(d desc.Descriptor) string {
+	info := d.GetSourceInfo()
+	if info == nil {
+		return ""
+	}
+	return strings.TrimSpace(info.GetLeadingComments() + info.GetTrailingComments())
+}
+
+// getFields 消息 的 字段 结构，visiting 为 正在 展开 的 消息，防止 递归
+func getFields(message *desc.MessageDescriptor, visiting map[string]bool) (res []*Field) {
+	if message == nil || visiting[message.GetFullyQualifiedName()] || len(visiting) > templateMaxDepth {
+		return
+	}
+	visiting[message.GetFullyQualifiedName()] = true
+	defer delete(visiting, message.GetFullyQualifiedName())
+
+	for _, field := range message.GetFields() {
+		one := &Field{
+			Name:     field.GetName(),
+			JsonName: field.GetJSONName(),
+			Number:   field.GetNumber(),
+			Type:     fieldTypeName(field),
+			Comment:  comment(field),
+		}
+		if field.GetOneOf() != nil && !field.IsProto3Optional() {
+			one.OneOf = field.GetOneOf().GetName()
+		}
+		valueField := field
+		if field.IsMap() {
+			one.Label = "map"
+			valueField = field.GetMapValueType()
+			one.Type = "map<" + fieldTypeName(field.GetMapKeyType()) + "," + fieldTypeName(valueField) + ">"
+		} else if field.IsRepeated() {
+			one.Label = "repeated"
+		} else if field.IsRequired() {
+			one.Label = "required"
+		} else if field.IsProto3Optional() || !field.GetFile().IsProto3() {
+			one.Label = "optional"
+		}
+		if valueField.GetMessageType() != nil {
+			one.TypeName = valueField.GetMessageType().GetFullyQualifiedName()
+			one.Fields = getFields(valueField.GetMessageType(), visiting)
+		}
+		if valueField.GetEnumType() != nil {
+			one.TypeName = valueField.GetEnumType().GetFullyQualifiedName()
+			for _, v := range valueField.GetEnumType().GetValues() {
+				one.Enums = append(one.Enums, v.GetName())
+			}
+		}
+		res = append(res, one)
+	}
+	return
+}
+
+// wellKnownTemplate 常用 内置 类型 的 JSON 格式
+var wellKnownTemplate = map[string]interface{}{
+	"google.protobuf.Timestamp":   "1970-01-01T00:00:00Z",
+	"google.protobuf.Duration":    "0s",
+	"google.protobuf.Struct":      map[string]interface{}{},
+	"google.protobuf.Value":       nil,
+	"google.protobuf.ListValue":   []interface{}{},
+	"google.protobuf.Empty":       map[string]interface{}{},
+	"google.protobuf.FieldMask":   "",
+	"google.protobuf.StringValue": "",
+	"google.protobuf.BytesValue":  "",
+	"google.protobuf.BoolValue":   false,
+	"google.protobuf.Int32Value":  0,
+	"google.protobuf.UInt32Value": 0,
+	"google.protobuf.Int64Value":  "0",
+	"google.protobuf.UInt64Value": "0",
+	"google.protobuf.FloatValue":  0,
+	"google.protobuf.DoubleValue": 0,
+}
+
+// getTemplate 按 消息 结构 生成 JSON 模板，字段 使用 proto 中 的 名称
+func getTemplate(message *desc.MessageDescriptor, visiting map[string]bool) interface{} {
+	if v, ok := wellKnownTemplate[message.GetFullyQualifiedName()]; ok {
+		return v
+	}
+	if message.GetFullyQualifiedName() == "google.protobuf.Any" {
+		return map[string]interface{}{"@type": ""}
+	}
+	res := map[string]interface{}{}
+	if visiting[message.GetFullyQualifiedName()] || len(visiting) > templateMaxDepth {
+		return res
+	}
+	visiting[message.GetFullyQualifiedName()] = true
+	defer delete(visiting, message.GetFullyQualifiedName())
+
+	oneOfs := map[string]bool{}
+	for _, field := range message.GetFields() {
+		// oneof 只 生成 第一个 字段
+		if oneOf := field.GetOneOf(); oneOf != nil && !field.IsProto3Optional() {
+			if oneOfs[oneOf.GetName()] {
+				continue
+			}
+			oneOfs[oneOf.GetName()] = true
+		}
+		if field.IsMap() {
+			key := "key"
+			if field.GetMapKeyType().GetType() != descriptorpb.FieldDescriptorProto_TYPE_STRING {
+				key = fmt.Sprint(fieldTemplate(field.GetMapKeyType(), visiting))
+			}
+			res[field.GetName()] = map[string]interface{}{key: fieldTemplate(field.GetMapValueType(), visiting)}
+			continue
+		}
+		value := fieldTemplate(field, visiting)
+		if field.IsRepeated() {
+			res[field.GetName()] = []interface{}{value}
+		} else {
+			res[field.GetName()] = value
+		}
+	}
+	return res
+}
+
+func fieldTemplate(field *desc.FieldDescriptor, visiting map[string]bool) interface{} {
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return getTemplate(field.GetMessageType(), visiting)
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		values := field.GetEnumType().GetValues()
+		if len(values) > 0 {
+			return values[0].GetName()
+		}
+		return ""
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		return false
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		return ""
+	case descriptorpb.FieldDescriptorProto_TYPE_INT64, descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+		descriptorpb.FieldDescriptorProto_TYPE_SINT64, descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+		descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		// 64 位 整数 在 JSON 中 为 字符串
+		return "0"
+	}
+	return 0
+}
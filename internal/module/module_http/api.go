@@ -0,0 +1,319 @@
+package module_http
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/util"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+)
+
+// 请求 集合 和 环境 保存 为 工具 扩展，使用 工具 扩展 的 接口 查询、保存、删除
+const (
+	ExtendTypeCollection  = "httpCollection"
+	ExtendTypeEnvironment = "httpEnvironment"
+)
+
+type api struct {
+	toolboxService *module_toolbox.ToolboxService
+}
+
+func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+	return &api{
+		toolboxService: toolboxService,
+	}
+}
+
+var (
+	Power              = base.AppendPower(&base.PowerAction{Action: "http", Text: "HTTP", ShouldLogin: true, StandAlone: true})
+	sendPower          = base.AppendPower(&base.PowerAction{Action: "send", Text: "HTTP发送请求", ShouldLogin: true, StandAlone: true, Parent: Power})
+	cookieListPower    = base.AppendPower(&base.PowerAction{Action: "cookieList", Text: "HTTP查询Cookie", ShouldLogin: true, StandAlone: true, Parent: Power})
+	cookieSetPower     = base.AppendPower(&base.PowerAction{Action: "cookieSet", Text: "HTTP设置Cookie", ShouldLogin: true, StandAlone: true, Parent: Power})
+	cookieClearPower   = base.AppendPower(&base.PowerAction{Action: "cookieClear", Text: "HTTP清空Cookie", ShouldLogin: true, StandAlone: true, Parent: Power})
+	importCurlPower    = base.AppendPower(&base.PowerAction{Action: "importCurl", Text: "HTTP导入curl", ShouldLogin: true, StandAlone: true, Parent: Power})
+	importOpenApiPower = base.AppendPower(&base.PowerAction{Action: "importOpenApi", Text: "HTTP导入OpenAPI", ShouldLogin: true, StandAlone: true, Parent: Power})
+	closePower         = base.AppendPower(&base.PowerAction{Action: "close", Text: "HTTP关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: sendPower, Do: this_.send})
+	apis = append(apis, &base.ApiWorker{Power: cookieListPower, Do: this_.cookieList})
+	apis = append(apis, &base.ApiWorker{Power: cookieSetPower, Do: this_.cookieSet})
+	apis = append(apis, &base.ApiWorker{Power: cookieClearPower, Do: this_.cookieClear})
+	apis = append(apis, &base.ApiWorker{Power: importCurlPower, Do: this_.importCurl})
+	apis = append(apis, &base.ApiWorker{Power: importOpenApiPower, Do: this_.importOpenApi})
+	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+
+	return
+}
+
+type Config struct {
+	BaseUrl            string `json:"baseUrl,omitempty"`
+	Timeout            int    `json:"timeout,omitempty"` // 毫秒
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *Config, sshConfig *ssh.Config, err error) {
+	config = &Config{}
+	sshConfig, err = this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) send(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	client, err := getClient(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &SendRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Request == nil {
+		err = errors.New("request is empty")
+		return
+	}
+	request.ToolboxId, err = this_.getSendToolboxId(requestBean, c)
+	if err != nil {
+		return
+	}
+	if request.ToolboxId == 0 && (request.CollectionId > 0 || request.EnvironmentId > 0) {
+		err = errors.New("测试工具不支持集合和环境，请先保存工具")
+		return
+	}
+
+	s := &sender{
+		api:         this_,
+		config:      config,
+		client:      client,
+		sendRequest: request,
+	}
+	res, err = s.send()
+	return
+}
+
+// getSendToolboxId 发送 请求 使用 的 工具 id，集合、环境 和 Cookie 按 工具 隔离，未 保存 的 测试 工具 返回 0
+func (this_ *api) getSendToolboxId(requestBean *base.RequestBean, c *gin.Context) (toolboxId int64, err error) {
+	bindConfigRequest := &module_toolbox.BindConfigRequest{}
+	if !base.RequestJSON(bindConfigRequest, c) {
+		err = errors.New("request body bind error")
+		return
+	}
+	if bindConfigRequest.ToolboxToTest == "1" {
+		return
+	}
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+	toolboxId = toolbox.ToolboxId
+	return
+}
+
+type CookieRequest struct {
+	ToolboxId int64  `json:"toolboxId,omitempty"`
+	CookieJar string `json:"cookieJar,omitempty"`
+	Url       string `json:"url,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Value     string `json:"value,omitempty"`
+	Path      string `json:"path,omitempty"`
+}
+
+func (this_ *api) cookieList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &CookieRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+	res = getCookieJar(toolbox.ToolboxId, request.CookieJar).list()
+	return
+}
+
+func (this_ *api) cookieSet(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &CookieRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+	if request.Url == "" || request.Name == "" {
+		err = errors.New("cookie url and name can not be empty")
+		return
+	}
+	err = getCookieJar(toolbox.ToolboxId, request.CookieJar).set(request.Url, request.Name, request.Value, request.Path)
+	return
+}
+
+func (this_ *api) cookieClear(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &CookieRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+	removeCookieJar(toolbox.ToolboxId, request.CookieJar)
+	return
+}
+
+type ImportRequest struct {
+	ToolboxId    int64  `json:"toolboxId,omitempty"`
+	Content      string `json:"content,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Save         bool   `json:"save,omitempty"`         // 保存 为 集合，curl 导入 时 追加 到 CollectionId 对应 的 集合
+	CollectionId int64  `json:"collectionId,omitempty"` // 集合 扩展 id
+}
+
+func (this_ *api) importCurl(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ImportRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	req, err := ParseCurl(request.Content)
+	if err != nil {
+		return
+	}
+	if request.Name != "" {
+		req.Name = request.Name
+	}
+	if !request.Save {
+		res = req
+		return
+	}
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+	var collection *Collection
+	var extend *module_toolbox.ToolboxExtendModel
+	if request.CollectionId > 0 {
+		extend, collection, err = this_.getCollection(toolbox.ToolboxId, request.CollectionId)
+		if err != nil {
+			return
+		}
+	} else {
+		collection = &Collection{Name: req.Name}
+		extend = &module_toolbox.ToolboxExtendModel{
+			ToolboxId:  toolbox.ToolboxId,
+			ExtendType: ExtendTypeCollection,
+			UserId:     requestBean.JWT.UserId,
+		}
+	}
+	collection.Items = append(collection.Items, req)
+	err = this_.saveCollection(extend, collection)
+	if err != nil {
+		return
+	}
+	res = extend
+	return
+}
+
+func (this_ *api) importOpenApi(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ImportRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	collection, err := ParseOpenApi(request.Content)
+	if err != nil {
+		return
+	}
+	if request.Name != "" {
+		collection.Name = request.Name
+	}
+	if !request.Save {
+		res = collection
+		return
+	}
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+	extend := &module_toolbox.ToolboxExtendModel{
+		ToolboxId:  toolbox.ToolboxId,
+		ExtendType: ExtendTypeCollection,
+		UserId:     requestBean.JWT.UserId,
+	}
+	if request.CollectionId > 0 {
+		extend, _, err = this_.getCollection(toolbox.ToolboxId, request.CollectionId)
+		if err != nil {
+			return
+		}
+	}
+	err = this_.saveCollection(extend, collection)
+	if err != nil {
+		return
+	}
+	res = extend
+	return
+}
+
+// getCollection 获取 工具 下 的 集合，不是 该 工具 的 集合 视为 不存在
+func (this_ *api) getCollection(toolboxId int64, extendId int64) (extend *module_toolbox.ToolboxExtendModel, collection *Collection, err error) {
+	extend, err = this_.toolboxService.GetExtend(extendId)
+	if err != nil {
+		return
+	}
+	if extend == nil || extend.ToolboxId != toolboxId || extend.ExtendType != ExtendTypeCollection {
+		err = errors.New("http collection not found")
+		return
+	}
+	collection = &Collection{}
+	err = mapToStruct(extend.Extend, collection)
+	return
+}
+
+func (this_ *api) saveCollection(extend *module_toolbox.ToolboxExtendModel, collection *Collection) (err error) {
+	extend.Name = collection.Name
+	extend.Extend, err = structToMap(collection)
+	if err != nil {
+		return
+	}
+	err = this_.toolboxService.SaveExtend(extend)
+	return
+}
+
+// getEnvironment 获取 工具 下 的 环境，不是 该 工具 的 环境 视为 不存在
+func (this_ *api) getEnvironment(toolboxId int64, extendId int64) (extend *module_toolbox.ToolboxExtendModel, variables map[string]string, err error) {
+	extend, err = this_.toolboxService.GetExtend(extendId)
+	if err != nil {
+		return
+	}
+	if extend == nil || extend.ToolboxId != toolboxId || extend.ExtendType != ExtendTypeEnvironment {
+		err = errors.New("http environment not found")
+		return
+	}
+	variables = map[string]string{}
+	if vs, ok := extend.Extend["variables"].(map[string]interface{}); ok {
+		for k, v := range vs {
+			variables[k] = util.GetStringValue(v)
+		}
+	}
+	return
+}
+
+func (this_ *api) saveEnvironment(extend *module_toolbox.ToolboxExtendModel, variables map[string]string) (err error) {
+	if extend.Extend == nil {
+		extend.Extend = map[string]interface{}{}
+	}
+	extend.Extend["variables"] = variables
+	err = this_.toolboxService.SaveExtend(extend)
+	return
+}
+
+func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return
+}
@@ -0,0 +1,195 @@
+package module_http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	goSSH "golang.org/x/crypto/ssh"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+// getClient 按 SSH隧道 和 证书 校验 配置 缓存 Transport，复用 连接
+func getClient(config *Config, sshConfig *ssh.Config) (res *http.Transport, err error) {
+	key := "http"
+	if config.InsecureSkipVerify {
+		key += "-insecure"
+	}
+	if sshConfig != nil {
+		key += "-ssh-" + sshConfig.Address
+		key += "-ssh-" + sshConfig.Username
+	}
+	var serviceInfo *base.ServiceInfo
+	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		transport := &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+			TLSClientConfig:       &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify},
+		}
+		var sshClient *goSSH.Client
+		if sshConfig != nil {
+			sshClient, err = ssh.NewClient(*sshConfig)
+			if err != nil {
+				util.Logger.Error("getHttpClient ssh NewClient error", zap.Any("key", key), zap.Error(err))
+				return
+			}
+			// 经 SSH隧道 时 不 使用 本地 代理
+			transport.Proxy = nil
+			transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return sshClient.Dial(network, addr)
+			}
+		}
+		res = &base.ServiceInfo{
+			WaitTime:    10 * 60 * 1000,
+			LastUseTime: util.GetNowMilli(),
+			Service:     transport,
+			Stop: func() {
+				transport.CloseIdleConnections()
+				if sshClient != nil {
+					_ = sshClient.Close()
+				}
+			},
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = serviceInfo.Service.(*http.Transport)
+	serviceInfo.SetLastUseTime()
+	return
+}
+
+// cookieJar 记录 设置 过 Cookie 的 地址，用于 列出 Cookie
+type cookieJar struct {
+	jar  *cookiejar.Jar
+	urls map[string]*url.URL
+	lock sync.Mutex
+}
+
+func (this_ *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	this_.jar.SetCookies(u, cookies)
+	if len(cookies) == 0 {
+		return
+	}
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	for _, cookie := range cookies {
+		one := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: cookie.Path}
+		if cookie.Domain != "" {
+			one.Host = cookie.Domain
+			if len(one.Host) > 0 && one.Host[0] == '.' {
+				one.Host = one.Host[1:]
+			}
+		}
+		if one.Path == "" {
+			one.Path = "/"
+		}
+		this_.urls[one.String()] = one
+	}
+}
+
+func (this_ *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return this_.jar.Cookies(u)
+}
+
+type CookieInfo struct {
+	Url   string `json:"url"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (this_ *cookieJar) list() (res []*CookieInfo) {
+	this_.lock.Lock()
+	var keys []string
+	for key := range this_.urls {
+		keys = append(keys, key)
+	}
+	this_.lock.Unlock()
+	sort.Strings(keys)
+
+	seen := map[string]bool{}
+	for _, key := range keys {
+		for _, cookie := range this_.jar.Cookies(this_.urls[key]) {
+			// 父 路径 的 Cookie 在 子 路径 也 可见，按 名称 和 值 去重
+			id := cookie.Name + "=" + cookie.Value
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			res = append(res, &CookieInfo{Url: key, Name: cookie.Name, Value: cookie.Value})
+		}
+	}
+	return
+}
+
+func (this_ *cookieJar) set(rawUrl string, name string, value string, path string) (err error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return
+	}
+	if path == "" {
+		path = "/"
+	}
+	this_.SetCookies(u, []*http.Cookie{{Name: name, Value: value, Path: path}})
+	return
+}
+
+var (
+	cookieJarCache     = map[string]*cookieJar{}
+	cookieJarCacheLock = &sync.Mutex{}
+)
+
+func getCookieJarKey(toolboxId int64, name string) string {
+	if name == "" {
+		name = "default"
+	}
+	return fmt.Sprintf("%d-%s", toolboxId, name)
+}
+
+// getCookieJar Cookie 按 工具 和 名称 保存 在 内存 中，重启 后 清空
+func getCookieJar(toolboxId int64, name string) *cookieJar {
+	key := getCookieJarKey(toolboxId, name)
+	cookieJarCacheLock.Lock()
+	defer cookieJarCacheLock.Unlock()
+
+	res := cookieJarCache[key]
+	if res == nil {
+		res = newCookieJar()
+		cookieJarCache[key] = res
+	}
+	return res
+}
+
+func newCookieJar() *cookieJar {
+	jar, _ := cookiejar.New(nil)
+	return &cookieJar{
+		jar:  jar,
+		urls: map[string]*url.URL{},
+	}
+}
+
+func removeCookieJar(toolboxId int64, name string) {
+	key := getCookieJarKey(toolboxId, name)
+	cookieJarCacheLock.Lock()
+	defer cookieJarCacheLock.Unlock()
+
+	delete(cookieJarCache, key)
+}
@@ -0,0 +1,284 @@
+package module_http
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// splitCommand 按 shell 规则 拆分 命令 参数，支持 单引号、双引号、反斜杠 转义 和 续行
+func splitCommand(command string) (args []string, err error) {
+	var current strings.Builder
+	var quote rune
+	hasArg := false
+	escaped := false
+	for _, r := range command {
+		if escaped {
+			escaped = false
+			if quote == 0 && (r == '\n' || r == '\r') {
+				continue
+			}
+			if quote == '"' && !strings.ContainsRune("\"\\$`\n", r) {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			hasArg = true
+			continue
+		}
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			hasArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if quote != 0 {
+		err = errors.New("curl command has unclosed quote")
+		return
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return
+}
+
+// ParseCurl 解析 curl 命令，支持 常用 参数，其它 参数 忽略
+func ParseCurl(command string) (res *Request, err error) {
+	args, err := splitCommand(strings.TrimSpace(command))
+	if err != nil {
+		return
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		err = errors.New("content is not a curl command")
+		return
+	}
+	res = &Request{}
+	var data []string
+	var isGet, isHead bool
+	bodyType := ""
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		next := func() string {
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		// 兼容 --header=xxx 写法
+		if strings.HasPrefix(arg, "--") && strings.Contains(arg, "=") {
+			index := strings.Index(arg, "=")
+			args = append(args[:i+1], append([]string{arg[index+1:]}, args[i+1:]...)...)
+			arg = arg[:index]
+		}
+		// 兼容 -XPOST、-d'xxx' 写法
+		if len(arg) > 2 && arg[0] == '-' && strings.ContainsRune("XHduFbAe", rune(arg[1])) {
+			args = append(args[:i+1], append([]string{arg[2:]}, args[i+1:]...)...)
+			arg = arg[:2]
+		}
+		switch arg {
+		case "-X", "--request":
+			res.Method = strings.ToUpper(next())
+		case "-H", "--header":
+			header := next()
+			index := strings.Index(header, ":")
+			if index < 0 {
+				continue
+			}
+			res.Headers = append(res.Headers, &KeyValue{
+				Key:   strings.TrimSpace(header[:index]),
+				Value: strings.TrimSpace(header[index+1:]),
+			})
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii", "--json":
+			data = append(data, next())
+			if arg == "--json" {
+				bodyType = BodyTypeJson
+			}
+		case "--data-urlencode":
+			value := next()
+			index := strings.Index(value, "=")
+			if index < 0 {
+				data = append(data, url.QueryEscape(value))
+			} else {
+				data = append(data, value[:index+1]+url.QueryEscape(value[index+1:]))
+			}
+		case "-F", "--form", "--form-string":
+			value := next()
+			index := strings.Index(value, "=")
+			if index < 0 {
+				continue
+			}
+			one := &KeyValue{Key: value[:index], Value: value[index+1:]}
+			if arg != "--form-string" && strings.HasPrefix(one.Value, "@") {
+				// 文件 需要 上传 后 重新 选择，保留 原 文件名
+				one.Type = "file"
+				one.Value = strings.TrimPrefix(one.Value, "@")
+			}
+			res.Form = append(res.Form, one)
+			bodyType = BodyTypeForm
+		case "-u", "--user":
+			user := next()
+			index := strings.Index(user, ":")
+			res.Auth = &Auth{Type: AuthTypeBasic, Username: user}
+			if index >= 0 {
+				res.Auth.Username = user[:index]
+				res.Auth.Password = user[index+1:]
+			}
+		case "-b", "--cookie":
+			res.Headers = append(res.Headers, &KeyValue{Key: "Cookie", Value: next()})
+		case "-A", "--user-agent":
+			res.Headers = append(res.Headers, &KeyValue{Key: "User-Agent", Value: next()})
+		case "-e", "--referer":
+			res.Headers = append(res.Headers, &KeyValue{Key: "Referer", Value: next()})
+		case "-G", "--get":
+			isGet = true
+		case "-I", "--head":
+			isHead = true
+		case "--url":
+			res.Url = next()
+		case "-o", "--output", "-m", "--max-time", "--connect-timeout", "-x", "--proxy", "-w", "--write-out", "--retry", "-c", "--cookie-jar", "--cacert", "--cert", "--key", "-r", "--range":
+			next()
+		default:
+			if !strings.HasPrefix(arg, "-") && res.Url == "" {
+				res.Url = arg
+			}
+		}
+	}
+	if res.Url == "" {
+		err = errors.New("curl command url is empty")
+		return
+	}
+
+	u, e := url.Parse(res.Url)
+	if e == nil && u.RawQuery != "" {
+		for _, kv := range strings.Split(u.RawQuery, "&") {
+			if kv == "" {
+				continue
+			}
+			one := &KeyValue{}
+			index := strings.Index(kv, "=")
+			if index < 0 {
+				one.Key, _ = url.QueryUnescape(kv)
+			} else {
+				one.Key, _ = url.QueryUnescape(kv[:index])
+				one.Value, _ = url.QueryUnescape(kv[index+1:])
+			}
+			res.Params = append(res.Params, one)
+		}
+		u.RawQuery = ""
+		res.Url = u.String()
+	}
+	if e == nil && u.User != nil && res.Auth == nil {
+		password, _ := u.User.Password()
+		res.Auth = &Auth{Type: AuthTypeBasic, Username: u.User.Username(), Password: password}
+		u.User = nil
+		res.Url = u.String()
+	}
+
+	if len(data) > 0 {
+		body := strings.Join(data, "&")
+		if isGet {
+			for _, kv := range strings.Split(body, "&") {
+				index := strings.Index(kv, "=")
+				one := &KeyValue{Key: kv}
+				if index >= 0 {
+					one.Key, _ = url.QueryUnescape(kv[:index])
+					one.Value, _ = url.QueryUnescape(kv[index+1:])
+				}
+				res.Params = append(res.Params, one)
+			}
+		} else {
+			res.Body = body
+			if bodyType == "" {
+				bodyType = BodyTypeRaw
+				contentType := strings.ToLower(getHeader(res.Headers, "Content-Type"))
+				trimBody := strings.TrimSpace(body)
+				if strings.Contains(contentType, "json") || (contentType == "" && (strings.HasPrefix(trimBody, "{") || strings.HasPrefix(trimBody, "["))) {
+					bodyType = BodyTypeJson
+				} else if contentType == "" || strings.Contains(contentType, "x-www-form-urlencoded") {
+					// curl 的 -d 默认 以 表单 提交，保留 原始 内容
+					if contentType == "" {
+						res.Headers = append(res.Headers, &KeyValue{Key: "Content-Type", Value: "application/x-www-form-urlencoded"})
+					}
+				}
+			}
+		}
+	}
+	res.BodyType = bodyType
+	if res.BodyType == "" {
+		res.BodyType = BodyTypeNone
+	}
+
+	if res.Method == "" {
+		switch {
+		case isHead:
+			res.Method = "HEAD"
+		case isGet:
+			res.Method = "GET"
+		case res.Body != "" || len(res.Form) > 0:
+			res.Method = "POST"
+		default:
+			res.Method = "GET"
+		}
+	}
+
+	// Authorization 请求头 转为 认证 配置
+	for i, one := range res.Headers {
+		if !strings.EqualFold(one.Key, "Authorization") || res.Auth != nil {
+			continue
+		}
+		if strings.HasPrefix(one.Value, "Bearer ") {
+			res.Auth = &Auth{Type: AuthTypeBearer, Token: strings.TrimSpace(one.Value[len("Bearer "):])}
+		} else if strings.HasPrefix(one.Value, "Basic ") {
+			bs, e := base64.StdEncoding.DecodeString(strings.TrimSpace(one.Value[len("Basic "):]))
+			if e != nil {
+				continue
+			}
+			user := string(bs)
+			index := strings.Index(user, ":")
+			if index < 0 {
+				continue
+			}
+			res.Auth = &Auth{Type: AuthTypeBasic, Username: user[:index], Password: user[index+1:]}
+		} else {
+			continue
+		}
+		res.Headers = append(res.Headers[:i], res.Headers[i+1:]...)
+		break
+	}
+
+	res.Name = res.Method + " " + res.Url
+	return
+}
+
+func getHeader(headers []*KeyValue, name string) string {
+	for _, one := range headers {
+		if strings.EqualFold(one.Key, name) {
+			return one.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package module_http
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	for _, one := range []struct {
+		command string
+		expect  []string
+		error   bool
+	}{
+		{`curl  http://a`, []string{"curl", "http://a"}, false},
+		{`curl -H 'a: b c' "x y"`, []string{"curl", "-H", "a: b c", "x y"}, false},
+		{`'it'\''s'`, []string{"it's"}, false},
+		// 双引号 中 只 转义 " \ $ ` 换行
+		{`"a\"b\\c\n"`, []string{`a"b\c\n`}, false},
+		{`a\ b`, []string{"a b"}, false},
+		{"curl \\\n  -X POST", []string{"curl", "-X", "POST"}, false},
+		{`''`, []string{""}, false},
+		{`'abc`, nil, true},
+		{`"abc`, nil, true},
+	} {
+		res, err := splitCommand(one.command)
+		if (err != nil) != one.error {
+			t.Errorf("command %s expect error %v, got %v", one.command, one.error, err)
+			continue
+		}
+		if !one.error && !reflect.DeepEqual(res, one.expect) {
+			t.Errorf("command %s expect %q, got %q", one.command, one.expect, res)
+		}
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	for _, one := range []struct {
+		command  string
+		method   string
+		url      string
+		bodyType string
+		body     string
+		check    func(req *Request) bool
+	}{
+		{`curl http://a/b`, "GET", "http://a/b", BodyTypeNone, "", nil},
+		{`curl -XPUT --url=http://a -H 'Content-Type: application/json' -d '{"a":1}'`, "PUT", "http://a", BodyTypeJson, `{"a":1}`, nil},
+		{`curl http://a -d a=1 -d b=2`, "POST", "http://a", BodyTypeRaw, "a=1&b=2", func(req *Request) bool {
+			return getHeader(req.Headers, "content-type") == "application/x-www-form-urlencoded"
+		}},
+		{`curl -G http://a?x=1 --data-urlencode 'q=a b'`, "GET", "http://a", BodyTypeNone, "", func(req *Request) bool {
+			return len(req.Params) == 2 && req.Params[0].Value == "1" && req.Params[1].Key == "q" && req.Params[1].Value == "a b"
+		}},
+		{`curl -I http://a`, "HEAD", "http://a", BodyTypeNone, "", nil},
+		{`curl http://a -F name=x -F file=@/tmp/a.txt`, "POST", "http://a", BodyTypeForm, "", func(req *Request) bool {
+			return len(req.Form) == 2 && req.Form[1].Type == "file" && req.Form[1].Value == "/tmp/a.txt"
+		}},
+		{`curl http://u:p@a/b`, "GET", "http://a/b", BodyTypeNone, "", func(req *Request) bool {
+			return req.Auth.Type == AuthTypeBasic && req.Auth.Username == "u" && req.Auth.Password == "p"
+		}},
+		{`curl http://a -H 'Authorization: Bearer t1' -H 'X: y'`, "GET", "http://a", BodyTypeNone, "", func(req *Request) bool {
+			return req.Auth.Type == AuthTypeBearer && req.Auth.Token == "t1" && len(req.Headers) == 1
+		}},
+		{`curl http://a -H 'Authorization: Basic dTpw'`, "GET", "http://a", BodyTypeNone, "", func(req *Request) bool {
+			return req.Auth.Username == "u" && req.Auth.Password == "p" && len(req.Headers) == 0
+		}},
+		{`curl --json '[1]' -o out.txt http://a`, "POST", "http://a", BodyTypeJson, "[1]", nil},
+	} {
+		req, err := ParseCurl(one.command)
+		if err != nil {
+			t.Errorf("curl %s error:%s", one.command, err)
+			continue
+		}
+		if req.Method != one.method || req.Url != one.url || req.BodyType != one.bodyType || req.Body != one.body {
+			t.Errorf("curl %s expect %s %s %s %s, got %s %s %s %s", one.command, one.method, one.url, one.bodyType, one.body, req.Method, req.Url, req.BodyType, req.Body)
+			continue
+		}
+		if one.check != nil && !one.check(req) {
+			t.Errorf("curl %s check fail, got %+v", one.command, req)
+		}
+	}
+
+	for _, command := range []string{"", "wget http://a", "curl -X POST", "curl 'http://a"} {
+		if _, err := ParseCurl(command); err == nil {
+			t.Errorf("curl %s expect error", command)
+		}
+	}
+}
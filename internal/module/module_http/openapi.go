@@ -0,0 +1,517 @@
+package module_http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var openApiMethods = []string{"get", "post", "put", "patch", "delete", "head", "options"}
+
+// 生成 示例 的 最大 嵌套 层级，防止 循环 引用
+const openApiMaxDepth = 8
+
+type openApiParser struct {
+	doc        map[string]interface{}
+	isSwagger  bool
+	collection *Collection
+	visiting   map[string]bool // 生成 示例 时 正在 展开 的 $ref，用于 跳过 递归 引用
+}
+
+// ParseOpenApi 解析 OpenAPI 3 或 Swagger 2 文档（JSON、YAML）为 请求 集合
+// 请求 地址 使用 {{baseUrl}} 变量，路径 参数 转为 {{参数}} 变量，按 第一个 tag 分组
+func ParseOpenApi(content string) (res *Collection, err error) {
+	var data interface{}
+	trim := strings.TrimSpace(content)
+	if strings.HasPrefix(trim, "{") {
+		err = json.Unmarshal([]byte(trim), &data)
+	} else {
+		err = yaml.Unmarshal([]byte(trim), &data)
+	}
+	if err != nil {
+		err = errors.New("openapi content parse error:" + err.Error())
+		return
+	}
+	doc, ok := normalizeYaml(data).(map[string]interface{})
+	if !ok {
+		err = errors.New("openapi content is not an object")
+		return
+	}
+	parser := &openApiParser{
+		doc:        doc,
+		isSwagger:  doc["swagger"] != nil,
+		collection: &Collection{Variables: map[string]string{}},
+		visiting:   map[string]bool{},
+	}
+	if !parser.isSwagger && doc["openapi"] == nil {
+		err = errors.New("content is not an openapi or swagger document")
+		return
+	}
+	parser.parse()
+	res = parser.collection
+	return
+}
+
+// normalizeYaml YAML 中 非 字符串 的 键（如 响应 码 200）转为 字符串
+func normalizeYaml(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		res := map[string]interface{}{}
+		for key, one := range v {
+			res[fmt.Sprint(key)] = normalizeYaml(one)
+		}
+		return res
+	case map[string]interface{}:
+		for key, one := range v {
+			v[key] = normalizeYaml(one)
+		}
+		return v
+	case []interface{}:
+		for i, one := range v {
+			v[i] = normalizeYaml(one)
+		}
+		return v
+	}
+	return value
+}
+
+func getMap(data map[string]interface{}, name string) map[string]interface{} {
+	res, _ := data[name].(map[string]interface{})
+	return res
+}
+
+func getList(data map[string]interface{}, name string) []interface{} {
+	res, _ := data[name].([]interface{})
+	return res
+}
+
+func getString(data map[string]interface{}, name string) string {
+	if data[name] == nil {
+		return ""
+	}
+	return util.GetStringValue(data[name])
+}
+
+func sortedKeys(data map[string]interface{}) (keys []string) {
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// resolve 解析 本 文档 内 的 $ref，如 #/components/schemas/User
+func (this_ *openApiParser) resolve(data map[string]interface{}) map[string]interface{} {
+	for i := 0; i < openApiMaxDepth && data != nil; i++ {
+		ref := getString(data, "$ref")
+		if !strings.HasPrefix(ref, "#/") {
+			return data
+		}
+		var current interface{} = this_.doc
+		for _, name := range strings.Split(ref[2:], "/") {
+			name = strings.ReplaceAll(strings.ReplaceAll(name, "~1", "/"), "~0", "~")
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			current = m[name]
+		}
+		data, _ = current.(map[string]interface{})
+	}
+	return data
+}
+
+func (this_ *openApiParser) parse() {
+	doc := this_.doc
+	collection := this_.collection
+	info := getMap(doc, "info")
+	collection.Name = getString(info, "title")
+	if collection.Name == "" {
+		collection.Name = "OpenAPI"
+	}
+	collection.Description = getString(info, "description")
+	collection.Variables["baseUrl"] = this_.baseUrl()
+	collection.Auth = this_.auth()
+
+	paths := getMap(doc, "paths")
+	for _, path := range sortedKeys(paths) {
+		pathItem := this_.resolve(getMap(paths, path))
+		if pathItem == nil {
+			continue
+		}
+		for _, method := range openApiMethods {
+			operation := getMap(pathItem, method)
+			if operation == nil {
+				continue
+			}
+			collection.Items = append(collection.Items, this_.parseOperation(path, method, getList(pathItem, "parameters"), operation))
+		}
+	}
+}
+
+func (this_ *openApiParser) baseUrl() string {
+	doc := this_.doc
+	if this_.isSwagger {
+		host := getString(doc, "host")
+		if host == "" {
+			host = "localhost"
+		}
+		scheme := "http"
+		if schemes := getList(doc, "schemes"); len(schemes) > 0 {
+			scheme = util.GetStringValue(schemes[0])
+		}
+		return scheme + "://" + host + strings.TrimRight(getString(doc, "basePath"), "/")
+	}
+	servers := getList(doc, "servers")
+	if len(servers) == 0 {
+		return "http://localhost"
+	}
+	server, _ := servers[0].(map[string]interface{})
+	url := getString(server, "url")
+	variables := getMap(server, "variables")
+	for _, name := range sortedKeys(variables) {
+		url = strings.ReplaceAll(url, "{"+name+"}", getString(getMap(variables, name), "default"))
+	}
+	return strings.TrimRight(url, "/")
+}
+
+// auth 集合 的 认证 使用 文档 的 第一个 安全 方案，凭证 使用 变量
+func (this_ *openApiParser) auth() (res *Auth) {
+	doc := this_.doc
+	var schemes map[string]interface{}
+	if this_.isSwagger {
+		schemes = getMap(doc, "securityDefinitions")
+	} else {
+		schemes = getMap(getMap(doc, "components"), "securitySchemes")
+	}
+	if len(schemes) == 0 {
+		return
+	}
+	name := ""
+	for _, one := range getList(doc, "security") {
+		requirement, _ := one.(map[string]interface{})
+		for key := range requirement {
+			name = key
+			break
+		}
+		if name != "" {
+			break
+		}
+	}
+	if name == "" {
+		name = sortedKeys(schemes)[0]
+	}
+	scheme := this_.resolve(getMap(schemes, name))
+	if scheme == nil {
+		return
+	}
+	switch getString(scheme, "type") {
+	case "basic":
+		res = &Auth{Type: AuthTypeBasic, Username: "{{username}}", Password: "{{password}}"}
+	case "http":
+		switch strings.ToLower(getString(scheme, "scheme")) {
+		case "basic":
+			res = &Auth{Type: AuthTypeBasic, Username: "{{username}}", Password: "{{password}}"}
+		case "bearer":
+			res = &Auth{Type: AuthTypeBearer, Token: "{{token}}"}
+		}
+	case "apiKey":
+		res = &Auth{Type: AuthTypeApiKey, Key: getString(scheme, "name"), Value: "{{apiKey}}", In: getString(scheme, "in")}
+	case "oauth2", "openIdConnect":
+		res = &Auth{Type: AuthTypeBearer, Token: "{{token}}"}
+	}
+	if res != nil {
+		for _, v := range []string{"username", "password", "token", "apiKey"} {
+			if strings.Contains(res.Username+res.Password+res.Token+res.Value, "{{"+v+"}}") {
+				this_.collection.Variables[v] = ""
+			}
+		}
+	}
+	return
+}
+
+func (this_ *openApiParser) parseOperation(path string, method string, pathParameters []interface{}, operation map[string]interface{}) (req *Request) {
+	req = &Request{
+		Method:   strings.ToUpper(method),
+		Url:      "{{baseUrl}}" + path,
+		BodyType: BodyTypeNone,
+	}
+	req.Name = getString(operation, "summary")
+	if req.Name == "" {
+		req.Name = getString(operation, "operationId")
+	}
+	if req.Name == "" {
+		req.Name = req.Method + " " + path
+	}
+	if tags := getList(operation, "tags"); len(tags) > 0 {
+		req.Folder = util.GetStringValue(tags[0])
+	}
+
+	// 操作 的 参数 覆盖 路径 的 同名 参数
+	parameters := map[string]map[string]interface{}{}
+	var order []string
+	var list []interface{}
+	list = append(list, pathParameters...)
+	list = append(list, getList(operation, "parameters")...)
+	for _, one := range list {
+		parameter, _ := one.(map[string]interface{})
+		parameter = this_.resolve(parameter)
+		if parameter == nil {
+			continue
+		}
+		key := getString(parameter, "in") + ":" + getString(parameter, "name")
+		if parameters[key] == nil {
+			order = append(order, key)
+		}
+		parameters[key] = parameter
+	}
+	var cookies []string
+	var bodyParameter map[string]interface{}
+	for _, key := range order {
+		parameter := parameters[key]
+		name := getString(parameter, "name")
+		value := this_.parameterValue(parameter)
+		disabled := parameter["required"] != true
+		switch getString(parameter, "in") {
+		case "path":
+			req.Url = strings.ReplaceAll(req.Url, "{"+name+"}", "{{"+name+"}}")
+			if _, ok := this_.collection.Variables[name]; !ok {
+				this_.collection.Variables[name] = value
+			}
+		case "query":
+			req.Params = append(req.Params, &KeyValue{Key: name, Value: value, Disabled: disabled})
+		case "header":
+			req.Headers = append(req.Headers, &KeyValue{Key: name, Value: value, Disabled: disabled})
+		case "cookie":
+			cookies = append(cookies, name+"="+value)
+		case "formData":
+			one := &KeyValue{Key: name, Value: value, Disabled: disabled}
+			if getString(parameter, "type") == "file" {
+				one.Type = "file"
+				req.BodyType = BodyTypeForm
+			}
+			req.Form = append(req.Form, one)
+		case "body":
+			bodyParameter = parameter
+		}
+	}
+	if len(cookies) > 0 {
+		req.Headers = append(req.Headers, &KeyValue{Key: "Cookie", Value: strings.Join(cookies, "; ")})
+	}
+
+	if this_.isSwagger {
+		if len(req.Form) > 0 && req.BodyType == BodyTypeNone {
+			req.BodyType = BodyTypeUrlencoded
+			for _, one := range getList(operation, "consumes") {
+				if util.GetStringValue(one) == "multipart/form-data" {
+					req.BodyType = BodyTypeForm
+				}
+			}
+		}
+		if bodyParameter != nil {
+			req.BodyType = BodyTypeJson
+			req.Body = toJsonExample(this_.example(getMap(bodyParameter, "schema"), 0))
+		}
+		return
+	}
+	this_.parseRequestBody(req, this_.resolve(getMap(operation, "requestBody")))
+	return
+}
+
+func (this_ *openApiParser) parameterValue(parameter map[string]interface{}) string {
+	var value interface{}
+	if v, ok := parameter["example"]; ok {
+		value = v
+	} else if v, ok := parameter["default"]; ok {
+		value = v
+	} else if schema := getMap(parameter, "schema"); schema != nil {
+		value = this_.example(schema, 0)
+	} else if enum := getList(parameter, "enum"); len(enum) > 0 {
+		value = enum[0]
+	}
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}, []interface{}:
+		return toJsonExample(v)
+	}
+	return util.GetStringValue(value)
+}
+
+func (this_ *openApiParser) parseRequestBody(req *Request, requestBody map[string]interface{}) {
+	content := getMap(requestBody, "content")
+	if len(content) == 0 {
+		return
+	}
+	contentType := ""
+	for _, one := range sortedKeys(content) {
+		if one == "application/json" || strings.HasSuffix(one, "+json") {
+			contentType = one
+			break
+		}
+	}
+	if contentType == "" {
+		if content["application/x-www-form-urlencoded"] != nil {
+			contentType = "application/x-www-form-urlencoded"
+		} else if content["multipart/form-data"] != nil {
+			contentType = "multipart/form-data"
+		} else {
+			contentType = sortedKeys(content)[0]
+		}
+	}
+	media := getMap(content, contentType)
+	schema := this_.resolve(getMap(media, "schema"))
+
+	if contentType == "application/x-www-form-urlencoded" || contentType == "multipart/form-data" {
+		req.BodyType = BodyTypeUrlencoded
+		if contentType == "multipart/form-data" {
+			req.BodyType = BodyTypeForm
+		}
+		required := map[string]bool{}
+		for _, one := range getList(schema, "required") {
+			required[util.GetStringValue(one)] = true
+		}
+		properties := getMap(schema, "properties")
+		for _, name := range sortedKeys(properties) {
+			property := this_.resolve(getMap(properties, name))
+			one := &KeyValue{Key: name, Disabled: !required[name]}
+			if getString(property, "format") == "binary" {
+				one.Type = "file"
+			} else {
+				one.Value = this_.parameterValue(map[string]interface{}{"schema": property})
+			}
+			req.Form = append(req.Form, one)
+		}
+		return
+	}
+
+	var example interface{}
+	if v, ok := media["example"]; ok {
+		example = v
+	} else if examples := getMap(media, "examples"); len(examples) > 0 {
+		example = this_.resolve(getMap(examples, sortedKeys(examples)[0]))["value"]
+	} else {
+		example = this_.example(schema, 0)
+	}
+	if contentType == "application/json" || strings.HasSuffix(contentType, "+json") {
+		req.BodyType = BodyTypeJson
+		req.Body = toJsonExample(example)
+		if contentType != "application/json" {
+			req.Headers = append(req.Headers, &KeyValue{Key: "Content-Type", Value: contentType})
+		}
+		return
+	}
+	req.BodyType = BodyTypeRaw
+	req.Headers = append(req.Headers, &KeyValue{Key: "Content-Type", Value: contentType})
+	if s, ok := example.(string); ok {
+		req.Body = s
+	} else if example != nil {
+		req.Body = toJsonExample(example)
+	}
+}
+
+// example 按 schema 生成 示例 值，优先 使用 example、default、enum
+func (this_ *openApiParser) example(schema map[string]interface{}, depth int) interface{} {
+	if depth > openApiMaxDepth {
+		return nil
+	}
+	if ref := getString(schema, "$ref"); ref != "" {
+		if this_.visiting[ref] {
+			return nil
+		}
+		this_.visiting[ref] = true
+		defer delete(this_.visiting, ref)
+	}
+	schema = this_.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	if v, ok := schema["example"]; ok {
+		return v
+	}
+	if v, ok := schema["default"]; ok {
+		return v
+	}
+	if enum := getList(schema, "enum"); len(enum) > 0 {
+		return enum[0]
+	}
+	if allOf := getList(schema, "allOf"); len(allOf) > 0 {
+		res := map[string]interface{}{}
+		for _, one := range allOf {
+			sub, _ := one.(map[string]interface{})
+			if m, ok := this_.example(sub, depth+1).(map[string]interface{}); ok {
+				for k, v := range m {
+					res[k] = v
+				}
+			}
+		}
+		return res
+	}
+	for _, name := range []string{"oneOf", "anyOf"} {
+		if list := getList(schema, name); len(list) > 0 {
+			sub, _ := list[0].(map[string]interface{})
+			return this_.example(sub, depth+1)
+		}
+	}
+
+	schemaType := getString(schema, "type")
+	// OpenAPI 3.1 的 type 可以 是 数组
+	if types := getList(schema, "type"); len(types) > 0 {
+		schemaType = ""
+		for _, one := range types {
+			if s := util.GetStringValue(one); s != "null" {
+				schemaType = s
+				break
+			}
+		}
+	}
+	if schemaType == "" && schema["properties"] != nil {
+		schemaType = "object"
+	}
+	switch schemaType {
+	case "object":
+		res := map[string]interface{}{}
+		properties := getMap(schema, "properties")
+		for _, name := range sortedKeys(properties) {
+			res[name] = this_.example(getMap(properties, name), depth+1)
+		}
+		return res
+	case "array":
+		item := this_.example(getMap(schema, "items"), depth+1)
+		if item == nil {
+			return []interface{}{}
+		}
+		return []interface{}{item}
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	case "string":
+		switch getString(schema, "format") {
+		case "date-time":
+			return "1970-01-01T00:00:00Z"
+		case "date":
+			return "1970-01-01"
+		}
+		return ""
+	}
+	return nil
+}
+
+func toJsonExample(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	bs, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(bs)
+}
@@ -0,0 +1,159 @@
+package module_http
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testOpenApi = `
+openapi: 3.0.0
+info:
+  title: Pet
+servers:
+  - url: http://{host}/v1/
+    variables:
+      host:
+        default: localhost:8080
+security:
+  - token: []
+components:
+  securitySchemes:
+    token:
+      type: http
+      scheme: bearer
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        name:
+          type: string
+          example: cat
+        age:
+          type: integer
+        parent:
+          $ref: '#/components/schemas/Pet'
+paths:
+  /pets/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: integer
+          example: 1
+    get:
+      summary: get pet
+      tags: [pet]
+      parameters:
+        - name: fields
+          in: query
+          schema:
+            type: string
+        - name: sid
+          in: cookie
+          example: s1
+      responses:
+        200:
+          description: ok
+    put:
+      operationId: updatePet
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+  /upload:
+    post:
+      requestBody:
+        content:
+          multipart/form-data:
+            schema:
+              type: object
+              required: [file]
+              properties:
+                file:
+                  type: string
+                  format: binary
+                note:
+                  type: string
+`
+
+const testSwagger = `{
+  "swagger": "2.0",
+  "info": {"title": "S"},
+  "host": "a.com",
+  "basePath": "/api/",
+  "schemes": ["https"],
+  "securityDefinitions": {"key": {"type": "apiKey", "name": "X-Key", "in": "header"}},
+  "paths": {
+    "/users": {
+      "post": {
+        "parameters": [{"name": "body", "in": "body", "schema": {"type": "object", "properties": {"tags": {"type": "array", "items": {"type": "string", "enum": ["a"]}}}}}]
+      },
+      "put": {
+        "consumes": ["multipart/form-data"],
+        "parameters": [{"name": "f", "in": "formData", "type": "string", "default": "x", "required": true}]
+      }
+    }
+  }
+}`
+
+func TestParseOpenApi(t *testing.T) {
+	collection, err := ParseOpenApi(testOpenApi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collection.Name != "Pet" || collection.Variables["baseUrl"] != "http://localhost:8080/v1" || collection.Variables["id"] != "1" {
+		t.Errorf("collection error, got %s %v", collection.Name, collection.Variables)
+	}
+	if collection.Auth == nil || collection.Auth.Type != AuthTypeBearer || collection.Auth.Token != "{{token}}" {
+		t.Errorf("collection auth expect bearer, got %+v", collection.Auth)
+	}
+	if len(collection.Items) != 3 {
+		t.Fatalf("expect 3 requests, got %d", len(collection.Items))
+	}
+	get, put, upload := collection.Items[0], collection.Items[1], collection.Items[2]
+	if get.Name != "get pet" || get.Folder != "pet" || get.Method != "GET" || get.Url != "{{baseUrl}}/pets/{{id}}" {
+		t.Errorf("get error, got %+v", get)
+	}
+	if len(get.Params) != 1 || !get.Params[0].Disabled || getHeader(get.Headers, "Cookie") != "sid=s1" {
+		t.Errorf("get params headers error, got %+v %+v", get.Params, get.Headers)
+	}
+	// 递归 引用 展开 一层 后 跳过
+	var putBody interface{}
+	_ = json.Unmarshal([]byte(put.Body), &putBody)
+	pet := map[string]interface{}{"age": float64(0), "name": "cat"}
+	pet["parent"] = map[string]interface{}{"age": float64(0), "name": "cat", "parent": nil}
+	if put.Name != "updatePet" || put.BodyType != BodyTypeJson || !reflect.DeepEqual(putBody, pet) {
+		t.Errorf("put error, got %s %s %s", put.Name, put.BodyType, put.Body)
+	}
+	if upload.Name != "POST /upload" || upload.BodyType != BodyTypeForm || len(upload.Form) != 2 ||
+		upload.Form[0].Type != "file" || upload.Form[0].Disabled || !upload.Form[1].Disabled {
+		t.Errorf("upload error, got %+v %+v", upload, upload.Form)
+	}
+
+	collection, err = ParseOpenApi(testSwagger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collection.Variables["baseUrl"] != "https://a.com/api" || collection.Auth.Type != AuthTypeApiKey || collection.Auth.Key != "X-Key" {
+		t.Errorf("swagger collection error, got %v %+v", collection.Variables, collection.Auth)
+	}
+	post, put := collection.Items[0], collection.Items[1]
+	var postBody interface{}
+	_ = json.Unmarshal([]byte(post.Body), &postBody)
+	if post.BodyType != BodyTypeJson || !reflect.DeepEqual(postBody, map[string]interface{}{"tags": []interface{}{"a"}}) {
+		t.Errorf("swagger post error, got %s %s", post.BodyType, post.Body)
+	}
+	if put.BodyType != BodyTypeForm || len(put.Form) != 1 || put.Form[0].Value != "x" || put.Form[0].Disabled {
+		t.Errorf("swagger put error, got %s %+v", put.BodyType, put.Form)
+	}
+
+	for _, content := range []string{"{a", "[1]", `{"info":{}}`} {
+		if _, err = ParseOpenApi(content); err == nil {
+			t.Errorf("content %s expect error", content)
+		}
+	}
+}
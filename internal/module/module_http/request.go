@@ -0,0 +1,232 @@
+package module_http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	BodyTypeNone       = "none"
+	BodyTypeRaw        = "raw"
+	BodyTypeJson       = "json"
+	BodyTypeForm       = "form" // multipart/form-data
+	BodyTypeUrlencoded = "urlencoded"
+
+	AuthTypeNone    = "none"
+	AuthTypeInherit = "inherit" // 使用 集合 的 认证
+	AuthTypeBasic   = "basic"
+	AuthTypeBearer  = "bearer"
+	AuthTypeApiKey  = "apiKey"
+)
+
+type KeyValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Type     string `json:"type,omitempty"` // form 中 的 text、file，file 时 Value 为 上传 文件 的 路径
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+type Auth struct {
+	Type     string `json:"type,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Value    string `json:"value,omitempty"`
+	In       string `json:"in,omitempty"` // apiKey 的 位置 header、query，默认 header
+}
+
+// Request 请求 定义，Url、参数、请求头、请求体 和 认证 中 可以 使用 {{变量}}
+type Request struct {
+	Name             string      `json:"name,omitempty"`
+	Folder           string      `json:"folder,omitempty"`
+	Method           string      `json:"method,omitempty"`
+	Url              string      `json:"url,omitempty"`
+	Params           []*KeyValue `json:"params,omitempty"`
+	Headers          []*KeyValue `json:"headers,omitempty"`
+	BodyType         string      `json:"bodyType,omitempty"`
+	Body             string      `json:"body,omitempty"`
+	Form             []*KeyValue `json:"form,omitempty"`
+	Auth             *Auth       `json:"auth,omitempty"`
+	PreRequestScript string      `json:"preRequestScript,omitempty"`
+	TestScript       string      `json:"testScript,omitempty"`
+}
+
+// Collection 请求 集合，集合 的 变量、认证 和 脚本 对 所有 请求 生效，集合 脚本 在 请求 脚本 之前 执行
+type Collection struct {
+	Name             string            `json:"name,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	Variables        map[string]string `json:"variables,omitempty"`
+	Auth             *Auth             `json:"auth,omitempty"`
+	PreRequestScript string            `json:"preRequestScript,omitempty"`
+	TestScript       string            `json:"testScript,omitempty"`
+	Items            []*Request        `json:"items,omitempty"`
+}
+
+type SendRequest struct {
+	ToolboxId         int64             `json:"toolboxId,omitempty"`
+	Request           *Request          `json:"request,omitempty"`
+	CollectionId      int64             `json:"collectionId,omitempty"`
+	EnvironmentId     int64             `json:"environmentId,omitempty"`
+	Variables         map[string]string `json:"variables,omitempty"`
+	CookieJar         string            `json:"cookieJar,omitempty"`
+	Timeout           int               `json:"timeout,omitempty"` // 毫秒，为 0 时 使用 工具 配置
+	NotFollowRedirect bool              `json:"notFollowRedirect,omitempty"`
+	SaveEnvironment   bool              `json:"saveEnvironment,omitempty"` // 脚本 修改 的 环境 变量 保存 到 环境
+}
+
+func (this_ *Request) clone() (res *Request) {
+	res = &Request{}
+	bs, _ := json.Marshal(this_)
+	_ = json.Unmarshal(bs, res)
+	return
+}
+
+var variablePattern = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*}}`)
+
+// replaceVariables 替换 {{name}}，未 定义 的 变量 保留 原样
+// 支持 动态 变量 $timestamp、$timestampMilli、$isoTimestamp、$uuid、$randomInt
+func replaceVariables(str string, variables map[string]string) string {
+	if !strings.Contains(str, "{{") {
+		return str
+	}
+	return variablePattern.ReplaceAllStringFunc(str, func(s string) string {
+		name := variablePattern.FindStringSubmatch(s)[1]
+		if v, ok := variables[name]; ok {
+			return v
+		}
+		switch name {
+		case "$timestamp":
+			return fmt.Sprintf("%d", time.Now().Unix())
+		case "$timestampMilli":
+			return fmt.Sprintf("%d", time.Now().UnixMilli())
+		case "$isoTimestamp":
+			return time.Now().UTC().Format(time.RFC3339)
+		case "$uuid":
+			return util.GetUUID()
+		case "$randomInt":
+			return fmt.Sprintf("%d", rand.Intn(1000))
+		}
+		return s
+	})
+}
+
+func replaceKeyValues(list []*KeyValue, variables map[string]string) (res []*KeyValue) {
+	for _, one := range list {
+		if one == nil || one.Disabled || one.Key == "" {
+			continue
+		}
+		res = append(res, &KeyValue{
+			Key:   replaceVariables(one.Key, variables),
+			Value: replaceVariables(one.Value, variables),
+			Type:  one.Type,
+		})
+	}
+	return
+}
+
+// resolve 替换 变量 后 的 请求，去掉 禁用 的 项
+func (this_ *Request) resolve(variables map[string]string) (res *Request) {
+	res = &Request{
+		Name:     this_.Name,
+		Folder:   this_.Folder,
+		Method:   strings.ToUpper(strings.TrimSpace(this_.Method)),
+		Url:      strings.TrimSpace(replaceVariables(this_.Url, variables)),
+		Params:   replaceKeyValues(this_.Params, variables),
+		Headers:  replaceKeyValues(this_.Headers, variables),
+		BodyType: this_.BodyType,
+		Body:     replaceVariables(this_.Body, variables),
+		Form:     replaceKeyValues(this_.Form, variables),
+	}
+	if res.Method == "" {
+		res.Method = "GET"
+	}
+	if this_.Auth != nil {
+		res.Auth = &Auth{
+			Type:     this_.Auth.Type,
+			Username: replaceVariables(this_.Auth.Username, variables),
+			Password: replaceVariables(this_.Auth.Password, variables),
+			Token:    replaceVariables(this_.Auth.Token, variables),
+			Key:      replaceVariables(this_.Auth.Key, variables),
+			Value:    replaceVariables(this_.Auth.Value, variables),
+			In:       this_.Auth.In,
+		}
+	}
+	return
+}
+
+// toScriptObject 脚本 中 的 request 对象，请求头 和 参数 为 键值 对象
+func (this_ *Request) toScriptObject() map[string]interface{} {
+	headers := map[string]interface{}{}
+	for _, one := range this_.Headers {
+		if one != nil && !one.Disabled && one.Key != "" {
+			headers[one.Key] = one.Value
+		}
+	}
+	params := map[string]interface{}{}
+	for _, one := range this_.Params {
+		if one != nil && !one.Disabled && one.Key != "" {
+			params[one.Key] = one.Value
+		}
+	}
+	return map[string]interface{}{
+		"method":  this_.Method,
+		"url":     this_.Url,
+		"headers": headers,
+		"params":  params,
+		"body":    this_.Body,
+	}
+}
+
+// fromScriptObject 读取 前置 脚本 修改 后 的 request 对象
+func (this_ *Request) fromScriptObject(data map[string]interface{}) {
+	this_.Method = util.GetStringValue(data["method"])
+	this_.Url = util.GetStringValue(data["url"])
+	this_.Body = util.GetStringValue(data["body"])
+	this_.Headers = scriptObjectToKeyValues(data["headers"])
+	this_.Params = scriptObjectToKeyValues(data["params"])
+}
+
+func scriptObjectToKeyValues(value interface{}) (res []*KeyValue) {
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	var keys []string
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if data[key] == nil {
+			continue
+		}
+		res = append(res, &KeyValue{Key: key, Value: util.GetStringValue(data[key])})
+	}
+	return
+}
+
+func mapToStruct(data map[string]interface{}, value interface{}) (err error) {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(bs, value)
+	return
+}
+
+func structToMap(value interface{}) (res map[string]interface{}, err error) {
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	res = map[string]interface{}{}
+	err = json.Unmarshal(bs, &res)
+	return
+}
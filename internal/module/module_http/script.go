@@ -0,0 +1,127 @@
+package module_http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/team-ide/go-tool/javascript"
+	"github.com/team-ide/go-tool/util"
+	"strconv"
+	"strings"
+)
+
+// scriptPrelude 前置、测试 脚本 共用 的 函数，test 的 函数 抛出 异常 或 返回 false 时 失败
+const scriptPrelude = `
+function test(name, fn) {
+	try {
+		var r = fn();
+		__testResult(name, r !== false, r === false ? "return false" : "");
+	} catch (e) {
+		__testResult(name, false, String(e && e.message ? e.message : e));
+	}
+}
+function assert(condition, message) {
+	if (!condition) {
+		throw new Error(message || "assert failed");
+	}
+}
+`
+
+type TestResult struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// scriptContext 脚本 执行 上下文，variables 为 本次 请求 的 变量，environment 为 环境 变量
+type scriptContext struct {
+	variables          map[string]string
+	environment        map[string]string
+	environmentChanged bool
+	tests              []*TestResult
+	logs               []string
+}
+
+func (this_ *scriptContext) newContext() map[string]interface{} {
+	context := javascript.NewContext()
+	context["variables"] = map[string]interface{}{
+		"get": func(name string) interface{} {
+			if v, ok := this_.variables[name]; ok {
+				return v
+			}
+			return nil
+		},
+		"set": func(name string, value interface{}) {
+			this_.variables[name] = scriptString(value)
+		},
+		"has": func(name string) bool {
+			_, ok := this_.variables[name]
+			return ok
+		},
+		"unset": func(name string) {
+			delete(this_.variables, name)
+		},
+	}
+	context["environment"] = map[string]interface{}{
+		"get": func(name string) interface{} {
+			if v, ok := this_.environment[name]; ok {
+				return v
+			}
+			return nil
+		},
+		"set": func(name string, value interface{}) {
+			this_.environment[name] = scriptString(value)
+			// 环境 变量 同时 对 本次 请求 生效
+			this_.variables[name] = this_.environment[name]
+			this_.environmentChanged = true
+		},
+		"unset": func(name string) {
+			delete(this_.environment, name)
+			this_.environmentChanged = true
+		},
+	}
+	context["console"] = map[string]interface{}{
+		"log": func(args ...interface{}) {
+			var ss []string
+			for _, arg := range args {
+				ss = append(ss, scriptString(arg))
+			}
+			this_.logs = append(this_.logs, strings.Join(ss, " "))
+		},
+	}
+	context["__testResult"] = func(name string, passed bool, message string) {
+		this_.tests = append(this_.tests, &TestResult{Name: name, Passed: passed, Message: message})
+	}
+	return context
+}
+
+// run 执行 脚本，scope 为 额外 的 对象，如 request、response
+func (this_ *scriptContext) run(name string, script string, scope map[string]interface{}) (err error) {
+	if strings.TrimSpace(script) == "" {
+		return
+	}
+	context := this_.newContext()
+	for k, v := range scope {
+		context[k] = v
+	}
+	_, err = javascript.RunScript(scriptPrelude+script, context)
+	if err != nil {
+		err = fmt.Errorf("%s script error:%s", name, err.Error())
+		return
+	}
+	return
+}
+
+func scriptString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}, []interface{}:
+		bs, _ := json.Marshal(v)
+		return string(bs)
+	}
+	return util.GetStringValue(value)
+}
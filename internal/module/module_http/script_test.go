@@ -0,0 +1,24 @@
+package module_http
+
+import (
+	"testing"
+)
+
+func TestScriptString(t *testing.T) {
+	for _, one := range []struct {
+		value  interface{}
+		expect string
+	}{
+		{nil, ""},
+		{"abc", "abc"},
+		{true, "true"},
+		{int64(12), "12"},
+		{1.5, "1.5"},
+		{map[string]interface{}{"a": 1}, `{"a":1}`},
+		{[]interface{}{"a", 1}, `["a",1]`},
+	} {
+		if res := scriptString(one.value); res != one.expect {
+			t.Errorf("value %v expect %s, got %s", one.value, one.expect, res)
+		}
+	}
+}
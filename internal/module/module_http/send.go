@@ -0,0 +1,409 @@
+package module_http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"teamide/internal/module/module_toolbox"
+	"time"
+	"unicode/utf8"
+)
+
+// 响应 体 最多 读取 10M，超出 部分 丢弃
+const maxResponseBodySize = 10 * 1024 * 1024
+
+type Response struct {
+	Status     int                 `json:"status"`
+	StatusText string              `json:"statusText"`
+	Proto      string              `json:"proto"`
+	Headers    map[string][]string `json:"headers"`
+	Cookies    []*CookieInfo       `json:"cookies,omitempty"`
+	Body       string              `json:"body,omitempty"`
+	BodyBase64 string              `json:"bodyBase64,omitempty"` // 非 文本 响应 体
+	Size       int64               `json:"size"`
+	Truncated  bool                `json:"truncated,omitempty"`
+	Timing     *Timing             `json:"timing"`
+}
+
+// Timing 请求 各 阶段 耗时 毫秒，经 SSH隧道 时 没有 DNS 耗时
+type Timing struct {
+	Dns       float64 `json:"dns,omitempty"`
+	Connect   float64 `json:"connect,omitempty"`
+	Tls       float64 `json:"tls,omitempty"`
+	FirstByte float64 `json:"firstByte"`
+	Total     float64 `json:"total"`
+}
+
+type SendResult struct {
+	Request     *Request          `json:"request"` // 变量 替换 后 实际 发送 的 请求
+	Url         string            `json:"url"`
+	Response    *Response         `json:"response,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	ScriptError string            `json:"scriptError,omitempty"`
+	Tests       []*TestResult     `json:"tests,omitempty"`
+	Logs        []string          `json:"logs,omitempty"`
+	Variables   map[string]string `json:"variables,omitempty"`
+	Environment map[string]string `json:"environment,omitempty"`
+}
+
+type sender struct {
+	api         *api
+	config      *Config
+	client      *http.Transport
+	sendRequest *SendRequest
+}
+
+func (this_ *sender) send() (res *SendResult, err error) {
+	sendRequest := this_.sendRequest
+	request := sendRequest.Request.clone()
+	res = &SendResult{}
+
+	var collection *Collection
+	if sendRequest.CollectionId > 0 {
+		_, collection, err = this_.api.getCollection(sendRequest.ToolboxId, sendRequest.CollectionId)
+		if err != nil {
+			return
+		}
+	}
+	var environmentExtend *module_toolbox.ToolboxExtendModel
+	script := &scriptContext{
+		variables:   map[string]string{},
+		environment: map[string]string{},
+	}
+	if sendRequest.EnvironmentId > 0 {
+		environmentExtend, script.environment, err = this_.api.getEnvironment(sendRequest.ToolboxId, sendRequest.EnvironmentId)
+		if err != nil {
+			return
+		}
+	}
+	// 变量 优先级：请求 变量 > 环境 变量 > 集合 变量
+	if collection != nil {
+		for k, v := range collection.Variables {
+			script.variables[k] = v
+		}
+	}
+	for k, v := range script.environment {
+		script.variables[k] = v
+	}
+	for k, v := range sendRequest.Variables {
+		script.variables[k] = v
+	}
+
+	if request.Auth == nil || request.Auth.Type == AuthTypeInherit {
+		request.Auth = nil
+		if collection != nil {
+			request.Auth = collection.Auth
+		}
+	}
+
+	defer func() {
+		res.Tests = script.tests
+		res.Logs = script.logs
+		res.Variables = script.variables
+		if environmentExtend != nil {
+			res.Environment = script.environment
+			if sendRequest.SaveEnvironment && script.environmentChanged {
+				err = this_.api.saveEnvironment(environmentExtend, script.environment)
+			}
+		}
+	}()
+
+	var preScripts, testScripts []string
+	if collection != nil {
+		preScripts = append(preScripts, collection.PreRequestScript)
+		testScripts = append(testScripts, collection.TestScript)
+	}
+	preScripts = append(preScripts, request.PreRequestScript)
+	testScripts = append(testScripts, request.TestScript)
+
+	requestObject := request.toScriptObject()
+	for _, one := range preScripts {
+		if err = script.run("pre-request", one, map[string]interface{}{"request": requestObject}); err != nil {
+			res.ScriptError = err.Error()
+			err = nil
+			return
+		}
+	}
+	request.fromScriptObject(requestObject)
+
+	resolved := request.resolve(script.variables)
+	res.Request = resolved
+	httpRequest, err := this_.buildRequest(resolved)
+	if err != nil {
+		res.Error = err.Error()
+		err = nil
+		return
+	}
+	res.Url = httpRequest.URL.String()
+
+	response, err := this_.do(httpRequest)
+	if err != nil {
+		res.Error = err.Error()
+		err = nil
+		return
+	}
+	res.Response = response
+
+	responseObject := map[string]interface{}{
+		"status":     response.Status,
+		"statusText": response.StatusText,
+		"headers":    flatHeaders(response.Headers),
+		"body":       response.Body,
+		"time":       response.Timing.Total,
+		"json": func() (interface{}, error) {
+			var data interface{}
+			e := json.Unmarshal([]byte(response.Body), &data)
+			return data, e
+		},
+	}
+	for _, one := range testScripts {
+		if err = script.run("test", one, map[string]interface{}{"request": requestObject, "response": responseObject}); err != nil {
+			res.ScriptError = err.Error()
+			err = nil
+			return
+		}
+	}
+	return
+}
+
+func flatHeaders(headers map[string][]string) map[string]interface{} {
+	res := map[string]interface{}{}
+	for k, v := range headers {
+		res[k] = strings.Join(v, ", ")
+		// 脚本 中 可以 使用 小写 名称 读取
+		res[strings.ToLower(k)] = res[k]
+	}
+	return res
+}
+
+func (this_ *sender) getUrl(request *Request) (u *url.URL, err error) {
+	rawUrl := request.Url
+	if !strings.Contains(rawUrl, "://") {
+		if this_.config.BaseUrl != "" {
+			rawUrl = strings.TrimRight(this_.config.BaseUrl, "/") + "/" + strings.TrimLeft(rawUrl, "/")
+		} else {
+			rawUrl = "http://" + rawUrl
+		}
+	}
+	u, err = url.Parse(rawUrl)
+	if err != nil {
+		return
+	}
+	if len(request.Params) > 0 {
+		query := u.Query()
+		for _, one := range request.Params {
+			query.Add(one.Key, one.Value)
+		}
+		u.RawQuery = query.Encode()
+	}
+	if request.Auth != nil && request.Auth.Type == AuthTypeApiKey && request.Auth.In == "query" && request.Auth.Key != "" {
+		query := u.Query()
+		query.Set(request.Auth.Key, request.Auth.Value)
+		u.RawQuery = query.Encode()
+	}
+	return
+}
+
+func (this_ *sender) buildBody(request *Request) (body io.Reader, contentType string, err error) {
+	switch request.BodyType {
+	case "", BodyTypeNone:
+		return
+	case BodyTypeJson:
+		body = strings.NewReader(request.Body)
+		contentType = "application/json"
+	case BodyTypeUrlencoded:
+		values := url.Values{}
+		for _, one := range request.Form {
+			values.Add(one.Key, one.Value)
+		}
+		body = strings.NewReader(values.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	case BodyTypeForm:
+		buf := &bytes.Buffer{}
+		writer := multipart.NewWriter(buf)
+		for _, one := range request.Form {
+			if one.Type != "file" {
+				if err = writer.WriteField(one.Key, one.Value); err != nil {
+					return
+				}
+				continue
+			}
+			var part io.Writer
+			part, err = writer.CreateFormFile(one.Key, filepath.Base(one.Value))
+			if err != nil {
+				return
+			}
+			var filename string
+			filename, err = getFilesFile(this_.api.toolboxService.GetFilesDir(), one.Value)
+			if err != nil {
+				return
+			}
+			var f *os.File
+			f, err = os.Open(filename)
+			if err != nil {
+				return
+			}
+			_, err = io.Copy(part, f)
+			_ = f.Close()
+			if err != nil {
+				return
+			}
+		}
+		if err = writer.Close(); err != nil {
+			return
+		}
+		body = buf
+		contentType = writer.FormDataContentType()
+	default:
+		body = strings.NewReader(request.Body)
+	}
+	return
+}
+
+// getFilesFile 表单 文件 为 工具 文件 目录 下 的 相对 路径，不能 访问 目录 外 的 文件
+func getFilesFile(filesDir string, path string) (filename string, err error) {
+	filename = filepath.Join(filesDir, path)
+	rel, err := filepath.Rel(filesDir, filename)
+	if err != nil || path == "" || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		err = errors.New("form file [" + path + "] is invalid")
+		return
+	}
+	return
+}
+
+func (this_ *sender) buildRequest(request *Request) (httpRequest *http.Request, err error) {
+	u, err := this_.getUrl(request)
+	if err != nil {
+		return
+	}
+	body, contentType, err := this_.buildBody(request)
+	if err != nil {
+		return
+	}
+	httpRequest, err = http.NewRequest(request.Method, u.String(), body)
+	if err != nil {
+		return
+	}
+	for _, one := range request.Headers {
+		if strings.EqualFold(one.Key, "Host") {
+			httpRequest.Host = one.Value
+			continue
+		}
+		httpRequest.Header.Add(one.Key, one.Value)
+	}
+	if contentType != "" && httpRequest.Header.Get("Content-Type") == "" {
+		httpRequest.Header.Set("Content-Type", contentType)
+	}
+	if httpRequest.Header.Get("User-Agent") == "" {
+		httpRequest.Header.Set("User-Agent", "Team-IDE")
+	}
+	if auth := request.Auth; auth != nil {
+		switch auth.Type {
+		case AuthTypeBasic:
+			httpRequest.SetBasicAuth(auth.Username, auth.Password)
+		case AuthTypeBearer:
+			httpRequest.Header.Set("Authorization", "Bearer "+auth.Token)
+		case AuthTypeApiKey:
+			if auth.In != "query" && auth.Key != "" {
+				httpRequest.Header.Set(auth.Key, auth.Value)
+			}
+		}
+	}
+	return
+}
+
+func sinceMilli(start time.Time, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return float64(end.Sub(start).Microseconds()) / 1000
+}
+
+func (this_ *sender) do(httpRequest *http.Request) (res *Response, err error) {
+	timeout := this_.sendRequest.Timeout
+	if timeout <= 0 {
+		timeout = this_.config.Timeout
+	}
+	if timeout <= 0 {
+		timeout = 60 * 1000
+	}
+	// 未 保存 的 测试 工具 不 保留 Cookie
+	jar := newCookieJar()
+	if this_.sendRequest.ToolboxId > 0 {
+		jar = getCookieJar(this_.sendRequest.ToolboxId, this_.sendRequest.CookieJar)
+	}
+	client := &http.Client{
+		Transport: this_.client,
+		Jar:       jar,
+		Timeout:   time.Duration(timeout) * time.Millisecond,
+	}
+	if this_.sendRequest.NotFollowRedirect {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	var dnsStart, dnsDone, connectStart, connectDone, tlsStart, tlsDone, firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { dnsDone = time.Now() },
+		ConnectStart:         func(string, string) { connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { connectDone = time.Now() },
+		TLSHandshakeStart:    func() { tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { tlsDone = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+	httpRequest = httpRequest.WithContext(httptrace.WithClientTrace(context.Background(), trace))
+
+	start := time.Now()
+	response, err := client.Do(httpRequest)
+	if err != nil {
+		return
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	bs, err := io.ReadAll(io.LimitReader(response.Body, maxResponseBodySize+1))
+	end := time.Now()
+	if err != nil {
+		return
+	}
+	res = &Response{
+		Status:     response.StatusCode,
+		StatusText: http.StatusText(response.StatusCode),
+		Proto:      response.Proto,
+		Headers:    response.Header,
+		Size:       int64(len(bs)),
+		Timing: &Timing{
+			Dns:       sinceMilli(dnsStart, dnsDone),
+			Connect:   sinceMilli(connectStart, connectDone),
+			Tls:       sinceMilli(tlsStart, tlsDone),
+			FirstByte: sinceMilli(start, firstByte),
+			Total:     sinceMilli(start, end),
+		},
+	}
+	if len(bs) > maxResponseBodySize {
+		bs = bs[:maxResponseBodySize]
+		res.Size = int64(len(bs))
+		res.Truncated = true
+	}
+	if utf8.Valid(bs) {
+		res.Body = string(bs)
+	} else {
+		res.BodyBase64 = base64.StdEncoding.EncodeToString(bs)
+	}
+	for _, cookie := range response.Cookies() {
+		res.Cookies = append(res.Cookies, &CookieInfo{Url: response.Request.URL.String(), Name: cookie.Name, Value: cookie.Value})
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package module_http
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGetFilesFile(t *testing.T) {
+	filesDir := "/data/files/"
+	for _, one := range []struct {
+		path   string
+		expect string
+	}{
+		{"upload/a.txt", "/data/files/upload/a.txt"},
+		{"/upload/a.txt", "/data/files/upload/a.txt"},
+		{"upload/../b.txt", "/data/files/b.txt"},
+		{"..a/b.txt", "/data/files/..a/b.txt"},
+		{"", ""},
+		{".", ""},
+		{"..", ""},
+		{"../a.txt", ""},
+		{"/upload/../../etc/passwd", ""},
+	} {
+		res, err := getFilesFile(filesDir, one.path)
+		if one.expect == "" {
+			if err == nil {
+				t.Errorf("path %s expect error, got %s", one.path, res)
+			}
+			continue
+		}
+		if err != nil || res != filepath.FromSlash(one.expect) {
+			t.Errorf("path %s expect %s, got %s %v", one.path, one.expect, res, err)
+		}
+	}
+}
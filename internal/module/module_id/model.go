@@ -63,4 +63,14 @@ const (
 	IDTypeTerminalLog = 8001
 	// IDTypeTerminalCommand 控制台命令
 	IDTypeTerminalCommand = 8002
+
+	// IDTypeKafkaLag Kafka 消费 延迟 采样
+	IDTypeKafkaLag = 9001
+	// IDTypeKafkaLagAlert Kafka 消费 延迟 告警
+	IDTypeKafkaLagAlert = 9002
+
+	// IDTypeElasticsearchDiagnose ES 诊断 报告
+	IDTypeElasticsearchDiagnose = 9101
+	// IDTypeElasticsearchDiagnoseSchedule ES 诊断 定时
+	IDTypeElasticsearchDiagnoseSchedule = 9102
 )
@@ -0,0 +1,800 @@
+package module_kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/Shopify/sarama"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/kafka"
+	"os"
+	"sort"
+	"teamide/pkg/base"
+)
+
+// AdminConfig 管理 接口 的 连接 配置，Version 为 Kafka 版本 如 2.8.0，为空 时 根据 broker 支持 的 接口 推断
+// sarama 默认 按 1.0.0 协议 请求，IncrementalAlterConfigs 需要 2.3.0，ClientQuotas 需要 2.6.0，ACL 的 patternType 需要 2.0.0
+type AdminConfig struct {
+	*kafka.Config
+	Version string `json:"version"`
+}
+
+func (this_ *api) getAdminConfig(requestBean *base.RequestBean, c *gin.Context) (config *AdminConfig, err error) {
+	kafkaConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	versionConfig := &struct {
+		Version string `json:"version"`
+	}{}
+	_, err = this_.toolboxService.BindConfig(requestBean, c, versionConfig)
+	if err != nil {
+		return
+	}
+	config = &AdminConfig{Config: kafkaConfig, Version: versionConfig.Version}
+	return
+}
+
+// apiKeyVersions 按 从 高 到 低 的 顺序，broker 支持 某个 接口 即 说明 版本 不 低于 对应 版本
+var apiKeyVersions = []struct {
+	apiKey     int16
+	minVersion int16
+	version    sarama.KafkaVersion
+}{
+	{60, 0, sarama.V2_8_0_0},  // DescribeCluster
+	{50, 0, sarama.V2_7_0_0},  // DescribeUserScramCredentials
+	{48, 0, sarama.V2_6_0_0},  // DescribeClientQuotas
+	{45, 0, sarama.V2_4_0_0},  // AlterPartitionReassignments
+	{44, 0, sarama.V2_3_0_0},  // IncrementalAlterConfigs
+	{42, 0, sarama.V1_1_0_0},  // DeleteGroups
+	{29, 1, sarama.V2_0_0_0},  // DescribeAcls v1
+	{37, 0, sarama.V1_0_0_0},  // CreatePartitions
+	{32, 0, sarama.V0_11_0_0}, // DescribeConfigs
+}
+
+// kafkaVersionByApiKeys 根据 ApiVersions 响应 推断 Kafka 版本
+func kafkaVersionByApiKeys(apiKeys []sarama.ApiVersionsResponseKey) sarama.KafkaVersion {
+	maxVersions := map[int16]int16{}
+	for _, one := range apiKeys {
+		maxVersions[one.ApiKey] = one.MaxVersion
+	}
+	var res = sarama.V0_10_0_0
+	for _, one := range apiKeyVersions {
+		if maxVersion, ok := maxVersions[one.apiKey]; ok && maxVersion >= one.minVersion && one.version.IsAtLeast(res) {
+			res = one.version
+		}
+	}
+	return res
+}
+
+// newSaramaConfig 与 kafka.Service 相同 的 认证 配置，并 设置 协议 版本
+func newSaramaConfig(config *AdminConfig) (saramaConfig *sarama.Config, err error) {
+	saramaConfig = sarama.NewConfig()
+	if config.Username != "" || config.Password != "" {
+		saramaConfig.Net.SASL.Enable = true
+		saramaConfig.Net.SASL.User = config.Username
+		saramaConfig.Net.SASL.Password = config.Password
+	}
+	if config.CertPath != "" {
+		certPool := x509.NewCertPool()
+		var pemCerts []byte
+		if pemCerts, err = os.ReadFile(config.CertPath); err != nil {
+			return
+		}
+		if !certPool.AppendCertsFromPEM(pemCerts) {
+			err = errors.New("证书[" + config.CertPath + "]解析失败")
+			return
+		}
+		saramaConfig.Net.TLS.Enable = true
+		saramaConfig.Net.TLS.Config = &tls.Config{
+			InsecureSkipVerify: true,
+			RootCAs:            certPool,
+		}
+	}
+	if config.Version != "" {
+		if saramaConfig.Version, err = sarama.ParseKafkaVersion(config.Version); err != nil {
+			err = errors.New("kafka version [" + config.Version + "] error:" + err.Error())
+			return
+		}
+	}
+	return
+}
+
+// negotiateVersion 使用 默认 版本 连接，通过 ApiVersions 获取 broker 支持 的 接口 推断 版本
+func negotiateVersion(servers []string, saramaConfig *sarama.Config) (version sarama.KafkaVersion, err error) {
+	client, err := sarama.NewClient(servers, saramaConfig)
+	if err != nil {
+		return
+	}
+	defer func() { _ = client.Close() }()
+	broker, err := client.Controller()
+	if err != nil {
+		return
+	}
+	response, err := broker.ApiVersions(&sarama.ApiVersionsRequest{})
+	if err != nil {
+		return
+	}
+	if response.ErrorCode != int16(sarama.ErrNoError) {
+		err = sarama.KError(response.ErrorCode)
+		return
+	}
+	version = kafkaVersionByApiKeys(response.ApiKeys)
+	return
+}
+
+// withAdmin 创建 临时 ClusterAdmin 执行 操作，执行 完成 后 关闭
+func withAdmin(config *AdminConfig, do func(admin sarama.ClusterAdmin) error) (err error) {
+	saramaConfig, err := newSaramaConfig(config)
+	if err != nil {
+		return
+	}
+	servers := kafka.Service{Config: config.Config}
+	if config.Version == "" {
+		if saramaConfig.Version, err = negotiateVersion(servers.GetServers(), saramaConfig); err != nil {
+			return
+		}
+	}
+	client, err := sarama.NewClient(servers.GetServers(), saramaConfig)
+	if err != nil {
+		return
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return
+	}
+	// 关闭 admin 会 同时 关闭 client
+	defer func() { _ = admin.Close() }()
+
+	err = do(admin)
+	return
+}
+
+// AclModel 一条 ACL，枚举 字段 使用 文本 形式，如 topic、literal、read、allow
+type AclModel struct {
+	ResourceType   string `json:"resourceType"`
+	ResourceName   string `json:"resourceName"`
+	PatternType    string `json:"patternType"`
+	Principal      string `json:"principal"`
+	Host           string `json:"host"`
+	Operation      string `json:"operation"`
+	PermissionType string `json:"permissionType"`
+	Error          string `json:"error,omitempty"`
+}
+
+type AclRequest struct {
+	// 查询 和 删除 的 过滤 条件，字段 为空 表示 任意
+	AclModel
+	// 创建 的 ACL 列表
+	Acls         []*AclModel `json:"acls"`
+	ValidateOnly bool        `json:"validateOnly"`
+}
+
+// parseAclEnum 解析 ACL 枚举 文本，为空 时 使用 默认值
+func parseAclEnum(text string, defaultText string, value interface{ UnmarshalText([]byte) error }) (err error) {
+	if text == "" {
+		text = defaultText
+	}
+	err = value.UnmarshalText([]byte(text))
+	return
+}
+
+func stringPtr(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func (this_ *AclModel) toFilter() (filter sarama.AclFilter, err error) {
+	if err = parseAclEnum(this_.ResourceType, "any", &filter.ResourceType); err != nil {
+		return
+	}
+	if err = parseAclEnum(this_.PatternType, "any", &filter.ResourcePatternTypeFilter); err != nil {
+		return
+	}
+	if err = parseAclEnum(this_.Operation, "any", &filter.Operation); err != nil {
+		return
+	}
+	if err = parseAclEnum(this_.PermissionType, "any", &filter.PermissionType); err != nil {
+		return
+	}
+	filter.ResourceName = stringPtr(this_.ResourceName)
+	filter.Principal = stringPtr(this_.Principal)
+	filter.Host = stringPtr(this_.Host)
+	return
+}
+
+func (this_ *AclModel) toResourceAcls() (res *sarama.ResourceAcls, err error) {
+	if this_.ResourceType == "" || this_.Operation == "" || this_.PermissionType == "" {
+		err = errors.New("ACL resourceType、operation、permissionType 不能为空")
+		return
+	}
+	if this_.Principal == "" {
+		err = errors.New("ACL principal 不能为空")
+		return
+	}
+	res = &sarama.ResourceAcls{}
+	acl := &sarama.Acl{
+		Principal: this_.Principal,
+		Host:      this_.Host,
+	}
+	if acl.Host == "" {
+		acl.Host = "*"
+	}
+	if err = parseAclEnum(this_.ResourceType, "", &res.ResourceType); err != nil {
+		return
+	}
+	if err = parseAclEnum(this_.PatternType, "literal", &res.ResourcePatternType); err != nil {
+		return
+	}
+	if err = parseAclEnum(this_.Operation, "", &acl.Operation); err != nil {
+		return
+	}
+	if err = parseAclEnum(this_.PermissionType, "", &acl.PermissionType); err != nil {
+		return
+	}
+	if res.ResourceType == sarama.AclResourceUnknown || res.ResourceType == sarama.AclResourceAny {
+		err = errors.New("ACL resourceType [" + this_.ResourceType + "] 不支持")
+		return
+	}
+	if acl.Operation == sarama.AclOperationUnknown || acl.Operation == sarama.AclOperationAny {
+		err = errors.New("ACL operation [" + this_.Operation + "] 不支持")
+		return
+	}
+	if acl.PermissionType == sarama.AclPermissionUnknown || acl.PermissionType == sarama.AclPermissionAny {
+		err = errors.New("ACL permissionType [" + this_.PermissionType + "] 不支持")
+		return
+	}
+	res.ResourceName = this_.ResourceName
+	res.Acls = []*sarama.Acl{acl}
+	return
+}
+
+func newAclModel(resource sarama.Resource, acl sarama.Acl) *AclModel {
+	return &AclModel{
+		ResourceType:   resource.ResourceType.String(),
+		ResourceName:   resource.ResourceName,
+		PatternType:    resource.ResourcePatternType.String(),
+		Principal:      acl.Principal,
+		Host:           acl.Host,
+		Operation:      acl.Operation.String(),
+		PermissionType: acl.PermissionType.String(),
+	}
+}
+
+func (this_ *api) aclList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getAdminConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &AclRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	filter, err := request.toFilter()
+	if err != nil {
+		return
+	}
+
+	var list []*AclModel
+	err = withAdmin(config, func(admin sarama.ClusterAdmin) (err error) {
+		resourceAclsList, err := admin.ListAcls(filter)
+		if err != nil {
+			return
+		}
+		for _, resourceAcls := range resourceAclsList {
+			for _, acl := range resourceAcls.Acls {
+				list = append(list, newAclModel(resourceAcls.Resource, *acl))
+			}
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = list
+	return
+}
+
+func (this_ *api) aclCreate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getAdminConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &AclRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if len(request.Acls) == 0 {
+		err = errors.New("创建 ACL 列表 不能为空")
+		return
+	}
+	var resourceAclsList []*sarama.ResourceAcls
+	for _, one := range request.Acls {
+		var resourceAcls *sarama.ResourceAcls
+		if resourceAcls, err = one.toResourceAcls(); err != nil {
+			return
+		}
+		resourceAclsList = append(resourceAclsList, resourceAcls)
+	}
+
+	err = withAdmin(config, func(admin sarama.ClusterAdmin) error {
+		return admin.CreateACLs(resourceAclsList)
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// aclDelete 按 过滤 条件 删除 ACL，返回 匹配 的 ACL，validateOnly 时 只 返回 匹配 结果 不 删除
+func (this_ *api) aclDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getAdminConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &AclRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	filter, err := request.toFilter()
+	if err != nil {
+		return
+	}
+	if filter.ResourceType == sarama.AclResourceAny && filter.ResourceName == nil && filter.Principal == nil {
+		err = errors.New("删除 ACL 需要 指定 resourceType、resourceName 或 principal")
+		return
+	}
+
+	var list []*AclModel
+	err = withAdmin(config, func(admin sarama.ClusterAdmin) (err error) {
+		var matchingAcls []sarama.MatchingAcl
+		if request.ValidateOnly {
+			// 部分 版本 broker 不 支持 validateOnly 删除，这里 直接 查询 匹配 的 ACL 作为 预览
+			var resourceAclsList []sarama.ResourceAcls
+			if resourceAclsList, err = admin.ListAcls(filter); err != nil {
+				return
+			}
+			for _, resourceAcls := range resourceAclsList {
+				for _, acl := range resourceAcls.Acls {
+					matchingAcls = append(matchingAcls, sarama.MatchingAcl{Resource: resourceAcls.Resource, Acl: *acl})
+				}
+			}
+		} else if matchingAcls, err = admin.DeleteACL(filter, false); err != nil {
+			return
+		}
+		for _, matchingAcl := range matchingAcls {
+			one := newAclModel(matchingAcl.Resource, matchingAcl.Acl)
+			if matchingAcl.Err != sarama.ErrNoError {
+				one.Error = matchingAcl.Err.Error()
+				if matchingAcl.ErrMsg != nil {
+					one.Error += ": " + *matchingAcl.ErrMsg
+				}
+			}
+			list = append(list, one)
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = list
+	return
+}
+
+// ConfigEntryModel 配置 项
+type ConfigEntryModel struct {
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	ReadOnly  bool   `json:"readOnly"`
+	Default   bool   `json:"default"`
+	Sensitive bool   `json:"sensitive"`
+	Source    string `json:"source"`
+}
+
+// ConfigDiffModel 配置 变更 预览，action 为 set 或 delete，delete 表示 恢复 默认值
+type ConfigDiffModel struct {
+	Name        string `json:"name"`
+	Action      string `json:"action"`
+	OldValue    string `json:"oldValue"`
+	NewValue    string `json:"newValue"`
+	OldDefault  bool   `json:"oldDefault"`
+	OldSource   string `json:"oldSource"`
+	ReadOnly    bool   `json:"readOnly"`
+	Sensitive   bool   `json:"sensitive"`
+	Description string `json:"description"`
+}
+
+type ConfigRequest struct {
+	// topic 或 broker
+	ResourceType string `json:"resourceType"`
+	// topic 名称 或 broker id，broker id 为空 表示 集群 默认 配置
+	ResourceName string `json:"resourceName"`
+	// 只 返回 指定 的 配置 项
+	ConfigNames []string `json:"configNames"`
+	// 需要 修改 的 配置，值 为 null 表示 删除 动态 配置 恢复 默认值
+	Configs      map[string]*string `json:"configs"`
+	ValidateOnly bool               `json:"validateOnly"`
+}
+
+func (this_ *ConfigRequest) getResourceType() (res sarama.ConfigResourceType, err error) {
+	switch this_.ResourceType {
+	case "topic", "":
+		res = sarama.TopicResource
+		if this_.ResourceName == "" {
+			err = errors.New("topic 名称 不能为空")
+		}
+	case "broker":
+		res = sarama.BrokerResource
+	default:
+		err = errors.New("配置 资源 类型 [" + this_.ResourceType + "] 不支持")
+	}
+	return
+}
+
+func describeConfig(admin sarama.ClusterAdmin, resourceType sarama.ConfigResourceType, name string, configNames []string) (res []*ConfigEntryModel, err error) {
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{
+		Type:        resourceType,
+		Name:        name,
+		ConfigNames: configNames,
+	})
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		res = append(res, &ConfigEntryModel{
+			Name:      entry.Name,
+			Value:     entry.Value,
+			ReadOnly:  entry.ReadOnly,
+			Default:   entry.Default || entry.Source == sarama.SourceDefault || entry.Source == sarama.SourceStaticBroker,
+			Sensitive: entry.Sensitive,
+			Source:    entry.Source.String(),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return
+}
+
+// diffConfig 对比 当前 配置 和 需要 修改 的 配置，值 未 变化 的 配置 不 返回
+func diffConfig(current []*ConfigEntryModel, configs map[string]*string) (res []*ConfigDiffModel) {
+	currentMap := map[string]*ConfigEntryModel{}
+	for _, one := range current {
+		currentMap[one.Name] = one
+	}
+	var names []string
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := configs[name]
+		diff := &ConfigDiffModel{
+			Name: name,
+		}
+		old := currentMap[name]
+		if old != nil {
+			diff.OldValue = old.Value
+			diff.OldDefault = old.Default
+			diff.OldSource = old.Source
+			diff.ReadOnly = old.ReadOnly
+			diff.Sensitive = old.Sensitive
+		}
+		if value == nil {
+			if old == nil || old.Default {
+				continue
+			}
+			diff.Action = "delete"
+			diff.Description = "删除 动态 配置，恢复 默认值"
+		} else {
+			// 敏感 配置 无法 读取 当前 值，始终 视为 修改
+			if old != nil && !old.Sensitive && old.Value == *value {
+				continue
+			}
+			diff.Action = "set"
+			diff.NewValue = *value
+			if old == nil {
+				diff.Description = "新增 配置"
+			} else if old.Default {
+				diff.Description = "覆盖 默认值"
+			} else {
+				diff.Description = "修改 配置"
+			}
+		}
+		res = append(res, diff)
+	}
+	return
+}
+
+func (this_ *api) configDescribe(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getAdminConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &ConfigRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	resourceType, err := request.getResourceType()
+	if err != nil {
+		return
+	}
+
+	err = withAdmin(config, func(admin sarama.ClusterAdmin) (err error) {
+		res, err = describeConfig(admin, resourceType, request.ResourceName, request.ConfigNames)
+		return
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// configAlter 修改 配置，先 对比 当前 配置 生成 变更 列表，只 提交 有 变化 的 配置 项
+// diff 为 true 时 只 返回 变更 预览，由 broker 校验 但 不 修改
+func (this_ *api) configAlterOrDiff(requestBean *base.RequestBean, c *gin.Context, diff bool) (res interface{}, err error) {
+	config, err := this_.getAdminConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &ConfigRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	resourceType, err := request.getResourceType()
+	if err != nil {
+		return
+	}
+	if len(request.Configs) == 0 {
+		err = errors.New("修改 配置 不能为空")
+		return
+	}
+
+	data := map[string]interface{}{}
+	err = withAdmin(config, func(admin sarama.ClusterAdmin) (err error) {
+		current, err := describeConfig(admin, resourceType, request.ResourceName, nil)
+		if err != nil {
+			return
+		}
+		diffList := diffConfig(current, request.Configs)
+		data["diffList"] = diffList
+		if len(diffList) == 0 {
+			return
+		}
+		entries := map[string]sarama.IncrementalAlterConfigsEntry{}
+		for _, one := range diffList {
+			if one.ReadOnly {
+				err = errors.New("配置 [" + one.Name + "] 为 只读，不能 修改")
+				return
+			}
+			if one.Action == "delete" {
+				entries[one.Name] = sarama.IncrementalAlterConfigsEntry{Operation: sarama.IncrementalAlterConfigsOperationDelete}
+			} else {
+				value := one.NewValue
+				entries[one.Name] = sarama.IncrementalAlterConfigsEntry{Operation: sarama.IncrementalAlterConfigsOperationSet, Value: &value}
+			}
+		}
+		validateOnly := diff || request.ValidateOnly
+		alterErr := admin.IncrementalAlterConfig(resourceType, request.ResourceName, entries, validateOnly)
+		// 版本 不 支持 时 无法 校验，作为 接口 错误 返回
+		if diff && !errors.Is(alterErr, sarama.ErrUnsupportedVersion) {
+			// 预览 时 broker 校验 失败 不 作为 接口 错误，返回 给 页面 展示
+			if alterErr != nil {
+				data["validateError"] = alterErr.Error()
+			}
+			return
+		}
+		err = alterErr
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = data
+	return
+}
+
+func (this_ *api) configDiff(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.configAlterOrDiff(requestBean, c, true)
+}
+
+func (this_ *api) configAlter(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.configAlterOrDiff(requestBean, c, false)
+}
+
+// QuotaEntityModel 配额 实体，entityType 为 user、client-id、ip
+// default 为 true 表示 该 类型 的 默认 配额
+type QuotaEntityModel struct {
+	EntityType string `json:"entityType"`
+	Name       string `json:"name"`
+	Default    bool   `json:"default"`
+}
+
+type QuotaModel struct {
+	Entity []*QuotaEntityModel `json:"entity"`
+	Values map[string]float64  `json:"values"`
+}
+
+type QuotaRequest struct {
+	Entity []*QuotaEntityModel `json:"entity"`
+	// 查询 时 是否 只 返回 精确 匹配 entity 的 配额
+	Strict bool `json:"strict"`
+	// 需要 修改 的 配额，如 producer_byte_rate、consumer_byte_rate、request_percentage，值 为 null 表示 删除
+	Configs      map[string]*float64 `json:"configs"`
+	ValidateOnly bool                `json:"validateOnly"`
+}
+
+func getQuotaEntityType(entityType string) (res sarama.QuotaEntityType, err error) {
+	res = sarama.QuotaEntityType(entityType)
+	switch res {
+	case sarama.QuotaEntityUser, sarama.QuotaEntityClientID, sarama.QuotaEntityIP:
+	default:
+		err = errors.New("配额 实体 类型 [" + entityType + "] 不支持")
+	}
+	return
+}
+
+// toQuotaFilterComponents 生成 配额 查询 条件，不 指定 实体 时 查询 所有 类型 的 配额
+func toQuotaFilterComponents(entity []*QuotaEntityModel) (components []sarama.QuotaFilterComponent, err error) {
+	for _, one := range entity {
+		component := sarama.QuotaFilterComponent{}
+		if component.EntityType, err = getQuotaEntityType(one.EntityType); err != nil {
+			return
+		}
+		if one.Default {
+			component.MatchType = sarama.QuotaMatchDefault
+		} else if one.Name != "" {
+			component.MatchType = sarama.QuotaMatchExact
+			component.Match = one.Name
+		} else {
+			component.MatchType = sarama.QuotaMatchAny
+		}
+		components = append(components, component)
+	}
+	if len(components) == 0 {
+		for _, entityType := range []sarama.QuotaEntityType{sarama.QuotaEntityUser, sarama.QuotaEntityClientID, sarama.QuotaEntityIP} {
+			components = append(components, sarama.QuotaFilterComponent{EntityType: entityType, MatchType: sarama.QuotaMatchAny})
+		}
+	}
+	return
+}
+
+// toQuotaEntityComponents 生成 修改 配额 的 实体，非 默认 配额 必须 指定 名称
+func toQuotaEntityComponents(entity []*QuotaEntityModel) (res []sarama.QuotaEntityComponent, err error) {
+	for _, one := range entity {
+		component := sarama.QuotaEntityComponent{}
+		if component.EntityType, err = getQuotaEntityType(one.EntityType); err != nil {
+			return
+		}
+		if one.Default {
+			component.MatchType = sarama.QuotaMatchDefault
+		} else {
+			if one.Name == "" {
+				err = errors.New("配额 实体 [" + one.EntityType + "] 名称 不能为空")
+				return
+			}
+			component.MatchType = sarama.QuotaMatchExact
+			component.Name = one.Name
+		}
+		res = append(res, component)
+	}
+	return
+}
+
+func (this_ *api) quotaList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getAdminConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &QuotaRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	components, err := toQuotaFilterComponents(request.Entity)
+	if err != nil {
+		return
+	}
+
+	var list []*QuotaModel
+	err = withAdmin(config, func(admin sarama.ClusterAdmin) (err error) {
+		var entries []sarama.DescribeClientQuotasEntry
+		if len(request.Entity) == 0 {
+			// 多个 any 条件 为 且 关系，需要 按 类型 分别 查询
+			for _, component := range components {
+				var one []sarama.DescribeClientQuotasEntry
+				if one, err = admin.DescribeClientQuotas([]sarama.QuotaFilterComponent{component}, false); err != nil {
+					return
+				}
+				entries = append(entries, one...)
+			}
+		} else if entries, err = admin.DescribeClientQuotas(components, request.Strict); err != nil {
+			return
+		}
+		seen := map[string]bool{}
+		for _, entry := range entries {
+			one := &QuotaModel{
+				Values: entry.Values,
+			}
+			var key string
+			for _, component := range entry.Entity {
+				one.Entity = append(one.Entity, &QuotaEntityModel{
+					EntityType: string(component.EntityType),
+					Name:       component.Name,
+					Default:    component.MatchType == sarama.QuotaMatchDefault,
+				})
+				key += string(component.EntityType) + "=" + component.Name + ";"
+			}
+			// 组合 实体 会 在 多个 类型 的 查询 中 重复 返回
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			list = append(list, one)
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = list
+	return
+}
+
+func (this_ *api) quotaAlter(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getAdminConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &QuotaRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if len(request.Entity) == 0 {
+		err = errors.New("配额 实体 不能为空")
+		return
+	}
+	if len(request.Configs) == 0 {
+		err = errors.New("修改 配额 不能为空")
+		return
+	}
+	entity, err := toQuotaEntityComponents(request.Entity)
+	if err != nil {
+		return
+	}
+	var keys []string
+	for key := range request.Configs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	err = withAdmin(config, func(admin sarama.ClusterAdmin) (err error) {
+		for _, key := range keys {
+			value := request.Configs[key]
+			op := sarama.ClientQuotasOp{Key: key}
+			if value == nil {
+				op.Remove = true
+			} else {
+				op.Value = *value
+			}
+			if err = admin.AlterClientQuotas(entity, op, request.ValidateOnly); err != nil {
+				err = errors.New("配额 [" + key + "] 修改 失败: " + err.Error())
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,187 @@
+package module_kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/team-ide/go-tool/kafka"
+	"testing"
+)
+
+func TestAclToFilter(t *testing.T) {
+	filter, err := (&AclModel{}).toFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.ResourceType != sarama.AclResourceAny || filter.ResourcePatternTypeFilter != sarama.AclPatternAny ||
+		filter.Operation != sarama.AclOperationAny || filter.PermissionType != sarama.AclPermissionAny {
+		t.Errorf("empty filter expect any, got %+v", filter)
+	}
+	if filter.ResourceName != nil || filter.Principal != nil || filter.Host != nil {
+		t.Errorf("empty filter expect nil name principal host")
+	}
+
+	filter, err = (&AclModel{ResourceType: "topic", ResourceName: "t1", PatternType: "prefixed", Principal: "User:a", Operation: "read", PermissionType: "deny"}).toFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.ResourceType != sarama.AclResourceTopic || filter.ResourcePatternTypeFilter != sarama.AclPatternPrefixed ||
+		filter.Operation != sarama.AclOperationRead || filter.PermissionType != sarama.AclPermissionDeny ||
+		*filter.ResourceName != "t1" || *filter.Principal != "User:a" {
+		t.Errorf("filter error, got %+v", filter)
+	}
+
+	if _, err = (&AclModel{ResourceType: "unknown_type"}).toFilter(); err == nil {
+		t.Errorf("unknown resource type expect error")
+	}
+}
+
+func TestAclToResourceAcls(t *testing.T) {
+	res, err := (&AclModel{ResourceType: "topic", ResourceName: "t1", Principal: "User:a", Operation: "write", PermissionType: "allow"}).toResourceAcls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ResourceType != sarama.AclResourceTopic || res.ResourceName != "t1" || res.ResourcePatternType != sarama.AclPatternLiteral {
+		t.Errorf("resource error, got %+v", res.Resource)
+	}
+	if len(res.Acls) != 1 || res.Acls[0].Host != "*" || res.Acls[0].Operation != sarama.AclOperationWrite || res.Acls[0].PermissionType != sarama.AclPermissionAllow {
+		t.Errorf("acl error, got %+v", res.Acls)
+	}
+
+	for _, one := range []*AclModel{
+		{ResourceType: "topic", Operation: "read", PermissionType: "allow"},
+		{ResourceType: "topic", Principal: "User:a", PermissionType: "allow"},
+		{ResourceType: "any", Principal: "User:a", Operation: "read", PermissionType: "allow"},
+		{ResourceType: "topic", Principal: "User:a", Operation: "any", PermissionType: "allow"},
+		{ResourceType: "topic", Principal: "User:a", Operation: "read", PermissionType: "any"},
+	} {
+		if _, err = one.toResourceAcls(); err == nil {
+			t.Errorf("acl %+v expect error", one)
+		}
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	stringValue := func(value string) *string { return &value }
+	current := []*ConfigEntryModel{
+		{Name: "retention.ms", Value: "1000"},
+		{Name: "cleanup.policy", Value: "delete", Default: true},
+		{Name: "segment.ms", Value: "10"},
+		{Name: "sasl.jaas.config", Sensitive: true},
+	}
+	res := diffConfig(current, map[string]*string{
+		"retention.ms":      stringValue("1000"), // 未 变化
+		"cleanup.policy":    nil,                 // 已 是 默认值
+		"segment.ms":        nil,
+		"max.message.bytes": stringValue("100"),
+		"sasl.jaas.config":  stringValue("x"),
+	})
+	expects := []struct {
+		name   string
+		action string
+	}{
+		{"max.message.bytes", "set"},
+		{"sasl.jaas.config", "set"},
+		{"segment.ms", "delete"},
+	}
+	if len(res) != len(expects) {
+		t.Fatalf("diff expect %d, got %d", len(expects), len(res))
+	}
+	for i, one := range expects {
+		if res[i].Name != one.name || res[i].Action != one.action {
+			t.Errorf("diff %d expect %s %s, got %s %s", i, one.name, one.action, res[i].Name, res[i].Action)
+		}
+	}
+}
+
+func TestConfigRequestResourceType(t *testing.T) {
+	for _, one := range []struct {
+		request *ConfigRequest
+		expect  sarama.ConfigResourceType
+		error   bool
+	}{
+		{&ConfigRequest{ResourceName: "t1"}, sarama.TopicResource, false},
+		{&ConfigRequest{ResourceType: "topic"}, sarama.TopicResource, true},
+		{&ConfigRequest{ResourceType: "broker"}, sarama.BrokerResource, false},
+		{&ConfigRequest{ResourceType: "group"}, 0, true},
+	} {
+		res, err := one.request.getResourceType()
+		if (err != nil) != one.error || (!one.error && res != one.expect) {
+			t.Errorf("resource type %+v expect %v error %v, got %v %v", one.request, one.expect, one.error, res, err)
+		}
+	}
+}
+
+func TestQuotaComponents(t *testing.T) {
+	components, err := toQuotaFilterComponents(nil)
+	if err != nil || len(components) != 3 {
+		t.Fatalf("empty entity expect 3 any components, got %v %v", components, err)
+	}
+	components, err = toQuotaFilterComponents([]*QuotaEntityModel{
+		{EntityType: "user", Name: "a"},
+		{EntityType: "client-id", Default: true},
+		{EntityType: "ip"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if components[0].MatchType != sarama.QuotaMatchExact || components[0].Match != "a" ||
+		components[1].MatchType != sarama.QuotaMatchDefault || components[2].MatchType != sarama.QuotaMatchAny {
+		t.Errorf("filter components error, got %+v", components)
+	}
+	if _, err = toQuotaFilterComponents([]*QuotaEntityModel{{EntityType: "group"}}); err == nil {
+		t.Errorf("unknown entity type expect error")
+	}
+
+	entity, err := toQuotaEntityComponents([]*QuotaEntityModel{{EntityType: "user", Name: "a"}, {EntityType: "client-id", Default: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entity[0].MatchType != sarama.QuotaMatchExact || entity[0].Name != "a" || entity[1].MatchType != sarama.QuotaMatchDefault {
+		t.Errorf("entity components error, got %+v", entity)
+	}
+	if _, err = toQuotaEntityComponents([]*QuotaEntityModel{{EntityType: "user"}}); err == nil {
+		t.Errorf("entity without name expect error")
+	}
+}
+
+func TestKafkaVersionByApiKeys(t *testing.T) {
+	keys := func(apiKeys ...int16) (res []sarama.ApiVersionsResponseKey) {
+		for _, apiKey := range apiKeys {
+			res = append(res, sarama.ApiVersionsResponseKey{ApiKey: apiKey, MaxVersion: 1})
+		}
+		return
+	}
+	for _, one := range []struct {
+		apiKeys []sarama.ApiVersionsResponseKey
+		expect  sarama.KafkaVersion
+	}{
+		{nil, sarama.V0_10_0_0},
+		{keys(32), sarama.V0_11_0_0},
+		{keys(32, 37), sarama.V1_0_0_0},
+		{keys(29, 37, 42), sarama.V2_0_0_0},
+		{keys(29, 37, 42, 44), sarama.V2_3_0_0},
+		{keys(29, 37, 42, 44, 45, 48), sarama.V2_6_0_0},
+		{keys(29, 37, 42, 44, 45, 48, 50, 60), sarama.V2_8_0_0},
+		// DescribeAcls 只 支持 v0
+		{[]sarama.ApiVersionsResponseKey{{ApiKey: 29, MaxVersion: 0}, {ApiKey: 42}}, sarama.V1_1_0_0},
+	} {
+		if res := kafkaVersionByApiKeys(one.apiKeys); res != one.expect {
+			t.Errorf("api keys %v expect %s, got %s", one.apiKeys, one.expect, res)
+		}
+	}
+}
+
+func TestNewSaramaConfig(t *testing.T) {
+	saramaConfig, err := newSaramaConfig(&AdminConfig{Config: &kafka.Config{Username: "u", Password: "p"}, Version: "2.6.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saramaConfig.Version != sarama.V2_6_0_0 || !saramaConfig.Net.SASL.Enable || saramaConfig.Net.SASL.User != "u" {
+		t.Errorf("sarama config error, got version %s sasl %v", saramaConfig.Version, saramaConfig.Net.SASL.Enable)
+	}
+	if _, err = newSaramaConfig(&AdminConfig{Config: &kafka.Config{}, Version: "abc"}); err == nil {
+		t.Errorf("invalid version expect error")
+	}
+	if _, err = newSaramaConfig(&AdminConfig{Config: &kafka.Config{CertPath: "/not/exist.pem"}}); err == nil {
+		t.Errorf("missing cert expect error")
+	}
+}
@@ -11,11 +11,13 @@ import (
 
 type api struct {
 	toolboxService *module_toolbox.ToolboxService
+	lagService     *LagService
 }
 
-func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+func NewApi(toolboxService *module_toolbox.ToolboxService, lagService *LagService) *api {
 	return &api{
 		toolboxService: toolboxService,
+		lagService:     lagService,
 	}
 }
 
@@ -42,6 +44,33 @@ var (
 	groupDeleteOffsets = base.AppendPower(&base.PowerAction{Action: "deleteOffsets", Text: "删除组Offsets", ShouldLogin: true, StandAlone: true, Parent: group})
 	groupDelete        = base.AppendPower(&base.PowerAction{Action: "delete", Text: "删除组", ShouldLogin: true, StandAlone: true, Parent: group})
 
+	tailKey       = base.AppendPower(&base.PowerAction{Action: "tail/key", Text: "Kafka实时消息Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	tailWebsocket = base.AppendPower(&base.PowerAction{Action: "tail/websocket", Text: "Kafka实时消息WebSocket", ShouldLogin: true, StandAlone: true, Parent: Power})
+	tailClose     = base.AppendPower(&base.PowerAction{Action: "tail/close", Text: "Kafka实时消息关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	lagPower       = base.AppendPower(&base.PowerAction{Action: "lag", Text: "Kafka消费延迟", ShouldLogin: true, StandAlone: true, Parent: Power})
+	lagHistory     = base.AppendPower(&base.PowerAction{Action: "lag/history", Text: "Kafka消费延迟历史", ShouldLogin: true, StandAlone: true, Parent: Power})
+	lagAlertList   = base.AppendPower(&base.PowerAction{Action: "lag/alert/list", Text: "Kafka消费延迟告警列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	lagAlertSave   = base.AppendPower(&base.PowerAction{Action: "lag/alert/save", Text: "Kafka消费延迟告警保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	lagAlertDelete = base.AppendPower(&base.PowerAction{Action: "lag/alert/delete", Text: "Kafka消费延迟告警删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	replayStart  = base.AppendPower(&base.PowerAction{Action: "replay/start", Text: "Kafka消息重放", ShouldLogin: true, StandAlone: true, Parent: Power})
+	replayStatus = base.AppendPower(&base.PowerAction{Action: "replay/status", Text: "Kafka消息重放状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+	replayStop   = base.AppendPower(&base.PowerAction{Action: "replay/stop", Text: "Kafka消息重放停止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	replayClean  = base.AppendPower(&base.PowerAction{Action: "replay/clean", Text: "Kafka消息重放清理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	replayList   = base.AppendPower(&base.PowerAction{Action: "replay/list", Text: "Kafka消息重放列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	aclList   = base.AppendPower(&base.PowerAction{Action: "acl/list", Text: "Kafka ACL查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	aclCreate = base.AppendPower(&base.PowerAction{Action: "acl/create", Text: "Kafka ACL创建", ShouldLogin: true, StandAlone: true, Parent: Power})
+	aclDelete = base.AppendPower(&base.PowerAction{Action: "acl/delete", Text: "Kafka ACL删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	configDescribe = base.AppendPower(&base.PowerAction{Action: "config/describe", Text: "Kafka配置查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	configDiff     = base.AppendPower(&base.PowerAction{Action: "config/diff", Text: "Kafka配置变更预览", ShouldLogin: true, StandAlone: true, Parent: Power})
+	configAlter    = base.AppendPower(&base.PowerAction{Action: "config/alter", Text: "Kafka配置修改", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	quotaList  = base.AppendPower(&base.PowerAction{Action: "quota/list", Text: "Kafka客户端配额查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	quotaAlter = base.AppendPower(&base.PowerAction{Action: "quota/alter", Text: "Kafka客户端配额修改", ShouldLogin: true, StandAlone: true, Parent: Power})
+
 	closePower = base.AppendPower(&base.PowerAction{Action: "close", Text: "Kafka关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
@@ -66,6 +95,33 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: groupDeleteOffsets, Do: this_.groupDeleteOffsets})
 	apis = append(apis, &base.ApiWorker{Power: groupDelete, Do: this_.groupDelete})
 
+	apis = append(apis, &base.ApiWorker{Power: tailKey, Do: this_.tailKey})
+	apis = append(apis, &base.ApiWorker{Power: tailWebsocket, Do: tailerCache.Websocket, IsWebSocket: true})
+	apis = append(apis, &base.ApiWorker{Power: tailClose, Do: tailerCache.Close})
+
+	apis = append(apis, &base.ApiWorker{Power: lagPower, Do: this_.lag})
+	apis = append(apis, &base.ApiWorker{Power: lagHistory, Do: this_.lagHistory})
+	apis = append(apis, &base.ApiWorker{Power: lagAlertList, Do: this_.lagAlertList})
+	apis = append(apis, &base.ApiWorker{Power: lagAlertSave, Do: this_.lagAlertSave})
+	apis = append(apis, &base.ApiWorker{Power: lagAlertDelete, Do: this_.lagAlertDelete})
+
+	apis = append(apis, &base.ApiWorker{Power: replayStart, Do: this_.replayStart})
+	apis = append(apis, &base.ApiWorker{Power: replayStatus, Do: this_.replayStatus, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: replayStop, Do: this_.replayStop})
+	apis = append(apis, &base.ApiWorker{Power: replayClean, Do: this_.replayClean})
+	apis = append(apis, &base.ApiWorker{Power: replayList, Do: this_.replayList, NotRecodeLog: true})
+
+	apis = append(apis, &base.ApiWorker{Power: aclList, Do: this_.aclList})
+	apis = append(apis, &base.ApiWorker{Power: aclCreate, Do: this_.aclCreate})
+	apis = append(apis, &base.ApiWorker{Power: aclDelete, Do: this_.aclDelete})
+
+	apis = append(apis, &base.ApiWorker{Power: configDescribe, Do: this_.configDescribe})
+	apis = append(apis, &base.ApiWorker{Power: configDiff, Do: this_.configDiff})
+	apis = append(apis, &base.ApiWorker{Power: configAlter, Do: this_.configAlter})
+
+	apis = append(apis, &base.ApiWorker{Power: quotaList, Do: this_.quotaList})
+	apis = append(apis, &base.ApiWorker{Power: quotaAlter, Do: this_.quotaAlter})
+
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
 
 	return
@@ -142,6 +198,11 @@ type BaseRequest struct {
 	Count     int32  `json:"count"`
 	KeyType   string `json:"keyType"`
 	ValueType string `json:"valueType"`
+
+	KeyMessageName   string `json:"keyMessageName"`   // protobuf 类型 时 的 消息 名称
+	ValueMessageName string `json:"valueMessageName"` // protobuf 类型 时 的 消息 名称
+
+	WorkerId string `json:"workerId"`
 }
 
 func (this_ *api) check(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
@@ -277,13 +338,42 @@ func (this_ *api) pull(requestBean *base.RequestBean, c *gin.Context) (res inter
 		return
 	}
 
-	res, err = service.Pull(request.GroupId, []string{request.Topic}, request.PullSize, request.PullTimeout, request.KeyType, request.ValueType)
+	if !isSchemaDataType(request.KeyType) && !isSchemaDataType(request.ValueType) {
+		res, err = service.Pull(request.GroupId, []string{request.Topic}, request.PullSize, request.PullTimeout, request.KeyType, request.ValueType)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	schemaConfig, err := this_.getSchemaConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	codec := getSchemaCodec(schemaConfig)
+	messages, err := pullRaw(service, request.GroupId, request.Topic, request.PullSize, request.PullTimeout)
 	if err != nil {
 		return
 	}
+	var msgList []*kafka.Message
+	for _, one := range messages {
+		var msg *kafka.Message
+		msg, err = consumerMessageToMessage(codec, request.KeyType, request.KeyMessageName, request.ValueType, request.ValueMessageName, one)
+		if err != nil {
+			return
+		}
+		msgList = append(msgList, msg)
+	}
+	res = msgList
 	return
 }
 
+type PushRequest struct {
+	kafka.Message
+	KeyMessageName   string `json:"keyMessageName"`
+	ValueMessageName string `json:"valueMessageName"`
+}
+
 func (this_ *api) push(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	config, err := this_.getConfig(requestBean, c)
 	if err != nil {
@@ -294,14 +384,37 @@ func (this_ *api) push(requestBean *base.RequestBean, c *gin.Context) (res inter
 		return
 	}
 
-	request := &kafka.Message{}
+	request := &PushRequest{}
 	if !base.RequestJSON(request, c) {
 		return
 	}
 
-	err = service.Push(request)
+	if !isSchemaDataType(request.KeyType) && !isSchemaDataType(request.ValueType) {
+		err = service.Push(&request.Message)
+		if err != nil {
+			return nil, err
+		}
+		return
+	}
+
+	schemaConfig, err := this_.getSchemaConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	producerMessage, err := pushMessageToProducerMessage(getSchemaCodec(schemaConfig), request)
+	if err != nil {
+		return
+	}
+	syncProducer, err := service.NewSyncProducer()
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = syncProducer.Close()
+	}()
+	_, _, err = syncProducer.SendMessage(producerMessage)
 	if err != nil {
-		return nil, err
+		return
 	}
 	return
 }
@@ -535,5 +648,11 @@ func (this_ *api) groupDescribe(requestBean *base.RequestBean, c *gin.Context) (
 
 func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	tailerCache.RemoveWorker(request.WorkerId)
+	removeWorkerReplayTasks(request.WorkerId)
 	return
 }
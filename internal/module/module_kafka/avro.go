@@ -0,0 +1,622 @@
+package module_kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// avroSchema Avro 模式 节点，只 保留 编解码 需要 的 信息
+type avroSchema struct {
+	Type      string // null boolean int long float double bytes string record enum array map fixed union
+	Name      string // 命名 类型 的 全名
+	Fields    []*avroField
+	Symbols   []string
+	Items     *avroSchema
+	Values    *avroSchema
+	Size      int
+	Branches  []*avroSchema
+	reference string // 引用 未 解析 的 命名 类型
+}
+
+type avroField struct {
+	Name    string
+	Schema  *avroSchema
+	Default interface{}
+	HasDef  bool
+}
+
+type avroParser struct {
+	names map[string]*avroSchema
+}
+
+// parseAvroSchema 解析 Avro 模式 JSON
+func parseAvroSchema(text string) (schema *avroSchema, err error) {
+	var data interface{}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if err = decoder.Decode(&data); err != nil {
+		err = errors.New("avro schema parse error:" + err.Error())
+		return
+	}
+	parser := &avroParser{names: map[string]*avroSchema{}}
+	schema, err = parser.parse(data, "")
+	if err != nil {
+		return
+	}
+	err = parser.resolve(schema, map[*avroSchema]bool{})
+	return
+}
+
+func avroFullName(name string, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func (this_ *avroParser) parse(data interface{}, namespace string) (schema *avroSchema, err error) {
+	switch v := data.(type) {
+	case string:
+		switch v {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			schema = &avroSchema{Type: v}
+		default:
+			schema = &avroSchema{reference: avroFullName(v, namespace)}
+			if find, ok := this_.names[schema.reference]; ok {
+				schema = find
+			} else if find, ok = this_.names[v]; ok {
+				schema = find
+			}
+		}
+	case []interface{}:
+		schema = &avroSchema{Type: "union"}
+		for _, one := range v {
+			var branch *avroSchema
+			if branch, err = this_.parse(one, namespace); err != nil {
+				return
+			}
+			schema.Branches = append(schema.Branches, branch)
+		}
+	case map[string]interface{}:
+		typeName, _ := v["type"].(string)
+		if typeName == "" {
+			// {"type": {...}} 或 {"type": [...]}
+			if v["type"] == nil {
+				err = errors.New("avro schema type is empty")
+				return
+			}
+			return this_.parse(v["type"], namespace)
+		}
+		switch typeName {
+		case "record", "error", "enum", "fixed":
+			name, _ := v["name"].(string)
+			if ns, ok := v["namespace"].(string); ok && ns != "" {
+				namespace = ns
+			}
+			fullName := avroFullName(name, namespace)
+			if i := strings.LastIndex(fullName, "."); i > 0 {
+				namespace = fullName[:i]
+			}
+			schema = &avroSchema{Type: typeName, Name: fullName}
+			if typeName == "error" {
+				schema.Type = "record"
+			}
+			this_.names[fullName] = schema
+			switch schema.Type {
+			case "record":
+				fields, _ := v["fields"].([]interface{})
+				for _, one := range fields {
+					fieldData, ok := one.(map[string]interface{})
+					if !ok {
+						err = errors.New("avro record [" + fullName + "] field is not object")
+						return
+					}
+					field := &avroField{}
+					field.Name, _ = fieldData["name"].(string)
+					if field.Schema, err = this_.parse(fieldData["type"], namespace); err != nil {
+						return
+					}
+					field.Default, field.HasDef = fieldData["default"]
+					schema.Fields = append(schema.Fields, field)
+				}
+			case "enum":
+				symbols, _ := v["symbols"].([]interface{})
+				for _, one := range symbols {
+					schema.Symbols = append(schema.Symbols, fmt.Sprint(one))
+				}
+			case "fixed":
+				size, _ := v["size"].(json.Number).Int64()
+				schema.Size = int(size)
+			}
+		case "array":
+			schema = &avroSchema{Type: typeName}
+			if schema.Items, err = this_.parse(v["items"], namespace); err != nil {
+				return
+			}
+		case "map":
+			schema = &avroSchema{Type: typeName}
+			if schema.Values, err = this_.parse(v["values"], namespace); err != nil {
+				return
+			}
+		default:
+			// 带 logicalType 的 基础 类型 按 基础 类型 处理
+			return this_.parse(typeName, namespace)
+		}
+	default:
+		err = fmt.Errorf("avro schema [%v] not support", data)
+	}
+	return
+}
+
+// resolve 处理 先 使用 后 定义 的 命名 类型 引用
+func (this_ *avroParser) resolve(schema *avroSchema, visited map[*avroSchema]bool) (err error) {
+	if schema == nil || visited[schema] {
+		return
+	}
+	visited[schema] = true
+	fix := func(one **avroSchema) error {
+		if (*one).reference == "" {
+			return this_.resolve(*one, visited)
+		}
+		find, ok := this_.names[(*one).reference]
+		if !ok {
+			return errors.New("avro schema type [" + (*one).reference + "] not defined")
+		}
+		*one = find
+		return nil
+	}
+	for _, field := range schema.Fields {
+		if err = fix(&field.Schema); err != nil {
+			return
+		}
+	}
+	for i := range schema.Branches {
+		if err = fix(&schema.Branches[i]); err != nil {
+			return
+		}
+	}
+	if schema.Items != nil {
+		if err = fix(&schema.Items); err != nil {
+			return
+		}
+	}
+	if schema.Values != nil {
+		if err = fix(&schema.Values); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// branchName 联合 类型 中 分支 的 名称，用于 JSON 编码 的 包装
+func (this_ *avroSchema) branchName() string {
+	if this_.Name != "" {
+		return this_.Name
+	}
+	return this_.Type
+}
+
+type avroReader struct {
+	*bytes.Reader
+}
+
+func (this_ *avroReader) readLong() (int64, error) {
+	return binary.ReadVarint(this_)
+}
+
+func (this_ *avroReader) readBytes() ([]byte, error) {
+	size, err := this_.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 || size > int64(this_.Len()) {
+		return nil, errors.New("avro bytes size invalid")
+	}
+	bs := make([]byte, size)
+	_, err = io.ReadFull(this_, bs)
+	return bs, err
+}
+
+// avroDecode 解码 Avro 二进制 数据 为 JSON 字符串
+func avroDecode(schema *avroSchema, data []byte) (res string, err error) {
+	reader := &avroReader{Reader: bytes.NewReader(data)}
+	value, err := avroRead(schema, reader)
+	if err != nil {
+		return
+	}
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	res = string(bs)
+	return
+}
+
+// bytesToString 按 Avro JSON 编码 规范 每个 字节 对应 一个 字符
+func bytesToString(bs []byte) string {
+	runes := make([]rune, len(bs))
+	for i, b := range bs {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func stringToBytes(str string) []byte {
+	var bs []byte
+	for _, r := range str {
+		bs = append(bs, byte(r))
+	}
+	return bs
+}
+
+func avroRead(schema *avroSchema, reader *avroReader) (value interface{}, err error) {
+	switch schema.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		var b byte
+		if b, err = reader.ReadByte(); err != nil {
+			return
+		}
+		return b != 0, nil
+	case "int", "long":
+		return reader.readLong()
+	case "float":
+		bs := make([]byte, 4)
+		if _, err = io.ReadFull(reader, bs); err != nil {
+			return
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(bs)), nil
+	case "double":
+		bs := make([]byte, 8)
+		if _, err = io.ReadFull(reader, bs); err != nil {
+			return
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(bs)), nil
+	case "bytes":
+		var bs []byte
+		if bs, err = reader.readBytes(); err != nil {
+			return
+		}
+		return bytesToString(bs), nil
+	case "string":
+		var bs []byte
+		if bs, err = reader.readBytes(); err != nil {
+			return
+		}
+		return string(bs), nil
+	case "fixed":
+		bs := make([]byte, schema.Size)
+		if _, err = io.ReadFull(reader, bs); err != nil {
+			return
+		}
+		return bytesToString(bs), nil
+	case "enum":
+		var index int64
+		if index, err = reader.readLong(); err != nil {
+			return
+		}
+		if index < 0 || int(index) >= len(schema.Symbols) {
+			return nil, fmt.Errorf("avro enum [%s] index [%d] invalid", schema.Name, index)
+		}
+		return schema.Symbols[index], nil
+	case "union":
+		var index int64
+		if index, err = reader.readLong(); err != nil {
+			return
+		}
+		if index < 0 || int(index) >= len(schema.Branches) {
+			return nil, fmt.Errorf("avro union index [%d] invalid", index)
+		}
+		// 解码 结果 不 包装 分支 名称，便于 阅读 和 JSONPath 过滤
+		return avroRead(schema.Branches[index], reader)
+	case "record":
+		record := map[string]interface{}{}
+		for _, field := range schema.Fields {
+			if record[field.Name], err = avroRead(field.Schema, reader); err != nil {
+				return
+			}
+		}
+		return record, nil
+	case "array", "map":
+		var list []interface{}
+		var object = map[string]interface{}{}
+		for {
+			var count int64
+			if count, err = reader.readLong(); err != nil {
+				return
+			}
+			if count == 0 {
+				break
+			}
+			if count < 0 {
+				count = -count
+				// 负数 时 后面 跟着 块 的 字节 数
+				if _, err = reader.readLong(); err != nil {
+					return
+				}
+			}
+			for i := int64(0); i < count; i++ {
+				var one interface{}
+				if schema.Type == "array" {
+					if one, err = avroRead(schema.Items, reader); err != nil {
+						return
+					}
+					list = append(list, one)
+					continue
+				}
+				var key []byte
+				if key, err = reader.readBytes(); err != nil {
+					return
+				}
+				if one, err = avroRead(schema.Values, reader); err != nil {
+					return
+				}
+				object[string(key)] = one
+			}
+		}
+		if schema.Type == "array" {
+			if list == nil {
+				list = []interface{}{}
+			}
+			return list, nil
+		}
+		return object, nil
+	}
+	return nil, errors.New("avro type [" + schema.Type + "] not support")
+}
+
+// avroEncode 将 JSON 字符串 编码 为 Avro 二进制 数据
+func avroEncode(schema *avroSchema, text string) (res []byte, err error) {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if err = decoder.Decode(&value); err != nil {
+		// 非 JSON 内容 按 字符串 处理
+		value = text
+		err = nil
+	}
+	buf := &bytes.Buffer{}
+	if err = avroWrite(schema, value, buf); err != nil {
+		return
+	}
+	res = buf.Bytes()
+	return
+}
+
+func writeLong(buf *bytes.Buffer, v int64) {
+	bs := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(bs, v)
+	buf.Write(bs[:n])
+}
+
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		return int64(f), err
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case float64:
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("value [%v] is not number", value)
+}
+
+func toFloat64(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case float64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("value [%v] is not number", value)
+}
+
+// avroMatch 判断 值 是否 可以 按 分支 编码，用于 未 包装 的 联合 类型
+func avroMatch(schema *avroSchema, value interface{}) bool {
+	switch schema.Type {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "int", "long":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "float", "double":
+		_, ok := value.(json.Number)
+		return ok
+	case "string", "bytes":
+		_, ok := value.(string)
+		return ok
+	case "fixed":
+		str, ok := value.(string)
+		return ok && len(stringToBytes(str)) == schema.Size
+	case "enum":
+		str, ok := value.(string)
+		if !ok {
+			return false
+		}
+		for _, symbol := range schema.Symbols {
+			if symbol == str {
+				return true
+			}
+		}
+		return false
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "map":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "record":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for _, field := range schema.Fields {
+			if _, find := object[field.Name]; !find && !field.HasDef {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func avroWrite(schema *avroSchema, value interface{}, buf *bytes.Buffer) (err error) {
+	switch schema.Type {
+	case "null":
+		return
+	case "boolean":
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("value [%v] is not boolean", value)
+		}
+		if b {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case "int", "long":
+		var v int64
+		if v, err = toInt64(value); err != nil {
+			return
+		}
+		writeLong(buf, v)
+	case "float":
+		var v float64
+		if v, err = toFloat64(value); err != nil {
+			return
+		}
+		bs := make([]byte, 4)
+		binary.LittleEndian.PutUint32(bs, math.Float32bits(float32(v)))
+		buf.Write(bs)
+	case "double":
+		var v float64
+		if v, err = toFloat64(value); err != nil {
+			return
+		}
+		bs := make([]byte, 8)
+		binary.LittleEndian.PutUint64(bs, math.Float64bits(v))
+		buf.Write(bs)
+	case "bytes", "string":
+		str, ok := value.(string)
+		if !ok {
+			str = fmt.Sprint(value)
+		}
+		bs := []byte(str)
+		if schema.Type == "bytes" {
+			bs = stringToBytes(str)
+		}
+		writeLong(buf, int64(len(bs)))
+		buf.Write(bs)
+	case "fixed":
+		str, _ := value.(string)
+		bs := stringToBytes(str)
+		if len(bs) != schema.Size {
+			return fmt.Errorf("avro fixed [%s] size must be %d", schema.Name, schema.Size)
+		}
+		buf.Write(bs)
+	case "enum":
+		str, _ := value.(string)
+		for i, symbol := range schema.Symbols {
+			if symbol == str {
+				writeLong(buf, int64(i))
+				return
+			}
+		}
+		return fmt.Errorf("avro enum [%s] symbol [%v] not found", schema.Name, value)
+	case "union":
+		// 支持 {"分支名": 值} 的 包装 形式
+		if object, ok := value.(map[string]interface{}); ok && len(object) == 1 {
+			for name, one := range object {
+				for i, branch := range schema.Branches {
+					if branch.branchName() == name || strings.HasSuffix(branch.Name, "."+name) {
+						writeLong(buf, int64(i))
+						return avroWrite(branch, one, buf)
+					}
+				}
+			}
+		}
+		for i, branch := range schema.Branches {
+			if avroMatch(branch, value) {
+				writeLong(buf, int64(i))
+				return avroWrite(branch, value, buf)
+			}
+		}
+		return fmt.Errorf("avro union not match value [%v]", value)
+	case "record":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("avro record [%s] value must be object", schema.Name)
+		}
+		for _, field := range schema.Fields {
+			one, find := object[field.Name]
+			if !find {
+				if !field.HasDef {
+					return fmt.Errorf("avro record [%s] field [%s] is required", schema.Name, field.Name)
+				}
+				one = field.Default
+			}
+			if err = avroWrite(field.Schema, one, buf); err != nil {
+				return errors.New(field.Name + ":" + err.Error())
+			}
+		}
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return errors.New("avro array value must be array")
+		}
+		if len(list) > 0 {
+			writeLong(buf, int64(len(list)))
+			for _, one := range list {
+				if err = avroWrite(schema.Items, one, buf); err != nil {
+					return
+				}
+			}
+		}
+		writeLong(buf, 0)
+	case "map":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return errors.New("avro map value must be object")
+		}
+		if len(object) > 0 {
+			writeLong(buf, int64(len(object)))
+			for key, one := range object {
+				writeLong(buf, int64(len(key)))
+				buf.WriteString(key)
+				if err = avroWrite(schema.Values, one, buf); err != nil {
+					return
+				}
+			}
+		}
+		writeLong(buf, 0)
+	default:
+		return errors.New("avro type [" + schema.Type + "] not support")
+	}
+	return
+}
@@ -0,0 +1,152 @@
+package module_kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+const testAvroSchema = `{
+	"type": "record",
+	"name": "User",
+	"namespace": "test",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "name", "type": "string"},
+		{"name": "age", "type": "int"},
+		{"name": "score", "type": "double"},
+		{"name": "active", "type": "boolean"},
+		{"name": "level", "type": {"type": "enum", "name": "Level", "symbols": ["LOW", "HIGH"]}},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "attrs", "type": {"type": "map", "values": "long"}},
+		{"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 2}},
+		{"name": "email", "type": ["null", "string"]},
+		{"name": "friend", "type": ["null", "User"]},
+		{"name": "remark", "type": "string", "default": "none"}
+	]
+}`
+
+// jsonEqual 按 JSON 语义 比较，忽略 字段 顺序
+func jsonEqual(t *testing.T, a, b string) bool {
+	var va, vb interface{}
+	if err := json.Unmarshal([]byte(a), &va); err != nil {
+		t.Fatalf("json %s error:%s", a, err)
+	}
+	if err := json.Unmarshal([]byte(b), &vb); err != nil {
+		t.Fatalf("json %s error:%s", b, err)
+	}
+	ba, _ := json.Marshal(va)
+	bb, _ := json.Marshal(vb)
+	return bytes.Equal(ba, bb)
+}
+
+func TestAvroRoundTrip(t *testing.T) {
+	schema, err := parseAvroSchema(testAvroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, one := range []struct {
+		text   string
+		expect string
+	}{
+		{
+			`{"id":1,"name":"a","age":-3,"score":1.5,"active":true,"level":"HIGH","tags":["x","y"],"attrs":{"k":9},"hash":"\u0001ÿ","email":"a@b.c","friend":null,"remark":"r"}`,
+			"",
+		},
+		// 空 数组 空 map，null 分支
+		{
+			`{"id":-9223372036854775808,"name":"","age":2147483647,"score":0,"active":false,"level":"LOW","tags":[],"attrs":{},"hash":"ab","email":null,"friend":null,"remark":""}`,
+			"",
+		},
+		// 递归 引用 的 命名 类型，带 分支 名 的 联合 类型
+		{
+			`{"id":1,"name":"a","age":1,"score":1,"active":true,"level":"LOW","tags":[],"attrs":{},"hash":"ab","email":{"string":"e"},"friend":{"test.User":{"id":2,"name":"b","age":2,"score":2,"active":false,"level":"HIGH","tags":["t"],"attrs":{},"hash":"cd","email":null,"friend":null}}}`,
+			`{"id":1,"name":"a","age":1,"score":1,"active":true,"level":"LOW","tags":[],"attrs":{},"hash":"ab","email":"e","friend":{"id":2,"name":"b","age":2,"score":2,"active":false,"level":"HIGH","tags":["t"],"attrs":{},"hash":"cd","email":null,"friend":null,"remark":"none"},"remark":"none"}`,
+		},
+	} {
+		bs, err := avroEncode(schema, one.text)
+		if err != nil {
+			t.Errorf("encode %s error:%s", one.text, err)
+			continue
+		}
+		res, err := avroDecode(schema, bs)
+		if err != nil {
+			t.Errorf("decode %s error:%s", one.text, err)
+			continue
+		}
+		expect := one.expect
+		if expect == "" {
+			expect = one.text
+		}
+		if !jsonEqual(t, res, expect) {
+			t.Errorf("round trip expect %s, got %s", expect, res)
+		}
+	}
+}
+
+// TestAvroEncodeBytes 对照 Avro 规范 中 的 二进制 示例
+func TestAvroEncodeBytes(t *testing.T) {
+	for _, one := range []struct {
+		schema string
+		text   string
+		expect []byte
+	}{
+		{`"long"`, `1`, []byte{0x02}},
+		{`"long"`, `-1`, []byte{0x01}},
+		{`"long"`, `64`, []byte{0x80, 0x01}},
+		{`"string"`, `"foo"`, []byte{0x06, 'f', 'o', 'o'}},
+		{`{"type":"record","name":"test","fields":[{"name":"a","type":"long"},{"name":"b","type":"string"}]}`, `{"a":27,"b":"foo"}`, []byte{0x36, 0x06, 'f', 'o', 'o'}},
+		{`{"type":"array","items":"long"}`, `[3,27]`, []byte{0x04, 0x06, 0x36, 0x00}},
+		{`["null","string"]`, `null`, []byte{0x00}},
+		{`["null","string"]`, `"a"`, []byte{0x02, 0x02, 'a'}},
+		{`"boolean"`, `true`, []byte{0x01}},
+	} {
+		schema, err := parseAvroSchema(one.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs, err := avroEncode(schema, one.text)
+		if err != nil {
+			t.Errorf("encode %s %s error:%s", one.schema, one.text, err)
+			continue
+		}
+		if !bytes.Equal(bs, one.expect) {
+			t.Errorf("encode %s %s expect %v, got %v", one.schema, one.text, one.expect, bs)
+		}
+		res, err := avroDecode(schema, bs)
+		if err != nil {
+			t.Errorf("decode %s %v error:%s", one.schema, bs, err)
+			continue
+		}
+		if !jsonEqual(t, res, one.text) {
+			t.Errorf("decode %s expect %s, got %s", one.schema, one.text, res)
+		}
+	}
+}
+
+func TestAvroError(t *testing.T) {
+	for _, one := range []struct {
+		schema string
+		text   string
+	}{
+		{`{"type":"enum","name":"E","symbols":["A"]}`, `"B"`},
+		{`{"type":"fixed","name":"F","size":2}`, `"abc"`},
+		{`{"type":"record","name":"R","fields":[{"name":"a","type":"long"}]}`, `{}`},
+		{`"int"`, `"x"`},
+	} {
+		schema, err := parseAvroSchema(one.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = avroEncode(schema, one.text); err == nil {
+			t.Errorf("encode %s %s expect error", one.schema, one.text)
+		}
+	}
+	if _, err := parseAvroSchema(`{"type":"record","name":"R","fields":[{"name":"a","type":"Missing"}]}`); err == nil {
+		t.Errorf("unknown named type expect error")
+	}
+	schema, _ := parseAvroSchema(`"string"`)
+	if _, err := avroDecode(schema, []byte{0x06, 'f'}); err == nil {
+		t.Errorf("short data expect error")
+	}
+}
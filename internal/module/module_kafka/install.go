@@ -0,0 +1,91 @@
+package module_kafka
+
+import (
+	"teamide/internal/install"
+)
+
+func GetInstallStages() []*install.StageModel {
+
+	return []*install.StageModel{
+
+		// 创建 消费 延迟 采样 表
+		{
+			Version: "1.0",
+			Module:  ModuleKafkaLag,
+			Stage:   `创建表[` + TableKafkaLag + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableKafkaLag + ` (
+	lagId bigint(20) NOT NULL COMMENT '采样ID',
+	toolboxId bigint(20) NOT NULL COMMENT '工具ID',
+	groupId varchar(200) NOT NULL COMMENT '消费组',
+	totalLag bigint(20) NOT NULL DEFAULT 0 COMMENT '总延迟',
+	detail text DEFAULT NULL COMMENT '分区延迟',
+	createTime datetime NOT NULL COMMENT '采样时间',
+	PRIMARY KEY (lagId),
+	KEY index_toolboxId_groupId (toolboxId, groupId),
+	KEY index_createTime (createTime)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableKafkaLagComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableKafkaLag + ` (
+	lagId bigint(20) NOT NULL,
+	toolboxId bigint(20) NOT NULL,
+	groupId varchar(200) NOT NULL,
+	totalLag bigint(20) NOT NULL DEFAULT 0,
+	detail text DEFAULT NULL,
+	createTime datetime NOT NULL,
+	PRIMARY KEY (lagId)
+);
+`,
+					`CREATE INDEX ` + TableKafkaLag + `_index_toolboxId_groupId on ` + TableKafkaLag + ` (toolboxId, groupId);`,
+					`CREATE INDEX ` + TableKafkaLag + `_index_createTime on ` + TableKafkaLag + ` (createTime);`,
+				},
+			},
+		},
+
+		// 创建 消费 延迟 告警 表
+		{
+			Version: "1.0",
+			Module:  ModuleKafkaLag,
+			Stage:   `创建表[` + TableKafkaLagAlert + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableKafkaLagAlert + ` (
+	alertId bigint(20) NOT NULL COMMENT '告警ID',
+	toolboxId bigint(20) NOT NULL COMMENT '工具ID',
+	groupId varchar(200) NOT NULL COMMENT '消费组',
+	threshold bigint(20) NOT NULL DEFAULT 0 COMMENT '延迟阈值',
+	growTimes int(10) NOT NULL DEFAULT 3 COMMENT '连续增长次数',
+	silenceMinute int(10) NOT NULL DEFAULT 10 COMMENT '静默分钟',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	lastAlertTime datetime DEFAULT NULL COMMENT '最后告警时间',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	updateTime datetime DEFAULT NULL COMMENT '修改时间',
+	PRIMARY KEY (alertId),
+	KEY index_toolboxId_groupId (toolboxId, groupId),
+	KEY index_userId (userId)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableKafkaLagAlertComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableKafkaLagAlert + ` (
+	alertId bigint(20) NOT NULL,
+	toolboxId bigint(20) NOT NULL,
+	groupId varchar(200) NOT NULL,
+	threshold bigint(20) NOT NULL DEFAULT 0,
+	growTimes int(10) NOT NULL DEFAULT 3,
+	silenceMinute int(10) NOT NULL DEFAULT 10,
+	userId bigint(20) DEFAULT NULL,
+	lastAlertTime datetime DEFAULT NULL,
+	createTime datetime NOT NULL,
+	updateTime datetime DEFAULT NULL,
+	PRIMARY KEY (alertId)
+);
+`,
+					`CREATE INDEX ` + TableKafkaLagAlert + `_index_toolboxId_groupId on ` + TableKafkaLagAlert + ` (toolboxId, groupId);`,
+					`CREATE INDEX ` + TableKafkaLagAlert + `_index_userId on ` + TableKafkaLagAlert + ` (userId);`,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,536 @@
+package module_kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Shopify/sarama"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/kafka"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_id"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"time"
+)
+
+// 采样 数据 保存 天数
+var lagSaveDays = 7
+
+type PartitionLag struct {
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Offset        int64  `json:"offset"` // 已 提交 位置，-1 表示 未 提交
+	HighWatermark int64  `json:"highWatermark"`
+	Lag           int64  `json:"lag"`
+}
+
+type GroupLag struct {
+	GroupId    string           `json:"groupId"`
+	TotalLag   int64            `json:"totalLag"`
+	TopicLag   map[string]int64 `json:"topicLag"`
+	Partitions []*PartitionLag  `json:"partitions"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// loadGroupLags 计算 消费组 延迟，groupIds 为空 则 计算 所有 消费组
+// 延迟 为 分区 最新 位置 减去 已 提交 位置，未 提交 的 分区 按 最早 位置 计算
+func loadGroupLags(service kafka.IService, groupIds []string) (res []*GroupLag, err error) {
+	client, err := service.GetClient()
+	if err != nil {
+		return
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return
+	}
+	// 关闭 admin 会 同时 关闭 client
+	defer func() { _ = admin.Close() }()
+
+	if len(groupIds) == 0 {
+		var groups map[string]string
+		if groups, err = admin.ListConsumerGroups(); err != nil {
+			return
+		}
+		for groupId := range groups {
+			groupIds = append(groupIds, groupId)
+		}
+		sort.Strings(groupIds)
+	}
+	offsetCache := map[string]map[int32]int64{}
+	getOffset := func(topic string, partition int32, time int64) (int64, error) {
+		key := topic + "-" + util.GetStringValue(time)
+		if offsetCache[key] == nil {
+			offsetCache[key] = map[int32]int64{}
+		}
+		if offset, ok := offsetCache[key][partition]; ok {
+			return offset, nil
+		}
+		offset, e := client.GetOffset(topic, partition, time)
+		if e != nil {
+			return 0, e
+		}
+		offsetCache[key][partition] = offset
+		return offset, nil
+	}
+	for _, groupId := range groupIds {
+		groupLag := &GroupLag{GroupId: groupId, TopicLag: map[string]int64{}}
+		res = append(res, groupLag)
+		response, e := admin.ListConsumerGroupOffsets(groupId, nil)
+		if e == nil && response.Err != sarama.ErrNoError {
+			e = response.Err
+		}
+		if e != nil {
+			groupLag.Error = e.Error()
+			continue
+		}
+		for topic, blocks := range response.Blocks {
+			for partition, block := range blocks {
+				if block == nil || block.Err != sarama.ErrNoError {
+					continue
+				}
+				one := &PartitionLag{Topic: topic, Partition: partition, Offset: block.Offset}
+				if one.HighWatermark, e = getOffset(topic, partition, sarama.OffsetNewest); e != nil {
+					groupLag.Error = e.Error()
+					continue
+				}
+				start := block.Offset
+				if start < 0 {
+					if start, e = getOffset(topic, partition, sarama.OffsetOldest); e != nil {
+						groupLag.Error = e.Error()
+						continue
+					}
+				}
+				one.Lag = one.HighWatermark - start
+				if one.Lag < 0 {
+					one.Lag = 0
+				}
+				groupLag.Partitions = append(groupLag.Partitions, one)
+				groupLag.TopicLag[topic] += one.Lag
+				groupLag.TotalLag += one.Lag
+			}
+		}
+		sort.Slice(groupLag.Partitions, func(i, j int) bool {
+			a, b := groupLag.Partitions[i], groupLag.Partitions[j]
+			if a.Topic != b.Topic {
+				return a.Topic < b.Topic
+			}
+			return a.Partition < b.Partition
+		})
+	}
+	return
+}
+
+// NewLagService 创建 消费 延迟 采样 服务
+func NewLagService(toolboxService *module_toolbox.ToolboxService) (res *LagService) {
+	res = &LagService{
+		ServerContext:  toolboxService.ServerContext,
+		toolboxService: toolboxService,
+		idService:      module_id.NewIDService(toolboxService.ServerContext),
+		sampling:       map[int64]bool{},
+	}
+	return
+}
+
+// LagService 定时 采样 所有 Kafka 工具 的 消费组 延迟，并 检查 告警
+type LagService struct {
+	*context.ServerContext
+	toolboxService *module_toolbox.ToolboxService
+	idService      *module_id.IDService
+	sampling       map[int64]bool
+	samplingLock   sync.Mutex
+}
+
+func (this_ *LagService) ServerReady() (err error) {
+	// 每分钟 采样 一次
+	_, err = this_.CronHandler.AddFunc("0 * * * * ?", this_.sampleTask)
+	if err != nil {
+		return
+	}
+	// 每天 3 点 清理
+	_, err = this_.CronHandler.AddFunc("0 0 3 * * ?", this_.cleanTask)
+	return
+}
+
+func (this_ *LagService) cleanTask() {
+	deleteBeforeTime := time.Now().AddDate(0, 0, -lagSaveDays)
+	sql := "DELETE FROM " + TableKafkaLag + " WHERE createTime<? "
+	deleteCount, err := this_.DatabaseWorker.Exec(sql, []interface{}{deleteBeforeTime})
+	if err != nil {
+		this_.Logger.Error("kafka lag clean task error", zap.Error(err))
+		return
+	}
+	this_.Logger.Info("kafka lag clean task end", zap.Any("deleteBeforeTime", deleteBeforeTime), zap.Any("deleteCount", deleteCount))
+}
+
+// sampleTask 采样 所有 Kafka 工具，配置 了 告警 的 同时 检查 告警
+func (this_ *LagService) sampleTask() {
+	toolboxList, err := this_.toolboxService.QueryByType("kafka")
+	if err != nil {
+		return
+	}
+	alerts, err := this_.QueryAlert(0)
+	if err != nil {
+		return
+	}
+	toolboxAlerts := map[int64][]*LagAlertModel{}
+	for _, one := range alerts {
+		toolboxAlerts[one.ToolboxId] = append(toolboxAlerts[one.ToolboxId], one)
+	}
+	for _, toolbox := range toolboxList {
+		toolboxId := toolbox.ToolboxId
+		list := toolboxAlerts[toolboxId]
+		// 上一次 采样 未 结束 则 跳过，防止 连接 超时 时 堆积
+		this_.samplingLock.Lock()
+		if this_.sampling[toolboxId] {
+			this_.samplingLock.Unlock()
+			continue
+		}
+		this_.sampling[toolboxId] = true
+		this_.samplingLock.Unlock()
+		go func(toolboxId int64, list []*LagAlertModel) {
+			defer func() {
+				if e := recover(); e != nil {
+					this_.Logger.Error("kafka lag sample error", zap.Any("toolboxId", toolboxId), zap.Any("error", e))
+				}
+				this_.samplingLock.Lock()
+				delete(this_.sampling, toolboxId)
+				this_.samplingLock.Unlock()
+			}()
+			if e := this_.sample(toolboxId, list); e != nil {
+				this_.Logger.Error("kafka lag sample error", zap.Any("toolboxId", toolboxId), zap.Error(e))
+			}
+		}(toolboxId, list)
+	}
+}
+
+// sample 采样 工具 下 所有 消费组 并 检查 告警
+func (this_ *LagService) sample(toolboxId int64, alerts []*LagAlertModel) (err error) {
+	config := &kafka.Config{}
+	_, err = this_.toolboxService.BindConfigById(toolboxId, config)
+	if err != nil {
+		return
+	}
+	if config.Address == "" {
+		err = errors.New("toolbox [" + util.GetStringValue(toolboxId) + "] kafka address is empty")
+		return
+	}
+	service, err := getService(config)
+	if err != nil {
+		return
+	}
+	groupLags, err := loadGroupLags(service, nil)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, groupLag := range groupLags {
+		if groupLag.Error != "" {
+			continue
+		}
+		if err = this_.insertLag(toolboxId, groupLag, now); err != nil {
+			return
+		}
+	}
+	for _, alert := range alerts {
+		this_.checkAlert(alert, now)
+	}
+	return
+}
+
+func (this_ *LagService) insertLag(toolboxId int64, groupLag *GroupLag, now time.Time) (err error) {
+	lagId, err := this_.idService.GetNextID(module_id.IDTypeKafkaLag)
+	if err != nil {
+		return
+	}
+	detail, _ := json.Marshal(groupLag.Partitions)
+	sql := `INSERT INTO ` + TableKafkaLag + `(lagId, toolboxId, groupId, totalLag, detail, createTime) VALUES (?, ?, ?, ?, ?, ?) `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{lagId, toolboxId, groupLag.GroupId, groupLag.TotalLag, string(detail), now})
+	return
+}
+
+// checkAlert 延迟 超过 阈值 且 连续 增长 时 通知 配置 告警 的 用户
+func (this_ *LagService) checkAlert(alert *LagAlertModel, now time.Time) {
+	if alert.Threshold <= 0 || alert.UserId == 0 {
+		return
+	}
+	growTimes := alert.GrowTimes
+	if growTimes <= 0 {
+		growTimes = 3
+	}
+	silenceMinute := alert.SilenceMinute
+	if silenceMinute <= 0 {
+		silenceMinute = 10
+	}
+	if !alert.LastAlertTime.IsZero() && now.Sub(alert.LastAlertTime) < time.Duration(silenceMinute)*time.Minute {
+		return
+	}
+	list, err := this_.QueryHistory(alert.ToolboxId, alert.GroupId, time.Time{}, time.Time{}, growTimes+1, false)
+	if err != nil || len(list) < growTimes+1 {
+		return
+	}
+	// list 按 时间 倒序
+	if list[0].TotalLag < alert.Threshold {
+		return
+	}
+	for i := 0; i < growTimes; i++ {
+		if list[i].TotalLag <= list[i+1].TotalLag {
+			return
+		}
+	}
+	event := context.NewListenEvent("kafka-lag-alert", map[string]interface{}{
+		"alertId":   alert.AlertId,
+		"toolboxId": alert.ToolboxId,
+		"groupId":   alert.GroupId,
+		"threshold": alert.Threshold,
+		"totalLag":  list[0].TotalLag,
+		"growTimes": growTimes,
+		"time":      util.GetMilliByTime(now),
+	})
+	event.KeyForRemoveDuplicates = util.GetStringValue(alert.AlertId)
+	context.CallUserEvent(alert.UserId, event)
+	this_.Logger.Warn("kafka lag alert", zap.Any("toolboxId", alert.ToolboxId), zap.Any("groupId", alert.GroupId), zap.Any("totalLag", list[0].TotalLag))
+
+	sql := `UPDATE ` + TableKafkaLagAlert + ` SET lastAlertTime=? WHERE alertId=? `
+	_, _ = this_.DatabaseWorker.Exec(sql, []interface{}{now, alert.AlertId})
+}
+
+// QueryHistory 查询 消费组 延迟 历史，按 时间 倒序
+func (this_ *LagService) QueryHistory(toolboxId int64, groupId string, startTime time.Time, endTime time.Time, size int, withDetail bool) (list []*LagModel, err error) {
+	columns := "lagId, toolboxId, groupId, totalLag, createTime"
+	if withDetail {
+		columns += ", detail"
+	}
+	sql := `SELECT ` + columns + ` FROM ` + TableKafkaLag + ` WHERE toolboxId=? AND groupId=? `
+	values := []interface{}{toolboxId, groupId}
+	if !startTime.IsZero() {
+		sql += " AND createTime>=? "
+		values = append(values, startTime)
+	}
+	if !endTime.IsZero() {
+		sql += " AND createTime<=? "
+		values = append(values, endTime)
+	}
+	sql += " ORDER BY createTime DESC "
+	if size > 0 {
+		sql += " LIMIT " + util.GetStringValue(size)
+	}
+	err = this_.DatabaseWorker.Query(sql, values, &list)
+	return
+}
+
+// QueryAlert 查询 告警 配置，toolboxId 为 0 时 查询 所有
+func (this_ *LagService) QueryAlert(toolboxId int64) (list []*LagAlertModel, err error) {
+	sql := `SELECT * FROM ` + TableKafkaLagAlert + ` WHERE 1=1 `
+	var values []interface{}
+	if toolboxId != 0 {
+		sql += " AND toolboxId=? "
+		values = append(values, toolboxId)
+	}
+	sql += " ORDER BY groupId "
+	err = this_.DatabaseWorker.Query(sql, values, &list)
+	return
+}
+
+// SaveAlert 新增 或 修改 告警 配置，同一 工具 同一 消费组 只 保留 一个
+func (this_ *LagService) SaveAlert(alert *LagAlertModel) (err error) {
+	if alert.GrowTimes <= 0 {
+		alert.GrowTimes = 3
+	}
+	if alert.SilenceMinute <= 0 {
+		alert.SilenceMinute = 10
+	}
+	if alert.AlertId == 0 {
+		var find []*LagAlertModel
+		sql := `SELECT alertId FROM ` + TableKafkaLagAlert + ` WHERE toolboxId=? AND groupId=? `
+		if err = this_.DatabaseWorker.Query(sql, []interface{}{alert.ToolboxId, alert.GroupId}, &find); err != nil {
+			return
+		}
+		if len(find) > 0 {
+			alert.AlertId = find[0].AlertId
+		}
+	} else {
+		// 修改 时 只能 修改 当前 工具 的 告警，消费组 不能 修改
+		var find []*LagAlertModel
+		sql := `SELECT alertId, groupId FROM ` + TableKafkaLagAlert + ` WHERE toolboxId=? AND alertId=? `
+		if err = this_.DatabaseWorker.Query(sql, []interface{}{alert.ToolboxId, alert.AlertId}, &find); err != nil {
+			return
+		}
+		if len(find) == 0 {
+			err = errors.New("lag alert [" + util.GetStringValue(alert.AlertId) + "] not found")
+			return
+		}
+		if find[0].GroupId != alert.GroupId {
+			err = errors.New("lag alert groupId can not be changed, delete it and create a new one")
+			return
+		}
+	}
+	if alert.AlertId > 0 {
+		sql := `UPDATE ` + TableKafkaLagAlert + ` SET threshold=?,growTimes=?,silenceMinute=?,userId=?,updateTime=? WHERE toolboxId=? AND alertId=? `
+		var rowsAffected int64
+		rowsAffected, err = this_.DatabaseWorker.Exec(sql, []interface{}{alert.Threshold, alert.GrowTimes, alert.SilenceMinute, alert.UserId, time.Now(), alert.ToolboxId, alert.AlertId})
+		if err != nil {
+			return
+		}
+		if rowsAffected == 0 {
+			err = errors.New("lag alert [" + util.GetStringValue(alert.AlertId) + "] not found")
+			return
+		}
+		return
+	}
+	alert.AlertId, err = this_.idService.GetNextID(module_id.IDTypeKafkaLagAlert)
+	if err != nil {
+		return
+	}
+	alert.CreateTime = time.Now()
+	sql := `INSERT INTO ` + TableKafkaLagAlert + `(alertId, toolboxId, groupId, threshold, growTimes, silenceMinute, userId, createTime) VALUES (?, ?, ?, ?, ?, ?, ?, ?) `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{alert.AlertId, alert.ToolboxId, alert.GroupId, alert.Threshold, alert.GrowTimes, alert.SilenceMinute, alert.UserId, alert.CreateTime})
+	return
+}
+
+// DeleteAlert 删除 告警 配置
+func (this_ *LagService) DeleteAlert(toolboxId int64, alertId int64) (err error) {
+	sql := `DELETE FROM ` + TableKafkaLagAlert + ` WHERE toolboxId=? AND alertId=? `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{toolboxId, alertId})
+	return
+}
+
+type LagRequest struct {
+	ToolboxId  int64    `json:"toolboxId"`
+	GroupIds   []string `json:"groupIds"` // 为空 则 所有 消费组
+	GroupId    string   `json:"groupId"`
+	StartTime  int64    `json:"startTime"` // 毫秒
+	EndTime    int64    `json:"endTime"`   // 毫秒
+	Size       int      `json:"size"`
+	WithDetail bool     `json:"withDetail"`
+
+	AlertId       int64 `json:"alertId"`
+	Threshold     int64 `json:"threshold"`
+	GrowTimes     int   `json:"growTimes"`
+	SilenceMinute int   `json:"silenceMinute"`
+}
+
+func (this_ *api) lag(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config)
+	if err != nil {
+		return
+	}
+
+	request := &LagRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = loadGroupLags(service, request.GroupIds)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) lagHistory(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	// 使用 校验 过 权限 的 工具，不 信任 请求 中 的 toolboxId
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &LagRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.GroupId == "" {
+		err = errors.New("groupId is empty")
+		return
+	}
+	var startTime, endTime time.Time
+	if request.StartTime > 0 {
+		startTime = time.UnixMilli(request.StartTime)
+	}
+	if request.EndTime > 0 {
+		endTime = time.UnixMilli(request.EndTime)
+	}
+	if request.Size <= 0 {
+		request.Size = 1440
+	}
+	list, err := this_.lagService.QueryHistory(toolbox.ToolboxId, request.GroupId, startTime, endTime, request.Size, request.WithDetail)
+	if err != nil {
+		return
+	}
+	// 按 时间 正序 返回 便于 画图
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	res = list
+	return
+}
+
+func (this_ *api) lagAlertList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+	res, err = this_.lagService.QueryAlert(toolbox.ToolboxId)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) lagAlertSave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &LagRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.GroupId == "" {
+		err = errors.New("groupId is empty")
+		return
+	}
+	alert := &LagAlertModel{
+		AlertId:       request.AlertId,
+		ToolboxId:     toolbox.ToolboxId,
+		GroupId:       request.GroupId,
+		Threshold:     request.Threshold,
+		GrowTimes:     request.GrowTimes,
+		SilenceMinute: request.SilenceMinute,
+	}
+	if requestBean.JWT != nil {
+		alert.UserId = requestBean.JWT.UserId
+	}
+	err = this_.lagService.SaveAlert(alert)
+	if err != nil {
+		return
+	}
+	res = alert
+	return
+}
+
+func (this_ *api) lagAlertDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &LagRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	err = this_.lagService.DeleteAlert(toolbox.ToolboxId, request.AlertId)
+	if err != nil {
+		return
+	}
+	return
+}
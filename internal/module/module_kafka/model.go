@@ -0,0 +1,39 @@
+package module_kafka
+
+import "time"
+
+const (
+	// ModuleKafkaLag Kafka 消费 延迟 模块
+	ModuleKafkaLag = "kafka_lag"
+	// TableKafkaLag Kafka 消费 延迟 采样 表
+	TableKafkaLag        = "TM_KAFKA_LAG"
+	TableKafkaLagComment = "Kafka消费延迟采样"
+
+	// TableKafkaLagAlert Kafka 消费 延迟 告警 表
+	TableKafkaLagAlert        = "TM_KAFKA_LAG_ALERT"
+	TableKafkaLagAlertComment = "Kafka消费延迟告警"
+)
+
+// LagModel 消费组 某次 采样 的 延迟，detail 为 分区 延迟 JSON
+type LagModel struct {
+	LagId      int64     `json:"lagId,omitempty"`
+	ToolboxId  int64     `json:"toolboxId,omitempty"`
+	GroupId    string    `json:"groupId,omitempty"`
+	TotalLag   int64     `json:"totalLag"`
+	Detail     string    `json:"detail,omitempty"`
+	CreateTime time.Time `json:"createTime,omitempty"`
+}
+
+// LagAlertModel 消费组 延迟 告警 配置，配置 后 该 工具 开始 定时 采样
+type LagAlertModel struct {
+	AlertId       int64     `json:"alertId,omitempty"`
+	ToolboxId     int64     `json:"toolboxId,omitempty"`
+	GroupId       string    `json:"groupId,omitempty"`
+	Threshold     int64     `json:"threshold"`     // 延迟 超过 该值 才 告警，0 表示 只 采样 不 告警
+	GrowTimes     int       `json:"growTimes"`     // 连续 增长 次数，默认 3
+	SilenceMinute int       `json:"silenceMinute"` // 告警 后 静默 分钟，默认 10
+	UserId        int64     `json:"userId,omitempty"`
+	LastAlertTime time.Time `json:"lastAlertTime,omitempty"`
+	CreateTime    time.Time `json:"createTime,omitempty"`
+	UpdateTime    time.Time `json:"updateTime,omitempty"`
+}
@@ -0,0 +1,223 @@
+package module_kafka
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	// 注册 常用 的 google/protobuf/*.proto 供 import 使用
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// protoFiles 本地 解析 的 文件 加上 全局 注册 的 google/protobuf 文件
+type protoFiles struct {
+	files *protoregistry.Files
+}
+
+func (this_ *protoFiles) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if find, err := this_.files.FindFileByPath(path); err == nil {
+		return find, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (this_ *protoFiles) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if find, err := this_.files.FindDescriptorByName(name); err == nil {
+		return find, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// protoImportResolver 按 文件名 匹配 import 路径，上传 的 文件 只有 文件名，import 时 可能 带 目录
+type protoImportResolver struct {
+	contents map[string]string
+	aliases  map[string]string // 上传 的 文件名 -> import 路径
+}
+
+func (this_ *protoImportResolver) resolve(name string) (path string, ok bool) {
+	if _, ok = this_.contents[name]; ok {
+		path = name
+		return
+	}
+	var paths []string
+	for one := range this_.contents {
+		paths = append(paths, one)
+	}
+	sort.Strings(paths)
+	for _, one := range paths {
+		if strings.HasSuffix(name, "/"+one) || strings.HasSuffix(one, "/"+name) {
+			return one, true
+		}
+	}
+	return
+}
+
+func (this_ *protoImportResolver) open(name string) (io.ReadCloser, error) {
+	path, ok := this_.resolve(name)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	if path != name {
+		this_.aliases[path] = name
+	}
+	return io.NopCloser(strings.NewReader(this_.contents[path])), nil
+}
+
+// parseProtoFiles 使用 protoparse 解析，被 其它 文件 以 带 目录 的 路径 import 的 文件 使用 import 路径 重新 解析，避免 同一 文件 解析 两次
+func parseProtoFiles(contents map[string]string) (files []*desc.FileDescriptor, err error) {
+	resolver := &protoImportResolver{contents: contents, aliases: map[string]string{}}
+	parser := protoparse.Parser{Accessor: resolver.open}
+	var names []string
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// 有 别名 时 第一次 解析 会 因 同一 文件 两个 名称 报 重复 定义，以 第二次 解析 结果 为准
+	if files, err = parser.ParseFiles(names...); len(resolver.aliases) == 0 {
+		return
+	}
+	var aliasNames []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		if alias, ok := resolver.aliases[name]; ok {
+			name = alias
+		}
+		if !seen[name] {
+			seen[name] = true
+			aliasNames = append(aliasNames, name)
+		}
+	}
+	files, err = parser.ParseFiles(aliasNames...)
+	return
+}
+
+// buildProtoFiles 解析 多个 .proto 文件 并 按 依赖 顺序 注册，key 为 文件名 或 import 使用 的 路径
+func buildProtoFiles(contents map[string]string) (res *protoFiles, err error) {
+	files, err := parseProtoFiles(contents)
+	if err != nil {
+		return
+	}
+	res = &protoFiles{files: &protoregistry.Files{}}
+	var register func(file *desc.FileDescriptor) error
+	register = func(file *desc.FileDescriptor) error {
+		// google/protobuf 等 已 全局 注册 的 文件 不 重复 注册
+		if _, e := res.FindFileByPath(file.GetName()); e == nil {
+			return nil
+		}
+		for _, dependency := range file.GetDependencies() {
+			if e := register(dependency); e != nil {
+				return e
+			}
+		}
+		fd, e := protodesc.NewFile(file.AsFileDescriptorProto(), res)
+		if e != nil {
+			return errors.New("proto [" + file.GetName() + "] error:" + e.Error())
+		}
+		return res.files.RegisterFile(fd)
+	}
+	for _, file := range files {
+		if err = register(file); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// findMessage 查找 消息 类型，支持 全名 或 不带 包名 的 名称
+func (this_ *protoFiles) findMessage(name string) (md protoreflect.MessageDescriptor, err error) {
+	name = strings.TrimPrefix(name, ".")
+	if find, e := this_.FindDescriptorByName(protoreflect.FullName(name)); e == nil {
+		if md, ok := find.(protoreflect.MessageDescriptor); ok {
+			return md, nil
+		}
+	}
+	this_.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		md = findMessageInList(fd.Messages(), name)
+		return md == nil
+	})
+	if md == nil {
+		err = errors.New("proto message [" + name + "] not found")
+	}
+	return
+}
+
+func findMessageInList(list protoreflect.MessageDescriptors, name string) protoreflect.MessageDescriptor {
+	for i := 0; i < list.Len(); i++ {
+		one := list.Get(i)
+		if string(one.Name()) == name || strings.HasSuffix(string(one.FullName()), "."+name) {
+			return one
+		}
+		if find := findMessageInList(one.Messages(), name); find != nil {
+			return find
+		}
+	}
+	return nil
+}
+
+// messageByIndexes 按 Confluent 消息 下标 路径 查找 消息 类型
+func messageByIndexes(fd protoreflect.FileDescriptor, indexes []int) (md protoreflect.MessageDescriptor, err error) {
+	list := fd.Messages()
+	for _, index := range indexes {
+		if index < 0 || index >= list.Len() {
+			err = fmt.Errorf("proto message index %v invalid", indexes)
+			return
+		}
+		md = list.Get(index)
+		list = md.Messages()
+	}
+	if md == nil {
+		err = errors.New("proto file [" + fd.Path() + "] has no message")
+	}
+	return
+}
+
+// indexesByMessage 计算 消息 类型 在 文件 中的 下标 路径
+func indexesByMessage(md protoreflect.MessageDescriptor) (indexes []int) {
+	var parent protoreflect.Descriptor = md
+	for {
+		indexes = append([]int{parent.Index()}, indexes...)
+		next, ok := parent.Parent().(protoreflect.MessageDescriptor)
+		if !ok {
+			return
+		}
+		parent = next
+	}
+}
+
+func protoDecode(md protoreflect.MessageDescriptor, data []byte) (res string, err error) {
+	message := dynamicpb.NewMessage(md)
+	if err = proto.Unmarshal(data, message); err != nil {
+		return
+	}
+	bs, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(message)
+	if err != nil {
+		return
+	}
+	res = string(bs)
+	return
+}
+
+func protoEncode(md protoreflect.MessageDescriptor, text string) (res []byte, err error) {
+	message := dynamicpb.NewMessage(md)
+	if err = (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal([]byte(text), message); err != nil {
+		return
+	}
+	res, err = proto.Marshal(message)
+	return
+}
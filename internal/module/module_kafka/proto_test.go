@@ -0,0 +1,121 @@
+package module_kafka
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testProtoContents = map[string]string{
+	// 上传 的 文件 只有 文件名，被 import 时 带 目录
+	"common.proto": `syntax = "proto3";
+package test.common;
+option java_package = "com.test.common";
+
+message Address {
+  string city = 1;
+}
+`,
+	"user.proto": `syntax = "proto3";
+package test;
+import "test/common/common.proto";
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/descriptor.proto";
+
+option go_package = "test/user";
+
+extend google.protobuf.FieldOptions {
+  string label = 50001;
+}
+
+message User {
+  int64 id = 1 [(label) = "ID", json_name = "userId"];
+  string name = 2;
+  repeated test.common.Address addresses = 3;
+  map<string, int32> scores = 4;
+  google.protobuf.Timestamp created = 5;
+  oneof contact {
+    string email = 6;
+    string phone = 7;
+  }
+  message Tag {
+    string value = 1;
+  }
+  repeated Tag tags = 8;
+  reserved 20;
+}
+
+service UserService {
+  rpc Get (User) returns (User) {
+    option deprecated = true;
+  }
+}
+`,
+}
+
+func TestBuildProtoFiles(t *testing.T) {
+	files, err := buildProtoFiles(testProtoContents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = files.FindFileByPath("test/common/common.proto"); err != nil {
+		t.Errorf("common.proto expect registered by import path, error:%s", err)
+	}
+	for _, name := range []string{"test.User", "User", ".test.User", "Tag", "test.User.Tag", "Address"} {
+		if _, err = files.findMessage(name); err != nil {
+			t.Errorf("find message %s error:%s", name, err)
+		}
+	}
+	if _, err = files.findMessage("Missing"); err == nil {
+		t.Errorf("find message Missing expect error")
+	}
+
+	md, _ := files.findMessage("Tag")
+	indexes := indexesByMessage(md)
+	// map 字段 的 ScoresEntry 是 第一个 嵌套 消息
+	if !reflect.DeepEqual(indexes, []int{0, 1}) {
+		t.Errorf("tag indexes expect [0 1], got %v", indexes)
+	}
+	find, err := messageByIndexes(md.ParentFile(), indexes)
+	if err != nil || find.FullName() != md.FullName() {
+		t.Errorf("message by indexes expect %s, got %v %v", md.FullName(), find, err)
+	}
+	if _, err = messageByIndexes(md.ParentFile(), []int{5}); err == nil {
+		t.Errorf("invalid indexes expect error")
+	}
+}
+
+func TestBuildProtoFilesError(t *testing.T) {
+	if _, err := buildProtoFiles(map[string]string{"a.proto": `syntax = "proto3"; import "missing.proto";`}); err == nil {
+		t.Errorf("missing import expect error")
+	}
+	if _, err := buildProtoFiles(map[string]string{"a.proto": `syntax = "proto3"; message A { B b = 1; }`}); err == nil {
+		t.Errorf("unknown type expect error")
+	}
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	files, err := buildProtoFiles(testProtoContents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := files.findMessage("User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := `{"id":"12","name":"a","addresses":[{"city":"x"}],"scores":{"m":3},"created":"2024-01-02T03:04:05Z","email":"a@b.c","tags":[{"value":"t"}]}`
+	bs, err := protoEncode(md, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := protoDecode(md, bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(t, res, text) {
+		t.Errorf("round trip expect %s, got %s", text, res)
+	}
+	// 未知 字段 忽略
+	if _, err = protoEncode(md, `{"id":"1","unknown":1}`); err != nil {
+		t.Errorf("unknown field expect discarded, error:%s", err)
+	}
+}
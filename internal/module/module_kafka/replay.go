@@ -0,0 +1,550 @@
+package module_kafka
+
+import (
+	"errors"
+	"github.com/Shopify/sarama"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/kafka"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+type ReplayRequest struct {
+	WorkerId string `json:"workerId"`
+	TaskId   string `json:"taskId"`
+
+	Topic        string          `json:"topic"`
+	Partitions   []int32         `json:"partitions"` // 为空 则 所有 分区
+	RangeType    string          `json:"rangeType"`  // timestamp offset 默认 timestamp
+	StartTime    int64           `json:"startTime"`  // 毫秒 包含
+	EndTime      int64           `json:"endTime"`    // 毫秒 不包含，为 0 则 到 当前 最新 位置
+	StartOffsets map[int32]int64 `json:"startOffsets"`
+	EndOffsets   map[int32]int64 `json:"endOffsets"` // 不包含，未 配置 的 分区 到 当前 最新 位置
+
+	KeyFilter   string `json:"keyFilter"` // Key 包含
+	HeaderKey   string `json:"headerKey"`
+	HeaderValue string `json:"headerValue"` // 为空 时 只 判断 Header 存在
+
+	TargetToolboxId int64  `json:"targetToolboxId"` // 为 0 则 写入 当前 工具
+	TargetTopic     string `json:"targetTopic"`     // 为空 则 写入 源 主题
+	KeepPartition   bool   `json:"keepPartition"`   // 写入 相同 分区 号，否则 按 Key 分区
+	KeepTimestamp   bool   `json:"keepTimestamp"`   // 保留 原 消息 时间
+	RateLimit       int    `json:"rateLimit"`       // 每秒 最多 写入 条数，0 表示 不限制
+}
+
+type ReplayPartition struct {
+	Partition   int32 `json:"partition"`
+	StartOffset int64 `json:"startOffset"`
+	EndOffset   int64 `json:"endOffset"` // 不包含
+	Offset      int64 `json:"offset"`    // 已 处理 到 的 位置
+	IsEnd       bool  `json:"isEnd"`
+}
+
+// ReplayTask 消息 重放 任务，读取 源 主题 区间 内 的 消息 写入 目标 主题，保留 Key 和 Header
+type ReplayTask struct {
+	TaskId     string             `json:"taskId"`
+	WorkerId   string             `json:"workerId"`
+	Request    *ReplayRequest     `json:"request"`
+	Partitions []*ReplayPartition `json:"partitions"`
+
+	Total   int64 `json:"total"`
+	Read    int64 `json:"read"`
+	Matched int64 `json:"matched"`
+	Written int64 `json:"written"`
+
+	IsEnd     bool      `json:"isEnd"`
+	IsStop    bool      `json:"isStop"`
+	StartTime time.Time `json:"startTime,omitempty"`
+	EndTime   time.Time `json:"endTime,omitempty"`
+	UseTime   int64     `json:"useTime"`
+	Error     string    `json:"error,omitempty"`
+
+	source       kafka.IService
+	target       kafka.IService
+	stopped      chan struct{}
+	stopOnce     sync.Once
+	lock         sync.Mutex
+	nextSendTime time.Time
+}
+
+var (
+	replayTaskCache     = map[string]*ReplayTask{}
+	replayTaskCacheLock = &sync.Mutex{}
+)
+
+func getReplayTask(taskId string) *ReplayTask {
+	replayTaskCacheLock.Lock()
+	defer replayTaskCacheLock.Unlock()
+	return replayTaskCache[taskId]
+}
+
+func getWorkerReplayTasks(workerId string) (list []*ReplayTask) {
+	replayTaskCacheLock.Lock()
+	defer replayTaskCacheLock.Unlock()
+	for _, one := range replayTaskCache {
+		if one.WorkerId == workerId {
+			list = append(list, one)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartTime.Before(list[j].StartTime)
+	})
+	return
+}
+
+func removeReplayTask(taskId string) {
+	replayTaskCacheLock.Lock()
+	one := replayTaskCache[taskId]
+	delete(replayTaskCache, taskId)
+	replayTaskCacheLock.Unlock()
+	if one != nil {
+		one.stop()
+	}
+}
+
+func removeWorkerReplayTasks(workerId string) {
+	for _, one := range getWorkerReplayTasks(workerId) {
+		removeReplayTask(one.TaskId)
+	}
+}
+
+func (this_ *ReplayTask) stop() {
+	this_.stopOnce.Do(func() {
+		this_.lock.Lock()
+		this_.IsStop = true
+		this_.lock.Unlock()
+		close(this_.stopped)
+	})
+}
+
+func (this_ *ReplayTask) isStopped() bool {
+	select {
+	case <-this_.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// status 复制 当前 状态 避免 并发 读写
+func (this_ *ReplayTask) status() *ReplayTask {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	res := &ReplayTask{
+		TaskId:    this_.TaskId,
+		WorkerId:  this_.WorkerId,
+		Request:   this_.Request,
+		Total:     this_.Total,
+		Read:      this_.Read,
+		Matched:   this_.Matched,
+		Written:   this_.Written,
+		IsEnd:     this_.IsEnd,
+		IsStop:    this_.IsStop,
+		StartTime: this_.StartTime,
+		EndTime:   this_.EndTime,
+		UseTime:   this_.UseTime,
+		Error:     this_.Error,
+	}
+	if !res.IsEnd {
+		res.UseTime = time.Since(this_.StartTime).Milliseconds()
+	}
+	for _, one := range this_.Partitions {
+		p := *one
+		res.Partitions = append(res.Partitions, &p)
+	}
+	return res
+}
+
+// waitRate 按 速率 限制 等待，返回 false 表示 任务 已 停止
+func (this_ *ReplayTask) waitRate() bool {
+	rateLimit := this_.Request.RateLimit
+	if rateLimit <= 0 {
+		return !this_.isStopped()
+	}
+	interval := time.Second / time.Duration(rateLimit)
+	this_.lock.Lock()
+	now := time.Now()
+	if this_.nextSendTime.Before(now) {
+		this_.nextSendTime = now
+	}
+	wait := this_.nextSendTime.Sub(now)
+	this_.nextSendTime = this_.nextSendTime.Add(interval)
+	this_.lock.Unlock()
+	if wait <= 0 {
+		return !this_.isStopped()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-this_.stopped:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (this_ *ReplayTask) match(msg *sarama.ConsumerMessage) bool {
+	request := this_.Request
+	if request.KeyFilter != "" && !strings.Contains(string(msg.Key), request.KeyFilter) {
+		return false
+	}
+	if request.HeaderKey != "" {
+		for _, header := range msg.Headers {
+			if header == nil || string(header.Key) != request.HeaderKey {
+				continue
+			}
+			if request.HeaderValue == "" || string(header.Value) == request.HeaderValue {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// offsetClient prepare 只 需要 分区 和 位置 查询，sarama.Client 满足 该 接口
+type offsetClient interface {
+	Partitions(topic string) ([]int32, error)
+	GetOffset(topic string, partitionID int32, time int64) (int64, error)
+}
+
+// prepare 计算 每个 分区 的 读取 区间
+func (this_ *ReplayTask) prepare(client offsetClient) (err error) {
+	request := this_.Request
+	partitions := request.Partitions
+	if len(partitions) == 0 {
+		if partitions, err = client.Partitions(request.Topic); err != nil {
+			return
+		}
+	}
+	for _, partition := range partitions {
+		var oldest, newest int64
+		if oldest, err = client.GetOffset(request.Topic, partition, sarama.OffsetOldest); err != nil {
+			return
+		}
+		if newest, err = client.GetOffset(request.Topic, partition, sarama.OffsetNewest); err != nil {
+			return
+		}
+		one := &ReplayPartition{Partition: partition, StartOffset: oldest, EndOffset: newest}
+		if request.RangeType == "offset" {
+			if v, ok := request.StartOffsets[partition]; ok && v > oldest {
+				one.StartOffset = v
+			}
+			if v, ok := request.EndOffsets[partition]; ok && v < newest {
+				one.EndOffset = v
+			}
+		} else {
+			if request.StartTime > 0 {
+				var offset int64
+				if offset, err = client.GetOffset(request.Topic, partition, request.StartTime); err != nil {
+					return
+				}
+				// -1 表示 该 时间 之后 没有 消息
+				if offset < 0 {
+					offset = newest
+				}
+				one.StartOffset = offset
+			}
+			if request.EndTime > 0 {
+				var offset int64
+				if offset, err = client.GetOffset(request.Topic, partition, request.EndTime); err != nil {
+					return
+				}
+				if offset >= 0 && offset < newest {
+					one.EndOffset = offset
+				}
+			}
+		}
+		if one.EndOffset < one.StartOffset {
+			one.EndOffset = one.StartOffset
+		}
+		one.Offset = one.StartOffset
+		one.IsEnd = one.StartOffset >= one.EndOffset
+		this_.Partitions = append(this_.Partitions, one)
+		this_.Total += one.EndOffset - one.StartOffset
+	}
+	return
+}
+
+func (this_ *ReplayTask) run() {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("kafka replay task error", zap.Any("error", e))
+			this_.lock.Lock()
+			this_.Error = util.GetStringValue(e)
+			this_.lock.Unlock()
+		}
+		this_.lock.Lock()
+		this_.IsEnd = true
+		this_.EndTime = time.Now()
+		this_.UseTime = this_.EndTime.Sub(this_.StartTime).Milliseconds()
+		this_.lock.Unlock()
+		util.Logger.Info("kafka replay task end", zap.Any("taskId", this_.TaskId), zap.Any("read", this_.Read), zap.Any("written", this_.Written))
+	}()
+	err := this_.doRun()
+	if err != nil {
+		util.Logger.Error("kafka replay task error", zap.Any("taskId", this_.TaskId), zap.Error(err))
+		this_.lock.Lock()
+		this_.Error = err.Error()
+		this_.lock.Unlock()
+	}
+}
+
+func (this_ *ReplayTask) doRun() (err error) {
+	sourceClient, err := this_.source.GetClient()
+	if err != nil {
+		return
+	}
+	defer func() { _ = sourceClient.Close() }()
+	if err = this_.prepare(sourceClient); err != nil {
+		return
+	}
+
+	targetClient, err := this_.target.GetClient()
+	if err != nil {
+		return
+	}
+	defer func() { _ = targetClient.Close() }()
+	// 使用 独立 客户端 可以 修改 生产者 配置
+	targetConfig := targetClient.Config()
+	targetConfig.Producer.Return.Successes = true
+	targetConfig.Producer.Return.Errors = true
+	if this_.Request.KeepPartition {
+		targetConfig.Producer.Partitioner = sarama.NewManualPartitioner
+	}
+	producer, err := sarama.NewSyncProducerFromClient(targetClient)
+	if err != nil {
+		return
+	}
+	defer func() { _ = producer.Close() }()
+
+	consumer, err := sarama.NewConsumerFromClient(sourceClient)
+	if err != nil {
+		return
+	}
+	defer func() { _ = consumer.Close() }()
+
+	var wait sync.WaitGroup
+	var errOnce sync.Once
+	for _, partition := range this_.Partitions {
+		if partition.IsEnd {
+			continue
+		}
+		wait.Add(1)
+		go func(partition *ReplayPartition) {
+			defer wait.Done()
+			if e := this_.replayPartition(consumer, producer, partition); e != nil {
+				errOnce.Do(func() {
+					err = e
+				})
+				// 一个 分区 失败 则 停止 整个 任务
+				this_.stop()
+			}
+		}(partition)
+	}
+	wait.Wait()
+	return
+}
+
+// 分区 空闲 超过 该 时间 视为 读取 完成，用于 压缩 主题 等 位置 不连续 的 情况
+var replayIdleTimeout = 10 * time.Second
+
+func (this_ *ReplayTask) replayPartition(consumer sarama.Consumer, producer sarama.SyncProducer, partition *ReplayPartition) (err error) {
+	request := this_.Request
+	pc, err := consumer.ConsumePartition(request.Topic, partition.Partition, partition.StartOffset)
+	if err != nil {
+		return
+	}
+	defer pc.AsyncClose()
+	targetTopic := request.TargetTopic
+	if targetTopic == "" {
+		targetTopic = request.Topic
+	}
+	idle := time.NewTimer(replayIdleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case <-this_.stopped:
+			return
+		case <-idle.C:
+			this_.lock.Lock()
+			partition.IsEnd = true
+			this_.lock.Unlock()
+			return
+		case e, ok := <-pc.Errors():
+			if !ok {
+				return
+			}
+			return e
+		case msg, ok := <-pc.Messages():
+			if !ok {
+				return
+			}
+			idle.Reset(replayIdleTimeout)
+			if msg.Offset >= partition.EndOffset {
+				this_.lock.Lock()
+				partition.IsEnd = true
+				this_.lock.Unlock()
+				return
+			}
+			matched := this_.match(msg)
+			this_.lock.Lock()
+			this_.Read++
+			if matched {
+				this_.Matched++
+			}
+			this_.lock.Unlock()
+			if matched {
+				if !this_.waitRate() {
+					return
+				}
+				producerMessage := &sarama.ProducerMessage{
+					Topic:     targetTopic,
+					Partition: msg.Partition,
+					Timestamp: time.Now(),
+				}
+				if msg.Key != nil {
+					producerMessage.Key = sarama.ByteEncoder(msg.Key)
+				}
+				if msg.Value != nil {
+					producerMessage.Value = sarama.ByteEncoder(msg.Value)
+				}
+				if request.KeepTimestamp {
+					producerMessage.Timestamp = msg.Timestamp
+				}
+				for _, header := range msg.Headers {
+					if header != nil {
+						producerMessage.Headers = append(producerMessage.Headers, *header)
+					}
+				}
+				if _, _, err = producer.SendMessage(producerMessage); err != nil {
+					return
+				}
+				this_.lock.Lock()
+				this_.Written++
+				this_.lock.Unlock()
+			}
+			this_.lock.Lock()
+			partition.Offset = msg.Offset + 1
+			if partition.Offset >= partition.EndOffset {
+				partition.IsEnd = true
+			}
+			end := partition.IsEnd
+			this_.lock.Unlock()
+			if end {
+				return
+			}
+		}
+	}
+}
+
+func (this_ *api) replayStart(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	source, err := getService(config)
+	if err != nil {
+		return
+	}
+
+	request := &ReplayRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Topic == "" {
+		err = errors.New("topic is empty")
+		return
+	}
+	target := source
+	if request.TargetToolboxId != 0 {
+		find, e := this_.toolboxService.Get(request.TargetToolboxId)
+		if e != nil {
+			return nil, e
+		}
+		if find == nil || find.ToolboxType != "kafka" {
+			err = errors.New("target kafka toolbox not found")
+			return
+		}
+		if err = this_.toolboxService.CheckToolboxPower(requestBean, find); err != nil {
+			return
+		}
+		targetConfig := &kafka.Config{}
+		if _, err = this_.toolboxService.BindConfigById(request.TargetToolboxId, targetConfig); err != nil {
+			return
+		}
+		if target, err = getService(targetConfig); err != nil {
+			return
+		}
+	}
+	task := &ReplayTask{
+		TaskId:    util.GetUUID(),
+		WorkerId:  request.WorkerId,
+		Request:   request,
+		StartTime: time.Now(),
+		source:    source,
+		target:    target,
+		stopped:   make(chan struct{}),
+	}
+	request.TaskId = task.TaskId
+	replayTaskCacheLock.Lock()
+	replayTaskCache[task.TaskId] = task
+	replayTaskCacheLock.Unlock()
+
+	util.Logger.Info("kafka replay task start", zap.Any("taskId", task.TaskId), zap.Any("topic", request.Topic), zap.Any("targetTopic", request.TargetTopic), zap.Any("targetToolboxId", request.TargetToolboxId))
+	go task.run()
+
+	res = task.status()
+	return
+}
+
+func (this_ *api) replayStatus(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ReplayRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	task := getReplayTask(request.TaskId)
+	if task != nil {
+		res = task.status()
+	}
+	return
+}
+
+func (this_ *api) replayStop(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ReplayRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	task := getReplayTask(request.TaskId)
+	if task != nil {
+		task.stop()
+	}
+	return
+}
+
+func (this_ *api) replayClean(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ReplayRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	removeReplayTask(request.TaskId)
+	return
+}
+
+func (this_ *api) replayList(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ReplayRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	var list []*ReplayTask
+	for _, one := range getWorkerReplayTasks(request.WorkerId) {
+		list = append(list, one.status())
+	}
+	res = list
+	return
+}
@@ -0,0 +1,144 @@
+package module_kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testOffsetClient struct {
+	partitions []int32
+	oldest     int64
+	newest     int64
+	times      map[int64]int64 // 时间 -> 位置
+}
+
+func (this_ *testOffsetClient) Partitions(_ string) ([]int32, error) {
+	return this_.partitions, nil
+}
+
+func (this_ *testOffsetClient) GetOffset(_ string, _ int32, time int64) (int64, error) {
+	switch time {
+	case sarama.OffsetOldest:
+		return this_.oldest, nil
+	case sarama.OffsetNewest:
+		return this_.newest, nil
+	}
+	if offset, ok := this_.times[time]; ok {
+		return offset, nil
+	}
+	return -1, nil
+}
+
+func TestReplayPrepare(t *testing.T) {
+	client := &testOffsetClient{
+		partitions: []int32{0, 1},
+		oldest:     10,
+		newest:     100,
+		times:      map[int64]int64{1000: 20, 2000: 50, 3000: 200},
+	}
+	for _, one := range []struct {
+		name    string
+		request *ReplayRequest
+		expect  []*ReplayPartition
+		total   int64
+	}{
+		{"all", &ReplayRequest{Partitions: []int32{0}},
+			[]*ReplayPartition{{Partition: 0, StartOffset: 10, EndOffset: 100, Offset: 10}}, 90},
+		{"topic partitions", &ReplayRequest{},
+			[]*ReplayPartition{{Partition: 0, StartOffset: 10, EndOffset: 100, Offset: 10}, {Partition: 1, StartOffset: 10, EndOffset: 100, Offset: 10}}, 180},
+		// 超出 范围 的 位置 收敛 到 oldest newest
+		{"offset clamp", &ReplayRequest{Partitions: []int32{0}, RangeType: "offset", StartOffsets: map[int32]int64{0: 5}, EndOffsets: map[int32]int64{0: 500}},
+			[]*ReplayPartition{{Partition: 0, StartOffset: 10, EndOffset: 100, Offset: 10}}, 90},
+		{"offset", &ReplayRequest{Partitions: []int32{0, 1}, RangeType: "offset", StartOffsets: map[int32]int64{0: 30}, EndOffsets: map[int32]int64{1: 40}},
+			[]*ReplayPartition{{Partition: 0, StartOffset: 30, EndOffset: 100, Offset: 30}, {Partition: 1, StartOffset: 10, EndOffset: 40, Offset: 10}}, 100},
+		{"offset end before start", &ReplayRequest{Partitions: []int32{0}, RangeType: "offset", StartOffsets: map[int32]int64{0: 60}, EndOffsets: map[int32]int64{0: 40}},
+			[]*ReplayPartition{{Partition: 0, StartOffset: 60, EndOffset: 60, Offset: 60, IsEnd: true}}, 0},
+		{"time", &ReplayRequest{Partitions: []int32{0}, StartTime: 1000, EndTime: 2000},
+			[]*ReplayPartition{{Partition: 0, StartOffset: 20, EndOffset: 50, Offset: 20}}, 30},
+		// 开始 时间 之后 没有 消息
+		{"time start after newest", &ReplayRequest{Partitions: []int32{0}, StartTime: 9000},
+			[]*ReplayPartition{{Partition: 0, StartOffset: 100, EndOffset: 100, Offset: 100, IsEnd: true}}, 0},
+		// 结束 时间 之后 没有 消息 或 超出 newest 则 到 最新 位置
+		{"time end after newest", &ReplayRequest{Partitions: []int32{0}, StartTime: 1000, EndTime: 9000},
+			[]*ReplayPartition{{Partition: 0, StartOffset: 20, EndOffset: 100, Offset: 20}}, 80},
+		{"time end beyond newest", &ReplayRequest{Partitions: []int32{0}, EndTime: 3000},
+			[]*ReplayPartition{{Partition: 0, StartOffset: 10, EndOffset: 100, Offset: 10}}, 90},
+	} {
+		task := &ReplayTask{Request: one.request}
+		if err := task.prepare(client); err != nil {
+			t.Fatalf("%s prepare error:%s", one.name, err)
+		}
+		if !reflect.DeepEqual(task.Partitions, one.expect) {
+			t.Errorf("%s partitions expect %+v, got %+v", one.name, one.expect, task.Partitions)
+		}
+		if task.Total != one.total {
+			t.Errorf("%s total expect %d, got %d", one.name, one.total, task.Total)
+		}
+	}
+}
+
+func TestReplayMatch(t *testing.T) {
+	msg := &sarama.ConsumerMessage{
+		Key:     []byte("order-1"),
+		Headers: []*sarama.RecordHeader{nil, {Key: []byte("type"), Value: []byte("create")}},
+	}
+	for _, one := range []struct {
+		request *ReplayRequest
+		expect  bool
+	}{
+		{&ReplayRequest{}, true},
+		{&ReplayRequest{KeyFilter: "order"}, true},
+		{&ReplayRequest{KeyFilter: "user"}, false},
+		{&ReplayRequest{HeaderKey: "type"}, true},
+		{&ReplayRequest{HeaderKey: "type", HeaderValue: "create"}, true},
+		{&ReplayRequest{HeaderKey: "type", HeaderValue: "delete"}, false},
+		{&ReplayRequest{HeaderKey: "source"}, false},
+		{&ReplayRequest{KeyFilter: "user", HeaderKey: "type"}, false},
+	} {
+		task := &ReplayTask{Request: one.request}
+		if res := task.match(msg); res != one.expect {
+			t.Errorf("request %+v expect %v, got %v", one.request, one.expect, res)
+		}
+	}
+}
+
+func TestReplayWaitRate(t *testing.T) {
+	task := &ReplayTask{Request: &ReplayRequest{}, stopped: make(chan struct{})}
+	if !task.waitRate() {
+		t.Errorf("no rate limit expect true")
+	}
+
+	// 每秒 1000 条 连续 调用 间隔 1 毫秒
+	task = &ReplayTask{Request: &ReplayRequest{RateLimit: 1000}, stopped: make(chan struct{})}
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		if !task.waitRate() {
+			t.Fatalf("rate limit expect true")
+		}
+	}
+	if use := time.Since(start); use < 15*time.Millisecond {
+		t.Errorf("20 sends at 1000/s expect about 19ms, got %s", use)
+	}
+
+	// 停止 后 等待 中 的 调用 立即 返回 false
+	task = &ReplayTask{Request: &ReplayRequest{RateLimit: 1}, stopped: make(chan struct{})}
+	if !task.waitRate() {
+		t.Fatalf("first send expect true")
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		task.stop()
+	}()
+	start = time.Now()
+	if task.waitRate() {
+		t.Errorf("stopped task expect false")
+	}
+	if use := time.Since(start); use > 500*time.Millisecond {
+		t.Errorf("stop expect wake wait, got %s", use)
+	}
+	if task.waitRate() {
+		t.Errorf("stopped task expect false")
+	}
+}
@@ -0,0 +1,514 @@
+package module_kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Shopify/sarama"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/kafka"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+// 消息 Key Value 类型 除 string long 外 支持 的 类型
+const (
+	// dataTypeSchemaRegistry Confluent 格式 魔数 0 + 4 字节 Schema ID，按 注册中心 的 Avro Protobuf JSON 模式 编解码
+	dataTypeSchemaRegistry = "schemaRegistry"
+	// dataTypeProtobuf 不带 头部 的 Protobuf 数据，使用 工具 配置 中 上传 的 .proto 文件 和 指定 的 消息 类型
+	dataTypeProtobuf = "protobuf"
+)
+
+func isSchemaDataType(dataType string) bool {
+	return dataType == dataTypeSchemaRegistry || dataType == dataTypeProtobuf
+}
+
+type ProtoFile struct {
+	Name string `json:"name"` // import 使用 的 路径，为空 则 使用 文件名
+	Path string `json:"path"`
+}
+
+// SchemaConfig Kafka 工具 配置 中 Schema 相关 配置
+type SchemaConfig struct {
+	SchemaRegistryUrl      string       `json:"schemaRegistryUrl"`
+	SchemaRegistryUsername string       `json:"schemaRegistryUsername"`
+	SchemaRegistryPassword string       `json:"schemaRegistryPassword"`
+	ProtoFiles             []*ProtoFile `json:"protoFiles"`
+}
+
+func (this_ *api) getSchemaConfig(requestBean *base.RequestBean, c *gin.Context) (config *SchemaConfig, err error) {
+	config = &SchemaConfig{}
+	_, err = this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	config.SchemaRegistryPassword = this_.toolboxService.DecryptOptionAttr(config.SchemaRegistryPassword)
+	for _, one := range config.ProtoFiles {
+		if one.Path != "" {
+			one.Path = this_.toolboxService.GetFilesFile(one.Path)
+		}
+	}
+	return
+}
+
+type registrySchema struct {
+	Id         int                  `json:"id"`
+	Subject    string               `json:"subject,omitempty"`
+	Version    int                  `json:"version,omitempty"`
+	SchemaType string               `json:"schemaType,omitempty"` // AVRO PROTOBUF JSON 为空 表示 AVRO
+	Schema     string               `json:"schema"`
+	References []*registryReference `json:"references,omitempty"`
+
+	avro  *avroSchema
+	proto protoreflect.FileDescriptor
+}
+
+type registryReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// schemaCodec 按 工具 配置 缓存 注册中心 模式 和 解析 后的 .proto 文件
+type schemaCodec struct {
+	config     *SchemaConfig
+	httpClient *http.Client
+	byId       map[int]*registrySchema
+	lock       sync.Mutex
+
+	protoFiles *protoFiles
+	protoError error
+	protoOnce  sync.Once
+}
+
+var (
+	schemaCodecCache     = map[string]*schemaCodec{}
+	schemaCodecCacheLock = &sync.Mutex{}
+)
+
+func getSchemaCodec(config *SchemaConfig) *schemaCodec {
+	key := config.SchemaRegistryUrl + "-" + base.GetMd5String(config.SchemaRegistryUsername+config.SchemaRegistryPassword)
+	for _, one := range config.ProtoFiles {
+		key += "-" + one.Name + ":" + one.Path
+		if info, e := os.Stat(one.Path); e == nil {
+			key += ":" + strconv.FormatInt(info.ModTime().UnixNano(), 10)
+		}
+	}
+	schemaCodecCacheLock.Lock()
+	defer schemaCodecCacheLock.Unlock()
+	codec, ok := schemaCodecCache[key]
+	if !ok {
+		codec = &schemaCodec{
+			config:     config,
+			httpClient: &http.Client{Timeout: 10 * time.Second},
+			byId:       map[int]*registrySchema{},
+		}
+		schemaCodecCache[key] = codec
+	}
+	return codec
+}
+
+func (this_ *schemaCodec) registryGet(path string, res interface{}) (err error) {
+	if this_.config.SchemaRegistryUrl == "" {
+		err = errors.New("schema registry url is empty, please config it in toolbox")
+		return
+	}
+	request, err := http.NewRequest("GET", strings.TrimSuffix(this_.config.SchemaRegistryUrl, "/")+path, nil)
+	if err != nil {
+		return
+	}
+	request.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if this_.config.SchemaRegistryUsername != "" {
+		request.SetBasicAuth(this_.config.SchemaRegistryUsername, this_.config.SchemaRegistryPassword)
+	}
+	response, err := this_.httpClient.Do(request)
+	if err != nil {
+		return
+	}
+	defer func() { _ = response.Body.Close() }()
+	bs, err := io.ReadAll(response.Body)
+	if err != nil {
+		return
+	}
+	if response.StatusCode != http.StatusOK {
+		err = fmt.Errorf("schema registry [%s] status [%d] error:%s", path, response.StatusCode, string(bs))
+		return
+	}
+	err = json.Unmarshal(bs, res)
+	return
+}
+
+// getById 根据 Schema ID 获取 模式，结果 缓存
+func (this_ *schemaCodec) getById(id int) (schema *registrySchema, err error) {
+	this_.lock.Lock()
+	schema = this_.byId[id]
+	this_.lock.Unlock()
+	if schema != nil {
+		return
+	}
+	schema = &registrySchema{}
+	if err = this_.registryGet("/schemas/ids/"+strconv.Itoa(id), schema); err != nil {
+		return
+	}
+	schema.Id = id
+	if err = this_.prepare(schema); err != nil {
+		return
+	}
+	this_.lock.Lock()
+	this_.byId[id] = schema
+	this_.lock.Unlock()
+	return
+}
+
+// getLatest 获取 subject 最新 版本 的 模式，用于 推送 编码，不 缓存 以便 获取 新 版本
+func (this_ *schemaCodec) getLatest(subject string) (schema *registrySchema, err error) {
+	schema = &registrySchema{}
+	if err = this_.registryGet("/subjects/"+url.PathEscape(subject)+"/versions/latest", schema); err != nil {
+		return
+	}
+	this_.lock.Lock()
+	find := this_.byId[schema.Id]
+	this_.lock.Unlock()
+	if find != nil {
+		schema = find
+		return
+	}
+	if err = this_.prepare(schema); err != nil {
+		return
+	}
+	this_.lock.Lock()
+	this_.byId[schema.Id] = schema
+	this_.lock.Unlock()
+	return
+}
+
+// loadReferences 递归 加载 Protobuf 模式 引用 的 文件
+func (this_ *schemaCodec) loadReferences(references []*registryReference, contents map[string]string) (err error) {
+	for _, reference := range references {
+		if _, ok := contents[reference.Name]; ok {
+			continue
+		}
+		one := &registrySchema{}
+		if err = this_.registryGet("/subjects/"+url.PathEscape(reference.Subject)+"/versions/"+strconv.Itoa(reference.Version), one); err != nil {
+			return
+		}
+		contents[reference.Name] = one.Schema
+		if err = this_.loadReferences(one.References, contents); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (this_ *schemaCodec) prepare(schema *registrySchema) (err error) {
+	switch strings.ToUpper(schema.SchemaType) {
+	case "", "AVRO":
+		schema.avro, err = parseAvroSchema(schema.Schema)
+	case "PROTOBUF":
+		name := fmt.Sprintf("schema-%d.proto", schema.Id)
+		contents := map[string]string{name: schema.Schema}
+		if err = this_.loadReferences(schema.References, contents); err != nil {
+			return
+		}
+		var files *protoFiles
+		if files, err = buildProtoFiles(contents); err != nil {
+			return
+		}
+		schema.proto, err = files.FindFileByPath(name)
+	case "JSON":
+	default:
+		err = errors.New("schema type [" + schema.SchemaType + "] not support")
+	}
+	return
+}
+
+// getProtoFiles 解析 工具 配置 中 上传 的 .proto 文件
+func (this_ *schemaCodec) getProtoFiles() (*protoFiles, error) {
+	this_.protoOnce.Do(func() {
+		if len(this_.config.ProtoFiles) == 0 {
+			this_.protoError = errors.New("proto files is empty, please upload it in toolbox")
+			return
+		}
+		contents := map[string]string{}
+		for _, one := range this_.config.ProtoFiles {
+			bs, err := os.ReadFile(one.Path)
+			if err != nil {
+				this_.protoError = err
+				return
+			}
+			name := one.Name
+			if name == "" {
+				name = filepath.Base(one.Path)
+			}
+			contents[name] = string(bs)
+		}
+		this_.protoFiles, this_.protoError = buildProtoFiles(contents)
+	})
+	return this_.protoFiles, this_.protoError
+}
+
+// readIndexes 读取 Confluent Protobuf 消息 下标，zigzag varint 编码，单个 0 表示 [0]
+func readIndexes(data []byte) (indexes []int, size int, err error) {
+	count, n := binary.Varint(data)
+	if n <= 0 {
+		err = errors.New("protobuf message indexes invalid")
+		return
+	}
+	size += n
+	if count == 0 {
+		indexes = []int{0}
+		return
+	}
+	for i := int64(0); i < count; i++ {
+		index, n := binary.Varint(data[size:])
+		if n <= 0 {
+			err = errors.New("protobuf message indexes invalid")
+			return
+		}
+		size += n
+		indexes = append(indexes, int(index))
+	}
+	return
+}
+
+func writeIndexes(indexes []int) (bs []byte) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return []byte{0}
+	}
+	n := binary.PutVarint(buf, int64(len(indexes)))
+	bs = append(bs, buf[:n]...)
+	for _, index := range indexes {
+		n = binary.PutVarint(buf, int64(index))
+		bs = append(bs, buf[:n]...)
+	}
+	return
+}
+
+// decode 按 类型 解码 消息 Key 或 Value 为 字符串，messageName 用于 protobuf 类型
+func (this_ *schemaCodec) decode(data []byte, dataType string, messageName string) (res string, err error) {
+	if len(data) == 0 {
+		return
+	}
+	switch dataType {
+	case dataTypeProtobuf:
+		var files *protoFiles
+		if files, err = this_.getProtoFiles(); err != nil {
+			return
+		}
+		var md protoreflect.MessageDescriptor
+		if md, err = files.findMessage(messageName); err != nil {
+			return
+		}
+		return protoDecode(md, data)
+	case dataTypeSchemaRegistry:
+		if len(data) < 5 || data[0] != 0 {
+			err = errors.New("data is not schema registry format, magic byte not found")
+			return
+		}
+		id := int(binary.BigEndian.Uint32(data[1:5]))
+		var schema *registrySchema
+		if schema, err = this_.getById(id); err != nil {
+			return
+		}
+		payload := data[5:]
+		switch {
+		case schema.avro != nil:
+			return avroDecode(schema.avro, payload)
+		case schema.proto != nil:
+			indexes, size, e := readIndexes(payload)
+			if e != nil {
+				return "", e
+			}
+			md, e := messageByIndexes(schema.proto, indexes)
+			if e != nil {
+				return "", e
+			}
+			return protoDecode(md, payload[size:])
+		default:
+			return string(payload), nil
+		}
+	}
+	return string(data), nil
+}
+
+// encode 按 类型 编码 消息 Key 或 Value，schemaRegistry 类型 使用 主题 对应 subject 的 最新 版本
+func (this_ *schemaCodec) encode(topic string, isKey bool, text string, dataType string, messageName string) (res []byte, err error) {
+	switch dataType {
+	case dataTypeProtobuf:
+		var files *protoFiles
+		if files, err = this_.getProtoFiles(); err != nil {
+			return
+		}
+		var md protoreflect.MessageDescriptor
+		if md, err = files.findMessage(messageName); err != nil {
+			return
+		}
+		return protoEncode(md, text)
+	case dataTypeSchemaRegistry:
+		subject := topic + "-value"
+		if isKey {
+			subject = topic + "-key"
+		}
+		var schema *registrySchema
+		if schema, err = this_.getLatest(subject); err != nil {
+			return
+		}
+		res = make([]byte, 5)
+		binary.BigEndian.PutUint32(res[1:], uint32(schema.Id))
+		var payload []byte
+		switch {
+		case schema.avro != nil:
+			payload, err = avroEncode(schema.avro, text)
+		case schema.proto != nil:
+			var md protoreflect.MessageDescriptor
+			if messageName != "" {
+				md = findMessageInList(schema.proto.Messages(), messageName)
+				if md == nil {
+					err = errors.New("proto message [" + messageName + "] not found in subject [" + subject + "]")
+					return
+				}
+			} else if md, err = messageByIndexes(schema.proto, []int{0}); err != nil {
+				return
+			}
+			res = append(res, writeIndexes(indexesByMessage(md))...)
+			payload, err = protoEncode(md, text)
+		default:
+			payload = []byte(text)
+		}
+		if err != nil {
+			return
+		}
+		res = append(res, payload...)
+		return
+	}
+	return []byte(text), nil
+}
+
+// consumerMessageToMessage 转换 消费 消息，Key Value 为 schema 类型 时 使用 原始 字节 解码
+func consumerMessageToMessage(codec *schemaCodec, keyType, keyMessageName, valueType, valueMessageName string, consumerMessage *sarama.ConsumerMessage) (msg *kafka.Message, err error) {
+	msg, err = kafka.ConsumerMessageToMessage(keyType, valueType, consumerMessage)
+	if err != nil {
+		return
+	}
+	if codec == nil {
+		return
+	}
+	if isSchemaDataType(keyType) {
+		if msg.Key, err = codec.decode(consumerMessage.Key, keyType, keyMessageName); err != nil {
+			err = errors.New("key decode error:" + err.Error())
+			return
+		}
+		msg.KeyType = keyType
+	}
+	if isSchemaDataType(valueType) {
+		if msg.Value, err = codec.decode(consumerMessage.Value, valueType, valueMessageName); err != nil {
+			err = errors.New("value decode error:" + err.Error())
+			return
+		}
+		msg.ValueType = valueType
+	}
+	msg.Timestamp = &consumerMessage.Timestamp
+	return
+}
+
+type pullHandler struct {
+	messages []*sarama.ConsumerMessage
+	cancel   context.CancelFunc
+	size     int
+	lock     sync.Mutex
+}
+
+func (*pullHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
+func (*pullHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+func (this_ *pullHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	if sess == nil || claim == nil {
+		return nil
+	}
+	for msg := range claim.Messages() {
+		this_.lock.Lock()
+		this_.messages = append(this_.messages, msg)
+		full := len(this_.messages) >= this_.size
+		this_.lock.Unlock()
+		if full {
+			this_.cancel()
+			break
+		}
+	}
+	return nil
+}
+
+// pullRaw 与 service.Pull 相同 方式 拉取，返回 原始 消息 以便 按 schema 解码
+func pullRaw(service kafka.IService, groupId string, topic string, pullSize int, pullTimeout int) (messages []*sarama.ConsumerMessage, err error) {
+	if pullSize <= 0 {
+		pullSize = 10
+	}
+	if pullTimeout <= 0 {
+		pullTimeout = 1000
+	}
+	client, err := service.GetClient()
+	if err != nil {
+		return
+	}
+	defer func() { _ = client.Close() }()
+	group, err := sarama.NewConsumerGroupFromClient(groupId, client)
+	if err != nil {
+		return
+	}
+	defer func() { _ = group.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*time.Duration(pullTimeout))
+	defer cancel()
+	handler := &pullHandler{size: pullSize, cancel: cancel}
+	if e := group.Consume(ctx, []string{topic}, handler); e != nil {
+		util.Logger.Error("kafka pull consume error", zap.Error(e))
+	}
+	handler.lock.Lock()
+	messages = handler.messages
+	handler.lock.Unlock()
+	return
+}
+
+// pushMessageToProducerMessage 转换 推送 消息，Key Value 为 schema 类型 时 编码 为 对应 格式
+func pushMessageToProducerMessage(codec *schemaCodec, request *PushRequest) (producerMessage *sarama.ProducerMessage, err error) {
+	msg := request.Message
+	if isSchemaDataType(msg.KeyType) {
+		msg.Key = ""
+	}
+	if isSchemaDataType(msg.ValueType) {
+		msg.Value = ""
+	}
+	producerMessage, err = kafka.MessageToProducerMessage(&msg)
+	if err != nil {
+		return
+	}
+	if isSchemaDataType(request.KeyType) && request.Key != "" {
+		var bs []byte
+		if bs, err = codec.encode(request.Topic, true, request.Key, request.KeyType, request.KeyMessageName); err != nil {
+			err = errors.New("key encode error:" + err.Error())
+			return
+		}
+		producerMessage.Key = sarama.ByteEncoder(bs)
+	}
+	if isSchemaDataType(request.ValueType) && request.Value != "" {
+		var bs []byte
+		if bs, err = codec.encode(request.Topic, false, request.Value, request.ValueType, request.ValueMessageName); err != nil {
+			err = errors.New("value encode error:" + err.Error())
+			return
+		}
+		producerMessage.Value = sarama.ByteEncoder(bs)
+	}
+	return
+}
@@ -0,0 +1,327 @@
+package module_kafka
+
+import (
+	"errors"
+	"github.com/Shopify/sarama"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/kafka"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/jsonpath"
+	"time"
+)
+
+type TailRequest struct {
+	WorkerId         string          `json:"workerId"`
+	Key              string          `json:"key"`
+	Topic            string          `json:"topic"`
+	Partitions       []int32         `json:"partitions"`       // 为空 则 所有 分区
+	StartType        string          `json:"startType"`        // latest earliest timestamp offset 默认 latest
+	StartTime        int64           `json:"startTime"`        // 毫秒 startType 为 timestamp 时 使用
+	PartitionOffsets map[int32]int64 `json:"partitionOffsets"` // startType 为 offset 时 使用
+	KeyFilter        string          `json:"keyFilter"`        // Key 包含
+	HeaderKey        string          `json:"headerKey"`
+	HeaderValue      string          `json:"headerValue"` // 为空 时 只 判断 Header 存在
+	JsonPath         string          `json:"jsonPath"`    // 对 Value 的 JSONPath 表达式 如 $.type == "order"
+	RateLimit        int             `json:"rateLimit"`   // 每秒 最多 推送 条数 默认 100
+	KeyType          string          `json:"keyType"`
+	ValueType        string          `json:"valueType"`
+	KeyMessageName   string          `json:"keyMessageName"`   // protobuf 类型 时 的 消息 名称
+	ValueMessageName string          `json:"valueMessageName"` // protobuf 类型 时 的 消息 名称
+}
+
+type TailMessage struct {
+	Type      string                `json:"type"` // message stats error
+	Topic     string                `json:"topic,omitempty"`
+	Partition int32                 `json:"partition,omitempty"`
+	Offset    int64                 `json:"offset,omitempty"`
+	Key       string                `json:"key,omitempty"`
+	Value     string                `json:"value,omitempty"`
+	Headers   []kafka.MessageHeader `json:"headers,omitempty"`
+	Timestamp int64                 `json:"timestamp,omitempty"`
+	Time      int64                 `json:"time"`
+
+	Received int64  `json:"received,omitempty"`
+	Matched  int64  `json:"matched,omitempty"`
+	Sent     int64  `json:"sent,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// tailer 临时 消费者 直接 消费 分区，不加入 消费组 也不 提交 位置
+type tailer struct {
+	*base.WebsocketSession
+	request  *TailRequest
+	service  kafka.IService
+	codec    *schemaCodec
+	client   sarama.Client
+	consumer sarama.Consumer
+	pcList   []sarama.PartitionConsumer
+	buffer   chan *TailMessage
+
+	received int64
+	matched  int64
+	sent     int64
+	lock     sync.Mutex
+}
+
+var tailerCache = base.NewWebsocketCache("kafka tail")
+
+// startOffset 计算 分区 开始 位置
+func (this_ *tailer) startOffset(partition int32) (offset int64, err error) {
+	switch this_.request.StartType {
+	case "earliest":
+		offset = sarama.OffsetOldest
+	case "timestamp":
+		offset, err = this_.client.GetOffset(this_.request.Topic, partition, this_.request.StartTime)
+		if err != nil {
+			return
+		}
+		// 该 时间 之后 没有 消息
+		if offset < 0 {
+			offset = sarama.OffsetNewest
+		}
+	case "offset":
+		var find bool
+		offset, find = this_.request.PartitionOffsets[partition]
+		if !find {
+			offset = sarama.OffsetNewest
+		}
+	default:
+		offset = sarama.OffsetNewest
+	}
+	return
+}
+
+func (this_ *tailer) match(msg *sarama.ConsumerMessage, value string) bool {
+	request := this_.request
+	if request.KeyFilter != "" && !strings.Contains(string(msg.Key), request.KeyFilter) {
+		return false
+	}
+	if request.HeaderKey != "" {
+		var find bool
+		for _, header := range msg.Headers {
+			if header == nil || string(header.Key) != request.HeaderKey {
+				continue
+			}
+			if request.HeaderValue == "" || string(header.Value) == request.HeaderValue {
+				find = true
+				break
+			}
+		}
+		if !find {
+			return false
+		}
+	}
+	if request.JsonPath != "" {
+		ok, err := jsonpath.MatchJSON(value, request.JsonPath)
+		if err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// consume 读取 分区 消息，缓冲 已满 时 阻塞 等待，不 丢弃 消息
+func (this_ *tailer) consume(pc sarama.PartitionConsumer) {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("kafka tail consume error", zap.Any("error", e))
+		}
+	}()
+	for {
+		select {
+		case <-this_.Done():
+			return
+		case e, ok := <-pc.Errors():
+			if !ok {
+				return
+			}
+			this_.push(&TailMessage{Type: "error", Time: util.GetNowMilli(), Error: e.Error()})
+		case msg, ok := <-pc.Messages():
+			if !ok {
+				return
+			}
+			this_.lock.Lock()
+			this_.received++
+			this_.lock.Unlock()
+			request := this_.request
+			one, err := consumerMessageToMessage(this_.codec, request.KeyType, request.KeyMessageName, request.ValueType, request.ValueMessageName, msg)
+			if err != nil {
+				this_.push(&TailMessage{Type: "error", Time: util.GetNowMilli(), Error: err.Error()})
+				continue
+			}
+			if !this_.match(msg, one.Value) {
+				continue
+			}
+			this_.lock.Lock()
+			this_.matched++
+			this_.lock.Unlock()
+			this_.push(&TailMessage{
+				Type:      "message",
+				Topic:     msg.Topic,
+				Partition: msg.Partition,
+				Offset:    msg.Offset,
+				Key:       one.Key,
+				Value:     one.Value,
+				Headers:   one.Headers,
+				Timestamp: msg.Timestamp.UnixMilli(),
+				Time:      util.GetNowMilli(),
+			})
+		}
+	}
+}
+
+func (this_ *tailer) push(msg *TailMessage) {
+	select {
+	case <-this_.Done():
+	case this_.buffer <- msg:
+	}
+}
+
+func (this_ *tailer) stats() *TailMessage {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	return &TailMessage{
+		Type:     "stats",
+		Time:     util.GetNowMilli(),
+		Received: this_.received,
+		Matched:  this_.matched,
+		Sent:     this_.sent,
+	}
+}
+
+// send 按 速率 限制 推送 消息，并 每秒 推送 统计
+func (this_ *tailer) send() {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("kafka tail send error", zap.Any("error", e))
+		}
+		this_.Stop()
+	}()
+	limiter := time.NewTicker(base.RateLimitInterval(this_.request.RateLimit))
+	defer limiter.Stop()
+	statsTicker := time.NewTicker(time.Second)
+	defer statsTicker.Stop()
+	for {
+		select {
+		case <-this_.Done():
+			return
+		case <-statsTicker.C:
+			if this_.WriteJSON(this_.stats()) != nil {
+				return
+			}
+		case msg := <-this_.buffer:
+			if this_.WriteJSON(msg) != nil {
+				return
+			}
+			if msg.Type != "message" {
+				continue
+			}
+			this_.lock.Lock()
+			this_.sent++
+			this_.lock.Unlock()
+			select {
+			case <-this_.Done():
+				return
+			case <-limiter.C:
+			}
+		}
+	}
+}
+
+func (this_ *tailer) Start() (err error) {
+	this_.client, err = this_.service.GetClient()
+	if err != nil {
+		return
+	}
+	this_.consumer, err = sarama.NewConsumerFromClient(this_.client)
+	if err != nil {
+		return
+	}
+	partitions := this_.request.Partitions
+	if len(partitions) == 0 {
+		partitions, err = this_.client.Partitions(this_.request.Topic)
+		if err != nil {
+			return
+		}
+	}
+	for _, partition := range partitions {
+		var offset int64
+		offset, err = this_.startOffset(partition)
+		if err != nil {
+			return
+		}
+		var pc sarama.PartitionConsumer
+		pc, err = this_.consumer.ConsumePartition(this_.request.Topic, partition, offset)
+		if err != nil {
+			return
+		}
+		this_.pcList = append(this_.pcList, pc)
+	}
+	util.Logger.Info("kafka tail start", zap.Any("key", this_.Key), zap.Any("topic", this_.request.Topic), zap.Any("partitions", partitions))
+	for _, pc := range this_.pcList {
+		go this_.consume(pc)
+	}
+	go this_.send()
+	return
+}
+
+func (this_ *tailer) OnStop() {
+	for _, pc := range this_.pcList {
+		pc.AsyncClose()
+	}
+	if this_.consumer != nil {
+		_ = this_.consumer.Close()
+	}
+	if this_.client != nil {
+		_ = this_.client.Close()
+	}
+}
+
+// tailKey 创建 tail 会话，返回 key 用于 建立 websocket
+func (this_ *api) tailKey(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config)
+	if err != nil {
+		return
+	}
+
+	request := &TailRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Topic == "" {
+		err = errors.New("topic is empty")
+		return
+	}
+	if request.JsonPath != "" {
+		if _, err = jsonpath.Match(nil, request.JsonPath); err != nil {
+			return
+		}
+	}
+	if request.RateLimit <= 0 {
+		request.RateLimit = 100
+	}
+	one := &tailer{
+		WebsocketSession: base.NewWebsocketSession(requestBean, request.WorkerId),
+		request:          request,
+		service:          service,
+		buffer:           make(chan *TailMessage, 1000),
+	}
+	if isSchemaDataType(request.KeyType) || isSchemaDataType(request.ValueType) {
+		var schemaConfig *SchemaConfig
+		schemaConfig, err = this_.getSchemaConfig(requestBean, c)
+		if err != nil {
+			return
+		}
+		one.codec = getSchemaCodec(schemaConfig)
+	}
+	res = tailerCache.Add(one)
+	return
+}
@@ -0,0 +1,248 @@
+package module_mongodb
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strconv"
+	"strings"
+	"teamide/pkg/base"
+	"time"
+)
+
+// 预览 默认 条数 和 最大 条数
+const (
+	aggregatePreviewSize    = 20
+	aggregatePreviewMaxSize = 1000
+)
+
+type AggregateRequest struct {
+	WorkerId       string `json:"workerId"`
+	DatabaseName   string `json:"databaseName"`
+	CollectionName string `json:"collectionName"`
+	// 扩展 JSON 格式 的 管道，如 [{"$match":{"_id":{"$oid":"..."}}}]
+	Pipeline string `json:"pipeline"`
+	// 只 执行 前 N 个 阶段，用于 逐 阶段 预览，0 表示 所有 阶段
+	StageCount   int   `json:"stageCount"`
+	PreviewSize  int   `json:"previewSize"`
+	MaxTimeMS    int64 `json:"maxTimeMS"`
+	AllowDiskUse bool  `json:"allowDiskUse"`
+	// explain 模式 queryPlanner executionStats allPlansExecution，默认 queryPlanner
+	Verbosity string `json:"verbosity"`
+
+	ExportOption
+}
+
+// parsePipeline 解析 扩展 JSON 管道
+func parsePipeline(text string) (pipeline []bson.D, err error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	// 扩展 JSON 解析 需要 顶层 为 文档
+	data := &struct {
+		Pipeline []bson.D `bson:"pipeline"`
+	}{}
+	err = bson.UnmarshalExtJSON([]byte(`{"pipeline":`+text+`}`), false, data)
+	if err != nil {
+		err = errors.New("管道 解析 失败: " + err.Error())
+		return
+	}
+	for i, stage := range data.Pipeline {
+		if len(stage) != 1 || !strings.HasPrefix(stage[0].Key, "$") {
+			err = errors.New("管道 第 " + strconv.Itoa(i+1) + " 个 阶段 格式 错误，每个 阶段 只能 包含 一个 $ 开头 的 操作符")
+			return
+		}
+	}
+	pipeline = data.Pipeline
+	return
+}
+
+// getStages 获取 需要 执行 的 阶段，预览 和 导出 不 允许 写入 阶段
+func (this_ *AggregateRequest) getStages() (pipeline []bson.D, total int, err error) {
+	pipeline, err = parsePipeline(this_.Pipeline)
+	if err != nil {
+		return
+	}
+	total = len(pipeline)
+	if this_.StageCount > 0 && this_.StageCount < total {
+		pipeline = pipeline[:this_.StageCount]
+	}
+	for _, stage := range pipeline {
+		if stage[0].Key == "$out" || stage[0].Key == "$merge" {
+			err = errors.New("不支持 " + stage[0].Key + " 阶段")
+			return
+		}
+	}
+	return
+}
+
+func (this_ *AggregateRequest) getOptions() *options.AggregateOptions {
+	opts := options.Aggregate()
+	if this_.MaxTimeMS > 0 {
+		opts.SetMaxTime(time.Duration(this_.MaxTimeMS) * time.Millisecond)
+	}
+	if this_.AllowDiskUse {
+		opts.SetAllowDiskUse(true)
+	}
+	return opts
+}
+
+func (this_ *api) getClient(requestBean *base.RequestBean, c *gin.Context) (client *mongo.Client, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	client, err = getClient(config)
+	return
+}
+
+// aggregate 执行 管道 前 N 个 阶段，返回 前 previewSize 条 结果
+func (this_ *api) aggregate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &AggregateRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	pipeline, total, err := request.getStages()
+	if err != nil {
+		return
+	}
+	previewSize := request.PreviewSize
+	if previewSize <= 0 {
+		previewSize = aggregatePreviewSize
+	}
+	if previewSize > aggregatePreviewMaxSize {
+		previewSize = aggregatePreviewMaxSize
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$limit", Value: previewSize}})
+
+	startTime := time.Now()
+	ctx := context.Background()
+	cursor, err := client.Database(request.DatabaseName).Collection(request.CollectionName).Aggregate(ctx, pipeline, request.getOptions())
+	if err != nil {
+		return
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	var list []string
+	for cursor.Next(ctx) {
+		var bs []byte
+		if bs, err = bson.MarshalExtJSONIndent(cursor.Current, false, false, "", "  "); err != nil {
+			return
+		}
+		list = append(list, string(bs))
+	}
+	if err = cursor.Err(); err != nil {
+		return
+	}
+	res = map[string]interface{}{
+		"stageCount": len(pipeline) - 1,
+		"stageTotal": total,
+		"list":       list,
+		"useTime":    time.Since(startTime).Milliseconds(),
+	}
+	return
+}
+
+// aggregateExplain 返回 管道 前 N 个 阶段 的 执行 计划
+func (this_ *api) aggregateExplain(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &AggregateRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	pipeline, _, err := request.getStages()
+	if err != nil {
+		return
+	}
+	verbosity := request.Verbosity
+	switch verbosity {
+	case "":
+		verbosity = "queryPlanner"
+	case "queryPlanner", "executionStats", "allPlansExecution":
+	default:
+		err = errors.New("不支持 的 verbosity [" + verbosity + "]")
+		return
+	}
+	if pipeline == nil {
+		pipeline = []bson.D{}
+	}
+	aggregateCommand := bson.D{
+		{Key: "aggregate", Value: request.CollectionName},
+		{Key: "pipeline", Value: pipeline},
+		{Key: "cursor", Value: bson.D{}},
+	}
+	if request.AllowDiskUse {
+		aggregateCommand = append(aggregateCommand, bson.E{Key: "allowDiskUse", Value: true})
+	}
+	command := bson.D{
+		{Key: "explain", Value: aggregateCommand},
+		{Key: "verbosity", Value: verbosity},
+	}
+	if request.MaxTimeMS > 0 {
+		command = append(command, bson.E{Key: "maxTimeMS", Value: request.MaxTimeMS})
+	}
+	raw, err := client.Database(request.DatabaseName).RunCommand(context.Background(), command).Raw()
+	if err != nil {
+		return
+	}
+	bs, err := bson.MarshalExtJSONIndent(raw, false, false, "", "  ")
+	if err != nil {
+		return
+	}
+	res = string(bs)
+	return
+}
+
+// aggregateExport 执行 管道 并 将 全部 结果 导出 为 文件，后台 任务 执行
+func (this_ *api) aggregateExport(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &AggregateRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	pipeline, _, err := request.getStages()
+	if err != nil {
+		return
+	}
+	if err = request.ExportOption.check(); err != nil {
+		return
+	}
+	if pipeline == nil {
+		pipeline = []bson.D{}
+	}
+
+	fileName := request.CollectionName + "-aggregate-" + time.Now().Format("20060102150405") + getFileSuffix(request.FileType)
+	task := newTask(requestBean, request.WorkerId, "aggregateExport", func(task *Task) (err error) {
+		cursor, err := client.Database(request.DatabaseName).Collection(request.CollectionName).Aggregate(task.ctx, pipeline, request.getOptions())
+		if err != nil {
+			return
+		}
+		err = exportCursor(task, cursor, fileName, &request.ExportOption)
+		return
+	})
+	if task.dir, err = this_.getTaskDir(requestBean, task.TaskId); err != nil {
+		return
+	}
+	task.DatabaseName = request.DatabaseName
+	task.CollectionName = request.CollectionName
+	startTask(task)
+	res = task.status()
+	return
+}
@@ -0,0 +1,71 @@
+package module_mongodb
+
+import (
+	"testing"
+)
+
+func TestParsePipeline(t *testing.T) {
+	for _, one := range []struct {
+		text   string
+		stages []string
+		error  bool
+	}{
+		{"", nil, false},
+		{"  ", nil, false},
+		{`[]`, nil, false},
+		{`[{"$match":{"a":1}},{"$group":{"_id":"$b","n":{"$sum":1}}}]`, []string{"$match", "$group"}, false},
+		// 扩展 JSON
+		{`[{"$match":{"_id":{"$oid":"5f0c5a0e9d1b2c3d4e5f6a7b"},"t":{"$date":"2024-01-02T03:04:05Z"}}}]`, []string{"$match"}, false},
+		{`[{"$match":{}},{"$limit":1,"$skip":1}]`, nil, true},
+		{`[{"match":{}}]`, nil, true},
+		{`[{}]`, nil, true},
+		{`{"$match":{}}`, nil, true},
+		{`[{"$match":`, nil, true},
+	} {
+		pipeline, err := parsePipeline(one.text)
+		if (err != nil) != one.error {
+			t.Errorf("pipeline %s expect error %v, got %v", one.text, one.error, err)
+			continue
+		}
+		if one.error {
+			continue
+		}
+		if len(pipeline) != len(one.stages) {
+			t.Errorf("pipeline %s expect %d stages, got %d", one.text, len(one.stages), len(pipeline))
+			continue
+		}
+		for i, stage := range pipeline {
+			if stage[0].Key != one.stages[i] {
+				t.Errorf("pipeline %s stage %d expect %s, got %s", one.text, i, one.stages[i], stage[0].Key)
+			}
+		}
+	}
+}
+
+func TestAggregateGetStages(t *testing.T) {
+	pipeline := `[{"$match":{}},{"$sort":{"a":1}},{"$out":"x"}]`
+	for _, one := range []struct {
+		stageCount int
+		expect     int
+		error      bool
+	}{
+		{1, 1, false},
+		{2, 2, false},
+		// 包含 写入 阶段
+		{0, 0, true},
+		{3, 0, true},
+	} {
+		request := &AggregateRequest{Pipeline: pipeline, StageCount: one.stageCount}
+		stages, total, err := request.getStages()
+		if (err != nil) != one.error {
+			t.Errorf("stage count %d expect error %v, got %v", one.stageCount, one.error, err)
+			continue
+		}
+		if total != 3 {
+			t.Errorf("stage count %d expect total 3, got %d", one.stageCount, total)
+		}
+		if !one.error && len(stages) != one.expect {
+			t.Errorf("stage count %d expect %d stages, got %d", one.stageCount, one.expect, len(stages))
+		}
+	}
+}
@@ -42,6 +42,9 @@ var (
 	collectionDelete   = base.AppendPower(&base.PowerAction{Action: "delete", Text: "删除", ShouldLogin: true, StandAlone: true, Parent: collection})
 	collectionCreate   = base.AppendPower(&base.PowerAction{Action: "create", Text: "创建", ShouldLogin: true, StandAlone: true, Parent: collection})
 	collectionDataTrim = base.AppendPower(&base.PowerAction{Action: "dataTrim", Text: "清空数据", ShouldLogin: true, StandAlone: true, Parent: collection})
+	collectionExport   = base.AppendPower(&base.PowerAction{Action: "export", Text: "导出", ShouldLogin: true, StandAlone: true, Parent: collection})
+	collectionImport   = base.AppendPower(&base.PowerAction{Action: "import", Text: "导入", ShouldLogin: true, StandAlone: true, Parent: collection})
+	collectionCopy     = base.AppendPower(&base.PowerAction{Action: "copy", Text: "复制", ShouldLogin: true, StandAlone: true, Parent: collection})
 
 	index       = base.AppendPower(&base.PowerAction{Action: "index", Text: "索引", ShouldLogin: true, StandAlone: true, Parent: Power})
 	indexList   = base.AppendPower(&base.PowerAction{Action: "list", Text: "列表", ShouldLogin: true, StandAlone: true, Parent: index})
@@ -54,6 +57,23 @@ var (
 	deleteById = base.AppendPower(&base.PowerAction{Action: "deleteById", Text: "删除", ShouldLogin: true, StandAlone: true, Parent: Power})
 	queryPage  = base.AppendPower(&base.PowerAction{Action: "queryPage", Text: "分页查询", ShouldLogin: true, StandAlone: true, Parent: Power})
 
+	aggregate_       = base.AppendPower(&base.PowerAction{Action: "aggregate", Text: "聚合", ShouldLogin: true, StandAlone: true, Parent: Power})
+	aggregateQuery   = base.AppendPower(&base.PowerAction{Action: "query", Text: "查询", ShouldLogin: true, StandAlone: true, Parent: aggregate_})
+	aggregateExplain = base.AppendPower(&base.PowerAction{Action: "explain", Text: "执行计划", ShouldLogin: true, StandAlone: true, Parent: aggregate_})
+	aggregateExport  = base.AppendPower(&base.PowerAction{Action: "export", Text: "导出", ShouldLogin: true, StandAlone: true, Parent: aggregate_})
+
+	task_        = base.AppendPower(&base.PowerAction{Action: "task", Text: "任务", ShouldLogin: true, StandAlone: true, Parent: Power})
+	taskStatus   = base.AppendPower(&base.PowerAction{Action: "status", Text: "状态", ShouldLogin: true, StandAlone: true, Parent: task_})
+	taskStop     = base.AppendPower(&base.PowerAction{Action: "stop", Text: "停止", ShouldLogin: true, StandAlone: true, Parent: task_})
+	taskClean    = base.AppendPower(&base.PowerAction{Action: "clean", Text: "清理", ShouldLogin: true, StandAlone: true, Parent: task_})
+	taskList     = base.AppendPower(&base.PowerAction{Action: "list", Text: "列表", ShouldLogin: true, StandAlone: true, Parent: task_})
+	taskDownload = base.AppendPower(&base.PowerAction{Action: "download", Text: "下载", ShouldLogin: true, StandAlone: true, Parent: task_})
+
+	watch_         = base.AppendPower(&base.PowerAction{Action: "watch", Text: "变更流", ShouldLogin: true, StandAlone: true, Parent: Power})
+	watchKey       = base.AppendPower(&base.PowerAction{Action: "key", Text: "创建", ShouldLogin: true, StandAlone: true, Parent: watch_})
+	watchWebsocket = base.AppendPower(&base.PowerAction{Action: "websocket", Text: "WebSocket", ShouldLogin: true, StandAlone: true, Parent: watch_})
+	watchClose     = base.AppendPower(&base.PowerAction{Action: "close", Text: "关闭", ShouldLogin: true, StandAlone: true, Parent: watch_})
+
 	closePower = base.AppendPower(&base.PowerAction{Action: "close", Text: "关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
@@ -69,6 +89,9 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: collectionCreate, Do: this_.collectionCreate})
 	apis = append(apis, &base.ApiWorker{Power: collectionDelete, Do: this_.collectionDelete})
 	apis = append(apis, &base.ApiWorker{Power: collectionDataTrim, Do: this_.collectionDataTrim})
+	apis = append(apis, &base.ApiWorker{Power: collectionExport, Do: this_.collectionExport})
+	apis = append(apis, &base.ApiWorker{Power: collectionImport, Do: this_.collectionImport})
+	apis = append(apis, &base.ApiWorker{Power: collectionCopy, Do: this_.collectionCopy})
 
 	apis = append(apis, &base.ApiWorker{Power: indexList, Do: this_.indexList})
 	apis = append(apis, &base.ApiWorker{Power: indexDelete, Do: this_.indexDelete})
@@ -80,6 +103,20 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: deleteById, Do: this_.deleteById})
 	apis = append(apis, &base.ApiWorker{Power: queryPage, Do: this_.queryPage})
 
+	apis = append(apis, &base.ApiWorker{Power: aggregateQuery, Do: this_.aggregate})
+	apis = append(apis, &base.ApiWorker{Power: aggregateExplain, Do: this_.aggregateExplain})
+	apis = append(apis, &base.ApiWorker{Power: aggregateExport, Do: this_.aggregateExport})
+
+	apis = append(apis, &base.ApiWorker{Power: taskStatus, Do: this_.taskStatus, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: taskStop, Do: this_.taskStop})
+	apis = append(apis, &base.ApiWorker{Power: taskClean, Do: this_.taskClean})
+	apis = append(apis, &base.ApiWorker{Power: taskList, Do: this_.taskList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: taskDownload, Do: this_.taskDownload})
+
+	apis = append(apis, &base.ApiWorker{Power: watchKey, Do: this_.watchKey})
+	apis = append(apis, &base.ApiWorker{Power: watchWebsocket, Do: watcherCache.Websocket, IsWebSocket: true})
+	apis = append(apis, &base.ApiWorker{Power: watchClose, Do: watcherCache.Close})
+
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
 
 	return
@@ -193,6 +230,12 @@ func (this_ *api) check(requestBean *base.RequestBean, c *gin.Context) (res inte
 
 func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	removeWorkerTasks(request.WorkerId)
+	watcherCache.RemoveWorker(request.WorkerId)
 	return
 }
 func (this_ *api) info(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
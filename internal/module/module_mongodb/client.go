@@ -0,0 +1,121 @@
+package module_mongodb
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/team-ide/go-tool/mongodb"
+	"github.com/team-ide/go-tool/util"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"os"
+	"strings"
+	"teamide/pkg/base"
+	"time"
+)
+
+// getClient 获取 原生 mongo 客户端，用于 聚合、变更流 等 mongodb.IService 未 提供 的 功能
+// 连接 参数 与 mongodb.New 保持 一致
+func getClient(config *mongodb.Config) (res *mongo.Client, err error) {
+	key := "mongodb-client-" + config.Address
+	if config.Username != "" {
+		key += "-" + base.GetMd5String(key+config.Username)
+	}
+	if config.Password != "" {
+		key += "-" + base.GetMd5String(key+config.Password)
+	}
+	if config.CertPath != "" {
+		key += "-" + base.GetMd5String(key+config.CertPath)
+	}
+
+	var serviceInfo *base.ServiceInfo
+	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
+		var client *mongo.Client
+		client, err = newClient(config)
+		if err != nil {
+			util.Logger.Error("getClient error", zap.Any("key", key), zap.Error(err))
+			return
+		}
+		res = &base.ServiceInfo{
+			WaitTime:    10 * 60 * 1000,
+			LastUseTime: util.GetNowMilli(),
+			Service:     client,
+			Stop: func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				_ = client.Disconnect(ctx)
+			},
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = serviceInfo.Service.(*mongo.Client)
+	serviceInfo.SetLastUseTime()
+	return
+}
+
+func newClient(config *mongodb.Config) (client *mongo.Client, err error) {
+	var servers []string
+	if strings.Contains(config.Address, ",") {
+		servers = strings.Split(config.Address, ",")
+	} else if strings.Contains(config.Address, ";") {
+		servers = strings.Split(config.Address, ";")
+	} else if config.Address != "" {
+		servers = []string{config.Address}
+	}
+	var minPoolSize = 10
+	if config.MinPoolSize > 0 {
+		minPoolSize = config.MinPoolSize
+	}
+	var maxPoolSize = 20
+	if config.MaxPoolSize >= minPoolSize {
+		maxPoolSize = config.MaxPoolSize
+	}
+	var connectTimeout = 10
+	if config.ConnectTimeout > 0 {
+		connectTimeout = config.ConnectTimeout
+	}
+
+	clientOptions := options.Client().SetHosts(servers).
+		SetMinPoolSize(uint64(minPoolSize)).
+		SetMaxPoolSize(uint64(maxPoolSize)).
+		SetConnectTimeout(time.Second * time.Duration(connectTimeout))
+
+	if len(config.Username) > 0 && len(config.Password) > 0 {
+		clientOptions.SetAuth(options.Credential{Username: config.Username, Password: config.Password})
+	}
+	if config.CertPath != "" {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: true,
+		}
+		certPool := x509.NewCertPool()
+		var pemCerts []byte
+		pemCerts, err = os.ReadFile(config.CertPath)
+		if err != nil {
+			return
+		}
+		if !certPool.AppendCertsFromPEM(pemCerts) {
+			err = errors.New("证书[" + config.CertPath + "]解析失败")
+			return
+		}
+		tlsConfig.RootCAs = certPool
+		clientOptions.TLSConfig = tlsConfig
+	}
+
+	client, err = mongo.Connect(context.Background(), clientOptions)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(connectTimeout))
+	defer cancel()
+	if err = client.Ping(ctx, nil); err != nil {
+		_ = client.Disconnect(context.Background())
+		client = nil
+		return
+	}
+	return
+}
@@ -0,0 +1,259 @@
+package module_mongodb
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 导出 文件 类型
+const (
+	// 每行 一个 relaxed 扩展 JSON 文档
+	fileTypeJsonLines = "jsonl"
+	// 扩展 JSON 文档 数组
+	fileTypeJson = "json"
+	// CSV，嵌套 文档 和 数组 输出 为 扩展 JSON
+	fileTypeCsv = "csv"
+)
+
+func getFileSuffix(fileType string) string {
+	switch fileType {
+	case fileTypeCsv:
+		return ".csv"
+	case fileTypeJson:
+		return ".json"
+	default:
+		return ".jsonl"
+	}
+}
+
+// docWriter 将 文档 写入 文件
+type docWriter interface {
+	write(doc bson.Raw) error
+	flush() error
+}
+
+type ExportOption struct {
+	FileType  string   `json:"fileType"`  // jsonl json csv，默认 jsonl
+	Canonical bool     `json:"canonical"` // 使用 canonical 扩展 JSON，保留 数值 类型
+	Fields    []string `json:"fields"`    // CSV 字段，支持 a.b 路径，为空 时 使用 第一个 文档 的 字段
+}
+
+func (this_ *ExportOption) check() (err error) {
+	switch this_.FileType {
+	case fileTypeJsonLines, fileTypeJson, fileTypeCsv, "":
+	default:
+		err = errors.New("不支持 的 文件 类型 [" + this_.FileType + "]")
+	}
+	return
+}
+
+func newDocWriter(w io.Writer, option *ExportOption) (res docWriter, err error) {
+	switch option.FileType {
+	case fileTypeJsonLines, "":
+		res = &jsonLinesWriter{
+			writer:    bufio.NewWriter(w),
+			canonical: option.Canonical,
+		}
+	case fileTypeJson:
+		res = &jsonArrayWriter{
+			writer:    bufio.NewWriter(w),
+			canonical: option.Canonical,
+		}
+	case fileTypeCsv:
+		res = &csvWriter{
+			writer:    csv.NewWriter(w),
+			canonical: option.Canonical,
+			fields:    option.Fields,
+		}
+	default:
+		err = errors.New("不支持 的 文件 类型 [" + option.FileType + "]")
+	}
+	return
+}
+
+type jsonLinesWriter struct {
+	writer    *bufio.Writer
+	canonical bool
+}
+
+func (this_ *jsonLinesWriter) write(doc bson.Raw) (err error) {
+	bs, err := bson.MarshalExtJSON(doc, this_.canonical, false)
+	if err != nil {
+		return
+	}
+	if _, err = this_.writer.Write(bs); err != nil {
+		return
+	}
+	err = this_.writer.WriteByte('\n')
+	return
+}
+
+func (this_ *jsonLinesWriter) flush() error {
+	return this_.writer.Flush()
+}
+
+type jsonArrayWriter struct {
+	writer    *bufio.Writer
+	canonical bool
+	count     int64
+}
+
+func (this_ *jsonArrayWriter) write(doc bson.Raw) (err error) {
+	bs, err := bson.MarshalExtJSON(doc, this_.canonical, false)
+	if err != nil {
+		return
+	}
+	if this_.count == 0 {
+		_, err = this_.writer.WriteString("[\n")
+	} else {
+		_, err = this_.writer.WriteString(",\n")
+	}
+	if err != nil {
+		return
+	}
+	this_.count++
+	_, err = this_.writer.Write(bs)
+	return
+}
+
+func (this_ *jsonArrayWriter) flush() (err error) {
+	if this_.count == 0 {
+		_, err = this_.writer.WriteString("[")
+	}
+	if err != nil {
+		return
+	}
+	if _, err = this_.writer.WriteString("\n]\n"); err != nil {
+		return
+	}
+	return this_.writer.Flush()
+}
+
+type csvWriter struct {
+	writer      *csv.Writer
+	canonical   bool
+	fields      []string
+	wroteHeader bool
+}
+
+func (this_ *csvWriter) write(doc bson.Raw) (err error) {
+	if !this_.wroteHeader {
+		if len(this_.fields) == 0 {
+			var elements []bson.RawElement
+			if elements, err = doc.Elements(); err != nil {
+				return
+			}
+			for _, element := range elements {
+				this_.fields = append(this_.fields, element.Key())
+			}
+		}
+		if err = this_.writer.Write(this_.fields); err != nil {
+			return
+		}
+		this_.wroteHeader = true
+	}
+	record := make([]string, len(this_.fields))
+	for i, field := range this_.fields {
+		value, e := doc.LookupErr(strings.Split(field, ".")...)
+		if e != nil {
+			continue
+		}
+		if record[i], err = rawValueToString(value, this_.canonical); err != nil {
+			return
+		}
+	}
+	err = this_.writer.Write(record)
+	return
+}
+
+func (this_ *csvWriter) flush() error {
+	this_.writer.Flush()
+	return this_.writer.Error()
+}
+
+// rawValueToString 转换 为 CSV 单元格 文本，简单 类型 输出 原始 值，其它 输出 扩展 JSON
+func rawValueToString(value bson.RawValue, canonical bool) (res string, err error) {
+	switch value.Type {
+	case bsontype.Null, bsontype.Undefined:
+		return
+	case bsontype.String:
+		res = value.StringValue()
+		return
+	case bsontype.ObjectID:
+		res = value.ObjectID().Hex()
+		return
+	case bsontype.Boolean:
+		res = fmt.Sprint(value.Boolean())
+		return
+	case bsontype.Int32:
+		res = fmt.Sprint(value.Int32())
+		return
+	case bsontype.Int64:
+		res = fmt.Sprint(value.Int64())
+		return
+	case bsontype.Double:
+		res = fmt.Sprint(value.Double())
+		return
+	case bsontype.Decimal128:
+		res = value.Decimal128().String()
+		return
+	case bsontype.DateTime:
+		res = value.Time().UTC().Format(time.RFC3339Nano)
+		return
+	}
+	// 包装 为 文档 后 输出 扩展 JSON，再 去掉 包装
+	bs, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: value}}, canonical, false)
+	if err != nil {
+		return
+	}
+	res = strings.TrimSpace(string(bs))
+	res = strings.TrimPrefix(res, `{"v":`)
+	res = strings.TrimSuffix(res, `}`)
+	return
+}
+
+// exportCursor 将 游标 结果 写入 任务 目录 下 的 文件
+func exportCursor(task *Task, cursor *mongo.Cursor, fileName string, option *ExportOption) (err error) {
+	defer func() { _ = cursor.Close(task.ctx) }()
+
+	path := filepath.Join(task.dir, fileName)
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = f.Close()
+		if info, e := os.Stat(path); e == nil {
+			task.setFile(fileName, info.Size())
+		}
+	}()
+	writer, err := newDocWriter(f, option)
+	if err != nil {
+		return
+	}
+	for cursor.Next(task.ctx) {
+		if err = writer.write(cursor.Current); err != nil {
+			task.addCount(0, err)
+			return
+		}
+		task.addCount(1)
+	}
+	if err = writer.flush(); err != nil {
+		return
+	}
+	if task.isStopped() {
+		return
+	}
+	err = cursor.Err()
+	return
+}
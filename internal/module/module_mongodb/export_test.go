@@ -0,0 +1,59 @@
+package module_mongodb
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestRawValueToString(t *testing.T) {
+	objectId, _ := primitive.ObjectIDFromHex("5f0c5a0e9d1b2c3d4e5f6a7b")
+	decimal, _ := primitive.ParseDecimal128("12.50")
+	doc := bson.D{
+		{Key: "null", Value: nil},
+		{Key: "string", Value: "abc"},
+		{Key: "objectId", Value: objectId},
+		{Key: "bool", Value: true},
+		{Key: "int32", Value: int32(-3)},
+		{Key: "int64", Value: int64(1) << 40},
+		{Key: "double", Value: 1.5},
+		{Key: "decimal", Value: decimal},
+		{Key: "date", Value: primitive.NewDateTimeFromTime(time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC))},
+		{Key: "doc", Value: bson.D{{Key: "a", Value: int32(1)}}},
+		{Key: "array", Value: bson.A{"x", int64(2)}},
+	}
+	bs, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := bson.Raw(bs)
+	for _, one := range []struct {
+		key       string
+		canonical bool
+		expect    string
+	}{
+		{"null", false, ""},
+		{"string", false, "abc"},
+		{"objectId", false, "5f0c5a0e9d1b2c3d4e5f6a7b"},
+		{"bool", false, "true"},
+		{"int32", false, "-3"},
+		{"int64", false, "1099511627776"},
+		{"double", false, "1.5"},
+		{"decimal", false, "12.50"},
+		{"date", false, "2024-01-02T03:04:05.006Z"},
+		{"doc", false, `{"a":1}`},
+		{"doc", true, `{"a":{"$numberInt":"1"}}`},
+		{"array", false, `["x",2]`},
+		{"array", true, `["x",{"$numberLong":"2"}]`},
+	} {
+		res, err := rawValueToString(raw.Lookup(one.key), one.canonical)
+		if err != nil {
+			t.Errorf("%s error:%s", one.key, err)
+			continue
+		}
+		if res != one.expect {
+			t.Errorf("%s canonical %v expect %s, got %s", one.key, one.canonical, one.expect, res)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package module_mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+// Task 后台 任务，如 聚合 导出、集合 导入 导出 等，执行 进度 通过 计数 反馈
+type Task struct {
+	TaskId         string `json:"taskId"`
+	WorkerId       string `json:"workerId"`
+	UserId         int64  `json:"userId"`
+	Type           string `json:"type"`
+	DatabaseName   string `json:"databaseName"`
+	CollectionName string `json:"collectionName"`
+
+	Total        int64 `json:"total"` // -1 表示 未知
+	Count        int64 `json:"count"`
+	SuccessCount int64 `json:"successCount"`
+	ErrorCount   int64 `json:"errorCount"`
+
+	FileName string   `json:"fileName,omitempty"`
+	FileSize int64    `json:"fileSize,omitempty"`
+	Errors   []string `json:"errors,omitempty"` // 最近 的 错误 信息
+
+	IsEnd     bool      `json:"isEnd"`
+	IsStop    bool      `json:"isStop"`
+	StartTime time.Time `json:"startTime,omitempty"`
+	EndTime   time.Time `json:"endTime,omitempty"`
+	UseTime   int64     `json:"useTime"`
+	Error     string    `json:"error,omitempty"`
+
+	dir    string
+	ctx    context.Context
+	cancel context.CancelFunc
+	lock   sync.Mutex
+	do     func(task *Task) error
+}
+
+// 保留 的 错误 信息 条数
+const taskErrorsSize = 20
+
+var (
+	taskCache     = map[string]*Task{}
+	taskCacheLock = &sync.Mutex{}
+)
+
+func getTask(taskId string) *Task {
+	taskCacheLock.Lock()
+	defer taskCacheLock.Unlock()
+	return taskCache[taskId]
+}
+
+// getUserTask 只 返回 当前 用户 创建 的 任务
+func getUserTask(requestBean *base.RequestBean, taskId string) *Task {
+	one := getTask(taskId)
+	if one == nil || requestBean.JWT == nil || one.UserId != requestBean.JWT.UserId {
+		return nil
+	}
+	return one
+}
+
+// getWorkerTasks userId 为 0 时 返回 工具 的 所有 任务
+func getWorkerTasks(workerId string, userId int64) (list []*Task) {
+	taskCacheLock.Lock()
+	defer taskCacheLock.Unlock()
+	for _, one := range taskCache {
+		if one.WorkerId == workerId && (userId == 0 || one.UserId == userId) {
+			list = append(list, one)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartTime.Before(list[j].StartTime)
+	})
+	return
+}
+
+// removeTask 停止 并 删除 任务，同时 删除 任务 生成 的 文件
+func removeTask(taskId string) {
+	taskCacheLock.Lock()
+	one := taskCache[taskId]
+	delete(taskCache, taskId)
+	taskCacheLock.Unlock()
+	if one != nil {
+		one.stop()
+		if one.dir != "" {
+			_ = os.RemoveAll(one.dir)
+		}
+	}
+}
+
+func removeWorkerTasks(workerId string) {
+	for _, one := range getWorkerTasks(workerId, 0) {
+		removeTask(one.TaskId)
+	}
+}
+
+// getTaskDir 任务 文件 目录，按 用户 隔离
+func (this_ *api) getTaskDir(requestBean *base.RequestBean, taskId string) (dir string, err error) {
+	dir = fmt.Sprintf("%suses/%d/mongodb/%s/", this_.toolboxService.GetFilesDir(), requestBean.JWT.UserId, taskId)
+	err = os.MkdirAll(dir, os.ModePerm)
+	return
+}
+
+// newTask 创建 任务，任务 生成 文件 时 需要 设置 dir，任务 清理 时 删除 该 目录
+func newTask(requestBean *base.RequestBean, workerId string, taskType string, do func(task *Task) error) *Task {
+	task := &Task{
+		TaskId:   util.GetUUID(),
+		WorkerId: workerId,
+		Type:     taskType,
+		Total:    -1,
+		do:       do,
+	}
+	if requestBean != nil && requestBean.JWT != nil {
+		task.UserId = requestBean.JWT.UserId
+	}
+	task.ctx, task.cancel = context.WithCancel(context.Background())
+	return task
+}
+
+// startTask 缓存 并 异步 执行 任务
+func startTask(task *Task) {
+	task.StartTime = time.Now()
+	taskCacheLock.Lock()
+	taskCache[task.TaskId] = task
+	taskCacheLock.Unlock()
+	go task.run()
+}
+
+func (this_ *Task) run() {
+	var err error
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprint(e))
+		}
+		if err != nil {
+			util.Logger.Error("mongodb task error", zap.Any("taskId", this_.TaskId), zap.Any("type", this_.Type), zap.Error(err))
+		}
+		this_.lock.Lock()
+		if err != nil && !this_.IsStop {
+			this_.Error = err.Error()
+		}
+		this_.IsEnd = true
+		this_.EndTime = time.Now()
+		this_.UseTime = this_.EndTime.Sub(this_.StartTime).Milliseconds()
+		this_.lock.Unlock()
+		this_.cancel()
+	}()
+	err = this_.do(this_)
+}
+
+func (this_ *Task) stop() {
+	this_.lock.Lock()
+	if !this_.IsEnd {
+		this_.IsStop = true
+	}
+	this_.lock.Unlock()
+	this_.cancel()
+}
+
+func (this_ *Task) isStopped() bool {
+	return this_.ctx.Err() != nil
+}
+
+func (this_ *Task) setTotal(total int64) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	this_.Total = total
+}
+
+// addCount 累加 处理 数量，errs 为 本次 的 错误 信息
+func (this_ *Task) addCount(success int64, errs ...error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	this_.Count += success + int64(len(errs))
+	this_.SuccessCount += success
+	this_.ErrorCount += int64(len(errs))
+	for _, e := range errs {
+		this_.Errors = append(this_.Errors, e.Error())
+	}
+	if len(this_.Errors) > taskErrorsSize {
+		this_.Errors = this_.Errors[len(this_.Errors)-taskErrorsSize:]
+	}
+}
+
+func (this_ *Task) setFile(fileName string, fileSize int64) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	this_.FileName = fileName
+	this_.FileSize = fileSize
+}
+
+// status 复制 当前 状态 避免 并发 读写
+func (this_ *Task) status() *Task {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	res := &Task{
+		TaskId:         this_.TaskId,
+		WorkerId:       this_.WorkerId,
+		UserId:         this_.UserId,
+		Type:           this_.Type,
+		DatabaseName:   this_.DatabaseName,
+		CollectionName: this_.CollectionName,
+		Total:          this_.Total,
+		Count:          this_.Count,
+		SuccessCount:   this_.SuccessCount,
+		ErrorCount:     this_.ErrorCount,
+		FileName:       this_.FileName,
+		FileSize:       this_.FileSize,
+		Errors:         append([]string{}, this_.Errors...),
+		IsEnd:          this_.IsEnd,
+		IsStop:         this_.IsStop,
+		StartTime:      this_.StartTime,
+		EndTime:        this_.EndTime,
+		UseTime:        this_.UseTime,
+		Error:          this_.Error,
+	}
+	if !res.IsEnd {
+		res.UseTime = time.Since(res.StartTime).Milliseconds()
+	}
+	return res
+}
+
+type TaskRequest struct {
+	WorkerId string `json:"workerId"`
+	TaskId   string `json:"taskId"`
+}
+
+func (this_ *api) taskStatus(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &TaskRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	task := getUserTask(requestBean, request.TaskId)
+	if task != nil {
+		res = task.status()
+	}
+	return
+}
+
+func (this_ *api) taskStop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &TaskRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	task := getUserTask(requestBean, request.TaskId)
+	if task != nil {
+		task.stop()
+	}
+	return
+}
+
+func (this_ *api) taskClean(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &TaskRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if getUserTask(requestBean, request.TaskId) != nil {
+		removeTask(request.TaskId)
+	}
+	return
+}
+
+func (this_ *api) taskList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &TaskRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if requestBean.JWT == nil {
+		return
+	}
+	var list []*Task
+	for _, one := range getWorkerTasks(request.WorkerId, requestBean.JWT.UserId) {
+		list = append(list, one.status())
+	}
+	res = list
+	return
+}
+
+// taskDownload 下载 任务 生成 的 文件，任务 结束 后 才能 下载
+func (this_ *api) taskDownload(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	data := map[string]string{}
+	err = c.Bind(&data)
+	if err != nil {
+		return
+	}
+
+	task := getUserTask(requestBean, data["taskId"])
+	if task == nil {
+		err = errors.New("任务不存在")
+		return
+	}
+	status := task.status()
+	if !status.IsEnd {
+		err = errors.New("任务未结束")
+		return
+	}
+	if status.FileName == "" {
+		err = errors.New("任务没有导出文件")
+		return
+	}
+	path := filepath.Join(task.dir, status.FileName)
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", "attachment; filename="+url.QueryEscape(status.FileName))
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("Content-Length", fmt.Sprint(fileInfo.Size()))
+	c.Header("download-file-name", status.FileName)
+
+	_, err = io.Copy(c.Writer, f)
+	if err != nil {
+		return
+	}
+
+	c.Status(http.StatusOK)
+	res = base.HttpNotResponse
+	return
+}
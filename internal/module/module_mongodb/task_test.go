@@ -0,0 +1,32 @@
+package module_mongodb
+
+import (
+	"teamide/pkg/base"
+	"testing"
+)
+
+func TestTaskOwner(t *testing.T) {
+	owner := &base.RequestBean{JWT: &base.JWTBean{UserId: 1}}
+	other := &base.RequestBean{JWT: &base.JWTBean{UserId: 2}}
+	done := make(chan struct{})
+	task := newTask(owner, "w1", "test", func(task *Task) error {
+		<-done
+		return nil
+	})
+	startTask(task)
+	defer removeWorkerTasks("w1")
+
+	if task.UserId != 1 || task.status().UserId != 1 {
+		t.Errorf("task expect user 1, got %d", task.UserId)
+	}
+	if getUserTask(owner, task.TaskId) != task {
+		t.Errorf("owner expect get task")
+	}
+	if getUserTask(other, task.TaskId) != nil || getUserTask(&base.RequestBean{}, task.TaskId) != nil {
+		t.Errorf("other user expect not get task")
+	}
+	if len(getWorkerTasks("w1", 1)) != 1 || len(getWorkerTasks("w1", 2)) != 0 || len(getWorkerTasks("w1", 0)) != 1 {
+		t.Errorf("worker tasks expect filtered by user")
+	}
+	close(done)
+}
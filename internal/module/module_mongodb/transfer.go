@@ -0,0 +1,663 @@
+package module_mongodb
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/mongodb"
+	"github.com/team-ide/go-tool/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"teamide/pkg/base"
+	"time"
+)
+
+// 写入 模式
+const (
+	// 直接 插入，主键 冲突 计 为 错误
+	writeModeInsert = "insert"
+	// 按 key 查找，存在 则 整体 替换，不存在 则 插入
+	writeModeUpsert = "upsert"
+	// 按 key 查找，存在 则 $set 合并 字段，不存在 则 插入
+	writeModeMerge = "merge"
+)
+
+const defaultBatchSize = 500
+
+type WriteOption struct {
+	Mode      string   `json:"mode"`      // insert upsert merge，默认 insert
+	Keys      []string `json:"keys"`      // upsert merge 时 用于 匹配 的 字段，默认 _id
+	BatchSize int      `json:"batchSize"` // 每批 写入 条数
+}
+
+func (this_ *WriteOption) check() (err error) {
+	switch this_.Mode {
+	case "":
+		this_.Mode = writeModeInsert
+	case writeModeInsert, writeModeUpsert, writeModeMerge:
+	default:
+		err = errors.New("不支持 的 写入 模式 [" + this_.Mode + "]")
+		return
+	}
+	if len(this_.Keys) == 0 {
+		this_.Keys = []string{"_id"}
+	}
+	if this_.BatchSize <= 0 {
+		this_.BatchSize = defaultBatchSize
+	}
+	return
+}
+
+func (this_ *WriteOption) toModel(doc bson.Raw) (model mongo.WriteModel, err error) {
+	if this_.Mode == writeModeInsert {
+		model = mongo.NewInsertOneModel().SetDocument(doc)
+		return
+	}
+	filter := bson.D{}
+	for _, key := range this_.Keys {
+		value, e := doc.LookupErr(strings.Split(key, ".")...)
+		if e != nil {
+			err = errors.New("文档 缺少 匹配 字段 [" + key + "]")
+			return
+		}
+		filter = append(filter, bson.E{Key: key, Value: value})
+	}
+	if this_.Mode == writeModeUpsert {
+		model = mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(true)
+		return
+	}
+	elements, err := doc.Elements()
+	if err != nil {
+		return
+	}
+	set := bson.D{}
+	update := bson.D{}
+	for _, element := range elements {
+		// _id 不可 修改，只在 插入 时 设置
+		if element.Key() == "_id" {
+			update = append(update, bson.E{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: element.Value()}}})
+			continue
+		}
+		set = append(set, bson.E{Key: element.Key(), Value: element.Value()})
+	}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(update) == 0 {
+		update = append(update, bson.E{Key: "$setOnInsert", Value: bson.D{}})
+	}
+	model = mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true)
+	return
+}
+
+// writeDocs 批量 写入，单条 失败 不 影响 其它 文档，返回 的 err 为 无法 继续 的 错误
+func writeDocs(ctx context.Context, collection *mongo.Collection, option *WriteOption, docs []bson.Raw) (success int64, errs []error, err error) {
+	var models []mongo.WriteModel
+	for _, doc := range docs {
+		model, e := option.toModel(doc)
+		if e != nil {
+			errs = append(errs, e)
+			continue
+		}
+		models = append(models, model)
+	}
+	if len(models) == 0 {
+		return
+	}
+	_, err = collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+			return
+		}
+		err = nil
+		for _, writeErr := range bulkErr.WriteErrors {
+			errs = append(errs, errors.New(writeErr.Message))
+		}
+		success = int64(len(models) - len(bulkErr.WriteErrors))
+		return
+	}
+	success = int64(len(models))
+	return
+}
+
+// docBatch 按 批 缓存 文档，满 一批 时 写入
+type docBatch struct {
+	task       *Task
+	collection *mongo.Collection
+	option     *WriteOption
+	docs       []bson.Raw
+}
+
+func (this_ *docBatch) add(doc bson.Raw) (err error) {
+	this_.docs = append(this_.docs, doc)
+	if len(this_.docs) >= this_.option.BatchSize {
+		err = this_.flush()
+	}
+	return
+}
+
+func (this_ *docBatch) flush() (err error) {
+	if len(this_.docs) == 0 {
+		return
+	}
+	success, errs, err := writeDocs(this_.task.ctx, this_.collection, this_.option, this_.docs)
+	this_.docs = nil
+	if err != nil {
+		return
+	}
+	this_.task.addCount(success, errs...)
+	return
+}
+
+type TransferRequest struct {
+	WorkerId       string `json:"workerId"`
+	DatabaseName   string `json:"databaseName"`
+	CollectionName string `json:"collectionName"`
+	// 扩展 JSON 格式 的 过滤 条件、投影、排序
+	Filter     string `json:"filter"`
+	Projection string `json:"projection"`
+	Sort       string `json:"sort"`
+	Limit      int64  `json:"limit"`
+
+	ExportOption
+	WriteOption
+
+	// 导入 文件 路径，上传 后 的 相对 路径
+	FilePath string `json:"filePath"`
+	// CSV 字段 类型，string int long double bool date objectId json，默认 string
+	FieldTypes map[string]string `json:"fieldTypes"`
+
+	TargetToolboxId      int64  `json:"targetToolboxId"` // 为 0 则 复制 到 当前 工具
+	TargetDatabaseName   string `json:"targetDatabaseName"`
+	TargetCollectionName string `json:"targetCollectionName"`
+	CopyIndexes          bool   `json:"copyIndexes"`
+	DropTarget           bool   `json:"dropTarget"` // 复制 前 删除 目标 集合
+}
+
+// parseDocument 解析 扩展 JSON 文档，为空 返回 空 文档
+func parseDocument(text string) (doc bson.D, err error) {
+	doc = bson.D{}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	err = bson.UnmarshalExtJSON([]byte(text), false, &doc)
+	return
+}
+
+func (this_ *TransferRequest) getFindOptions() (opts *options.FindOptions, filter bson.D, err error) {
+	if filter, err = parseDocument(this_.Filter); err != nil {
+		err = errors.New("过滤 条件 解析 失败: " + err.Error())
+		return
+	}
+	opts = options.Find()
+	if strings.TrimSpace(this_.Projection) != "" {
+		var projection bson.D
+		if projection, err = parseDocument(this_.Projection); err != nil {
+			err = errors.New("投影 解析 失败: " + err.Error())
+			return
+		}
+		opts.SetProjection(projection)
+	}
+	if strings.TrimSpace(this_.Sort) != "" {
+		var sort bson.D
+		if sort, err = parseDocument(this_.Sort); err != nil {
+			err = errors.New("排序 解析 失败: " + err.Error())
+			return
+		}
+		opts.SetSort(sort)
+	}
+	if this_.Limit > 0 {
+		opts.SetLimit(this_.Limit)
+	}
+	return
+}
+
+// collectionExport 导出 集合 为 JSON Lines、扩展 JSON 或 CSV 文件
+func (this_ *api) collectionExport(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &TransferRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if err = request.ExportOption.check(); err != nil {
+		return
+	}
+	opts, filter, err := request.getFindOptions()
+	if err != nil {
+		return
+	}
+
+	fileName := request.CollectionName + "-" + time.Now().Format("20060102150405") + getFileSuffix(request.FileType)
+	task := newTask(requestBean, request.WorkerId, "export", func(task *Task) (err error) {
+		collection := client.Database(request.DatabaseName).Collection(request.CollectionName)
+		total, err := collection.CountDocuments(task.ctx, filter)
+		if err != nil {
+			return
+		}
+		if request.Limit > 0 && request.Limit < total {
+			total = request.Limit
+		}
+		task.setTotal(total)
+		cursor, err := collection.Find(task.ctx, filter, opts)
+		if err != nil {
+			return
+		}
+		err = exportCursor(task, cursor, fileName, &request.ExportOption)
+		return
+	})
+	if task.dir, err = this_.getTaskDir(requestBean, task.TaskId); err != nil {
+		return
+	}
+	task.DatabaseName = request.DatabaseName
+	task.CollectionName = request.CollectionName
+	startTask(task)
+	res = task.status()
+	return
+}
+
+// collectionImport 从 JSON Lines、扩展 JSON 数组 或 CSV 文件 导入 集合
+func (this_ *api) collectionImport(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &TransferRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if err = request.ExportOption.check(); err != nil {
+		return
+	}
+	if err = request.WriteOption.check(); err != nil {
+		return
+	}
+	if request.FilePath == "" {
+		err = errors.New("导入 文件 不能为空")
+		return
+	}
+	filePath := this_.toolboxService.GetFilesFile(request.FilePath)
+	if exists, _ := util.PathExists(filePath); !exists {
+		err = errors.New("导入 文件 [" + request.FilePath + "] 不存在")
+		return
+	}
+
+	task := newTask(requestBean, request.WorkerId, "import", func(task *Task) (err error) {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return
+		}
+		defer func() { _ = f.Close() }()
+
+		batch := &docBatch{
+			task:       task,
+			collection: client.Database(request.DatabaseName).Collection(request.CollectionName),
+			option:     &request.WriteOption,
+		}
+		onDoc := func(doc bson.Raw, e error) (err error) {
+			if e != nil {
+				task.addCount(0, e)
+				return
+			}
+			err = batch.add(doc)
+			return
+		}
+		if request.FileType == fileTypeCsv {
+			err = readCsvFile(task, f, request.FieldTypes, onDoc)
+		} else {
+			err = readJsonFile(task, f, onDoc)
+		}
+		if err != nil || task.isStopped() {
+			return
+		}
+		err = batch.flush()
+		return
+	})
+	task.DatabaseName = request.DatabaseName
+	task.CollectionName = request.CollectionName
+	startTask(task)
+	res = task.status()
+	return
+}
+
+// readJsonFile 读取 扩展 JSON 文件，支持 文档 数组 和 每行 一个 文档
+func readJsonFile(task *Task, r io.Reader, onDoc func(doc bson.Raw, err error) error) (err error) {
+	reader := bufio.NewReader(r)
+	// 跳过 UTF-8 BOM
+	if bs, _ := reader.Peek(3); string(bs) == "\ufeff" {
+		_, _ = reader.Discard(3)
+	}
+	var isArray bool
+	for {
+		var b byte
+		if b, err = reader.ReadByte(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+		isArray = b == '['
+		_ = reader.UnreadByte()
+		break
+	}
+	decoder := json.NewDecoder(reader)
+	if isArray {
+		if _, err = decoder.Token(); err != nil {
+			return
+		}
+	}
+	var index int64
+	for !task.isStopped() {
+		if isArray && !decoder.More() {
+			return
+		}
+		var raw json.RawMessage
+		if err = decoder.Decode(&raw); err != nil {
+			if err == io.EOF && !isArray {
+				err = nil
+			}
+			return
+		}
+		index++
+		var doc bson.Raw
+		e := bson.UnmarshalExtJSON(raw, false, &doc)
+		if e != nil {
+			e = errors.New("第 " + strconv.FormatInt(index, 10) + " 个 文档 解析 失败: " + e.Error())
+		}
+		if err = onDoc(doc, e); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// readCsvFile 读取 CSV 文件，第一 行 为 字段 名，a.b 形式 的 字段 转换 为 嵌套 文档
+func readCsvFile(task *Task, r io.Reader, fieldTypes map[string]string, onDoc func(doc bson.Raw, err error) error) (err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			err = nil
+		}
+		return
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	var line int64 = 1
+	for !task.isStopped() {
+		var record []string
+		if record, err = reader.Read(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		line++
+		doc, e := csvRecordToDoc(header, record, fieldTypes)
+		var raw bson.Raw
+		if e == nil {
+			raw, e = bson.Marshal(doc)
+		}
+		if e != nil {
+			e = errors.New("第 " + strconv.FormatInt(line, 10) + " 行 解析 失败: " + e.Error())
+		}
+		if err = onDoc(raw, e); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func csvRecordToDoc(header []string, record []string, fieldTypes map[string]string) (doc bson.D, err error) {
+	doc = bson.D{}
+	for i, field := range header {
+		if field == "" || i >= len(record) {
+			continue
+		}
+		var value interface{}
+		if value, err = csvValueToBson(record[i], fieldTypes[field]); err != nil {
+			err = errors.New("字段 [" + field + "] " + err.Error())
+			return
+		}
+		// 空 值 不 写入，保留 字段 缺失 的 语义
+		if value == nil {
+			continue
+		}
+		doc = setDocPath(doc, strings.Split(field, "."), value)
+	}
+	return
+}
+
+func setDocPath(doc bson.D, path []string, value interface{}) bson.D {
+	for i, e := range doc {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			doc[i].Value = value
+			return doc
+		}
+		sub, _ := e.Value.(bson.D)
+		doc[i].Value = setDocPath(sub, path[1:], value)
+		return doc
+	}
+	if len(path) == 1 {
+		return append(doc, bson.E{Key: path[0], Value: value})
+	}
+	return append(doc, bson.E{Key: path[0], Value: setDocPath(bson.D{}, path[1:], value)})
+}
+
+func csvValueToBson(text string, fieldType string) (value interface{}, err error) {
+	if text == "" && fieldType != "" && fieldType != "string" {
+		return
+	}
+	switch fieldType {
+	case "", "string":
+		value = text
+	case "int":
+		var v int64
+		if v, err = strconv.ParseInt(text, 10, 32); err == nil {
+			value = int32(v)
+		}
+	case "long":
+		value, err = strconv.ParseInt(text, 10, 64)
+	case "double":
+		value, err = strconv.ParseFloat(text, 64)
+	case "bool":
+		value, err = strconv.ParseBool(text)
+	case "date":
+		var t time.Time
+		if t, err = time.Parse(time.RFC3339Nano, text); err != nil {
+			// 兼容 毫秒 时间戳
+			var ms int64
+			if ms, err = strconv.ParseInt(text, 10, 64); err == nil {
+				t = time.UnixMilli(ms)
+			}
+		}
+		if err == nil {
+			value = primitive.NewDateTimeFromTime(t)
+		}
+	case "objectId":
+		value, err = primitive.ObjectIDFromHex(text)
+	case "json":
+		// 扩展 JSON 值，包装 为 文档 解析
+		var doc bson.D
+		if err = bson.UnmarshalExtJSON([]byte(`{"v":`+text+`}`), false, &doc); err == nil && len(doc) == 1 {
+			value = doc[0].Value
+		}
+	default:
+		err = errors.New("不支持 的 类型 [" + fieldType + "]")
+	}
+	if err != nil {
+		err = errors.New("值 [" + text + "] 转换 为 " + fieldType + " 失败: " + err.Error())
+	}
+	return
+}
+
+// collectionCopy 复制 集合 到 当前 或 其它 Mongodb 工具，可 同时 复制 索引
+func (this_ *api) collectionCopy(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	source, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &TransferRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if err = request.WriteOption.check(); err != nil {
+		return
+	}
+	opts, filter, err := request.getFindOptions()
+	if err != nil {
+		return
+	}
+	if request.TargetDatabaseName == "" {
+		request.TargetDatabaseName = request.DatabaseName
+	}
+	if request.TargetCollectionName == "" {
+		request.TargetCollectionName = request.CollectionName
+	}
+	target := source
+	if request.TargetToolboxId != 0 {
+		find, e := this_.toolboxService.Get(request.TargetToolboxId)
+		if e != nil {
+			return nil, e
+		}
+		if find == nil || find.ToolboxType != "mongodb" {
+			err = errors.New("目标 Mongodb 工具 不存在")
+			return
+		}
+		if err = this_.toolboxService.CheckToolboxPower(requestBean, find); err != nil {
+			return
+		}
+		targetConfig := &mongodb.Config{}
+		if _, err = this_.toolboxService.BindConfigById(request.TargetToolboxId, targetConfig); err != nil {
+			return
+		}
+		if target, err = getClient(targetConfig); err != nil {
+			return
+		}
+	}
+	if target == source && request.TargetDatabaseName == request.DatabaseName && request.TargetCollectionName == request.CollectionName {
+		err = errors.New("源 集合 和 目标 集合 不能 相同")
+		return
+	}
+
+	task := newTask(requestBean, request.WorkerId, "copy", func(task *Task) (err error) {
+		sourceCollection := source.Database(request.DatabaseName).Collection(request.CollectionName)
+		targetCollection := target.Database(request.TargetDatabaseName).Collection(request.TargetCollectionName)
+		if request.DropTarget {
+			if err = targetCollection.Drop(task.ctx); err != nil {
+				return
+			}
+		}
+		// 先 创建 索引，唯一 索引 可以 在 写入 时 生效
+		if request.CopyIndexes {
+			if err = copyIndexes(task.ctx, sourceCollection, targetCollection); err != nil {
+				return
+			}
+		}
+		total, err := sourceCollection.CountDocuments(task.ctx, filter)
+		if err != nil {
+			return
+		}
+		if request.Limit > 0 && request.Limit < total {
+			total = request.Limit
+		}
+		task.setTotal(total)
+		cursor, err := sourceCollection.Find(task.ctx, filter, opts)
+		if err != nil {
+			return
+		}
+		defer func() { _ = cursor.Close(task.ctx) }()
+		batch := &docBatch{
+			task:       task,
+			collection: targetCollection,
+			option:     &request.WriteOption,
+		}
+		for cursor.Next(task.ctx) {
+			// cursor.Current 在 下次 Next 时 会 被 复用，需要 复制
+			if err = batch.add(append(bson.Raw{}, cursor.Current...)); err != nil {
+				return
+			}
+		}
+		if task.isStopped() {
+			return
+		}
+		if err = cursor.Err(); err != nil {
+			return
+		}
+		err = batch.flush()
+		return
+	})
+	task.DatabaseName = request.DatabaseName
+	task.CollectionName = request.CollectionName
+	util.Logger.Info("mongodb copy task start", zap.Any("taskId", task.TaskId), zap.Any("collection", request.DatabaseName+"."+request.CollectionName), zap.Any("target", request.TargetDatabaseName+"."+request.TargetCollectionName), zap.Any("targetToolboxId", request.TargetToolboxId))
+	startTask(task)
+	res = task.status()
+	return
+}
+
+// copyIndexes 按 源 集合 的 索引 定义 在 目标 集合 创建 索引，保留 唯一、过期、部分 索引 等 选项
+func copyIndexes(ctx context.Context, source *mongo.Collection, target *mongo.Collection) (err error) {
+	cursor, err := source.Indexes().List(ctx)
+	if err != nil {
+		return
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+	var indexes bson.A
+	for cursor.Next(ctx) {
+		var spec bson.D
+		if err = cursor.Decode(&spec); err != nil {
+			return
+		}
+		var name string
+		var index bson.D
+		for _, e := range spec {
+			switch e.Key {
+			case "v", "ns":
+				continue
+			case "name":
+				name, _ = e.Value.(string)
+			}
+			index = append(index, e)
+		}
+		if name == "_id_" {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	if err = cursor.Err(); err != nil {
+		return
+	}
+	if len(indexes) == 0 {
+		return
+	}
+	err = target.Database().RunCommand(ctx, bson.D{
+		{Key: "createIndexes", Value: target.Name()},
+		{Key: "indexes", Value: indexes},
+	}).Err()
+	return
+}
@@ -0,0 +1,126 @@
+package module_mongodb
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCsvValueToBson(t *testing.T) {
+	objectId, _ := primitive.ObjectIDFromHex("5f0c5a0e9d1b2c3d4e5f6a7b")
+	date := primitive.NewDateTimeFromTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	for _, one := range []struct {
+		text      string
+		fieldType string
+		expect    interface{}
+		error     bool
+	}{
+		{"abc", "", "abc", false},
+		{"", "string", "", false},
+		// 非 字符串 类型 空值 为 null
+		{"", "int", nil, false},
+		{"12", "int", int32(12), false},
+		{"3000000000", "int", nil, true},
+		{"3000000000", "long", int64(3000000000), false},
+		{"1.5", "double", 1.5, false},
+		{"true", "bool", true, false},
+		{"yes", "bool", nil, true},
+		{"2024-01-02T03:04:05Z", "date", date, false},
+		{"1704164645000", "date", date, false},
+		{"2024/01/02", "date", nil, true},
+		{"5f0c5a0e9d1b2c3d4e5f6a7b", "objectId", objectId, false},
+		{"xyz", "objectId", nil, true},
+		{`{"a":1}`, "json", bson.D{{Key: "a", Value: int32(1)}}, false},
+		{`{"$numberLong":"5"}`, "json", int64(5), false},
+		{`{`, "json", nil, true},
+		{"1", "decimal", nil, true},
+	} {
+		value, err := csvValueToBson(one.text, one.fieldType)
+		if (err != nil) != one.error {
+			t.Errorf("%s %s expect error %v, got %v", one.text, one.fieldType, one.error, err)
+			continue
+		}
+		if !one.error && !reflect.DeepEqual(value, one.expect) {
+			t.Errorf("%s %s expect %#v, got %#v", one.text, one.fieldType, one.expect, value)
+		}
+	}
+}
+
+func TestSetDocPath(t *testing.T) {
+	var doc bson.D
+	doc = setDocPath(doc, []string{"a"}, 1)
+	doc = setDocPath(doc, []string{"b", "c"}, 2)
+	doc = setDocPath(doc, []string{"b", "d"}, 3)
+	doc = setDocPath(doc, []string{"a"}, 4)
+	// 已 存在 的 非 文档 字段 被 替换 为 文档
+	doc = setDocPath(doc, []string{"e"}, "x")
+	doc = setDocPath(doc, []string{"e", "f"}, 5)
+	expect := bson.D{
+		{Key: "a", Value: 4},
+		{Key: "b", Value: bson.D{{Key: "c", Value: 2}, {Key: "d", Value: 3}}},
+		{Key: "e", Value: bson.D{{Key: "f", Value: 5}}},
+	}
+	if !reflect.DeepEqual(doc, expect) {
+		t.Errorf("doc expect %v, got %v", expect, doc)
+	}
+}
+
+func TestReadJsonFile(t *testing.T) {
+	for _, one := range []struct {
+		name   string
+		text   string
+		expect []string
+		errors int
+		error  bool
+	}{
+		{"lines", "{\"a\":1}\n{\"a\":2}\n", []string{`{"a":1}`, `{"a":2}`}, 0, false},
+		{"array", " [ {\"a\":1},\n{\"a\":{\"$numberLong\":\"2\"}} ] ", []string{`{"a":1}`, `{"a":{"$numberLong":"2"}}`}, 0, false},
+		{"bom", "\ufeff{\"a\":1}", []string{`{"a":1}`}, 0, false},
+		{"empty", " \n ", nil, 0, false},
+		{"emptyArray", "[]", nil, 0, false},
+		// 扩展 JSON 错误 的 文档 通过 回调 返回 错误，继续 读取
+		{"badExtJson", "{\"a\":{\"$oid\":\"x\"}}\n{\"a\":3}", []string{`{"a":3}`}, 1, false},
+		{"badJson", "{\"a\":1}\n{\"a\":", []string{`{"a":1}`}, 0, true},
+	} {
+		task := newTask(nil, "", "test", nil)
+		var docs []string
+		var errs int
+		err := readJsonFile(task, strings.NewReader(one.text), func(doc bson.Raw, err error) error {
+			if err != nil {
+				errs++
+				return nil
+			}
+			bs, _ := bson.MarshalExtJSON(doc, true, false)
+			docs = append(docs, string(bs))
+			return nil
+		})
+		if (err != nil) != one.error {
+			t.Errorf("%s expect error %v, got %v", one.name, one.error, err)
+		}
+		var expect []string
+		for _, text := range one.expect {
+			var doc bson.Raw
+			_ = bson.UnmarshalExtJSON([]byte(text), false, &doc)
+			bs, _ := bson.MarshalExtJSON(doc, true, false)
+			expect = append(expect, string(bs))
+		}
+		if !reflect.DeepEqual(docs, expect) || errs != one.errors {
+			t.Errorf("%s expect %v errors %d, got %v errors %d", one.name, expect, one.errors, docs, errs)
+		}
+	}
+
+	// 任务 停止 后 不再 读取
+	task := newTask(nil, "", "test", nil)
+	var count int
+	_ = readJsonFile(task, strings.NewReader("{\"a\":1}\n{\"a\":2}\n"), func(doc bson.Raw, err error) error {
+		count++
+		task.stop()
+		return nil
+	})
+	if count != 1 {
+		t.Errorf("stopped task expect read 1 doc, got %d", count)
+	}
+}
@@ -0,0 +1,319 @@
+package module_mongodb
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+type WatchRequest struct {
+	WorkerId string `json:"workerId"`
+	Key      string `json:"key"`
+	// 监听 范围 由 库 和 集合 决定：都 为空 监听 整个 部署，集合 为空 监听 库
+	DatabaseName   string `json:"databaseName"`
+	CollectionName string `json:"collectionName"`
+	// 扩展 JSON 格式 的 $match 条件，如 {"operationType":{"$in":["insert","update"]}}
+	Match string `json:"match"`
+	// 扩展 JSON 格式 的 管道，在 $match 之后 执行，如 $project
+	Pipeline string `json:"pipeline"`
+	// default updateLookup whenAvailable required，默认 default
+	FullDocument string `json:"fullDocument"`
+	// off whenAvailable required，需要 集合 开启 changeStreamPreAndPostImages
+	FullDocumentBeforeChange string `json:"fullDocumentBeforeChange"`
+	// 扩展 JSON 格式 的 恢复 令牌，用于 断开 后 从 上次 位置 继续
+	ResumeToken string `json:"resumeToken"`
+	// 从 指定 时间 开始，秒，ResumeToken 为空 时 使用
+	StartAtTime int64 `json:"startAtTime"`
+	RateLimit   int   `json:"rateLimit"` // 每秒 最多 推送 条数 默认 100
+}
+
+type WatchMessage struct {
+	Type           string `json:"type"` // change stats error
+	OperationType  string `json:"operationType,omitempty"`
+	DatabaseName   string `json:"databaseName,omitempty"`
+	CollectionName string `json:"collectionName,omitempty"`
+	ClusterTime    int64  `json:"clusterTime,omitempty"` // 秒
+	Value          string `json:"value,omitempty"`       // relaxed 扩展 JSON 格式 的 变更 事件
+	ResumeToken    string `json:"resumeToken,omitempty"`
+	Time           int64  `json:"time"`
+
+	Received int64  `json:"received,omitempty"`
+	Sent     int64  `json:"sent,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// 变更流 出错 后 重新 打开 的 间隔 和 最大 次数
+const (
+	watchRetryInterval = 3 * time.Second
+	watchRetryTimes    = 5
+)
+
+// watcher 变更流 会话，websocket 断开 或 工具 关闭 时 停止
+type watcher struct {
+	*base.WebsocketSession
+	request  *WatchRequest
+	client   *mongo.Client
+	pipeline []bson.D
+	buffer   chan *WatchMessage
+
+	resumeToken bson.Raw
+	received    int64
+	sent        int64
+	lock        sync.Mutex
+}
+
+var watcherCache = base.NewWebsocketCache("mongodb watch")
+
+func (this_ *WatchRequest) getPipeline() (pipeline []bson.D, err error) {
+	pipeline = []bson.D{}
+	if strings.TrimSpace(this_.Match) != "" {
+		var match bson.D
+		if match, err = parseDocument(this_.Match); err != nil {
+			err = errors.New("$match 解析 失败: " + err.Error())
+			return
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: match}})
+	}
+	stages, err := parsePipeline(this_.Pipeline)
+	if err != nil {
+		return
+	}
+	pipeline = append(pipeline, stages...)
+	return
+}
+
+func (this_ *watcher) getOptions() *options.ChangeStreamOptions {
+	request := this_.request
+	opts := options.ChangeStream()
+	if request.FullDocument != "" {
+		opts.SetFullDocument(options.FullDocument(request.FullDocument))
+	}
+	if request.FullDocumentBeforeChange != "" {
+		opts.SetFullDocumentBeforeChange(options.FullDocument(request.FullDocumentBeforeChange))
+	}
+	this_.lock.Lock()
+	resumeToken := this_.resumeToken
+	this_.lock.Unlock()
+	if resumeToken != nil {
+		opts.SetResumeAfter(resumeToken)
+	} else if request.StartAtTime > 0 {
+		opts.SetStartAtOperationTime(&primitive.Timestamp{T: uint32(request.StartAtTime)})
+	}
+	return opts
+}
+
+func (this_ *watcher) open() (stream *mongo.ChangeStream, err error) {
+	request := this_.request
+	opts := this_.getOptions()
+	if request.DatabaseName == "" {
+		stream, err = this_.client.Watch(this_.Context(), this_.pipeline, opts)
+	} else if request.CollectionName == "" {
+		stream, err = this_.client.Database(request.DatabaseName).Watch(this_.Context(), this_.pipeline, opts)
+	} else {
+		stream, err = this_.client.Database(request.DatabaseName).Collection(request.CollectionName).Watch(this_.Context(), this_.pipeline, opts)
+	}
+	return
+}
+
+// watch 读取 变更流，出错 时 使用 最后 的 恢复 令牌 重新 打开
+func (this_ *watcher) watch(stream *mongo.ChangeStream) {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("mongodb watch error", zap.Any("error", e))
+		}
+		this_.Stop()
+	}()
+	var retryTimes int
+	for {
+		for stream.Next(this_.Context()) {
+			retryTimes = 0
+			this_.onChange(stream.Current, stream.ResumeToken())
+		}
+		err := stream.Err()
+		_ = stream.Close(context.Background())
+		if this_.IsStopped() {
+			return
+		}
+		if err == nil {
+			// 集合 删除 等 invalidate 事件 后 变更流 结束
+			this_.push(&WatchMessage{Type: "error", Time: util.GetNowMilli(), Error: "变更流 已 结束"})
+			return
+		}
+		retryTimes++
+		if retryTimes > watchRetryTimes {
+			this_.push(&WatchMessage{Type: "error", Time: util.GetNowMilli(), Error: err.Error()})
+			return
+		}
+		this_.push(&WatchMessage{Type: "error", Time: util.GetNowMilli(), Error: err.Error() + "，" + watchRetryInterval.String() + " 后 重新 连接"})
+		select {
+		case <-this_.Done():
+			return
+		case <-time.After(watchRetryInterval):
+		}
+		if stream, err = this_.open(); err != nil {
+			this_.push(&WatchMessage{Type: "error", Time: util.GetNowMilli(), Error: err.Error()})
+			return
+		}
+	}
+}
+
+func (this_ *watcher) onChange(event bson.Raw, resumeToken bson.Raw) {
+	this_.lock.Lock()
+	this_.received++
+	if resumeToken != nil {
+		this_.resumeToken = append(bson.Raw{}, resumeToken...)
+	}
+	this_.lock.Unlock()
+
+	msg := &WatchMessage{
+		Type: "change",
+		Time: util.GetNowMilli(),
+	}
+	if v, ok := event.Lookup("operationType").StringValueOK(); ok {
+		msg.OperationType = v
+	}
+	if v, ok := event.Lookup("ns", "db").StringValueOK(); ok {
+		msg.DatabaseName = v
+	}
+	if v, ok := event.Lookup("ns", "coll").StringValueOK(); ok {
+		msg.CollectionName = v
+	}
+	if t, _, ok := event.Lookup("clusterTime").TimestampOK(); ok {
+		msg.ClusterTime = int64(t)
+	}
+	if bs, err := bson.MarshalExtJSONIndent(event, false, false, "", "  "); err == nil {
+		msg.Value = string(bs)
+	} else {
+		msg.Value = event.String()
+	}
+	if resumeToken != nil {
+		if bs, err := bson.MarshalExtJSON(resumeToken, true, false); err == nil {
+			msg.ResumeToken = string(bs)
+		}
+	}
+	this_.push(msg)
+}
+
+func (this_ *watcher) push(msg *WatchMessage) {
+	select {
+	case <-this_.Done():
+	case this_.buffer <- msg:
+	}
+}
+
+func (this_ *watcher) stats() *WatchMessage {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	return &WatchMessage{
+		Type:     "stats",
+		Time:     util.GetNowMilli(),
+		Received: this_.received,
+		Sent:     this_.sent,
+	}
+}
+
+// send 按 速率 限制 推送 事件，并 每秒 推送 统计
+func (this_ *watcher) send() {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("mongodb watch send error", zap.Any("error", e))
+		}
+		this_.Stop()
+	}()
+	limiter := time.NewTicker(base.RateLimitInterval(this_.request.RateLimit))
+	defer limiter.Stop()
+	statsTicker := time.NewTicker(time.Second)
+	defer statsTicker.Stop()
+	for {
+		select {
+		case <-this_.Done():
+			return
+		case <-statsTicker.C:
+			if this_.WriteJSON(this_.stats()) != nil {
+				return
+			}
+		case msg := <-this_.buffer:
+			if this_.WriteJSON(msg) != nil {
+				return
+			}
+			if msg.Type != "change" {
+				continue
+			}
+			this_.lock.Lock()
+			this_.sent++
+			this_.lock.Unlock()
+			select {
+			case <-this_.Done():
+				return
+			case <-limiter.C:
+			}
+		}
+	}
+}
+
+func (this_ *watcher) Start() (err error) {
+	stream, err := this_.open()
+	if err != nil {
+		return
+	}
+	util.Logger.Info("mongodb watch start", zap.Any("key", this_.Key), zap.Any("database", this_.request.DatabaseName), zap.Any("collection", this_.request.CollectionName))
+	go this_.watch(stream)
+	go this_.send()
+	return
+}
+
+// OnStop 变更流 使用 会话 的 Context，会话 结束 时 已 取消
+func (this_ *watcher) OnStop() {
+}
+
+// watchKey 创建 变更流 会话，返回 key 用于 建立 websocket
+// 重新 连接 时 传入 最后 收到 的 resumeToken 可 从 断开 的 位置 继续
+func (this_ *api) watchKey(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &WatchRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.DatabaseName == "" && request.CollectionName != "" {
+		err = errors.New("监听 集合 需要 指定 库")
+		return
+	}
+	pipeline, err := request.getPipeline()
+	if err != nil {
+		return
+	}
+	if request.RateLimit <= 0 {
+		request.RateLimit = 100
+	}
+	one := &watcher{
+		WebsocketSession: base.NewWebsocketSession(requestBean, request.WorkerId),
+		request:          request,
+		client:           client,
+		pipeline:         pipeline,
+		buffer:           make(chan *WatchMessage, 1000),
+	}
+	if strings.TrimSpace(request.ResumeToken) != "" {
+		var resumeToken bson.Raw
+		if err = bson.UnmarshalExtJSON([]byte(request.ResumeToken), true, &resumeToken); err != nil {
+			err = errors.New("resumeToken 解析 失败: " + err.Error())
+			return
+		}
+		one.resumeToken = resumeToken
+	}
+	res = watcherCache.Add(one)
+	return
+}
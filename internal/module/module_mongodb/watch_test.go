@@ -0,0 +1,55 @@
+package module_mongodb
+
+import (
+	"testing"
+)
+
+func TestWatchRequestGetPipeline(t *testing.T) {
+	for _, one := range []struct {
+		request *WatchRequest
+		stages  []string
+		error   bool
+	}{
+		{&WatchRequest{}, nil, false},
+		{&WatchRequest{Match: `{"operationType":{"$in":["insert","update"]}}`}, []string{"$match"}, false},
+		{&WatchRequest{Match: `{"operationType":"insert"}`, Pipeline: `[{"$project":{"fullDocument":1}}]`}, []string{"$match", "$project"}, false},
+		{&WatchRequest{Pipeline: `[{"$project":{"fullDocument":1}}]`}, []string{"$project"}, false},
+		{&WatchRequest{Match: `{"a":`}, nil, true},
+		{&WatchRequest{Pipeline: `[{"project":{}}]`}, nil, true},
+	} {
+		pipeline, err := one.request.getPipeline()
+		if (err != nil) != one.error {
+			t.Errorf("request %+v expect error %v, got %v", one.request, one.error, err)
+			continue
+		}
+		if one.error {
+			continue
+		}
+		if pipeline == nil || len(pipeline) != len(one.stages) {
+			t.Errorf("request %+v expect %d stages, got %v", one.request, len(one.stages), pipeline)
+			continue
+		}
+		for i, stage := range pipeline {
+			if stage[0].Key != one.stages[i] {
+				t.Errorf("request %+v stage %d expect %s, got %s", one.request, i, one.stages[i], stage[0].Key)
+			}
+		}
+	}
+}
+
+func TestWatcherResumeOptions(t *testing.T) {
+	one := &watcher{request: &WatchRequest{FullDocument: "updateLookup", StartAtTime: 100}}
+	opts := one.getOptions()
+	if opts.FullDocument == nil || string(*opts.FullDocument) != "updateLookup" {
+		t.Errorf("fullDocument expect updateLookup")
+	}
+	if opts.StartAtOperationTime == nil || opts.StartAtOperationTime.T != 100 || opts.ResumeAfter != nil {
+		t.Errorf("expect start at time 100 without resume token")
+	}
+	// 收到 事件 后 使用 恢复 令牌，不再 使用 开始 时间
+	one.resumeToken = []byte{5, 0, 0, 0, 0}
+	opts = one.getOptions()
+	if opts.ResumeAfter == nil || opts.StartAtOperationTime != nil {
+		t.Errorf("expect resume after token")
+	}
+}
@@ -0,0 +1,118 @@
+package module_mqtt
+
+import (
+	"github.com/gin-gonic/gin"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+)
+
+type api struct {
+	toolboxService *module_toolbox.ToolboxService
+}
+
+func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+	return &api{
+		toolboxService: toolboxService,
+	}
+}
+
+var (
+	Power         = base.AppendPower(&base.PowerAction{Action: "mqtt", Text: "MQTT", ShouldLogin: true, StandAlone: true})
+	check         = base.AppendPower(&base.PowerAction{Action: "check", Text: "MQTT测试", ShouldLogin: true, StandAlone: true, Parent: Power})
+	publishPower  = base.AppendPower(&base.PowerAction{Action: "publish", Text: "MQTT发送消息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	retainedPower = base.AppendPower(&base.PowerAction{Action: "retained", Text: "MQTT查询保留消息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	closePower    = base.AppendPower(&base.PowerAction{Action: "close", Text: "MQTT关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	subscribeKeyPower       = base.AppendPower(&base.PowerAction{Action: "subscribe/key", Text: "MQTT订阅Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	subscribeWebsocketPower = base.AppendPower(&base.PowerAction{Action: "subscribe/websocket", Text: "MQTT订阅WebSocket", ShouldLogin: true, StandAlone: true, Parent: Power})
+	subscribeClosePower     = base.AppendPower(&base.PowerAction{Action: "subscribe/close", Text: "MQTT订阅关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: check, Do: this_.check})
+	apis = append(apis, &base.ApiWorker{Power: publishPower, Do: this_.publish})
+	apis = append(apis, &base.ApiWorker{Power: retainedPower, Do: this_.retained})
+	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+
+	apis = append(apis, &base.ApiWorker{Power: subscribeKeyPower, Do: this_.subscribeKey})
+	apis = append(apis, &base.ApiWorker{Power: subscribeWebsocketPower, Do: subscriberCache.Websocket, IsWebSocket: true})
+	apis = append(apis, &base.ApiWorker{Power: subscribeClosePower, Do: subscriberCache.Close})
+
+	return
+}
+
+func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *Config, sshConfig *ssh.Config, err error) {
+	config = &Config{}
+	sshConfig, err = this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	// 工具 保存 时 密码 已 加密
+	config.Password = this_.toolboxService.DecryptOptionAttr(config.Password)
+	if config.CaCert != "" {
+		config.CaCert = this_.toolboxService.GetFilesFile(config.CaCert)
+	}
+	if config.ClientCert != "" {
+		config.ClientCert = this_.toolboxService.GetFilesFile(config.ClientCert)
+	}
+	if config.ClientKey != "" {
+		config.ClientKey = this_.toolboxService.GetFilesFile(config.ClientKey)
+	}
+	return
+}
+
+type BaseRequest struct {
+	WorkerId string `json:"workerId"`
+}
+
+func (this_ *api) check(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	_, err = getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) publish(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+	request := &PublishRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = publish(service, request)
+	return
+}
+
+func (this_ *api) retained(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &RetainedRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = retained(config, sshConfig, request)
+	return
+}
+
+func (this_ *api) close(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	subscriberCache.RemoveWorker(request.WorkerId)
+	return
+}
@@ -0,0 +1,217 @@
+package module_mqtt
+
+import (
+	"encoding/base64"
+	"errors"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/team-ide/go-tool/util"
+	"sort"
+	"strconv"
+	"sync"
+	"teamide/pkg/ssh"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// 一次 最多 发送 的 消息 数
+	maxPublishCount = 10000
+	// 保留 消息 最多 返回 的 数量
+	defaultMaxRetained = 1000
+	// 订阅 失败 时 服务端 返回 的 结果 码
+	subscribeFailure = 0x80
+)
+
+type Message struct {
+	Type      string `json:"type"` // message connected connectionLost error
+	Topic     string `json:"topic,omitempty"`
+	Payload   string `json:"payload,omitempty"`
+	Base64    bool   `json:"base64,omitempty"`
+	Qos       byte   `json:"qos"`
+	Retained  bool   `json:"retained,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	MessageId uint16 `json:"messageId,omitempty"`
+	Time      int64  `json:"time"`
+	Error     string `json:"error,omitempty"`
+}
+
+func toMessage(msg mqtt.Message) (res *Message) {
+	res = &Message{
+		Type:      "message",
+		Topic:     msg.Topic(),
+		Qos:       msg.Qos(),
+		Retained:  msg.Retained(),
+		Duplicate: msg.Duplicate(),
+		MessageId: msg.MessageID(),
+		Time:      util.GetNowMilli(),
+	}
+	payload := msg.Payload()
+	if utf8.Valid(payload) {
+		res.Payload = string(payload)
+	} else {
+		res.Payload = base64.StdEncoding.EncodeToString(payload)
+		res.Base64 = true
+	}
+	return
+}
+
+func checkQos(qos byte) (err error) {
+	if qos > 2 {
+		err = errors.New("qos must be 0, 1 or 2")
+	}
+	return
+}
+
+// waitToken 等待 操作 完成，超时 返回 错误
+func waitToken(token mqtt.Token, action string) (err error) {
+	if !token.WaitTimeout(30 * time.Second) {
+		err = errors.New("mqtt " + action + " timeout")
+		return
+	}
+	err = token.Error()
+	return
+}
+
+// subscribe 订阅 并 检查 服务端 返回 的 结果 码
+func subscribe(client mqtt.Client, filters map[string]byte, callback mqtt.MessageHandler) (err error) {
+	token := client.SubscribeMultiple(filters, callback)
+	if err = waitToken(token, "subscribe"); err != nil {
+		return
+	}
+	for filter, code := range token.(*mqtt.SubscribeToken).Result() {
+		if code == subscribeFailure {
+			err = errors.New("topic filter [" + filter + "] subscribe refused by broker")
+			return
+		}
+	}
+	return
+}
+
+type PublishRequest struct {
+	Topic   string `json:"topic"`
+	Payload string `json:"payload"`
+	Base64  bool   `json:"base64"` // Payload 为 Base64 编码 的 二进制 数据
+	Qos     byte   `json:"qos"`
+	Retain  bool   `json:"retain"` // 保留 消息，Payload 为 空 时 清除 主题 的 保留 消息
+	Count   int    `json:"count"`  // 发送 次数 默认 1
+}
+
+// toPayload 校验 发送 请求 并 解码 消息 内容
+func toPayload(request *PublishRequest) (payload []byte, count int, err error) {
+	if request.Topic == "" {
+		err = errors.New("topic can not be empty")
+		return
+	}
+	if err = checkQos(request.Qos); err != nil {
+		return
+	}
+	payload = []byte(request.Payload)
+	if request.Base64 {
+		payload, err = base64.StdEncoding.DecodeString(request.Payload)
+		if err != nil {
+			err = errors.New("payload base64 decode error:" + err.Error())
+			return
+		}
+	}
+	count = request.Count
+	if count <= 0 {
+		count = 1
+	}
+	if count > maxPublishCount {
+		err = errors.New("publish count can not be greater than " + strconv.Itoa(maxPublishCount))
+		return
+	}
+	return
+}
+
+func publish(conn *Conn, request *PublishRequest) (res interface{}, err error) {
+	payload, count, err := toPayload(request)
+	if err != nil {
+		return
+	}
+	var tokens []mqtt.Token
+	for i := 0; i < count; i++ {
+		tokens = append(tokens, conn.Publish(request.Topic, request.Qos, request.Retain, payload))
+	}
+	for _, token := range tokens {
+		if err = waitToken(token, "publish"); err != nil {
+			return
+		}
+	}
+	data := map[string]interface{}{}
+	data["count"] = count
+	res = data
+	return
+}
+
+type RetainedRequest struct {
+	TopicFilter string `json:"topicFilter"` // 默认 #
+	WaitTime    int    `json:"waitTime"`    // 最多 等待 毫秒 默认 2000
+	MaxMessages int    `json:"maxMessages"` // 默认 1000
+}
+
+// retained 使用 新 的 连接 订阅，服务端 在 订阅 后 立即 推送 匹配 的 保留 消息
+func retained(config *Config, sshConfig *ssh.Config, request *RetainedRequest) (res interface{}, err error) {
+	topicFilter := request.TopicFilter
+	if topicFilter == "" {
+		topicFilter = "#"
+	}
+	waitTime := request.WaitTime
+	if waitTime <= 0 {
+		waitTime = 2000
+	}
+	maxMessages := request.MaxMessages
+	if maxMessages <= 0 {
+		maxMessages = defaultMaxRetained
+	}
+	conn, err := newConn(config, sshConfig, config.getClientId("retained"), nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var messages []*Message
+	var lock sync.Mutex
+	received := make(chan struct{}, 1)
+	err = subscribe(conn, map[string]byte{topicFilter: 0}, func(_ mqtt.Client, msg mqtt.Message) {
+		if !msg.Retained() {
+			return
+		}
+		lock.Lock()
+		if len(messages) < maxMessages {
+			messages = append(messages, toMessage(msg))
+		}
+		lock.Unlock()
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		return
+	}
+	// 保留 消息 连续 推送，500 毫秒 没有 新 消息 或 达到 等待 时间 后 结束
+	timeout := time.After(time.Duration(waitTime) * time.Millisecond)
+wait:
+	for {
+		select {
+		case <-timeout:
+			break wait
+		case <-received:
+		case <-time.After(500 * time.Millisecond):
+			break wait
+		}
+	}
+	conn.Unsubscribe(topicFilter).WaitTimeout(time.Second)
+
+	lock.Lock()
+	defer lock.Unlock()
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].Topic < messages[j].Topic
+	})
+	data := map[string]interface{}{}
+	data["messages"] = messages
+	data["more"] = len(messages) >= maxMessages
+	res = data
+	return
+}
@@ -0,0 +1,90 @@
+package module_mqtt
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testMessage struct {
+	topic     string
+	payload   []byte
+	qos       byte
+	retained  bool
+	duplicate bool
+	messageId uint16
+}
+
+func (this_ *testMessage) Duplicate() bool   { return this_.duplicate }
+func (this_ *testMessage) Qos() byte         { return this_.qos }
+func (this_ *testMessage) Retained() bool    { return this_.retained }
+func (this_ *testMessage) Topic() string     { return this_.topic }
+func (this_ *testMessage) MessageID() uint16 { return this_.messageId }
+func (this_ *testMessage) Payload() []byte   { return this_.payload }
+func (this_ *testMessage) Ack()              {}
+
+func TestToMessage(t *testing.T) {
+	for _, one := range []struct {
+		msg    *testMessage
+		expect *Message
+	}{
+		{&testMessage{topic: "a/b", payload: []byte("hello"), qos: 1, messageId: 7},
+			&Message{Type: "message", Topic: "a/b", Payload: "hello", Qos: 1, MessageId: 7}},
+		// 非 UTF-8 内容 使用 Base64
+		{&testMessage{topic: "a", payload: []byte{0xff, 0xfe}, retained: true, duplicate: true},
+			&Message{Type: "message", Topic: "a", Payload: "//4=", Base64: true, Retained: true, Duplicate: true}},
+	} {
+		res := toMessage(one.msg)
+		if res.Time <= 0 {
+			t.Errorf("message time expect set, got %d", res.Time)
+		}
+		res.Time = 0
+		if !reflect.DeepEqual(res, one.expect) {
+			t.Errorf("message %+v expect %+v, got %+v", one.msg, one.expect, res)
+		}
+	}
+}
+
+func TestToPayload(t *testing.T) {
+	for _, one := range []struct {
+		request *PublishRequest
+		payload []byte
+		count   int
+		isErr   bool
+	}{
+		{&PublishRequest{Topic: "a", Payload: "hello"}, []byte("hello"), 1, false},
+		{&PublishRequest{Topic: "a", Payload: "//4=", Base64: true, Qos: 2, Count: 5}, []byte{0xff, 0xfe}, 5, false},
+		// 空 内容 用于 清除 保留 消息
+		{&PublishRequest{Topic: "a", Retain: true}, []byte{}, 1, false},
+		{&PublishRequest{Payload: "hello"}, nil, 0, true},
+		{&PublishRequest{Topic: "a", Qos: 3}, nil, 0, true},
+		{&PublishRequest{Topic: "a", Payload: "!", Base64: true}, nil, 0, true},
+		{&PublishRequest{Topic: "a", Count: maxPublishCount + 1}, nil, 0, true},
+	} {
+		payload, count, err := toPayload(one.request)
+		if (err != nil) != one.isErr {
+			t.Errorf("request %+v expect error %v, got %v", one.request, one.isErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(payload, one.payload) || count != one.count {
+			t.Errorf("request %+v expect %v %d, got %v %d", one.request, one.payload, one.count, payload, count)
+		}
+	}
+}
+
+func TestGetBroker(t *testing.T) {
+	for _, one := range []struct {
+		config *Config
+		expect string
+	}{
+		{&Config{Address: "127.0.0.1:1883"}, "tcp://127.0.0.1:1883"},
+		{&Config{Address: "127.0.0.1:8883", Tls: true}, "ssl://127.0.0.1:8883"},
+		{&Config{Address: "ws://127.0.0.1:8083/mqtt", Tls: true}, "ws://127.0.0.1:8083/mqtt"},
+	} {
+		if res := one.config.getBroker(); res != one.expect {
+			t.Errorf("address %s expect %s, got %s", one.config.Address, one.expect, res)
+		}
+	}
+}
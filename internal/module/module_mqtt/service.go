@@ -0,0 +1,218 @@
+package module_mqtt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	goSSH "golang.org/x/crypto/ssh"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+type Config struct {
+	Address            string `json:"address"` // tcp://127.0.0.1:1883，支持 tcp ssl ws wss
+	ClientId           string `json:"clientId,omitempty"`
+	Username           string `json:"username,omitempty"`
+	Password           string `json:"password,omitempty"`
+	ProtocolVersion    string `json:"protocolVersion,omitempty"` // 3.1 3.1.1，默认 3.1.1
+	Tls                bool   `json:"tls,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	CaCert             string `json:"caCert,omitempty"`
+	ClientCert         string `json:"clientCert,omitempty"`
+	ClientKey          string `json:"clientKey,omitempty"`
+	ConnectTimeout     int    `json:"connectTimeout,omitempty"` // 连接 超时 毫秒
+}
+
+// getBroker 地址 没有 协议 时 按 是否 开启 TLS 使用 tcp 或 ssl
+func (this_ *Config) getBroker() string {
+	if strings.Contains(this_.Address, "://") {
+		return this_.Address
+	}
+	if this_.Tls {
+		return "ssl://" + this_.Address
+	}
+	return "tcp://" + this_.Address
+}
+
+func (this_ *Config) getTlsConfig() (res *tls.Config, err error) {
+	res = &tls.Config{
+		InsecureSkipVerify: this_.InsecureSkipVerify,
+	}
+	if this_.CaCert != "" {
+		var bs []byte
+		bs, err = os.ReadFile(this_.CaCert)
+		if err != nil {
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			err = errors.New("ca cert [" + this_.CaCert + "] append error")
+			return
+		}
+		res.RootCAs = pool
+	}
+	if this_.ClientCert != "" && this_.ClientKey != "" {
+		var cert tls.Certificate
+		cert, err = tls.LoadX509KeyPair(this_.ClientCert, this_.ClientKey)
+		if err != nil {
+			return
+		}
+		res.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+// getClientId 同一 Broker 上 客户端 ID 不能 重复，订阅 等 独立 连接 追加 后缀
+func (this_ *Config) getClientId(suffix string) string {
+	clientId := this_.ClientId
+	if clientId == "" {
+		clientId = "teamide"
+	}
+	return clientId + "-" + suffix + "-" + util.GetUUID()[:8]
+}
+
+// Conn 一个 MQTT 客户端 连接，SSH 隧道 跟随 连接 关闭
+type Conn struct {
+	mqtt.Client
+	sshClient *goSSH.Client
+}
+
+func (this_ *Conn) Close() {
+	if this_.Client != nil {
+		this_.Client.Disconnect(250)
+	}
+	if this_.sshClient != nil {
+		_ = this_.sshClient.Close()
+	}
+}
+
+// sshOpenConnection 通过 SSH 隧道 建立 连接，只 支持 tcp 和 ssl
+func sshOpenConnection(sshClient *goSSH.Client) mqtt.OpenConnectionFunc {
+	return func(uri *url.URL, options mqtt.ClientOptions) (conn net.Conn, err error) {
+		switch uri.Scheme {
+		case "tcp", "mqtt":
+			return sshClient.Dial("tcp", uri.Host)
+		case "ssl", "tls", "mqtts", "tcps":
+			conn, err = sshClient.Dial("tcp", uri.Host)
+			if err != nil {
+				return
+			}
+			tlsConfig := options.TLSConfig.Clone()
+			if tlsConfig.ServerName == "" {
+				tlsConfig.ServerName = uri.Hostname()
+			}
+			tlsConn := tls.Client(conn, tlsConfig)
+			if err = tlsConn.Handshake(); err != nil {
+				_ = conn.Close()
+				return
+			}
+			conn = tlsConn
+			return
+		}
+		err = errors.New("scheme [" + uri.Scheme + "] not support ssh tunnel, please use tcp or ssl")
+		return
+	}
+}
+
+// newConn 建立 连接，setOptions 用于 设置 连接 及 断开 等 回调
+func newConn(config *Config, sshConfig *ssh.Config, clientId string, setOptions func(options *mqtt.ClientOptions)) (res *Conn, err error) {
+	res = &Conn{}
+	if config.Address == "" {
+		err = errors.New("mqtt address is empty")
+		return
+	}
+	connectTimeout := config.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = 10 * 1000
+	}
+	options := mqtt.NewClientOptions().
+		AddBroker(config.getBroker()).
+		SetClientID(clientId).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectTimeout(time.Duration(connectTimeout) * time.Millisecond)
+	switch config.ProtocolVersion {
+	case "", "3.1.1":
+		options.SetProtocolVersion(4)
+	case "3.1":
+		options.SetProtocolVersion(3)
+	default:
+		err = errors.New("mqtt protocol version [" + config.ProtocolVersion + "] not support")
+		return
+	}
+	tlsConfig, err := config.getTlsConfig()
+	if err != nil {
+		return
+	}
+	options.SetTLSConfig(tlsConfig)
+	if sshConfig != nil {
+		res.sshClient, err = ssh.NewClient(*sshConfig)
+		if err != nil {
+			return
+		}
+		options.SetCustomOpenConnectionFn(sshOpenConnection(res.sshClient))
+	}
+	if setOptions != nil {
+		setOptions(options)
+	}
+	res.Client = mqtt.NewClient(options)
+	token := res.Client.Connect()
+	if !token.WaitTimeout(options.ConnectTimeout + time.Second) {
+		err = errors.New("mqtt connect [" + config.getBroker() + "] timeout")
+	} else {
+		err = token.Error()
+	}
+	if err != nil {
+		res.Close()
+		return
+	}
+	return
+}
+
+// getService 缓存 的 连接 用于 测试 和 发送 消息
+func getService(config *Config, sshConfig *ssh.Config) (res *Conn, err error) {
+	key := "mqtt-" + config.getBroker() + "-" + config.ClientId
+	if config.Username != "" {
+		key += "-" + base.GetMd5String(key+config.Username)
+	}
+	if config.Password != "" {
+		key += "-" + base.GetMd5String(key+config.Password)
+	}
+	key += "-" + base.GetMd5String(config.CaCert+config.ClientCert+config.ClientKey)
+	if sshConfig != nil {
+		key += "-ssh-" + sshConfig.Address
+		key += "-ssh-" + sshConfig.Username
+	}
+	var serviceInfo *base.ServiceInfo
+	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
+		var s *Conn
+		s, err = newConn(config, sshConfig, config.getClientId("pub"), nil)
+		if err != nil {
+			util.Logger.Error("getMqttService error", zap.Any("key", key), zap.Error(err))
+			return
+		}
+		res = &base.ServiceInfo{
+			WaitTime:    10 * 60 * 1000,
+			LastUseTime: util.GetNowMilli(),
+			Service:     s,
+			Stop:        s.Close,
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = serviceInfo.Service.(*Conn)
+	serviceInfo.SetLastUseTime()
+	return
+}
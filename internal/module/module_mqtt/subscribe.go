@@ -0,0 +1,146 @@
+package module_mqtt
+
+import (
+	"errors"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/util"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+)
+
+type TopicFilter struct {
+	Filter string `json:"filter"`
+	Qos    byte   `json:"qos"`
+}
+
+type SubscribeRequest struct {
+	WorkerId string         `json:"workerId"`
+	Key      string         `json:"key"`
+	Topics   []*TopicFilter `json:"topics"`
+}
+
+// subscriber 每个 订阅 会话 使用 独立 的 连接，断线 重连 后 重新 订阅
+type subscriber struct {
+	*base.WebsocketSession
+	request   *SubscribeRequest
+	config    *Config
+	sshConfig *ssh.Config
+	conn      *Conn
+	connLock  sync.Mutex
+}
+
+var subscriberCache = base.NewWebsocketCache("mqtt subscribe")
+
+func (this_ *subscriber) write(msg *Message) {
+	if msg.Time == 0 {
+		msg.Time = util.GetNowMilli()
+	}
+	if err := this_.WriteJSON(msg); err != nil {
+		go this_.Stop()
+	}
+}
+
+func (this_ *subscriber) writeError(err error) {
+	this_.write(&Message{Type: "error", Error: err.Error()})
+}
+
+func (this_ *subscriber) filters() map[string]byte {
+	filters := map[string]byte{}
+	for _, one := range this_.request.Topics {
+		filters[one.Filter] = one.Qos
+	}
+	return filters
+}
+
+func (this_ *subscriber) onMessage(_ mqtt.Client, msg mqtt.Message) {
+	this_.write(toMessage(msg))
+}
+
+// onConnect 连接 及 重连 成功 后 订阅，清除 会话 的 连接 重连 后 订阅 会 丢失
+func (this_ *subscriber) onConnect(client mqtt.Client) {
+	select {
+	case <-this_.Done():
+		return
+	default:
+	}
+	if err := subscribe(client, this_.filters(), this_.onMessage); err != nil {
+		this_.writeError(err)
+		return
+	}
+	this_.write(&Message{Type: "connected"})
+}
+
+func (this_ *subscriber) onConnectionLost(_ mqtt.Client, err error) {
+	this_.write(&Message{Type: "connectionLost", Error: err.Error()})
+}
+
+func (this_ *subscriber) connect() {
+	conn, err := newConn(this_.config, this_.sshConfig, this_.config.getClientId("sub"), func(options *mqtt.ClientOptions) {
+		options.SetOnConnectHandler(this_.onConnect)
+		options.SetConnectionLostHandler(this_.onConnectionLost)
+	})
+	if err != nil {
+		this_.writeError(err)
+		this_.Stop()
+		return
+	}
+	this_.connLock.Lock()
+	defer this_.connLock.Unlock()
+	select {
+	case <-this_.Done():
+		// 连接 过程 中 已 停止
+		conn.Close()
+		return
+	default:
+	}
+	this_.conn = conn
+}
+
+func (this_ *subscriber) Start() (err error) {
+	go this_.connect()
+	return
+}
+
+func (this_ *subscriber) OnStop() {
+	this_.connLock.Lock()
+	defer this_.connLock.Unlock()
+	if this_.conn != nil {
+		this_.conn.Close()
+	}
+}
+
+// subscribeKey 创建 订阅 会话，返回 key 用于 建立 websocket
+func (this_ *api) subscribeKey(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &SubscribeRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if len(request.Topics) == 0 {
+		err = errors.New("topics can not be empty")
+		return
+	}
+	for _, one := range request.Topics {
+		if one.Filter == "" {
+			err = errors.New("topic filter can not be empty")
+			return
+		}
+		if err = checkQos(one.Qos); err != nil {
+			return
+		}
+	}
+	one := &subscriber{
+		WebsocketSession: base.NewWebsocketSession(requestBean, request.WorkerId),
+		request:          request,
+		config:           config,
+		sshConfig:        sshConfig,
+	}
+	res = subscriberCache.Add(one)
+	return
+}
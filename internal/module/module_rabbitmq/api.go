@@ -0,0 +1,170 @@
+package module_rabbitmq
+
+import (
+	"github.com/gin-gonic/gin"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+)
+
+type api struct {
+	toolboxService *module_toolbox.ToolboxService
+}
+
+func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+	return &api{
+		toolboxService: toolboxService,
+	}
+}
+
+var (
+	Power          = base.AppendPower(&base.PowerAction{Action: "rabbitmq", Text: "RabbitMQ", ShouldLogin: true, StandAlone: true})
+	check          = base.AppendPower(&base.PowerAction{Action: "check", Text: "RabbitMQ测试", ShouldLogin: true, StandAlone: true, Parent: Power})
+	overviewPower  = base.AppendPower(&base.PowerAction{Action: "overview", Text: "RabbitMQ概览", ShouldLogin: true, StandAlone: true, Parent: Power})
+	exchangesPower = base.AppendPower(&base.PowerAction{Action: "exchanges", Text: "RabbitMQ查询交换机", ShouldLogin: true, StandAlone: true, Parent: Power})
+	queuesPower    = base.AppendPower(&base.PowerAction{Action: "queues", Text: "RabbitMQ查询队列", ShouldLogin: true, StandAlone: true, Parent: Power})
+	bindingsPower  = base.AppendPower(&base.PowerAction{Action: "bindings", Text: "RabbitMQ查询绑定", ShouldLogin: true, StandAlone: true, Parent: Power})
+	publishPower   = base.AppendPower(&base.PowerAction{Action: "publish", Text: "RabbitMQ发送消息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	getPower       = base.AppendPower(&base.PowerAction{Action: "get", Text: "RabbitMQ获取消息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	purgePower     = base.AppendPower(&base.PowerAction{Action: "purge", Text: "RabbitMQ清空队列", ShouldLogin: true, StandAlone: true, Parent: Power})
+	closePower     = base.AppendPower(&base.PowerAction{Action: "close", Text: "RabbitMQ关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: check, Do: this_.check})
+	apis = append(apis, &base.ApiWorker{Power: overviewPower, Do: this_.overview})
+	apis = append(apis, &base.ApiWorker{Power: exchangesPower, Do: this_.exchanges})
+	apis = append(apis, &base.ApiWorker{Power: queuesPower, Do: this_.queues})
+	apis = append(apis, &base.ApiWorker{Power: bindingsPower, Do: this_.bindings})
+	apis = append(apis, &base.ApiWorker{Power: publishPower, Do: this_.publish})
+	apis = append(apis, &base.ApiWorker{Power: getPower, Do: this_.get})
+	apis = append(apis, &base.ApiWorker{Power: purgePower, Do: this_.purge})
+	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+
+	return
+}
+
+func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *Config, sshConfig *ssh.Config, err error) {
+	config = &Config{}
+	sshConfig, err = this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	// 工具 保存 时 密码 已 加密
+	config.Password = this_.toolboxService.DecryptOptionAttr(config.Password)
+	if config.CaCert != "" {
+		config.CaCert = this_.toolboxService.GetFilesFile(config.CaCert)
+	}
+	if config.ClientCert != "" {
+		config.ClientCert = this_.toolboxService.GetFilesFile(config.ClientCert)
+	}
+	if config.ClientKey != "" {
+		config.ClientKey = this_.toolboxService.GetFilesFile(config.ClientKey)
+	}
+	return
+}
+
+func (this_ *api) getService(requestBean *base.RequestBean, c *gin.Context) (res *Service, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	res, err = getService(config, sshConfig)
+	return
+}
+
+type BaseRequest struct {
+	Queue    string `json:"queue"`
+	Exchange string `json:"exchange"`
+}
+
+func (this_ *api) check(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	_, err = this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) overview(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	res, err = service.overview()
+	return
+}
+
+func (this_ *api) exchanges(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	res, err = service.exchanges()
+	return
+}
+
+func (this_ *api) queues(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	res, err = service.queues()
+	return
+}
+
+func (this_ *api) bindings(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = service.bindings(request.Queue, request.Exchange)
+	return
+}
+
+func (this_ *api) publish(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &PublishRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = service.publish(request)
+	return
+}
+
+func (this_ *api) get(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &GetRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = service.get(request)
+	return
+}
+
+func (this_ *api) purge(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, err := this_.getService(requestBean, c)
+	if err != nil {
+		return
+	}
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = service.purge(request.Queue)
+	return
+}
+
+func (this_ *api) close(_ *base.RequestBean, _ *gin.Context) (res interface{}, err error) {
+	return
+}
@@ -0,0 +1,90 @@
+package module_rabbitmq
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+type Exchange struct {
+	Name       string                 `json:"name"`
+	Vhost      string                 `json:"vhost"`
+	Type       string                 `json:"type"`
+	Durable    bool                   `json:"durable"`
+	AutoDelete bool                   `json:"auto_delete"`
+	Internal   bool                   `json:"internal"`
+	Arguments  map[string]interface{} `json:"arguments"`
+}
+
+type Queue struct {
+	Name                   string                 `json:"name"`
+	Vhost                  string                 `json:"vhost"`
+	Type                   string                 `json:"type"` // classic quorum stream
+	Durable                bool                   `json:"durable"`
+	AutoDelete             bool                   `json:"auto_delete"`
+	Exclusive              bool                   `json:"exclusive"`
+	Arguments              map[string]interface{} `json:"arguments"`
+	Node                   string                 `json:"node"`
+	State                  string                 `json:"state"`
+	Consumers              int64                  `json:"consumers"`
+	Messages               int64                  `json:"messages"`
+	MessagesReady          int64                  `json:"messages_ready"`
+	MessagesUnacknowledged int64                  `json:"messages_unacknowledged"`
+	Memory                 int64                  `json:"memory"`
+	IdleSince              string                 `json:"idle_since,omitempty"`
+}
+
+type Binding struct {
+	Source          string                 `json:"source"` // 为 空 表示 默认 交换机
+	Vhost           string                 `json:"vhost"`
+	Destination     string                 `json:"destination"`
+	DestinationType string                 `json:"destination_type"` // queue exchange
+	RoutingKey      string                 `json:"routing_key"`
+	Arguments       map[string]interface{} `json:"arguments"`
+	PropertiesKey   string                 `json:"properties_key"`
+}
+
+func (this_ *Service) vhostPath() string {
+	return url.PathEscape(this_.config.getVhost())
+}
+
+// overview 集群 概览，包含 版本、消息 速率、对象 数量 等，原样 返回
+func (this_ *Service) overview() (res map[string]interface{}, err error) {
+	res = map[string]interface{}{}
+	err = this_.management(http.MethodGet, "/api/overview", nil, &res)
+	return
+}
+
+func (this_ *Service) exchanges() (res []*Exchange, err error) {
+	err = this_.management(http.MethodGet, "/api/exchanges/"+this_.vhostPath(), nil, &res)
+	if err != nil {
+		return
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return
+}
+
+func (this_ *Service) queues() (res []*Queue, err error) {
+	err = this_.management(http.MethodGet, "/api/queues/"+this_.vhostPath(), nil, &res)
+	if err != nil {
+		return
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return
+}
+
+// bindings 查询 绑定，指定 队列 时 返回 队列 的 绑定，指定 交换机 时 返回 以 其 为 源 的 绑定
+func (this_ *Service) bindings(queue string, exchange string) (res []*Binding, err error) {
+	path := "/api/bindings/" + this_.vhostPath()
+	if queue != "" {
+		path = "/api/queues/" + this_.vhostPath() + "/" + url.PathEscape(queue) + "/bindings"
+	} else if exchange != "" {
+		path = "/api/exchanges/" + this_.vhostPath() + "/" + url.PathEscape(exchange) + "/bindings/source"
+	}
+	err = this_.management(http.MethodGet, path, nil, &res)
+	return
+}
@@ -0,0 +1,276 @@
+package module_rabbitmq
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// 一次 最多 获取 的 消息 数
+	maxGetCount = 1000
+	// 一次 最多 发送 的 消息 数
+	maxPublishCount = 10000
+)
+
+// 获取 消息 后 的 处理 方式
+const (
+	AckModeRequeue = "requeue" // 放回 队列，用于 查看 消息，放回 后 消息 标记 为 重新 投递
+	AckModeAck     = "ack"     // 确认，消息 从 队列 删除
+	AckModeReject  = "reject"  // 拒绝 且 不 放回，有 死信 交换机 时 进入 死信
+)
+
+type PublishRequest struct {
+	Exchange      string                 `json:"exchange"`
+	RoutingKey    string                 `json:"routingKey"`
+	Body          string                 `json:"body"`
+	Base64        bool                   `json:"base64"` // Body 为 Base64 编码 的 二进制 数据
+	Mandatory     bool                   `json:"mandatory"`
+	Count         int                    `json:"count"` // 发送 次数 默认 1
+	Headers       map[string]interface{} `json:"headers"`
+	ContentType   string                 `json:"contentType"`
+	Persistent    bool                   `json:"persistent"`
+	Priority      uint8                  `json:"priority"`
+	CorrelationId string                 `json:"correlationId"`
+	ReplyTo       string                 `json:"replyTo"`
+	Expiration    string                 `json:"expiration"` // 毫秒
+	MessageId     string                 `json:"messageId"`
+	Type          string                 `json:"type"`
+	AppId         string                 `json:"appId"`
+}
+
+type Message struct {
+	DeliveryTag     uint64                 `json:"deliveryTag"`
+	Exchange        string                 `json:"exchange"`
+	RoutingKey      string                 `json:"routingKey"`
+	Redelivered     bool                   `json:"redelivered"`
+	Body            string                 `json:"body"`
+	Base64          bool                   `json:"base64,omitempty"`
+	Headers         map[string]interface{} `json:"headers,omitempty"`
+	ContentType     string                 `json:"contentType,omitempty"`
+	ContentEncoding string                 `json:"contentEncoding,omitempty"`
+	DeliveryMode    uint8                  `json:"deliveryMode,omitempty"`
+	Priority        uint8                  `json:"priority,omitempty"`
+	CorrelationId   string                 `json:"correlationId,omitempty"`
+	ReplyTo         string                 `json:"replyTo,omitempty"`
+	Expiration      string                 `json:"expiration,omitempty"`
+	MessageId       string                 `json:"messageId,omitempty"`
+	Timestamp       int64                  `json:"timestamp,omitempty"`
+	Type            string                 `json:"type,omitempty"`
+	UserId          string                 `json:"userId,omitempty"`
+	AppId           string                 `json:"appId,omitempty"`
+}
+
+func toMessage(delivery *amqp.Delivery) (res *Message) {
+	res = &Message{
+		DeliveryTag:     delivery.DeliveryTag,
+		Exchange:        delivery.Exchange,
+		RoutingKey:      delivery.RoutingKey,
+		Redelivered:     delivery.Redelivered,
+		Headers:         delivery.Headers,
+		ContentType:     delivery.ContentType,
+		ContentEncoding: delivery.ContentEncoding,
+		DeliveryMode:    delivery.DeliveryMode,
+		Priority:        delivery.Priority,
+		CorrelationId:   delivery.CorrelationId,
+		ReplyTo:         delivery.ReplyTo,
+		Expiration:      delivery.Expiration,
+		MessageId:       delivery.MessageId,
+		Type:            delivery.Type,
+		UserId:          delivery.UserId,
+		AppId:           delivery.AppId,
+	}
+	if !delivery.Timestamp.IsZero() {
+		res.Timestamp = delivery.Timestamp.UnixMilli()
+	}
+	if utf8.Valid(delivery.Body) {
+		res.Body = string(delivery.Body)
+	} else {
+		res.Body = base64.StdEncoding.EncodeToString(delivery.Body)
+		res.Base64 = true
+	}
+	return
+}
+
+// toPublishing 解码 消息 体 并 校验 发送 次数
+func toPublishing(request *PublishRequest) (msg amqp.Publishing, count int, err error) {
+	body := []byte(request.Body)
+	if request.Base64 {
+		body, err = base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			err = errors.New("body base64 decode error:" + err.Error())
+			return
+		}
+	}
+	count = request.Count
+	if count <= 0 {
+		count = 1
+	}
+	if count > maxPublishCount {
+		err = errors.New("publish count can not be greater than " + strconv.Itoa(maxPublishCount))
+		return
+	}
+	msg = amqp.Publishing{
+		Headers:       request.Headers,
+		ContentType:   request.ContentType,
+		Priority:      request.Priority,
+		CorrelationId: request.CorrelationId,
+		ReplyTo:       request.ReplyTo,
+		Expiration:    request.Expiration,
+		MessageId:     request.MessageId,
+		Timestamp:     time.Now(),
+		Type:          request.Type,
+		AppId:         request.AppId,
+		Body:          body,
+	}
+	if request.Persistent {
+		msg.DeliveryMode = amqp.Persistent
+	}
+	return
+}
+
+func (this_ *Service) publish(request *PublishRequest) (res interface{}, err error) {
+	msg, count, err := toPublishing(request)
+	if err != nil {
+		return
+	}
+	var returned int
+	err = this_.withChannel(func(channel *amqp.Channel) (err error) {
+		// 开启 确认 模式，确保 消息 已 被 服务端 接收
+		if err = channel.Confirm(false); err != nil {
+			return
+		}
+		returns := channel.NotifyReturn(make(chan amqp.Return, count))
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		var confirms []*amqp.DeferredConfirmation
+		for i := 0; i < count; i++ {
+			var confirm *amqp.DeferredConfirmation
+			confirm, err = channel.PublishWithDeferredConfirmWithContext(ctx, request.Exchange, request.RoutingKey, request.Mandatory, false, msg)
+			if err != nil {
+				return
+			}
+			confirms = append(confirms, confirm)
+		}
+		for _, confirm := range confirms {
+			var ack bool
+			ack, err = confirm.WaitContext(ctx)
+			if err != nil {
+				return
+			}
+			if !ack {
+				err = errors.New("message publish nack by server")
+				return
+			}
+		}
+		// 无法 路由 的 消息 在 确认 之前 退回
+		returned = len(returns)
+		return
+	})
+	if err != nil {
+		return
+	}
+	data := map[string]interface{}{}
+	data["count"] = count
+	data["returned"] = returned
+	res = data
+	return
+}
+
+type GetRequest struct {
+	Queue   string `json:"queue"`
+	Count   int    `json:"count"`   // 最多 获取 的 消息 数 默认 10
+	AckMode string `json:"ackMode"` // requeue ack reject，默认 requeue
+}
+
+// get 逐条 获取 消息，全部 获取 后 再 统一 处理，避免 放回 的 消息 被 重复 获取
+func (this_ *Service) get(request *GetRequest) (res interface{}, err error) {
+	if request.Queue == "" {
+		err = errors.New("queue can not be empty")
+		return
+	}
+	count := request.Count
+	if count <= 0 {
+		count = 10
+	}
+	if count > maxGetCount {
+		count = maxGetCount
+	}
+	ackMode := request.AckMode
+	if ackMode == "" {
+		ackMode = AckModeRequeue
+	}
+	if ackMode != AckModeRequeue && ackMode != AckModeAck && ackMode != AckModeReject {
+		err = errors.New("ack mode [" + ackMode + "] not support")
+		return
+	}
+	var messages []*Message
+	var remaining int
+	err = this_.withChannel(func(channel *amqp.Channel) (err error) {
+		var last *amqp.Delivery
+		for len(messages) < count {
+			delivery, ok, e := channel.Get(request.Queue, false)
+			if e != nil {
+				err = e
+				break
+			}
+			if !ok {
+				remaining = 0
+				break
+			}
+			last = &delivery
+			remaining = int(delivery.MessageCount)
+			messages = append(messages, toMessage(&delivery))
+		}
+		if last == nil {
+			return
+		}
+		var e error
+		switch ackMode {
+		case AckModeAck:
+			e = last.Ack(true)
+		case AckModeReject:
+			e = last.Nack(true, false)
+		default:
+			e = last.Nack(true, true)
+		}
+		if err == nil {
+			err = e
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	if ackMode == AckModeRequeue {
+		remaining += len(messages)
+	}
+	data := map[string]interface{}{}
+	data["messages"] = messages
+	data["remaining"] = remaining
+	res = data
+	return
+}
+
+func (this_ *Service) purge(queue string) (res interface{}, err error) {
+	if queue == "" {
+		err = errors.New("queue can not be empty")
+		return
+	}
+	var count int
+	err = this_.withChannel(func(channel *amqp.Channel) (err error) {
+		count, err = channel.QueuePurge(queue, false)
+		return
+	})
+	if err != nil {
+		return
+	}
+	data := map[string]interface{}{}
+	data["count"] = count
+	res = data
+	return
+}
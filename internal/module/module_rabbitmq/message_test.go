@@ -0,0 +1,59 @@
+package module_rabbitmq
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestToMessage(t *testing.T) {
+	for _, one := range []struct {
+		delivery *amqp.Delivery
+		expect   *Message
+	}{
+		{&amqp.Delivery{DeliveryTag: 1, Exchange: "ex", RoutingKey: "rk", Body: []byte("hello")},
+			&Message{DeliveryTag: 1, Exchange: "ex", RoutingKey: "rk", Body: "hello"}},
+		// 非 UTF-8 消息 体 使用 Base64
+		{&amqp.Delivery{DeliveryTag: 2, Body: []byte{0xff, 0xfe}, Redelivered: true},
+			&Message{DeliveryTag: 2, Body: "//4=", Base64: true, Redelivered: true}},
+		{&amqp.Delivery{
+			Headers: amqp.Table{"a": "1"}, ContentType: "text/plain", DeliveryMode: amqp.Persistent, Priority: 3,
+			CorrelationId: "c1", MessageId: "m1", Timestamp: time.UnixMilli(1700000000123), Type: "t", UserId: "u", AppId: "app",
+		}, &Message{
+			Headers: map[string]interface{}{"a": "1"}, ContentType: "text/plain", DeliveryMode: amqp.Persistent, Priority: 3,
+			CorrelationId: "c1", MessageId: "m1", Timestamp: 1700000000123, Type: "t", UserId: "u", AppId: "app",
+		}},
+	} {
+		if res := toMessage(one.delivery); !reflect.DeepEqual(res, one.expect) {
+			t.Errorf("delivery %+v expect %+v, got %+v", one.delivery, one.expect, res)
+		}
+	}
+}
+
+func TestToPublishing(t *testing.T) {
+	for _, one := range []struct {
+		request *PublishRequest
+		body    []byte
+		count   int
+		mode    uint8
+		isErr   bool
+	}{
+		{&PublishRequest{Body: "hello"}, []byte("hello"), 1, 0, false},
+		{&PublishRequest{Body: "//4=", Base64: true, Count: 3, Persistent: true}, []byte{0xff, 0xfe}, 3, amqp.Persistent, false},
+		{&PublishRequest{Body: "!", Base64: true}, nil, 0, 0, true},
+		{&PublishRequest{Body: "a", Count: maxPublishCount + 1}, nil, 0, 0, true},
+	} {
+		msg, count, err := toPublishing(one.request)
+		if (err != nil) != one.isErr {
+			t.Errorf("request %+v expect error %v, got %v", one.request, one.isErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(msg.Body, one.body) || count != one.count || msg.DeliveryMode != one.mode {
+			t.Errorf("request %+v expect %v %d %d, got %v %d %d", one.request, one.body, one.count, one.mode, msg.Body, count, msg.DeliveryMode)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package module_rabbitmq
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	goSSH "golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+type Config struct {
+	Address            string `json:"address"` // AMQP 地址 127.0.0.1:5672
+	Vhost              string `json:"vhost,omitempty"`
+	Username           string `json:"username,omitempty"`
+	Password           string `json:"password,omitempty"`
+	ManagementAddress  string `json:"managementAddress,omitempty"` // 管理 接口 地址 http://127.0.0.1:15672
+	Tls                bool   `json:"tls,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	CaCert             string `json:"caCert,omitempty"`
+	ClientCert         string `json:"clientCert,omitempty"`
+	ClientKey          string `json:"clientKey,omitempty"`
+}
+
+func (this_ *Config) getVhost() string {
+	if this_.Vhost == "" {
+		return "/"
+	}
+	return this_.Vhost
+}
+
+// getTlsConfig AMQP 开启 TLS 或 管理 接口 使用 HTTPS 时 使用
+func (this_ *Config) getTlsConfig() (res *tls.Config, err error) {
+	res = &tls.Config{
+		InsecureSkipVerify: this_.InsecureSkipVerify,
+	}
+	if this_.CaCert != "" {
+		var bs []byte
+		bs, err = os.ReadFile(this_.CaCert)
+		if err != nil {
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			err = errors.New("ca cert [" + this_.CaCert + "] append error")
+			return
+		}
+		res.RootCAs = pool
+	}
+	if this_.ClientCert != "" && this_.ClientKey != "" {
+		var cert tls.Certificate
+		cert, err = tls.LoadX509KeyPair(this_.ClientCert, this_.ClientKey)
+		if err != nil {
+			return
+		}
+		res.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+type Service struct {
+	config     *Config
+	tlsConfig  *tls.Config
+	sshClient  *goSSH.Client
+	conn       *amqp.Connection
+	connLock   sync.Mutex
+	httpClient *http.Client
+}
+
+func (this_ *Service) Close() {
+	this_.connLock.Lock()
+	defer this_.connLock.Unlock()
+	if this_.conn != nil {
+		_ = this_.conn.Close()
+		this_.conn = nil
+	}
+	if this_.httpClient != nil {
+		this_.httpClient.CloseIdleConnections()
+	}
+	if this_.sshClient != nil {
+		_ = this_.sshClient.Close()
+	}
+}
+
+func (this_ *Service) dial(network, addr string) (net.Conn, error) {
+	if this_.sshClient != nil {
+		return this_.sshClient.Dial(network, addr)
+	}
+	return net.DialTimeout(network, addr, 10*time.Second)
+}
+
+// getConn 连接 断开 后 重新 连接
+func (this_ *Service) getConn() (res *amqp.Connection, err error) {
+	this_.connLock.Lock()
+	defer this_.connLock.Unlock()
+	if this_.conn != nil && !this_.conn.IsClosed() {
+		res = this_.conn
+		return
+	}
+	scheme := "amqp"
+	if this_.config.Tls {
+		scheme = "amqps"
+	}
+	// Vhost 在 配置 中 指定，不 放在 地址 中 避免 转义 问题
+	uri := &url.URL{
+		Scheme: scheme,
+		Host:   this_.config.Address,
+	}
+	if this_.config.Username != "" {
+		uri.User = url.UserPassword(this_.config.Username, this_.config.Password)
+	}
+	this_.conn, err = amqp.DialConfig(uri.String(), amqp.Config{
+		Vhost: this_.config.getVhost(),
+		// 连接 时 会 设置 ServerName，复制 一份 避免 影响 管理 接口
+		TLSClientConfig: this_.tlsConfig.Clone(),
+		Heartbeat:       10 * time.Second,
+		Properties:      amqp.Table{"connection_name": "Team IDE"},
+		Dial:            this_.dial,
+	})
+	if err != nil {
+		this_.conn = nil
+		return
+	}
+	res = this_.conn
+	return
+}
+
+// withChannel 每次 操作 使用 新 的 通道，通道 出错 后 不可 再 使用
+func (this_ *Service) withChannel(do func(channel *amqp.Channel) error) (err error) {
+	conn, err := this_.getConn()
+	if err != nil {
+		return
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		return
+	}
+	defer func() { _ = channel.Close() }()
+	err = do(channel)
+	return
+}
+
+// management 调用 管理 接口，path 中 的 参数 需要 已 转义
+func (this_ *Service) management(method string, path string, body interface{}, res interface{}) (err error) {
+	if this_.config.ManagementAddress == "" {
+		err = errors.New("management address is empty, please set it in toolbox config")
+		return
+	}
+	var reader io.Reader
+	if body != nil {
+		var bs []byte
+		bs, err = json.Marshal(body)
+		if err != nil {
+			return
+		}
+		reader = strings.NewReader(string(bs))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	address := strings.TrimSuffix(this_.config.ManagementAddress, "/")
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	req, err := http.NewRequestWithContext(ctx, method, address+path, reader)
+	if err != nil {
+		return
+	}
+	req.SetBasicAuth(this_.config.Username, this_.config.Password)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := this_.httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		e := &struct {
+			Error  string `json:"error"`
+			Reason string `json:"reason"`
+		}{}
+		_ = json.Unmarshal(bs, e)
+		msg := resp.Status
+		if e.Error != "" {
+			msg += "," + e.Error
+		}
+		if e.Reason != "" {
+			msg += ":" + e.Reason
+		}
+		err = errors.New("management [" + method + " " + path + "] error:" + msg)
+		return
+	}
+	if res != nil && len(bs) > 0 {
+		err = json.Unmarshal(bs, res)
+	}
+	return
+}
+
+func newService(config *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	res = &Service{config: config}
+	if config.Address == "" {
+		err = errors.New("rabbitmq address is empty")
+		return
+	}
+	if res.tlsConfig, err = config.getTlsConfig(); err != nil {
+		return
+	}
+	if sshConfig != nil {
+		res.sshClient, err = ssh.NewClient(*sshConfig)
+		if err != nil {
+			return
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = res.tlsConfig
+	if res.sshClient != nil {
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return res.sshClient.Dial(network, addr)
+		}
+	}
+	res.httpClient = &http.Client{Transport: transport}
+	if _, err = res.getConn(); err != nil {
+		res.Close()
+		return
+	}
+	return
+}
+
+func getService(config *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	key := "rabbitmq-" + config.Address + "-" + config.getVhost() + "-" + config.ManagementAddress
+	if config.Username != "" {
+		key += "-" + base.GetMd5String(key+config.Username)
+	}
+	if config.Password != "" {
+		key += "-" + base.GetMd5String(key+config.Password)
+	}
+	if config.Tls {
+		key += "-tls"
+	}
+	key += "-" + base.GetMd5String(config.CaCert+config.ClientCert+config.ClientKey)
+	if sshConfig != nil {
+		key += "-ssh-" + sshConfig.Address
+		key += "-ssh-" + sshConfig.Username
+	}
+	var serviceInfo *base.ServiceInfo
+	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
+		var s *Service
+		s, err = newService(config, sshConfig)
+		if err != nil {
+			util.Logger.Error("getRabbitmqService error", zap.Any("key", key), zap.Error(err))
+			return
+		}
+		res = &base.ServiceInfo{
+			WaitTime:    10 * 60 * 1000,
+			LastUseTime: util.GetNowMilli(),
+			Service:     s,
+			Stop:        s.Close,
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = serviceInfo.Service.(*Service)
+	serviceInfo.SetLastUseTime()
+	return
+}
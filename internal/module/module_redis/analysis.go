@@ -0,0 +1,497 @@
+package module_redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/team-ide/go-tool/redis"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+type AnalysisRequest struct {
+	BaseRequest
+	ScanCount   int64  `json:"scanCount"`   // 每次 SCAN 数量 默认 500
+	SleepMs     int64  `json:"sleepMs"`     // 每次 SCAN 后 休眠 毫秒 用于 限速 默认 10
+	MaxKeys     int64  `json:"maxKeys"`     // 最多 分析 Key 数量 0 不限制
+	TopN        int    `json:"topN"`        // 默认 100
+	Delimiter   string `json:"delimiter"`   // 前缀 分隔符 默认 :
+	PrefixDepth int    `json:"prefixDepth"` // 前缀 层级 默认 1
+	Samples     int    `json:"samples"`     // MEMORY USAGE 的 SAMPLES 默认 5
+}
+
+type AnalysisKey struct {
+	Key    string `json:"key"`
+	Type   string `json:"type"`
+	Memory int64  `json:"memory"`
+	TTL    int64  `json:"ttl"` // 秒 -1 表示 没有 过期时间
+	Node   string `json:"node,omitempty"`
+}
+
+type AnalysisGroup struct {
+	Name      string `json:"name"`
+	Count     int64  `json:"count"`
+	Memory    int64  `json:"memory"`
+	NoTTL     int64  `json:"noTTL"`
+	MaxKey    string `json:"maxKey"`
+	MaxMemory int64  `json:"maxMemory"`
+}
+
+type AnalysisTask struct {
+	TaskKey   string `json:"taskKey"`
+	WorkerId  string `json:"workerId"`
+	Database  int    `json:"database"`
+	Pattern   string `json:"pattern"`
+	StartTime int64  `json:"startTime"`
+	EndTime   int64  `json:"endTime"`
+	UseTime   int64  `json:"useTime"`
+	IsEnd     bool   `json:"isEnd"`
+	IsStop    bool   `json:"isStop"`
+	Error     string `json:"error,omitempty"`
+
+	NodeCount     int   `json:"nodeCount"`
+	NodeDoneCount int   `json:"nodeDoneCount"`
+	DbSize        int64 `json:"dbSize"`
+	ScanCount     int64 `json:"scanCount"`
+	ErrorCount    int64 `json:"errorCount"`
+	TotalMemory   int64 `json:"totalMemory"`
+	NoTTLCount    int64 `json:"noTTLCount"`
+	NoTTLMemory   int64 `json:"noTTLMemory"`
+
+	request  *AnalysisRequest
+	topKeys  []*AnalysisKey
+	noTTLs   []*AnalysisKey
+	prefixes map[string]*AnalysisGroup
+	types    map[string]*AnalysisGroup
+	lock     sync.Mutex
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+var analysisTaskCache = map[string]*AnalysisTask{}
+var analysisTaskCacheLock = &sync.Mutex{}
+
+func getAnalysisTask(taskKey string) *AnalysisTask {
+	analysisTaskCacheLock.Lock()
+	defer analysisTaskCacheLock.Unlock()
+	return analysisTaskCache[taskKey]
+}
+
+func removeAnalysisTask(taskKey string) {
+	analysisTaskCacheLock.Lock()
+	one := analysisTaskCache[taskKey]
+	delete(analysisTaskCache, taskKey)
+	analysisTaskCacheLock.Unlock()
+	if one != nil {
+		one.stop()
+	}
+}
+
+// removeWorkerAnalysisTasks 工具 关闭 时 停止 并 移除 分析 任务
+func removeWorkerAnalysisTasks(workerId string) {
+	var taskKeys []string
+	analysisTaskCacheLock.Lock()
+	for taskKey, one := range analysisTaskCache {
+		if one.WorkerId == workerId {
+			taskKeys = append(taskKeys, taskKey)
+		}
+	}
+	analysisTaskCacheLock.Unlock()
+	for _, taskKey := range taskKeys {
+		removeAnalysisTask(taskKey)
+	}
+}
+
+func (this_ *AnalysisTask) stop() {
+	this_.stopOnce.Do(func() {
+		this_.lock.Lock()
+		this_.IsStop = true
+		this_.lock.Unlock()
+		close(this_.stopped)
+	})
+}
+
+func (this_ *AnalysisTask) isStopped() bool {
+	select {
+	case <-this_.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+func (this_ *AnalysisTask) setError(err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	if this_.Error == "" {
+		this_.Error = err.Error()
+	}
+}
+
+// keyPrefix 根据 分隔符 和 层级 获取 前缀，没有 分隔符 的 Key 归为一组
+func (this_ *AnalysisTask) keyPrefix(key string) string {
+	ss := strings.Split(key, this_.request.Delimiter)
+	if len(ss) <= 1 {
+		return "(no prefix)"
+	}
+	depth := this_.request.PrefixDepth
+	if depth >= len(ss) {
+		depth = len(ss) - 1
+	}
+	return strings.Join(ss[:depth], this_.request.Delimiter) + this_.request.Delimiter + "*"
+}
+
+// pushTop 保留 内存 最大的 N 个
+func pushTop(list []*AnalysisKey, one *AnalysisKey, n int) []*AnalysisKey {
+	if len(list) >= n && list[len(list)-1].Memory >= one.Memory {
+		return list
+	}
+	index := sort.Search(len(list), func(i int) bool {
+		return list[i].Memory < one.Memory
+	})
+	list = append(list, nil)
+	copy(list[index+1:], list[index:])
+	list[index] = one
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+func addGroup(cache map[string]*AnalysisGroup, name string, one *AnalysisKey) {
+	group := cache[name]
+	if group == nil {
+		group = &AnalysisGroup{Name: name}
+		cache[name] = group
+	}
+	group.Count++
+	group.Memory += one.Memory
+	if one.TTL == -1 {
+		group.NoTTL++
+	}
+	if one.Memory > group.MaxMemory {
+		group.MaxMemory = one.Memory
+		group.MaxKey = one.Key
+	}
+}
+
+func (this_ *AnalysisTask) add(one *AnalysisKey) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.ScanCount++
+	this_.TotalMemory += one.Memory
+	this_.topKeys = pushTop(this_.topKeys, one, this_.request.TopN)
+	if one.TTL == -1 {
+		this_.NoTTLCount++
+		this_.NoTTLMemory += one.Memory
+		this_.noTTLs = pushTop(this_.noTTLs, one, this_.request.TopN)
+	}
+	addGroup(this_.prefixes, this_.keyPrefix(one.Key), one)
+	addGroup(this_.types, one.Type, one)
+}
+
+func (this_ *AnalysisTask) reachMax() bool {
+	if this_.request.MaxKeys <= 0 {
+		return false
+	}
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	return this_.ScanCount >= this_.request.MaxKeys
+}
+
+// scanNode 分析 单个 节点，每批 Key 使用 pipeline 查询 内存 TTL 和 类型
+func (this_ *AnalysisTask) scanNode(ctx context.Context, client goRedis.Cmdable, node string) (err error) {
+	var cursor uint64
+	for {
+		if this_.isStopped() || this_.reachMax() {
+			return
+		}
+		var keys []string
+		keys, cursor, err = client.Scan(ctx, cursor, this_.Pattern, this_.request.ScanCount).Result()
+		if err != nil {
+			return
+		}
+		if len(keys) > 0 {
+			pipe := client.Pipeline()
+			var memoryCmdList []*goRedis.IntCmd
+			var ttlCmdList []*goRedis.DurationCmd
+			var typeCmdList []*goRedis.StatusCmd
+			for _, key := range keys {
+				memoryCmdList = append(memoryCmdList, pipe.MemoryUsage(ctx, key, this_.request.Samples))
+				ttlCmdList = append(ttlCmdList, pipe.TTL(ctx, key))
+				typeCmdList = append(typeCmdList, pipe.Type(ctx, key))
+			}
+			_, _ = pipe.Exec(ctx)
+			for i, key := range keys {
+				memory, e := memoryCmdList[i].Result()
+				if e != nil {
+					// Key 可能 已经 过期 或 删除
+					this_.lock.Lock()
+					this_.ErrorCount++
+					this_.lock.Unlock()
+					continue
+				}
+				one := &AnalysisKey{
+					Key:    key,
+					Memory: memory,
+					Type:   typeCmdList[i].Val(),
+					Node:   node,
+				}
+				// 没有 过期时间 返回 -1 不存在 返回 -2
+				ttl := ttlCmdList[i].Val()
+				if ttl < 0 {
+					one.TTL = int64(ttl)
+				} else {
+					one.TTL = int64(ttl / time.Second)
+				}
+				this_.add(one)
+			}
+		}
+		if cursor == 0 {
+			return
+		}
+		if this_.request.SleepMs > 0 {
+			select {
+			case <-this_.stopped:
+				return
+			case <-time.After(time.Duration(this_.request.SleepMs) * time.Millisecond):
+			}
+		}
+	}
+}
+
+func (this_ *AnalysisTask) run(service redis.IService) {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("redis analysis error", zap.Any("error", e))
+			this_.setError(fmt.Errorf("%v", e))
+		}
+		this_.lock.Lock()
+		defer this_.lock.Unlock()
+		this_.EndTime = util.GetNowMilli()
+		this_.UseTime = this_.EndTime - this_.StartTime
+		this_.IsEnd = true
+	}()
+
+	ctx := context.Background()
+	client, err := service.GetClient(&redis.Param{Database: this_.Database})
+	if err != nil {
+		this_.setError(err)
+		return
+	}
+
+	var setNodes = func(nodeCount int, dbSize int64) {
+		this_.lock.Lock()
+		defer this_.lock.Unlock()
+		this_.NodeCount = nodeCount
+		this_.DbSize = dbSize
+	}
+	var nodeDone = func(e error) {
+		this_.lock.Lock()
+		defer this_.lock.Unlock()
+		this_.NodeDoneCount++
+		if e != nil && this_.Error == "" {
+			this_.Error = e.Error()
+		}
+	}
+	switch c := client.(type) {
+	case *goRedis.ClusterClient:
+		var nodes []*goRedis.Client
+		var nodesLock sync.Mutex
+		err = c.ForEachMaster(ctx, func(ctx context.Context, node *goRedis.Client) error {
+			nodesLock.Lock()
+			nodes = append(nodes, node)
+			nodesLock.Unlock()
+			return nil
+		})
+		if err != nil {
+			this_.setError(err)
+			return
+		}
+		var dbSize int64
+		for _, node := range nodes {
+			size, _ := node.DBSize(ctx).Result()
+			dbSize += size
+		}
+		setNodes(len(nodes), dbSize)
+		// 节点 依次 扫描，避免 同时 对 所有 节点 施压
+		for _, node := range nodes {
+			if this_.isStopped() {
+				return
+			}
+			nodeDone(this_.scanNode(ctx, node, node.Options().Addr))
+		}
+	case *goRedis.Client:
+		// 使用 单独 连接 保证 SELECT 的 库 和 SCAN 的 库 一致
+		conn := c.Conn(ctx)
+		defer func() { _ = conn.Close() }()
+		err = conn.Select(ctx, this_.Database).Err()
+		if err != nil {
+			this_.setError(err)
+			return
+		}
+		dbSize, _ := conn.DBSize(ctx).Result()
+		setNodes(1, dbSize)
+		nodeDone(this_.scanNode(ctx, conn, ""))
+	default:
+		dbSize, _ := client.DBSize(ctx).Result()
+		setNodes(1, dbSize)
+		nodeDone(this_.scanNode(ctx, client, ""))
+	}
+}
+
+func sortGroups(cache map[string]*AnalysisGroup, limit int) (list []*AnalysisGroup) {
+	for _, one := range cache {
+		list = append(list, one)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Memory > list[j].Memory
+	})
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return
+}
+
+// report 当前 分析 结果，task 为 复制 的 快照，避免 序列化 时 任务 仍在 修改
+func (this_ *AnalysisTask) report() map[string]interface{} {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	task := &AnalysisTask{
+		TaskKey:       this_.TaskKey,
+		WorkerId:      this_.WorkerId,
+		Database:      this_.Database,
+		Pattern:       this_.Pattern,
+		StartTime:     this_.StartTime,
+		EndTime:       this_.EndTime,
+		UseTime:       this_.UseTime,
+		IsEnd:         this_.IsEnd,
+		IsStop:        this_.IsStop,
+		Error:         this_.Error,
+		NodeCount:     this_.NodeCount,
+		NodeDoneCount: this_.NodeDoneCount,
+		DbSize:        this_.DbSize,
+		ScanCount:     this_.ScanCount,
+		ErrorCount:    this_.ErrorCount,
+		TotalMemory:   this_.TotalMemory,
+		NoTTLCount:    this_.NoTTLCount,
+		NoTTLMemory:   this_.NoTTLMemory,
+	}
+	if !task.IsEnd {
+		task.UseTime = util.GetNowMilli() - task.StartTime
+	}
+	data := map[string]interface{}{}
+	data["task"] = task
+	data["topKeys"] = append([]*AnalysisKey{}, this_.topKeys...)
+	data["noTTLKeys"] = append([]*AnalysisKey{}, this_.noTTLs...)
+	data["prefixCount"] = len(this_.prefixes)
+	data["prefixes"] = sortGroups(this_.prefixes, 500)
+	data["types"] = sortGroups(this_.types, 0)
+	return data
+}
+
+func (this_ *api) analysisStart(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &AnalysisRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.ScanCount <= 0 {
+		request.ScanCount = 500
+	}
+	if request.SleepMs < 0 {
+		err = errors.New("sleepMs must be greater than or equal to 0")
+		return
+	} else if request.SleepMs == 0 {
+		request.SleepMs = 10
+	}
+	if request.TopN <= 0 {
+		request.TopN = 100
+	}
+	if request.Delimiter == "" {
+		request.Delimiter = ":"
+	}
+	if request.PrefixDepth <= 0 {
+		request.PrefixDepth = 1
+	}
+	if request.Samples <= 0 {
+		request.Samples = 5
+	}
+	if request.Pattern == "" {
+		request.Pattern = "*"
+	}
+
+	task := &AnalysisTask{
+		TaskKey:   util.GetUUID(),
+		WorkerId:  request.WorkerId,
+		Database:  request.Database,
+		Pattern:   request.Pattern,
+		StartTime: util.GetNowMilli(),
+		request:   request,
+		prefixes:  map[string]*AnalysisGroup{},
+		types:     map[string]*AnalysisGroup{},
+		stopped:   make(chan struct{}),
+	}
+	analysisTaskCacheLock.Lock()
+	analysisTaskCache[task.TaskKey] = task
+	analysisTaskCacheLock.Unlock()
+
+	go task.run(service)
+
+	res = task.TaskKey
+	return
+}
+
+func (this_ *api) analysisStatus(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	task := getAnalysisTask(request.TaskKey)
+	if task == nil {
+		return
+	}
+	res = task.report()
+	return
+}
+
+func (this_ *api) analysisStop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	task := getAnalysisTask(request.TaskKey)
+	if task != nil {
+		task.stop()
+	}
+	return
+}
+
+func (this_ *api) analysisClean(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	removeAnalysisTask(request.TaskKey)
+	return
+}
@@ -0,0 +1,151 @@
+package module_redis
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func newTestAnalysisTask(request *AnalysisRequest) *AnalysisTask {
+	return &AnalysisTask{
+		TaskKey:  "test",
+		WorkerId: request.WorkerId,
+		request:  request,
+		prefixes: map[string]*AnalysisGroup{},
+		types:    map[string]*AnalysisGroup{},
+		stopped:  make(chan struct{}),
+	}
+}
+
+func TestKeyPrefix(t *testing.T) {
+	for _, one := range []struct {
+		delimiter string
+		depth     int
+		key       string
+		expect    string
+	}{
+		{":", 1, "user:1", "user:*"},
+		{":", 1, "user:1:name", "user:*"},
+		{":", 2, "user:1:name", "user:1:*"},
+		{":", 3, "user:1:name", "user:1:*"},
+		{":", 1, "user", "(no prefix)"},
+		{":", 1, ":1", ":*"},
+		{".", 1, "a.b", "a.*"},
+		{"::", 1, "a::b:c", "a::*"},
+	} {
+		task := newTestAnalysisTask(&AnalysisRequest{Delimiter: one.delimiter, PrefixDepth: one.depth})
+		if res := task.keyPrefix(one.key); res != one.expect {
+			t.Errorf("key %s delimiter %s depth %d expect %s, got %s", one.key, one.delimiter, one.depth, one.expect, res)
+		}
+	}
+}
+
+func TestPushTop(t *testing.T) {
+	var list []*AnalysisKey
+	for _, memory := range []int64{5, 1, 9, 3, 9, 7} {
+		list = pushTop(list, &AnalysisKey{Memory: memory}, 3)
+	}
+	var res []int64
+	for _, one := range list {
+		res = append(res, one.Memory)
+	}
+	if expect := []int64{9, 9, 7}; !reflect.DeepEqual(res, expect) {
+		t.Errorf("top expect %v, got %v", expect, res)
+	}
+}
+
+func TestAnalysisAdd(t *testing.T) {
+	task := newTestAnalysisTask(&AnalysisRequest{Delimiter: ":", PrefixDepth: 1, TopN: 2})
+	for _, one := range []*AnalysisKey{
+		{Key: "user:1", Type: "hash", Memory: 100, TTL: -1},
+		{Key: "user:2", Type: "hash", Memory: 300, TTL: 60},
+		{Key: "order:1", Type: "string", Memory: 50, TTL: -1},
+		{Key: "counter", Type: "string", Memory: 10, TTL: -1},
+	} {
+		task.add(one)
+	}
+	data := task.report()
+	snapshot := data["task"].(*AnalysisTask)
+	if snapshot == task || snapshot.ScanCount != 4 || snapshot.TotalMemory != 460 ||
+		snapshot.NoTTLCount != 3 || snapshot.NoTTLMemory != 160 {
+		t.Errorf("task count error, got %+v", snapshot)
+	}
+	topKeys := data["topKeys"].([]*AnalysisKey)
+	if len(topKeys) != 2 || topKeys[0].Key != "user:2" || topKeys[1].Key != "user:1" {
+		t.Errorf("top keys error, got %v", topKeys)
+	}
+	noTTLKeys := data["noTTLKeys"].([]*AnalysisKey)
+	if len(noTTLKeys) != 2 || noTTLKeys[0].Key != "user:1" || noTTLKeys[1].Key != "order:1" {
+		t.Errorf("no ttl keys error, got %v", noTTLKeys)
+	}
+	prefixes := data["prefixes"].([]*AnalysisGroup)
+	expect := []*AnalysisGroup{
+		{Name: "user:*", Count: 2, Memory: 400, NoTTL: 1, MaxKey: "user:2", MaxMemory: 300},
+		{Name: "order:*", Count: 1, Memory: 50, NoTTL: 1, MaxKey: "order:1", MaxMemory: 50},
+		{Name: "(no prefix)", Count: 1, Memory: 10, NoTTL: 1, MaxKey: "counter", MaxMemory: 10},
+	}
+	if data["prefixCount"] != 3 || !reflect.DeepEqual(prefixes, expect) {
+		bs, _ := json.Marshal(prefixes)
+		t.Errorf("prefixes error, got %s", bs)
+	}
+	types := data["types"].([]*AnalysisGroup)
+	if len(types) != 2 || types[0].Name != "hash" || types[0].Count != 2 || types[1].Name != "string" || types[1].Memory != 60 {
+		bs, _ := json.Marshal(types)
+		t.Errorf("types error, got %s", bs)
+	}
+}
+
+func TestAnalysisReachMax(t *testing.T) {
+	task := newTestAnalysisTask(&AnalysisRequest{Delimiter: ":", PrefixDepth: 1, TopN: 1, MaxKeys: 2})
+	task.add(&AnalysisKey{Key: "a"})
+	if task.reachMax() {
+		t.Errorf("1 key expect not reach max 2")
+	}
+	task.add(&AnalysisKey{Key: "b"})
+	if !task.reachMax() {
+		t.Errorf("2 keys expect reach max 2")
+	}
+}
+
+func TestRemoveWorkerAnalysisTasks(t *testing.T) {
+	tasks := []*AnalysisTask{
+		newTestAnalysisTask(&AnalysisRequest{BaseRequest: BaseRequest{WorkerId: "w1"}}),
+		newTestAnalysisTask(&AnalysisRequest{BaseRequest: BaseRequest{WorkerId: "w1"}}),
+		newTestAnalysisTask(&AnalysisRequest{BaseRequest: BaseRequest{WorkerId: "w2"}}),
+	}
+	analysisTaskCacheLock.Lock()
+	for i, one := range tasks {
+		one.TaskKey = "test-" + string(rune('a'+i))
+		analysisTaskCache[one.TaskKey] = one
+	}
+	analysisTaskCacheLock.Unlock()
+
+	// 停止 与 读取 结果 并发，-race 下 检查 数据 竞争
+	var wait sync.WaitGroup
+	wait.Add(1)
+	go func() {
+		defer wait.Done()
+		for i := 0; i < 100; i++ {
+			bs, _ := json.Marshal(tasks[0].report())
+			if len(bs) == 0 {
+				t.Errorf("report expect json")
+			}
+		}
+	}()
+	removeWorkerAnalysisTasks("w1")
+	wait.Wait()
+
+	if !tasks[0].isStopped() || !tasks[1].isStopped() || tasks[2].isStopped() {
+		t.Errorf("worker w1 tasks expect stopped only")
+	}
+	if getAnalysisTask(tasks[0].TaskKey) != nil || getAnalysisTask(tasks[2].TaskKey) == nil {
+		t.Errorf("worker w1 tasks expect removed only")
+	}
+	if !tasks[0].report()["task"].(*AnalysisTask).IsStop {
+		t.Errorf("stopped task report expect isStop")
+	}
+	removeAnalysisTask(tasks[2].TaskKey)
+	// 重复 停止 不 panic
+	tasks[2].stop()
+}
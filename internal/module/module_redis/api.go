@@ -37,11 +37,33 @@ var (
 	lremPower          = base.AppendPower(&base.PowerAction{Action: "lrem", Text: "Redis LRem", ShouldLogin: true, StandAlone: true, Parent: Power})
 	hsetPower          = base.AppendPower(&base.PowerAction{Action: "hset", Text: "Redis HSet", ShouldLogin: true, StandAlone: true, Parent: Power})
 	hdelPower          = base.AppendPower(&base.PowerAction{Action: "hdel", Text: "Redis HDel", ShouldLogin: true, StandAlone: true, Parent: Power})
+	zaddPower          = base.AppendPower(&base.PowerAction{Action: "zadd", Text: "Redis ZAdd", ShouldLogin: true, StandAlone: true, Parent: Power})
+	zincrbyPower       = base.AppendPower(&base.PowerAction{Action: "zincrby", Text: "Redis ZIncrBy", ShouldLogin: true, StandAlone: true, Parent: Power})
+	zremPower          = base.AppendPower(&base.PowerAction{Action: "zrem", Text: "Redis ZRem", ShouldLogin: true, StandAlone: true, Parent: Power})
+	zrangePower        = base.AppendPower(&base.PowerAction{Action: "zrange", Text: "Redis ZRange分页查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xaddPower          = base.AppendPower(&base.PowerAction{Action: "xadd", Text: "Redis XAdd", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xrangePower        = base.AppendPower(&base.PowerAction{Action: "xrange", Text: "Redis XRange", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xrevrangePower     = base.AppendPower(&base.PowerAction{Action: "xrevrange", Text: "Redis XRevRange", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xdelPower          = base.AppendPower(&base.PowerAction{Action: "xdel", Text: "Redis XDel", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xtrimPower         = base.AppendPower(&base.PowerAction{Action: "xtrim", Text: "Redis XTrim", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xinfoGroupsPower   = base.AppendPower(&base.PowerAction{Action: "xinfoGroups", Text: "Redis XInfo Groups", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xpendingPower      = base.AppendPower(&base.PowerAction{Action: "xpending", Text: "Redis XPending", ShouldLogin: true, StandAlone: true, Parent: Power})
+	xclaimPower        = base.AppendPower(&base.PowerAction{Action: "xclaim", Text: "Redis XClaim", ShouldLogin: true, StandAlone: true, Parent: Power})
+	pfaddPower         = base.AppendPower(&base.PowerAction{Action: "pfadd", Text: "Redis PFAdd", ShouldLogin: true, StandAlone: true, Parent: Power})
+	pfcountPower       = base.AppendPower(&base.PowerAction{Action: "pfcount", Text: "Redis PFCount", ShouldLogin: true, StandAlone: true, Parent: Power})
 	deletePower        = base.AppendPower(&base.PowerAction{Action: "delete", Text: "Redis删除Key", ShouldLogin: true, StandAlone: true, Parent: Power})
 	deletePatternPower = base.AppendPower(&base.PowerAction{Action: "deletePattern", Text: "Redis删除匹配Key", ShouldLogin: true, StandAlone: true, Parent: Power})
 	expirePower        = base.AppendPower(&base.PowerAction{Action: "expire", Text: "Redis设置过期", ShouldLogin: true, StandAlone: true, Parent: Power})
 	ttlPower           = base.AppendPower(&base.PowerAction{Action: "ttl", Text: "Redis过期时间查询", ShouldLogin: true, StandAlone: true, Parent: Power})
 	persistPower       = base.AppendPower(&base.PowerAction{Action: "persist", Text: "Redis移除过期时间", ShouldLogin: true, StandAlone: true, Parent: Power})
+	analysisStart      = base.AppendPower(&base.PowerAction{Action: "analysisStart", Text: "Redis内存分析开始", ShouldLogin: true, StandAlone: true, Parent: Power})
+	analysisStatus     = base.AppendPower(&base.PowerAction{Action: "analysisStatus", Text: "Redis内存分析状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+	analysisStop       = base.AppendPower(&base.PowerAction{Action: "analysisStop", Text: "Redis内存分析停止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	analysisClean      = base.AppendPower(&base.PowerAction{Action: "analysisClean", Text: "Redis内存分析清理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	subscribeKey       = base.AppendPower(&base.PowerAction{Action: "subscribe/key", Text: "Redis订阅Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	subscribeWebsocket = base.AppendPower(&base.PowerAction{Action: "subscribe/websocket", Text: "Redis订阅WebSocket", ShouldLogin: true, StandAlone: true, Parent: Power})
+	subscribeClose     = base.AppendPower(&base.PowerAction{Action: "subscribe/close", Text: "Redis订阅关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+	publishPower       = base.AppendPower(&base.PowerAction{Action: "publish", Text: "Redis Publish", ShouldLogin: true, StandAlone: true, Parent: Power})
 	closePower         = base.AppendPower(&base.PowerAction{Action: "close", Text: "Redis关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
@@ -59,11 +81,33 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: lremPower, Do: this_.lrem})
 	apis = append(apis, &base.ApiWorker{Power: hsetPower, Do: this_.hset})
 	apis = append(apis, &base.ApiWorker{Power: hdelPower, Do: this_.hdel})
+	apis = append(apis, &base.ApiWorker{Power: zaddPower, Do: this_.zadd})
+	apis = append(apis, &base.ApiWorker{Power: zincrbyPower, Do: this_.zincrby})
+	apis = append(apis, &base.ApiWorker{Power: zremPower, Do: this_.zrem})
+	apis = append(apis, &base.ApiWorker{Power: zrangePower, Do: this_.zrange})
+	apis = append(apis, &base.ApiWorker{Power: xaddPower, Do: this_.xadd})
+	apis = append(apis, &base.ApiWorker{Power: xrangePower, Do: this_.xrange})
+	apis = append(apis, &base.ApiWorker{Power: xrevrangePower, Do: this_.xrevrange})
+	apis = append(apis, &base.ApiWorker{Power: xdelPower, Do: this_.xdel})
+	apis = append(apis, &base.ApiWorker{Power: xtrimPower, Do: this_.xtrim})
+	apis = append(apis, &base.ApiWorker{Power: xinfoGroupsPower, Do: this_.xinfoGroups})
+	apis = append(apis, &base.ApiWorker{Power: xpendingPower, Do: this_.xpending})
+	apis = append(apis, &base.ApiWorker{Power: xclaimPower, Do: this_.xclaim})
+	apis = append(apis, &base.ApiWorker{Power: pfaddPower, Do: this_.pfadd})
+	apis = append(apis, &base.ApiWorker{Power: pfcountPower, Do: this_.pfcount})
 	apis = append(apis, &base.ApiWorker{Power: deletePower, Do: this_.delete})
 	apis = append(apis, &base.ApiWorker{Power: deletePatternPower, Do: this_.deletePattern})
 	apis = append(apis, &base.ApiWorker{Power: expirePower, Do: this_.expire})
 	apis = append(apis, &base.ApiWorker{Power: ttlPower, Do: this_.ttl})
 	apis = append(apis, &base.ApiWorker{Power: persistPower, Do: this_.persist})
+	apis = append(apis, &base.ApiWorker{Power: analysisStart, Do: this_.analysisStart})
+	apis = append(apis, &base.ApiWorker{Power: analysisStatus, Do: this_.analysisStatus, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: analysisStop, Do: this_.analysisStop})
+	apis = append(apis, &base.ApiWorker{Power: analysisClean, Do: this_.analysisClean})
+	apis = append(apis, &base.ApiWorker{Power: subscribeKey, Do: this_.subscribeKey})
+	apis = append(apis, &base.ApiWorker{Power: subscribeWebsocket, Do: subscriberCache.Websocket, IsWebSocket: true})
+	apis = append(apis, &base.ApiWorker{Power: subscribeClose, Do: subscriberCache.Close})
+	apis = append(apis, &base.ApiWorker{Power: publishPower, Do: this_.publish})
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
 
 	return
@@ -159,6 +203,7 @@ type BaseRequest struct {
 	Field      string `json:"field"`
 	TaskKey    string `json:"taskKey,omitempty"`
 	Expire     int64  `json:"expire"`
+	WorkerId   string `json:"workerId"`
 }
 
 func (this_ *BaseRequest) getKey() string {
@@ -612,5 +657,11 @@ func (this_ *api) importClean(requestBean *base.RequestBean, c *gin.Context) (re
 
 func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	subscriberCache.RemoveWorker(request.WorkerId)
+	removeWorkerAnalysisTasks(request.WorkerId)
 	return
 }
@@ -0,0 +1,69 @@
+package module_redis
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/redis"
+	"teamide/pkg/base"
+)
+
+type HyperLogLogRequest struct {
+	BaseRequest
+	Values []string `json:"values"`
+}
+
+func (this_ *api) pfadd(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &HyperLogLogRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	var values []interface{}
+	if request.Value != "" {
+		values = append(values, request.Value)
+	}
+	for _, one := range request.Values {
+		values = append(values, one)
+	}
+	if len(values) == 0 {
+		err = errors.New("values is empty")
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	res, err = client.PFAdd(context.Background(), request.getKey(), values...).Result()
+	return
+}
+
+func (this_ *api) pfcount(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &HyperLogLogRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	res, err = client.PFCount(context.Background(), request.getKey()).Result()
+	return
+}
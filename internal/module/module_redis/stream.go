@@ -0,0 +1,406 @@
+package module_redis
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/team-ide/go-tool/redis"
+	"sort"
+	"teamide/pkg/base"
+	"time"
+)
+
+type StreamField struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+type StreamRequest struct {
+	BaseRequest
+	Id       string         `json:"id"`     // XADD 的 ID 默认 *
+	Ids      []string       `json:"ids"`    // XDEL XCLAIM 的 ID 列表
+	Fields   []*StreamField `json:"fields"` // XADD 的 字段
+	Start    string         `json:"start"`  // 范围 开始 ID 支持 ( 开区间
+	End      string         `json:"end"`    // 范围 结束 ID 支持 ( 开区间
+	MaxLen   *int64         `json:"maxLen"` // XADD XTRIM 的 MAXLEN，XTRIM 时 与 minId 必须 指定 一个，0 会 删除 所有 消息
+	MinId    string         `json:"minId"`
+	Approx   bool           `json:"approx"` // 使用 ~ 近似 裁剪
+	Group    string         `json:"group"`
+	Consumer string         `json:"consumer"`
+	MinIdle  int64          `json:"minIdle"` // 毫秒
+}
+
+type StreamMessage struct {
+	Id     string         `json:"id"`
+	Fields []*StreamField `json:"fields"`
+}
+
+func toStreamMessages(list []goRedis.XMessage) (messages []*StreamMessage) {
+	messages = []*StreamMessage{}
+	for _, one := range list {
+		message := &StreamMessage{
+			Id: one.ID,
+		}
+		for field, value := range one.Values {
+			message.Fields = append(message.Fields, &StreamField{Field: field, Value: redisString(value)})
+		}
+		// Values 为 map 顺序 不固定，按 字段 排序
+		sort.Slice(message.Fields, func(i, j int) bool {
+			return message.Fields[i].Field < message.Fields[j].Field
+		})
+		messages = append(messages, message)
+	}
+	return
+}
+
+func (this_ *StreamRequest) getMaxLen() int64 {
+	if this_.MaxLen == nil {
+		return 0
+	}
+	return *this_.MaxLen
+}
+
+// rangeBounds 范围 默认 为 所有，倒序 时 开始 为 + 结束 为 -
+func (this_ *StreamRequest) rangeBounds(reverse bool) (start string, end string) {
+	start, end = this_.Start, this_.End
+	if start == "" {
+		start = "-"
+		if reverse {
+			start = "+"
+		}
+	}
+	if end == "" {
+		end = "+"
+		if reverse {
+			end = "-"
+		}
+	}
+	return
+}
+
+// checkTrim XTRIM 必须 指定 minId 或 maxLen，避免 未 指定 时 按 MAXLEN 0 清空 Stream
+func (this_ *StreamRequest) checkTrim() (err error) {
+	if this_.MinId != "" {
+		return
+	}
+	if this_.MaxLen == nil {
+		err = errors.New("minId or maxLen is required")
+		return
+	}
+	if *this_.MaxLen < 0 {
+		err = errors.New("maxLen must be greater than or equal to 0")
+		return
+	}
+	return
+}
+
+func (this_ *api) xadd(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if len(request.Fields) == 0 {
+		err = errors.New("fields is empty")
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	var values []interface{}
+	for _, one := range request.Fields {
+		values = append(values, one.Field, one.Value)
+	}
+	res, err = client.XAdd(context.Background(), &goRedis.XAddArgs{
+		Stream: request.getKey(),
+		ID:     request.Id,
+		MaxLen: request.getMaxLen(),
+		MinID:  request.MinId,
+		Approx: request.Approx,
+		Values: values,
+	}).Result()
+	return
+}
+
+func (this_ *api) xrange(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.doXRange(requestBean, c, false)
+}
+
+func (this_ *api) xrevrange(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.doXRange(requestBean, c, true)
+}
+
+// doXRange 分页 查询 使用 上一页 最后一个 ID 加 ( 作为 下一页 的 开始
+func (this_ *api) doXRange(requestBean *base.RequestBean, c *gin.Context, reverse bool) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	if request.Count <= 0 {
+		request.Count = 50
+	}
+	ctx := context.Background()
+	key := request.getKey()
+	data := map[string]interface{}{}
+	data["total"], err = client.XLen(ctx, key).Result()
+	if err != nil {
+		return
+	}
+	var list []goRedis.XMessage
+	start, end := request.rangeBounds(reverse)
+	if reverse {
+		list, err = client.XRevRangeN(ctx, key, start, end, request.Count).Result()
+	} else {
+		list, err = client.XRangeN(ctx, key, start, end, request.Count).Result()
+	}
+	if err != nil {
+		return
+	}
+	data["list"] = toStreamMessages(list)
+	res = data
+	return
+}
+
+func (this_ *api) xdel(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Id != "" {
+		request.Ids = append(request.Ids, request.Id)
+	}
+	if len(request.Ids) == 0 {
+		err = errors.New("ids is empty")
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	res, err = client.XDel(context.Background(), request.getKey(), request.Ids...).Result()
+	return
+}
+
+func (this_ *api) xtrim(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if err = request.checkTrim(); err != nil {
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	key := request.getKey()
+	if request.MinId != "" {
+		if request.Approx {
+			res, err = client.XTrimMinIDApprox(ctx, key, request.MinId, 0).Result()
+		} else {
+			res, err = client.XTrimMinID(ctx, key, request.MinId).Result()
+		}
+		return
+	}
+	if request.Approx {
+		res, err = client.XTrimMaxLenApprox(ctx, key, request.getMaxLen(), 0).Result()
+	} else {
+		res, err = client.XTrimMaxLen(ctx, key, request.getMaxLen()).Result()
+	}
+	return
+}
+
+// xinfoGroups 查询 消费组 及 组内 消费者
+func (this_ *api) xinfoGroups(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	key := request.getKey()
+	groups, err := client.XInfoGroups(ctx, key).Result()
+	if err != nil {
+		return
+	}
+	var list []map[string]interface{}
+	for _, group := range groups {
+		one := map[string]interface{}{}
+		one["name"] = group.Name
+		one["consumers"] = group.Consumers
+		one["pending"] = group.Pending
+		one["lastDeliveredId"] = group.LastDeliveredID
+		consumers, e := client.XInfoConsumers(ctx, key, group.Name).Result()
+		if e == nil {
+			var consumerList []map[string]interface{}
+			for _, consumer := range consumers {
+				consumerList = append(consumerList, map[string]interface{}{
+					"name":    consumer.Name,
+					"pending": consumer.Pending,
+					"idle":    consumer.Idle,
+				})
+			}
+			one["consumerList"] = consumerList
+		}
+		list = append(list, one)
+	}
+	res = list
+	return
+}
+
+func (this_ *api) xpending(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Group == "" {
+		err = errors.New("group is empty")
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	key := request.getKey()
+	data := map[string]interface{}{}
+	summary, err := client.XPending(ctx, key, request.Group).Result()
+	if err != nil {
+		return
+	}
+	data["count"] = summary.Count
+	data["lower"] = summary.Lower
+	data["higher"] = summary.Higher
+	data["consumers"] = summary.Consumers
+	if request.Count <= 0 {
+		request.Count = 50
+	}
+	start, end := request.rangeBounds(false)
+	list, err := client.XPendingExt(ctx, &goRedis.XPendingExtArgs{
+		Stream:   key,
+		Group:    request.Group,
+		Idle:     time.Duration(request.MinIdle) * time.Millisecond,
+		Start:    start,
+		End:      end,
+		Count:    request.Count,
+		Consumer: request.Consumer,
+	}).Result()
+	if err != nil {
+		return
+	}
+	var pending []map[string]interface{}
+	for _, one := range list {
+		pending = append(pending, map[string]interface{}{
+			"id":         one.ID,
+			"consumer":   one.Consumer,
+			"idle":       one.Idle.Milliseconds(),
+			"retryCount": one.RetryCount,
+		})
+	}
+	data["list"] = pending
+	res = data
+	return
+}
+
+func (this_ *api) xclaim(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &StreamRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Group == "" || request.Consumer == "" {
+		err = errors.New("group or consumer is empty")
+		return
+	}
+	if len(request.Ids) == 0 {
+		err = errors.New("ids is empty")
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	list, err := client.XClaim(context.Background(), &goRedis.XClaimArgs{
+		Stream:   request.getKey(),
+		Group:    request.Group,
+		Consumer: request.Consumer,
+		MinIdle:  time.Duration(request.MinIdle) * time.Millisecond,
+		Messages: request.Ids,
+	}).Result()
+	if err != nil {
+		return
+	}
+	res = toStreamMessages(list)
+	return
+}
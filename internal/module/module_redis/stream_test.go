@@ -0,0 +1,70 @@
+package module_redis
+
+import (
+	goRedis "github.com/go-redis/redis/v8"
+	"reflect"
+	"testing"
+)
+
+func TestStreamCheckTrim(t *testing.T) {
+	var zero, five, negative int64 = 0, 5, -1
+	for _, one := range []struct {
+		request *StreamRequest
+		valid   bool
+		maxLen  int64
+	}{
+		{&StreamRequest{}, false, 0},
+		{&StreamRequest{Approx: true}, false, 0},
+		{&StreamRequest{MinId: "1-0"}, true, 0},
+		{&StreamRequest{MinId: "1-0", MaxLen: &negative}, true, -1},
+		{&StreamRequest{MaxLen: &zero}, true, 0},
+		{&StreamRequest{MaxLen: &five}, true, 5},
+		{&StreamRequest{MaxLen: &negative}, false, -1},
+	} {
+		err := one.request.checkTrim()
+		if (err == nil) != one.valid {
+			t.Errorf("trim %+v expect valid %v, got %v", one.request, one.valid, err)
+		}
+		if res := one.request.getMaxLen(); res != one.maxLen {
+			t.Errorf("trim %+v expect maxLen %d, got %d", one.request, one.maxLen, res)
+		}
+	}
+}
+
+func TestStreamRangeBounds(t *testing.T) {
+	for _, one := range []struct {
+		start   string
+		end     string
+		reverse bool
+		expect  [2]string
+	}{
+		{"", "", false, [2]string{"-", "+"}},
+		{"", "", true, [2]string{"+", "-"}},
+		{"(1-0", "", false, [2]string{"(1-0", "+"}},
+		{"(2-0", "", true, [2]string{"(2-0", "-"}},
+		{"", "3-0", false, [2]string{"-", "3-0"}},
+		{"1-0", "3-0", true, [2]string{"1-0", "3-0"}},
+	} {
+		start, end := (&StreamRequest{Start: one.start, End: one.end}).rangeBounds(one.reverse)
+		if [2]string{start, end} != one.expect {
+			t.Errorf("range %s %s reverse %v expect %v, got %s %s", one.start, one.end, one.reverse, one.expect, start, end)
+		}
+	}
+}
+
+func TestToStreamMessages(t *testing.T) {
+	res := toStreamMessages([]goRedis.XMessage{
+		{ID: "1-0", Values: map[string]interface{}{"b": "2", "a": []byte("1"), "c": 3}},
+		{ID: "2-0", Values: map[string]interface{}{}},
+	})
+	expect := []*StreamMessage{
+		{Id: "1-0", Fields: []*StreamField{{Field: "a", Value: "1"}, {Field: "b", Value: "2"}, {Field: "c", Value: ""}}},
+		{Id: "2-0"},
+	}
+	if !reflect.DeepEqual(res, expect) {
+		t.Errorf("messages error, got %v", res)
+	}
+	if res = toStreamMessages(nil); res == nil || len(res) != 0 {
+		t.Errorf("empty messages expect empty list, got %v", res)
+	}
+}
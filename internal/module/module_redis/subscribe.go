@@ -0,0 +1,392 @@
+package module_redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/team-ide/go-tool/redis"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"regexp"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"time"
+)
+
+type SubscribeRequest struct {
+	BaseRequest
+	Channels       []string `json:"channels"`       // SUBSCRIBE 的 频道
+	Patterns       []string `json:"patterns"`       // PSUBSCRIBE 的 频道 模式
+	Keyspace       bool     `json:"keyspace"`       // 订阅 键空间 通知
+	KeyspaceEvents string   `json:"keyspaceEvents"` // 设置 notify-keyspace-events 为空 则 不修改 服务端 配置，会话 结束 后 恢复
+	KeyPattern     string   `json:"keyPattern"`     // 键空间 通知 的 Key 模式 默认 *
+	Filter         string   `json:"filter"`         // 过滤 频道 或 消息 内容
+	FilterRegex    bool     `json:"filterRegex"`    // 过滤 使用 正则
+	RateLimit      int      `json:"rateLimit"`      // 每秒 最多 推送 消息数 默认 100
+	BufferSize     int      `json:"bufferSize"`     // 服务端 缓冲 消息数 超出 丢弃 默认 1000 最大 10000
+	Channel        string   `json:"channel"`        // PUBLISH 的 频道
+	Message        string   `json:"message"`        // PUBLISH 的 消息
+}
+
+type SubscribeMessage struct {
+	Type    string `json:"type"` // message stats error
+	Channel string `json:"channel,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Payload string `json:"payload,omitempty"`
+	Time    int64  `json:"time"`
+
+	Received int64  `json:"received,omitempty"`
+	Sent     int64  `json:"sent,omitempty"`
+	Filtered int64  `json:"filtered,omitempty"`
+	Dropped  int64  `json:"dropped,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type subscriber struct {
+	*base.WebsocketSession
+	request *SubscribeRequest
+	client  goRedis.Cmdable
+	filter  *regexp.Regexp
+	pubSubs []*goRedis.PubSub
+	buffer  chan *SubscribeMessage
+	// 恢复 notify-keyspace-events 配置
+	restores []func()
+
+	received int64
+	sent     int64
+	filtered int64
+	dropped  int64
+	lock     sync.Mutex
+}
+
+var subscriberCache = base.NewWebsocketCache("redis subscribe")
+
+// keyspaceConfig 修改 节点 notify-keyspace-events 的 会话 数，最后 一个 会话 结束 时 恢复 修改 前 的 配置
+type keyspaceConfig struct {
+	prev  string
+	count int
+}
+
+var keyspaceConfigCache = map[string]*keyspaceConfig{}
+var keyspaceConfigCacheLock = &sync.Mutex{}
+
+type configClient interface {
+	ConfigGet(ctx context.Context, parameter string) *goRedis.SliceCmd
+	ConfigSet(ctx context.Context, parameter, value string) *goRedis.StatusCmd
+}
+
+// setKeyspaceEvents 设置 节点 的 notify-keyspace-events，返回 会话 结束 时 调用 的 恢复 函数
+func setKeyspaceEvents(ctx context.Context, address string, node configClient, events string) (restore func(), err error) {
+	keyspaceConfigCacheLock.Lock()
+	defer keyspaceConfigCacheLock.Unlock()
+
+	one := keyspaceConfigCache[address]
+	if one == nil {
+		var res []interface{}
+		res, err = node.ConfigGet(ctx, "notify-keyspace-events").Result()
+		if err != nil {
+			return
+		}
+		one = &keyspaceConfig{}
+		if len(res) == 2 {
+			one.prev = util.GetStringValue(res[1])
+		}
+	}
+	if err = node.ConfigSet(ctx, "notify-keyspace-events", events).Err(); err != nil {
+		return
+	}
+	one.count++
+	keyspaceConfigCache[address] = one
+	restore = func() {
+		keyspaceConfigCacheLock.Lock()
+		defer keyspaceConfigCacheLock.Unlock()
+		one.count--
+		if one.count > 0 {
+			return
+		}
+		delete(keyspaceConfigCache, address)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if e := node.ConfigSet(ctx, "notify-keyspace-events", one.prev).Err(); e != nil {
+			util.Logger.Warn("redis restore notify-keyspace-events error", zap.Any("address", address), zap.Error(e))
+		}
+	}
+	return
+}
+
+func (this_ *subscriber) setKeyspaceEvents(ctx context.Context, node *goRedis.Client) (err error) {
+	restore, err := setKeyspaceEvents(ctx, node.Options().Addr, node, this_.request.KeyspaceEvents)
+	if err != nil {
+		return
+	}
+	this_.lock.Lock()
+	this_.restores = append(this_.restores, restore)
+	this_.lock.Unlock()
+	return
+}
+
+type pubSubClient interface {
+	Subscribe(ctx context.Context, channels ...string) *goRedis.PubSub
+	PSubscribe(ctx context.Context, channels ...string) *goRedis.PubSub
+}
+
+// subscribe 创建 订阅，集群 的 键空间 通知 只在 各自 节点 发布，需要 每个 主节点 单独 订阅
+func (this_ *subscriber) subscribe(ctx context.Context) (err error) {
+	request := this_.request
+	// 只 订阅 键空间 频道，同时 订阅 键事件 频道 会 收到 两次 相同 的 通知
+	var keyspacePatterns []string
+	if request.Keyspace {
+		keyPattern := request.KeyPattern
+		if keyPattern == "" {
+			keyPattern = "*"
+		}
+		keyspacePatterns = append(keyspacePatterns, fmt.Sprintf("__keyspace@%d__:%s", request.Database, keyPattern))
+	}
+
+	var nodes []pubSubClient
+	switch c := this_.client.(type) {
+	case *goRedis.ClusterClient:
+		if request.KeyspaceEvents != "" {
+			err = c.ForEachMaster(ctx, this_.setKeyspaceEvents)
+			if err != nil {
+				return
+			}
+		}
+		if len(keyspacePatterns) > 0 {
+			err = c.ForEachMaster(ctx, func(ctx context.Context, node *goRedis.Client) error {
+				this_.lock.Lock()
+				nodes = append(nodes, node)
+				this_.lock.Unlock()
+				return nil
+			})
+			if err != nil {
+				return
+			}
+		}
+		if len(request.Channels) > 0 {
+			this_.pubSubs = append(this_.pubSubs, c.Subscribe(ctx, request.Channels...))
+		}
+		if len(request.Patterns) > 0 {
+			this_.pubSubs = append(this_.pubSubs, c.PSubscribe(ctx, request.Patterns...))
+		}
+	case *goRedis.Client:
+		if request.KeyspaceEvents != "" {
+			err = this_.setKeyspaceEvents(ctx, c)
+			if err != nil {
+				return
+			}
+		}
+		if len(keyspacePatterns) > 0 {
+			nodes = append(nodes, c)
+		}
+		if len(request.Channels) > 0 {
+			this_.pubSubs = append(this_.pubSubs, c.Subscribe(ctx, request.Channels...))
+		}
+		if len(request.Patterns) > 0 {
+			this_.pubSubs = append(this_.pubSubs, c.PSubscribe(ctx, request.Patterns...))
+		}
+	default:
+		err = errors.New("redis client not support subscribe")
+		return
+	}
+	for _, node := range nodes {
+		this_.pubSubs = append(this_.pubSubs, node.PSubscribe(ctx, keyspacePatterns...))
+	}
+	if len(this_.pubSubs) == 0 {
+		err = errors.New("channels, patterns and keyspace are all empty")
+		return
+	}
+	for _, pubSub := range this_.pubSubs {
+		// 等待 订阅 确认，订阅 失败 直接 返回 错误
+		if _, err = pubSub.Receive(ctx); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (this_ *subscriber) match(msg *goRedis.Message) bool {
+	if this_.request.Filter == "" {
+		return true
+	}
+	if this_.filter != nil {
+		return this_.filter.MatchString(msg.Channel) || this_.filter.MatchString(msg.Payload)
+	}
+	return strings.Contains(msg.Channel, this_.request.Filter) || strings.Contains(msg.Payload, this_.request.Filter)
+}
+
+// receive 读取 订阅 消息 放入 缓冲，缓冲 已满 时 丢弃
+func (this_ *subscriber) receive(pubSub *goRedis.PubSub) {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("redis subscribe receive error", zap.Any("error", e))
+		}
+	}()
+	for msg := range pubSub.Channel() {
+		this_.lock.Lock()
+		this_.received++
+		this_.lock.Unlock()
+		if !this_.match(msg) {
+			this_.lock.Lock()
+			this_.filtered++
+			this_.lock.Unlock()
+			continue
+		}
+		one := &SubscribeMessage{
+			Type:    "message",
+			Channel: msg.Channel,
+			Pattern: msg.Pattern,
+			Payload: msg.Payload,
+			Time:    util.GetNowMilli(),
+		}
+		select {
+		case this_.buffer <- one:
+		default:
+			this_.lock.Lock()
+			this_.dropped++
+			this_.lock.Unlock()
+		}
+	}
+}
+
+func (this_ *subscriber) stats() *SubscribeMessage {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	return &SubscribeMessage{
+		Type:     "stats",
+		Time:     util.GetNowMilli(),
+		Received: this_.received,
+		Sent:     this_.sent,
+		Filtered: this_.filtered,
+		Dropped:  this_.dropped,
+	}
+}
+
+// send 按 速率 限制 推送 消息，并 每秒 推送 统计
+func (this_ *subscriber) send() {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("redis subscribe send error", zap.Any("error", e))
+		}
+		this_.Stop()
+	}()
+	interval := base.RateLimitInterval(this_.request.RateLimit)
+	limiter := time.NewTicker(interval)
+	defer limiter.Stop()
+	statsTicker := time.NewTicker(time.Second)
+	defer statsTicker.Stop()
+	for {
+		select {
+		case <-this_.Done():
+			return
+		case <-statsTicker.C:
+			if this_.WriteJSON(this_.stats()) != nil {
+				return
+			}
+		case msg := <-this_.buffer:
+			if this_.WriteJSON(msg) != nil {
+				return
+			}
+			this_.lock.Lock()
+			this_.sent++
+			this_.lock.Unlock()
+			select {
+			case <-this_.Done():
+				return
+			case <-limiter.C:
+			}
+		}
+	}
+}
+
+func (this_ *subscriber) Start() (err error) {
+	err = this_.subscribe(this_.Context())
+	if err != nil {
+		return
+	}
+	for _, pubSub := range this_.pubSubs {
+		go this_.receive(pubSub)
+	}
+	go this_.send()
+	return
+}
+
+func (this_ *subscriber) OnStop() {
+	for _, pubSub := range this_.pubSubs {
+		_ = pubSub.Close()
+	}
+	this_.lock.Lock()
+	restores := this_.restores
+	this_.restores = nil
+	this_.lock.Unlock()
+	for _, restore := range restores {
+		restore()
+	}
+}
+
+// subscribeKey 创建 订阅 会话，返回 key 用于 建立 websocket
+func (this_ *api) subscribeKey(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &SubscribeRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.RateLimit <= 0 {
+		request.RateLimit = 100
+	}
+	request.BufferSize = base.BufferSize(request.BufferSize)
+	one := &subscriber{
+		WebsocketSession: base.NewWebsocketSession(requestBean, request.WorkerId),
+		request:          request,
+		buffer:           make(chan *SubscribeMessage, request.BufferSize),
+	}
+	if request.Filter != "" && request.FilterRegex {
+		one.filter, err = regexp.Compile(request.Filter)
+		if err != nil {
+			return
+		}
+	}
+	one.client, err = service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	res = subscriberCache.Add(one)
+	return
+}
+
+func (this_ *api) publish(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &SubscribeRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Channel == "" {
+		err = errors.New("channel is empty")
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	res, err = client.Publish(context.Background(), request.Channel, request.Message).Result()
+	return
+}
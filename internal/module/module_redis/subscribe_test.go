@@ -0,0 +1,74 @@
+package module_redis
+
+import (
+	"context"
+	"errors"
+	goRedis "github.com/go-redis/redis/v8"
+	"testing"
+)
+
+type testConfigClient struct {
+	value    string
+	getCount int
+	setErr   error
+}
+
+func (this_ *testConfigClient) ConfigGet(ctx context.Context, parameter string) *goRedis.SliceCmd {
+	this_.getCount++
+	cmd := goRedis.NewSliceCmd(ctx)
+	cmd.SetVal([]interface{}{parameter, this_.value})
+	return cmd
+}
+
+func (this_ *testConfigClient) ConfigSet(ctx context.Context, _ string, value string) *goRedis.StatusCmd {
+	cmd := goRedis.NewStatusCmd(ctx)
+	if this_.setErr != nil {
+		cmd.SetErr(this_.setErr)
+		return cmd
+	}
+	this_.value = value
+	cmd.SetVal("OK")
+	return cmd
+}
+
+func TestSetKeyspaceEvents(t *testing.T) {
+	ctx := context.Background()
+	node := &testConfigClient{value: "Ex"}
+
+	restore1, err := setKeyspaceEvents(ctx, "127.0.0.1:6379", node, "KEA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore2, err := setKeyspaceEvents(ctx, "127.0.0.1:6379", node, "Kg$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.value != "Kg$" || node.getCount != 1 {
+		t.Errorf("second session expect set without reading config again, got %s %d", node.value, node.getCount)
+	}
+	// 其它 会话 仍在 使用 时 不 恢复
+	restore1()
+	if node.value != "Kg$" {
+		t.Errorf("config expect kept while other session running, got %s", node.value)
+	}
+	restore2()
+	if node.value != "Ex" {
+		t.Errorf("config expect restored to Ex, got %s", node.value)
+	}
+	keyspaceConfigCacheLock.Lock()
+	if len(keyspaceConfigCache) != 0 {
+		t.Errorf("config cache expect empty, got %d", len(keyspaceConfigCache))
+	}
+	keyspaceConfigCacheLock.Unlock()
+
+	// 设置 失败 时 不 记录 会话
+	failed := &testConfigClient{value: "", setErr: errors.New("ERR unknown command")}
+	if _, err = setKeyspaceEvents(ctx, "127.0.0.1:6380", failed, "KEA"); err == nil {
+		t.Errorf("set error expect error")
+	}
+	keyspaceConfigCacheLock.Lock()
+	if keyspaceConfigCache["127.0.0.1:6380"] != nil {
+		t.Errorf("failed set expect not cached")
+	}
+	keyspaceConfigCacheLock.Unlock()
+}
@@ -0,0 +1,233 @@
+package module_redis
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/team-ide/go-tool/redis"
+	"teamide/pkg/base"
+)
+
+type ZSetMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+	Rank   int64   `json:"rank"`
+}
+
+type ZSetRequest struct {
+	BaseRequest
+	Members   []*ZSetMember `json:"members"`
+	Increment float64       `json:"increment"`
+	RangeType string        `json:"rangeType"` // rank 根据排名 score 根据分数
+	Min       string        `json:"min"`       // 分数 最小值 默认 -inf 支持 ( 开区间
+	Max       string        `json:"max"`       // 分数 最大值 默认 +inf 支持 ( 开区间
+	Reverse   bool          `json:"reverse"`   // 从大到小
+	PageNo    int64         `json:"pageNo"`
+	PageSize  int64         `json:"pageSize"`
+}
+
+type ZSetPage struct {
+	Total    int64         `json:"total"`
+	PageNo   int64         `json:"pageNo"`
+	PageSize int64         `json:"pageSize"`
+	List     []*ZSetMember `json:"list"`
+}
+
+func (this_ *api) zadd(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ZSetRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if len(request.Members) == 0 {
+		err = errors.New("members is empty")
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	var members []*goRedis.Z
+	for _, one := range request.Members {
+		members = append(members, &goRedis.Z{Member: one.Member, Score: one.Score})
+	}
+	res, err = client.ZAdd(context.Background(), request.getKey(), members...).Result()
+	return
+}
+
+func (this_ *api) zincrby(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ZSetRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	res, err = client.ZIncrBy(context.Background(), request.getKey(), request.Increment, request.Value).Result()
+	return
+}
+
+func (this_ *api) zrem(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ZSetRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	var members []interface{}
+	if request.Value != "" {
+		members = append(members, request.Value)
+	}
+	for _, one := range request.Members {
+		members = append(members, one.Member)
+	}
+	if len(members) == 0 {
+		err = errors.New("members is empty")
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	res, err = client.ZRem(context.Background(), request.getKey(), members...).Result()
+	return
+}
+
+// zrange 根据 排名 或 分数 分页 查询
+func (this_ *api) zrange(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &ZSetRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	client, err := service.GetClient(&redis.Param{Database: request.Database})
+	if err != nil {
+		return
+	}
+	offset := request.pageOffset()
+	page := &ZSetPage{
+		PageNo:   request.PageNo,
+		PageSize: request.PageSize,
+	}
+	ctx := context.Background()
+	key := request.getKey()
+
+	var list []goRedis.Z
+	if request.RangeType == "score" {
+		min, max := request.scoreRange()
+		page.Total, err = client.ZCount(ctx, key, min, max).Result()
+		if err != nil {
+			return
+		}
+		if request.Reverse {
+			list, err = client.ZRevRangeByScoreWithScores(ctx, key, &goRedis.ZRangeBy{Min: min, Max: max, Offset: offset, Count: request.PageSize}).Result()
+		} else {
+			list, err = client.ZRangeByScoreWithScores(ctx, key, &goRedis.ZRangeBy{Min: min, Max: max, Offset: offset, Count: request.PageSize}).Result()
+		}
+	} else {
+		page.Total, err = client.ZCard(ctx, key).Result()
+		if err != nil {
+			return
+		}
+		if request.Reverse {
+			list, err = client.ZRevRangeWithScores(ctx, key, offset, offset+request.PageSize-1).Result()
+		} else {
+			list, err = client.ZRangeWithScores(ctx, key, offset, offset+request.PageSize-1).Result()
+		}
+	}
+	if err != nil {
+		return
+	}
+	// 结果 是 连续的，只需要 查询 第一个 的 排名
+	var firstRank = offset
+	if request.RangeType == "score" && len(list) > 0 {
+		first := redisString(list[0].Member)
+		if request.Reverse {
+			firstRank, _ = client.ZRevRank(ctx, key, first).Result()
+		} else {
+			firstRank, _ = client.ZRank(ctx, key, first).Result()
+		}
+	}
+	page.List = toZSetMembers(list, firstRank)
+	res = page
+	return
+}
+
+// pageOffset 设置 默认 分页 第 1 页 每页 50，返回 偏移
+func (this_ *ZSetRequest) pageOffset() int64 {
+	if this_.PageNo <= 0 {
+		this_.PageNo = 1
+	}
+	if this_.PageSize <= 0 {
+		this_.PageSize = 50
+	}
+	return (this_.PageNo - 1) * this_.PageSize
+}
+
+// scoreRange 分数 范围，为空 时 为 -inf +inf
+func (this_ *ZSetRequest) scoreRange() (min string, max string) {
+	min, max = this_.Min, this_.Max
+	if min == "" {
+		min = "-inf"
+	}
+	if max == "" {
+		max = "+inf"
+	}
+	return
+}
+
+// toZSetMembers 结果 是 连续的，排名 从 firstRank 开始 递增
+func toZSetMembers(list []goRedis.Z, firstRank int64) (members []*ZSetMember) {
+	for i, one := range list {
+		members = append(members, &ZSetMember{
+			Member: redisString(one.Member),
+			Score:  one.Score,
+			Rank:   firstRank + int64(i),
+		})
+	}
+	return
+}
+
+func redisString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	}
+	return ""
+}
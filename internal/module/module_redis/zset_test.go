@@ -0,0 +1,77 @@
+package module_redis
+
+import (
+	goRedis "github.com/go-redis/redis/v8"
+	"reflect"
+	"testing"
+)
+
+func TestZSetPageOffset(t *testing.T) {
+	for _, one := range []struct {
+		pageNo   int64
+		pageSize int64
+		expect   [3]int64
+	}{
+		{0, 0, [3]int64{1, 50, 0}},
+		{-1, -1, [3]int64{1, 50, 0}},
+		{2, 0, [3]int64{2, 50, 50}},
+		{3, 20, [3]int64{3, 20, 40}},
+	} {
+		request := &ZSetRequest{PageNo: one.pageNo, PageSize: one.pageSize}
+		offset := request.pageOffset()
+		if res := [3]int64{request.PageNo, request.PageSize, offset}; res != one.expect {
+			t.Errorf("page %d %d expect %v, got %v", one.pageNo, one.pageSize, one.expect, res)
+		}
+	}
+}
+
+func TestZSetScoreRange(t *testing.T) {
+	for _, one := range []struct {
+		min    string
+		max    string
+		expect [2]string
+	}{
+		{"", "", [2]string{"-inf", "+inf"}},
+		{"(1", "", [2]string{"(1", "+inf"}},
+		{"", "(10", [2]string{"-inf", "(10"}},
+		{"1.5", "2", [2]string{"1.5", "2"}},
+	} {
+		min, max := (&ZSetRequest{Min: one.min, Max: one.max}).scoreRange()
+		if [2]string{min, max} != one.expect {
+			t.Errorf("score range %s %s expect %v, got %s %s", one.min, one.max, one.expect, min, max)
+		}
+	}
+}
+
+func TestToZSetMembers(t *testing.T) {
+	res := toZSetMembers([]goRedis.Z{
+		{Member: "a", Score: 1.5},
+		{Member: []byte("b"), Score: 2},
+	}, 10)
+	expect := []*ZSetMember{
+		{Member: "a", Score: 1.5, Rank: 10},
+		{Member: "b", Score: 2, Rank: 11},
+	}
+	if !reflect.DeepEqual(res, expect) {
+		t.Errorf("members error, got %v", res)
+	}
+	if res = toZSetMembers(nil, 0); len(res) != 0 {
+		t.Errorf("empty list expect no members, got %v", res)
+	}
+}
+
+func TestRedisString(t *testing.T) {
+	for _, one := range []struct {
+		value  interface{}
+		expect string
+	}{
+		{"a", "a"},
+		{[]byte("b"), "b"},
+		{1, ""},
+		{nil, ""},
+	} {
+		if res := redisString(one.value); res != one.expect {
+			t.Errorf("redis string %v expect %s, got %s", one.value, one.expect, res)
+		}
+	}
+}
@@ -10,26 +10,25 @@ import (
 	"github.com/team-ide/go-tool/thrift"
 	"github.com/team-ide/go-tool/util"
 	"golang.org/x/net/context"
-	"io"
-	"io/fs"
-	"net/http"
-	"net/url"
-	"os"
 	"sort"
 	"strings"
 	"sync"
 	"teamide/internal/module/module_toolbox"
 	"teamide/pkg/base"
+	"teamide/pkg/load"
+	"teamide/pkg/loadtask"
 	"time"
 )
 
 type api struct {
 	toolboxService *module_toolbox.ToolboxService
+	taskManager    *loadtask.Manager
 }
 
 func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
 	return &api{
 		toolboxService: toolboxService,
+		taskManager:    loadtask.NewManager("thrift-tasks", toolboxService.GetFilesDir),
 	}
 }
 
@@ -46,6 +45,19 @@ var (
 	invokeMetric          = base.AppendPower(&base.PowerAction{Action: "invokeMetric", Text: "执行信息", ShouldLogin: true, StandAlone: true, Parent: Power})
 	invokeMarkdown        = base.AppendPower(&base.PowerAction{Action: "invokeMarkdown", Text: "执行信息", ShouldLogin: true, StandAlone: true, Parent: Power})
 	closePower            = base.AppendPower(&base.PowerAction{Action: "close", Text: "关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	mockStartPower      = base.AppendPower(&base.PowerAction{Action: "mockStart", Text: "模拟服务启动", ShouldLogin: true, StandAlone: true, Parent: Power})
+	mockStopPower       = base.AppendPower(&base.PowerAction{Action: "mockStop", Text: "模拟服务停止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	mockListPower       = base.AppendPower(&base.PowerAction{Action: "mockList", Text: "模拟服务列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	mockUpdatePower     = base.AppendPower(&base.PowerAction{Action: "mockUpdate", Text: "模拟服务修改", ShouldLogin: true, StandAlone: true, Parent: Power})
+	mockCallsPower      = base.AppendPower(&base.PowerAction{Action: "mockCalls", Text: "模拟服务调用记录", ShouldLogin: true, StandAlone: true, Parent: Power})
+	mockCallsCleanPower = base.AppendPower(&base.PowerAction{Action: "mockCallsClean", Text: "模拟服务调用记录清理", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	scenarioListPower   = base.AppendPower(&base.PowerAction{Action: "scenarioList", Text: "测试场景列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	scenarioSavePower   = base.AppendPower(&base.PowerAction{Action: "scenarioSave", Text: "测试场景保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	scenarioDeletePower = base.AppendPower(&base.PowerAction{Action: "scenarioDelete", Text: "测试场景删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	scenarioRunPower    = base.AppendPower(&base.PowerAction{Action: "scenarioRun", Text: "测试场景执行", ShouldLogin: true, StandAlone: true, Parent: Power})
+	scenarioInvokePower = base.AppendPower(&base.PowerAction{Action: "scenarioInvoke", Text: "测试场景压测", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
 func (this_ *api) GetApis() (apis []*base.ApiWorker) {
@@ -62,6 +74,19 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: invokeMarkdown, Do: this_.invokeMarkdown})
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
 
+	apis = append(apis, &base.ApiWorker{Power: mockStartPower, Do: this_.mockStart})
+	apis = append(apis, &base.ApiWorker{Power: mockStopPower, Do: this_.mockStop})
+	apis = append(apis, &base.ApiWorker{Power: mockListPower, Do: this_.mockList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: mockUpdatePower, Do: this_.mockUpdate})
+	apis = append(apis, &base.ApiWorker{Power: mockCallsPower, Do: this_.mockCalls, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: mockCallsCleanPower, Do: this_.mockCallsClean})
+
+	apis = append(apis, &base.ApiWorker{Power: scenarioListPower, Do: this_.scenarioList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: scenarioSavePower, Do: this_.scenarioSave})
+	apis = append(apis, &base.ApiWorker{Power: scenarioDeletePower, Do: this_.scenarioDelete})
+	apis = append(apis, &base.ApiWorker{Power: scenarioRunPower, Do: this_.scenarioRun})
+	apis = append(apis, &base.ApiWorker{Power: scenarioInvokePower, Do: this_.scenarioInvoke})
+
 	return
 }
 
@@ -104,6 +129,11 @@ type BaseRequest struct {
 	PrometheusMetricsAddress    string `json:"prometheusMetricsAddress,omitempty"`
 	PrometheusSummaryCountMatch string `json:"prometheusSummaryCountMatch,omitempty"`
 	PrometheusSummarySumMatch   string `json:"prometheusSummarySumMatch,omitempty"`
+	PrometheusSumUnit           string `json:"prometheusSumUnit,omitempty"` // 耗时 单位 s、ms、us、ns，默认 s
+
+	ScenarioName string `json:"scenarioName,omitempty"` // 场景 名称，不为空 时 报告 按 场景 存放
+
+	LoadProfile *load.Profile `json:"loadProfile,omitempty"` // 负载 模型，为空 时 固定 Worker 个 线程
 
 	RequestMd5 string `json:"requestMd5,omitempty"`
 }
@@ -207,8 +237,8 @@ func (this_ *api) invokeByServerAddress(requestBean *base.RequestBean, c *gin.Co
 
 	filename := service.GetFormatDir() + "/" + request.RelativePath
 
-	var argFormat_ *argFormat
-	argFormat_, err = newArgFormat()
+	var argFormat_ *loadtask.ArgFormat
+	argFormat_, err = loadtask.NewArgFormat()
 	if err != nil {
 		err = errors.New("newArgFormat error:" + err.Error())
 		return
@@ -221,31 +251,15 @@ func (this_ *api) invokeByServerAddress(requestBean *base.RequestBean, c *gin.Co
 		data["end"] = time.Now().UnixMilli()
 	}()
 
-	var argsJSON = "["
-	for i, arg := range request.Args {
-		if i > 0 {
-			argsJSON += ","
-		}
-		trimS := strings.TrimSpace(arg)
-		if strings.HasPrefix(trimS, "[") || strings.HasPrefix(trimS, "{") {
-			argsJSON += arg
-		} else {
-			argsJSON += `"` + arg + `"`
-		}
-	}
-	argsJSON += "]"
-
-	var args []interface{}
-	err = util.JSONDecodeUseNumber([]byte(argsJSON), &args)
+	args, err := parseArgs(request.Args)
 	if err != nil {
-		err = errors.New("args json " + argsJSON + " to args error:" + err.Error())
 		return
 	}
 	if !request.IsTest {
 		var param *thrift.MethodParam
 
 		var fArgs []interface{}
-		fArgs, err = argFormat_.formatArgs(args, nil)
+		fArgs, err = argFormat_.FormatArgs(args, nil)
 		if err != nil {
 			err = errors.New("formatArgs error:" + err.Error())
 			return
@@ -289,192 +303,127 @@ func (this_ *api) invokeByServerAddress(requestBean *base.RequestBean, c *gin.Co
 			}
 		}
 	} else {
-		var parentDir string
-		parentDir, err = this_.getTaskParentDir(request)
-		if err != nil {
-			return
-		}
-		var t *task.Task
-
 		executor := &invokeExecutor{
-			argFormat:    argFormat_,
+			ArgFormat:    argFormat_,
 			BaseRequest:  request,
 			filename:     filename,
 			args:         args,
 			workerClient: make(map[int]*thrift.ServiceClient),
 			service:      service,
 		}
-		t, err = task.New(&task.Options{
-			Key:       fmt.Sprintf("%d", time.Now().UnixNano()),
-			Worker:    request.Worker,
-			Frequency: request.Frequency,
-			Duration:  request.Duration,
-			Executor:  executor,
-		})
-		if err != nil {
-			return
-		}
-		if request.CountSecond > 0 {
-			t.Metric.SetCountSecond(request.CountSecond)
-		}
-		if request.CountTop {
-			t.Metric.SetCountTop(request.CountTop)
-		}
-		executor.taskDir = parentDir + "" + t.Key
-		executor.t = t
-		_ = this_.saveTaskInfo(executor, request, t)
-		go func() {
-			defer func() {
-				removeTask(t.Key)
-				_ = this_.saveTaskInfo(executor, request, t)
-				executor.stop()
-				_ = this_.saveTaskInfo(executor, request, t)
-			}()
-			for !t.IsEnd {
-				_ = this_.saveTaskInfo(executor, request, t)
-				time.Sleep(time.Second * 1)
-			}
-		}()
-		executor.startSaveRecords()
-		go t.Run()
-		addTask(t)
+		_, err = this_.startTask(executor, executor)
 	}
 	return
 }
 
-func (this_ *api) getTaskParentDir(request *BaseRequest) (taskDir string, err error) {
-	taskDir = this_.toolboxService.GetFilesDir()
-	taskDir += this_.getTaskParentDirRelativePath(request)
-
-	ex, err := util.PathExists(taskDir)
+// startTask 创建 并 启动 压测 任务，executor 保存 任务 信息 和 执行 记录，taskExecutor 执行 调用
+func (this_ *api) startTask(executor *invokeExecutor, taskExecutor task.Executor) (t *task.Task, err error) {
+	request := executor.BaseRequest
+	parentRelativePath, err := this_.getTaskParentRelativePath(request)
 	if err != nil {
 		return
 	}
-	if !ex {
-		err = os.MkdirAll(taskDir, fs.ModePerm)
-	}
-
-	return
-}
-
-func (this_ *api) getTaskParentDirRelativePath(request *BaseRequest) (taskDir string) {
-	taskDir = fmt.Sprintf("%s/toolbox-%d/%s", "thrift-tasks", request.ToolboxId, request.RelativePath) + "/" + request.ServiceName + "/" + request.MethodName + "/"
-
-	return
-}
-
-func (this_ *api) saveTaskInfo(executor *invokeExecutor, request *BaseRequest, task *task.Task) (err error) {
-	ex, err := util.PathExists(executor.taskDir)
+	runner, err := load.New(&task.Options{
+		Key:       fmt.Sprintf("%d", time.Now().UnixNano()),
+		Worker:    request.Worker,
+		Frequency: request.Frequency,
+		Duration:  request.Duration,
+		Executor:  taskExecutor,
+	}, request.LoadProfile, request.CountSecond)
 	if err != nil {
 		return
 	}
-	if !ex {
-		err = os.MkdirAll(executor.taskDir, fs.ModePerm)
-	}
-
-	bs, _ := json.Marshal(request)
-	requestMd5 := util.GetMD5(string(bs))
-	data := map[string]interface{}{}
-	data["requestMd5"] = requestMd5
-	data["request"] = request
-	data["task"] = task
-	data["taskKey"] = task.Key
-	c := task.Metric.GetCount()
-	data["metric"] = c
-	bs, _ = json.Marshal(data)
-	err = util.WriteFile(executor.taskDir+"/info.json", bs)
+	t = runner.Task()
+	executor.taskDir, err = this_.taskManager.TaskDir(parentRelativePath, t.Key)
 	if err != nil {
 		return
 	}
-	bs, _ = json.Marshal(c)
-	_ = util.WriteFile(executor.taskDir+"/metric.json", bs)
-	bs, _ = json.Marshal(task.Metric.GetSecondCounts())
-	_ = util.WriteFile(executor.taskDir+"/metric.second.json", bs)
-
+	executor.latency = runner.Latency
+	if request.CountSecond > 0 {
+		t.Metric.SetCountSecond(request.CountSecond)
+	}
+	if request.CountTop {
+		t.Metric.SetCountTop(request.CountTop)
+	}
+	executor.t = t
+	if request.PrometheusMetricsAddress != "" {
+		executor.prometheus = newPrometheusDataCollect(request)
+		go executor.prometheus.start()
+	}
+	executor.startSaveRecords()
+	this_.taskManager.Run(runner, func() {
+		_ = this_.saveTaskInfo(executor, request, t)
+	}, func() {
+		if executor.prometheus != nil {
+			executor.prometheus.stop()
+		}
+		executor.stop()
+	})
 	return
 }
-func (this_ *api) loadTask(taskDir string) (data map[string]interface{}, err error) {
-	defer func() {
-		if len(data) == 0 {
-			data = nil
-		}
-	}()
-	data = map[string]interface{}{}
-	data["isEnd"] = true
 
-	var bs []byte
-	if ex, _ := util.PathExists(taskDir + "/info.json"); ex {
-		if bs, err = os.ReadFile(taskDir + "/info.json"); err != nil {
+// getTaskParentRelativePath 任务 按 文件/服务/方法 存放，场景 压测 按 场景 名称 存放
+func (this_ *api) getTaskParentRelativePath(request *BaseRequest) (relativePath string, err error) {
+	if request.ScenarioName != "" {
+		if err = checkScenarioName(request.ScenarioName); err != nil {
 			return
 		}
-		err = util.JSONDecodeUseNumber(bs, &data)
-		if err != nil {
-			return
-		}
-		if data["metric"] == nil {
-			data["metric"] = &metric.Count{}
-		}
-		if data["taskKey"] != nil {
-			taskKey := util.GetStringValue(data["taskKey"])
-			t := getTask(taskKey)
-			if t != nil {
-				data["isEnd"] = false
-			} else {
-				data["isEnd"] = true
-			}
-		}
-
+		relativePath, err = this_.taskManager.ParentRelativePath(request.ToolboxId, "scenarios", request.ScenarioName)
+		return
 	}
-
-	return
-}
-
-func (this_ *api) loadTasks(requestBean *base.RequestBean, c *gin.Context) (taskList []map[string]interface{}, err error) {
-	request := &BaseRequest{}
-	if !base.RequestJSON(request, c) {
+	if err = loadtask.CheckName(request.ServiceName); err != nil {
+		return
+	}
+	if err = loadtask.CheckName(request.MethodName); err != nil {
 		return
 	}
+	relativePath, err = this_.taskManager.ParentRelativePath(request.ToolboxId, request.RelativePath, request.ServiceName, request.MethodName)
+	return
+}
 
-	parentDir, err := this_.getTaskParentDir(request)
+func (this_ *api) getTaskDir(request *BaseRequest) (taskDir string, err error) {
+	parentRelativePath, err := this_.getTaskParentRelativePath(request)
 	if err != nil {
 		return
 	}
+	taskDir, err = this_.taskManager.TaskDir(parentRelativePath, request.TaskKey)
+	return
+}
 
-	parentDirRelativePath := this_.getTaskParentDirRelativePath(request)
-
-	fileList, err := os.ReadDir(parentDir)
+func (this_ *api) saveTaskInfo(executor *invokeExecutor, request *BaseRequest, task *task.Task) (err error) {
+	extend := map[string]interface{}{}
+	if executor.prometheus != nil {
+		extend["server"] = executor.prometheus.summary()
+	}
+	if executor.scenarioCount != nil {
+		extend["scenario"] = executor.scenarioCount.summary()
+	}
+	err = loadtask.SaveTask(executor.taskDir, request, task, executor.latency, extend)
 	if err != nil {
 		return
 	}
-	var taskInfo map[string]interface{}
-	var names []string
-	for _, f := range fileList {
-		if !f.IsDir() {
-			continue
-		}
-		names = append(names, f.Name())
-
+	if executor.prometheus != nil {
+		_ = loadtask.WriteJSON(executor.taskDir, "prometheus.json", executor.prometheus.getData())
 	}
+	return
+}
 
-	sort.Slice(names, func(i, j int) bool {
-		return strings.ToLower(names[i]) < strings.ToLower(names[j]) //升序  即前面的值比后面的小 忽略大小写排序
-	})
-	size := len(names)
-	for i := size - 1; i >= 0; i-- {
-		taskInfo, err = this_.loadTask(parentDir + names[i])
-		if err != nil {
-			return
-		}
-		if taskInfo != nil {
-			taskInfo["taskRelativePath"] = parentDirRelativePath + names[i]
-			taskList = append(taskList, taskInfo)
-		}
+func (this_ *api) loadTasks(c *gin.Context) (taskList []map[string]interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
 	}
+	parentRelativePath, err := this_.getTaskParentRelativePath(request)
+	if err != nil {
+		return
+	}
+	taskList, err = this_.taskManager.LoadTasks(parentRelativePath)
 	return
 }
+
 func (this_ *api) invokeReports(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 
-	res, err = this_.loadTasks(requestBean, c)
+	res, err = this_.loadTasks(c)
 
 	return
 }
@@ -485,55 +434,29 @@ func (this_ *api) invokeMarkdown(requestBean *base.RequestBean, c *gin.Context)
 		return
 	}
 
-	taskList, err := this_.loadTasks(requestBean, c)
+	taskList, err := this_.loadTasks(c)
 	if err != nil {
 		return
 	}
 	res = toMarkdown(request.RequestMd5, taskList)
 	return
 }
-func (this_ *api) downloadRecords(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Content-Transfer-Encoding", "binary")
 
+func (this_ *api) downloadRecords(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	this_.toolboxService.Logger.Info("下载执行记录 start")
 	res = base.HttpNotResponse
-	defer func() {
-		if err != nil {
-			_, _ = c.Writer.WriteString(err.Error())
-		}
-	}()
 
 	request := map[string]string{}
-
 	err = c.Bind(&request)
 	if err != nil {
 		return
 	}
 
 	fileName := "" + request["serviceName"] + "." + request["methodName"] + "-执行记录.txt"
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.QueryEscape(fileName)))
-
-	// 此处不设置 文件大小，如果设置文件大小，将无法终止下载
-	//c.Header("Content-Length", fmt.Sprint(fileInfo.Size))
-	c.Header("download-file-name", fileName)
-
-	taskDir := this_.toolboxService.GetFilesDir() + request["taskRelativePath"]
-
-	if ex, _ := util.PathExists(taskDir + "/records.txt"); ex {
-		var f *os.File
-		f, err = os.Open(taskDir + "/records.txt")
-		if err != nil {
-			return
-		}
-		defer func() { _ = f.Close() }()
-		_, err = io.Copy(c.Writer, f)
-	} else {
-		_, err = c.Writer.WriteString("暂无执行记录")
-	}
-	c.Status(http.StatusOK)
+	err = this_.taskManager.DownloadRecords(c, fileName, request["taskRelativePath"])
 	return
 }
+
 func (this_ *api) invokeReportDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 
 	request := &BaseRequest{}
@@ -541,84 +464,21 @@ func (this_ *api) invokeReportDelete(requestBean *base.RequestBean, c *gin.Conte
 		return
 	}
 
-	taskParentDir, err := this_.getTaskParentDir(request)
+	parentRelativePath, err := this_.getTaskParentRelativePath(request)
 	if err != nil {
 		return
 	}
-
-	var removeKeys []string
-	if request.RequestMd5 == "" {
-		removeKeys = append(removeKeys, request.TaskKey)
-	} else {
-		var taskList []map[string]interface{}
-		taskList, err = this_.loadTasks(requestBean, c)
-		if err != nil {
-			return
-		}
-		for _, t := range taskList {
-			if t["requestMd5"] == nil {
-				continue
-			}
-			if t["requestMd5"].(string) == request.RequestMd5 {
-				removeKeys = append(removeKeys, t["taskKey"].(string))
-			}
-		}
-	}
-	for _, removeKey := range removeKeys {
-		t := getTask(removeKey)
-		if t != nil {
-			t.Stop()
-		}
-		taskDir := taskParentDir + "" + removeKey
-		if ex, _ := util.PathExists(taskDir); ex {
-			err = os.RemoveAll(taskDir)
-		}
-	}
-
+	err = this_.taskManager.Delete(parentRelativePath, request.TaskKey, request.RequestMd5)
 	return
 }
 
-var taskCache = map[string]*task.Task{}
-var taskLocker = &sync.Mutex{}
-
-func getTask(taskKey string) *task.Task {
-	taskLocker.Lock()
-	defer taskLocker.Unlock()
-
-	return taskCache[taskKey]
-}
-
-func addTask(task *task.Task) {
-	taskLocker.Lock()
-	defer taskLocker.Unlock()
-
-	taskCache[task.Key] = task
-}
-
-func removeTask(taskKey string) {
-	taskLocker.Lock()
-	defer taskLocker.Unlock()
-
-	delete(taskCache, taskKey)
-}
-
 func (this_ *api) invokeStop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	request := &BaseRequest{}
 	if !base.RequestJSON(request, c) {
 		return
 	}
 
-	t := getTask(request.TaskKey)
-
-	if t != nil {
-		t.Stop()
-		for {
-			find := getTask(request.TaskKey)
-			if find == nil {
-				break
-			}
-		}
-	}
+	this_.taskManager.Stop(request.TaskKey)
 	return
 }
 
@@ -629,12 +489,12 @@ func (this_ *api) invokeInfo(requestBean *base.RequestBean, c *gin.Context) (res
 		return
 	}
 
-	taskParentDir, err := this_.getTaskParentDir(request)
+	taskDir, err := this_.getTaskDir(request)
 	if err != nil {
 		return
 	}
 
-	res, err = this_.loadTask(taskParentDir + request.TaskKey)
+	res, err = this_.taskManager.LoadTask(taskDir)
 
 	return
 }
@@ -646,26 +506,58 @@ func (this_ *api) invokeMetric(requestBean *base.RequestBean, c *gin.Context) (r
 		return
 	}
 
-	taskParentDir, err := this_.getTaskParentDir(request)
+	taskDir, err := this_.getTaskDir(request)
+	if err != nil {
+		return
+	}
+
+	data, latency, err := loadtask.ReadMetric(taskDir)
 	if err != nil {
 		return
 	}
+	var serverData []*prometheusData
+	err = loadtask.ReadJSON(taskDir, "prometheus.json", &serverData)
+	if err != nil {
+		return
+	}
+	if len(serverData) == 0 && len(latency) == 0 {
+		res = data
+		return
+	}
+	res = withExtendMetric(data, serverData, latency)
 
-	taskDir := taskParentDir + request.TaskKey
-	var data []*metric.Count
-	var bs []byte
+	return
+}
 
-	if ex, _ := util.PathExists(taskDir + "/metric.second.json"); ex {
-		if bs, err = os.ReadFile(taskDir + "/metric.second.json"); err != nil {
-			return
-		}
-		err = util.JSONDecodeUseNumber(bs, &data)
-		if err != nil {
-			return
+// invokeMetricCount 客户端 统计 和 同一 时段 的 耗时 分位、服务端 采集 的 指标
+type invokeMetricCount struct {
+	*metric.Count
+	Latency *load.Percentile `json:"latency,omitempty"`
+	Server  *prometheusData  `json:"server,omitempty"`
+}
+
+// withExtendMetric 为 每个 统计 时段 匹配 耗时 分位 和 结束 时间 最 接近 的 服务端 采集
+func withExtendMetric(counts []*metric.Count, serverData []*prometheusData, latency []*load.Percentile) (res []*invokeMetricCount) {
+	for _, count := range counts {
+		one := &invokeMetricCount{Count: count}
+		one.Latency = load.Match(latency, count.StartTime)
+		endTime := count.EndTime / int64(time.Millisecond)
+		var minDiff int64 = -1
+		for _, server := range serverData {
+			if server.Error != "" {
+				continue
+			}
+			diff := server.Time - endTime
+			if diff < 0 {
+				diff = -diff
+			}
+			if minDiff < 0 || diff < minDiff {
+				minDiff = diff
+				one.Server = server
+			}
 		}
+		res = append(res, one)
 	}
-	res = data
-
 	return
 }
 
@@ -675,6 +567,7 @@ func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res inte
 		return
 	}
 
+	removeDirMocks(config.ThriftDir)
 	removeWorkspace(config.ThriftDir)
 	return
 }
@@ -0,0 +1,70 @@
+package module_thrift
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	res, err := parseArgs([]string{"a", ` {"b":1}`, "[1,2]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 || res[0] != "a" {
+		t.Errorf("args expect 3 with first a, got %v", res)
+	}
+	if _, ok := res[1].(map[string]interface{}); !ok {
+		t.Errorf("object arg expect map, got %T", res[1])
+	}
+	if _, err = parseArgs([]string{"{b"}); err == nil {
+		t.Errorf("invalid json arg expect error")
+	}
+}
+
+func TestScenarioScriptValue(t *testing.T) {
+	format, err := newScenarioArgFormat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 场景 中 使用 导出 值 字符串 不带 引号
+	for _, one := range []struct {
+		script string
+		expect string
+	}{
+		{`"abc"`, "abc"},
+		{"1+2", "3"},
+	} {
+		res, err := format.ScriptValue(one.script, nil)
+		if err != nil || res != one.expect {
+			t.Errorf("scenario script %s expect %s, got %s %v", one.script, one.expect, res, err)
+		}
+	}
+}
+
+func TestScenarioFormatArgs(t *testing.T) {
+	format, err := newScenarioArgFormat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = format.SetVars(map[string]interface{}{
+		"steps": map[string]interface{}{"login": map[string]interface{}{"result": map[string]interface{}{"token": "t1"}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := format.FormatArgs([]interface{}{
+		"token=${steps.login.result.token}",
+		map[string]interface{}{"token": "${steps.login.result.token}", "n": 1},
+		[]interface{}{"${1+1}"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []interface{}{
+		"token=t1",
+		map[string]interface{}{"token": "t1", "n": 1},
+		[]interface{}{"2"},
+	}
+	if !reflect.DeepEqual(res, expect) {
+		t.Errorf("format args expect %v, got %v", expect, res)
+	}
+}
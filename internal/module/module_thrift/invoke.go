@@ -12,12 +12,14 @@ import (
 	"io/fs"
 	"os"
 	"sync"
+	"teamide/pkg/load"
+	"teamide/pkg/loadtask"
 	"time"
 )
 
 type invokeExecutor struct {
 	*BaseRequest
-	*argFormat
+	*loadtask.ArgFormat
 	filename         string
 	args             []interface{}
 	workerClient     map[int]*thrift.ServiceClient
@@ -28,6 +30,9 @@ type invokeExecutor struct {
 	recordsFile      *os.File
 	paramList        []*thrift.MethodParam
 	t                *task.Task
+	prometheus       *prometheusDataCollect
+	scenarioCount    *scenarioCount // 场景 压测 时 的 步骤 及 断言 统计
+	latency          *load.Latency
 }
 
 func (this_ *invokeExecutor) startSaveRecords() {
@@ -138,9 +143,8 @@ func (this_ *invokeExecutor) stop() {
 
 }
 
-func NewClient(request *BaseRequest) (client *thrift.ServiceClient, err error) {
-	var protocolFactory go_thrift.TProtocolFactory
-
+// getProtocolFactory 根据 配置 获取 协议，客户端 和 模拟 服务端 共用
+func getProtocolFactory(request *BaseRequest) (protocolFactory go_thrift.TProtocolFactory) {
 	switch request.ProtocolFactory {
 	case "compact":
 		protocolFactory = go_thrift.NewTCompactProtocolFactoryConf(nil)
@@ -153,8 +157,11 @@ func NewClient(request *BaseRequest) (client *thrift.ServiceClient, err error) {
 	default:
 		protocolFactory = go_thrift.NewTBinaryProtocolFactoryConf(nil)
 	}
+	return
+}
 
-	var transportFactory go_thrift.TTransportFactory
+// getTransportFactory 根据 配置 获取 传输 方式，客户端 和 模拟 服务端 共用
+func getTransportFactory(request *BaseRequest) (transportFactory go_thrift.TTransportFactory) {
 	if request.Buffered {
 		transportFactory = go_thrift.NewTBufferedTransportFactory(8192)
 	} else {
@@ -164,6 +171,12 @@ func NewClient(request *BaseRequest) (client *thrift.ServiceClient, err error) {
 	if request.Framed {
 		transportFactory = go_thrift.NewTFramedTransportFactoryConf(transportFactory, nil)
 	}
+	return
+}
+
+func NewClient(request *BaseRequest) (client *thrift.ServiceClient, err error) {
+	protocolFactory := getProtocolFactory(request)
+	transportFactory := getTransportFactory(request)
 
 	transport := go_thrift.NewTSocketConf(request.ServerAddress, nil)
 	_ = transport.SetConnTimeout(time.Millisecond * time.Duration(request.Timeout))
@@ -201,7 +214,7 @@ func (this_ *invokeExecutor) getClient(param *task.ExecutorParam) (client *thrif
 	return
 }
 func (this_ *invokeExecutor) Before(param *task.ExecutorParam) (err error) {
-	args, err := this_.formatArgs(this_.args, param)
+	args, err := this_.FormatArgs(this_.args, param)
 	if err != nil {
 		return
 	}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/team-ide/go-tool/metric"
+	"strings"
+	"teamide/pkg/load"
 )
 
 func toMarkdown(requestMd5 string, taskList []map[string]interface{}) (content string) {
@@ -46,8 +48,12 @@ func groupToMarkdown(index int, group []map[string]interface{}) (content string)
 
 	content += fmt.Sprintf("## 测试组-%d  \n\n", index+1)
 	content += fmt.Sprintf("#### 接口信息  \n\n")
-	content += fmt.Sprintf("* 服务名称：%s  \n", request.ServiceName)
-	content += fmt.Sprintf("* 方法名称：%s  \n", request.MethodName)
+	if request.ScenarioName != "" {
+		content += fmt.Sprintf("* 场景名称：%s  \n", request.ScenarioName)
+	} else {
+		content += fmt.Sprintf("* 服务名称：%s  \n", request.ServiceName)
+		content += fmt.Sprintf("* 方法名称：%s  \n", request.MethodName)
+	}
 
 	content += fmt.Sprintf("\n")
 
@@ -58,6 +64,9 @@ func groupToMarkdown(index int, group []map[string]interface{}) (content string)
 	} else {
 		content += fmt.Sprintf("* 执行时长：%d  \n", request.Duration)
 	}
+	if !request.LoadProfile.IsFixed() {
+		content += fmt.Sprintf("* 负载模型：%s  \n", loadProfileText(request.LoadProfile))
+	}
 	content += fmt.Sprintf("* 测试地址：%s  \n", request.ServerAddress)
 	content += fmt.Sprintf("* 超时时长：%d  \n", request.Timeout)
 	content += fmt.Sprintf("* ProtocolFactory类型：%s  \n", request.ProtocolFactory)
@@ -94,9 +103,122 @@ func groupToMarkdown(index int, group []map[string]interface{}) (content string)
 		WarnUseTime:   1000,
 	})
 	content += fmt.Sprintf("\n\n")
+	content += serverToMarkdown(group, cs)
+	content += latencyToMarkdown(group)
+	content += scenarioToMarkdown(group)
+	return
+}
+
+func loadProfileText(profile *load.Profile) string {
+	switch profile.Mode {
+	case load.ModeRamp:
+		return fmt.Sprintf("线性 %d 秒 从 %d 增加 到 最大 线程数，保持 %d 秒，%d 秒 减少 到 0", profile.RampUpSecond, profile.StartWorker, profile.HoldSecond, profile.RampDownSecond)
+	case load.ModeStep:
+		return fmt.Sprintf("阶梯 每 %d 秒 增加 %d 个 线程 到 最大 线程数，保持 %d 秒", profile.StepSecond, profile.StepWorker, profile.HoldSecond)
+	case load.ModeRate:
+		return fmt.Sprintf("固定 到达 速率 每秒 %d 次，保持 %d 秒，耗时 分位 包含 排队 等待", profile.Rate, profile.HoldSecond)
+	}
+	return profile.Mode
+}
+
+// latencyToMarkdown 耗时 直方图 统计 的 分位
+func latencyToMarkdown(group []map[string]interface{}) (content string) {
+	var rows string
+	for i, task := range group {
+		if task["latency"] == nil {
+			continue
+		}
+		bs, _ := json.Marshal(task["latency"])
+		latency := &load.Percentile{}
+		_ = json.Unmarshal(bs, latency)
+		rows += fmt.Sprintf("| %d | %d | %.2f | %.2f | %.2f | %.2f | %.2f | %.2f |\n",
+			i+1, latency.Count, latency.Avg, latency.P50, latency.P90, latency.P99, latency.P999, latency.Max)
+	}
+	if rows == "" {
+		return
+	}
+	content += fmt.Sprintf("#### 耗时分位  \n\n")
+	content += fmt.Sprintf("* 由 耗时 直方图 计算，单位 毫秒，各 时段 的 分位 见 执行 指标 \n")
+	content += fmt.Sprintf("\n")
+	content += fmt.Sprintf("| 序号 | 样本数 | 平均 | P50 | P90 | P99 | P999 | 最大 |\n")
+	content += fmt.Sprintf("| --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	content += rows
+	content += fmt.Sprintf("\n\n")
 	return
 }
 
+// scenarioToMarkdown 场景 压测 的 步骤 及 断言 统计
+func scenarioToMarkdown(group []map[string]interface{}) (content string) {
+	var rows string
+	for i, task := range group {
+		if task["scenario"] == nil {
+			continue
+		}
+		bs, _ := json.Marshal(task["scenario"])
+		count := &scenarioCount{}
+		_ = json.Unmarshal(bs, count)
+		rows += fmt.Sprintf("| %d | 合计 | - | %d | %d | - | %d | %d | |\n", i+1, count.Count, count.FailCount, count.AssertCount, count.AssertFail)
+		for _, step := range count.Steps {
+			rows += fmt.Sprintf("| %d | %s | %s.%s | %d | %d | %d | %d | %d | %s |\n",
+				i+1, step.Name, step.ServiceName, step.MethodName, step.Count, step.ErrorCount, step.SkipCount, step.AssertCount, step.AssertFail, markdownCell(step.LastFail))
+		}
+	}
+	if rows == "" {
+		return
+	}
+	content += fmt.Sprintf("#### 场景断言  \n\n")
+	content += fmt.Sprintf("* 合计：场景 执行 次数 及 失败 次数，任意 步骤 错误 或 断言 失败 即 场景 失败，计入 错误数 \n")
+	content += fmt.Sprintf("* 跳过：前面 步骤 失败 后 未 执行 的 次数 \n")
+	content += fmt.Sprintf("\n")
+	content += fmt.Sprintf("| 序号 | 步骤 | 方法 | 执行次数 | 错误次数 | 跳过次数 | 断言次数 | 断言失败 | 最后失败 |\n")
+	content += fmt.Sprintf("| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	content += rows
+	content += fmt.Sprintf("\n\n")
+	return
+}
+
+// serverToMarkdown 服务端 Prometheus 指标 与 客户端 统计 对比
+func serverToMarkdown(group []map[string]interface{}, cs []*metric.Count) (content string) {
+	var rows string
+	for i, task := range group {
+		if task["server"] == nil {
+			continue
+		}
+		bs, _ := json.Marshal(task["server"])
+		server := &prometheusSummary{}
+		_ = json.Unmarshal(bs, server)
+		rows += fmt.Sprintf("| %d | %s | %.2f | %s | %.2f | %s | %s | %s | %s |\n",
+			i+1, cs[i].Tps, server.Qps, cs[i].Avg, server.Avg,
+			quantileText(server, "p50"), quantileText(server, "p90"), quantileText(server, "p99"), quantileText(server, "p999"))
+	}
+	if rows == "" {
+		return
+	}
+	content += fmt.Sprintf("#### 服务端指标  \n\n")
+	content += fmt.Sprintf("* 服务端QPS：测试期间 Prometheus 计数 差值 / 采集 时长 \n")
+	content += fmt.Sprintf("* 服务端平均耗时：耗时 累计 差值 / 计数 差值，单位 毫秒；与 客户端 平均耗时 的 差值 为 网络 及 序列化 开销 \n")
+	content += fmt.Sprintf("* 分位：summary 为 服务端 计算 值，histogram 由 分桶 差值 估算，单位 毫秒 \n")
+	content += fmt.Sprintf("\n")
+	content += fmt.Sprintf("| 序号 | 客户端TPS | 服务端QPS | 客户端平均耗时 | 服务端平均耗时 | P50 | P90 | P99 | P999 |\n")
+	content += fmt.Sprintf("| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	content += rows
+	content += fmt.Sprintf("\n\n")
+	return
+}
+
+// markdownCell 转义 表格 单元格 中 的 竖线 和 换行
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "\r", "").Replace(text)
+}
+
+func quantileText(server *prometheusSummary, name string) string {
+	v, ok := server.Quantiles[name]
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
 type tS struct {
 	Size int64
 	Unit string
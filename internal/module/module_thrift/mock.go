@@ -0,0 +1,554 @@
+package module_thrift
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	go_thrift "github.com/apache/thrift/lib/go/thrift"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/thrift"
+	"github.com/team-ide/go-tool/util"
+	"golang.org/x/net/context"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/loadtask"
+	"time"
+)
+
+// 模拟 服务 保留 的 调用 记录 条数
+const mockCallsSize = 1000
+
+// MockMethod 模拟 方法 的 响应 配置
+// mode 为 demo 时 按 返回 类型 生成 示例 数据，json 时 返回 固定 JSON，script 时 执行 脚本 取 最后 一个 表达式 的 值
+// 脚本 中 可 使用 args（参数 名 对应 值）、argList、methodName、callIndex
+// 脚本 返回 {"$exception": "异常 字段 名", "$value": {...}} 时 响应 IDL 中 声明 的 异常
+type MockMethod struct {
+	MethodName string `json:"methodName"`
+	Mode       string `json:"mode"` // demo、json、script，默认 demo
+	Json       string `json:"json,omitempty"`
+	Script     string `json:"script,omitempty"`
+	Delay      int    `json:"delay,omitempty"` // 响应 延迟 毫秒
+}
+
+// MockCall 模拟 服务 收到 的 调用
+type MockCall struct {
+	Index          int64                  `json:"index"`
+	Time           int64                  `json:"time"`
+	MethodName     string                 `json:"methodName"`
+	SeqId          int32                  `json:"seqId"`
+	Args           map[string]interface{} `json:"args"`
+	Result         interface{}            `json:"result,omitempty"`
+	Exception      string                 `json:"exception,omitempty"`
+	ExceptionValue interface{}            `json:"exceptionValue,omitempty"`
+	Error          string                 `json:"error,omitempty"`
+	UseTime        int64                  `json:"useTime"` // 毫秒
+}
+
+// MockInfo 模拟 服务 状态
+type MockInfo struct {
+	MockId          string        `json:"mockId"`
+	ToolboxId       int64         `json:"toolboxId"`
+	RelativePath    string        `json:"relativePath"`
+	ServiceName     string        `json:"serviceName"`
+	Address         string        `json:"address"`
+	ProtocolFactory string        `json:"protocolFactory"`
+	Buffered        bool          `json:"buffered"`
+	Framed          bool          `json:"framed"`
+	StartTime       int64         `json:"startTime"`
+	CallCount       int64         `json:"callCount"`
+	Methods         []*MockMethod `json:"methods"`
+	MethodNames     []string      `json:"methodNames"`
+}
+
+type mockMethodInfo struct {
+	filename    string
+	serviceName string
+	param       *thrift.MethodParam
+	oneway      bool
+}
+
+// mockServer 按 IDL 读取 参数 并 按 配置 响应，实现 go_thrift.TProcessor
+type mockServer struct {
+	MockInfo
+	thriftDir string
+	workspace *thrift.Workspace
+	argFormat *loadtask.ArgFormat
+	server    *go_thrift.TSimpleServer
+	infos     map[string]*mockMethodInfo
+	methods   map[string]*MockMethod
+	calls     []*MockCall
+	lock      sync.Mutex
+}
+
+var (
+	mockCache     = map[string]*mockServer{}
+	mockCacheLock = &sync.Mutex{}
+)
+
+func getMock(mockId string) *mockServer {
+	mockCacheLock.Lock()
+	defer mockCacheLock.Unlock()
+	return mockCache[mockId]
+}
+
+// getToolboxMock 只 返回 属于 该 工具 的 模拟 服务
+func getToolboxMock(toolboxId int64, mockId string) *mockServer {
+	one := getMock(mockId)
+	if one == nil || one.ToolboxId != toolboxId {
+		return nil
+	}
+	return one
+}
+
+func getMocks(toolboxId int64) (list []*mockServer) {
+	mockCacheLock.Lock()
+	defer mockCacheLock.Unlock()
+	for _, one := range mockCache {
+		if toolboxId == 0 || one.ToolboxId == toolboxId {
+			list = append(list, one)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartTime < list[j].StartTime
+	})
+	return
+}
+
+func removeMock(mockId string) {
+	mockCacheLock.Lock()
+	one := mockCache[mockId]
+	delete(mockCache, mockId)
+	mockCacheLock.Unlock()
+	if one != nil {
+		_ = one.server.Stop()
+	}
+}
+
+// removeDirMocks 工作 目录 关闭 时 停止 使用 该 目录 的 模拟 服务
+func removeDirMocks(thriftDir string) {
+	for _, one := range getMocks(0) {
+		if one.thriftDir == thriftDir {
+			removeMock(one.MockId)
+		}
+	}
+}
+
+// loadServiceMethods 加载 服务 的 方法，包含 继承 的 服务 方法
+func loadServiceMethods(workspace *thrift.Workspace, filename string, serviceName string, infos map[string]*mockMethodInfo) (err error) {
+	serviceNode := workspace.GetService(filename, serviceName)
+	if serviceNode == nil {
+		err = errors.New("service [" + filename + "][" + serviceName + "] not found")
+		return
+	}
+	if serviceNode.ExtendsName != "" {
+		extendsFilename := filename
+		if serviceNode.ExtendsInclude != "" {
+			extendsFilename = workspace.GetIncludePath(filename, serviceNode.ExtendsInclude)
+		}
+		if err = loadServiceMethods(workspace, extendsFilename, serviceNode.ExtendsName, infos); err != nil {
+			return
+		}
+	}
+	for _, method := range serviceNode.Methods {
+		info := &mockMethodInfo{
+			filename:    filename,
+			serviceName: serviceName,
+			oneway:      method.Oneway,
+		}
+		info.param, err = workspace.GetMethodParam(filename, serviceName, method.Name)
+		if err != nil {
+			return
+		}
+		infos[method.Name] = info
+	}
+	return
+}
+
+func startMock(workspace *thrift.Workspace, thriftDir string, request *MockRequest) (mock *mockServer, err error) {
+	if request.Port <= 0 {
+		err = errors.New("port is empty")
+		return
+	}
+	filename := util.FormatPath(workspace.GetFormatDir() + "/" + request.RelativePath)
+	mock = &mockServer{
+		thriftDir: thriftDir,
+		workspace: workspace,
+		infos:     map[string]*mockMethodInfo{},
+		methods:   map[string]*MockMethod{},
+	}
+	mock.MockId = util.GetUUID()
+	mock.ToolboxId = request.ToolboxId
+	mock.RelativePath = request.RelativePath
+	mock.ServiceName = request.ServiceName
+	mock.Address = fmt.Sprintf("%s:%d", request.Host, request.Port)
+	mock.ProtocolFactory = request.ProtocolFactory
+	mock.Buffered = request.Buffered
+	mock.Framed = request.Framed
+
+	if err = loadServiceMethods(workspace, filename, request.ServiceName, mock.infos); err != nil {
+		return
+	}
+	for name := range mock.infos {
+		mock.MethodNames = append(mock.MethodNames, name)
+	}
+	sort.Strings(mock.MethodNames)
+	if err = mock.setMethods(request.Methods); err != nil {
+		return
+	}
+	if mock.argFormat, err = loadtask.NewArgFormat(); err != nil {
+		return
+	}
+
+	serverSocket, err := go_thrift.NewTServerSocket(mock.Address)
+	if err != nil {
+		return
+	}
+	mock.server = go_thrift.NewTSimpleServer4(mock, serverSocket, getTransportFactory(&request.BaseRequest), getProtocolFactory(&request.BaseRequest))
+	// 先 监听 以便 端口 被 占用 时 直接 返回 错误
+	if err = mock.server.Listen(); err != nil {
+		err = errors.New("listen " + mock.Address + " error:" + err.Error())
+		return
+	}
+	mock.StartTime = util.GetNowMilli()
+	go func() {
+		_ = mock.server.AcceptLoop()
+	}()
+
+	mockCacheLock.Lock()
+	mockCache[mock.MockId] = mock
+	mockCacheLock.Unlock()
+	return
+}
+
+func (this_ *mockServer) setMethods(methods []*MockMethod) (err error) {
+	cache := map[string]*MockMethod{}
+	for _, one := range methods {
+		if this_.infos[one.MethodName] == nil {
+			err = errors.New("method [" + one.MethodName + "] not found in service [" + this_.ServiceName + "]")
+			return
+		}
+		switch one.Mode {
+		case "", "demo":
+		case "json":
+			var v interface{}
+			if err = util.JSONDecodeUseNumber([]byte(one.Json), &v); err != nil {
+				err = errors.New("method [" + one.MethodName + "] json error:" + err.Error())
+				return
+			}
+		case "script":
+			if strings.TrimSpace(one.Script) == "" {
+				err = errors.New("method [" + one.MethodName + "] script is empty")
+				return
+			}
+		default:
+			err = errors.New("method [" + one.MethodName + "] mode [" + one.Mode + "] not support")
+			return
+		}
+		cache[one.MethodName] = one
+	}
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	this_.methods = cache
+	this_.Methods = methods
+	return
+}
+
+func (this_ *mockServer) status() *MockInfo {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	res := this_.MockInfo
+	return &res
+}
+
+func (this_ *mockServer) addCall(call *MockCall) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	this_.CallCount++
+	call.Index = this_.CallCount
+	this_.calls = append(this_.calls, call)
+	if len(this_.calls) > mockCallsSize {
+		this_.calls = this_.calls[len(this_.calls)-mockCallsSize:]
+	}
+}
+
+// getCalls 查询 index 大于 since 的 调用 记录
+func (this_ *mockServer) getCalls(since int64, size int) (list []*MockCall) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	for _, one := range this_.calls {
+		if one.Index <= since {
+			continue
+		}
+		list = append(list, one)
+		if size > 0 && len(list) >= size {
+			break
+		}
+	}
+	return
+}
+
+func (this_ *mockServer) cleanCalls() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	this_.calls = nil
+}
+
+func (this_ *mockServer) ProcessorMap() map[string]go_thrift.TProcessorFunction {
+	return map[string]go_thrift.TProcessorFunction{}
+}
+
+func (this_ *mockServer) AddToProcessorMap(string, go_thrift.TProcessorFunction) {
+}
+
+func (this_ *mockServer) Process(ctx context.Context, in, out go_thrift.TProtocol) (bool, go_thrift.TException) {
+	name, _, seqId, err := in.ReadMessageBegin(ctx)
+	if err != nil {
+		return false, go_thrift.WrapTException(err)
+	}
+	startTime := time.Now()
+	call := &MockCall{
+		Time:       util.GetMilliByTime(startTime),
+		MethodName: name,
+		SeqId:      seqId,
+	}
+	defer func() {
+		call.UseTime = time.Since(startTime).Milliseconds()
+		this_.addCall(call)
+	}()
+
+	info := this_.infos[name]
+	if info == nil {
+		_ = in.Skip(ctx, go_thrift.STRUCT)
+		_ = in.ReadMessageEnd(ctx)
+		call.Error = "unknown method " + name
+		x := go_thrift.NewTApplicationException(go_thrift.UNKNOWN_METHOD, "Unknown function "+name)
+		return false, this_.writeException(ctx, out, name, seqId, x)
+	}
+	call.Args, err = thrift.ReadStructFields(ctx, in, info.param.ArgFields)
+	if err != nil {
+		call.Error = "read args error:" + err.Error()
+		_ = in.ReadMessageEnd(ctx)
+		x := go_thrift.NewTApplicationException(go_thrift.PROTOCOL_ERROR, call.Error)
+		_ = this_.writeException(ctx, out, name, seqId, x)
+		return false, x
+	}
+	if err = in.ReadMessageEnd(ctx); err != nil {
+		return false, go_thrift.WrapTException(err)
+	}
+
+	result, exceptionField, err := this_.answer(info, call)
+	if info.oneway {
+		return true, nil
+	}
+	if err != nil {
+		call.Error = err.Error()
+		x := go_thrift.NewTApplicationException(go_thrift.INTERNAL_ERROR, "mock "+name+" error:"+err.Error())
+		if e := this_.writeException(ctx, out, name, seqId, x); e != nil {
+			return false, e
+		}
+		return true, nil
+	}
+
+	var fields []*thrift.Field
+	value := map[string]interface{}{}
+	if exceptionField != nil {
+		fields = append(fields, exceptionField)
+		value[exceptionField.Name] = call.ExceptionValue
+	} else if info.param.ResultType != nil && info.param.ResultType.TypeId != go_thrift.VOID {
+		fields = append(fields, &thrift.Field{Num: 0, Name: "success", Type: info.param.ResultType})
+		value["success"] = result
+	}
+	if err = out.WriteMessageBegin(ctx, name, go_thrift.REPLY, seqId); err == nil {
+		if err = thrift.WriteStructFields(ctx, out, name+"_result", fields, value); err == nil {
+			if err = out.WriteMessageEnd(ctx); err == nil {
+				err = out.Flush(ctx)
+			}
+		}
+	}
+	if err != nil {
+		call.Error = "write result error:" + err.Error()
+		return false, go_thrift.WrapTException(err)
+	}
+	return true, nil
+}
+
+func (this_ *mockServer) writeException(ctx context.Context, out go_thrift.TProtocol, name string, seqId int32, x go_thrift.TApplicationException) go_thrift.TException {
+	_ = out.WriteMessageBegin(ctx, name, go_thrift.EXCEPTION, seqId)
+	_ = x.Write(ctx, out)
+	_ = out.WriteMessageEnd(ctx)
+	_ = out.Flush(ctx)
+	return x
+}
+
+// answer 按 方法 配置 生成 响应，返回 异常 字段 时 响应 该 异常
+func (this_ *mockServer) answer(info *mockMethodInfo, call *MockCall) (result interface{}, exceptionField *thrift.Field, err error) {
+	this_.lock.Lock()
+	method := this_.methods[call.MethodName]
+	callIndex := this_.CallCount + 1
+	this_.lock.Unlock()
+	if method == nil {
+		method = &MockMethod{MethodName: call.MethodName}
+	}
+	if method.Delay > 0 {
+		time.Sleep(time.Duration(method.Delay) * time.Millisecond)
+	}
+
+	switch method.Mode {
+	case "json":
+		err = util.JSONDecodeUseNumber([]byte(method.Json), &result)
+	case "script":
+		args := map[string]interface{}{}
+		var argList []interface{}
+		// 转换 为 JSON 类型 便于 脚本 使用
+		bs, _ := json.Marshal(call.Args)
+		_ = json.Unmarshal(bs, &args)
+		for _, field := range info.param.ArgFields {
+			argList = append(argList, args[field.Name])
+		}
+		result, err = this_.argFormat.RunScript(method.Script, map[string]interface{}{
+			"args":       args,
+			"argList":    argList,
+			"methodName": call.MethodName,
+			"callIndex":  callIndex,
+		})
+	default:
+		if info.param.ResultType != nil && info.param.ResultType.TypeId != go_thrift.VOID {
+			result = this_.workspace.GetFieldDemoDataByType(info.filename, info.param.ResultType)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	if data, ok := result.(map[string]interface{}); ok && data["$exception"] != nil {
+		name := util.GetStringValue(data["$exception"])
+		for _, field := range info.param.ExceptionFields {
+			if field.Name == name {
+				exceptionField = field
+				break
+			}
+		}
+		if exceptionField == nil {
+			err = errors.New("exception [" + name + "] not declared in method [" + call.MethodName + "]")
+			return
+		}
+		call.Exception = name
+		call.ExceptionValue = data["$value"]
+		result = nil
+		return
+	}
+	call.Result = result
+	return
+}
+
+type MockRequest struct {
+	BaseRequest
+	MockId  string        `json:"mockId"`
+	Host    string        `json:"host"` // 监听 地址，默认 所有 网卡
+	Port    int           `json:"port"`
+	Methods []*MockMethod `json:"methods"`
+	Since   int64         `json:"since"` // 查询 index 大于 该值 的 调用 记录
+	Size    int           `json:"size"`
+}
+
+func (this_ *api) mockStart(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	// 模拟 服务 按 工具 管理，需要 已 保存 且 有 权限 的 工具
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getOrCreateWorkspace(config)
+	if err != nil {
+		return
+	}
+
+	request := &MockRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	request.ToolboxId = toolbox.ToolboxId
+	mock, err := startMock(service, config.ThriftDir, request)
+	if err != nil {
+		return
+	}
+	res = mock.status()
+	return
+}
+
+// getRequestMock 获取 请求 工具 下 的 模拟 服务，不存在 或 不 属于 该 工具 时 返回 错误
+func (this_ *api) getRequestMock(requestBean *base.RequestBean, c *gin.Context) (mock *mockServer, request *MockRequest, err error) {
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+	request = &MockRequest{}
+	if !base.RequestJSON(request, c) {
+		err = errors.New("request body bind error")
+		return
+	}
+	mock = getToolboxMock(toolbox.ToolboxId, request.MockId)
+	if mock == nil {
+		err = errors.New("mock [" + request.MockId + "] not found")
+		return
+	}
+	return
+}
+
+func (this_ *api) mockStop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	mock, _, err := this_.getRequestMock(requestBean, c)
+	if err != nil {
+		return
+	}
+	removeMock(mock.MockId)
+	return
+}
+
+func (this_ *api) mockList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	toolbox, err := this_.toolboxService.GetRequestToolbox(requestBean, c)
+	if err != nil {
+		return
+	}
+	var list []*MockInfo
+	for _, one := range getMocks(toolbox.ToolboxId) {
+		list = append(list, one.status())
+	}
+	res = list
+	return
+}
+
+// mockUpdate 修改 运行 中 的 模拟 服务 的 方法 响应 配置
+func (this_ *api) mockUpdate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	mock, request, err := this_.getRequestMock(requestBean, c)
+	if err != nil {
+		return
+	}
+	if err = mock.setMethods(request.Methods); err != nil {
+		return
+	}
+	res = mock.status()
+	return
+}
+
+func (this_ *api) mockCalls(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	mock, request, err := this_.getRequestMock(requestBean, c)
+	if err != nil {
+		return
+	}
+	res = mock.getCalls(request.Since, request.Size)
+	return
+}
+
+func (this_ *api) mockCallsClean(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	mock, _, err := this_.getRequestMock(requestBean, c)
+	if err != nil {
+		return
+	}
+	mock.cleanCalls()
+	return
+}
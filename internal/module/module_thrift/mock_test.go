@@ -0,0 +1,35 @@
+package module_thrift
+
+import (
+	"testing"
+)
+
+func TestGetToolboxMock(t *testing.T) {
+	for _, one := range []*mockServer{
+		{MockInfo: MockInfo{MockId: "m1", ToolboxId: 1, StartTime: 1}},
+		{MockInfo: MockInfo{MockId: "m2", ToolboxId: 2, StartTime: 2}},
+		{MockInfo: MockInfo{MockId: "m3", ToolboxId: 1, StartTime: 3}},
+	} {
+		mockCacheLock.Lock()
+		mockCache[one.MockId] = one
+		mockCacheLock.Unlock()
+	}
+	defer func() {
+		mockCacheLock.Lock()
+		for _, mockId := range []string{"m1", "m2", "m3"} {
+			delete(mockCache, mockId)
+		}
+		mockCacheLock.Unlock()
+	}()
+
+	if getToolboxMock(1, "m1") == nil || getToolboxMock(2, "m2") == nil {
+		t.Errorf("toolbox mock expect found")
+	}
+	if getToolboxMock(2, "m1") != nil || getToolboxMock(1, "m4") != nil {
+		t.Errorf("other toolbox mock expect not found")
+	}
+	list := getMocks(1)
+	if len(list) != 2 || list[0].MockId != "m1" || list[1].MockId != "m3" {
+		t.Errorf("toolbox 1 mocks expect m1 m3, got %d", len(list))
+	}
+}
@@ -2,70 +2,490 @@ package module_thrift
 
 import (
 	"bufio"
+	"errors"
+	"github.com/team-ide/go-tool/util"
 	"io"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// metricSample 指标 样本 行，line 为 去掉 值 后 的 原始 文本，用于 前缀 匹配
+type metricSample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	line   string
+}
+
+type metricFamily struct {
+	Name    string
+	Type    string // counter、gauge、summary、histogram、untyped
+	Samples []*metricSample
+}
+
+// parseExposition 解析 Prometheus 文本 格式
+// summary 的 _sum、_count 以及 histogram 的 _bucket、_sum、_count 归属 到 同一 指标 族
+func parseExposition(rd io.Reader) (families map[string]*metricFamily, err error) {
+	families = map[string]*metricFamily{}
+	getFamily := func(name string) *metricFamily {
+		family := families[name]
+		if family == nil {
+			family = &metricFamily{Name: name, Type: "untyped"}
+			families[name] = family
+		}
+		return family
+	}
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				getFamily(fields[2]).Type = fields[3]
+			}
+			continue
+		}
+		var sample *metricSample
+		sample, err = parseSampleLine(line)
+		if err != nil {
+			return
+		}
+		familyName := sample.Name
+		for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+			if !strings.HasSuffix(sample.Name, suffix) {
+				continue
+			}
+			base := families[strings.TrimSuffix(sample.Name, suffix)]
+			if base != nil && (base.Type == "summary" || base.Type == "histogram") {
+				familyName = base.Name
+			}
+			break
+		}
+		family := getFamily(familyName)
+		family.Samples = append(family.Samples, sample)
+	}
+	err = scanner.Err()
+	return
+}
+
+// parseSampleLine 解析 样本 行：name{label="value",...} value [timestamp]
+func parseSampleLine(line string) (sample *metricSample, err error) {
+	sample = &metricSample{Labels: map[string]string{}}
+	i := strings.IndexAny(line, "{ \t")
+	if i < 0 {
+		err = errors.New("metric line [" + line + "] format error")
+		return
+	}
+	sample.Name = line[:i]
+	rest := line[i:]
+	if rest[0] == '{' {
+		var end int
+		end, err = parseLabels(rest, sample.Labels)
+		if err != nil {
+			err = errors.New("metric line [" + line + "] labels error:" + err.Error())
+			return
+		}
+		sample.line = sample.Name + rest[:end]
+		rest = rest[end:]
+	} else {
+		sample.line = sample.Name
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		err = errors.New("metric line [" + line + "] value is empty")
+		return
+	}
+	sample.Value, err = strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		err = errors.New("metric line [" + line + "] value error:" + err.Error())
+		return
+	}
+	return
+}
+
+// parseLabels 解析 {} 中 的 标签，返回 } 之后 的 位置，值 支持 \\ \" \n 转义
+func parseLabels(text string, labels map[string]string) (end int, err error) {
+	i := 1
+	for {
+		for i < len(text) && (text[i] == ' ' || text[i] == ',') {
+			i++
+		}
+		if i >= len(text) {
+			err = errors.New("missing }")
+			return
+		}
+		if text[i] == '}' {
+			end = i + 1
+			return
+		}
+		eq := strings.IndexByte(text[i:], '=')
+		if eq < 0 {
+			err = errors.New("missing =")
+			return
+		}
+		name := strings.TrimSpace(text[i : i+eq])
+		i += eq + 1
+		if i >= len(text) || text[i] != '"' {
+			err = errors.New("label [" + name + "] value must be quoted")
+			return
+		}
+		i++
+		var value strings.Builder
+		for ; i < len(text) && text[i] != '"'; i++ {
+			if text[i] == '\\' && i+1 < len(text) {
+				i++
+				if text[i] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(text[i])
+		}
+		if i >= len(text) {
+			err = errors.New("label [" + name + "] value not closed")
+			return
+		}
+		i++
+		labels[name] = value.String()
+	}
+}
+
+// sameLabels 比较 标签，忽略 except 指定 的 标签
+func sameLabels(a map[string]string, b map[string]string, except string) bool {
+	size := 0
+	for k, v := range a {
+		if k == except {
+			continue
+		}
+		size++
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	for k := range b {
+		if k != except {
+			size--
+		}
+	}
+	return size == 0
+}
+
+type prometheusBucket struct {
+	Le    float64
+	Count float64
+}
+
 type prometheusData struct {
-	Time         int64  `json:"time"`
-	Text         string `json:"text"`
-	SummaryCount int64  `json:"summaryCount"`
-	SummarySum   int64  `json:"summarySum"` // 毫秒
+	Time         int64              `json:"time"`
+	SummaryCount float64            `json:"summaryCount"`        // 累计 次数
+	SummarySum   float64            `json:"summarySum"`          // 累计 耗时 毫秒
+	Qps          float64            `json:"qps"`                 // 与 上次 采集 的 差值 计算
+	Avg          float64            `json:"avg"`                 // 与 上次 采集 的 差值 计算 毫秒
+	Quantiles    map[string]float64 `json:"quantiles,omitempty"` // 毫秒，summary 为 服务端 计算 的 值，histogram 由 分桶 差值 估算
+	Error        string             `json:"error,omitempty"`
+	buckets      []*prometheusBucket
+}
+
+// prometheusSummary 整个 任务 期间 的 服务端 统计，使用 第一次 和 最后 一次 采集 的 差值
+type prometheusSummary struct {
+	Count     float64            `json:"count"`
+	Qps       float64            `json:"qps"`
+	Avg       float64            `json:"avg"` // 毫秒
+	Quantiles map[string]float64 `json:"quantiles,omitempty"`
+}
+
+var prometheusQuantiles = []struct {
+	name  string
+	value float64
+}{
+	{"p50", 0.5},
+	{"p90", 0.9},
+	{"p99", 0.99},
+	{"p999", 0.999},
 }
 
 type prometheusDataCollect struct {
 	*BaseRequest
-	data []*prometheusData
+	url      string
+	unit     float64 // 耗时 换算 为 毫秒 的 倍数
+	client   *http.Client
+	data     []*prometheusData
+	last     *prometheusData
+	first    *prometheusData
+	lock     sync.Mutex
+	stopChan chan struct{}
+	done     chan struct{}
 }
 
+func newPrometheusDataCollect(request *BaseRequest) *prometheusDataCollect {
+	res := &prometheusDataCollect{
+		BaseRequest: request,
+		url:         request.PrometheusMetricsAddress,
+		unit:        1000,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		stopChan:    make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if !strings.Contains(res.url, "://") {
+		scheme := request.PrometheusMetricsScheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		res.url = scheme + "://" + res.url
+	}
+	switch request.PrometheusSumUnit {
+	case "ms":
+		res.unit = 1
+	case "us":
+		res.unit = 0.001
+	case "ns":
+		res.unit = 0.000001
+	}
+	return res
+}
+
+// start 按 统计 间隔 采集，任务 结束 后 由 stop 触发 最后 一次 采集
 func (this_ *prometheusDataCollect) start() {
+	defer close(this_.done)
+	countSecond := this_.CountSecond
+	if countSecond <= 0 {
+		countSecond = 10
+	}
+	ticker := time.NewTicker(time.Duration(countSecond) * time.Second)
+	defer ticker.Stop()
+	this_.collect()
+	for {
+		select {
+		case <-this_.stopChan:
+			this_.collect()
+			return
+		case <-ticker.C:
+			this_.collect()
+		}
+	}
+}
 
+// stop 停止 采集 并 等待 最后 一次 采集 完成
+func (this_ *prometheusDataCollect) stop() {
+	select {
+	case <-this_.stopChan:
+	default:
+		close(this_.stopChan)
+	}
+	<-this_.done
 }
 
 func (this_ *prometheusDataCollect) collect() {
+	one, err := this_.scrape()
+	if err != nil {
+		one = &prometheusData{Error: err.Error()}
+	}
+	one.Time = util.GetNowMilli()
 
-	//nowTime := util.GetNow()
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
 
-	res, err := http.Get(this_.PrometheusMetricsAddress)
+	if err == nil {
+		if this_.last != nil {
+			this_.computeDelta(this_.last, one)
+		}
+		if this_.first == nil {
+			this_.first = one
+		}
+		this_.last = one
+	}
+	this_.data = append(this_.data, one)
+}
+
+func (this_ *prometheusDataCollect) scrape() (one *prometheusData, err error) {
+	res, err := this_.client.Get(this_.url)
 	if err != nil {
 		return
 	}
 	defer func() { _ = res.Body.Close() }()
-	if res.StatusCode != 200 {
+	if res.StatusCode != http.StatusOK {
+		err = errors.New("metrics address [" + this_.url + "] response status:" + res.Status)
 		return
 	}
-
-	lines, err := ReadLine(res.Body)
+	families, err := parseExposition(res.Body)
 	if err != nil {
 		return
 	}
 
-	for _, line := range lines {
-		if strings.HasPrefix(line, this_.PrometheusSummaryCountMatch) {
-
-		} else if strings.HasPrefix(line, this_.PrometheusSummarySumMatch) {
-
+	one = &prometheusData{}
+	var countSamples []*metricSample
+	var countFamily *metricFamily
+	for _, family := range families {
+		for _, sample := range family.Samples {
+			if this_.PrometheusSummaryCountMatch != "" && strings.HasPrefix(sample.line, this_.PrometheusSummaryCountMatch) {
+				one.SummaryCount += sample.Value
+				countSamples = append(countSamples, sample)
+				countFamily = family
+			} else if this_.PrometheusSummarySumMatch != "" && strings.HasPrefix(sample.line, this_.PrometheusSummarySumMatch) {
+				one.SummarySum += sample.Value * this_.unit
+			}
+		}
+	}
+	if countFamily == nil {
+		return
+	}
+	// 与 匹配 的 _count 标签 相同 的 分桶 或 分位 样本
+	matchSeries := func(sample *metricSample, except string) bool {
+		for _, countSample := range countSamples {
+			if sameLabels(sample.Labels, countSample.Labels, except) {
+				return true
+			}
+		}
+		return false
+	}
+	switch countFamily.Type {
+	case "histogram":
+		bucketCache := map[float64]*prometheusBucket{}
+		for _, sample := range countFamily.Samples {
+			if sample.Name != countFamily.Name+"_bucket" || !matchSeries(sample, "le") {
+				continue
+			}
+			le, e := strconv.ParseFloat(sample.Labels["le"], 64)
+			if e != nil {
+				continue
+			}
+			bucket := bucketCache[le]
+			if bucket == nil {
+				bucket = &prometheusBucket{Le: le}
+				bucketCache[le] = bucket
+				one.buckets = append(one.buckets, bucket)
+			}
+			bucket.Count += sample.Value
+		}
+		sort.Slice(one.buckets, func(i, j int) bool {
+			return one.buckets[i].Le < one.buckets[j].Le
+		})
+	case "summary":
+		for _, sample := range countFamily.Samples {
+			if sample.Name != countFamily.Name || !matchSeries(sample, "quantile") {
+				continue
+			}
+			q, e := strconv.ParseFloat(sample.Labels["quantile"], 64)
+			if e != nil || math.IsNaN(sample.Value) {
+				continue
+			}
+			for _, one_ := range prometheusQuantiles {
+				if one_.value != q {
+					continue
+				}
+				if one.Quantiles == nil {
+					one.Quantiles = map[string]float64{}
+				}
+				// 多个 序列 取 最大 值
+				if v := sample.Value * this_.unit; v > one.Quantiles[one_.name] {
+					one.Quantiles[one_.name] = v
+				}
+			}
 		}
 	}
+	return
+}
 
+// computeDelta 根据 两次 采集 的 差值 计算 QPS、平均 耗时 和 histogram 分位
+func (this_ *prometheusDataCollect) computeDelta(from *prometheusData, to *prometheusData) {
+	seconds := float64(to.Time-from.Time) / 1000
+	if seconds <= 0 {
+		return
+	}
+	count := to.SummaryCount - from.SummaryCount
+	sum := to.SummarySum - from.SummarySum
+	// 服务 重启 计数 归零 时 与 rate 相同 视为 从 0 开始
+	if count < 0 {
+		count, sum = to.SummaryCount, to.SummarySum
+	}
+	to.Qps = count / seconds
+	if count > 0 {
+		to.Avg = sum / count
+	}
+	if len(to.buckets) > 0 {
+		quantiles := histogramQuantiles(from.buckets, to.buckets, this_.unit)
+		if len(quantiles) > 0 {
+			to.Quantiles = quantiles
+		}
+	}
 }
 
-// ReadLine 逐行读取文件
-func ReadLine(rd io.Reader) (lines []string, err error) {
-	buf := bufio.NewReader(rd)
-	var line string
-	for {
-		line, err = buf.ReadString('\n')
-		line = strings.TrimSpace(line)
-		if err != nil {
-			if err == io.EOF { //读取结束，会报EOF
-				err = nil
-				return
+// histogramQuantiles 按 分桶 差值 线性 插值 估算 分位，算法 同 histogram_quantile
+func histogramQuantiles(from []*prometheusBucket, to []*prometheusBucket, unit float64) (res map[string]float64) {
+	fromCount := map[float64]float64{}
+	for _, bucket := range from {
+		fromCount[bucket.Le] = bucket.Count
+	}
+	// 任意 分桶 减少 说明 服务 重启 计数 归零，视为 从 0 开始
+	for _, bucket := range to {
+		if bucket.Count < fromCount[bucket.Le] {
+			fromCount = map[float64]float64{}
+			break
+		}
+	}
+	if len(to) == 0 {
+		return
+	}
+	var deltas []*prometheusBucket
+	for _, bucket := range to {
+		deltas = append(deltas, &prometheusBucket{Le: bucket.Le, Count: bucket.Count - fromCount[bucket.Le]})
+	}
+	total := deltas[len(deltas)-1].Count
+	if total <= 0 {
+		return
+	}
+	res = map[string]float64{}
+	for _, q := range prometheusQuantiles {
+		rank := q.value * total
+		var prevLe, prevCount float64
+		for _, bucket := range deltas {
+			if bucket.Count < rank {
+				prevLe, prevCount = bucket.Le, bucket.Count
+				continue
+			}
+			v := bucket.Le
+			if math.IsInf(bucket.Le, 1) {
+				// 落在 +Inf 分桶 时 取 上一个 分桶 的 上限
+				v = prevLe
+			} else if bucket.Count > prevCount {
+				v = prevLe + (bucket.Le-prevLe)*(rank-prevCount)/(bucket.Count-prevCount)
 			}
-			return nil, err
+			res[q.name] = v * unit
+			break
 		}
-		lines = append(lines, line)
+	}
+	return
+}
+
+func (this_ *prometheusDataCollect) getData() (res []*prometheusData) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	res = append(res, this_.data...)
+	return
+}
+
+func (this_ *prometheusDataCollect) summary() (res *prometheusSummary) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	if this_.first == nil || this_.last == nil || this_.first == this_.last {
+		return
+	}
+	total := &prometheusData{Time: this_.last.Time, SummaryCount: this_.last.SummaryCount, SummarySum: this_.last.SummarySum, Quantiles: this_.last.Quantiles, buckets: this_.last.buckets}
+	this_.computeDelta(this_.first, total)
+	res = &prometheusSummary{
+		Count:     total.SummaryCount - this_.first.SummaryCount,
+		Qps:       total.Qps,
+		Avg:       total.Avg,
+		Quantiles: total.Quantiles,
 	}
 	return
 }
@@ -0,0 +1,204 @@
+package module_thrift
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	for _, one := range []struct {
+		text   string
+		expect map[string]string
+		end    int
+		error  bool
+	}{
+		{`{}`, map[string]string{}, 2, false},
+		{`{a="1"} 2`, map[string]string{"a": "1"}, 7, false},
+		{`{a="1",b="x y",}`, map[string]string{"a": "1", "b": "x y"}, 16, false},
+		{`{ a = "1" , b="2"}`, nil, 0, true},
+		{`{a="q\"uote",b="back\\slash",c="new\nline"}`, map[string]string{"a": `q"uote`, "b": `back\slash`, "c": "new\nline"}, 43, false},
+		{`{path="/a{b}",m="a=b"}`, map[string]string{"path": "/a{b}", "m": "a=b"}, 22, false},
+		{`{a="1"`, nil, 0, true},
+		{`{a=1}`, nil, 0, true},
+		{`{a="1}`, nil, 0, true},
+		{`{a}`, nil, 0, true},
+	} {
+		labels := map[string]string{}
+		end, err := parseLabels(one.text, labels)
+		if (err != nil) != one.error {
+			t.Errorf("labels %s expect error %v, got %v", one.text, one.error, err)
+			continue
+		}
+		if one.error {
+			continue
+		}
+		if end != one.end || !reflect.DeepEqual(labels, one.expect) {
+			t.Errorf("labels %s expect %v end %d, got %v end %d", one.text, one.expect, one.end, labels, end)
+		}
+	}
+}
+
+const testExposition = `
+# HELP rpc_seconds rpc latency
+# TYPE rpc_seconds histogram
+rpc_seconds_bucket{method="get",le="0.1"} 5
+rpc_seconds_bucket{method="get",le="0.5"} 8
+rpc_seconds_bucket{method="get",le="+Inf"} 10 1700000000000
+rpc_seconds_sum{method="get"} 2.5
+rpc_seconds_count{method="get"} 10
+# TYPE rpc_summary summary
+rpc_summary{quantile="0.5"} 0.01
+rpc_summary{quantile="0.99"} NaN
+rpc_summary_sum 1
+rpc_summary_count 3
+# TYPE up gauge
+up 1
+requests_total{path="/a\"b"} 7
+`
+
+func TestParseExposition(t *testing.T) {
+	families, err := parseExposition(strings.NewReader(testExposition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, one := range []struct {
+		name    string
+		kind    string
+		samples int
+	}{
+		{"rpc_seconds", "histogram", 5},
+		{"rpc_summary", "summary", 4},
+		{"up", "gauge", 1},
+		{"requests_total", "untyped", 1},
+	} {
+		family := families[one.name]
+		if family == nil {
+			t.Errorf("family %s not found", one.name)
+			continue
+		}
+		if family.Type != one.kind || len(family.Samples) != one.samples {
+			t.Errorf("family %s expect %s %d samples, got %s %d", one.name, one.kind, one.samples, family.Type, len(family.Samples))
+		}
+	}
+	if len(families) != 4 {
+		t.Errorf("expect 4 families, got %d", len(families))
+	}
+	inf := families["rpc_seconds"].Samples[2]
+	if inf.Labels["le"] != "+Inf" || inf.Value != 10 || inf.line != `rpc_seconds_bucket{method="get",le="+Inf"}` {
+		t.Errorf("+Inf bucket error, got %+v", inf)
+	}
+	if !math.IsNaN(families["rpc_summary"].Samples[1].Value) {
+		t.Errorf("NaN value expect parsed")
+	}
+	if families["requests_total"].Samples[0].Labels["path"] != `/a"b` {
+		t.Errorf("escaped label expect /a\"b, got %s", families["requests_total"].Samples[0].Labels["path"])
+	}
+
+	for _, text := range []string{"metric", "metric{a=\"1\"}", "metric abc", "metric{a=1} 1"} {
+		if _, err = parseExposition(strings.NewReader(text)); err == nil {
+			t.Errorf("line %s expect error", text)
+		}
+	}
+}
+
+func TestHistogramQuantiles(t *testing.T) {
+	buckets := func(counts ...float64) (res []*prometheusBucket) {
+		les := []float64{0.1, 0.2, 0.4, math.Inf(1)}
+		for i, count := range counts {
+			res = append(res, &prometheusBucket{Le: les[i], Count: count})
+		}
+		return
+	}
+	for _, one := range []struct {
+		name   string
+		from   []*prometheusBucket
+		to     []*prometheusBucket
+		expect map[string]float64
+	}{
+		{"empty", nil, nil, nil},
+		{"noChange", buckets(1, 2, 3, 4), buckets(1, 2, 3, 4), nil},
+		// 100 次：50 次 <=0.1，40 次 0.1~0.2，10 次 0.2~0.4
+		{"linear", nil, buckets(50, 90, 100, 100), map[string]float64{"p50": 100, "p90": 200, "p99": 380, "p999": 398}},
+		{"delta", buckets(50, 90, 100, 100), buckets(100, 180, 200, 200), map[string]float64{"p50": 100, "p90": 200, "p99": 380, "p999": 398}},
+		// 落在 +Inf 分桶 时 取 上一个 分桶 的 上限
+		{"inf", nil, buckets(0, 0, 50, 100), map[string]float64{"p50": 400, "p90": 400, "p99": 400, "p999": 400}},
+		// 计数 归零 时 视为 从 0 开始
+		{"reset", buckets(500, 900, 1000, 1000), buckets(50, 90, 100, 100), map[string]float64{"p50": 100, "p90": 200, "p99": 380, "p999": 398}},
+	} {
+		res := histogramQuantiles(one.from, one.to, 1000)
+		if len(res) != len(one.expect) {
+			t.Errorf("%s expect %v, got %v", one.name, one.expect, res)
+			continue
+		}
+		for k, v := range one.expect {
+			if math.Abs(res[k]-v) > 1e-6 {
+				t.Errorf("%s %s expect %v, got %v", one.name, k, v, res[k])
+			}
+		}
+	}
+}
+
+func TestPrometheusComputeDelta(t *testing.T) {
+	collect := &prometheusDataCollect{unit: 1000}
+	from := &prometheusData{Time: 1000, SummaryCount: 100, SummarySum: 1000}
+	to := &prometheusData{Time: 3000, SummaryCount: 300, SummarySum: 3000}
+	collect.computeDelta(from, to)
+	if to.Qps != 100 || to.Avg != 10 {
+		t.Errorf("delta expect qps 100 avg 10, got %v %v", to.Qps, to.Avg)
+	}
+	// 计数 归零
+	to = &prometheusData{Time: 3000, SummaryCount: 20, SummarySum: 100}
+	collect.computeDelta(from, to)
+	if to.Qps != 10 || to.Avg != 5 {
+		t.Errorf("reset expect qps 10 avg 5, got %v %v", to.Qps, to.Avg)
+	}
+	to = &prometheusData{Time: 1000, SummaryCount: 300}
+	collect.computeDelta(from, to)
+	if to.Qps != 0 {
+		t.Errorf("same time expect no qps, got %v", to.Qps)
+	}
+}
+
+func TestPrometheusCollect(t *testing.T) {
+	var scrapes int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&scrapes, 1)
+		_, _ = fmt.Fprintf(w, "# TYPE rpc_seconds histogram\n"+
+			"rpc_seconds_bucket{method=\"get\",le=\"0.1\"} %d\n"+
+			"rpc_seconds_bucket{method=\"get\",le=\"+Inf\"} %d\n"+
+			"rpc_seconds_sum{method=\"get\"} %d\n"+
+			"rpc_seconds_count{method=\"get\"} %d\n"+
+			"rpc_seconds_count{method=\"set\"} 100\n", n*10, n*10, n, n*10)
+	}))
+	defer server.Close()
+
+	collect := newPrometheusDataCollect(&BaseRequest{
+		PrometheusMetricsAddress:    strings.TrimPrefix(server.URL, "http://"),
+		PrometheusSummaryCountMatch: `rpc_seconds_count{method="get"}`,
+		PrometheusSummarySumMatch:   `rpc_seconds_sum{method="get"}`,
+		CountSecond:                 1,
+	})
+	go collect.start()
+	collect.stop()
+	// stop 后 不再 采集，可 重复 调用
+	collect.stop()
+
+	data := collect.getData()
+	if len(data) != 2 {
+		t.Fatalf("expect 2 scrapes, got %d", len(data))
+	}
+	for _, one := range data {
+		if one.Error != "" {
+			t.Fatalf("scrape error:%s", one.Error)
+		}
+	}
+	if data[0].SummaryCount != 10 || data[0].SummarySum != 1000 || len(data[0].buckets) != 2 {
+		t.Errorf("first scrape error, got %+v", data[0])
+	}
+}
@@ -0,0 +1,704 @@
+package module_thrift
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/task"
+	"github.com/team-ide/go-tool/thrift"
+	"github.com/team-ide/go-tool/util"
+	"golang.org/x/net/context"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/jsonpath"
+	"teamide/pkg/loadtask"
+	"time"
+)
+
+// Scenario 测试 场景，按 顺序 执行 多个 Thrift 调用，后面 的 步骤 可 引用 前面 步骤 的 响应
+type Scenario struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Steps       []*ScenarioStep `json:"steps"`
+	UpdateTime  int64           `json:"updateTime,omitempty"`
+}
+
+// ScenarioStep 场景 步骤
+// 参数 脚本 中 可 使用 steps（步骤 名称 -> 响应）和 prev（上一 步骤 响应），响应 包含 args、result、exceptions、error
+// 如 ${steps.login.result.token}
+type ScenarioStep struct {
+	Name              string       `json:"name,omitempty"` // 为空 时 使用 step{序号}
+	RelativePath      string       `json:"relativePath"`
+	ServiceName       string       `json:"serviceName"`
+	MethodName        string       `json:"methodName"`
+	Args              []string     `json:"args,omitempty"`
+	Assertions        []*Assertion `json:"assertions,omitempty"`
+	ContinueOnFailure bool         `json:"continueOnFailure,omitempty"` // 失败 后 继续 执行 后续 步骤
+}
+
+// Assertion 响应 断言，作用 于 步骤 响应 {args, result, exceptions, error}
+// jsonPath：Path 的 值 与 Expect 比较，Operator 默认 ==，支持 jsonpath.Match 的 操作符，exists 判断 路径 是否 存在
+// regex：Path 的 值（为空 时 为 result 的 JSON）匹配 Pattern
+// script：脚本 返回 true 为 通过，可 使用 response、result、steps
+type Assertion struct {
+	Type     string      `json:"type"`
+	Path     string      `json:"path,omitempty"`
+	Operator string      `json:"operator,omitempty"`
+	Expect   interface{} `json:"expect,omitempty"`
+	Pattern  string      `json:"pattern,omitempty"`
+	Script   string      `json:"script,omitempty"`
+}
+
+type AssertionResult struct {
+	Type    string `json:"type"`
+	Path    string `json:"path,omitempty"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+type ScenarioStepResult struct {
+	Name        string             `json:"name"`
+	ServiceName string             `json:"serviceName"`
+	MethodName  string             `json:"methodName"`
+	Args        []interface{}      `json:"args,omitempty"`
+	Result      interface{}        `json:"result,omitempty"`
+	Exceptions  []interface{}      `json:"exceptions,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	UseTime     int64              `json:"useTime"`
+	Success     bool               `json:"success"`
+	Skipped     bool               `json:"skipped,omitempty"`
+	Assertions  []*AssertionResult `json:"assertions,omitempty"`
+}
+
+type ScenarioResult struct {
+	Name    string                `json:"name"`
+	Success bool                  `json:"success"`
+	Start   int64                 `json:"start"`
+	End     int64                 `json:"end"`
+	Steps   []*ScenarioStepResult `json:"steps"`
+
+	params []*thrift.MethodParam
+}
+
+func (this_ *ScenarioStep) stepName(index int) string {
+	if this_.Name != "" {
+		return this_.Name
+	}
+	return fmt.Sprintf("step%d", index+1)
+}
+
+// scenarioRunner 执行 一次 场景，压测 时 各 线程 共用，脚本 变量 在 各 线程 自己 的 argFormat 中 设置
+type scenarioRunner struct {
+	service  *thrift.Workspace
+	scenario *Scenario
+	stepArgs [][]interface{}
+}
+
+func newScenarioRunner(service *thrift.Workspace, scenario *Scenario) (runner *scenarioRunner, err error) {
+	if scenario == nil || len(scenario.Steps) == 0 {
+		err = errors.New("scenario steps is empty")
+		return
+	}
+	runner = &scenarioRunner{
+		service:  service,
+		scenario: scenario,
+	}
+	names := map[string]bool{}
+	for i, step := range scenario.Steps {
+		name := step.stepName(i)
+		if names[name] {
+			err = errors.New("scenario step name [" + name + "] already exists")
+			return
+		}
+		names[name] = true
+		var args []interface{}
+		args, err = parseArgs(step.Args)
+		if err != nil {
+			err = errors.New("step [" + name + "] " + err.Error())
+			return
+		}
+		runner.stepArgs = append(runner.stepArgs, args)
+	}
+	return
+}
+
+func (this_ *scenarioRunner) run(argFormat_ *loadtask.ArgFormat, client *thrift.ServiceClient, param *task.ExecutorParam) (result *ScenarioResult) {
+	result = &ScenarioResult{
+		Name:    this_.scenario.Name,
+		Success: true,
+		Start:   time.Now().UnixMilli(),
+	}
+	defer func() {
+		result.End = time.Now().UnixMilli()
+	}()
+
+	steps := map[string]interface{}{}
+	var prev interface{}
+	var stopped bool
+	for i, step := range this_.scenario.Steps {
+		stepResult := &ScenarioStepResult{
+			Name:        step.stepName(i),
+			ServiceName: step.ServiceName,
+			MethodName:  step.MethodName,
+		}
+		result.Steps = append(result.Steps, stepResult)
+		if stopped {
+			stepResult.Skipped = true
+			continue
+		}
+
+		response := this_.runStep(argFormat_, client, param, i, stepResult, steps, prev, result)
+		steps[stepResult.Name] = response
+		prev = response
+
+		if !stepResult.Success {
+			result.Success = false
+			if !step.ContinueOnFailure {
+				stopped = true
+			}
+		}
+	}
+	return
+}
+
+func (this_ *scenarioRunner) runStep(argFormat_ *loadtask.ArgFormat, client *thrift.ServiceClient, param *task.ExecutorParam, index int,
+	stepResult *ScenarioStepResult, steps map[string]interface{}, prev interface{}, result *ScenarioResult) (response interface{}) {
+
+	step := this_.scenario.Steps[index]
+	defer func() {
+		stepResult.Success = stepResult.Error == ""
+		for _, one := range stepResult.Assertions {
+			if !one.Success {
+				stepResult.Success = false
+			}
+		}
+	}()
+
+	err := argFormat_.SetVars(map[string]interface{}{
+		"steps": steps,
+		"prev":  prev,
+	})
+	if err != nil {
+		stepResult.Error = err.Error()
+		return
+	}
+	args, err := argFormat_.FormatArgs(this_.stepArgs[index], param)
+	if err != nil {
+		stepResult.Error = "formatArgs error:" + err.Error()
+		return
+	}
+	stepResult.Args = args
+
+	filename := this_.service.GetFormatDir() + "/" + step.RelativePath
+	methodParam, err := this_.service.GetMethodParam(filename, step.ServiceName, step.MethodName, args...)
+	if err != nil {
+		stepResult.Error = "GetMethodParam error:" + err.Error()
+		return
+	}
+	result.params = append(result.params, methodParam)
+
+	_, err = client.Send(context.Background(), methodParam)
+	if err != nil && methodParam.Error == "" {
+		methodParam.Error = err.Error()
+	}
+	stepResult.Result = methodParam.Result
+	stepResult.Exceptions = methodParam.Exceptions
+	stepResult.Error = methodParam.Error
+	stepResult.UseTime = methodParam.UseTime
+
+	response = toJSONValue(map[string]interface{}{
+		"args":       methodParam.Args,
+		"result":     methodParam.Result,
+		"exceptions": methodParam.Exceptions,
+		"error":      methodParam.Error,
+	})
+	if stepResult.Error != "" {
+		return
+	}
+	for _, assertion := range step.Assertions {
+		stepResult.Assertions = append(stepResult.Assertions, checkAssertion(argFormat_, assertion, response, steps))
+	}
+	return
+}
+
+// newScenarioArgFormat 场景 使用 的 argFormat，${} 脚本 值 为 字符串 时 使用 导出 值，如 ${steps.login.result.token} 不 带 引号
+func newScenarioArgFormat() (res *loadtask.ArgFormat, err error) {
+	res, err = loadtask.NewArgFormat()
+	if err != nil {
+		return
+	}
+	res.ExportValue = true
+	return
+}
+
+// toJSONValue 转换 为 JSON 解析 后 的 结构，供 jsonpath 和 脚本 使用
+func toJSONValue(value interface{}) (res interface{}) {
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	_ = json.Unmarshal(bs, &res)
+	return
+}
+
+func responseResult(response interface{}) interface{} {
+	if data, ok := response.(map[string]interface{}); ok {
+		return data["result"]
+	}
+	return nil
+}
+
+func checkAssertion(argFormat_ *loadtask.ArgFormat, assertion *Assertion, response interface{}, steps map[string]interface{}) (res *AssertionResult) {
+	res = &AssertionResult{
+		Type: assertion.Type,
+		Path: assertion.Path,
+	}
+	var err error
+	switch assertion.Type {
+	case "jsonPath":
+		operator := assertion.Operator
+		if operator == "" {
+			operator = "=="
+		}
+		expression := assertion.Path
+		if operator != "exists" {
+			bs, _ := json.Marshal(assertion.Expect)
+			expression += " " + operator + " " + string(bs)
+		}
+		res.Success, err = jsonpath.Match(response, expression)
+		if err == nil && !res.Success {
+			values, _ := jsonpath.Get(response, assertion.Path)
+			bs, _ := json.Marshal(values)
+			res.Message = "expect " + expression + " but values is " + string(bs)
+		}
+	case "regex":
+		var reg *regexp.Regexp
+		reg, err = regexp.Compile(assertion.Pattern)
+		if err != nil {
+			break
+		}
+		var values []interface{}
+		if assertion.Path == "" {
+			values = append(values, responseResult(response))
+		} else if values, err = jsonpath.Get(response, assertion.Path); err != nil {
+			break
+		}
+		for _, value := range values {
+			text, ok := value.(string)
+			if !ok {
+				bs, _ := json.Marshal(value)
+				text = string(bs)
+			}
+			if reg.MatchString(text) {
+				res.Success = true
+				break
+			}
+		}
+		if !res.Success {
+			res.Message = "values not match pattern " + assertion.Pattern
+		}
+	case "script":
+		var v interface{}
+		v, err = argFormat_.RunScript(assertion.Script, map[string]interface{}{
+			"response": response,
+			"result":   responseResult(response),
+			"steps":    steps,
+		})
+		if err != nil {
+			break
+		}
+		res.Success = v == true
+		if !res.Success {
+			res.Message = fmt.Sprintf("script return %v", v)
+		}
+	default:
+		err = errors.New("assertion type [" + assertion.Type + "] not support")
+	}
+	if err != nil {
+		res.Success = false
+		res.Message = err.Error()
+	}
+	return
+}
+
+// scenarioCount 场景 压测 统计，记录 各 步骤 的 执行、错误 和 断言 失败 次数
+type scenarioCount struct {
+	Count       int64                `json:"count"`
+	FailCount   int64                `json:"failCount"`
+	AssertCount int64                `json:"assertCount"`
+	AssertFail  int64                `json:"assertFail"`
+	Steps       []*scenarioStepCount `json:"steps"`
+	lock        sync.Mutex
+}
+
+type scenarioStepCount struct {
+	Name        string `json:"name"`
+	ServiceName string `json:"serviceName"`
+	MethodName  string `json:"methodName"`
+	Count       int64  `json:"count"`
+	ErrorCount  int64  `json:"errorCount"`
+	SkipCount   int64  `json:"skipCount"`
+	AssertCount int64  `json:"assertCount"`
+	AssertFail  int64  `json:"assertFail"`
+	LastFail    string `json:"lastFail,omitempty"` // 最后 一次 失败 信息
+}
+
+func newScenarioCount(scenario *Scenario) (res *scenarioCount) {
+	res = &scenarioCount{}
+	for i, step := range scenario.Steps {
+		res.Steps = append(res.Steps, &scenarioStepCount{
+			Name:        step.stepName(i),
+			ServiceName: step.ServiceName,
+			MethodName:  step.MethodName,
+		})
+	}
+	return
+}
+
+func (this_ *scenarioCount) add(result *ScenarioResult) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.Count++
+	if !result.Success {
+		this_.FailCount++
+	}
+	for i, stepResult := range result.Steps {
+		stepCount := this_.Steps[i]
+		if stepResult.Skipped {
+			stepCount.SkipCount++
+			continue
+		}
+		stepCount.Count++
+		if stepResult.Error != "" {
+			stepCount.ErrorCount++
+			stepCount.LastFail = stepResult.Error
+		}
+		for _, one := range stepResult.Assertions {
+			this_.AssertCount++
+			stepCount.AssertCount++
+			if !one.Success {
+				this_.AssertFail++
+				stepCount.AssertFail++
+				stepCount.LastFail = one.Message
+			}
+		}
+	}
+}
+
+func (this_ *scenarioCount) summary() (res *scenarioCount) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	res = &scenarioCount{
+		Count:       this_.Count,
+		FailCount:   this_.FailCount,
+		AssertCount: this_.AssertCount,
+		AssertFail:  this_.AssertFail,
+	}
+	for _, one := range this_.Steps {
+		stepCount := *one
+		res.Steps = append(res.Steps, &stepCount)
+	}
+	return
+}
+
+// scenarioExecutor 场景 压测 执行器，复用 invokeExecutor 的 连接、执行 记录 和 Prometheus 采集
+type scenarioExecutor struct {
+	*invokeExecutor
+	runner          *scenarioRunner
+	workerFormat    map[int]*loadtask.ArgFormat
+	workerFormatMux sync.Mutex
+}
+
+func (this_ *scenarioExecutor) getArgFormat(param *task.ExecutorParam) (res *loadtask.ArgFormat, err error) {
+	this_.workerFormatMux.Lock()
+	defer this_.workerFormatMux.Unlock()
+
+	res = this_.workerFormat[param.WorkerIndex]
+	if res != nil {
+		return
+	}
+	res, err = newScenarioArgFormat()
+	if err != nil {
+		return
+	}
+	this_.workerFormat[param.WorkerIndex] = res
+	return
+}
+
+func (this_ *scenarioExecutor) Before(param *task.ExecutorParam) (err error) {
+	_, err = this_.getArgFormat(param)
+	if err != nil {
+		return
+	}
+	_, err = this_.getClient(param)
+	return
+}
+
+// Execute 执行 一次 场景，任意 步骤 错误 或 断言 失败 返回 错误，计入 任务 错误 数
+func (this_ *scenarioExecutor) Execute(param *task.ExecutorParam) (err error) {
+	argFormat_, err := this_.getArgFormat(param)
+	if err != nil {
+		return
+	}
+	client, err := this_.getClient(param)
+	if err != nil {
+		return
+	}
+	result := this_.runner.run(argFormat_, client, param)
+	this_.scenarioCount.add(result)
+	for _, methodParam := range result.params {
+		this_.addParam(methodParam)
+	}
+	if !result.Success {
+		err = errors.New("scenario [" + result.Name + "] fail")
+	}
+	return
+}
+
+func (this_ *scenarioExecutor) After(param *task.ExecutorParam) (err error) {
+	return
+}
+
+type ScenarioRequest struct {
+	BaseRequest
+	Scenario *Scenario `json:"scenario"` // 保存 的 场景，执行 时 为空 则 按 ScenarioName 读取
+}
+
+func (this_ *api) getScenarioDir(toolboxId int64) string {
+	return this_.toolboxService.GetFilesDir() + fmt.Sprintf("%s/toolbox-%d/", "thrift-scenarios", toolboxId)
+}
+
+func checkScenarioName(name string) (err error) {
+	if name == "" {
+		err = errors.New("scenario name is empty")
+		return
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\:*?"<>|`) {
+		err = errors.New("scenario name [" + name + "] is invalid")
+		return
+	}
+	return
+}
+
+func (this_ *api) loadScenario(toolboxId int64, name string) (scenario *Scenario, err error) {
+	if err = checkScenarioName(name); err != nil {
+		return
+	}
+	filename := this_.getScenarioDir(toolboxId) + name + ".json"
+	ex, err := util.PathExists(filename)
+	if err != nil {
+		return
+	}
+	if !ex {
+		err = errors.New("scenario [" + name + "] not found")
+		return
+	}
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+	scenario = &Scenario{}
+	err = json.Unmarshal(bs, scenario)
+	return
+}
+
+// getRequestScenario 获取 请求 中 的 场景，未 传入 时 读取 已 保存 的 场景
+func (this_ *api) getRequestScenario(request *ScenarioRequest) (scenario *Scenario, err error) {
+	scenario = request.Scenario
+	if scenario == nil {
+		scenario, err = this_.loadScenario(request.ToolboxId, request.ScenarioName)
+		if err != nil {
+			return
+		}
+	}
+	if scenario.Name == "" {
+		scenario.Name = request.ScenarioName
+	}
+	request.ScenarioName = scenario.Name
+	err = checkScenarioName(scenario.Name)
+	return
+}
+
+func (this_ *api) scenarioList(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ScenarioRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	dir := this_.getScenarioDir(request.ToolboxId)
+	var list []*Scenario
+	res = list
+	if ex, _ := util.PathExists(dir); !ex {
+		return
+	}
+	fileList, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, f := range fileList {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		var scenario *Scenario
+		scenario, err = this_.loadScenario(request.ToolboxId, strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil {
+			return
+		}
+		list = append(list, scenario)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+	res = list
+	return
+}
+
+func (this_ *api) scenarioSave(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ScenarioRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	scenario := request.Scenario
+	if scenario == nil {
+		err = errors.New("scenario is empty")
+		return
+	}
+	if err = checkScenarioName(scenario.Name); err != nil {
+		return
+	}
+	if _, err = newScenarioRunner(nil, scenario); err != nil {
+		return
+	}
+	dir := this_.getScenarioDir(request.ToolboxId)
+	if ex, _ := util.PathExists(dir); !ex {
+		if err = os.MkdirAll(dir, fs.ModePerm); err != nil {
+			return
+		}
+	}
+	// 重命名 时 删除 旧 文件
+	if request.ScenarioName != "" && request.ScenarioName != scenario.Name {
+		if err = checkScenarioName(request.ScenarioName); err != nil {
+			return
+		}
+		_ = os.Remove(dir + request.ScenarioName + ".json")
+	}
+	scenario.UpdateTime = time.Now().UnixMilli()
+	bs, err := json.MarshalIndent(scenario, "", "  ")
+	if err != nil {
+		return
+	}
+	err = util.WriteFile(dir+scenario.Name+".json", bs)
+	if err != nil {
+		return
+	}
+	res = scenario
+	return
+}
+
+func (this_ *api) scenarioDelete(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ScenarioRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if err = checkScenarioName(request.ScenarioName); err != nil {
+		return
+	}
+	filename := this_.getScenarioDir(request.ToolboxId) + request.ScenarioName + ".json"
+	if ex, _ := util.PathExists(filename); ex {
+		err = os.Remove(filename)
+	}
+	return
+}
+
+// scenarioRun 功能 测试，执行 一次 场景 并 返回 各 步骤 的 响应 和 断言 结果
+func (this_ *api) scenarioRun(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getOrCreateWorkspace(config)
+	if err != nil {
+		return
+	}
+
+	request := &ScenarioRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	scenario, err := this_.getRequestScenario(request)
+	if err != nil {
+		return
+	}
+	runner, err := newScenarioRunner(service, scenario)
+	if err != nil {
+		return
+	}
+	argFormat_, err := newScenarioArgFormat()
+	if err != nil {
+		err = errors.New("newArgFormat error:" + err.Error())
+		return
+	}
+	client, err := NewClient(&request.BaseRequest)
+	if err != nil {
+		err = errors.New("NewClient error:" + err.Error())
+		return
+	}
+	defer func() {
+		_ = client.TTransport.Close()
+	}()
+
+	res = runner.run(argFormat_, client, nil)
+	return
+}
+
+// scenarioInvoke 压测 场景，按 Worker、Frequency、Duration 执行，报告 通过 invokeReports 等 接口 传入 scenarioName 查询
+func (this_ *api) scenarioInvoke(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getOrCreateWorkspace(config)
+	if err != nil {
+		return
+	}
+
+	request := &ScenarioRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	scenario, err := this_.getRequestScenario(request)
+	if err != nil {
+		return
+	}
+	runner, err := newScenarioRunner(service, scenario)
+	if err != nil {
+		return
+	}
+
+	executor := &scenarioExecutor{
+		invokeExecutor: &invokeExecutor{
+			BaseRequest:   &request.BaseRequest,
+			workerClient:  make(map[int]*thrift.ServiceClient),
+			service:       service,
+			scenarioCount: newScenarioCount(scenario),
+		},
+		runner:       runner,
+		workerFormat: make(map[int]*loadtask.ArgFormat),
+	}
+	data := map[string]interface{}{}
+	res = data
+	data["start"] = time.Now().UnixMilli()
+	t, err := this_.startTask(executor.invokeExecutor, executor)
+	if err != nil {
+		return
+	}
+	data["taskKey"] = t.Key
+	return
+}
@@ -0,0 +1,111 @@
+package module_thrift
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewScenarioRunner(t *testing.T) {
+	for _, one := range []struct {
+		scenario *Scenario
+		error    string
+	}{
+		{nil, "empty"},
+		{&Scenario{}, "empty"},
+		{&Scenario{Steps: []*ScenarioStep{{Name: "a"}, {Name: "a"}}}, "already exists"},
+		// 未 命名 的 步骤 使用 step{序号}
+		{&Scenario{Steps: []*ScenarioStep{{Name: "step2"}, {}}}, "already exists"},
+		{&Scenario{Steps: []*ScenarioStep{{Args: []string{"{a"}}}}, "step [step1]"},
+		{&Scenario{Steps: []*ScenarioStep{{Args: []string{"a", `{"b":1}`}}, {Name: "b"}}}, ""},
+	} {
+		runner, err := newScenarioRunner(nil, one.scenario)
+		if one.error != "" {
+			if err == nil || !strings.Contains(err.Error(), one.error) {
+				t.Errorf("scenario %+v expect error %s, got %v", one.scenario, one.error, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(runner.stepArgs) != 2 || len(runner.stepArgs[0]) != 2 || len(runner.stepArgs[1]) != 0 {
+			t.Errorf("step args error, got %v", runner.stepArgs)
+		}
+	}
+}
+
+func TestCheckAssertion(t *testing.T) {
+	format, err := newScenarioArgFormat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := toJSONValue(map[string]interface{}{
+		"args":   []interface{}{1},
+		"result": map[string]interface{}{"code": 0, "name": "abc", "tags": []string{"x", "y"}},
+		"error":  "",
+	})
+	steps := map[string]interface{}{"login": map[string]interface{}{"result": "t1"}}
+	for _, one := range []struct {
+		assertion *Assertion
+		success   bool
+	}{
+		{&Assertion{Type: "jsonPath", Path: "$.result.code", Expect: 0}, true},
+		{&Assertion{Type: "jsonPath", Path: "$.result.code", Expect: 1}, false},
+		{&Assertion{Type: "jsonPath", Path: "$.result.code", Operator: ">=", Expect: 0}, true},
+		{&Assertion{Type: "jsonPath", Path: "$.result.tags[*]", Expect: "y"}, true},
+		{&Assertion{Type: "jsonPath", Path: "$.result.name", Operator: "exists"}, true},
+		{&Assertion{Type: "jsonPath", Path: "$.result.missing", Operator: "exists"}, false},
+		{&Assertion{Type: "regex", Pattern: `"name":"a`}, true},
+		{&Assertion{Type: "regex", Path: "$.result.name", Pattern: "^ab"}, true},
+		{&Assertion{Type: "regex", Path: "$.result.name", Pattern: "^b"}, false},
+		{&Assertion{Type: "regex", Pattern: "("}, false},
+		{&Assertion{Type: "script", Script: `result.code == 0 && steps.login.result == "t1"`}, true},
+		{&Assertion{Type: "script", Script: `response.args.length == 2`}, false},
+		{&Assertion{Type: "script", Script: `a b`}, false},
+		{&Assertion{Type: "unknown"}, false},
+	} {
+		res := checkAssertion(format, one.assertion, response, steps)
+		if res.Success != one.success {
+			t.Errorf("assertion %+v expect %v, got %v %s", one.assertion, one.success, res.Success, res.Message)
+		}
+		if !res.Success && res.Message == "" {
+			t.Errorf("assertion %+v expect fail message", one.assertion)
+		}
+	}
+}
+
+func TestScenarioCount(t *testing.T) {
+	count := newScenarioCount(&Scenario{Steps: []*ScenarioStep{{Name: "login"}, {MethodName: "get"}}})
+	if count.Steps[1].Name != "step2" || count.Steps[1].MethodName != "get" {
+		t.Errorf("step count error, got %+v", count.Steps[1])
+	}
+	count.add(&ScenarioResult{Success: true, Steps: []*ScenarioStepResult{
+		{Success: true, Assertions: []*AssertionResult{{Success: true}}},
+		{Success: true},
+	}})
+	count.add(&ScenarioResult{Steps: []*ScenarioStepResult{
+		{Assertions: []*AssertionResult{{Success: true}, {Message: "assert fail"}}},
+		{Skipped: true},
+	}})
+	count.add(&ScenarioResult{Steps: []*ScenarioStepResult{
+		{Error: "send error"},
+		{Skipped: true},
+	}})
+
+	summary := count.summary()
+	if summary.Count != 3 || summary.FailCount != 2 || summary.AssertCount != 3 || summary.AssertFail != 1 {
+		t.Errorf("summary error, got %+v", summary)
+	}
+	login := summary.Steps[0]
+	if login.Count != 3 || login.ErrorCount != 1 || login.AssertCount != 3 || login.AssertFail != 1 || login.LastFail != "send error" {
+		t.Errorf("login count error, got %+v", login)
+	}
+	if summary.Steps[1].Count != 1 || summary.Steps[1].SkipCount != 2 {
+		t.Errorf("step2 count error, got %+v", summary.Steps[1])
+	}
+	// summary 返回 副本
+	summary.Steps[0].Count = 100
+	if count.Steps[0].Count != 3 {
+		t.Errorf("summary expect copy")
+	}
+}
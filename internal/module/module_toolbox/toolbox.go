@@ -177,6 +177,18 @@ func (this_ *ToolboxService) GetUserToolboxByName(toolboxType string, name strin
 	return
 }
 
+// QueryByType 根据类型 查询 未删除 的 工具
+func (this_ *ToolboxService) QueryByType(toolboxType string) (res []*ToolboxModel, err error) {
+
+	sql := `SELECT toolboxId,toolboxType,name,userId FROM ` + TableToolbox + ` WHERE deleted=2 AND toolboxType = ? ORDER BY toolboxId ASC `
+	err = this_.DatabaseWorker.Query(sql, []interface{}{toolboxType}, &res)
+	if err != nil {
+		this_.Logger.Error("QueryByType Error", zap.Error(err))
+		return
+	}
+	return
+}
+
 // Insert 新增
 func (this_ *ToolboxService) Insert(toolbox *ToolboxModel) (rowsAffected int64, err error) {
 
@@ -103,6 +103,34 @@ func (this_ *ToolboxService) FormatOption(toolboxData *ToolboxModel) (err error)
 			}
 		}
 		break
+	case etcdWorker_:
+		if optionMap["password"] != nil {
+			str, ok := optionMap["password"].(string)
+			if ok {
+				optionMap["password"] = this_.EncryptOptionAttr(str)
+			} else {
+				delete(optionMap, "password")
+			}
+		}
+		break
+	case consulWorker_:
+		if optionMap["token"] != nil {
+			str, ok := optionMap["token"].(string)
+			if ok {
+				optionMap["token"] = this_.EncryptOptionAttr(str)
+			} else {
+				delete(optionMap, "token")
+			}
+		}
+		if optionMap["password"] != nil {
+			str, ok := optionMap["password"].(string)
+			if ok {
+				optionMap["password"] = this_.EncryptOptionAttr(str)
+			} else {
+				delete(optionMap, "password")
+			}
+		}
+		break
 	case elasticsearchWorker_:
 		if optionMap["password"] != nil {
 			str, ok := optionMap["password"].(string)
@@ -114,6 +142,34 @@ func (this_ *ToolboxService) FormatOption(toolboxData *ToolboxModel) (err error)
 		}
 		break
 	case kafkaWorker_:
+		if optionMap["password"] != nil {
+			str, ok := optionMap["password"].(string)
+			if ok {
+				optionMap["password"] = this_.EncryptOptionAttr(str)
+			} else {
+				delete(optionMap, "password")
+			}
+		}
+		if optionMap["schemaRegistryPassword"] != nil {
+			str, ok := optionMap["schemaRegistryPassword"].(string)
+			if ok {
+				optionMap["schemaRegistryPassword"] = this_.EncryptOptionAttr(str)
+			} else {
+				delete(optionMap, "schemaRegistryPassword")
+			}
+		}
+		break
+	case rabbitmqWorker_:
+		if optionMap["password"] != nil {
+			str, ok := optionMap["password"].(string)
+			if ok {
+				optionMap["password"] = this_.EncryptOptionAttr(str)
+			} else {
+				delete(optionMap, "password")
+			}
+		}
+		break
+	case mqttWorker_:
 		if optionMap["password"] != nil {
 			str, ok := optionMap["password"].(string)
 			if ok {
@@ -196,6 +252,42 @@ func (this_ *ToolboxService) CheckPower(requestBean *base.RequestBean) (err erro
 	return
 }
 
+// GetRequestToolbox 获取 请求 中 toolboxId 对应 的 工具 并 校验 权限
+// 用于 按 toolboxId 保存 查询 服务端 数据 的 接口，这类 接口 不 支持 未 保存 的 测试 工具
+func (this_ *ToolboxService) GetRequestToolbox(requestBean *base.RequestBean, c *gin.Context) (toolboxModel *ToolboxModel, err error) {
+	bindConfigRequest := &BindConfigRequest{}
+	if !base.RequestJSON(bindConfigRequest, c) {
+		err = errors.New("request body bind error")
+		return
+	}
+	if bindConfigRequest.ToolboxToTest == "1" {
+		err = errors.New("测试工具不支持该操作，请先保存工具")
+		return
+	}
+	if bindConfigRequest.ToolboxId == 0 {
+		err = errors.New("toolboxId is empty")
+		return
+	}
+	err = this_.initExtent(requestBean, c)
+	if err != nil {
+		return
+	}
+	if v := requestBean.GetExtend("toolboxModel"); v != nil {
+		toolboxModel = v.(*ToolboxModel)
+	}
+	if toolboxModel == nil || toolboxModel.ToolboxId != bindConfigRequest.ToolboxId {
+		err = errors.New("工具[" + util.GetStringValue(bindConfigRequest.ToolboxId) + "]不存在")
+		toolboxModel = nil
+		return
+	}
+	err = this_.CheckToolboxPower(requestBean, toolboxModel)
+	if err != nil {
+		toolboxModel = nil
+		return
+	}
+	return
+}
+
 func (this_ *ToolboxService) initExtent(requestBean *base.RequestBean, c *gin.Context) (err error) {
 	if requestBean.GetExtend("toolboxModel") != nil {
 		return
@@ -382,10 +474,16 @@ var (
 	sshWorker_           = sshWorker()
 	redisWorker_         = redisWorker()
 	zookeeperWorker_     = zookeeperWorker()
+	etcdWorker_          = etcdWorker()
+	consulWorker_        = consulWorker()
 	elasticsearchWorker_ = elasticsearchWorker()
 	kafkaWorker_         = kafkaWorker()
+	rabbitmqWorker_      = rabbitmqWorker()
+	mqttWorker_          = mqttWorker()
 	mongodbWorker_       = mongodbWorker()
 	netConnWorker_       = netConn()
+	httpWorker_          = httpWorker()
+	grpcWorker_          = grpcWorker()
 
 	thriftWorker_ = thriftWorker()
 	makerWorker_  = makerWorker()
@@ -402,10 +500,16 @@ func initToolboxTypes() {
 	*toolboxTypes = append(*toolboxTypes, sshWorker_)
 	*toolboxTypes = append(*toolboxTypes, redisWorker_)
 	*toolboxTypes = append(*toolboxTypes, zookeeperWorker_)
+	*toolboxTypes = append(*toolboxTypes, etcdWorker_)
+	*toolboxTypes = append(*toolboxTypes, consulWorker_)
 	*toolboxTypes = append(*toolboxTypes, elasticsearchWorker_)
 	*toolboxTypes = append(*toolboxTypes, kafkaWorker_)
+	*toolboxTypes = append(*toolboxTypes, rabbitmqWorker_)
+	*toolboxTypes = append(*toolboxTypes, mqttWorker_)
 	*toolboxTypes = append(*toolboxTypes, mongodbWorker_)
 	*toolboxTypes = append(*toolboxTypes, netConnWorker_)
+	*toolboxTypes = append(*toolboxTypes, httpWorker_)
+	*toolboxTypes = append(*toolboxTypes, grpcWorker_)
 	*toolboxTypes = append(*toolboxTypes, thriftWorker_)
 	if maker.HasMaker {
 		*toolboxTypes = append(*toolboxTypes, makerWorker_)
@@ -449,6 +553,7 @@ func databaseWorker() *ToolboxType {
 						{Text: "Postgresql", Value: "postgresql"},
 						{Text: "OpenGauss", Value: "opengauss"},
 						{Text: "GBase", Value: "gbase"},
+						{Text: "ClickHouse", Value: "clickhouse"},
 						{Text: "Odbc", Value: "odbc"},
 					},
 					Rules: []*form.Rule{
@@ -456,7 +561,7 @@ func databaseWorker() *ToolboxType {
 					},
 				},
 				{
-					Label: "SSH隧道", Name: "sshToolboxId", Type: "select", VIf: `type == 'mysql' || type == 'kingbase' || type == 'postgresql' || type == 'opengauss'`,
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select", VIf: `type == 'mysql' || type == 'kingbase' || type == 'postgresql' || type == 'opengauss' || type == 'clickhouse'`,
 					OptionsName: "sshToolboxOptions",
 					Rules:       []*form.Rule{},
 				},
@@ -476,7 +581,7 @@ func databaseWorker() *ToolboxType {
 				},
 				{Label: "Username", Name: "username", VIf: `type != 'sqlite' && type != 'odbc' && type != 'gbase'`, Col: 12},
 				{Label: "Password", Name: "password", Type: "password", VIf: `type != 'sqlite' && type != 'odbc' && type != 'gbase'`, Col: 12, ShowPlaintextBtn: true},
-				{Label: "Database", Name: "database", VIf: `type == 'mysql'`},
+				{Label: "Database", Name: "database", VIf: `type == 'mysql' || type == 'clickhouse'`},
 				{Label: "SID", Name: "sid", VIf: `type == 'oracle'`,
 					Rules: []*form.Rule{
 						{Required: true, Message: "SID不能为空"},
@@ -511,7 +616,7 @@ func databaseWorker() *ToolboxType {
 						{Text: "OpenGauss", Value: "opengauss"},
 					},
 				},
-				{Label: "TLS", Name: "tlsConfig", Type: "select", VIf: `type == 'mysql'`, DefaultValue: "", Placeholder: "不配置",
+				{Label: "TLS", Name: "tlsConfig", Type: "select", VIf: `type == 'mysql' || type == 'clickhouse'`, DefaultValue: "", Placeholder: "不配置",
 					Options: []*form.Option{
 						{Text: "不配置", Value: ""},
 						{Text: "Skip Verify", Value: "skip-verify"},
@@ -519,9 +624,9 @@ func databaseWorker() *ToolboxType {
 						{Text: "自定义", Value: "custom"},
 					},
 				},
-				{Label: "TLS RootCert", Name: "tlsRootCert", Type: "file", VIf: `type == 'mysql' && tlsConfig == 'custom'`},
-				{Label: "TLS Client Cert", Name: "tlsClientCert", Type: "file", VIf: `type == 'mysql' && tlsConfig == 'custom'`},
-				{Label: "TLS Client Key", Name: "tlsClientKey", Type: "file", VIf: `type == 'mysql' && tlsConfig == 'custom'`},
+				{Label: "TLS RootCert", Name: "tlsRootCert", Type: "file", VIf: `(type == 'mysql' || type == 'clickhouse') && tlsConfig == 'custom'`},
+				{Label: "TLS Client Cert", Name: "tlsClientCert", Type: "file", VIf: `(type == 'mysql' || type == 'clickhouse') && tlsConfig == 'custom'`},
+				{Label: "TLS Client Key", Name: "tlsClientKey", Type: "file", VIf: `(type == 'mysql' || type == 'clickhouse') && tlsConfig == 'custom'`},
 			},
 		},
 	}
@@ -595,6 +700,17 @@ func kafkaWorker() *ToolboxType {
 				{Label: "用户名", Name: "username", Col: 12},
 				{Label: "密码", Name: "password", Col: 12, ShowPlaintextBtn: true},
 				{Label: "Cert", Name: "certPath", Type: "file", Placeholder: "请上传Cert"},
+				{Label: "Kafka版本（如2.8.0，为空自动识别）", Name: "version"},
+				{Label: "Schema Registry地址（http://127.0.0.1:8081）", Name: "schemaRegistryUrl"},
+				{Label: "Schema Registry用户名", Name: "schemaRegistryUsername", Col: 12},
+				{Label: "Schema Registry密码", Name: "schemaRegistryPassword", Col: 12, ShowPlaintextBtn: true},
+				{
+					Label: "Proto文件", Name: "protoFiles", Type: "list",
+					Fields: []*form.Field{
+						{Label: "Import路径（为空使用文件名）", Name: "name"},
+						{Label: "Proto", Name: "path", Type: "file", Placeholder: "请上传.proto文件"},
+					},
+				},
 			},
 		},
 		OtherForm: map[string]*form.Form{
@@ -690,11 +806,16 @@ func kafkaWorker() *ToolboxType {
 						Options: []*form.Option{
 							{Text: "String", Value: "string"},
 							{Text: "Long（int64）", Value: "long"},
+							{Text: "Schema Registry（Avro/Protobuf/JSON）", Value: "schemaRegistry"},
+							{Text: "Protobuf（上传的Proto文件）", Value: "protobuf"},
 						},
 						Rules: []*form.Rule{
 							{Required: true, Message: "KeyType不能为空"},
 						},
 					},
+					{
+						Label: "Key消息类型（Protobuf）", Name: "keyMessageName", VIf: `keyType == 'protobuf' || keyType == 'schemaRegistry'`,
+					},
 					{
 						Label: "Key", Name: "key",
 					},
@@ -703,11 +824,16 @@ func kafkaWorker() *ToolboxType {
 						Options: []*form.Option{
 							{Text: "String", Value: "string"},
 							{Text: "Long（int64）", Value: "long"},
+							{Text: "Schema Registry（Avro/Protobuf/JSON）", Value: "schemaRegistry"},
+							{Text: "Protobuf（上传的Proto文件）", Value: "protobuf"},
 						},
 						Rules: []*form.Rule{
 							{Required: true, Message: "ValueType不能为空"},
 						},
 					},
+					{
+						Label: "Value消息类型（Protobuf）", Name: "valueMessageName", VIf: `valueType == 'protobuf' || valueType == 'schemaRegistry'`,
+					},
 					{
 						Label: "Value", Name: "value", Type: "textarea",
 						Rules: []*form.Rule{
@@ -816,6 +942,150 @@ func zookeeperWorker() *ToolboxType {
 	return worker_
 }
 
+func etcdWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "etcd",
+		Text: "Etcd",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules:       []*form.Rule{},
+				},
+				{
+					Label: "连接地址（127.0.0.1:2379，多个使用“,”分隔）", Name: "address", DefaultValue: "127.0.0.1:2379",
+					Rules: []*form.Rule{
+						{Required: true, Message: "连接地址不能为空"},
+					},
+				},
+				{Label: "Username", Name: "username", Col: 12},
+				{Label: "Password", Name: "password", Type: "password", Col: 12, ShowPlaintextBtn: true},
+				{Label: "连接超时（毫秒）", Name: "dialTimeout", Col: 12, IsNumber: true, DefaultValue: 10000},
+				{Label: "TLS", Name: "tls", Type: "switch", Col: 12, DefaultValue: false},
+				{Label: "跳过证书校验", Name: "insecureSkipVerify", Type: "switch", Col: 12, DefaultValue: false},
+				{Label: "CA证书", Name: "caCert", Type: "file", Placeholder: "请上传CA证书"},
+				{Label: "客户端证书", Name: "clientCert", Type: "file", Placeholder: "请上传客户端证书", Col: 12},
+				{Label: "客户端私钥", Name: "clientKey", Type: "file", Placeholder: "请上传客户端私钥", Col: 12},
+			},
+		},
+	}
+
+	return worker_
+}
+
+func consulWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "consul",
+		Text: "Consul",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules:       []*form.Rule{},
+				},
+				{
+					Label: "连接地址（127.0.0.1:8500）", Name: "address", DefaultValue: "127.0.0.1:8500",
+					Rules: []*form.Rule{
+						{Required: true, Message: "连接地址不能为空"},
+					},
+				},
+				{
+					Label: "协议", Name: "scheme", Type: "select", DefaultValue: "http", Col: 12,
+					Options: []*form.Option{
+						{Text: "HTTP", Value: "http"},
+						{Text: "HTTPS", Value: "https"},
+					},
+				},
+				{Label: "数据中心（为空使用Agent所在数据中心）", Name: "datacenter", Col: 12},
+				{Label: "ACL Token", Name: "token", Type: "password", ShowPlaintextBtn: true},
+				{Label: "Basic认证用户名", Name: "username", Col: 12},
+				{Label: "Basic认证密码", Name: "password", Type: "password", Col: 12, ShowPlaintextBtn: true},
+				{Label: "跳过证书校验", Name: "insecureSkipVerify", Type: "switch", DefaultValue: false},
+				{Label: "CA证书", Name: "caCert", Type: "file", Placeholder: "请上传CA证书"},
+				{Label: "客户端证书", Name: "clientCert", Type: "file", Placeholder: "请上传客户端证书", Col: 12},
+				{Label: "客户端私钥", Name: "clientKey", Type: "file", Placeholder: "请上传客户端私钥", Col: 12},
+			},
+		},
+	}
+
+	return worker_
+}
+
+func rabbitmqWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "rabbitmq",
+		Text: "RabbitMQ",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules:       []*form.Rule{},
+				},
+				{
+					Label: "AMQP地址（127.0.0.1:5672）", Name: "address", DefaultValue: "127.0.0.1:5672",
+					Rules: []*form.Rule{
+						{Required: true, Message: "AMQP地址不能为空"},
+					},
+				},
+				{Label: "管理接口地址（http://127.0.0.1:15672，用于查询交换机、队列、绑定）", Name: "managementAddress", DefaultValue: "http://127.0.0.1:15672"},
+				{Label: "Virtual Host", Name: "vhost", DefaultValue: "/"},
+				{Label: "Username", Name: "username", Col: 12},
+				{Label: "Password", Name: "password", Type: "password", Col: 12, ShowPlaintextBtn: true},
+				{Label: "TLS（AMQPS）", Name: "tls", Type: "switch", Col: 12, DefaultValue: false},
+				{Label: "跳过证书校验", Name: "insecureSkipVerify", Type: "switch", Col: 12, DefaultValue: false},
+				{Label: "CA证书", Name: "caCert", Type: "file", Placeholder: "请上传CA证书"},
+				{Label: "客户端证书", Name: "clientCert", Type: "file", Placeholder: "请上传客户端证书", Col: 12},
+				{Label: "客户端私钥", Name: "clientKey", Type: "file", Placeholder: "请上传客户端私钥", Col: 12},
+			},
+		},
+	}
+
+	return worker_
+}
+
+func mqttWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "mqtt",
+		Text: "MQTT",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules:       []*form.Rule{},
+				},
+				{
+					Label: "连接地址（tcp://127.0.0.1:1883，支持tcp、ssl、ws、wss）", Name: "address", DefaultValue: "tcp://127.0.0.1:1883",
+					Rules: []*form.Rule{
+						{Required: true, Message: "连接地址不能为空"},
+					},
+				},
+				{Label: "客户端ID前缀", Name: "clientId", Col: 12},
+				{
+					Label: "协议版本", Name: "protocolVersion", Type: "select", DefaultValue: "3.1.1", Col: 12,
+					Options: []*form.Option{
+						{Text: "MQTT 3.1.1", Value: "3.1.1"},
+						{Text: "MQTT 3.1", Value: "3.1"},
+					},
+				},
+				{Label: "Username", Name: "username", Col: 12},
+				{Label: "Password", Name: "password", Type: "password", Col: 12, ShowPlaintextBtn: true},
+				{Label: "连接超时（毫秒）", Name: "connectTimeout", Col: 12, IsNumber: true, DefaultValue: 10000},
+				{Label: "TLS（地址未指定协议时使用ssl）", Name: "tls", Type: "switch", Col: 12, DefaultValue: false},
+				{Label: "跳过证书校验", Name: "insecureSkipVerify", Type: "switch", DefaultValue: false},
+				{Label: "CA证书", Name: "caCert", Type: "file", Placeholder: "请上传CA证书"},
+				{Label: "客户端证书", Name: "clientCert", Type: "file", Placeholder: "请上传客户端证书", Col: 12},
+				{Label: "客户端私钥", Name: "clientKey", Type: "file", Placeholder: "请上传客户端私钥", Col: 12},
+			},
+		},
+	}
+
+	return worker_
+}
+
 func mongodbWorker() *ToolboxType {
 	worker_ := &ToolboxType{
 		Name: "mongodb",
@@ -864,6 +1134,69 @@ func netConn() *ToolboxType {
 
 	return worker_
 }
+
+func httpWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "http",
+		Text: "HTTP",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules:       []*form.Rule{},
+					Col:         12,
+				},
+				{Label: "基础地址（http://127.0.0.1:8080）", Name: "baseUrl", Col: 12},
+				{Label: "超时时间（毫秒）", Name: "timeout", Col: 12, IsNumber: true, DefaultValue: 60000},
+				{Label: "跳过证书校验", Name: "insecureSkipVerify", Type: "switch", Col: 12, DefaultValue: false},
+			},
+		},
+	}
+
+	return worker_
+}
+
+func grpcWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "grpc",
+		Text: "gRPC",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules:       []*form.Rule{},
+					Col:         12,
+				},
+				{Label: "连接地址（127.0.0.1:9090）", Name: "address", DefaultValue: "127.0.0.1:9090",
+					Rules: []*form.Rule{
+						{Required: true, Message: "连接地址不能为空"},
+					},
+				},
+				{
+					Label: "服务来源", Name: "source", Type: "select", DefaultValue: "reflection", Col: 12,
+					Options: []*form.Option{
+						{Text: "服务端反射", Value: "reflection"},
+						{Text: "Proto文件", Value: "proto"},
+					},
+				},
+				{Label: "超时时间（毫秒）", Name: "timeout", Col: 12, IsNumber: true, DefaultValue: 10000},
+				{Label: "Proto文件目录（服务来源为Proto文件时，同时作为Import目录）", Name: "protoDir"},
+				{Label: "Proto", Name: "protoFile", Type: "file", Placeholder: "请上传.proto文件"},
+				{Label: "TLS", Name: "tls", Type: "switch", Col: 12, DefaultValue: false},
+				{Label: "跳过证书校验", Name: "insecureSkipVerify", Type: "switch", Col: 12, DefaultValue: false},
+				{Label: "ServerName", Name: "serverName"},
+				{Label: "CA证书", Name: "caCert", Type: "file", Placeholder: "请上传CA证书"},
+				{Label: "客户端证书", Name: "clientCert", Type: "file", Placeholder: "请上传客户端证书", Col: 12},
+				{Label: "客户端私钥", Name: "clientKey", Type: "file", Placeholder: "请上传客户端私钥", Col: 12},
+			},
+		},
+	}
+
+	return worker_
+}
+
 func thriftWorker() *ToolboxType {
 	worker_ := &ToolboxType{
 		Name: "thrift",
@@ -1,6 +1,7 @@
 package module_zookeeper
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/team-ide/go-tool/util"
 	"github.com/team-ide/go-tool/zookeeper"
@@ -30,6 +31,14 @@ var (
 	getChildrenPower = base.AppendPower(&base.PowerAction{Action: "getChildren", Text: "Zookeeper查询子节点", ShouldLogin: true, StandAlone: true, Parent: Power})
 	deletePower      = base.AppendPower(&base.PowerAction{Action: "delete", Text: "Zookeeper删除节点", ShouldLogin: true, StandAlone: true, Parent: Power})
 	closePower       = base.AppendPower(&base.PowerAction{Action: "close", Text: "Zookeeper关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	watchKeyPower       = base.AppendPower(&base.PowerAction{Action: "watch/key", Text: "Zookeeper监听Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	watchWebsocketPower = base.AppendPower(&base.PowerAction{Action: "watch/websocket", Text: "Zookeeper监听WebSocket", ShouldLogin: true, StandAlone: true, Parent: Power})
+	watchClosePower     = base.AppendPower(&base.PowerAction{Action: "watch/close", Text: "Zookeeper监听关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+	getAclPower         = base.AppendPower(&base.PowerAction{Action: "getAcl", Text: "Zookeeper查询权限", ShouldLogin: true, StandAlone: true, Parent: Power})
+	setAclPower         = base.AppendPower(&base.PowerAction{Action: "setAcl", Text: "Zookeeper设置权限", ShouldLogin: true, StandAlone: true, Parent: Power})
+	exportPower         = base.AppendPower(&base.PowerAction{Action: "export", Text: "Zookeeper导出节点", ShouldLogin: true, StandAlone: true, Parent: Power})
+	importPower         = base.AppendPower(&base.PowerAction{Action: "import", Text: "Zookeeper导入节点", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
 func (this_ *api) GetApis() (apis []*base.ApiWorker) {
@@ -41,6 +50,14 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: deletePower, Do: this_.delete})
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
 
+	apis = append(apis, &base.ApiWorker{Power: watchKeyPower, Do: this_.watchKey})
+	apis = append(apis, &base.ApiWorker{Power: watchWebsocketPower, Do: watcherCache.Websocket, IsWebSocket: true})
+	apis = append(apis, &base.ApiWorker{Power: watchClosePower, Do: watcherCache.Close})
+	apis = append(apis, &base.ApiWorker{Power: getAclPower, Do: this_.getAcl})
+	apis = append(apis, &base.ApiWorker{Power: setAclPower, Do: this_.setAcl})
+	apis = append(apis, &base.ApiWorker{Power: exportPower, Do: this_.export})
+	apis = append(apis, &base.ApiWorker{Power: importPower, Do: this_.importData})
+
 	return
 }
 
@@ -67,37 +84,16 @@ func getService(zkConfig *zookeeper.Config, sshConfig *ssh.Config) (res zookeepe
 	}
 	var serviceInfo *base.ServiceInfo
 	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
-		var s zookeeper.IService
-		if sshConfig != nil {
-			var sshClient *goSSH.Client
-			sshClient, err = ssh.NewClient(*sshConfig)
-			if err != nil {
-				util.Logger.Error("getZKService ssh NewClient error", zap.Any("key", key), zap.Error(err))
-				return
-			}
-			zkConfig.SSHClient = sshClient
-		}
-		s, err = zookeeper.New(zkConfig)
-		if err != nil {
-			util.Logger.Error("getZKService error", zap.Any("key", key), zap.Error(err))
-			if s != nil {
-				s.Close()
-			}
-			return
-		}
-		_, err = s.Exists("/")
+		s, stop, err := newService(zkConfig, sshConfig)
 		if err != nil {
 			util.Logger.Error("getZKService error", zap.Any("key", key), zap.Error(err))
-			if s != nil {
-				s.Close()
-			}
 			return
 		}
 		res = &base.ServiceInfo{
 			WaitTime:    10 * 60 * 1000,
 			LastUseTime: util.GetNowMilli(),
 			Service:     s,
-			Stop:        s.Close,
+			Stop:        stop,
 		}
 		return
 	})
@@ -109,9 +105,41 @@ func getService(zkConfig *zookeeper.Config, sshConfig *ssh.Config) (res zookeepe
 	return
 }
 
+// newService 创建 连接 并 检查 可用，stop 关闭 连接 及 SSH 隧道，监听 会话 使用 独立 的 连接
+func newService(zkConfig *zookeeper.Config, sshConfig *ssh.Config) (s zookeeper.IService, stop func(), err error) {
+	var sshClient *goSSH.Client
+	if sshConfig != nil {
+		sshClient, err = ssh.NewClient(*sshConfig)
+		if err != nil {
+			return
+		}
+		zkConfig.SSHClient = sshClient
+	}
+	stop = func() {
+		if s != nil {
+			s.Close()
+		}
+		if sshClient != nil {
+			_ = sshClient.Close()
+		}
+	}
+	s, err = zookeeper.New(zkConfig)
+	if err == nil {
+		_, err = s.Exists("/")
+	}
+	if err != nil {
+		stop()
+		s, stop = nil, nil
+		return
+	}
+	return
+}
+
 type BaseRequest struct {
-	Path string `json:"path"`
-	Data string `json:"data"`
+	WorkerId string `json:"workerId"`
+	Path     string `json:"path"`
+	Data     string `json:"data"`
+	DryRun   bool   `json:"dryRun"` // 删除 时 只 统计 子树 节点 数，不 删除
 }
 
 func (this_ *api) check(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
@@ -261,12 +289,30 @@ func (this_ *api) delete(requestBean *base.RequestBean, c *gin.Context) (res int
 	if !base.RequestJSON(request, c) {
 		return
 	}
+	if request.Path == "/" || isSystemPath(request.Path) {
+		err = errors.New("path [" + request.Path + "] can not be deleted")
+		return
+	}
 	var isEx bool
 	isEx, err = service.Exists(request.Path)
 	if err != nil {
 		return
 	}
 	if isEx {
+		// 删除 包含 所有 子孙 节点，先 统计 节点 数 用于 确认
+		var count int
+		var paths []string
+		count, paths, err = countTree(service.GetConn(), request.Path)
+		if err != nil {
+			return
+		}
+		data := map[string]interface{}{}
+		data["count"] = count
+		res = data
+		if request.DryRun {
+			data["paths"] = paths
+			return
+		}
 		err = service.Delete(request.Path)
 		if err != nil {
 			return
@@ -276,5 +322,10 @@ func (this_ *api) delete(requestBean *base.RequestBean, c *gin.Context) (res int
 }
 
 func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	watcherCache.RemoveWorker(request.WorkerId)
 	return
 }
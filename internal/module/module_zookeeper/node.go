@@ -0,0 +1,278 @@
+package module_zookeeper
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-zookeeper/zk"
+	"github.com/team-ide/go-tool/zookeeper"
+	"net"
+	"sort"
+	"strings"
+	"teamide/pkg/base"
+)
+
+// 系统 节点，导出、导入、统计 时 跳过
+const systemPath = "/zookeeper"
+
+func joinPath(parent string, name string) string {
+	if parent == "/" {
+		return "/" + name
+	}
+	return parent + "/" + name
+}
+
+// isChildPath path 是否 为 parent 的 子孙 节点
+func isChildPath(parent string, path string) bool {
+	if parent == "/" {
+		return path != "/"
+	}
+	return strings.HasPrefix(path, parent+"/")
+}
+
+func isSystemPath(path string) bool {
+	return path == systemPath || isChildPath(systemPath, path)
+}
+
+// walkTree 先序 遍历 子树，on 返回 false 时 不 遍历 该 节点 的 子节点
+func walkTree(conn *zk.Conn, path string, on func(path string) (next bool, err error)) (err error) {
+	if isSystemPath(path) {
+		return
+	}
+	next, err := on(path)
+	if err != nil || !next {
+		return
+	}
+	children, _, err := conn.Children(path)
+	if err != nil {
+		if err == zk.ErrNoNode {
+			err = nil
+		}
+		return
+	}
+	sort.Strings(children)
+	for _, child := range children {
+		if err = walkTree(conn, joinPath(path, child), on); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Acl 节点 权限，Perms 为 cdrwa 的 组合
+type Acl struct {
+	Scheme   string `json:"scheme" yaml:"scheme"`
+	Id       string `json:"id" yaml:"id"`
+	Perms    string `json:"perms" yaml:"perms"`
+	Password string `json:"password,omitempty" yaml:"-"` // digest 的 明文 密码，不为空 时 按 id 中 的 用户名 生成 摘要
+}
+
+var permList = []struct {
+	Perm int32
+	Text string
+}{
+	{zk.PermCreate, "c"},
+	{zk.PermDelete, "d"},
+	{zk.PermRead, "r"},
+	{zk.PermWrite, "w"},
+	{zk.PermAdmin, "a"},
+}
+
+func permsText(perms int32) (res string) {
+	for _, one := range permList {
+		if perms&one.Perm != 0 {
+			res += one.Text
+		}
+	}
+	return
+}
+
+func parsePerms(text string) (perms int32, err error) {
+	for _, c := range strings.ToLower(text) {
+		var find bool
+		for _, one := range permList {
+			if string(c) == one.Text {
+				perms |= one.Perm
+				find = true
+				break
+			}
+		}
+		if !find {
+			err = errors.New("perms [" + text + "] invalid, please use cdrwa")
+			return
+		}
+	}
+	if perms == 0 {
+		err = errors.New("perms can not be empty")
+		return
+	}
+	return
+}
+
+func toAclList(list []zk.ACL) (res []*Acl) {
+	for _, one := range list {
+		res = append(res, &Acl{Scheme: one.Scheme, Id: one.ID, Perms: permsText(one.Perms)})
+	}
+	return
+}
+
+// toZkAclList 转换 并 校验 权限，支持 world、ip、digest，auth、sasl、x509 原样 设置
+func toZkAclList(list []*Acl) (res []zk.ACL, err error) {
+	if len(list) == 0 {
+		err = errors.New("acl can not be empty")
+		return
+	}
+	for _, one := range list {
+		var perms int32
+		if perms, err = parsePerms(one.Perms); err != nil {
+			return
+		}
+		acl := zk.ACL{Perms: perms, Scheme: one.Scheme, ID: one.Id}
+		switch one.Scheme {
+		case "world":
+			if acl.ID == "" {
+				acl.ID = "anyone"
+			}
+			if acl.ID != "anyone" {
+				err = errors.New("world acl id must be anyone")
+				return
+			}
+		case "ip":
+			if net.ParseIP(acl.ID) == nil {
+				if _, _, e := net.ParseCIDR(acl.ID); e != nil {
+					err = errors.New("ip acl id [" + acl.ID + "] invalid, please use ip or ip/bits")
+					return
+				}
+			}
+		case "digest":
+			username := acl.ID
+			if index := strings.Index(username, ":"); index >= 0 {
+				username = username[:index]
+			}
+			if one.Password != "" {
+				acl = zk.DigestACL(perms, username, one.Password)[0]
+			} else if !strings.Contains(acl.ID, ":") {
+				err = errors.New("digest acl id must be username:digest, or set password")
+				return
+			}
+		case "auth", "sasl", "x509":
+		default:
+			err = errors.New("acl scheme [" + one.Scheme + "] not support")
+			return
+		}
+		res = append(res, acl)
+	}
+	return
+}
+
+type AclRequest struct {
+	Path       string `json:"path"`
+	Acl        []*Acl `json:"acl"`
+	AclVersion *int32 `json:"aclVersion"` // 为空 时 不 校验 版本
+	Recursive  bool   `json:"recursive"`  // 同时 设置 所有 子孙 节点
+}
+
+func (this_ *api) getAcl(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &AclRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	list, stat, err := service.GetConn().GetACL(request.Path)
+	if err != nil {
+		err = errors.New("path [" + request.Path + "] get acl error:" + err.Error())
+		return
+	}
+	data := map[string]interface{}{}
+	data["acl"] = toAclList(list)
+	data["stat"] = zookeeper.StatToInfo(stat)
+	res = data
+	return
+}
+
+func (this_ *api) setAcl(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &AclRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	acl, err := toZkAclList(request.Acl)
+	if err != nil {
+		return
+	}
+	conn := service.GetConn()
+	var version int32 = -1
+	if request.AclVersion != nil {
+		version = *request.AclVersion
+	}
+	paths := []string{request.Path}
+	if request.Recursive {
+		paths = nil
+		err = walkTree(conn, request.Path, func(path string) (next bool, err error) {
+			paths = append(paths, path)
+			next = true
+			return
+		})
+		if err != nil {
+			return
+		}
+	}
+	count, err := setAclPostOrder(paths, version, func(path string, version int32) (err error) {
+		_, err = conn.SetACL(path, acl, version)
+		return
+	})
+	if err != nil {
+		return
+	}
+	data := map[string]interface{}{}
+	data["count"] = count
+	res = data
+	return
+}
+
+// setAclPostOrder paths 为 先序 遍历 的 节点，按 后序 设置 权限，根 节点 最后 设置 并 校验 版本，避免 先 限制 父节点 后 无法 设置 子节点
+func setAclPostOrder(paths []string, version int32, set func(path string, version int32) error) (count int, err error) {
+	for i := len(paths) - 1; i >= 0; i-- {
+		v := int32(-1)
+		if i == 0 {
+			v = version
+		}
+		if err = set(paths[i], v); err != nil {
+			err = errors.New("path [" + paths[i] + "] set acl error:" + err.Error())
+			return
+		}
+		count++
+	}
+	return
+}
+
+// 删除 预览 最多 返回 的 节点 路径 数
+const deletePreviewSize = 100
+
+// countTree 统计 子树 节点 数，包含 当前 节点
+func countTree(conn *zk.Conn, path string) (count int, paths []string, err error) {
+	err = walkTree(conn, path, func(path string) (next bool, err error) {
+		count++
+		if len(paths) < deletePreviewSize {
+			paths = append(paths, path)
+		}
+		next = true
+		return
+	})
+	return
+}
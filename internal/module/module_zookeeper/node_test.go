@@ -0,0 +1,67 @@
+package module_zookeeper
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSetAclPostOrder(t *testing.T) {
+	// 先序 遍历 的 节点
+	paths := []string{"/a", "/a/b", "/a/b/c", "/a/d"}
+	var setPaths []string
+	var versions []int32
+	count, err := setAclPostOrder(paths, 3, func(path string, version int32) error {
+		setPaths = append(setPaths, path)
+		versions = append(versions, version)
+		return nil
+	})
+	if err != nil || count != 4 {
+		t.Fatalf("set acl expect count 4, got %d %v", count, err)
+	}
+	// 子节点 先 设置，根 节点 最后 设置 并 校验 版本
+	expect := []string{"/a/d", "/a/b/c", "/a/b", "/a"}
+	if !reflect.DeepEqual(setPaths, expect) {
+		t.Errorf("set acl order expect %v, got %v", expect, setPaths)
+	}
+	if !reflect.DeepEqual(versions, []int32{-1, -1, -1, 3}) {
+		t.Errorf("set acl versions expect root version only, got %v", versions)
+	}
+
+	setPaths = nil
+	count, err = setAclPostOrder(paths, -1, func(path string, version int32) error {
+		if path == "/a/b" {
+			return errors.New("no auth")
+		}
+		setPaths = append(setPaths, path)
+		return nil
+	})
+	if err == nil || err.Error() != "path [/a/b] set acl error:no auth" || count != 2 {
+		t.Errorf("set acl error expect stop at /a/b, got %d %v", count, err)
+	}
+	if !reflect.DeepEqual(setPaths, []string{"/a/d", "/a/b/c"}) {
+		t.Errorf("root acl expect not set after error, got %v", setPaths)
+	}
+}
+
+func TestIsChildPath(t *testing.T) {
+	for _, one := range []struct {
+		parent string
+		path   string
+		expect bool
+	}{
+		{"/", "/a", true},
+		{"/", "/", false},
+		{"/a", "/a/b", true},
+		{"/a", "/a", false},
+		{"/a", "/ab", false},
+		{"/zookeeper", "/zookeeper/quota", true},
+	} {
+		if res := isChildPath(one.parent, one.path); res != one.expect {
+			t.Errorf("isChildPath %s %s expect %v, got %v", one.parent, one.path, one.expect, res)
+		}
+	}
+	if joinPath("/", "a") != "/a" || joinPath("/a", "b") != "/a/b" {
+		t.Errorf("joinPath error")
+	}
+}
@@ -0,0 +1,405 @@
+package module_zookeeper
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-zookeeper/zk"
+	"github.com/team-ide/go-tool/zookeeper"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strconv"
+	"strings"
+	"teamide/pkg/base"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	formatJson = "json"
+	formatYaml = "yaml"
+
+	ConflictSkip      = "skip"      // 已存在 的 节点 保持 不变
+	ConflictOverwrite = "overwrite" // 覆盖 已存在 节点 的 数据
+	ConflictFail      = "fail"      // 存在 冲突 时 不 导入
+)
+
+// 导入 结果 中 最多 返回 的 冲突、错误 数
+const transferResultSize = 100
+
+// ExportNode 导出 的 节点，子节点 嵌套 保存
+type ExportNode struct {
+	Name     string        `json:"name" yaml:"name"`
+	Data     string        `json:"data,omitempty" yaml:"data,omitempty"`
+	Base64   bool          `json:"base64,omitempty" yaml:"base64,omitempty"` // 数据 不是 UTF-8 文本 时 使用 Base64
+	Acl      []*Acl        `json:"acl,omitempty" yaml:"acl,omitempty"`
+	Children []*ExportNode `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+type ExportData struct {
+	Path  string      `json:"path" yaml:"path"` // 导出 的 节点 路径
+	Count int         `json:"count" yaml:"count"`
+	Time  int64       `json:"time" yaml:"time"`
+	Root  *ExportNode `json:"root" yaml:"root"`
+}
+
+type TransferRequest struct {
+	Path             string `json:"path"`
+	Format           string `json:"format"` // json yaml，导入 时 为空 则 按 内容 识别
+	IncludeAcl       bool   `json:"includeAcl"`
+	IncludeEphemeral bool   `json:"includeEphemeral"` // 导出 临时 节点，默认 跳过
+	Content          string `json:"content"`          // 导入 内容
+	FilePath         string `json:"filePath"`         // 导入 上传 的 文件，Content 为空 时 使用
+	TargetPath       string `json:"targetPath"`       // 导入 到 的 路径，为空 使用 导出 时 的 路径
+	TargetToolboxId  int64  `json:"targetToolboxId"`  // 导入 到 其它 ZooKeeper 工具
+	Conflict         string `json:"conflict"`         // skip overwrite fail，默认 skip
+	DryRun           bool   `json:"dryRun"`           // 只 检查 冲突，不 写入
+}
+
+func exportNode(conn *zk.Conn, path string, request *TransferRequest, count *int) (node *ExportNode, err error) {
+	data, stat, err := conn.Get(path)
+	if err != nil {
+		if err == zk.ErrNoNode {
+			err = nil
+			return
+		}
+		err = errors.New("path [" + path + "] get error:" + err.Error())
+		return
+	}
+	if stat.EphemeralOwner != 0 && !request.IncludeEphemeral {
+		return
+	}
+	node = &ExportNode{}
+	if index := strings.LastIndex(path, "/"); index >= 0 {
+		node.Name = path[index+1:]
+	}
+	if utf8.Valid(data) {
+		node.Data = string(data)
+	} else {
+		node.Data = base64.StdEncoding.EncodeToString(data)
+		node.Base64 = true
+	}
+	if request.IncludeAcl {
+		var list []zk.ACL
+		if list, _, err = conn.GetACL(path); err != nil {
+			err = errors.New("path [" + path + "] get acl error:" + err.Error())
+			return
+		}
+		node.Acl = toAclList(list)
+	}
+	*count++
+	children, _, err := conn.Children(path)
+	if err != nil {
+		if err == zk.ErrNoNode {
+			err = nil
+		}
+		return
+	}
+	for _, child := range children {
+		childPath := joinPath(path, child)
+		if isSystemPath(childPath) {
+			continue
+		}
+		var one *ExportNode
+		if one, err = exportNode(conn, childPath, request, count); err != nil {
+			return
+		}
+		if one != nil {
+			node.Children = append(node.Children, one)
+		}
+	}
+	return
+}
+
+// export 导出 子树 为 JSON 或 YAML，临时 节点 和 /zookeeper 默认 跳过
+func (this_ *api) export(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &TransferRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Path == "" {
+		request.Path = "/"
+	}
+	exportData := &ExportData{
+		Path: request.Path,
+		Time: time.Now().UnixMilli(),
+	}
+	exportData.Root, err = exportNode(service.GetConn(), request.Path, request, &exportData.Count)
+	if err != nil {
+		return
+	}
+	if exportData.Root == nil {
+		err = errors.New("path [" + request.Path + "] not exists")
+		return
+	}
+
+	var bs []byte
+	fileName := strings.ReplaceAll(strings.Trim(request.Path, "/"), "/", "_")
+	if fileName == "" {
+		fileName = "root"
+	}
+	fileName += "-" + time.Now().Format("20060102150405")
+	if request.Format == formatYaml {
+		bs, err = yaml.Marshal(exportData)
+		fileName += ".yaml"
+	} else {
+		bs, err = json.MarshalIndent(exportData, "", "  ")
+		fileName += ".json"
+	}
+	if err != nil {
+		return
+	}
+	data := map[string]interface{}{}
+	data["content"] = string(bs)
+	data["fileName"] = fileName
+	data["count"] = exportData.Count
+	res = data
+	return
+}
+
+func parseExportData(content string, format string) (res *ExportData, err error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		err = errors.New("import content is empty")
+		return
+	}
+	if format == "" {
+		format = formatYaml
+		if strings.HasPrefix(content, "{") {
+			format = formatJson
+		}
+	}
+	res = &ExportData{}
+	if format == formatJson {
+		err = json.Unmarshal([]byte(content), res)
+	} else {
+		err = yaml.Unmarshal([]byte(content), res)
+	}
+	if err != nil {
+		err = errors.New("parse " + format + " error:" + err.Error())
+		return
+	}
+	if res.Root == nil {
+		err = errors.New("import content root is empty")
+		return
+	}
+	return
+}
+
+type importNode struct {
+	path string
+	data []byte
+	acl  []zk.ACL
+}
+
+// flattenNodes 先序 展开，父节点 在 子节点 之前
+func flattenNodes(path string, node *ExportNode, includeAcl bool, list *[]*importNode) (err error) {
+	one := &importNode{path: path, data: []byte(node.Data)}
+	if node.Base64 {
+		if one.data, err = base64.StdEncoding.DecodeString(node.Data); err != nil {
+			err = errors.New("path [" + path + "] base64 data error:" + err.Error())
+			return
+		}
+	}
+	if includeAcl && len(node.Acl) > 0 {
+		if one.acl, err = toZkAclList(node.Acl); err != nil {
+			err = errors.New("path [" + path + "] " + err.Error())
+			return
+		}
+	}
+	if !isSystemPath(path) {
+		*list = append(*list, one)
+	}
+	for _, child := range node.Children {
+		if child.Name == "" || strings.Contains(child.Name, "/") {
+			err = errors.New("path [" + path + "] child name [" + child.Name + "] invalid")
+			return
+		}
+		if err = flattenNodes(joinPath(path, child.Name), child, includeAcl, list); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (this_ *api) getImportTarget(requestBean *base.RequestBean, c *gin.Context, targetToolboxId int64) (res zookeeper.IService, err error) {
+	if targetToolboxId == 0 {
+		config, sshConfig, e := this_.getConfig(requestBean, c)
+		if e != nil {
+			return nil, e
+		}
+		return getService(config, sshConfig)
+	}
+	find, err := this_.toolboxService.Get(targetToolboxId)
+	if err != nil {
+		return
+	}
+	if find == nil || find.ToolboxType != "zookeeper" {
+		err = errors.New("目标 ZooKeeper 工具 不存在")
+		return
+	}
+	if err = this_.toolboxService.CheckToolboxPower(requestBean, find); err != nil {
+		return
+	}
+	config := &zookeeper.Config{}
+	sshConfig, err := this_.toolboxService.BindConfigById(targetToolboxId, config)
+	if err != nil {
+		return
+	}
+	res, err = getService(config, sshConfig)
+	return
+}
+
+// createParents 创建 导入 路径 的 父节点
+func createParents(conn *zk.Conn, path string) (err error) {
+	index := strings.LastIndex(path, "/")
+	if index <= 0 {
+		return
+	}
+	parent := path[:index]
+	exists, _, err := conn.Exists(parent)
+	if err != nil || exists {
+		return
+	}
+	if err = createParents(conn, parent); err != nil {
+		return
+	}
+	_, err = conn.Create(parent, nil, 0, zk.WorldACL(zk.PermAll))
+	if err == zk.ErrNodeExists {
+		err = nil
+	}
+	return
+}
+
+// importData 导入 子树，先 检查 冲突，节点 按 先序 创建，权限 在 所有 节点 写入 后 按 后序 设置，避免 权限 限制 创建 子节点
+func (this_ *api) importData(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &TransferRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	switch request.Conflict {
+	case "":
+		request.Conflict = ConflictSkip
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+	default:
+		err = errors.New("conflict [" + request.Conflict + "] not support")
+		return
+	}
+	content := request.Content
+	if content == "" && request.FilePath != "" {
+		var bs []byte
+		if bs, err = os.ReadFile(this_.toolboxService.GetFilesFile(request.FilePath)); err != nil {
+			return
+		}
+		content = string(bs)
+	}
+	exportData, err := parseExportData(content, request.Format)
+	if err != nil {
+		return
+	}
+	targetPath := request.TargetPath
+	if targetPath == "" {
+		targetPath = exportData.Path
+	}
+	if targetPath == "" || !strings.HasPrefix(targetPath, "/") {
+		err = errors.New("target path [" + targetPath + "] invalid")
+		return
+	}
+	if targetPath != "/" {
+		targetPath = strings.TrimSuffix(targetPath, "/")
+	}
+	var nodes []*importNode
+	if err = flattenNodes(targetPath, exportData.Root, request.IncludeAcl, &nodes); err != nil {
+		return
+	}
+
+	service, err := this_.getImportTarget(requestBean, c, request.TargetToolboxId)
+	if err != nil {
+		return
+	}
+	conn := service.GetConn()
+
+	exists := map[string]bool{}
+	var conflicts []string
+	for _, node := range nodes {
+		var ex bool
+		if ex, _, err = conn.Exists(node.path); err != nil {
+			err = errors.New("path [" + node.path + "] exists error:" + err.Error())
+			return
+		}
+		if ex {
+			exists[node.path] = true
+			if len(conflicts) < transferResultSize {
+				conflicts = append(conflicts, node.path)
+			}
+		}
+	}
+	data := map[string]interface{}{}
+	data["total"] = len(nodes)
+	data["conflictCount"] = len(exists)
+	data["conflicts"] = conflicts
+	res = data
+	if request.DryRun {
+		return
+	}
+	if request.Conflict == ConflictFail && len(exists) > 0 {
+		err = errors.New("存在 " + strconv.Itoa(len(exists)) + " 个 冲突 节点，未 导入，如: " + strings.Join(conflicts, ", "))
+		return
+	}
+
+	if err = createParents(conn, targetPath); err != nil {
+		err = errors.New("path [" + targetPath + "] create parents error:" + err.Error())
+		return
+	}
+	var created, updated, skipped int
+	var errs []string
+	addError := func(path string, e error) {
+		if len(errs) < transferResultSize {
+			errs = append(errs, "path ["+path+"] "+e.Error())
+		}
+	}
+	var aclNodes []*importNode
+	for _, node := range nodes {
+		if exists[node.path] {
+			if request.Conflict == ConflictSkip {
+				skipped++
+				continue
+			}
+			if _, e := conn.Set(node.path, node.data, -1); e != nil {
+				addError(node.path, e)
+				continue
+			}
+			updated++
+		} else {
+			if _, e := conn.Create(node.path, node.data, 0, zk.WorldACL(zk.PermAll)); e != nil {
+				addError(node.path, e)
+				continue
+			}
+			created++
+		}
+		if len(node.acl) > 0 {
+			aclNodes = append(aclNodes, node)
+		}
+	}
+	for i := len(aclNodes) - 1; i >= 0; i-- {
+		node := aclNodes[i]
+		if _, e := conn.SetACL(node.path, node.acl, -1); e != nil {
+			addError(node.path, e)
+		}
+	}
+	data["created"] = created
+	data["updated"] = updated
+	data["skipped"] = skipped
+	data["errors"] = errs
+	return
+}
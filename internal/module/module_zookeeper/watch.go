@@ -0,0 +1,328 @@
+package module_zookeeper
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-zookeeper/zk"
+	"github.com/team-ide/go-tool/util"
+	"github.com/team-ide/go-tool/zookeeper"
+	"go.uber.org/zap"
+	"sort"
+	"strconv"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+type WatchRequest struct {
+	WorkerId string `json:"workerId"`
+	Key      string `json:"key"`
+	Path     string `json:"path"`
+	Subtree  bool   `json:"subtree"`  // 监听 整个 子树，否则 只 监听 当前 节点 的 数据 和 子节点
+	MaxNodes int    `json:"maxNodes"` // 子树 最多 监听 节点 数 默认 1000
+}
+
+type WatchMessage struct {
+	Type     string              `json:"type"` // created dataChanged deleted childrenChanged error
+	Path     string              `json:"path,omitempty"`
+	Data     string              `json:"data,omitempty"`
+	Stat     *zookeeper.StatInfo `json:"stat,omitempty"`
+	Children []string            `json:"children,omitempty"`
+	Added    []string            `json:"added,omitempty"`
+	Removed  []string            `json:"removed,omitempty"`
+	Time     int64               `json:"time"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// watcher 监听 节点 数据 和 子节点 变化，ZooKeeper 的 监听 只 触发 一次，每次 触发 后 重新 注册
+// 每个 监听 会话 使用 独立 的 连接，避免 缓存 的 服务 空闲 回收 后 监听 失效
+type watcher struct {
+	*base.WebsocketSession
+	request   *WatchRequest
+	config    *zookeeper.Config
+	sshConfig *ssh.Config
+	conn      *zk.Conn
+	stopConn  func()
+	connLock  sync.Mutex
+
+	// 正在 监听 的 节点，节点 删除 时 关闭 对应 的 通道 结束 监听
+	watching     map[string]chan struct{}
+	watchingLock sync.Mutex
+	exceeded     bool // 超出 最多 监听 节点 数 只 提示 一次
+}
+
+var watcherCache = base.NewWebsocketCache("zookeeper watch")
+
+func (this_ *watcher) write(msg *WatchMessage) {
+	msg.Time = util.GetNowMilli()
+	if err := this_.WriteJSON(msg); err != nil {
+		go this_.Stop()
+	}
+}
+
+func (this_ *watcher) writeError(path string, err error) {
+	this_.write(&WatchMessage{Type: "error", Path: path, Error: err.Error()})
+}
+
+// watchPath 开始 监听 节点，已 监听 或 超出 数量 时 忽略
+func (this_ *watcher) watchPath(path string, isRoot bool) {
+	this_.watchingLock.Lock()
+	if _, ok := this_.watching[path]; ok {
+		this_.watchingLock.Unlock()
+		return
+	}
+	if len(this_.watching) >= this_.request.MaxNodes {
+		exceeded := this_.exceeded
+		this_.exceeded = true
+		this_.watchingLock.Unlock()
+		if !exceeded {
+			this_.writeError(path, errors.New("watch nodes exceed max nodes "+strconv.Itoa(this_.request.MaxNodes)+", other nodes not watched"))
+		}
+		return
+	}
+	pathStopped := make(chan struct{})
+	this_.watching[path] = pathStopped
+	this_.watchingLock.Unlock()
+
+	go this_.watchData(path, isRoot, pathStopped)
+	go this_.watchChildren(path, isRoot, pathStopped)
+}
+
+// unwatchPath 结束 节点 及 其 子孙 的 监听
+func (this_ *watcher) unwatchPath(path string) {
+	this_.watchingLock.Lock()
+	defer this_.watchingLock.Unlock()
+	for one, pathStopped := range this_.watching {
+		if one == path || isChildPath(path, one) {
+			close(pathStopped)
+			delete(this_.watching, one)
+		}
+	}
+}
+
+// wait 等待 事件，返回 false 表示 已 停止
+func (this_ *watcher) wait(ch <-chan zk.Event, pathStopped chan struct{}) (event zk.Event, ok bool) {
+	select {
+	case <-this_.Done():
+		return
+	case <-pathStopped:
+		return
+	case event = <-ch:
+		ok = true
+		return
+	}
+}
+
+func (this_ *watcher) sleep(pathStopped chan struct{}) bool {
+	select {
+	case <-this_.Done():
+		return false
+	case <-pathStopped:
+		return false
+	case <-time.After(time.Second):
+		return true
+	}
+}
+
+// watchData 监听 节点 数据，根 节点 不存在 时 等待 创建，子孙 节点 删除 后 结束
+func (this_ *watcher) watchData(path string, isRoot bool, pathStopped chan struct{}) {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("zookeeper watch data error", zap.Any("error", e))
+		}
+	}()
+	var lastStat *zk.Stat
+	for {
+		data, stat, ch, err := this_.conn.GetW(path)
+		if err == zk.ErrNoNode {
+			if !isRoot {
+				return
+			}
+			var exists bool
+			exists, _, ch, err = this_.conn.ExistsW(path)
+			if err == nil && exists {
+				continue
+			}
+		}
+		if err != nil {
+			this_.writeError(path, err)
+			if !this_.sleep(pathStopped) {
+				return
+			}
+			continue
+		}
+		if stat != nil {
+			if lastStat != nil && stat.Mzxid != lastStat.Mzxid {
+				this_.write(&WatchMessage{Type: "dataChanged", Path: path, Data: string(data), Stat: zookeeper.StatToInfo(stat)})
+			}
+			lastStat = stat
+		}
+		event, ok := this_.wait(ch, pathStopped)
+		if !ok {
+			return
+		}
+		switch event.Type {
+		case zk.EventNodeCreated:
+			this_.write(&WatchMessage{Type: "created", Path: path})
+			// 根 节点 重新 创建 后 子节点 监听 会 重新 开始
+			lastStat = &zk.Stat{}
+		case zk.EventNodeDeleted:
+			this_.write(&WatchMessage{Type: "deleted", Path: path})
+			lastStat = nil
+			if !isRoot {
+				return
+			}
+		case zk.EventNotWatching:
+			msg := "watch removed"
+			if event.Err != nil {
+				msg += ":" + event.Err.Error()
+			}
+			this_.writeError(path, errors.New(msg))
+			if !this_.sleep(pathStopped) {
+				return
+			}
+		}
+	}
+}
+
+// watchChildren 监听 子节点 变化，监听 子树 时 为 新增 的 子节点 开始 监听
+func (this_ *watcher) watchChildren(path string, isRoot bool, pathStopped chan struct{}) {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("zookeeper watch children error", zap.Any("error", e))
+		}
+	}()
+	var lastChildren []string
+	// 第一次 加载 的 子节点 不 推送
+	var loaded bool
+	for {
+		children, _, ch, err := this_.conn.ChildrenW(path)
+		if err == zk.ErrNoNode {
+			if !isRoot {
+				return
+			}
+			var exists bool
+			exists, _, ch, err = this_.conn.ExistsW(path)
+			if err == nil && exists {
+				continue
+			}
+			if err == nil {
+				this_.removeChildren(path, lastChildren)
+				lastChildren = nil
+				loaded = true
+			}
+		} else if err == nil {
+			sort.Strings(children)
+			added, removed := diffChildren(lastChildren, children)
+			if loaded && (len(added) > 0 || len(removed) > 0) {
+				this_.write(&WatchMessage{Type: "childrenChanged", Path: path, Children: children, Added: added, Removed: removed})
+			}
+			this_.removeChildren(path, removed)
+			if this_.request.Subtree {
+				for _, child := range added {
+					this_.watchPath(joinPath(path, child), false)
+				}
+			}
+			lastChildren = children
+			loaded = true
+		}
+		if err != nil {
+			this_.writeError(path, err)
+			if !this_.sleep(pathStopped) {
+				return
+			}
+			continue
+		}
+		if _, ok := this_.wait(ch, pathStopped); !ok {
+			return
+		}
+	}
+}
+
+func (this_ *watcher) removeChildren(path string, removed []string) {
+	if !this_.request.Subtree {
+		return
+	}
+	for _, child := range removed {
+		this_.unwatchPath(joinPath(path, child))
+	}
+}
+
+// diffChildren 比较 排序 后 的 子节点，lastChildren 为 空 时 全部 为 新增
+func diffChildren(lastChildren []string, children []string) (added []string, removed []string) {
+	last := map[string]bool{}
+	for _, one := range lastChildren {
+		last[one] = true
+	}
+	now := map[string]bool{}
+	for _, one := range children {
+		now[one] = true
+		if !last[one] {
+			added = append(added, one)
+		}
+	}
+	for _, one := range lastChildren {
+		if !now[one] {
+			removed = append(removed, one)
+		}
+	}
+	return
+}
+
+func (this_ *watcher) Start() (err error) {
+	s, stop, err := newService(this_.config, this_.sshConfig)
+	if err != nil {
+		return
+	}
+	this_.connLock.Lock()
+	defer this_.connLock.Unlock()
+	select {
+	case <-this_.Done():
+		// 连接 过程 中 已 停止
+		stop()
+		return
+	default:
+	}
+	this_.conn = s.GetConn()
+	this_.stopConn = stop
+	this_.watchPath(this_.request.Path, true)
+	return
+}
+
+// OnStop 各 节点 的 监听 协程 通过 Done 结束，然后 关闭 连接
+func (this_ *watcher) OnStop() {
+	this_.connLock.Lock()
+	defer this_.connLock.Unlock()
+	if this_.stopConn != nil {
+		this_.stopConn()
+	}
+}
+
+// watchKey 创建 监听 会话，返回 key 用于 建立 websocket
+func (this_ *api) watchKey(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &WatchRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Path == "" {
+		request.Path = "/"
+	}
+	if request.MaxNodes <= 0 {
+		request.MaxNodes = 1000
+	}
+	one := &watcher{
+		WebsocketSession: base.NewWebsocketSession(requestBean, request.WorkerId),
+		request:          request,
+		config:           config,
+		sshConfig:        sshConfig,
+		watching:         map[string]chan struct{}{},
+	}
+	res = watcherCache.Add(one)
+	return
+}
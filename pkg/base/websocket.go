@@ -0,0 +1,294 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var WebsocketUpGrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// websocketConnectTimeout 创建 会话 后 在 该 时间 内 未 建立 websocket 则 结束 会话
+var websocketConnectTimeout = time.Minute
+
+// 推送 速率 上限，每秒 条数
+const maxRateLimit = 10000
+
+// RateLimitInterval 每秒 推送 条数 转换 为 推送 间隔，限制 在 1 到 maxRateLimit 之间，避免 间隔 为 0 时 time.NewTicker panic
+func RateLimitInterval(rateLimit int) time.Duration {
+	if rateLimit < 1 {
+		rateLimit = 1
+	} else if rateLimit > maxRateLimit {
+		rateLimit = maxRateLimit
+	}
+	return time.Second / time.Duration(rateLimit)
+}
+
+// 推送 缓冲 默认 和 上限 条数
+const defaultBufferSize = 1000
+const maxBufferSize = 10000
+
+// BufferSize 推送 缓冲 条数，小于 1 时 使用 默认 1000，限制 不 超过 maxBufferSize，避免 一个 请求 创建 过大 的 通道
+func BufferSize(bufferSize int) int {
+	if bufferSize < 1 {
+		return defaultBufferSize
+	} else if bufferSize > maxBufferSize {
+		return maxBufferSize
+	}
+	return bufferSize
+}
+
+// WebsocketHandler 推送 类 会话，先 通过 接口 创建 会话 返回 key，再 使用 key 建立 websocket
+type WebsocketHandler interface {
+	GetSession() *WebsocketSession
+	// Start websocket 建立 后 调用，返回 错误 时 推送 错误 并 结束 会话
+	Start() error
+	// OnStop 会话 结束 时 调用 一次，用于 释放 连接 等 资源
+	OnStop()
+}
+
+// WebsocketSession 会话 公共 部分，模块 的 会话 嵌入 使用
+type WebsocketSession struct {
+	Key      string
+	WorkerId string
+	UserId   int64
+
+	handler  WebsocketHandler
+	cache    *WebsocketCache
+	ws       *websocket.Conn
+	wsLock   sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+}
+
+func NewWebsocketSession(requestBean *RequestBean, workerId string) (session *WebsocketSession) {
+	session = &WebsocketSession{
+		Key:      util.GetUUID(),
+		WorkerId: workerId,
+	}
+	if requestBean != nil && requestBean.JWT != nil {
+		session.UserId = requestBean.JWT.UserId
+	}
+	session.ctx, session.cancel = context.WithCancel(context.Background())
+	return
+}
+
+func (this_ *WebsocketSession) GetSession() *WebsocketSession {
+	return this_
+}
+
+// Context 会话 结束 时 取消
+func (this_ *WebsocketSession) Context() context.Context {
+	return this_.ctx
+}
+
+func (this_ *WebsocketSession) Done() <-chan struct{} {
+	return this_.ctx.Done()
+}
+
+func (this_ *WebsocketSession) IsStopped() bool {
+	return this_.ctx.Err() != nil
+}
+
+// WriteJSON 多个 协程 推送 时 加锁 写入
+func (this_ *WebsocketSession) WriteJSON(msg interface{}) (err error) {
+	this_.wsLock.Lock()
+	defer this_.wsLock.Unlock()
+	if this_.ws == nil {
+		err = errors.New("websocket not connected")
+		return
+	}
+	return this_.ws.WriteJSON(msg)
+}
+
+func (this_ *WebsocketSession) isConnected() bool {
+	this_.wsLock.Lock()
+	defer this_.wsLock.Unlock()
+	return this_.ws != nil
+}
+
+// readUntilClose 只用于 感知 websocket 关闭
+func (this_ *WebsocketSession) readUntilClose() {
+	defer this_.Stop()
+	for {
+		if _, _, err := this_.ws.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (this_ *WebsocketSession) Stop() {
+	this_.stopOnce.Do(func() {
+		this_.cancel()
+		var name string
+		if this_.cache != nil {
+			name = this_.cache.name
+			this_.cache.remove(this_.Key)
+		}
+		if this_.handler != nil {
+			this_.handler.OnStop()
+		}
+		this_.wsLock.Lock()
+		if this_.ws != nil {
+			_ = this_.ws.Close()
+		}
+		this_.wsLock.Unlock()
+		util.Logger.Info(name+" stop", zap.Any("key", this_.Key))
+	})
+}
+
+// WebsocketCache 会话 缓存，提供 websocket 建立 和 关闭 接口
+type WebsocketCache struct {
+	name  string
+	cache map[string]WebsocketHandler
+	lock  sync.Mutex
+}
+
+func NewWebsocketCache(name string) *WebsocketCache {
+	return &WebsocketCache{
+		name:  name,
+		cache: map[string]WebsocketHandler{},
+	}
+}
+
+// Add 缓存 会话，返回 key 用于 建立 websocket，超时 未 建立 websocket 的 会话 自动 结束
+func (this_ *WebsocketCache) Add(handler WebsocketHandler) (res interface{}) {
+	session := handler.GetSession()
+	session.handler = handler
+	session.cache = this_
+	this_.lock.Lock()
+	this_.cache[session.Key] = handler
+	this_.lock.Unlock()
+	time.AfterFunc(websocketConnectTimeout, func() {
+		if !session.isConnected() {
+			util.Logger.Warn(this_.name+" websocket connect timeout", zap.Any("key", session.Key))
+			session.Stop()
+		}
+	})
+
+	data := make(map[string]interface{})
+	data["key"] = session.Key
+	res = data
+	return
+}
+
+func (this_ *WebsocketCache) Get(key string) WebsocketHandler {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	return this_.cache[key]
+}
+
+func (this_ *WebsocketCache) remove(key string) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	delete(this_.cache, key)
+}
+
+// RemoveWorker 工具 关闭 时 结束 该 工具 的 所有 会话
+func (this_ *WebsocketCache) RemoveWorker(workerId string) {
+	var list []WebsocketHandler
+	this_.lock.Lock()
+	for _, one := range this_.cache {
+		if one.GetSession().WorkerId == workerId {
+			list = append(list, one)
+		}
+	}
+	this_.lock.Unlock()
+	for _, one := range list {
+		one.GetSession().Stop()
+	}
+}
+
+// getUserSession 只 返回 当前 用户 创建 的 会话
+func (this_ *WebsocketCache) getUserSession(requestBean *RequestBean, key string) WebsocketHandler {
+	one := this_.Get(key)
+	if one == nil {
+		return nil
+	}
+	if requestBean.JWT == nil || one.GetSession().UserId != requestBean.JWT.UserId {
+		return nil
+	}
+	return one
+}
+
+// websocketError 与 各 模块 消息 的 错误 格式 一致
+func websocketError(err error) map[string]interface{} {
+	return map[string]interface{}{
+		"type":  "error",
+		"time":  util.GetNowMilli(),
+		"error": err.Error(),
+	}
+}
+
+// Websocket 建立 websocket 并 启动 会话
+func (this_ *WebsocketCache) Websocket(requestBean *RequestBean, c *gin.Context) (res interface{}, err error) {
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	key := c.Query("key")
+	if key == "" {
+		err = errors.New("key获取失败")
+		return
+	}
+	//升级get请求为webSocket协议
+	ws, err := WebsocketUpGrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	res = HttpNotResponse
+
+	one := this_.getUserSession(requestBean, key)
+	if one == nil {
+		_ = ws.WriteJSON(websocketError(errors.New("会话[" + key + "]不存在")))
+		_ = ws.Close()
+		return
+	}
+	session := one.GetSession()
+	session.wsLock.Lock()
+	if session.ws != nil || session.IsStopped() {
+		session.wsLock.Unlock()
+		_ = ws.WriteJSON(websocketError(errors.New("会话[" + key + "]已连接")))
+		_ = ws.Close()
+		return
+	}
+	session.ws = ws
+	session.wsLock.Unlock()
+
+	if e := one.Start(); e != nil {
+		util.Logger.Error(this_.name+" start error", zap.Error(e))
+		_ = session.WriteJSON(websocketError(e))
+		session.Stop()
+		return
+	}
+	go session.readUntilClose()
+	return
+}
+
+// Close 结束 会话
+func (this_ *WebsocketCache) Close(requestBean *RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &struct {
+		Key string `json:"key"`
+	}{}
+	if !RequestJSON(request, c) {
+		return
+	}
+	one := this_.getUserSession(requestBean, request.Key)
+	if one != nil {
+		one.GetSession().Stop()
+	}
+	return
+}
@@ -0,0 +1,156 @@
+package base
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testWebsocketHandler struct {
+	*WebsocketSession
+	stopped chan struct{}
+}
+
+func (this_ *testWebsocketHandler) Start() error {
+	return this_.WriteJSON(map[string]interface{}{"type": "hello"})
+}
+
+func (this_ *testWebsocketHandler) OnStop() {
+	close(this_.stopped)
+}
+
+func TestWebsocketCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cache := NewWebsocketCache("test")
+	owner := &RequestBean{JWT: &JWTBean{UserId: 1}}
+	one := &testWebsocketHandler{
+		WebsocketSession: NewWebsocketSession(owner, "w1"),
+		stopped:          make(chan struct{}),
+	}
+	data := cache.Add(one).(map[string]interface{})
+	if data["key"] != one.Key || cache.Get(one.Key) == nil {
+		t.Fatalf("add expect key %s, got %v", one.Key, data)
+	}
+
+	var requestBean = owner
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		_, _ = cache.Websocket(requestBean, c)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?key="
+
+	// 其它 用户 不能 使用 该 会话
+	requestBean = &RequestBean{JWT: &JWTBean{UserId: 2}}
+	ws, _, err := websocket.DefaultDialer.Dial(url+one.Key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := map[string]interface{}{}
+	if err = ws.ReadJSON(&msg); err != nil || msg["type"] != "error" {
+		t.Errorf("other user expect error message, got %v %v", msg, err)
+	}
+	_ = ws.Close()
+
+	requestBean = owner
+	ws, _, err = websocket.DefaultDialer.Dial(url+one.Key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = map[string]interface{}{}
+	if err = ws.ReadJSON(&msg); err != nil || msg["type"] != "hello" {
+		t.Errorf("owner expect hello message, got %v %v", msg, err)
+	}
+
+	// websocket 关闭 后 会话 结束
+	_ = ws.Close()
+	select {
+	case <-one.stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("session expect stopped after websocket closed")
+	}
+	if cache.Get(one.Key) != nil || !one.IsStopped() {
+		t.Errorf("session expect removed from cache")
+	}
+}
+
+func TestWebsocketCacheRemoveWorker(t *testing.T) {
+	cache := NewWebsocketCache("test")
+	var list []*testWebsocketHandler
+	for _, workerId := range []string{"w1", "w1", "w2"} {
+		one := &testWebsocketHandler{
+			WebsocketSession: NewWebsocketSession(nil, workerId),
+			stopped:          make(chan struct{}),
+		}
+		cache.Add(one)
+		list = append(list, one)
+	}
+	cache.RemoveWorker("w1")
+	if !list[0].IsStopped() || !list[1].IsStopped() || list[2].IsStopped() {
+		t.Errorf("remove worker expect only w1 sessions stopped")
+	}
+	if cache.Get(list[2].Key) == nil {
+		t.Errorf("w2 session expect still cached")
+	}
+}
+
+func TestWebsocketConnectTimeout(t *testing.T) {
+	timeout := websocketConnectTimeout
+	websocketConnectTimeout = 50 * time.Millisecond
+	defer func() { websocketConnectTimeout = timeout }()
+
+	cache := NewWebsocketCache("test")
+	one := &testWebsocketHandler{
+		WebsocketSession: NewWebsocketSession(nil, "w1"),
+		stopped:          make(chan struct{}),
+	}
+	cache.Add(one)
+	select {
+	case <-one.stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("session expect stopped when websocket not connected")
+	}
+	if cache.Get(one.Key) != nil {
+		t.Errorf("session expect removed from cache")
+	}
+}
+
+func TestRateLimitInterval(t *testing.T) {
+	for _, one := range []struct {
+		rateLimit int
+		expect    time.Duration
+	}{
+		{-1, time.Second},
+		{0, time.Second},
+		{1, time.Second},
+		{100, 10 * time.Millisecond},
+		{maxRateLimit, 100 * time.Microsecond},
+		{2000000000, 100 * time.Microsecond},
+	} {
+		if res := RateLimitInterval(one.rateLimit); res != one.expect {
+			t.Errorf("rate limit %d expect %s, got %s", one.rateLimit, one.expect, res)
+		}
+	}
+}
+
+func TestBufferSize(t *testing.T) {
+	for _, one := range []struct {
+		bufferSize int
+		expect     int
+	}{
+		{-1, defaultBufferSize},
+		{0, defaultBufferSize},
+		{1, 1},
+		{5000, 5000},
+		{maxBufferSize, maxBufferSize},
+		{2000000000, maxBufferSize},
+	} {
+		if res := BufferSize(one.bufferSize); res != one.expect {
+			t.Errorf("buffer size %d expect %d, got %d", one.bufferSize, one.expect, res)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 支持 的 语法：
+//   $            根
+//   .name        字段
+//   ['name']     字段
+//   [0] [-1]     数组 下标
+//   [*] .*       所有 子元素
+//   ..name       递归 查找 字段
+
+type step struct {
+	name      string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool
+}
+
+func parse(path string) (steps []*step, err error) {
+	path = strings.TrimSpace(path)
+	if path == "" || path[0] != '$' {
+		err = errors.New("jsonpath [" + path + "] must start with $")
+		return
+	}
+	i := 1
+	for i < len(path) {
+		switch path[i] {
+		case '.':
+			one := &step{}
+			i++
+			if i < len(path) && path[i] == '.' {
+				one.recursive = true
+				i++
+			}
+			start := i
+			for i < len(path) && path[i] != '.' && path[i] != '[' {
+				i++
+			}
+			name := path[start:i]
+			if name == "*" {
+				one.wildcard = true
+			} else if name == "" {
+				if !one.recursive || i >= len(path) || path[i] != '[' {
+					err = errors.New("jsonpath [" + path + "] field name is empty")
+					return
+				}
+				// ..[*] 或 ..['name']
+				steps = append(steps, one)
+				one = nil
+			} else {
+				one.name = name
+			}
+			if one != nil {
+				steps = append(steps, one)
+			}
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				err = errors.New("jsonpath [" + path + "] missing ]")
+				return
+			}
+			content := strings.TrimSpace(path[i+1 : i+end])
+			i += end + 1
+			one := &step{}
+			if len(steps) > 0 && steps[len(steps)-1].recursive && steps[len(steps)-1].name == "" && !steps[len(steps)-1].wildcard {
+				one = steps[len(steps)-1]
+				steps = steps[:len(steps)-1]
+			}
+			switch {
+			case content == "*":
+				one.wildcard = true
+			case len(content) >= 2 && (content[0] == '\'' || content[0] == '"') && content[len(content)-1] == content[0]:
+				one.name = content[1 : len(content)-1]
+			default:
+				one.index, err = strconv.Atoi(content)
+				if err != nil {
+					err = errors.New("jsonpath [" + path + "] index [" + content + "] is not number")
+					return
+				}
+				one.isIndex = true
+			}
+			steps = append(steps, one)
+		default:
+			err = fmt.Errorf("jsonpath [%s] unexpected char [%c] at %d", path, path[i], i)
+			return
+		}
+	}
+	return
+}
+
+func children(value interface{}) (list []interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, one := range v {
+			list = append(list, one)
+		}
+	case []interface{}:
+		list = append(list, v...)
+	}
+	return
+}
+
+func (this_ *step) apply(value interface{}) (list []interface{}) {
+	switch {
+	case this_.wildcard:
+		return children(value)
+	case this_.isIndex:
+		if arr, ok := value.([]interface{}); ok {
+			index := this_.index
+			if index < 0 {
+				index += len(arr)
+			}
+			if index >= 0 && index < len(arr) {
+				list = append(list, arr[index])
+			}
+		}
+	default:
+		if m, ok := value.(map[string]interface{}); ok {
+			if v, find := m[this_.name]; find {
+				list = append(list, v)
+			}
+		}
+	}
+	return
+}
+
+func descendants(value interface{}) (list []interface{}) {
+	list = append(list, value)
+	for _, one := range children(value) {
+		list = append(list, descendants(one)...)
+	}
+	return
+}
+
+// Get 根据 路径 获取 值 data 为 json.Unmarshal 的 结果
+func Get(data interface{}, path string) (values []interface{}, err error) {
+	steps, err := parse(path)
+	if err != nil {
+		return
+	}
+	values = []interface{}{data}
+	for _, one := range steps {
+		var next []interface{}
+		for _, value := range values {
+			if one.recursive {
+				for _, d := range descendants(value) {
+					next = append(next, one.apply(d)...)
+				}
+			} else {
+				next = append(next, one.apply(value)...)
+			}
+		}
+		values = next
+	}
+	return
+}
+
+// GetFromJSON 解析 JSON 字符串 后 获取 值
+func GetFromJSON(content string, path string) (values []interface{}, err error) {
+	var data interface{}
+	err = json.Unmarshal([]byte(content), &data)
+	if err != nil {
+		return
+	}
+	return Get(data, path)
+}
+
+var operators = []string{"==", "!=", ">=", "<=", "=~", ">", "<", " contains "}
+
+// splitExpression 拆分 表达式 为 路径 操作符 和 值，没有 操作符 时 判断 是否 存在
+func splitExpression(expression string) (path string, operator string, literal string) {
+	expression = strings.TrimSpace(expression)
+	var inQuote byte
+	var depth int
+	for i := 0; i < len(expression); i++ {
+		c := expression[i]
+		if inQuote != 0 {
+			if c == inQuote {
+				inQuote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			inQuote = c
+			continue
+		case '[':
+			depth++
+			continue
+		case ']':
+			depth--
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+		for _, op := range operators {
+			if strings.HasPrefix(expression[i:], op) {
+				return strings.TrimSpace(expression[:i]), strings.TrimSpace(op), strings.TrimSpace(expression[i+len(op):])
+			}
+		}
+	}
+	return expression, "", ""
+}
+
+func parseLiteral(literal string) interface{} {
+	if len(literal) >= 2 && (literal[0] == '\'' && literal[len(literal)-1] == '\'') {
+		return literal[1 : len(literal)-1]
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(literal), &v); err == nil {
+		return v
+	}
+	return literal
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case nil:
+		return ""
+	}
+	bs, _ := json.Marshal(v)
+	return string(bs)
+}
+
+func compare(value interface{}, operator string, expect interface{}) (bool, error) {
+	switch operator {
+	case "==":
+		if a, ok := toFloat(value); ok {
+			if b, ok := toFloat(expect); ok {
+				return a == b, nil
+			}
+		}
+		return reflect.DeepEqual(value, expect) || toString(value) == toString(expect), nil
+	case "!=":
+		eq, err := compare(value, "==", expect)
+		return !eq, err
+	case ">", ">=", "<", "<=":
+		a, okA := toFloat(value)
+		b, okB := toFloat(expect)
+		if !okA || !okB {
+			return false, nil
+		}
+		switch operator {
+		case ">":
+			return a > b, nil
+		case ">=":
+			return a >= b, nil
+		case "<":
+			return a < b, nil
+		default:
+			return a <= b, nil
+		}
+	case "=~":
+		reg, err := regexp.Compile(toString(expect))
+		if err != nil {
+			return false, err
+		}
+		return reg.MatchString(toString(value)), nil
+	case "contains":
+		return strings.Contains(toString(value), toString(expect)), nil
+	}
+	return false, errors.New("jsonpath operator [" + operator + "] not support")
+}
+
+// Match 判断 表达式 是否 成立，如 $.user.age >= 18、$.name =~ "^a"、$.tags[*] == "x"，没有 操作符 时 判断 路径 是否 存在
+// 路径 匹配 多个 值 时 任意 一个 成立 即 成立
+func Match(data interface{}, expression string) (ok bool, err error) {
+	path, operator, literal := splitExpression(expression)
+	values, err := Get(data, path)
+	if err != nil {
+		return
+	}
+	if operator == "" {
+		return len(values) > 0, nil
+	}
+	expect := parseLiteral(literal)
+	for _, value := range values {
+		ok, err = compare(value, operator, expect)
+		if err != nil || ok {
+			return
+		}
+	}
+	return
+}
+
+// MatchJSON 解析 JSON 字符串 后 判断 表达式，内容 不是 JSON 时 返回 false
+func MatchJSON(content string, expression string) (ok bool, err error) {
+	var data interface{}
+	if e := json.Unmarshal([]byte(content), &data); e != nil {
+		return
+	}
+	return Match(data, expression)
+}
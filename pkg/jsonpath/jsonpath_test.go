@@ -0,0 +1,52 @@
+package jsonpath
+
+import (
+	"testing"
+)
+
+const testJSON = `{"user":{"name":"tom","age":20,"tags":["a","b"]},"list":[{"id":1},{"id":2,"name":"x"}],"status":"ok"}`
+
+func TestGet(t *testing.T) {
+	var cases = map[string]int{
+		"$.user.name":      1,
+		"$['user']['age']": 1,
+		"$.user.tags[*]":   2,
+		"$.user.tags[-1]":  1,
+		"$.list[*].id":     2,
+		"$..name":          2,
+		"$.none":           0,
+	}
+	for path, size := range cases {
+		values, err := GetFromJSON(testJSON, path)
+		if err != nil {
+			t.Fatal(path, err)
+		}
+		if len(values) != size {
+			t.Fatal(path, "expect size", size, "but", len(values), values)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	var cases = map[string]bool{
+		"$.user.age >= 18":          true,
+		"$.user.age < 18":           false,
+		`$.status == "ok"`:          true,
+		"$.status == 'ok'":          true,
+		"$.status != 'ok'":          false,
+		`$.user.name =~ "^t"`:       true,
+		"$.user.tags[*] == 'b'":     true,
+		"$.list[1].name contains x": true,
+		"$.list[0].name":            false,
+		"$.user":                    true,
+	}
+	for expression, expect := range cases {
+		ok, err := MatchJSON(testJSON, expression)
+		if err != nil {
+			t.Fatal(expression, err)
+		}
+		if ok != expect {
+			t.Fatal(expression, "expect", expect, "but", ok)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package load
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// 每 个 2 的 幂 区间 划分 的 子 桶 数，相对 误差 约 3%
+const subBucketBits = 5
+const subBucketCount = 1 << subBucketBits
+
+// Histogram 对数 线性 分桶 的 耗时 直方图，单位 微秒，内存 固定 与 样本 数 无关
+type Histogram struct {
+	counts []int64
+	count  int64
+	max    int64
+	sum    int64
+}
+
+func bucketIndex(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	exp := bits.Len64(uint64(v)) - subBucketBits - 1
+	return (exp+1)*subBucketCount + int(v>>uint(exp)) - subBucketCount
+}
+
+// bucketValue 桶 的 中间 值
+func bucketValue(index int) float64 {
+	if index < subBucketCount {
+		return float64(index)
+	}
+	exp := index/subBucketCount - 1
+	lower := int64(index%subBucketCount+subBucketCount) << uint(exp)
+	return float64(lower) + float64(int64(1)<<uint(exp))/2
+}
+
+func (this_ *Histogram) Record(micro int64) {
+	if micro < 0 {
+		micro = 0
+	}
+	index := bucketIndex(micro)
+	if index >= len(this_.counts) {
+		counts := make([]int64, index+1)
+		copy(counts, this_.counts)
+		this_.counts = counts
+	}
+	this_.counts[index]++
+	this_.count++
+	this_.sum += micro
+	if micro > this_.max {
+		this_.max = micro
+	}
+}
+
+func (this_ *Histogram) Merge(other *Histogram) {
+	if len(other.counts) > len(this_.counts) {
+		counts := make([]int64, len(other.counts))
+		copy(counts, this_.counts)
+		this_.counts = counts
+	}
+	for i, c := range other.counts {
+		this_.counts[i] += c
+	}
+	this_.count += other.count
+	this_.sum += other.sum
+	if other.max > this_.max {
+		this_.max = other.max
+	}
+}
+
+// Quantile 分位 值 微秒，q 为 0 ~ 1
+func (this_ *Histogram) Quantile(q float64) float64 {
+	if this_.count == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(q * float64(this_.count)))
+	if target < 1 {
+		target = 1
+	}
+	var total int64
+	for i, c := range this_.counts {
+		total += c
+		if total >= target {
+			v := bucketValue(i)
+			if v > float64(this_.max) {
+				v = float64(this_.max)
+			}
+			return v
+		}
+	}
+	return float64(this_.max)
+}
+
+// Percentile 一个 时段 的 耗时 分位，单位 毫秒
+type Percentile struct {
+	Time  int64   `json:"time"` // 时段 开始 时间 毫秒
+	Count int64   `json:"count"`
+	Avg   float64 `json:"avg"`
+	Max   float64 `json:"max"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	P999  float64 `json:"p999"`
+}
+
+func (this_ *Histogram) Percentile(time int64) (res *Percentile) {
+	res = &Percentile{
+		Time:  time,
+		Count: this_.count,
+		Max:   float64(this_.max) / 1000,
+		P50:   this_.Quantile(0.5) / 1000,
+		P90:   this_.Quantile(0.9) / 1000,
+		P99:   this_.Quantile(0.99) / 1000,
+		P999:  this_.Quantile(0.999) / 1000,
+	}
+	if this_.count > 0 {
+		res.Avg = float64(this_.sum) / float64(this_.count) / 1000
+	}
+	return
+}
+
+// Latency 按 时段 记录 耗时 直方图，时段 划分 与 metric 的 统计 间隔 一致
+type Latency struct {
+	bucketSecond int64
+	buckets      map[int64]*Histogram
+	lock         sync.Mutex
+}
+
+// NewLatency bucketSecond 为 时段 秒 数，小于 等于 0 时 默认 10 秒
+func NewLatency(bucketSecond int) *Latency {
+	if bucketSecond <= 0 {
+		bucketSecond = 10
+	}
+	return &Latency{
+		bucketSecond: int64(bucketSecond),
+		buckets:      map[int64]*Histogram{},
+	}
+}
+
+// Record startNano 为 开始 时间 纳秒，useNano 为 耗时 纳秒
+func (this_ *Latency) Record(startNano int64, useNano int64) {
+	key := startNano / int64(1e9) / this_.bucketSecond
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	histogram := this_.buckets[key]
+	if histogram == nil {
+		histogram = &Histogram{}
+		this_.buckets[key] = histogram
+	}
+	histogram.Record(useNano / 1000)
+}
+
+// Buckets 各 时段 的 分位，按 时间 升序
+func (this_ *Latency) Buckets() (res []*Percentile) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	var keys []int64
+	for key := range this_.buckets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		res = append(res, this_.buckets[key].Percentile(key*this_.bucketSecond*1000))
+	}
+	return
+}
+
+// Total 全部 时段 合并 后 的 分位
+func (this_ *Latency) Total() *Percentile {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	total := &Histogram{}
+	var minKey int64 = -1
+	for key, histogram := range this_.buckets {
+		total.Merge(histogram)
+		if minKey < 0 || key < minKey {
+			minKey = key
+		}
+	}
+	if minKey < 0 {
+		minKey = 0
+	}
+	return total.Percentile(minKey * this_.bucketSecond * 1000)
+}
+
+// Match 返回 开始 时间 所在 时段 的 分位，即 时段 开始 时间 不 大于 startNano 的 最后 一个，buckets 按 时间 升序
+func Match(buckets []*Percentile, startNano int64) (res *Percentile) {
+	startMilli := startNano / int64(1e6)
+	for _, one := range buckets {
+		if one.Time > startMilli {
+			break
+		}
+		res = one
+	}
+	return
+}
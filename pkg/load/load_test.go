@@ -0,0 +1,131 @@
+package load
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/task"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	h := &Histogram{}
+	for i := int64(1); i <= 10000; i++ {
+		h.Record(i)
+	}
+	for _, one := range []struct {
+		q      float64
+		expect float64
+	}{{0.5, 5000}, {0.9, 9000}, {0.99, 9900}, {0.999, 9990}} {
+		v := h.Quantile(one.q)
+		if math.Abs(v-one.expect)/one.expect > 0.04 {
+			t.Errorf("quantile %v expect about %v, got %v", one.q, one.expect, v)
+		}
+	}
+	if h.Quantile(1) != 10000 {
+		t.Errorf("max quantile expect 10000, got %v", h.Quantile(1))
+	}
+}
+
+func TestProfileTargetWorker(t *testing.T) {
+	ramp := &Profile{Mode: ModeRamp, StartWorker: 0, RampUpSecond: 10, RampDownSecond: 10}
+	for _, one := range []struct {
+		elapsed int64
+		expect  int
+	}{{0, 0}, {5000, 5}, {10000, 10}, {15000, 10}, {25000, 5}, {29999, 1}, {30000, 0}} {
+		if n := ramp.TargetWorker(10, one.elapsed, 10000); n != one.expect {
+			t.Errorf("ramp elapsed %d expect %d, got %d", one.elapsed, one.expect, n)
+		}
+	}
+	if ramp.TotalMilli(10, 10000) != 30000 {
+		t.Errorf("ramp total expect 30000, got %d", ramp.TotalMilli(10, 10000))
+	}
+
+	step := &Profile{Mode: ModeStep, StepWorker: 3, StepSecond: 5}
+	for _, one := range []struct {
+		elapsed int64
+		expect  int
+	}{{0, 3}, {4999, 3}, {5000, 6}, {10000, 9}, {15000, 10}, {60000, 10}} {
+		if n := step.TargetWorker(10, one.elapsed, -1); n != one.expect {
+			t.Errorf("step elapsed %d expect %d, got %d", one.elapsed, one.expect, n)
+		}
+	}
+	if step.TotalMilli(10, 1000) != 16000 {
+		t.Errorf("step total expect 16000, got %d", step.TotalMilli(10, 1000))
+	}
+}
+
+func TestProfileRate(t *testing.T) {
+	for _, one := range []struct {
+		rate     int
+		error    bool
+		interval time.Duration
+	}{
+		{0, true, time.Second},
+		{100, false, 10 * time.Millisecond},
+		{MaxRate, false, time.Microsecond},
+		// 超过 MaxRate 时 间隔 不能 为 0
+		{2000000000, true, time.Microsecond},
+	} {
+		profile := &Profile{Mode: ModeRate, Rate: one.rate}
+		if err := profile.Check(1); (err != nil) != one.error {
+			t.Errorf("rate %d expect error %v, got %v", one.rate, one.error, err)
+		}
+		if interval := profile.rateInterval(); interval != one.interval {
+			t.Errorf("rate %d expect interval %v, got %v", one.rate, one.interval, interval)
+		}
+	}
+}
+
+type testExecutor struct {
+	sleep time.Duration
+	count int64
+}
+
+func (this_ *testExecutor) Before(_ *task.ExecutorParam) error { return nil }
+func (this_ *testExecutor) Execute(_ *task.ExecutorParam) error {
+	time.Sleep(this_.sleep)
+	if atomic.AddInt64(&this_.count, 1)%10 == 0 {
+		return errors.New("error")
+	}
+	return nil
+}
+func (this_ *testExecutor) After(_ *task.ExecutorParam) error { return nil }
+
+func TestRunnerRate(t *testing.T) {
+	executor := &testExecutor{sleep: 20 * time.Millisecond}
+	tk, err := task.New(&task.Options{Key: "rate", Worker: 1, Frequency: 20, Executor: executor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 单 线程 每 20 毫秒 处理 1 次，每秒 到达 100 次，排队 等待 计入 耗时
+	runner := NewRunner(tk, &Profile{Mode: ModeRate, Rate: 100}, 1)
+	runner.Run()
+	if !tk.IsEnd || executor.count != 20 {
+		t.Fatalf("expect end with 20 executions, got end %v count %d", tk.IsEnd, executor.count)
+	}
+	total := runner.Latency.Total()
+	if total.Count != 20 || total.Max < 150 {
+		t.Errorf("expect corrected latency include queue wait, got %+v", total)
+	}
+	count := tk.Metric.GetCount()
+	if count.ErrorCount != 2 || count.SuccessCount != 18 {
+		t.Errorf("expect 2 errors and 18 success, got %d %d", count.ErrorCount, count.SuccessCount)
+	}
+}
+
+func TestRunnerStep(t *testing.T) {
+	executor := &testExecutor{sleep: 10 * time.Millisecond}
+	runner, err := New(&task.Options{Key: "step", Worker: 4, Executor: executor}, &Profile{Mode: ModeStep, StepWorker: 2, StepSecond: 1, HoldSecond: 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.Run()
+	if len(runner.metrics) != 4 {
+		t.Errorf("expect 4 workers started, got %d", len(runner.metrics))
+	}
+	if len(runner.Latency.Buckets()) < 2 {
+		t.Errorf("expect latency buckets per second, got %d", len(runner.Latency.Buckets()))
+	}
+}
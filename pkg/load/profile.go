@@ -0,0 +1,131 @@
+package load
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	ModeFixed = ""     // 固定 线程 数，使用 任务 原有 的 执行 方式
+	ModeRamp  = "ramp" // 线性 增加 到 Worker，保持 后 线性 减少 到 0
+	ModeStep  = "step" // 每 StepSecond 秒 增加 StepWorker 个 线程，到达 Worker 后 保持
+	ModeRate  = "rate" // 开放 模型，每秒 固定 到达 Rate 次，最多 Worker 个 并发
+
+	MaxRate = 1000000 // rate 模式 每秒 最大 到达 次数，保证 到达 间隔 不 小于 1 微秒
+)
+
+// Profile 负载 模型
+// 保持 时长 为 HoldSecond，为 0 时 使用 任务 的 Duration（分钟），都 为 0 时 执行 到 Frequency 次数 为止
+type Profile struct {
+	Mode           string `json:"mode,omitempty"`
+	StartWorker    int    `json:"startWorker,omitempty"` // ramp、step 的 初始 线程 数
+	RampUpSecond   int    `json:"rampUpSecond,omitempty"`
+	HoldSecond     int    `json:"holdSecond,omitempty"`
+	RampDownSecond int    `json:"rampDownSecond,omitempty"`
+	StepWorker     int    `json:"stepWorker,omitempty"` // step 每 阶 增加 的 线程 数
+	StepSecond     int    `json:"stepSecond,omitempty"` // step 每 阶 保持 秒 数
+	Rate           int    `json:"rate,omitempty"`       // rate 每秒 到达 次数
+}
+
+func (this_ *Profile) IsFixed() bool {
+	return this_ == nil || this_.Mode == ModeFixed
+}
+
+// Check 校验 配置，worker 为 最大 线程 数
+func (this_ *Profile) Check(worker int) (err error) {
+	if this_.IsFixed() {
+		return
+	}
+	if worker <= 0 {
+		err = errors.New("load profile worker must be greater than 0")
+		return
+	}
+	switch this_.Mode {
+	case ModeRamp:
+		if this_.StartWorker < 0 || this_.StartWorker > worker {
+			err = errors.New("load profile startWorker must between 0 and worker")
+		}
+	case ModeStep:
+		if this_.StepWorker <= 0 || this_.StepSecond <= 0 {
+			err = errors.New("load profile stepWorker and stepSecond must be greater than 0")
+		}
+	case ModeRate:
+		if this_.Rate <= 0 || this_.Rate > MaxRate {
+			err = errors.New("load profile rate must between 1 and " + strconv.Itoa(MaxRate))
+		}
+	default:
+		err = errors.New("load profile mode [" + this_.Mode + "] not support")
+	}
+	return
+}
+
+// rateInterval rate 模式 的 到达 间隔，Rate 限制 在 1 到 MaxRate 之间
+func (this_ *Profile) rateInterval() time.Duration {
+	rate := this_.Rate
+	if rate < 1 {
+		rate = 1
+	} else if rate > MaxRate {
+		rate = MaxRate
+	}
+	return time.Second / time.Duration(rate)
+}
+
+// stepUpSecond 阶梯 增加 到 最大 线程 数 所需 的 秒 数
+func (this_ *Profile) stepUpSecond(worker int) int {
+	start := this_.StartWorker
+	if start <= 0 {
+		start = this_.StepWorker
+	}
+	if start >= worker {
+		return 0
+	}
+	steps := (worker - start + this_.StepWorker - 1) / this_.StepWorker
+	return steps * this_.StepSecond
+}
+
+// TotalMilli 负载 总 时长 毫秒，holdMilli 为 保持 时长，小于 0 表示 不限
+func (this_ *Profile) TotalMilli(worker int, holdMilli int64) int64 {
+	if holdMilli < 0 {
+		return -1
+	}
+	switch this_.Mode {
+	case ModeRamp:
+		return int64(this_.RampUpSecond+this_.RampDownSecond)*1000 + holdMilli
+	case ModeStep:
+		return int64(this_.stepUpSecond(worker))*1000 + holdMilli
+	}
+	return holdMilli
+}
+
+// TargetWorker 开始 后 elapsedMilli 毫秒 时 的 目标 线程 数，holdMilli 小于 0 表示 一直 保持
+func (this_ *Profile) TargetWorker(worker int, elapsedMilli int64, holdMilli int64) int {
+	switch this_.Mode {
+	case ModeRamp:
+		upMilli := int64(this_.RampUpSecond) * 1000
+		if elapsedMilli < upMilli {
+			return this_.StartWorker + int(int64(worker-this_.StartWorker)*elapsedMilli/upMilli)
+		}
+		if holdMilli < 0 || elapsedMilli < upMilli+holdMilli {
+			return worker
+		}
+		downMilli := int64(this_.RampDownSecond) * 1000
+		elapsedMilli -= upMilli + holdMilli
+		if elapsedMilli >= downMilli {
+			return 0
+		}
+		// 向上 取整，保证 减少 阶段 至少 保留 1 个 线程 直到 结束
+		return int((int64(worker)*(downMilli-elapsedMilli) + downMilli - 1) / downMilli)
+	case ModeStep:
+		start := this_.StartWorker
+		if start <= 0 {
+			start = this_.StepWorker
+		}
+		n := start + int(elapsedMilli/(int64(this_.StepSecond)*1000))*this_.StepWorker
+		if n > worker {
+			n = worker
+		}
+		return n
+	}
+	return worker
+}
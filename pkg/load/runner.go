@@ -0,0 +1,320 @@
+package load
+
+import (
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/metric"
+	"github.com/team-ide/go-tool/task"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// Runner 按 负载 模型 执行 任务，替代 task.Run
+// 复用 任务 的 Executor、Metric、Stop 和 回调，固定 线程 数 时 直接 调用 task.Run 并 记录 耗时 直方图
+type Runner struct {
+	Latency *Latency
+
+	task      *task.Task
+	profile   *Profile
+	onExecute func(param *task.ExecutorParam)
+
+	nextIndex int
+	finished  bool
+	target    int
+	running   []bool
+	metrics   []*metric.WorkerMetric
+	lock      sync.Mutex
+	waitGroup sync.WaitGroup
+}
+
+// New 创建 任务 和 执行器，bucketSecond 为 耗时 分位 的 统计 时段 秒 数，与 Metric 的 统计 间隔 保持 一致
+// 负载 模型 只 配置 HoldSecond 时 无需 Duration、Frequency
+func New(options *task.Options, profile *Profile, bucketSecond int) (runner *Runner, err error) {
+	if err = profile.Check(options.Worker); err != nil {
+		return
+	}
+	duration := options.Duration
+	if !profile.IsFixed() && options.Duration <= 0 && options.Frequency <= 0 && profile.HoldSecond > 0 {
+		options.Duration = 1
+	}
+	t, err := task.New(options)
+	options.Duration = duration
+	if err != nil {
+		return
+	}
+	runner = NewRunner(t, profile, bucketSecond)
+	return
+}
+
+func NewRunner(t *task.Task, profile *Profile, bucketSecond int) *Runner {
+	return &Runner{
+		Latency: NewLatency(bucketSecond),
+		task:    t,
+		profile: profile,
+	}
+}
+
+func (this_ *Runner) Task() *task.Task {
+	return this_.task
+}
+
+func (this_ *Runner) Run() {
+	t := this_.task
+	this_.onExecute = t.OnExecute
+	if this_.profile.IsFixed() {
+		t.OnExecute = func(param *task.ExecutorParam) {
+			if !param.ExecuteEndTime.IsZero() {
+				this_.Latency.Record(param.ExecuteStartTime.UnixNano(), param.ExecuteEndTime.UnixNano()-param.ExecuteStartTime.UnixNano())
+			}
+			if this_.onExecute != nil {
+				this_.onExecute(param)
+			}
+		}
+		t.Run()
+		return
+	}
+	if t.IsStart {
+		return
+	}
+	util.Logger.Info("负载任务执行 [Start]", zap.Any("Key", t.Key), zap.Any("profile", this_.profile))
+	t.IsStart = true
+	t.IsEnd = false
+	t.StartTime = time.Now()
+	defer func() {
+		if e := recover(); e != nil {
+			err := errors.New(fmt.Sprintf("负载任务执行 异常:%s", e))
+			t.Errors = append(t.Errors, err)
+			util.Logger.Error("load run error", zap.Error(err))
+		}
+		t.Metric.StopCount()
+		if t.OnEnd != nil {
+			t.OnEnd()
+		}
+		t.EndTime = time.Now()
+		t.IsEnd = true
+	}()
+	if t.IsStopped() {
+		return
+	}
+	if t.OnStart != nil {
+		t.OnStart()
+	}
+	t.Metric.StartCount()
+
+	if this_.profile.Mode == ModeRate {
+		this_.runRate()
+	} else {
+		this_.runWorkers()
+	}
+}
+
+// holdMilli 保持 时长，-1 表示 执行 到 Frequency 次数 为止
+func (this_ *Runner) holdMilli() int64 {
+	if this_.profile.HoldSecond > 0 {
+		return int64(this_.profile.HoldSecond) * 1000
+	}
+	if this_.task.Duration > 0 {
+		return int64(this_.task.Duration) * 60 * 1000
+	}
+	return -1
+}
+
+func (this_ *Runner) next() int {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.finished || this_.task.IsStopped() {
+		return -1
+	}
+	if this_.task.Frequency > 0 && this_.nextIndex >= this_.task.Frequency {
+		this_.finished = true
+		return -1
+	}
+	index := this_.nextIndex
+	this_.nextIndex++
+	return index
+}
+
+func (this_ *Runner) isFinished() bool {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	return this_.finished || this_.task.IsStopped()
+}
+
+func (this_ *Runner) finish() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.finished = true
+}
+
+func (this_ *Runner) getWorkerMetric(workerIndex int) *metric.WorkerMetric {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	for len(this_.metrics) <= workerIndex {
+		this_.metrics = append(this_.metrics, this_.task.Metric.NewWorkerMetric(len(this_.metrics)))
+	}
+	return this_.metrics[workerIndex]
+}
+
+// runWorkers ramp、step 模式，每 100 毫秒 按 目标 线程 数 启动 线程，超出 目标 的 线程 执行 完 当前 次 后 退出
+func (this_ *Runner) runWorkers() {
+	worker := this_.task.Worker
+	holdMilli := this_.holdMilli()
+	totalMilli := this_.profile.TotalMilli(worker, holdMilli)
+	this_.running = make([]bool, worker)
+	start := time.Now()
+	for !this_.isFinished() {
+		elapsed := time.Since(start).Milliseconds()
+		if totalMilli >= 0 && elapsed >= totalMilli {
+			break
+		}
+		target := this_.profile.TargetWorker(worker, elapsed, holdMilli)
+		this_.lock.Lock()
+		this_.target = target
+		for i := 0; i < target; i++ {
+			if this_.running[i] {
+				continue
+			}
+			this_.running[i] = true
+			this_.waitGroup.Add(1)
+			go this_.work(i)
+		}
+		this_.lock.Unlock()
+		time.Sleep(100 * time.Millisecond)
+	}
+	this_.finish()
+	this_.waitGroup.Wait()
+}
+
+func (this_ *Runner) work(workerIndex int) {
+	defer this_.waitGroup.Done()
+	workerMetric := this_.getWorkerMetric(workerIndex)
+	for {
+		this_.lock.Lock()
+		if this_.finished || workerIndex >= this_.target {
+			this_.running[workerIndex] = false
+			this_.lock.Unlock()
+			return
+		}
+		this_.lock.Unlock()
+
+		index := this_.next()
+		if index < 0 {
+			this_.lock.Lock()
+			this_.running[workerIndex] = false
+			this_.lock.Unlock()
+			return
+		}
+		this_.runExecutor(&task.ExecutorParam{Index: index, WorkerIndex: workerIndex}, workerMetric, time.Time{})
+	}
+}
+
+type arrival struct {
+	index     int
+	scheduled time.Time
+}
+
+// runRate rate 模式，按 计划 时间 到达，线程 都 忙 时 请求 排队
+// 耗时 从 计划 时间 开始 计算，包含 排队 等待，避免 协调 遗漏（coordinated omission）导致 的 耗时 偏低
+func (this_ *Runner) runRate() {
+	worker := this_.task.Worker
+	holdMilli := this_.holdMilli()
+	interval := this_.profile.rateInterval()
+	arrivals := make(chan *arrival, worker*2)
+	for i := 0; i < worker; i++ {
+		this_.waitGroup.Add(1)
+		go func(workerIndex int) {
+			defer this_.waitGroup.Done()
+			workerMetric := this_.getWorkerMetric(workerIndex)
+			for one := range arrivals {
+				if this_.task.IsStopped() {
+					continue
+				}
+				this_.runExecutor(&task.ExecutorParam{Index: one.index, WorkerIndex: workerIndex}, workerMetric, one.scheduled)
+			}
+		}(i)
+	}
+	start := time.Now()
+	for i := 0; ; i++ {
+		scheduled := start.Add(time.Duration(i) * interval)
+		if holdMilli >= 0 && scheduled.Sub(start).Milliseconds() >= holdMilli {
+			break
+		}
+		if wait := time.Until(scheduled); wait > 0 {
+			time.Sleep(wait)
+		}
+		index := this_.next()
+		if index < 0 {
+			break
+		}
+		arrivals <- &arrival{index: index, scheduled: scheduled}
+	}
+	close(arrivals)
+	this_.finish()
+	this_.waitGroup.Wait()
+}
+
+func (this_ *Runner) count(counter *int) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	*counter++
+}
+
+// runExecutor 与 task 的 执行 流程 一致，scheduled 不为 空 时 耗时 直方图 从 计划 时间 开始 计算
+func (this_ *Runner) runExecutor(param *task.ExecutorParam, workerMetric *metric.WorkerMetric, scheduled time.Time) {
+	t := this_.task
+	var err error
+	param.StartTime = time.Now()
+	item := workerMetric.NewItem(param.StartTime.UnixNano())
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprintf("负载任务执行 [runExecutor] 异常:%s", e))
+			util.Logger.Error("load runExecutor error", zap.Error(err))
+		}
+		param.EndTime = time.Now()
+		param.Error = err
+		if err != nil {
+			this_.count(&t.ExecutorErrorCount)
+		} else {
+			this_.count(&t.ExecutorSuccessCount)
+		}
+		if !param.ExecuteEndTime.IsZero() {
+			executeUse := param.ExecuteEndTime.UnixNano() - param.ExecuteStartTime.UnixNano()
+			item.Extend = param.Extend
+			item.End(int(executeUse), param.EndTime.UnixNano(), param.Error)
+			if scheduled.IsZero() {
+				this_.Latency.Record(param.ExecuteStartTime.UnixNano(), executeUse)
+			} else {
+				this_.Latency.Record(scheduled.UnixNano(), param.ExecuteEndTime.UnixNano()-scheduled.UnixNano())
+			}
+		}
+		if this_.onExecute != nil {
+			this_.onExecute(param)
+		}
+	}()
+
+	param.BeforeStartTime = time.Now()
+	err = t.Executor.Before(param)
+	param.BeforeEndTime = time.Now()
+	this_.count(&t.ExecutorBeforeCount)
+	if err != nil {
+		return
+	}
+	param.ExecuteStartTime = time.Now()
+	err = t.Executor.Execute(param)
+	param.ExecuteEndTime = time.Now()
+	this_.count(&t.ExecutorExecuteCount)
+	if err != nil {
+		return
+	}
+	param.AfterStartTime = time.Now()
+	err = t.Executor.After(param)
+	param.AfterEndTime = time.Now()
+	this_.count(&t.ExecutorAfterCount)
+}
@@ -0,0 +1,164 @@
+package loadtask
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/team-ide/go-tool/javascript"
+	"github.com/team-ide/go-tool/task"
+	"github.com/team-ide/go-tool/util"
+	"github.com/team-ide/goja"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var scriptPattern = regexp.MustCompile(`[$]+{(.+?)}`)
+
+func NewArgFormat() (res *ArgFormat, err error) {
+	res = &ArgFormat{}
+	res.runtime = goja.New()
+	for key, value := range javascript.NewContext() {
+		err = res.runtime.Set(key, value)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ArgFormat 参数 中 的 ${脚本} 每次 调用 时 执行，脚本 中 可以 使用 index、workerIndex
+type ArgFormat struct {
+	runtime     *goja.Runtime
+	lock        sync.Mutex
+	ExportValue bool // 字符串 使用 导出 值 不 带 引号，如 场景 中 引用 前面 步骤 的 响应、gRPC 的 JSON 参数
+}
+
+func (this_ *ArgFormat) ScriptValue(script string, param *task.ExecutorParam) (res string, err error) {
+	if script == "" {
+		return
+	}
+
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if param == nil {
+		param = &task.ExecutorParam{}
+	}
+	if err = this_.runtime.Set("index", param.Index); err != nil {
+		return
+	}
+	if err = this_.runtime.Set("workerIndex", param.WorkerIndex); err != nil {
+		return
+	}
+	v, err := this_.runtime.RunString(script)
+	if err != nil {
+		err = errors.New("get scriptValue error:" + err.Error())
+		return
+	}
+	if str, ok := v.Export().(string); ok && this_.ExportValue {
+		res = str
+	} else {
+		res = util.GetStringValue(v)
+	}
+	return
+}
+
+// FormatArg 执行 参数 中 的 脚本，数组 和 对象 递归 处理
+func (this_ *ArgFormat) FormatArg(arg interface{}, param *task.ExecutorParam) (res interface{}, err error) {
+	switch tV := arg.(type) {
+	case string:
+		if !strings.Contains(tV, "${") {
+			res = tV
+			return
+		}
+		text := ""
+		lastIndex := 0
+		for _, indexes := range scriptPattern.FindAllStringSubmatchIndex(tV, -1) {
+			text += tV[lastIndex:indexes[0]]
+			lastIndex = indexes[1]
+			var v string
+			v, err = this_.ScriptValue(tV[indexes[2]:indexes[3]], param)
+			if err != nil {
+				return
+			}
+			text += v
+		}
+		res = text + tV[lastIndex:]
+	case []interface{}:
+		var list []interface{}
+		for _, one := range tV {
+			var v interface{}
+			if v, err = this_.FormatArg(one, param); err != nil {
+				return
+			}
+			list = append(list, v)
+		}
+		res = list
+	case map[string]interface{}:
+		data := map[string]interface{}{}
+		for key, one := range tV {
+			var v interface{}
+			if v, err = this_.FormatArg(one, param); err != nil {
+				return
+			}
+			data[key] = v
+		}
+		res = data
+	default:
+		res = tV
+	}
+	return
+}
+
+func (this_ *ArgFormat) FormatArgs(args []interface{}, param *task.ExecutorParam) (res []interface{}, err error) {
+	for _, arg := range args {
+		var v interface{}
+		v, err = this_.FormatArg(arg, param)
+		if err != nil {
+			return
+		}
+		res = append(res, v)
+	}
+	return
+}
+
+// SetVars 设置 脚本 变量，如 场景 中 前面 步骤 的 响应
+func (this_ *ArgFormat) SetVars(vars map[string]interface{}) (err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	for key, value := range vars {
+		err = this_.runtime.Set(key, value)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// RunScript 设置 变量 后 执行 脚本，返回 最后 一个 表达式 的 值，结果 转换 为 JSON 类型
+func (this_ *ArgFormat) RunScript(script string, vars map[string]interface{}) (res interface{}, err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	for key, value := range vars {
+		err = this_.runtime.Set(key, value)
+		if err != nil {
+			return
+		}
+	}
+	v, err := this_.runtime.RunString(script)
+	if err != nil {
+		err = errors.New("run script error:" + err.Error())
+		return
+	}
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return
+	}
+	bs, err := json.Marshal(v.Export())
+	if err != nil {
+		return
+	}
+	err = util.JSONDecodeUseNumber(bs, &res)
+	return
+}
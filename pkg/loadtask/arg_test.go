@@ -0,0 +1,91 @@
+package loadtask
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestScriptValue(t *testing.T) {
+	format, err := NewArgFormat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exportFormat, err := NewArgFormat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exportFormat.ExportValue = true
+	for _, one := range []struct {
+		script       string
+		expect       string
+		exportExpect string
+	}{
+		{"true", "true", "true"},
+		{"1+2", "3", "3"},
+		{`"abc"`, `"abc"`, "abc"},
+		{"", "", ""},
+	} {
+		res, err := format.ScriptValue(one.script, nil)
+		if err != nil || res != one.expect {
+			t.Errorf("script %s expect %s, got %s %v", one.script, one.expect, res, err)
+		}
+		res, err = exportFormat.ScriptValue(one.script, nil)
+		if err != nil || res != one.exportExpect {
+			t.Errorf("export script %s expect %s, got %s %v", one.script, one.exportExpect, res, err)
+		}
+	}
+	if _, err = format.ScriptValue("a b", nil); err == nil {
+		t.Errorf("invalid script expect error")
+	}
+}
+
+func TestFormatArgs(t *testing.T) {
+	format, err := NewArgFormat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	format.ExportValue = true
+	if err = format.SetVars(map[string]interface{}{"token": "t1"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := format.FormatArgs([]interface{}{
+		"token=${token}",
+		"$${token}",
+		map[string]interface{}{"token": "${token}", "n": json.Number("1")},
+		[]interface{}{"${1+1}", nil},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []interface{}{
+		"token=t1",
+		"t1",
+		map[string]interface{}{"token": "t1", "n": json.Number("1")},
+		[]interface{}{"2", nil},
+	}
+	if !reflect.DeepEqual(res, expect) {
+		t.Errorf("format args expect %v, got %v", expect, res)
+	}
+	if _, err = format.FormatArgs([]interface{}{"${a b}"}, nil); err == nil {
+		t.Errorf("invalid script expect error")
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	format, err := NewArgFormat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := format.RunScript(`({a: data.n + 1, b: [true]})`, map[string]interface{}{"data": map[string]interface{}{"n": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{"a": json.Number("2"), "b": []interface{}{true}}
+	if !reflect.DeepEqual(res, expect) {
+		t.Errorf("run script expect %v, got %v", expect, res)
+	}
+	if res, err = format.RunScript(`undefined`, nil); err != nil || res != nil {
+		t.Errorf("undefined expect nil, got %v %v", res, err)
+	}
+}
@@ -0,0 +1,356 @@
+package loadtask
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/metric"
+	"github.com/team-ide/go-tool/task"
+	"github.com/team-ide/go-tool/util"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/pkg/load"
+	"time"
+)
+
+// Manager 压测 任务 管理，任务 报告 保存 在 文件 目录 下 的 {name}/toolbox-{toolboxId}/{分组}/{taskKey}/ 中
+// 分组 由 各 模块 定义，如 Thrift 为 文件/服务/方法，gRPC 为 服务/方法
+type Manager struct {
+	name        string
+	getFilesDir func() string
+	taskCache   map[string]*task.Task
+	taskLocker  sync.Mutex
+}
+
+func NewManager(name string, getFilesDir func() string) *Manager {
+	return &Manager{
+		name:        name,
+		getFilesDir: getFilesDir,
+		taskCache:   map[string]*task.Task{},
+	}
+}
+
+// CheckName 校验 路径 中 的 名称，如 任务 key，不能 为空、包含 路径 分隔符 或 为 . ..
+func CheckName(name string) (err error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		err = errors.New("name [" + name + "] is invalid")
+		return
+	}
+	return
+}
+
+// CheckRelativePath 校验 相对 路径，不能 为空、是 绝对 路径 或 包含 ..，避免 访问 任务 目录 外 的 文件
+func CheckRelativePath(path string) (err error) {
+	if path == "" || strings.HasPrefix(path, "/") || strings.HasPrefix(path, `\`) {
+		err = errors.New("path [" + path + "] is invalid")
+		return
+	}
+	for _, one := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if one == ".." {
+			err = errors.New("path [" + path + "] is invalid")
+			return
+		}
+	}
+	return
+}
+
+// ParentRelativePath 任务 父 目录 的 相对 路径，以 / 结尾，group 的 每 一项 使用 CheckRelativePath 校验
+func (this_ *Manager) ParentRelativePath(toolboxId int64, group ...string) (relativePath string, err error) {
+	relativePath = fmt.Sprintf("%s/toolbox-%d/", this_.name, toolboxId)
+	for _, one := range group {
+		if err = CheckRelativePath(one); err != nil {
+			return
+		}
+		relativePath += strings.Trim(one, "/") + "/"
+	}
+	return
+}
+
+// ParentDir 任务 父 目录，不存在 时 创建
+func (this_ *Manager) ParentDir(parentRelativePath string) (dir string, err error) {
+	dir = this_.getFilesDir() + parentRelativePath
+	ex, err := util.PathExists(dir)
+	if err != nil {
+		return
+	}
+	if !ex {
+		err = os.MkdirAll(dir, fs.ModePerm)
+	}
+	return
+}
+
+// TaskDir 任务 目录，taskKey 使用 CheckName 校验
+func (this_ *Manager) TaskDir(parentRelativePath string, taskKey string) (dir string, err error) {
+	if err = CheckName(taskKey); err != nil {
+		return
+	}
+	dir, err = this_.ParentDir(parentRelativePath)
+	if err != nil {
+		return
+	}
+	dir += taskKey
+	return
+}
+
+func (this_ *Manager) GetTask(taskKey string) *task.Task {
+	this_.taskLocker.Lock()
+	defer this_.taskLocker.Unlock()
+
+	return this_.taskCache[taskKey]
+}
+
+func (this_ *Manager) addTask(task *task.Task) {
+	this_.taskLocker.Lock()
+	defer this_.taskLocker.Unlock()
+
+	this_.taskCache[task.Key] = task
+}
+
+func (this_ *Manager) removeTask(taskKey string) {
+	this_.taskLocker.Lock()
+	defer this_.taskLocker.Unlock()
+
+	delete(this_.taskCache, taskKey)
+}
+
+// Run 启动 任务，执行 中 每秒 调用 save 保存 任务 信息，结束 后 调用 finish 并 再次 保存
+func (this_ *Manager) Run(runner *load.Runner, save func(), finish func()) {
+	t := runner.Task()
+	save()
+	go func() {
+		defer func() {
+			this_.removeTask(t.Key)
+			save()
+			finish()
+			save()
+		}()
+		for !t.IsEnd {
+			save()
+			time.Sleep(time.Second * 1)
+		}
+	}()
+	go runner.Run()
+	this_.addTask(t)
+}
+
+// Stop 停止 任务，等待 任务 结束
+func (this_ *Manager) Stop(taskKey string) {
+	t := this_.GetTask(taskKey)
+	if t == nil {
+		return
+	}
+	t.Stop()
+	for this_.GetTask(taskKey) != nil {
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+// SaveTask 保存 任务 信息 和 统计，extend 为 info.json 中 额外 的 数据
+func SaveTask(taskDir string, request interface{}, t *task.Task, latency *load.Latency, extend map[string]interface{}) (err error) {
+	ex, err := util.PathExists(taskDir)
+	if err != nil {
+		return
+	}
+	if !ex {
+		if err = os.MkdirAll(taskDir, fs.ModePerm); err != nil {
+			return
+		}
+	}
+
+	bs, _ := json.Marshal(request)
+	data := map[string]interface{}{}
+	for key, value := range extend {
+		data[key] = value
+	}
+	data["requestMd5"] = util.GetMD5(string(bs))
+	data["request"] = request
+	data["task"] = t
+	data["taskKey"] = t.Key
+	c := t.Metric.GetCount()
+	data["metric"] = c
+	if latency != nil {
+		data["latency"] = latency.Total()
+	}
+	if err = WriteJSON(taskDir, "info.json", data); err != nil {
+		return
+	}
+	_ = WriteJSON(taskDir, "metric.json", c)
+	_ = WriteJSON(taskDir, "metric.second.json", t.Metric.GetSecondCounts())
+	if latency != nil {
+		_ = WriteJSON(taskDir, "latency.json", latency.Buckets())
+	}
+	return
+}
+
+// WriteJSON 保存 任务 目录 下 的 JSON 文件
+func WriteJSON(taskDir string, name string, value interface{}) (err error) {
+	bs, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	err = util.WriteFile(taskDir+"/"+name, bs)
+	return
+}
+
+// ReadJSON 读取 任务 目录 下 的 JSON 文件，文件 不存在 时 不 处理
+func ReadJSON(taskDir string, name string, value interface{}) (err error) {
+	filename := taskDir + "/" + name
+	if ex, _ := util.PathExists(filename); !ex {
+		return
+	}
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+	err = util.JSONDecodeUseNumber(bs, value)
+	return
+}
+
+// ReadMetric 读取 每个 统计 时段 的 统计 和 耗时 分位
+func ReadMetric(taskDir string) (counts []*metric.Count, latency []*load.Percentile, err error) {
+	if err = ReadJSON(taskDir, "metric.second.json", &counts); err != nil {
+		return
+	}
+	err = ReadJSON(taskDir, "latency.json", &latency)
+	return
+}
+
+// LoadTask 读取 任务 信息，没有 任务 信息 时 返回 nil
+func (this_ *Manager) LoadTask(taskDir string) (data map[string]interface{}, err error) {
+	defer func() {
+		if len(data) == 0 {
+			data = nil
+		}
+	}()
+	data = map[string]interface{}{}
+	data["isEnd"] = true
+
+	if ex, _ := util.PathExists(taskDir + "/info.json"); !ex {
+		return
+	}
+	if err = ReadJSON(taskDir, "info.json", &data); err != nil {
+		return
+	}
+	if data["metric"] == nil {
+		data["metric"] = &metric.Count{}
+	}
+	if data["taskKey"] != nil {
+		data["isEnd"] = this_.GetTask(util.GetStringValue(data["taskKey"])) == nil
+	}
+	return
+}
+
+// LoadTasks 读取 父 目录 下 的 所有 任务 信息，按 任务 key 倒序
+func (this_ *Manager) LoadTasks(parentRelativePath string) (taskList []map[string]interface{}, err error) {
+	parentDir, err := this_.ParentDir(parentRelativePath)
+	if err != nil {
+		return
+	}
+	fileList, err := os.ReadDir(parentDir)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, f := range fileList {
+		if !f.IsDir() {
+			continue
+		}
+		names = append(names, f.Name())
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToLower(names[i]) < strings.ToLower(names[j])
+	})
+	var taskInfo map[string]interface{}
+	for i := len(names) - 1; i >= 0; i-- {
+		taskInfo, err = this_.LoadTask(parentDir + names[i])
+		if err != nil {
+			return
+		}
+		if taskInfo != nil {
+			taskInfo["taskRelativePath"] = parentRelativePath + names[i]
+			taskList = append(taskList, taskInfo)
+		}
+	}
+	return
+}
+
+// Delete 删除 任务，requestMd5 不为空 时 删除 相同 请求 的 所有 任务，执行 中 的 任务 先 停止
+func (this_ *Manager) Delete(parentRelativePath string, taskKey string, requestMd5 string) (err error) {
+	var removeKeys []string
+	if requestMd5 == "" {
+		removeKeys = append(removeKeys, taskKey)
+	} else {
+		var taskList []map[string]interface{}
+		taskList, err = this_.LoadTasks(parentRelativePath)
+		if err != nil {
+			return
+		}
+		for _, t := range taskList {
+			if util.GetStringValue(t["requestMd5"]) == requestMd5 {
+				removeKeys = append(removeKeys, util.GetStringValue(t["taskKey"]))
+			}
+		}
+	}
+	for _, removeKey := range removeKeys {
+		var taskDir string
+		taskDir, err = this_.TaskDir(parentRelativePath, removeKey)
+		if err != nil {
+			return
+		}
+		if t := this_.GetTask(removeKey); t != nil {
+			t.Stop()
+		}
+		if ex, _ := util.PathExists(taskDir); ex {
+			if err = os.RemoveAll(taskDir); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// DownloadRecords 下载 执行 记录，taskRelativePath 为 LoadTasks 返回 的 相对 路径
+func (this_ *Manager) DownloadRecords(c *gin.Context, fileName string, taskRelativePath string) (err error) {
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Transfer-Encoding", "binary")
+	defer func() {
+		if err != nil {
+			_, _ = c.Writer.WriteString(err.Error())
+		}
+	}()
+
+	if err = CheckRelativePath(taskRelativePath); err != nil {
+		return
+	}
+	if !strings.HasPrefix(taskRelativePath, this_.name+"/") {
+		err = errors.New("task path [" + taskRelativePath + "] is invalid")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.QueryEscape(fileName)))
+	// 此处不设置 文件大小，如果设置文件大小，将无法终止下载
+	c.Header("download-file-name", fileName)
+
+	filename := this_.getFilesDir() + taskRelativePath + "/records.txt"
+	if ex, _ := util.PathExists(filename); ex {
+		var f *os.File
+		f, err = os.Open(filename)
+		if err != nil {
+			return
+		}
+		defer func() { _ = f.Close() }()
+		_, err = io.Copy(c.Writer, f)
+	} else {
+		_, err = c.Writer.WriteString("暂无执行记录")
+	}
+	c.Status(http.StatusOK)
+	return
+}
@@ -0,0 +1,164 @@
+package loadtask
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/task"
+	"github.com/team-ide/go-tool/util"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"teamide/pkg/load"
+	"testing"
+	"time"
+)
+
+func TestCheckPath(t *testing.T) {
+	for _, one := range []struct {
+		path         string
+		name         bool
+		relativePath bool
+	}{
+		{"a", true, true},
+		{"a.b", true, true},
+		{"..a", true, true},
+		{"a/b.thrift", false, true},
+		{`a\b`, false, true},
+		{"", false, false},
+		{".", false, true},
+		{"..", false, false},
+		{"a/../..", false, false},
+		{`a\..\b`, false, false},
+		{"/etc", false, false},
+		{`\etc`, false, false},
+	} {
+		if err := CheckName(one.path); (err == nil) != one.name {
+			t.Errorf("name %s expect valid %v, got %v", one.path, one.name, err)
+		}
+		if err := CheckRelativePath(one.path); (err == nil) != one.relativePath {
+			t.Errorf("relative path %s expect valid %v, got %v", one.path, one.relativePath, err)
+		}
+	}
+}
+
+func TestParentRelativePath(t *testing.T) {
+	manager := NewManager("test-tasks", func() string { return "" })
+	res, err := manager.ParentRelativePath(1, "a/b.thrift/", "Service", "method")
+	if err != nil || res != "test-tasks/toolbox-1/a/b.thrift/Service/method/" {
+		t.Errorf("parent relative path error, got %s %v", res, err)
+	}
+	if _, err = manager.ParentRelativePath(1, "../a", "Service"); err == nil {
+		t.Errorf("parent path with .. expect error")
+	}
+	if _, err = manager.TaskDir("test-tasks/toolbox-1/", "../1"); err == nil {
+		t.Errorf("task key with path expect error")
+	}
+}
+
+type noopExecutor struct{}
+
+func (this_ *noopExecutor) Before(_ *task.ExecutorParam) error  { return nil }
+func (this_ *noopExecutor) Execute(_ *task.ExecutorParam) error { return nil }
+func (this_ *noopExecutor) After(_ *task.ExecutorParam) error   { return nil }
+
+func TestManagerRun(t *testing.T) {
+	filesDir := t.TempDir() + "/"
+	manager := NewManager("test-tasks", func() string { return filesDir })
+	parentRelativePath, err := manager.ParentRelativePath(1, "Service", "method")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := map[string]interface{}{"serviceName": "Service"}
+	var taskKeys []string
+	for i := 0; i < 2; i++ {
+		runner, err := load.New(&task.Options{
+			Key:       util.GetStringValue(time.Now().UnixNano()),
+			Worker:    2,
+			Frequency: 10,
+			Executor:  &noopExecutor{},
+		}, nil, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tk := runner.Task()
+		taskDir, err := manager.TaskDir(parentRelativePath, tk.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		finished := make(chan bool, 1)
+		manager.Run(runner, func() {
+			_ = SaveTask(taskDir, request, tk, runner.Latency, map[string]interface{}{"server": "s"})
+		}, func() {
+			finished <- true
+		})
+		select {
+		case <-finished:
+		case <-time.After(10 * time.Second):
+			t.Fatal("task not finished")
+		}
+		manager.Stop(tk.Key)
+		taskKeys = append(taskKeys, tk.Key)
+	}
+
+	taskList, err := manager.LoadTasks(parentRelativePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(taskList) != 2 || taskList[0]["taskKey"] != taskKeys[1] || taskList[0]["server"] != "s" ||
+		taskList[0]["taskRelativePath"] != parentRelativePath+taskKeys[1] {
+		t.Fatalf("task list error, got %v", taskList)
+	}
+	if taskList[0]["isEnd"] != true {
+		t.Errorf("finished task expect isEnd")
+	}
+	counts, latency, err := ReadMetric(filesDir + parentRelativePath + taskKeys[0])
+	if err != nil || len(counts) == 0 || len(latency) == 0 {
+		t.Errorf("metric expect counts and latency, got %d %d %v", len(counts), len(latency), err)
+	}
+
+	if err = manager.Delete(parentRelativePath, "..", ""); err == nil {
+		t.Errorf("delete invalid task key expect error")
+	}
+	if err = manager.Delete(parentRelativePath, taskKeys[0], ""); err != nil {
+		t.Fatal(err)
+	}
+	if ex, _ := util.PathExists(filesDir + parentRelativePath + taskKeys[0]); ex {
+		t.Errorf("task dir expect deleted")
+	}
+	// 按 请求 删除 相同 请求 的 任务
+	if err = manager.Delete(parentRelativePath, "", util.GetStringValue(taskList[0]["requestMd5"])); err != nil {
+		t.Fatal(err)
+	}
+	if taskList, _ = manager.LoadTasks(parentRelativePath); len(taskList) != 0 {
+		t.Errorf("tasks expect deleted, got %d", len(taskList))
+	}
+}
+
+func TestDownloadRecords(t *testing.T) {
+	filesDir := t.TempDir() + "/"
+	manager := NewManager("test-tasks", func() string { return filesDir })
+	if err := os.MkdirAll(filesDir+"test-tasks/toolbox-1/1", os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filesDir+"test-tasks/toolbox-1/1/records.txt", []byte("record"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.WriteFile(filesDir+"records.txt", []byte("secret"), 0666)
+
+	for _, one := range []struct {
+		path   string
+		expect string
+	}{
+		{"test-tasks/toolbox-1/1", "record"},
+		{"test-tasks/toolbox-1/2", "暂无执行记录"},
+		{"test-tasks/..", "invalid"},
+		{"other/../", "invalid"},
+		{"/test-tasks/toolbox-1/1", "invalid"},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		err := manager.DownloadRecords(c, "a.txt", one.path)
+		if !strings.Contains(w.Body.String(), one.expect) {
+			t.Errorf("download %s expect %s, got %s %v", one.path, one.expect, w.Body.String(), err)
+		}
+	}
+}